@@ -0,0 +1,72 @@
+// Command migrate-dynamodb applies the pending migrations of the DynamoDB documents table: new and
+// removed indexes, and backfills of the attributes they need. The service applies them at startup
+// unless DYNAMODB_SKIP_MIGRATIONS is set; run this command instead for migrations too long to hold
+// a deployment. It can be run again safely if interrupted, and resumes an interrupted backfill.
+// With -status it only lists the migrations already applied.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	cfgpkg "github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+	infrapkg "github.com/kristianrpo/document-management-microservice/internal/infrastructure/repository"
+)
+
+func main() {
+	status := flag.Bool("status", false, "list the applied migrations without applying any")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println(".env not found, using system environment variables")
+	}
+
+	config := cfgpkg.Load()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Hour)
+	defer cancel()
+
+	dynamoClient, err := cfgpkg.NewDynamoDBClient(
+		ctx,
+		config.AWSAccessKey,
+		config.AWSSecretKey,
+		config.AWSRegion,
+		config.DynamoDBEndpoint,
+	)
+	if err != nil {
+		log.Fatalf("dynamodb init: %v", err)
+	}
+
+	migrator := infrapkg.NewDynamoDBDocumentMigrator(dynamoClient, config.DynamoDBTable, config.DynamoDBHashGuardsTable, config.DynamoDBBlobRefsTable, config.DynamoDBUsageTable)
+
+	if *status {
+		applied, err := migrator.Applied(ctx)
+		if err != nil {
+			log.Fatalf("failed to read migrations: %v", err)
+		}
+		log.Printf("migrations applied to table %s: %s", config.DynamoDBTable, strings.Join(applied, ", "))
+		return
+	}
+
+	documentRepository := infrapkg.NewDynamoDBDocumentRepo(dynamoClient, config.DynamoDBTable, config.DynamoDBBlobRefsTable, config.DynamoDBOutboxTable, config.DynamoDBHashGuardsTable, config.DynamoDBUsageTable)
+	if err := documentRepository.EnsureTableExists(ctx); err != nil {
+		log.Fatalf("failed to ensure tables exist: %v", err)
+	}
+
+	log.Printf("migrating table %s", config.DynamoDBTable)
+	applied, err := migrator.Migrate(ctx)
+	if errors.Is(err, infrapkg.ErrMigrationsLocked) {
+		log.Fatalf("another runner is migrating table %s; try again once it is done", config.DynamoDBTable)
+	}
+	if err != nil {
+		log.Fatalf("failed to migrate: %v", err)
+	}
+
+	log.Printf("table %s is up to date: %d migrations applied", config.DynamoDBTable, len(applied))
+}
@@ -0,0 +1,78 @@
+// Command repair-blob-refs rebuilds the reference counters of content-addressed storage objects
+// from the documents table, in DynamoDB or PostgreSQL depending on DATABASE_BACKEND. The documents
+// stored before reference counting are counted by a migration of the documents table; run it
+// whenever the counters are suspected to have drifted.
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	cfgpkg "github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+	infrapkg "github.com/kristianrpo/document-management-microservice/internal/infrastructure/repository"
+)
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println(".env not found, using system environment variables")
+	}
+
+	config := cfgpkg.Load()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	var blobReferenceRepository interfaces.BlobReferenceRepository
+	if config.DatabaseBackend == cfgpkg.DatabaseBackendPostgres {
+		blobReferenceRepository = newPostgresBlobReferences(ctx, config)
+	} else {
+		blobReferenceRepository = newDynamoDBBlobReferences(ctx, config)
+	}
+
+	referenced, err := blobReferenceRepository.RebuildReferenceCounts(ctx)
+	if err != nil {
+		log.Fatalf("failed to rebuild blob reference counts: %v", err)
+	}
+
+	log.Printf("blob reference counts rebuilt: %d referenced objects", referenced)
+}
+
+// newDynamoDBBlobReferences creates the DynamoDB blob reference repository, creating its tables if needed
+func newDynamoDBBlobReferences(ctx context.Context, config *cfgpkg.Config) interfaces.BlobReferenceRepository {
+	dynamoClient, err := cfgpkg.NewDynamoDBClient(
+		ctx,
+		config.AWSAccessKey,
+		config.AWSSecretKey,
+		config.AWSRegion,
+		config.DynamoDBEndpoint,
+	)
+	if err != nil {
+		log.Fatalf("dynamodb init: %v", err)
+	}
+
+	documentRepository := infrapkg.NewDynamoDBDocumentRepo(dynamoClient, config.DynamoDBTable, config.DynamoDBBlobRefsTable, config.DynamoDBOutboxTable, config.DynamoDBHashGuardsTable, config.DynamoDBUsageTable)
+	if err := documentRepository.EnsureTableExists(ctx); err != nil {
+		log.Fatalf("failed to ensure tables exist: %v", err)
+	}
+
+	log.Printf("rebuilding blob reference counts from table %s into %s", config.DynamoDBTable, config.DynamoDBBlobRefsTable)
+	return infrapkg.NewDynamoDBBlobReferenceRepo(dynamoClient, config.DynamoDBTable, config.DynamoDBBlobRefsTable)
+}
+
+// newPostgresBlobReferences creates the PostgreSQL blob reference repository, migrating the schema if needed
+func newPostgresBlobReferences(ctx context.Context, config *cfgpkg.Config) interfaces.BlobReferenceRepository {
+	pool, err := cfgpkg.NewPostgresPool(ctx, config.PostgresURL)
+	if err != nil {
+		log.Fatalf("postgres init: %v", err)
+	}
+	if err := infrapkg.MigratePostgres(ctx, pool); err != nil {
+		log.Fatalf("postgres migrations: %v", err)
+	}
+
+	log.Println("rebuilding blob reference counts from PostgreSQL table documents")
+	return infrapkg.NewPostgresBlobReferenceRepo(pool)
+}
@@ -21,11 +21,10 @@ import (
 	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
 	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
 	"github.com/kristianrpo/document-management-microservice/internal/application/util"
+	domainevents "github.com/kristianrpo/document-management-microservice/internal/domain/events"
 	cfgpkg "github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
 	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/messaging"
 	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/metrics"
-
-	infrapkg "github.com/kristianrpo/document-management-microservice/internal/infrastructure/repository"
 )
 
 // @title Document Management Microservice API
@@ -68,68 +67,91 @@ func main() {
 	}
 
 	config := cfgpkg.Load()
+	if err := config.Validate(); err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
 
 	// Initialize Prometheus metrics
 	metricsCollector := metrics.NewPrometheusMetrics("documents_service")
 	log.Println("Prometheus metrics initialized")
 
-	dynamoClient, err := cfgpkg.NewDynamoDBClient(
-		context.Background(),
-		config.AWSAccessKey,
-		config.AWSSecretKey,
-		config.AWSRegion,
-		config.DynamoDBEndpoint,
-	)
-	if err != nil {
-		log.Fatalf("dynamodb init: %v", err)
+	var repos *repositories
+	switch config.DatabaseBackend {
+	case cfgpkg.DatabaseBackendMemory:
+		log.Println("STANDALONE mode enabled: running without DynamoDB, S3 or RabbitMQ")
+		repos = newMemoryRepositories()
+	case cfgpkg.DatabaseBackendPostgres:
+		repos = newPostgresRepositories(context.Background(), config)
+	case cfgpkg.DatabaseBackendDynamoDB:
+		repos = newDynamoDBRepositories(context.Background(), config)
+	default:
+		log.Fatalf("unsupported DATABASE_BACKEND %q", config.DatabaseBackend)
 	}
-	log.Printf("DynamoDB client initialized (endpoint: %s)", config.DynamoDBEndpoint)
+	documentRepository := repos.documents
+	blobReferenceRepository := repos.blobReferences
+	uploadSessionRepository := repos.uploadSessions
+	processedMessagesRepo := repos.processedMessages
+	outboxRepository := repos.outbox
+
 	if os.Getenv("DEBUG") == "true" {
 		log.Println("DEBUG mode enabled: error details will be included in responses")
 	} else {
 		log.Println("Set DEBUG=true to include error details in responses during local development")
 	}
 
-	s3Client, err := cfgpkg.NewS3Client(context.Background(), *config)
+	objectStorage, err := cfgpkg.NewObjectStorage(context.Background(), *config, metricsCollector)
 	if err != nil {
-		log.Fatalf("s3 init: %v", err)
-	}
-
-	documentRepository := infrapkg.NewDynamoDBDocumentRepo(dynamoClient, config.DynamoDBTable)
-
-	// Ensure the documents table exists (creates it if needed)
-	if err := documentRepository.EnsureTableExists(context.Background()); err != nil {
-		log.Printf("warning: failed to ensure documents table exists: %v", err)
-		log.Println("proceeding without documents table - operations will fail")
-	} else {
-		log.Println("Documents table verified/created successfully")
-	}
-
-	// Initialize processed messages repository for idempotency
-	// Uses the shared DynamoDB table from infrastructure (already exists, don't create it)
-	var processedMessagesRepo interfaces.ProcessedMessageRepository
-	processedMessagesTableName := config.DynamoDBProcessedMessagesTable
-	if processedMessagesTableName == "" {
-		log.Println("warning: DYNAMODB_PROCESSED_MESSAGES_TABLE not configured, message idempotency disabled")
-		processedMessagesRepo = nil
-	} else {
-		processedMessagesRepo = infrapkg.NewDynamoDBProcessedMessageRepository(dynamoClient, processedMessagesTableName)
-		log.Printf("Using shared DynamoDB table for processed messages: %s", processedMessagesTableName)
+		log.Fatalf("storage init: %v", err)
 	}
 
-	var objectStorage interfaces.ObjectStorage = s3Client
-
 	fileHasher := util.NewSHA256Hasher()
 	mimeDetector := util.NewExtensionBasedDetector()
 
+	// The broker topology routes published event types and names the consumed queues
+	topology, err := config.RabbitMQ.LoadTopology()
+	if err != nil {
+		log.Fatalf("rabbitmq topology: %v", err)
+	}
+
 	// Initialize shared RabbitMQ client
 	var rabbitMQClient *messaging.RabbitMQClient
 	var messagePublisher interfaces.MessagePublisher
 	var messageConsumer interfaces.MessageConsumer
 
-	if config.RabbitMQ.URL != "" {
+	if config.Standalone {
+		broker, err := messaging.NewMemoryBroker(config.MemoryBrokerFile, messaging.NewRetryPolicy(config.RabbitMQ), topology)
+		if err != nil {
+			log.Fatalf("message broker init: %v", err)
+		}
+		defer func() {
+			if err := broker.Close(); err != nil {
+				log.Printf("Error closing in-memory message broker: %v", err)
+			}
+		}()
+		messagePublisher = broker
+		messageConsumer = broker
+		log.Println("STANDALONE mode: using the in-process message broker instead of RabbitMQ")
+	} else if config.MessageBroker == cfgpkg.MessageBrokerKafka {
+		kafkaPublisher, err := messaging.NewKafkaPublisher(config.Kafka)
+		if err != nil {
+			log.Printf("warning: failed to initialize Kafka publisher: %v", err)
+		} else {
+			defer func() { _ = kafkaPublisher.Close() }()
+			messagePublisher = kafkaPublisher
+			log.Printf("Kafka publisher initialized (brokers: %v)", config.Kafka.Brokers)
+		}
+
+		kafkaConsumer, err := messaging.NewKafkaConsumer(config.Kafka, messaging.NewKafkaRetryPolicy(config.Kafka))
+		if err != nil {
+			log.Printf("warning: failed to initialize Kafka consumer: %v", err)
+		} else {
+			defer func() { _ = kafkaConsumer.Close() }()
+			messageConsumer = kafkaConsumer
+			log.Println("Kafka consumer initialized")
+		}
+	} else if config.RabbitMQ.URL != "" {
 		var err error
-		rabbitMQClient, err = messaging.NewRabbitMQClient(config.RabbitMQ)
+		rabbitMQClient, err = messaging.NewRabbitMQClient(config.RabbitMQ, topology)
 		if err != nil {
 			log.Printf("warning: failed to initialize RabbitMQ client: %v", err)
 			log.Println("continuing without RabbitMQ...")
@@ -162,25 +184,61 @@ func main() {
 		log.Println("RabbitMQ URL not configured, skipping RabbitMQ initialization")
 	}
 
+	// Lifecycle events are stored in the outbox with each write and published where the topology routes them
+	documentEvents := usecases.NewDocumentEvents()
+
+	quotaPolicy, err := config.Quotas.Policy()
+	if err != nil {
+		log.Fatalf("quota policy: %v", err)
+	}
+
 	documentService := usecases.NewDocumentService(
 		documentRepository,
 		objectStorage,
 		fileHasher,
 		mimeDetector,
+		documentEvents,
+		quotaPolicy,
 	)
-	documentListService := usecases.NewDocumentListService(documentRepository)
+	var uploadSessionService usecases.UploadSessionService
+	if multipartStorage, ok := objectStorage.(interfaces.MultipartObjectStorage); ok {
+		uploadSessionService = usecases.NewUploadSessionService(
+			uploadSessionRepository,
+			multipartStorage,
+			documentService.(interfaces.StagedDocumentUploader),
+			mimeDetector,
+			config.UploadSessionTTL,
+		)
+	}
+	var directUploadService usecases.DirectUploadService
+	if stagingStorage, ok := objectStorage.(interfaces.StagingObjectStorage); ok {
+		directUploadService = usecases.NewDirectUploadService(
+			uploadSessionRepository,
+			stagingStorage,
+			documentService.(interfaces.StagedDocumentUploader),
+			fileHasher,
+			mimeDetector,
+			config.UploadSessionTTL,
+		)
+	}
+	cursorCodec, err := cfgpkg.NewCursorCodec(*config)
+	if err != nil {
+		log.Fatalf("cursor codec init: %v", err)
+	}
+	documentListService := usecases.NewDocumentListService(documentRepository, cursorCodec)
 	documentGetService := usecases.NewDocumentGetService(documentRepository, objectStorage)
-	documentDeleteService := usecases.NewDocumentDeleteService(documentRepository, objectStorage)
-	documentDeleteAllService := usecases.NewDocumentDeleteAllService(documentRepository, objectStorage)
+	documentDeleteService := usecases.NewDocumentDeleteService(documentRepository, documentEvents, config.TrashRetention)
+	documentDeleteAllService := usecases.NewDocumentDeleteAllService(documentRepository, documentEvents, config.TrashRetention)
+	documentRestoreService := usecases.NewDocumentRestoreService(documentRepository, documentEvents, quotaPolicy)
+	documentPurgeService := usecases.NewDocumentPurgeService(documentRepository, objectStorage, blobReferenceRepository, config.PurgeInterval)
 	documentTransferService := usecases.NewDocumentTransferService(documentRepository, objectStorage, 15*time.Minute)
+	documentUsageService := usecases.NewDocumentUsageService(documentRepository, quotaPolicy)
 
 	var documentRequestAuthService usecases.DocumentRequestAuthenticationService
 	if messagePublisher != nil {
 		documentRequestAuthService = usecases.NewDocumentRequestAuthenticationService(
 			documentRepository,
 			objectStorage,
-			messagePublisher,
-			config.RabbitMQ.AuthenticationRequestQueue,
 			24*time.Hour,
 		)
 	}
@@ -195,12 +253,29 @@ func main() {
 	deleteHandler := handlers.NewDocumentDeleteHandler(documentDeleteService, documentGetService, errorHandler, metricsCollector)
 	deleteAllHandler := handlers.NewDocumentDeleteAllHandler(documentDeleteAllService, errorHandler, metricsCollector)
 	transferHandler := handlers.NewDocumentTransferHandler(documentTransferService, errorHandler, metricsCollector)
+	usageHandler := handlers.NewDocumentUsageHandler(documentUsageService, errorHandler)
+	restoreHandler := handlers.NewDocumentRestoreHandler(documentRestoreService, errorHandler)
 
 	var requestAuthHandler *handlers.DocumentRequestAuthenticationHandler
 	if documentRequestAuthService != nil {
 		requestAuthHandler = handlers.NewDocumentRequestAuthenticationHandler(documentRequestAuthService, documentGetService, errorHandler, metricsCollector)
 	}
 
+	var uploadSessionHandler *handlers.UploadSessionHandler
+	if uploadSessionService != nil {
+		uploadSessionHandler = handlers.NewUploadSessionHandler(uploadSessionService, errorHandler, metricsCollector)
+	}
+
+	var directUploadHandler *handlers.DirectUploadHandler
+	if directUploadService != nil {
+		directUploadHandler = handlers.NewDirectUploadHandler(directUploadService, errorHandler, metricsCollector)
+	}
+
+	var signedFileHandler *handlers.SignedFileHandler
+	if signedObjectServer, ok := objectStorage.(interfaces.SignedObjectServer); ok {
+		signedFileHandler = handlers.NewSignedFileHandler(signedObjectServer, errorHandler)
+	}
+
 	healthHandler := handlers.NewHealthHandler()
 
 	var jwtMiddleware *middleware.JWTAuthMiddleware
@@ -212,50 +287,98 @@ func main() {
 	}
 
 	routerConfig := &httpadapter.RouterConfig{
-		UploadHandler:      uploadHandler,
-		ListHandler:        listHandler,
-		GetHandler:         getHandler,
-		DeleteHandler:      deleteHandler,
-		DeleteAllHandler:   deleteAllHandler,
-		TransferHandler:    transferHandler,
-		RequestAuthHandler: requestAuthHandler,
-		HealthHandler:      healthHandler,
-		MetricsCollector:   metricsCollector,
-		JWTMiddleware:      jwtMiddleware,
+		UploadHandler:        uploadHandler,
+		ListHandler:          listHandler,
+		GetHandler:           getHandler,
+		DeleteHandler:        deleteHandler,
+		DeleteAllHandler:     deleteAllHandler,
+		TransferHandler:      transferHandler,
+		RequestAuthHandler:   requestAuthHandler,
+		UsageHandler:         usageHandler,
+		RestoreHandler:       restoreHandler,
+		UploadSessionHandler: uploadSessionHandler,
+		DirectUploadHandler:  directUploadHandler,
+		SignedFileHandler:    signedFileHandler,
+		HealthHandler:        healthHandler,
+		MetricsCollector:     metricsCollector,
+		JWTMiddleware:        jwtMiddleware,
 	}
 
 	router := httpadapter.NewRouter(routerConfig)
 
-	// Start consuming messages if messageConsumer is initialized
-	ctx := context.Background()
+	// Start consuming messages if messageConsumer is initialized. The consumers are drained on shutdown
+	ctx, stopConsumers := context.WithCancel(context.Background())
+	defer stopConsumers()
 	if messageConsumer != nil {
 		// Set up event handlers
-		userTransferHandler := events.NewUserTransferHandler(documentDeleteAllService)
-		authenticationHandler := events.NewDocumentAuthenticationHandler(documentRepository, processedMessagesRepo)
-		downloadHandler := events.NewDocumentDownloadHandler(documentService.(interfaces.DocumentUploader), messagePublisher, "documents.ready")
-
-		// Subscribe to user transfer events
-		if err := messageConsumer.SubscribeToQueue(ctx, config.RabbitMQ.ConsumerQueue, userTransferHandler.HandleUserTransferred); err != nil {
-			log.Printf("warning: failed to subscribe to user transfer queue: %v", err)
-		} else {
-			log.Printf("listening for user transfer events on queue: %s", config.RabbitMQ.ConsumerQueue)
+		eventRegistry := domainevents.NewDefaultRegistry()
+		// The documents of a transferred citizen stay in the trash for the grace period only
+		transferDeleteAllService := usecases.NewDocumentDeleteAllService(documentRepository, documentEvents, config.TransferGracePeriod)
+		userTransferHandler := events.NewUserTransferHandler(transferDeleteAllService, eventRegistry)
+		authenticationHandler := events.NewDocumentAuthenticationHandler(documentRepository, eventRegistry, documentEvents)
+		downloadHandler := events.NewDocumentDownloadHandler(documentService.(interfaces.DocumentUploader), outboxRepository, eventRegistry)
+		idempotent := events.NewIdempotentHandler(processedMessagesRepo, config.IdempotencyLease)
+
+		// Subscribe each handler to the queue the topology delivers its event type on, or to the
+		// topic of its event type on Kafka
+		consumerQueue := topology.ConsumerQueue
+		if !config.Standalone && config.MessageBroker == cfgpkg.MessageBrokerKafka {
+			consumerQueue = config.Kafka.Topic
 		}
-
-		// Subscribe to authentication result events
-		if err := messageConsumer.SubscribeToQueue(ctx, config.RabbitMQ.AuthenticationResultQueue, authenticationHandler.HandleAuthenticationCompleted); err != nil {
-			log.Printf("warning: failed to subscribe to authentication result queue: %v", err)
-		} else {
-			log.Printf("listening for authentication result events on queue: %s", config.RabbitMQ.AuthenticationResultQueue)
+		subscriptions := []struct {
+			eventType string
+			name      string
+			handler   interfaces.MessageHandler
+		}{
+			{domainevents.TypeUserTransferred, "user-transfer-handler", userTransferHandler.HandleUserTransferred},
+			{domainevents.TypeDocumentAuthenticationCompleted, "authentication-handler", authenticationHandler.HandleAuthenticationCompleted},
+			{domainevents.TypeDocumentDownloadRequested, "download-handler", downloadHandler.HandleDownloadRequested},
+		}
+		for _, subscription := range subscriptions {
+			queue := consumerQueue(subscription.eventType)
+			if queue == "" {
+				log.Printf("warning: no queue configured for %s events, not consuming them", subscription.eventType)
+				continue
+			}
+			if err := messageConsumer.SubscribeToQueue(ctx, queue, idempotent.Wrap(subscription.name, subscription.handler)); err != nil {
+				log.Printf("warning: failed to subscribe to %s queue: %v", subscription.eventType, err)
+			} else {
+				log.Printf("listening for %s events on queue: %s", subscription.eventType, queue)
+			}
 		}
+	}
 
-		// Subscribe to document download requested events
-		if err := messageConsumer.SubscribeToQueue(ctx, "documents.download.requested", downloadHandler.HandleDownloadRequested); err != nil {
-			log.Printf("warning: failed to subscribe to document download requested queue: %v", err)
-		} else {
-			log.Printf("listening for document download requested events on queue: documents.download.requested")
+	// Publish the events stored in the outbox. The relay outlives the consumers on shutdown so the
+	// events of the messages they drain still go out
+	relayContext, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	relayDone := make(chan struct{})
+	if messagePublisher != nil {
+		// A document whose authentication request is never published goes back to unauthenticated
+		authenticationHandler := events.NewDocumentAuthenticationHandler(documentRepository, domainevents.NewDefaultRegistry(), documentEvents)
+		parked := map[string]interfaces.MessageHandler{
+			domainevents.TypeDocumentAuthenticationRequested: authenticationHandler.HandleAuthenticationRequestParked,
 		}
+		relay := messaging.NewOutboxRelay(outboxRepository, messagePublisher, config.OutboxRelayInterval,
+			messaging.NewOutboxRetryPolicy(config.OutboxMaxAttempts, config.OutboxRelayInterval, config.OutboxRetryMaxDelay),
+			parked, metricsCollector)
+		go func() {
+			defer close(relayDone)
+			relay.Run(relayContext)
+		}()
+	} else {
+		close(relayDone)
 	}
 
+	// Delete for good the trashed documents past their retention
+	purgeContext, stopPurge := context.WithCancel(context.Background())
+	defer stopPurge()
+	purgeDone := make(chan struct{})
+	go func() {
+		defer close(purgeDone)
+		documentPurgeService.Run(purgeContext)
+	}()
+
 	server := &http.Server{
 		Addr:              config.Port,
 		Handler:           router,
@@ -273,12 +396,29 @@ func main() {
 	signal.Notify(stopSignal, os.Interrupt, syscall.SIGTERM)
 	<-stopSignal
 
-	shutdownContext, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownContext, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(shutdownContext); err != nil {
 		log.Printf("graceful shutdown error: %v", err)
 	}
 
+	// Stop taking new messages and let the in-flight ones finish within the same deadline
+	if messageConsumer != nil {
+		if err := messageConsumer.Shutdown(shutdownContext); err != nil {
+			log.Printf("consumer shutdown error: %v", err)
+		} else {
+			log.Println("consumers drained")
+		}
+	}
+
+	// Documents not purged yet are purged after the next start
+	stopPurge()
+	<-purgeDone
+
+	// Anything still pending stays in the outbox for the next start
+	stopRelay()
+	<-relayDone
+
 	log.Println("server stopped")
 }
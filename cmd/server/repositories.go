@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	cfgpkg "github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+	infrapkg "github.com/kristianrpo/document-management-microservice/internal/infrastructure/repository"
+)
+
+// migrationWaitInterval is how often a replica checks whether another one is done migrating
+const migrationWaitInterval = 15 * time.Second
+
+// repositories groups the persistence dependencies of the service
+type repositories struct {
+	documents         interfaces.DocumentRepository
+	blobReferences    interfaces.BlobReferenceRepository
+	uploadSessions    interfaces.UploadSessionRepository
+	processedMessages interfaces.ProcessedMessageRepository
+	outbox            interfaces.OutboxRepository
+}
+
+// newMemoryRepositories creates in-memory repositories for standalone mode. Nothing survives a restart
+func newMemoryRepositories() *repositories {
+	store := infrapkg.NewMemoryDocumentStore()
+	log.Println("Using in-memory repositories (data is lost on restart)")
+
+	return &repositories{
+		documents:         infrapkg.NewMemoryDocumentRepo(store),
+		blobReferences:    infrapkg.NewMemoryBlobReferenceRepo(store),
+		uploadSessions:    infrapkg.NewMemoryUploadSessionRepo(),
+		processedMessages: infrapkg.NewMemoryProcessedMessageRepository(),
+		outbox:            infrapkg.NewMemoryOutboxRepo(store),
+	}
+}
+
+// newDynamoDBRepositories creates the DynamoDB repositories, creating their tables if needed
+func newDynamoDBRepositories(ctx context.Context, config *cfgpkg.Config) *repositories {
+	dynamoClient, err := cfgpkg.NewDynamoDBClient(
+		ctx,
+		config.AWSAccessKey,
+		config.AWSSecretKey,
+		config.AWSRegion,
+		config.DynamoDBEndpoint,
+	)
+	if err != nil {
+		log.Fatalf("dynamodb init: %v", err)
+	}
+	log.Printf("DynamoDB client initialized (endpoint: %s)", config.DynamoDBEndpoint)
+
+	repos := &repositories{
+		documents:      infrapkg.NewDynamoDBDocumentRepo(dynamoClient, config.DynamoDBTable, config.DynamoDBBlobRefsTable, config.DynamoDBOutboxTable, config.DynamoDBHashGuardsTable, config.DynamoDBUsageTable),
+		blobReferences: infrapkg.NewDynamoDBBlobReferenceRepo(dynamoClient, config.DynamoDBTable, config.DynamoDBBlobRefsTable),
+		uploadSessions: infrapkg.NewDynamoDBUploadSessionRepo(dynamoClient, config.DynamoDBUploadSessionsTable),
+		outbox:         infrapkg.NewDynamoDBOutboxRepo(dynamoClient, config.DynamoDBOutboxTable),
+	}
+
+	// Ensure the documents table and the tables written along with it exist (creates them if needed)
+	if err := repos.documents.EnsureTableExists(ctx); err != nil {
+		log.Printf("warning: failed to ensure documents tables exist: %v", err)
+	} else {
+		log.Println("Documents tables verified/created successfully")
+	}
+
+	// The documents are queried from indexes added by the migrations, so the service never
+	// serves a table missing them
+	migrateDocumentsTable(ctx, config, dynamoClient)
+
+	if err := repos.uploadSessions.EnsureTableExists(ctx); err != nil {
+		log.Printf("warning: failed to ensure upload sessions table exists: %v", err)
+	}
+
+	if err := repos.outbox.EnsureTableExists(ctx); err != nil {
+		log.Printf("warning: failed to ensure outbox table exists: %v", err)
+	}
+
+	// Initialize processed messages repository for idempotency
+	// Uses the shared DynamoDB table from infrastructure (already exists, don't create it)
+	processedMessagesTableName := config.DynamoDBProcessedMessagesTable
+	if processedMessagesTableName == "" {
+		log.Println("warning: DYNAMODB_PROCESSED_MESSAGES_TABLE not configured, message idempotency disabled")
+	} else {
+		repos.processedMessages = infrapkg.NewDynamoDBProcessedMessageRepository(dynamoClient, processedMessagesTableName)
+		log.Printf("Using shared DynamoDB table for processed messages: %s", processedMessagesTableName)
+	}
+
+	return repos
+}
+
+// migrateDocumentsTable applies the pending migrations of the documents table, waiting for
+// another replica applying them. When they are left to the migrate-dynamodb command it only checks
+// none is pending. Exits if the table cannot be brought up to date
+func migrateDocumentsTable(ctx context.Context, config *cfgpkg.Config, dynamoClient *dynamodb.Client) {
+	migrator := infrapkg.NewDynamoDBDocumentMigrator(dynamoClient, config.DynamoDBTable, config.DynamoDBHashGuardsTable, config.DynamoDBBlobRefsTable, config.DynamoDBUsageTable)
+
+	if config.DynamoDBSkipMigrations {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			log.Fatalf("documents table migrations: %v", err)
+		}
+		if len(pending) > 0 {
+			log.Fatalf("documents table migrations pending (%s): run migrate-dynamodb before starting the service", strings.Join(pending, ", "))
+		}
+		log.Println("DYNAMODB_SKIP_MIGRATIONS set: documents table migrations were applied by migrate-dynamodb")
+		return
+	}
+
+	for {
+		applied, err := migrator.Migrate(ctx)
+		switch {
+		case errors.Is(err, infrapkg.ErrMigrationsLocked):
+			log.Println("documents table migrations are being applied by another replica, waiting")
+			select {
+			case <-ctx.Done():
+				log.Fatalf("documents table migrations: %v", ctx.Err())
+			case <-time.After(migrationWaitInterval):
+			}
+		case err != nil:
+			log.Fatalf("documents table migrations: %v", err)
+		default:
+			log.Printf("Documents table migrated successfully (%d migrations applied)", len(applied))
+			return
+		}
+	}
+}
+
+// newPostgresRepositories creates the PostgreSQL repositories, migrating the database schema first
+func newPostgresRepositories(ctx context.Context, config *cfgpkg.Config) *repositories {
+	pool, err := cfgpkg.NewPostgresPool(ctx, config.PostgresURL)
+	if err != nil {
+		log.Fatalf("postgres init: %v", err)
+	}
+	log.Println("PostgreSQL connection pool initialized")
+
+	// The repositories share one schema, migrated as a whole
+	if err := infrapkg.MigratePostgres(ctx, pool); err != nil {
+		log.Fatalf("postgres migrations: %v", err)
+	}
+	log.Println("PostgreSQL schema migrated successfully")
+
+	return &repositories{
+		documents:         infrapkg.NewPostgresDocumentRepo(pool),
+		blobReferences:    infrapkg.NewPostgresBlobReferenceRepo(pool),
+		uploadSessions:    infrapkg.NewPostgresUploadSessionRepo(pool),
+		processedMessages: infrapkg.NewPostgresProcessedMessageRepository(pool),
+		outbox:            infrapkg.NewPostgresOutboxRepo(pool),
+	}
+}
@@ -29,7 +29,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Retrieves a paginated list of documents for a specific owner (identified by citizen ID).\n\n## Features\n- Returns documents sorted by creation date (most recent first)\n- Supports pagination with configurable page size\n- Includes pagination metadata (total items, total pages, current page)\n- Maximum limit per page: 100 documents\n- Default page size: 10 documents\n\n## Pagination\n- Use ` + "`" + `page` + "`" + ` parameter to navigate through results (starts at 1)\n- Use ` + "`" + `limit` + "`" + ` parameter to control page size (1-100)\n- Response includes total count and total pages for UI rendering\n\n## Error Codes\n- ` + "`" + `VALIDATION_ERROR` + "`" + `: Invalid id_citizen or pagination parameters\n- ` + "`" + `PERSISTENCE_ERROR` + "`" + `: Failed to retrieve documents from database",
+                "description": "Retrieves a paginated list of documents for a specific owner (identified by citizen ID).\n\n## Features\n- Returns documents sorted by creation date (most recent first) unless ` + "`" + `sort` + "`" + ` is given\n- Sorts by ` + "`" + `created_at` + "`" + `, ` + "`" + `filename` + "`" + ` (ignoring case) or ` + "`" + `size` + "`" + ` with ` + "`" + `sort` + "`" + `, in the ` + "`" + `order` + "`" + ` given by ` + "`" + `asc` + "`" + ` or ` + "`" + `desc` + "`" + `\n- Filters by ` + "`" + `authentication_status` + "`" + `, MIME type family (` + "`" + `mime_type` + "`" + `, such as ` + "`" + `image` + "`" + `), creation date range (` + "`" + `created_from` + "`" + `, ` + "`" + `created_to` + "`" + `) and filename substring (` + "`" + `filename` + "`" + `, ignoring case)\n- Supports page number and cursor pagination with configurable page size\n- Maximum limit per page: 100 documents\n- Default page size: 10 documents\n\n## Pagination\n- By default documents are listed by page number: ` + "`" + `page` + "`" + ` (starting at 1, default 1) selects the page, and ` + "`" + `total_items` + "`" + ` and ` + "`" + `total_pages` + "`" + ` are always returned; documents come most recent first and deep pages get slower\n- Passing ` + "`" + `cursor` + "`" + `, ` + "`" + `include_total` + "`" + `, ` + "`" + `sort` + "`" + `, ` + "`" + `order` + "`" + ` or any filter lists by cursor instead, and ` + "`" + `page` + "`" + ` must then be left out\n- By cursor, pass the ` + "`" + `next_cursor` + "`" + ` of a response as ` + "`" + `cursor` + "`" + ` to get the following page; the last page has no ` + "`" + `next_cursor` + "`" + `\n- Cursors are opaque and signed; they are only valid for the citizen they were issued to, with the ` + "`" + `sort` + "`" + `, ` + "`" + `order` + "`" + `, filters and trash view of the request that returned them\n- By cursor, use ` + "`" + `include_total=true` + "`" + ` to also get ` + "`" + `total_items` + "`" + `, the number of documents matching the filters; counting reads all of them, so request it only when needed\n- Use ` + "`" + `limit` + "`" + ` parameter to control page size (1-100)\n\n## Error Codes\n- ` + "`" + `VALIDATION_ERROR` + "`" + `: Invalid id_citizen, cursor, sort, filter or pagination parameters\n- ` + "`" + `PERSISTENCE_ERROR` + "`" + `: Failed to retrieve documents from database",
                 "consumes": [
                     "application/json"
                 ],
@@ -42,12 +42,9 @@ const docTemplate = `{
                 "summary": "List documents",
                 "parameters": [
                     {
-                        "minimum": 1,
-                        "type": "integer",
-                        "default": 1,
-                        "example": 1,
-                        "description": "Page number (starts at 1)",
-                        "name": "page",
+                        "type": "string",
+                        "description": "Cursor of the page to retrieve, from the next_cursor of the previous page",
+                        "name": "cursor",
                         "in": "query"
                     },
                     {
@@ -59,6 +56,93 @@ const docTemplate = `{
                         "description": "Number of items per page (max 100)",
                         "name": "limit",
                         "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Include the total number of documents",
+                        "name": "include_total",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "created_at",
+                            "filename",
+                            "size"
+                        ],
+                        "type": "string",
+                        "default": "created_at",
+                        "description": "Attribute the documents are sorted by",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Sort direction; desc by default for created_at, asc otherwise",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "unauthenticated",
+                            "authenticating",
+                            "authenticated"
+                        ],
+                        "type": "string",
+                        "description": "Only documents in this authentication state",
+                        "name": "authentication_status",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "application",
+                            "audio",
+                            "font",
+                            "image",
+                            "message",
+                            "model",
+                            "multipart",
+                            "text",
+                            "video"
+                        ],
+                        "type": "string",
+                        "description": "Only documents of this MIME type family",
+                        "name": "mime_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2025-01-01",
+                        "description": "Only documents created at or after this date (YYYY-MM-DD) or time (RFC 3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2025-12-31",
+                        "description": "Only documents created at or before this date, the whole day included, or time",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "maxLength": 255,
+                        "type": "string",
+                        "description": "Only documents whose filename contains this text, ignoring case",
+                        "name": "filename",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 1,
+                        "example": 1,
+                        "description": "Page number (starts at 1); not allowed with cursor pagination",
+                        "name": "page",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -69,7 +153,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Validation error - invalid id_citizen or pagination parameters",
+                        "description": "Validation error - invalid id_citizen, cursor, sort, filter or pagination parameters",
                         "schema": {
                             "$ref": "#/definitions/endpoints.ListErrorResponse"
                         }
@@ -109,10 +193,28 @@ const docTemplate = `{
                     }
                 ],
                 "responses": {
+                    "200": {
+                        "description": "The owner already had the same file; the existing document is returned",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UploadResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the document"
+                            }
+                        }
+                    },
                     "201": {
                         "description": "Document uploaded successfully",
                         "schema": {
                             "$ref": "#/definitions/endpoints.UploadResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the document"
+                            }
                         }
                     },
                     "400": {
@@ -127,6 +229,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/endpoints.UploadErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Document quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UploadErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Storage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UploadErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -185,6 +299,179 @@ const docTemplate = `{
                 }
             }
         },
+        "/api/docs/documents/trash": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieves a paginated list of the deleted documents of the authenticated user still in the trash.\n\n## Features\n- Deleted documents stay in the trash until their ` + "`" + `purge_at` + "`" + ` time, then they and their files are deleted for good\n- Each document holds the time it was deleted (` + "`" + `deleted_at` + "`" + `) and the time it will be purged (` + "`" + `purge_at` + "`" + `)\n- Restore a document with ` + "`" + `POST /api/docs/documents/{id}/restore` + "`" + `\n- Sorts, filters and paginates by cursor like the document list; ` + "`" + `page` + "`" + ` is not supported\n\n## Error Codes\n- ` + "`" + `VALIDATION_ERROR` + "`" + `: Invalid cursor, sort, filter or pagination parameters\n- ` + "`" + `PERSISTENCE_ERROR` + "`" + `: Failed to retrieve documents from database",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "List the trash",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Cursor of the page to retrieve, from the next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 10,
+                        "example": 10,
+                        "description": "Number of items per page (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Include the total number of documents in the trash",
+                        "name": "include_total",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "created_at",
+                            "filename",
+                            "size"
+                        ],
+                        "type": "string",
+                        "default": "created_at",
+                        "description": "Attribute the documents are sorted by",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Sort direction; desc by default for created_at, asc otherwise",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "unauthenticated",
+                            "authenticating",
+                            "authenticated"
+                        ],
+                        "type": "string",
+                        "description": "Only documents in this authentication state",
+                        "name": "authentication_status",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "application",
+                            "audio",
+                            "font",
+                            "image",
+                            "message",
+                            "model",
+                            "multipart",
+                            "text",
+                            "video"
+                        ],
+                        "type": "string",
+                        "description": "Only documents of this MIME type family",
+                        "name": "mime_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2025-01-01",
+                        "description": "Only documents created at or after this date (YYYY-MM-DD) or time (RFC 3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "2025-12-31",
+                        "description": "Only documents created at or before this date, the whole day included, or time",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "maxLength": 255,
+                        "type": "string",
+                        "description": "Only documents whose filename contains this text, ignoring case",
+                        "name": "filename",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Documents in the trash retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.ListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error - invalid cursor, sort, filter or pagination parameters",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.ListErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error - database error",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.ListErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/docs/documents/usage": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the number and total size of the documents of the authenticated user (citizen ID from JWT)\nalong with their quota. A limit of 0 is unlimited. Documents in the trash do not count.\n\nUploads and restores taking the user past the quota fail with ` + "`" + `QUOTA_EXCEEDED` + "`" + `: 409 when they have too many\ndocuments, 413 when the document does not fit in the bytes left.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Get the storage usage of the authenticated user",
+                "responses": {
+                    "200": {
+                        "description": "Usage retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UsageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error - invalid citizen ID",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UsageErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error - database error",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UsageErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/api/docs/documents/user/delete-all": {
             "delete": {
                 "security": [
@@ -192,7 +479,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Deletes all documents belonging to the authenticated user (citizen ID from JWT) and their associated files from S3 storage.\n\n## Features\n- Deletes all document metadata from DynamoDB for the authenticated user\n- Removes all physical files from S3 storage\n- Returns the count of deleted documents\n- Useful for account closure or data migration scenarios\n\n## Use Cases\n- Account closure/deletion\n- Data migration to another system\n- Bulk cleanup operations\n- GDPR/privacy compliance (right to be forgotten)\n\n## Error Codes\n- ` + "`" + `VALIDATION_ERROR` + "`" + `: Invalid citizen ID\n- ` + "`" + `PERSISTENCE_ERROR` + "`" + `: Failed to delete documents from database",
+                "description": "Moves all documents belonging to the authenticated user (citizen ID from JWT) to the trash, from which they can be restored until they are purged.\n\n## Features\n- Moves every document of the authenticated user outside the trash to the trash\n- The documents no longer count in the quota of the user\n- The documents and their files are deleted for good once the trash retention is over (30 days by default)\n- Returns the count of deleted documents\n- Useful for account closure or data migration scenarios\n\n## Use Cases\n- Account closure/deletion\n- Data migration to another system\n- Bulk cleanup operations\n- GDPR/privacy compliance (right to be forgotten)\n\n## Error Codes\n- ` + "`" + `VALIDATION_ERROR` + "`" + `: Invalid citizen ID\n- ` + "`" + `PERSISTENCE_ERROR` + "`" + `: Failed to move the documents to the trash in the database",
                 "consumes": [
                     "application/json"
                 ],
@@ -205,7 +492,7 @@ const docTemplate = `{
                 "summary": "Delete all documents for the authenticated user",
                 "responses": {
                     "200": {
-                        "description": "All documents deleted successfully",
+                        "description": "All documents moved to the trash",
                         "schema": {
                             "$ref": "#/definitions/endpoints.DeleteAllResponse"
                         }
@@ -217,7 +504,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Internal server error - database or storage error",
+                        "description": "Internal server error - database error",
                         "schema": {
                             "$ref": "#/definitions/endpoints.DeleteAllErrorResponse"
                         }
@@ -232,7 +519,61 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Retrieves detailed information about a specific document by its ID.\n\n## Features\n- Returns complete document metadata including URL for viewing/downloading\n- URL is pre-signed and ready to use in frontend viewers\n- Includes file information (size, type, hash, etc.)\n\n## Use Cases\n- Display document details in UI\n- Preview documents in viewers (PDF, images, etc.)\n- Download documents\n- Verify document integrity using hash\n\n## Error Codes\n- ` + "`" + `NOT_FOUND` + "`" + `: Document with the specified ID does not exist\n- ` + "`" + `PERSISTENCE_ERROR` + "`" + `: Failed to retrieve document from database",
+                "description": "Retrieves detailed information about a specific document by its ID.\n\n## Features\n- Returns complete document metadata including URL for viewing/downloading\n- URL is pre-signed and ready to use in frontend viewers\n- Includes file information (size, type, hash, etc.)\n- The ` + "`" + `ETag` + "`" + ` header holds the version of the document, to send as ` + "`" + `If-Match` + "`" + ` when changing it\n\n## Use Cases\n- Display document details in UI\n- Preview documents in viewers (PDF, images, etc.)\n- Download documents\n- Verify document integrity using hash\n\n## Error Codes\n- ` + "`" + `NOT_FOUND` + "`" + `: Document with the specified ID does not exist\n- ` + "`" + `PERSISTENCE_ERROR` + "`" + `: Failed to retrieve document from database",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Get document by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "123e4567-e89b-12d3-a456-426614174000",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Document retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.GetResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the document"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.GetErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error - database error",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.GetErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Moves a document to the trash, from which it can be restored until it is purged.\n\n## Features\n- The document leaves the document list and can no longer be retrieved, but is listed in the trash\n- The document no longer counts in the quota of the user\n- The document and its file are deleted for good once the trash retention is over (30 days by default)\n- Restore the document with ` + "`" + `POST /api/docs/documents/{id}/restore` + "`" + ` until then\n- Returns 404 if document doesn't exist or is in the trash already\n- With ` + "`" + `If-Match` + "`" + ` set to the ETag of the document, deletes it only if it has not changed since\n\n## Use Cases\n- Remove unwanted documents\n- Clean up storage space\n- Comply with data deletion requests\n\n## Error Codes\n- ` + "`" + `VALIDATION_ERROR` + "`" + `: ` + "`" + `If-Match` + "`" + ` is not an ETag of the document\n- ` + "`" + `NOT_FOUND` + "`" + `: Document with the specified ID does not exist\n- ` + "`" + `CONFLICT` + "`" + `: The document changed since the ` + "`" + `If-Match` + "`" + ` version, or while it was being deleted\n- ` + "`" + `PERSISTENCE_ERROR` + "`" + `: Failed to move the document to the trash in the database",
                 "consumes": [
                     "application/json"
                 ],
@@ -240,14 +581,568 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "documents"
+                    "documents"
+                ],
+                "summary": "Delete a document by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "123e4567-e89b-12d3-a456-426614174000",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"3\"",
+                        "description": "ETag of the document version to delete",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Document moved to the trash",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DeleteResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid If-Match header",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DeleteErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DeleteErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Document changed since the If-Match version",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DeleteErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error - database error",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DeleteErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/docs/documents/{id}/request-authentication": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Requests authentication of a document by publishing an event for external authentication service. The document owner's citizen ID and filename are automatically included in the event. With ` + "`" + `If-Match` + "`" + ` set to the ETag of the document, the request is only made if the document has not changed since.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Request document authentication",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"3\"",
+                        "description": "ETag of the document version to authenticate",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Authentication request accepted",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RequestAuthenticationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RequestAuthenticationErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RequestAuthenticationErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Document changed since the If-Match version",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RequestAuthenticationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RequestAuthenticationErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/docs/documents/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Takes a deleted document of the authenticated user out of the trash before it is purged.\n\n## Features\n- Deleted documents stay in the trash until their ` + "`" + `purge_at` + "`" + ` time, then they and their files are deleted for good\n- The restored document is listed and can be retrieved again\n- The restored document counts in the quota of the user again\n- The ` + "`" + `ETag` + "`" + ` header holds the new version of the document\n- With ` + "`" + `If-Match` + "`" + ` set to the ETag of the trashed document, restores it only if it has not changed since\n\n## Error Codes\n- ` + "`" + `VALIDATION_ERROR` + "`" + `: ` + "`" + `If-Match` + "`" + ` is not an ETag of the document, or the document belongs to another user\n- ` + "`" + `NOT_FOUND` + "`" + `: No document with the specified ID is in the trash\n- ` + "`" + `CONFLICT` + "`" + `: The document changed since the ` + "`" + `If-Match` + "`" + ` version, or while it was being restored\n- ` + "`" + `QUOTA_EXCEEDED` + "`" + `: The document would take the user past their quota\n- ` + "`" + `PERSISTENCE_ERROR` + "`" + `: Failed to restore the document in the database",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "documents"
+                ],
+                "summary": "Restore a document from the trash",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "123e4567-e89b-12d3-a456-426614174000",
+                        "description": "Document ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"3\"",
+                        "description": "ETag of the trashed document version to restore",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Document restored successfully",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RestoreResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the document"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid If-Match header or document of another user",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RestoreErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Document not found in the trash",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RestoreErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Document changed since the If-Match version, or document quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RestoreErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Storage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RestoreErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error - database error",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.RestoreErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/docs/files/{key}": {
+            "get": {
+                "description": "Serves an object of the local filesystem storage. The URL is obtained from the API (for example the\ntransfer or authentication endpoints) and expires after the time it was signed for.",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "files"
+                ],
+                "summary": "Download a file through a signed URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Object key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiration as a Unix timestamp",
+                        "name": "expires",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "URL signature",
+                        "name": "signature",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File content",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired signature",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.SignedFileErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "File not found",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.SignedFileErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Stores an object in the local filesystem storage through a URL returned by the direct upload endpoint.\n` + "`" + `PUT` + "`" + ` requests carry the file as the body; ` + "`" + `POST` + "`" + ` requests are multipart forms with the signed fields\nfollowed by a ` + "`" + `file` + "`" + ` field. Files that do not match the signed size, content type or checksum are rejected.",
+                "consumes": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "files"
+                ],
+                "summary": "Upload a file through a signed URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Object key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File stored (PUT)"
+                    },
+                    "204": {
+                        "description": "File stored (POST)"
+                    },
+                    "400": {
+                        "description": "File does not match the signed policy",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.SignedFileErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired signature",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.SignedFileErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Stores an object in the local filesystem storage through a URL returned by the direct upload endpoint.\n` + "`" + `PUT` + "`" + ` requests carry the file as the body; ` + "`" + `POST` + "`" + ` requests are multipart forms with the signed fields\nfollowed by a ` + "`" + `file` + "`" + ` field. Files that do not match the signed size, content type or checksum are rejected.",
+                "consumes": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "files"
+                ],
+                "summary": "Upload a file through a signed URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Object key",
+                        "name": "key",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "File stored (PUT)"
+                    },
+                    "204": {
+                        "description": "File stored (POST)"
+                    },
+                    "400": {
+                        "description": "File does not match the signed policy",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.SignedFileErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid or expired signature",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.SignedFileErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/docs/uploads": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Starts a resumable upload session for a file of the given size. Chunks are then sent with\nPATCH requests and the upload is finalized with the complete endpoint.\n\n## Protocol\n1. ` + "`" + `POST /api/docs/uploads` + "`" + ` with the filename and total size\n2. ` + "`" + `PATCH /api/docs/uploads/{id}` + "`" + ` with ` + "`" + `Upload-Offset` + "`" + ` and a chunk body, as many times as needed\n3. After a dropped connection, ` + "`" + `HEAD /api/docs/uploads/{id}` + "`" + ` returns the offset to resume from\n4. ` + "`" + `POST /api/docs/uploads/{id}/complete` + "`" + ` creates the document\n\nSessions expire if they are not completed in time (24 hours by default).",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploads"
+                ],
+                "summary": "Create a resumable upload",
+                "parameters": [
+                    {
+                        "description": "File to upload",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.CreateUploadSessionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Upload session created",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UploadSessionResponse"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the upload session"
+                            },
+                            "Upload-Length": {
+                                "type": "integer",
+                                "description": "Declared total size"
+                            },
+                            "Upload-Offset": {
+                                "type": "integer",
+                                "description": "Bytes received so far"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UploadSessionErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing token",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UploadSessionErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UploadSessionErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/docs/uploads/direct": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns a pre-signed request the client uses to send the file straight to object storage, without\npassing through this service. The request only accepts the declared size and content type.\n\n## Protocol\n1. ` + "`" + `POST /api/docs/uploads/direct` + "`" + ` with the filename, size and optionally the hex SHA256 of the file\n2. Send the file with the returned ` + "`" + `method` + "`" + ` and ` + "`" + `url` + "`" + `:\n- ` + "`" + `PUT` + "`" + `: the file is the request body; include every entry of ` + "`" + `headers` + "`" + `\n- ` + "`" + `POST` + "`" + `: a multipart form with every entry of ` + "`" + `form_fields` + "`" + ` followed by a ` + "`" + `file` + "`" + ` field\n3. ` + "`" + `POST /api/docs/uploads/direct/{id}/complete` + "`" + ` verifies the stored file and creates the document\n\nThe pre-signed request expires after 15 minutes.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploads"
+                ],
+                "summary": "Initiate a direct-to-storage upload",
+                "parameters": [
+                    {
+                        "description": "File to upload",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/request.InitiateDirectUploadRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Direct upload initiated",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DirectUploadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DirectUploadErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized - invalid or missing token",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DirectUploadErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DirectUploadErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/docs/uploads/direct/{id}/complete": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Verifies the size and checksum of the stored file and creates the document. The result is identical to a\nregular upload, including deduplication. A file that does not match the declaration is discarded and\ncan be uploaded again with the same pre-signed request while it is valid.\n\n## Error Codes\n- ` + "`" + `UPLOAD_OFFSET_MISMATCH` + "`" + `: The file has not been uploaded yet, or another request is completing it\n- ` + "`" + `VALIDATION_ERROR` + "`" + `: The stored file does not match the declared size or checksum\n- ` + "`" + `QUOTA_EXCEEDED` + "`" + `: The document would take the owner past their quota",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "uploads"
+                ],
+                "summary": "Complete a direct-to-storage upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The owner already had the same file; the existing document is returned",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UploadResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the document"
+                            }
+                        }
+                    },
+                    "201": {
+                        "description": "Document uploaded successfully",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UploadResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the document"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Stored file does not match the declaration",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DirectUploadErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Upload not found or expired",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DirectUploadErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "File not uploaded yet, or document quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DirectUploadErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Storage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DirectUploadErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.DirectUploadErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/docs/uploads/{id}": {
+            "head": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the number of bytes already received in the ` + "`" + `Upload-Offset` + "`" + ` header. Clients resume by sending\nthe next chunk from that offset.",
+                "tags": [
+                    "uploads"
                 ],
-                "summary": "Get document by ID",
+                "summary": "Get the offset of a resumable upload",
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "123e4567-e89b-12d3-a456-426614174000",
-                        "description": "Document ID",
+                        "description": "Upload session ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -255,124 +1150,170 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Document retrieved successfully",
-                        "schema": {
-                            "$ref": "#/definitions/endpoints.GetResponse"
+                        "description": "Upload session state",
+                        "headers": {
+                            "Upload-Length": {
+                                "type": "integer",
+                                "description": "Declared total size"
+                            },
+                            "Upload-Offset": {
+                                "type": "integer",
+                                "description": "Bytes received so far"
+                            }
                         }
                     },
                     "404": {
-                        "description": "Document not found",
-                        "schema": {
-                            "$ref": "#/definitions/endpoints.GetErrorResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal server error - database error",
-                        "schema": {
-                            "$ref": "#/definitions/endpoints.GetErrorResponse"
-                        }
+                        "description": "Upload session not found or expired"
                     }
                 }
             },
-            "delete": {
+            "patch": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Deletes a document and its associated file from S3 storage.\n\n## Features\n- Deletes document metadata from DynamoDB\n- Removes the physical file from S3 storage\n- Returns 404 if document doesn't exist\n\n## Use Cases\n- Remove unwanted documents\n- Clean up storage space\n- Comply with data deletion requests\n\n## Error Codes\n- ` + "`" + `NOT_FOUND` + "`" + `: Document with the specified ID does not exist\n- ` + "`" + `PERSISTENCE_ERROR` + "`" + `: Failed to delete document from database",
+                "description": "Appends the request body at ` + "`" + `Upload-Offset` + "`" + `, which must equal the current offset of the session.\nIf the connection drops, the bytes received until then are kept; query the offset and resume.\n\n## Error Codes\n- ` + "`" + `UPLOAD_OFFSET_MISMATCH` + "`" + `: The offset does not match, or another request is writing the session\n- ` + "`" + `VALIDATION_ERROR` + "`" + `: Missing headers or the chunk exceeds the declared size",
                 "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
+                    "application/offset+octet-stream"
                 ],
                 "tags": [
-                    "documents"
+                    "uploads"
                 ],
-                "summary": "Delete a document by ID",
+                "summary": "Append a chunk to a resumable upload",
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "123e4567-e89b-12d3-a456-426614174000",
-                        "description": "Document ID",
+                        "description": "Upload session ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset of the first byte of the chunk",
+                        "name": "Upload-Offset",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Chunk bytes",
+                        "name": "chunk",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Document deleted successfully",
+                    "204": {
+                        "description": "Chunk stored",
+                        "headers": {
+                            "Upload-Offset": {
+                                "type": "integer",
+                                "description": "Bytes received so far"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
                         "schema": {
-                            "$ref": "#/definitions/endpoints.DeleteResponse"
+                            "$ref": "#/definitions/endpoints.UploadSessionErrorResponse"
                         }
                     },
                     "404": {
-                        "description": "Document not found",
+                        "description": "Upload session not found or expired",
                         "schema": {
-                            "$ref": "#/definitions/endpoints.DeleteErrorResponse"
+                            "$ref": "#/definitions/endpoints.UploadSessionErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Offset mismatch",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UploadSessionErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal server error - database or storage error",
+                        "description": "Internal server error",
                         "schema": {
-                            "$ref": "#/definitions/endpoints.DeleteErrorResponse"
+                            "$ref": "#/definitions/endpoints.UploadSessionErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/api/docs/documents/{id}/request-authentication": {
+        "/api/docs/uploads/{id}/complete": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Requests authentication of a document by publishing an event for external authentication service. The document owner's citizen ID and filename are automatically included in the event.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Creates the document once every byte has been received. The result is identical to a regular upload,\nincluding deduplication: if the owner already has the same file, the existing document is returned.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "documents"
+                    "uploads"
                 ],
-                "summary": "Request document authentication",
+                "summary": "Complete a resumable upload",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Document ID",
+                        "description": "Upload session ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "202": {
-                        "description": "Authentication request accepted",
+                    "200": {
+                        "description": "The owner already had the same file; the existing document is returned",
                         "schema": {
-                            "$ref": "#/definitions/endpoints.RequestAuthenticationResponse"
+                            "$ref": "#/definitions/endpoints.UploadResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the document"
+                            }
                         }
                     },
-                    "400": {
-                        "description": "Invalid request",
+                    "201": {
+                        "description": "Document uploaded successfully",
                         "schema": {
-                            "$ref": "#/definitions/endpoints.RequestAuthenticationErrorResponse"
+                            "$ref": "#/definitions/endpoints.UploadResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Version of the document"
+                            }
                         }
                     },
                     "404": {
-                        "description": "Document not found",
+                        "description": "Upload session not found or expired",
                         "schema": {
-                            "$ref": "#/definitions/endpoints.RequestAuthenticationErrorResponse"
+                            "$ref": "#/definitions/endpoints.UploadSessionErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Upload is incomplete, or document quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UploadSessionErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Storage quota exceeded",
+                        "schema": {
+                            "$ref": "#/definitions/endpoints.UploadSessionErrorResponse"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "$ref": "#/definitions/endpoints.RequestAuthenticationErrorResponse"
+                            "$ref": "#/definitions/endpoints.UploadSessionErrorResponse"
                         }
                     }
                 }
@@ -425,7 +1366,7 @@ const docTemplate = `{
                 },
                 "message": {
                     "type": "string",
-                    "example": "all documents deleted successfully"
+                    "example": "all documents moved to the trash"
                 },
                 "success": {
                     "type": "boolean",
@@ -446,7 +1387,31 @@ const docTemplate = `{
             "properties": {
                 "message": {
                     "type": "string",
-                    "example": "document deleted successfully"
+                    "example": "document moved to the trash"
+                },
+                "success": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "endpoints.DirectUploadErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/shared.ErrorDetail"
+                },
+                "success": {
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "endpoints.DirectUploadResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/shared.DirectUploadResponse"
                 },
                 "success": {
                     "type": "boolean",
@@ -542,6 +1507,42 @@ const docTemplate = `{
                 }
             }
         },
+        "endpoints.RestoreErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/shared.ErrorDetail"
+                }
+            }
+        },
+        "endpoints.RestoreResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/shared.DocumentResponse"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "document restored successfully"
+                },
+                "success": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "endpoints.SignedFileErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/shared.ErrorDetail"
+                },
+                "success": {
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
         "endpoints.TransferData": {
             "type": "object",
             "properties": {
@@ -607,18 +1608,169 @@ const docTemplate = `{
                 "data": {
                     "$ref": "#/definitions/shared.DocumentResponse"
                 },
+                "deduplicated": {
+                    "description": "Deduplicated is true when the owner already had a document with the same content, which is\nreturned instead of a new one",
+                    "type": "boolean",
+                    "example": false
+                },
+                "success": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "endpoints.UploadSessionErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/shared.ErrorDetail"
+                },
+                "success": {
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "endpoints.UploadSessionResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/shared.UploadSessionResponse"
+                },
+                "success": {
+                    "type": "boolean",
+                    "example": true
+                }
+            }
+        },
+        "endpoints.UsageData": {
+            "type": "object",
+            "properties": {
+                "document_count": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "max_bytes": {
+                    "type": "integer",
+                    "example": 1073741824
+                },
+                "max_documents": {
+                    "type": "integer",
+                    "example": 1000
+                },
+                "total_bytes": {
+                    "type": "integer",
+                    "example": 5242880
+                }
+            }
+        },
+        "endpoints.UsageErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/shared.ErrorDetail"
+                }
+            }
+        },
+        "endpoints.UsageResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/endpoints.UsageData"
+                },
                 "success": {
                     "type": "boolean",
                     "example": true
                 }
             }
         },
+        "request.CreateUploadSessionRequest": {
+            "type": "object",
+            "required": [
+                "filename",
+                "size"
+            ],
+            "properties": {
+                "filename": {
+                    "type": "string",
+                    "example": "passport.pdf"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 73400320
+                }
+            }
+        },
+        "request.InitiateDirectUploadRequest": {
+            "type": "object",
+            "required": [
+                "filename",
+                "size"
+            ],
+            "properties": {
+                "checksum_sha256": {
+                    "type": "string",
+                    "example": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+                },
+                "filename": {
+                    "type": "string",
+                    "example": "passport.pdf"
+                },
+                "method": {
+                    "type": "string",
+                    "enum": [
+                        "PUT",
+                        "POST"
+                    ],
+                    "example": "PUT"
+                },
+                "size": {
+                    "type": "integer",
+                    "example": 73400320
+                }
+            }
+        },
+        "shared.DirectUploadResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "form_fields": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "headers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "method": {
+                    "type": "string",
+                    "example": "PUT"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://bucket.s3.amazonaws.com/staging/..."
+                }
+            }
+        },
         "shared.DocumentResponse": {
             "type": "object",
             "properties": {
                 "authentication_status": {
                     "type": "string"
                 },
+                "deleted_at": {
+                    "type": "string"
+                },
                 "filename": {
                     "type": "string"
                 },
@@ -634,6 +1786,9 @@ const docTemplate = `{
                 "owner_id": {
                     "type": "integer"
                 },
+                "purge_at": {
+                    "type": "string"
+                },
                 "size_bytes": {
                     "type": "integer"
                 },
@@ -662,6 +1817,10 @@ const docTemplate = `{
                     "type": "integer",
                     "example": 10
                 },
+                "next_cursor": {
+                    "type": "string",
+                    "example": "eyJEb2N1bWVudElEIjp7IlMiOiIxMjMifX0.c2lnbmF0dXJl"
+                },
                 "page": {
                     "type": "integer",
                     "example": 1
@@ -708,6 +1867,34 @@ const docTemplate = `{
                     "example": 1048576
                 }
             }
+        },
+        "shared.UploadSessionResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "filename": {
+                    "type": "string",
+                    "example": "passport.pdf"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "123e4567-e89b-12d3-a456-426614174000"
+                },
+                "length": {
+                    "type": "integer",
+                    "example": 73400320
+                },
+                "mime_type": {
+                    "type": "string",
+                    "example": "application/pdf"
+                },
+                "offset": {
+                    "type": "integer",
+                    "example": 0
+                }
+            }
         }
     },
     "securityDefinitions": {
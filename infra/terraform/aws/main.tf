@@ -49,6 +49,25 @@ resource "aws_s3_bucket_public_access_block" "this" {
   restrict_public_buckets = true
 }
 
+# Streaming uploads land under staging/ before being promoted to their content-addressed key
+resource "aws_s3_bucket_lifecycle_configuration" "documents" {
+  bucket = aws_s3_bucket.documents.id
+
+  rule {
+    id     = "expire-staging-uploads"
+    status = "Enabled"
+    filter {
+      prefix = "staging/"
+    }
+    expiration {
+      days = 1
+    }
+    abort_incomplete_multipart_upload {
+      days_after_initiation = 1
+    }
+  }
+}
+
 resource "aws_dynamodb_table" "documents" {
   name         = "${local.name}-documents-${random_id.suffix.hex}"
   billing_mode = "PAY_PER_REQUEST"
@@ -80,6 +99,98 @@ resource "aws_dynamodb_table" "documents" {
     range_key       = "OwnerID"
     projection_type = "ALL"
   }
+
+  # Later indexes are added and removed by the migrations the service applies (migrate-dynamodb)
+  lifecycle {
+    ignore_changes = [attribute, global_secondary_index]
+  }
+}
+
+# Reference counters for content-addressed S3 objects shared between documents
+resource "aws_dynamodb_table" "blob_refs" {
+  name         = "${local.name}-document-blob-refs-${random_id.suffix.hex}"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "ObjectKey"
+
+  attribute {
+    name = "ObjectKey"
+    type = "S"
+  }
+}
+
+# Guards making the content of the documents of an owner unique, written in the same transaction as the document
+resource "aws_dynamodb_table" "hash_guards" {
+  name         = "${local.name}-document-hash-guards-${random_id.suffix.hex}"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "OwnerHash"
+
+  attribute {
+    name = "OwnerHash"
+    type = "S"
+  }
+}
+
+# Number and total size of the documents of every owner, written in the same transaction as the documents
+resource "aws_dynamodb_table" "owner_usage" {
+  name         = "${local.name}-document-owner-usage-${random_id.suffix.hex}"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "OwnerID"
+
+  attribute {
+    name = "OwnerID"
+    type = "S"
+  }
+}
+
+# Resumable upload sessions; expired sessions are removed by TTL and their staged parts by the bucket lifecycle
+resource "aws_dynamodb_table" "upload_sessions" {
+  name         = "${local.name}-document-upload-sessions-${random_id.suffix.hex}"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "UploadID"
+
+  attribute {
+    name = "UploadID"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "ExpiresAt"
+    enabled        = true
+  }
+}
+
+# Transactional outbox; pending messages are keyed by the sparse PendingIndex and sent ones expire by TTL
+resource "aws_dynamodb_table" "outbox" {
+  name         = "${local.name}-document-outbox-${random_id.suffix.hex}"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "MessageID"
+
+  attribute {
+    name = "MessageID"
+    type = "S"
+  }
+
+  attribute {
+    name = "Pending"
+    type = "S"
+  }
+
+  attribute {
+    name = "CreatedAt"
+    type = "N"
+  }
+
+  global_secondary_index {
+    name            = "PendingIndex"
+    hash_key        = "Pending"
+    range_key       = "CreatedAt"
+    projection_type = "ALL"
+  }
+
+  ttl {
+    attribute_name = "ExpiresAt"
+    enabled        = true
+  }
 }
 
 # ============================================================================
@@ -95,15 +206,25 @@ resource "aws_secretsmanager_secret" "app" {
 # ============================================================================
 data "aws_iam_policy_document" "documents_policy" {
   statement {
-    actions   = ["s3:PutObject","s3:GetObject","s3:DeleteObject","s3:ListBucket"]
+    actions   = ["s3:PutObject","s3:GetObject","s3:DeleteObject","s3:ListBucket","s3:AbortMultipartUpload","s3:ListMultipartUploadParts"]
     resources = [aws_s3_bucket.documents.arn, "${aws_s3_bucket.documents.arn}/*"]
   }
   statement {
-    actions   = ["dynamodb:PutItem","dynamodb:GetItem","dynamodb:DeleteItem","dynamodb:Query","dynamodb:BatchWriteItem","dynamodb:UpdateItem"]
-    resources = [aws_dynamodb_table.documents.arn, "${aws_dynamodb_table.documents.arn}/index/*"]
+    actions   = ["dynamodb:PutItem","dynamodb:GetItem","dynamodb:DeleteItem","dynamodb:Query","dynamodb:Scan","dynamodb:BatchWriteItem","dynamodb:UpdateItem","dynamodb:ConditionCheckItem"]
+    resources = [aws_dynamodb_table.documents.arn, "${aws_dynamodb_table.documents.arn}/index/*", aws_dynamodb_table.blob_refs.arn, aws_dynamodb_table.hash_guards.arn, aws_dynamodb_table.owner_usage.arn, aws_dynamodb_table.upload_sessions.arn, aws_dynamodb_table.outbox.arn, "${aws_dynamodb_table.outbox.arn}/index/*"]
+  }
+  statement {
+    # Checking at startup that the tables of the service exist
+    actions   = ["dynamodb:DescribeTable"]
+    resources = [aws_dynamodb_table.documents.arn, aws_dynamodb_table.blob_refs.arn, aws_dynamodb_table.hash_guards.arn, aws_dynamodb_table.owner_usage.arn, aws_dynamodb_table.upload_sessions.arn, aws_dynamodb_table.outbox.arn]
+  }
+  statement {
+    # Applying the migrations of the documents table at startup
+    actions   = ["dynamodb:UpdateTable"]
+    resources = [aws_dynamodb_table.documents.arn]
   }
   statement {
-    actions   = ["dynamodb:PutItem","dynamodb:GetItem","dynamodb:Query"]
+    actions   = ["dynamodb:PutItem","dynamodb:GetItem","dynamodb:Query","dynamodb:UpdateItem","dynamodb:DeleteItem"]
     resources = [local.processed_messages_table_arn]
   }
   statement {
@@ -231,6 +352,11 @@ resource "aws_apigatewayv2_route" "documents_api" {
 # ============================================================================
 output "s3_bucket"                 { value = aws_s3_bucket.documents.bucket }
 output "dynamodb_table"            { value = aws_dynamodb_table.documents.name }
+output "dynamodb_blob_refs_table"  { value = aws_dynamodb_table.blob_refs.name }
+output "dynamodb_upload_sessions_table" { value = aws_dynamodb_table.upload_sessions.name }
+output "dynamodb_outbox_table"     { value = aws_dynamodb_table.outbox.name }
+output "dynamodb_hash_guards_table" { value = aws_dynamodb_table.hash_guards.name }
+output "dynamodb_usage_table" { value = aws_dynamodb_table.owner_usage.name }
 output "rabbitmq_amqp_url"         { 
   value     = local.rabbitmq_url
   sensitive = true
@@ -2,50 +2,43 @@ package events
 
 import (
 	"context"
-	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"log"
 
 	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 )
 
 // DocumentAuthenticationHandler handles authentication completion events
 type DocumentAuthenticationHandler struct {
-	repo             interfaces.DocumentRepository
-	processedMsgRepo interfaces.ProcessedMessageRepository
+	repo           interfaces.DocumentRepository
+	registry       *events.Registry
+	documentEvents usecases.DocumentEvents
 }
 
 // NewDocumentAuthenticationHandler creates a new handler for document authentication events
-func NewDocumentAuthenticationHandler(repo interfaces.DocumentRepository, processedMsgRepo interfaces.ProcessedMessageRepository) *DocumentAuthenticationHandler {
+func NewDocumentAuthenticationHandler(repo interfaces.DocumentRepository, registry *events.Registry, documentEvents usecases.DocumentEvents) *DocumentAuthenticationHandler {
 	return &DocumentAuthenticationHandler{
-		repo:             repo,
-		processedMsgRepo: processedMsgRepo,
+		repo:           repo,
+		registry:       registry,
+		documentEvents: documentEvents,
 	}
 }
 
-// HandleAuthenticationCompleted processes the document authentication completed event
-func (h *DocumentAuthenticationHandler) HandleAuthenticationCompleted(ctx context.Context, message []byte) error {
-	var event events.DocumentAuthenticationCompletedEvent
-	if err := json.Unmarshal(message, &event); err != nil {
-		return fmt.Errorf("failed to unmarshal authentication completed event: %w", err)
+// HandleAuthenticationCompleted processes the document authentication completed event. Redeliveries
+// are filtered out by wrapping the handler in an IdempotentHandler
+func (h *DocumentAuthenticationHandler) HandleAuthenticationCompleted(ctx context.Context, envelope events.Envelope) error {
+	event, err := events.DecodeAs[events.DocumentAuthenticationCompletedEvent](h.registry, events.TypeDocumentAuthenticationCompleted, envelope)
+	if err != nil {
+		return interfaces.NewPermanentMessageError(fmt.Errorf("failed to decode authentication completed event: %w", err))
 	}
 
 	log.Printf("processing authentication completed event for document ID: %s, messageId: %s, citizen ID: %d, authenticated: %v",
 		event.DocumentID, event.MessageID, event.IDCitizen, event.Authenticated)
 
-	// IDEMPOTENCIA: Verificar si el mensaje ya fue procesado usando DynamoDB
-	if event.MessageID != "" && h.processedMsgRepo != nil {
-		alreadyProcessed, err := h.processedMsgRepo.CheckIfProcessed(ctx, event.MessageID)
-		if err != nil {
-			log.Printf("warning: failed to check if message is processed: %v, continuing with processing", err)
-		} else if alreadyProcessed {
-			log.Printf("message %s already processed, skipping (idempotent)", event.MessageID)
-			return nil // Idempotente: no error, solo skip
-		}
-	}
-
 	// Procesar el mensaje
 	var newStatus models.AuthenticationStatus
 	if event.Authenticated {
@@ -54,23 +47,16 @@ func (h *DocumentAuthenticationHandler) HandleAuthenticationCompleted(ctx contex
 		newStatus = models.AuthenticationStatusUnauthenticated
 	}
 
-	// Actualizar el estado del documento
-	if err := h.repo.UpdateAuthenticationStatus(ctx, event.DocumentID, newStatus); err != nil {
-		return fmt.Errorf("failed to update document authentication status: %w", err)
+	// Actualizar el estado del documento y publicar el cambio. A conflict with a concurrent write
+	// is returned as a transient error, so the event is redelivered and applied to the new version.
+	// A document deleted since its authentication was requested has no status left to update
+	err = h.repo.UpdateAuthenticationStatus(ctx, event.DocumentID, 0, newStatus, h.documentEvents.AuthenticationStatusChanged)
+	if stderrors.Is(err, interfaces.ErrDocumentNotFound) {
+		log.Printf("skipping authentication event %s for missing document %s", event.MessageID, event.DocumentID)
+		return nil
 	}
-
-	log.Printf("Document status updated to %s for document %s", newStatus, event.DocumentID)
-
-	// Marcar el mensaje como procesado para idempotencia
-	// IMPORTANTE: Solo hacemos ACK después de procesar completamente
-	// Si esto falla, el mensaje será re-enviado (idempotente gracias al check anterior)
-	if event.MessageID != "" && h.processedMsgRepo != nil {
-		processedMsg := models.NewProcessedMessage(event.MessageID, event.DocumentID, "authentication-handler")
-		if err := h.processedMsgRepo.MarkAsProcessed(ctx, processedMsg); err != nil {
-			log.Printf("error: failed to mark message as processed: %v", err)
-			return fmt.Errorf("failed to mark message as processed: %w", err)
-		}
-		log.Printf("Message %s marked as processed", event.MessageID)
+	if err != nil {
+		return fmt.Errorf("failed to update document authentication status: %w", err)
 	}
 
 	log.Printf("successfully processed authentication event for document %s (messageId: %s, status: %s, message: %s)",
@@ -78,3 +64,36 @@ func (h *DocumentAuthenticationHandler) HandleAuthenticationCompleted(ctx contex
 
 	return nil // ACK del mensaje
 }
+
+// HandleAuthenticationRequestParked compensates an authentication request the outbox relay gave up
+// publishing: the document would otherwise stay authenticating, as no completion ever arrives, so
+// it is moved back to unauthenticated and its owner can request the authentication again. A conflict
+// with a concurrent write is returned, so the compensation is retried on the new version
+func (h *DocumentAuthenticationHandler) HandleAuthenticationRequestParked(ctx context.Context, envelope events.Envelope) error {
+	event, err := events.DecodeAs[events.DocumentAuthenticationRequestedEvent](h.registry, events.TypeDocumentAuthenticationRequested, envelope)
+	if err != nil {
+		// There is no document to compensate
+		log.Printf("failed to decode parked authentication request %s: %v", envelope.ID, err)
+		return nil
+	}
+
+	doc, err := h.repo.GetByID(ctx, event.DocumentID)
+	if err != nil {
+		return fmt.Errorf("failed to get document %s: %w", event.DocumentID, err)
+	}
+	if doc == nil || doc.AuthenticationStatus != models.AuthenticationStatusAuthenticating {
+		return nil
+	}
+
+	err = h.repo.UpdateAuthenticationStatus(ctx, doc.ID, doc.Version, models.AuthenticationStatusUnauthenticated, h.documentEvents.AuthenticationStatusChanged)
+	if stderrors.Is(err, interfaces.ErrDocumentNotFound) {
+		// Deleted since it was read
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reset document authentication status: %w", err)
+	}
+
+	log.Printf("reset authentication status of document %s after its request %s was parked", doc.ID, event.MessageID)
+	return nil
+}
@@ -3,7 +3,6 @@ package events
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -11,30 +10,31 @@ import (
 
 	appinterfaces "github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 )
 
 // DocumentDownloadHandler downloads pre-signed URLs and stores them via DocumentUploader
 type DocumentDownloadHandler struct {
-	uploader   appinterfaces.DocumentUploader
-	publisher  appinterfaces.MessagePublisher
-	readyQueue string
+	uploader appinterfaces.DocumentUploader
+	outbox   appinterfaces.OutboxRepository
+	registry *events.Registry
 }
 
 // NewDocumentDownloadHandler creates an instance of DocumentDownloadHandler
-func NewDocumentDownloadHandler(uploader appinterfaces.DocumentUploader, publisher appinterfaces.MessagePublisher, readyQueue string) *DocumentDownloadHandler {
+func NewDocumentDownloadHandler(uploader appinterfaces.DocumentUploader, outbox appinterfaces.OutboxRepository, registry *events.Registry) *DocumentDownloadHandler {
 	return &DocumentDownloadHandler{
-		uploader:   uploader,
-		publisher:  publisher,
-		readyQueue: readyQueue,
+		uploader: uploader,
+		outbox:   outbox,
+		registry: registry,
 	}
 }
 
-// HandleDownloadRequested processes the event, downloads URLs and uploads them, then publishes a DocumentsReadyEvent
-func (h *DocumentDownloadHandler) HandleDownloadRequested(ctx context.Context, message []byte) error {
-	var evt events.DocumentDownloadRequestedEvent
-	if err := json.Unmarshal(message, &evt); err != nil {
-		log.Printf("failed to unmarshal document download requested event: %v", err)
-		return fmt.Errorf("unmarshal error: %w", err)
+// HandleDownloadRequested processes the event, downloads URLs and uploads them, then enqueues a DocumentsReadyEvent in the outbox
+func (h *DocumentDownloadHandler) HandleDownloadRequested(ctx context.Context, envelope events.Envelope) error {
+	evt, err := events.DecodeAs[events.DocumentDownloadRequestedEvent](h.registry, events.TypeDocumentDownloadRequested, envelope)
+	if err != nil {
+		log.Printf("failed to decode document download requested event: %v", err)
+		return appinterfaces.NewPermanentMessageError(err)
 	}
 
 	log.Printf("processing download requested for citizen=%d urls=%d", evt.IDCitizen, len(evt.URLs))
@@ -81,7 +81,7 @@ func (h *DocumentDownloadHandler) HandleDownloadRequested(ctx context.Context, m
 
 		// Use filename placeholder since pre-signed URL may not contain filename
 		filename := "downloaded-file"
-		if _, err := h.uploader.UploadFromReader(ctx, r, filename, int64(len(data)), evt.IDCitizen); err != nil {
+		if _, _, err := h.uploader.UploadFromReader(ctx, r, filename, int64(len(data)), evt.IDCitizen); err != nil {
 			log.Printf("failed uploading downloaded file for url %s: %v", u, err)
 			success = false
 			msg = err.Error()
@@ -100,15 +100,19 @@ func (h *DocumentDownloadHandler) HandleDownloadRequested(ctx context.Context, m
 		Message:   msg,
 	}
 
-	payload, err := json.Marshal(readyEvent)
+	ready, err := events.NewEnvelope(events.TypeDocumentsReady, events.DocumentsReadyVersion, "", readyEvent)
+	if err != nil {
+		return err
+	}
+
+	message, err := models.NewOutboxMessage(ready)
 	if err != nil {
-		log.Printf("failed to marshal ready event: %v", err)
-		return fmt.Errorf("marshal error: %w", err)
+		return err
 	}
 
-	if err := h.publisher.Publish(ctx, h.readyQueue, payload); err != nil {
-		log.Printf("failed to publish documents ready event: %v", err)
-		return fmt.Errorf("publish error: %w", err)
+	if err := h.outbox.Enqueue(ctx, message); err != nil {
+		log.Printf("failed to enqueue documents ready event: %v", err)
+		return fmt.Errorf("outbox error: %w", err)
 	}
 
 	return nil
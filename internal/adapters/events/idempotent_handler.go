@@ -0,0 +1,98 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// ErrMessageInProgress is returned when another consumer is processing the same message. It is a
+// deferred error, so the message is delivered again later without using up an attempt, by which
+// time the other consumer has finished
+var ErrMessageInProgress = errors.New("message is being processed by another consumer")
+
+// IdempotentHandler wraps message handlers so each message is processed once, even when it is
+// redelivered to several consumers at the same time
+type IdempotentHandler struct {
+	processed interfaces.ProcessedMessageRepository
+	lease     time.Duration
+}
+
+// NewIdempotentHandler creates a decorator claiming messages in processed for the given lease,
+// which must outlast the slowest handler
+func NewIdempotentHandler(processed interfaces.ProcessedMessageRepository, lease time.Duration) *IdempotentHandler {
+	return &IdempotentHandler{
+		processed: processed,
+		lease:     lease,
+	}
+}
+
+// Wrap returns a handler that claims each message before calling handler, marks it complete on
+// success and releases it on failure so a retry can claim it again. Messages already completed
+// are acknowledged without calling handler. Claims are scoped by name, so handlers sharing a
+// message ID do not skip each other's messages
+func (d *IdempotentHandler) Wrap(name string, handler interfaces.MessageHandler) interfaces.MessageHandler {
+	if d.processed == nil {
+		return handler
+	}
+
+	return func(ctx context.Context, event events.Envelope) error {
+		key := idempotencyKey(event)
+		if key == "" {
+			log.Printf("%s: message without ID cannot be deduplicated", name)
+			return handler(ctx, event)
+		}
+
+		claim := models.NewProcessedMessageClaim(name+":"+key, name, d.lease)
+		status, err := d.processed.Claim(ctx, claim)
+		if err != nil {
+			return fmt.Errorf("failed to claim message %s: %w", key, err)
+		}
+		switch status {
+		case interfaces.ClaimCompleted:
+			log.Printf("%s: message %s already processed, skipping (idempotent)", name, key)
+			return nil
+		case interfaces.ClaimInProgress:
+			return interfaces.NewDeferredMessageError(fmt.Errorf("%w: %s", ErrMessageInProgress, key))
+		}
+
+		// The claim is settled even if the handler context was canceled by a shutdown deadline
+		settleCtx := context.WithoutCancel(ctx)
+		if err := handler(ctx, event); err != nil {
+			if releaseErr := d.processed.Release(settleCtx, claim); releaseErr != nil {
+				log.Printf("%s: failed to release claim on message %s: %v", name, key, releaseErr)
+			}
+			return err
+		}
+
+		if err := d.processed.Complete(settleCtx, claim); err != nil {
+			// The work is done, so the message is still acknowledged; a redelivery is only
+			// processed again once the lease runs out
+			log.Printf("%s: failed to mark message %s as processed: %v", name, key, err)
+		}
+		return nil
+	}
+}
+
+// idempotencyKey returns the ID a message is deduplicated by: the CloudEvents ID or AMQP message
+// ID, or else the messageId field of the payload
+func idempotencyKey(event events.Envelope) string {
+	if event.ID != "" {
+		return event.ID
+	}
+
+	var payload struct {
+		MessageID string `json:"messageId"`
+	}
+	if err := json.Unmarshal(event.Data, &payload); err != nil {
+		return ""
+	}
+	return payload.MessageID
+}
@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	adapters "github.com/kristianrpo/document-management-microservice/internal/adapters/events"
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 	"github.com/stretchr/testify/assert"
@@ -15,7 +18,9 @@ import (
 
 type mockRepo struct{ mock.Mock }
 
-func (m *mockRepo) Create(ctx context.Context, _ *models.Document) error { return nil }
+func (m *mockRepo) Create(ctx context.Context, _ *models.Document, _ models.Quota, _ interfaces.OutboxFunc) error {
+	return nil
+}
 func (m *mockRepo) FindByHashAndOwnerID(ctx context.Context, hash string, ownerID int64) (*models.Document, error) {
 	args := m.Called(ctx, hash, ownerID)
 	if args.Get(0) == nil {
@@ -37,19 +42,54 @@ func (m *mockRepo) List(ctx context.Context, ownerID int64, limit, offset int) (
 	}
 	return args.Get(0).([]*models.Document), int64(args.Int(1)), args.Error(2)
 }
-func (m *mockRepo) DeleteByID(ctx context.Context, id string) (*models.Document, error) {
-	args := m.Called(ctx, id)
+func (m *mockRepo) ListPage(ctx context.Context, ownerID int64, sort models.DocumentSort, filter models.DocumentFilter, limit int, position string) ([]*models.Document, string, error) {
+	args := m.Called(ctx, ownerID, sort, filter, limit, position)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).([]*models.Document), args.String(1), args.Error(2)
+}
+func (m *mockRepo) CountByOwner(ctx context.Context, ownerID int64, filter models.DocumentFilter) (int64, error) {
+	args := m.Called(ctx, ownerID, filter)
+	return int64(args.Int(0)), args.Error(1)
+}
+func (m *mockRepo) GetUsage(ctx context.Context, ownerID int64) (*models.OwnerUsage, error) {
+	return &models.OwnerUsage{OwnerID: ownerID}, nil
+}
+func (m *mockRepo) DeleteByID(ctx context.Context, id string, expectedVersion int64, _ interfaces.OutboxFunc) (*models.Document, error) {
+	args := m.Called(ctx, id, expectedVersion)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.Document), args.Error(1)
 }
-func (m *mockRepo) DeleteAllByOwnerID(ctx context.Context, ownerID int64) (int, error) {
-	args := m.Called(ctx, ownerID)
+func (m *mockRepo) Trash(ctx context.Context, id string, expectedVersion int64, purgeAt time.Time, _ interfaces.OutboxFunc) (*models.Document, error) {
+	args := m.Called(ctx, id, expectedVersion, purgeAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Document), args.Error(1)
+}
+func (m *mockRepo) TrashAllByOwnerID(ctx context.Context, ownerID int64, purgeAt time.Time, _ interfaces.OutboxFunc) (int, error) {
+	args := m.Called(ctx, ownerID, purgeAt)
 	return args.Int(0), args.Error(1)
 }
-func (m *mockRepo) UpdateAuthenticationStatus(ctx context.Context, documentID string, status models.AuthenticationStatus) error {
-	args := m.Called(ctx, documentID, status)
+func (m *mockRepo) Restore(ctx context.Context, id string, expectedVersion int64, _ models.Quota, _ interfaces.OutboxFunc) (*models.Document, error) {
+	args := m.Called(ctx, id, expectedVersion)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Document), args.Error(1)
+}
+func (m *mockRepo) ListExpired(ctx context.Context, before time.Time, limit int, position string) ([]*models.Document, string, error) {
+	args := m.Called(ctx, before, limit, position)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).([]*models.Document), args.String(1), args.Error(2)
+}
+func (m *mockRepo) UpdateAuthenticationStatus(ctx context.Context, documentID string, expectedVersion int64, status models.AuthenticationStatus, outbox interfaces.OutboxFunc) error {
+	args := m.Called(ctx, documentID, expectedVersion, status, outbox)
 	return args.Error(0)
 }
 
@@ -61,7 +101,7 @@ func (m *mockRepo) EnsureTableExists(ctx context.Context) error {
 func TestHandleAuthenticationCompleted_Success(t *testing.T) {
 	ctx := context.Background()
 	repo := new(mockRepo)
-	h := adapters.NewDocumentAuthenticationHandler(repo, nil)
+	h := adapters.NewDocumentAuthenticationHandler(repo, events.NewDefaultRegistry(), usecases.NewDocumentEvents())
 
 	evt := events.DocumentAuthenticationCompletedEvent{
 		DocumentID:    "doc-1",
@@ -69,35 +109,113 @@ func TestHandleAuthenticationCompleted_Success(t *testing.T) {
 		Authenticated: true,
 		Message:       "ok",
 	}
-	payload, _ := json.Marshal(evt)
-	repo.On("UpdateAuthenticationStatus", ctx, "doc-1", models.AuthenticationStatusAuthenticated).Return(nil)
+	payload, _ := events.NewEnvelope(events.TypeDocumentAuthenticationCompleted, events.DocumentAuthenticationCompletedVersion, "evt-1", evt)
+	var eventTypes []string
+	repo.On("UpdateAuthenticationStatus", ctx, "doc-1", int64(0), models.AuthenticationStatusAuthenticated, mock.AnythingOfType("interfaces.OutboxFunc")).Return(nil).Run(func(args mock.Arguments) {
+		doc := &models.Document{ID: "doc-1", OwnerID: 99, AuthenticationStatus: models.AuthenticationStatusAuthenticated}
+		messages, err := args.Get(4).(interfaces.OutboxFunc)([]*models.Document{doc})
+		assert.NoError(t, err)
+		for _, message := range messages {
+			envelope, err := message.Event()
+			assert.NoError(t, err)
+			eventTypes = append(eventTypes, envelope.Type)
+		}
+	})
 
 	err := h.HandleAuthenticationCompleted(ctx, payload)
 	assert.NoError(t, err)
+	assert.Equal(t, []string{events.TypeDocumentAuthenticationStatusChanged}, eventTypes)
 	repo.AssertExpectations(t)
 }
 
 func TestHandleAuthenticationCompleted_UnmarshalError(t *testing.T) {
 	ctx := context.Background()
 	repo := new(mockRepo)
-	h := adapters.NewDocumentAuthenticationHandler(repo, nil)
+	h := adapters.NewDocumentAuthenticationHandler(repo, events.NewDefaultRegistry(), usecases.NewDocumentEvents())
 	// invalid JSON
-	payload := []byte("{invalid}")
+	payload := events.Envelope{ID: "evt-1", Data: []byte("{invalid}")}
 	err := h.HandleAuthenticationCompleted(ctx, payload)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to unmarshal")
+	assert.True(t, interfaces.IsPermanentMessageError(err), "malformed messages must not be retried")
 }
 
 func TestHandleAuthenticationCompleted_UpdateError(t *testing.T) {
 	ctx := context.Background()
 	repo := new(mockRepo)
-	h := adapters.NewDocumentAuthenticationHandler(repo, nil)
+	h := adapters.NewDocumentAuthenticationHandler(repo, events.NewDefaultRegistry(), usecases.NewDocumentEvents())
 	evt := events.DocumentAuthenticationCompletedEvent{DocumentID: "doc-1", IDCitizen: 3, Authenticated: false}
-	payload, _ := json.Marshal(evt)
-	repo.On("UpdateAuthenticationStatus", ctx, "doc-1", models.AuthenticationStatusUnauthenticated).Return(errors.New("db err"))
+	payload, _ := events.NewEnvelope(events.TypeDocumentAuthenticationCompleted, events.DocumentAuthenticationCompletedVersion, "evt-1", evt)
+	repo.On("UpdateAuthenticationStatus", ctx, "doc-1", int64(0), models.AuthenticationStatusUnauthenticated, mock.Anything).Return(errors.New("db err"))
 
 	err := h.HandleAuthenticationCompleted(ctx, payload)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to update")
 	repo.AssertExpectations(t)
 }
+
+func TestHandleAuthenticationCompleted_AcknowledgesMissingDocument(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepo)
+	h := adapters.NewDocumentAuthenticationHandler(repo, events.NewDefaultRegistry(), usecases.NewDocumentEvents())
+	evt := events.DocumentAuthenticationCompletedEvent{DocumentID: "doc-1", IDCitizen: 3, Authenticated: true}
+	payload, _ := events.NewEnvelope(events.TypeDocumentAuthenticationCompleted, events.DocumentAuthenticationCompletedVersion, "evt-1", evt)
+	repo.On("UpdateAuthenticationStatus", ctx, "doc-1", int64(0), models.AuthenticationStatusAuthenticated, mock.Anything).Return(interfaces.ErrDocumentNotFound)
+
+	err := h.HandleAuthenticationCompleted(ctx, payload)
+	assert.NoError(t, err, "a deleted document must not be retried until the dead-letter queue")
+	repo.AssertExpectations(t)
+}
+
+func TestHandleAuthenticationCompleted_LegacyMessageWithoutEnvelope(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepo)
+	h := adapters.NewDocumentAuthenticationHandler(repo, events.NewDefaultRegistry(), usecases.NewDocumentEvents())
+
+	data, _ := json.Marshal(events.DocumentAuthenticationCompletedEvent{DocumentID: "doc-1", Authenticated: true})
+	payload := events.Envelope{ID: "amqp-message-id", Data: data}
+	repo.On("UpdateAuthenticationStatus", ctx, "doc-1", int64(0), models.AuthenticationStatusAuthenticated, mock.Anything).Return(nil)
+
+	err := h.HandleAuthenticationCompleted(ctx, payload)
+	assert.NoError(t, err, "messages without CloudEvents attributes are read as version 1")
+	repo.AssertExpectations(t)
+}
+
+func TestHandleAuthenticationCompleted_RejectsUnsupportedVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepo)
+	h := adapters.NewDocumentAuthenticationHandler(repo, events.NewDefaultRegistry(), usecases.NewDocumentEvents())
+
+	payload, _ := events.NewEnvelope(events.TypeDocumentAuthenticationCompleted, events.DocumentAuthenticationCompletedVersion+1, "evt-1", events.DocumentAuthenticationCompletedEvent{DocumentID: "doc-1"})
+	err := h.HandleAuthenticationCompleted(ctx, payload)
+	assert.ErrorIs(t, err, events.ErrUnsupportedEventVersion)
+	assert.True(t, interfaces.IsPermanentMessageError(err), "unknown versions go to the dead-letter queue")
+	repo.AssertNotCalled(t, "UpdateAuthenticationStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandleAuthenticationRequestParked_ResetsAuthenticatingDocuments(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepo)
+	h := adapters.NewDocumentAuthenticationHandler(repo, events.NewDefaultRegistry(), usecases.NewDocumentEvents())
+
+	payload, _ := events.NewEnvelope(events.TypeDocumentAuthenticationRequested, events.DocumentAuthenticationRequestedVersion, "req-1", events.DocumentAuthenticationRequestedEvent{MessageID: "req-1", DocumentID: "doc-1"})
+	repo.On("GetByID", ctx, "doc-1").Return(&models.Document{ID: "doc-1", Version: 4, AuthenticationStatus: models.AuthenticationStatusAuthenticating}, nil)
+	repo.On("UpdateAuthenticationStatus", ctx, "doc-1", int64(4), models.AuthenticationStatusUnauthenticated, mock.Anything).Return(nil)
+
+	err := h.HandleAuthenticationRequestParked(ctx, payload)
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestHandleAuthenticationRequestParked_LeavesSettledDocuments(t *testing.T) {
+	ctx := context.Background()
+	repo := new(mockRepo)
+	h := adapters.NewDocumentAuthenticationHandler(repo, events.NewDefaultRegistry(), usecases.NewDocumentEvents())
+
+	payload, _ := events.NewEnvelope(events.TypeDocumentAuthenticationRequested, events.DocumentAuthenticationRequestedVersion, "req-1", events.DocumentAuthenticationRequestedEvent{MessageID: "req-1", DocumentID: "doc-1"})
+	repo.On("GetByID", ctx, "doc-1").Return(&models.Document{ID: "doc-1", Version: 5, AuthenticationStatus: models.AuthenticationStatusAuthenticated}, nil)
+
+	err := h.HandleAuthenticationRequestParked(ctx, payload)
+	assert.NoError(t, err, "a document whose authentication completed meanwhile is left as is")
+	repo.AssertNotCalled(t, "UpdateAuthenticationStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
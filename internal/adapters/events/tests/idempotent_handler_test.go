@@ -0,0 +1,114 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	adapters "github.com/kristianrpo/document-management-microservice/internal/adapters/events"
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/repository"
+)
+
+func TestIdempotentHandler_ConcurrentRedeliveriesRunOnce(t *testing.T) {
+	ctx := context.Background()
+	decorator := adapters.NewIdempotentHandler(repository.NewMemoryProcessedMessageRepository(), time.Minute)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	handler := decorator.Wrap("test-handler", func(context.Context, events.Envelope) error {
+		calls.Add(1)
+		<-release
+		return nil
+	})
+
+	const consumers = 5
+	results := make(chan error, consumers)
+	var wg sync.WaitGroup
+	for i := 0; i < consumers; i++ {
+		wg.Go(func() { results <- handler(ctx, events.Envelope{ID: "msg-1"}) })
+	}
+	assert.Eventually(t, func() bool { return len(results) == consumers-1 }, time.Second, 5*time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	inProgress := 0
+	for err := range results {
+		if err != nil {
+			assert.ErrorIs(t, err, adapters.ErrMessageInProgress)
+			assert.True(t, interfaces.IsDeferredMessageError(err), "concurrent copies do not use up attempts")
+			inProgress++
+		}
+	}
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, consumers-1, inProgress, "concurrent copies are retried later")
+
+	require.NoError(t, handler(ctx, events.Envelope{ID: "msg-1"}))
+	assert.Equal(t, int32(1), calls.Load(), "completed messages are skipped")
+}
+
+func TestIdempotentHandler_FailureReleasesTheClaim(t *testing.T) {
+	ctx := context.Background()
+	decorator := adapters.NewIdempotentHandler(repository.NewMemoryProcessedMessageRepository(), time.Minute)
+
+	var calls int
+	handler := decorator.Wrap("test-handler", func(context.Context, events.Envelope) error {
+		calls++
+		if calls == 1 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+
+	assert.Error(t, handler(ctx, events.Envelope{ID: "msg-1"}))
+	assert.NoError(t, handler(ctx, events.Envelope{ID: "msg-1"}))
+	assert.NoError(t, handler(ctx, events.Envelope{ID: "msg-1"}))
+	assert.Equal(t, 2, calls, "the retry runs once the failed attempt released its claim")
+}
+
+func TestIdempotentHandler_KeyFromPayloadAndScopedByHandler(t *testing.T) {
+	ctx := context.Background()
+	processed := repository.NewMemoryProcessedMessageRepository()
+	decorator := adapters.NewIdempotentHandler(processed, time.Minute)
+
+	var calls int
+	count := func(context.Context, events.Envelope) error {
+		calls++
+		return nil
+	}
+	legacy := events.Envelope{Data: []byte(`{"messageId":"payload-id"}`)}
+
+	require.NoError(t, decorator.Wrap("first", count)(ctx, legacy))
+	require.NoError(t, decorator.Wrap("first", count)(ctx, legacy))
+	require.NoError(t, decorator.Wrap("second", count)(ctx, legacy))
+	assert.Equal(t, 2, calls)
+
+	status, err := processed.Claim(ctx, models.NewProcessedMessageClaim("first:payload-id", "test", time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, interfaces.ClaimCompleted, status)
+
+	require.NoError(t, decorator.Wrap("first", count)(ctx, events.Envelope{Data: []byte(`{}`)}))
+	require.NoError(t, decorator.Wrap("first", count)(ctx, events.Envelope{Data: []byte(`{}`)}))
+	assert.Equal(t, 4, calls, "messages without ID are not deduplicated")
+}
+
+func TestIdempotentHandler_WithoutRepositoryPassesThrough(t *testing.T) {
+	var calls int
+	handler := adapters.NewIdempotentHandler(nil, time.Minute).Wrap("test-handler", func(context.Context, events.Envelope) error {
+		calls++
+		return nil
+	})
+
+	require.NoError(t, handler(context.Background(), events.Envelope{ID: "msg-1"}))
+	require.NoError(t, handler(context.Background(), events.Envelope{ID: "msg-1"}))
+	assert.Equal(t, 2, calls)
+}
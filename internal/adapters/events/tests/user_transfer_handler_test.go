@@ -2,11 +2,11 @@ package events_test
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"testing"
 
 	adapters "github.com/kristianrpo/document-management-microservice/internal/adapters/events"
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
 	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
 	"github.com/stretchr/testify/assert"
@@ -23,11 +23,11 @@ func (m *mockDeleteAllService) DeleteAll(ctx context.Context, ownerID int64) (in
 func TestHandleUserTransferred_Success(t *testing.T) {
 	ctx := context.Background()
 	service := new(mockDeleteAllService)
-	h := adapters.NewUserTransferHandler(service)
+	h := adapters.NewUserTransferHandler(service, events.NewDefaultRegistry())
 	_ = usecases.DocumentDeleteAllService(nil) // type reference for import
 
 	e := events.UserTransferredEvent{IDCitizen: 42}
-	payload, _ := json.Marshal(e)
+	payload, _ := events.NewEnvelope(events.TypeUserTransferred, events.UserTransferredVersion, "evt-1", e)
 	service.On("DeleteAll", ctx, int64(42)).Return(7, nil)
 
 	err := h.HandleUserTransferred(ctx, payload)
@@ -38,21 +38,23 @@ func TestHandleUserTransferred_Success(t *testing.T) {
 func TestHandleUserTransferred_UnmarshalError(t *testing.T) {
 	ctx := context.Background()
 	service := new(mockDeleteAllService)
-	h := adapters.NewUserTransferHandler(service)
-	payload := []byte("{invalid}")
+	h := adapters.NewUserTransferHandler(service, events.NewDefaultRegistry())
+	payload := events.Envelope{ID: "evt-1", Data: []byte("{invalid}")}
 	err := h.HandleUserTransferred(ctx, payload)
 	assert.Error(t, err)
+	assert.True(t, interfaces.IsPermanentMessageError(err))
 }
 
 func TestHandleUserTransferred_DeleteError(t *testing.T) {
 	ctx := context.Background()
 	service := new(mockDeleteAllService)
-	h := adapters.NewUserTransferHandler(service)
+	h := adapters.NewUserTransferHandler(service, events.NewDefaultRegistry())
 	e := events.UserTransferredEvent{IDCitizen: 42}
-	payload, _ := json.Marshal(e)
+	payload, _ := events.NewEnvelope(events.TypeUserTransferred, events.UserTransferredVersion, "evt-1", e)
 	service.On("DeleteAll", ctx, int64(42)).Return(0, errors.New("boom"))
 
 	err := h.HandleUserTransferred(ctx, payload)
 	assert.Error(t, err)
+	assert.False(t, interfaces.IsPermanentMessageError(err), "storage failures are retried")
 	service.AssertExpectations(t)
 }
@@ -2,9 +2,9 @@ package events
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
 	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
 )
@@ -12,22 +12,23 @@ import (
 // UserTransferHandler handles user transfer events by deleting all documents owned by the transferred user
 type UserTransferHandler struct {
 	deleteAllService usecases.DocumentDeleteAllService
+	registry         *events.Registry
 }
 
 // NewUserTransferHandler creates a new handler for user transfer events
-func NewUserTransferHandler(deleteAllService usecases.DocumentDeleteAllService) *UserTransferHandler {
+func NewUserTransferHandler(deleteAllService usecases.DocumentDeleteAllService, registry *events.Registry) *UserTransferHandler {
 	return &UserTransferHandler{
 		deleteAllService: deleteAllService,
+		registry:         registry,
 	}
 }
 
 // HandleUserTransferred processes user transfer events and deletes all associated documents
-func (h *UserTransferHandler) HandleUserTransferred(ctx context.Context, message []byte) error {
-	var event events.UserTransferredEvent
-
-	if err := json.Unmarshal(message, &event); err != nil {
-		log.Printf("failed to unmarshal user transfer event: %v", err)
-		return err
+func (h *UserTransferHandler) HandleUserTransferred(ctx context.Context, envelope events.Envelope) error {
+	event, err := events.DecodeAs[events.UserTransferredEvent](h.registry, events.TypeUserTransferred, envelope)
+	if err != nil {
+		log.Printf("failed to decode user transfer event: %v", err)
+		return interfaces.NewPermanentMessageError(err)
 	}
 
 	log.Printf("processing user transfer event for citizen ID: %d", event.IDCitizen)
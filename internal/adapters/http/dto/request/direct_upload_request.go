@@ -0,0 +1,8 @@
+package request
+
+type InitiateDirectUploadRequest struct {
+	Filename       string `json:"filename" binding:"required" example:"passport.pdf"`
+	Size           int64  `json:"size" binding:"required,gt=0" example:"73400320"`
+	ChecksumSHA256 string `json:"checksum_sha256,omitempty" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	Method         string `json:"method,omitempty" binding:"omitempty,oneof=PUT POST" example:"PUT"`
+}
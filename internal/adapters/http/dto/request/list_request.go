@@ -1,7 +1,19 @@
 package request
 
+// ListDocumentsRequest holds the query parameters of the document list. The citizen is the one
+// of the access token
 type ListDocumentsRequest struct {
-	IDCitizen int64 `form:"id_citizen" binding:"required,gt=0"`
-	Page      int   `form:"page" binding:"min=1" example:"1"`
-	Limit     int   `form:"limit" binding:"min=1,max=100" example:"10"`
+	IDCitizen    int64  `form:"-"`
+	Page         int    `form:"page" binding:"omitempty,min=1" example:"1"`
+	Limit        int    `form:"limit" binding:"omitempty,min=1,max=100" example:"10"`
+	Cursor       string `form:"cursor" example:"eyJEb2N1bWVudElEIjp7IlMiOiIxMjMifX0.c2lnbmF0dXJl"`
+	IncludeTotal bool   `form:"include_total" example:"false"`
+	Sort         string `form:"sort" binding:"omitempty,oneof=created_at filename size" example:"created_at"`
+	Order        string `form:"order" binding:"omitempty,oneof=asc desc" example:"desc"`
+
+	AuthenticationStatus string `form:"authentication_status" binding:"omitempty,oneof=unauthenticated authenticating authenticated" example:"authenticated"`
+	MimeType             string `form:"mime_type" binding:"omitempty,oneof=application audio font image message model multipart text video" example:"image"`
+	CreatedFrom          string `form:"created_from" binding:"omitempty,datetime=2006-01-02|datetime=2006-01-02T15:04:05Z07:00" example:"2025-01-01"`
+	CreatedTo            string `form:"created_to" binding:"omitempty,datetime=2006-01-02|datetime=2006-01-02T15:04:05Z07:00" example:"2025-12-31"`
+	Filename             string `form:"filename" binding:"omitempty,max=255" example:"passport"`
 }
@@ -0,0 +1,6 @@
+package request
+
+type CreateUploadSessionRequest struct {
+	Filename string `json:"filename" binding:"required" example:"passport.pdf"`
+	Size     int64  `json:"size" binding:"required,gt=0" example:"73400320"`
+}
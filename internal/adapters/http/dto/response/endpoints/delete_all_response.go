@@ -7,7 +7,7 @@ type DeleteAllData struct {
 }
 type DeleteAllResponse struct {
 	Success bool          `json:"success" example:"true"`
-	Message string        `json:"message" example:"all documents deleted successfully"`
+	Message string        `json:"message" example:"all documents moved to the trash"`
 	Data    DeleteAllData `json:"data"`
 }
 
@@ -4,7 +4,7 @@ import "github.com/kristianrpo/document-management-microservice/internal/adapter
 
 type DeleteResponse struct {
 	Success bool   `json:"success" example:"true"`
-	Message string `json:"message" example:"document deleted successfully"`
+	Message string `json:"message" example:"document moved to the trash"`
 }
 
 type DeleteErrorResponse struct {
@@ -0,0 +1,15 @@
+package endpoints
+
+import "github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/shared"
+
+// DirectUploadResponse represents an initiated direct-to-storage upload
+type DirectUploadResponse struct {
+	Success bool                        `json:"success" example:"true"`
+	Data    shared.DirectUploadResponse `json:"data"`
+}
+
+// DirectUploadErrorResponse represents an error response for direct upload operations
+type DirectUploadErrorResponse struct {
+	Success bool               `json:"success" example:"false"`
+	Error   shared.ErrorDetail `json:"error"`
+}
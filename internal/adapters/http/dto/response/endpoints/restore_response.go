@@ -0,0 +1,13 @@
+package endpoints
+
+import "github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/shared"
+
+type RestoreResponse struct {
+	Success bool                    `json:"success" example:"true"`
+	Message string                  `json:"message" example:"document restored successfully"`
+	Data    shared.DocumentResponse `json:"data"`
+}
+
+type RestoreErrorResponse struct {
+	Error shared.ErrorDetail `json:"error"`
+}
@@ -0,0 +1,9 @@
+package endpoints
+
+import "github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/shared"
+
+// SignedFileErrorResponse represents an error response for signed file URLs
+type SignedFileErrorResponse struct {
+	Success bool               `json:"success" example:"false"`
+	Error   shared.ErrorDetail `json:"error"`
+}
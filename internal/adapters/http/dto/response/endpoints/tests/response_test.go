@@ -47,10 +47,11 @@ func TestListResponse(t *testing.T) {
 		{ID: "doc-2", Filename: "file2.pdf"},
 	}
 
+	total := int64(2)
 	pagination := shared.Pagination{
 		Page:       1,
 		Limit:      10,
-		TotalItems: 2,
+		TotalItems: &total,
 		TotalPages: 1,
 	}
 
@@ -5,6 +5,9 @@ import "github.com/kristianrpo/document-management-microservice/internal/adapter
 type UploadResponse struct {
 	Success bool                    `json:"success" example:"true"`
 	Data    shared.DocumentResponse `json:"data"`
+	// Deduplicated is true when the owner already had a document with the same content, which is
+	// returned instead of a new one
+	Deduplicated bool `json:"deduplicated" example:"false"`
 }
 
 type UploadErrorResponse struct {
@@ -0,0 +1,15 @@
+package endpoints
+
+import "github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/shared"
+
+// UploadSessionResponse represents a created or queried resumable upload session
+type UploadSessionResponse struct {
+	Success bool                         `json:"success" example:"true"`
+	Data    shared.UploadSessionResponse `json:"data"`
+}
+
+// UploadSessionErrorResponse represents an error response for resumable upload operations
+type UploadSessionErrorResponse struct {
+	Success bool               `json:"success" example:"false"`
+	Error   shared.ErrorDetail `json:"error"`
+}
@@ -0,0 +1,19 @@
+package endpoints
+
+import "github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/shared"
+
+type UsageData struct {
+	DocumentCount int64 `json:"document_count" example:"12"`
+	TotalBytes    int64 `json:"total_bytes" example:"5242880"`
+	MaxDocuments  int64 `json:"max_documents" example:"1000"`
+	MaxBytes      int64 `json:"max_bytes" example:"1073741824"`
+}
+
+type UsageResponse struct {
+	Success bool      `json:"success" example:"true"`
+	Data    UsageData `json:"data"`
+}
+
+type UsageErrorResponse struct {
+	Error shared.ErrorDetail `json:"error"`
+}
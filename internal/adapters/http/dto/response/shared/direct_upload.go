@@ -0,0 +1,12 @@
+package shared
+
+import "time"
+
+type DirectUploadResponse struct {
+	ID         string            `json:"id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Method     string            `json:"method" example:"PUT"`
+	URL        string            `json:"url" example:"https://bucket.s3.amazonaws.com/staging/..."`
+	Headers    map[string]string `json:"headers,omitempty"`
+	FormFields map[string]string `json:"form_fields,omitempty"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+}
@@ -1,12 +1,16 @@
 package shared
 
+import "time"
+
 type DocumentResponse struct {
-	ID                   string `json:"id"`
-	Filename             string `json:"filename"`
-	MimeType             string `json:"mime_type"`
-	SizeBytes            int64  `json:"size_bytes"`
-	HashSHA256           string `json:"hash_sha256"`
-	URL                  string `json:"url"`
-	OwnerID              int64  `json:"owner_id"`
-	AuthenticationStatus string `json:"authentication_status"`
+	ID                   string     `json:"id"`
+	Filename             string     `json:"filename"`
+	MimeType             string     `json:"mime_type"`
+	SizeBytes            int64      `json:"size_bytes"`
+	HashSHA256           string     `json:"hash_sha256"`
+	URL                  string     `json:"url"`
+	OwnerID              int64      `json:"owner_id"`
+	AuthenticationStatus string     `json:"authentication_status"`
+	DeletedAt            *time.Time `json:"deleted_at,omitempty"`
+	PurgeAt              *time.Time `json:"purge_at,omitempty"`
 }
@@ -1,8 +1,36 @@
 package shared
 
+import "encoding/json"
+
+// Pagination describes a page of a list. Pages requested by number always carry page,
+// total_items and total_pages; pages requested by cursor carry next_cursor, and total_items only
+// when requested
 type Pagination struct {
-	Page       int   `json:"page" example:"1"`
-	Limit      int   `json:"limit" example:"10"`
-	TotalItems int64 `json:"total_items" example:"42"`
-	TotalPages int   `json:"total_pages" example:"5"`
+	Page       int    `json:"page,omitempty" example:"1"`
+	Limit      int    `json:"limit" example:"10"`
+	TotalItems *int64 `json:"total_items,omitempty" example:"42"`
+	TotalPages int    `json:"total_pages,omitempty" example:"5"`
+	NextCursor string `json:"next_cursor,omitempty" example:"eyJEb2N1bWVudElEIjp7IlMiOiIxMjMifX0.c2lnbmF0dXJl"`
+}
+
+// numberedPagination is the JSON shape of a page requested by number, whose totals are never
+// omitted, even when zero
+type numberedPagination struct {
+	Page       int   `json:"page"`
+	Limit      int   `json:"limit"`
+	TotalItems int64 `json:"total_items"`
+	TotalPages int   `json:"total_pages"`
+}
+
+// MarshalJSON encodes a page requested by number, which always has a page, with all its totals
+func (p Pagination) MarshalJSON() ([]byte, error) {
+	if p.Page > 0 {
+		numbered := numberedPagination{Page: p.Page, Limit: p.Limit, TotalPages: p.TotalPages}
+		if p.TotalItems != nil {
+			numbered.TotalItems = *p.TotalItems
+		}
+		return json.Marshal(numbered)
+	}
+	type cursorPagination Pagination
+	return json.Marshal(cursorPagination(p))
 }
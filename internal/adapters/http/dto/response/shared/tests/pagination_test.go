@@ -1,24 +1,27 @@
 package shared_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/shared"
 	"github.com/stretchr/testify/assert"
 )
 
+func totalItems(n int64) *int64 { return &n }
+
 func TestPagination_Structure(t *testing.T) {
 	t.Run("pagination with valid values", func(t *testing.T) {
 		pagination := shared.Pagination{
 			Page:       1,
 			Limit:      10,
-			TotalItems: 100,
+			TotalItems: totalItems(100),
 			TotalPages: 10,
 		}
 
 		assert.Equal(t, 1, pagination.Page)
 		assert.Equal(t, 10, pagination.Limit)
-		assert.Equal(t, int64(100), pagination.TotalItems)
+		assert.Equal(t, int64(100), *pagination.TotalItems)
 		assert.Equal(t, 10, pagination.TotalPages)
 	})
 
@@ -26,13 +29,13 @@ func TestPagination_Structure(t *testing.T) {
 		pagination := shared.Pagination{
 			Page:       1,
 			Limit:      25,
-			TotalItems: 50,
+			TotalItems: totalItems(50),
 			TotalPages: 2,
 		}
 
 		assert.Equal(t, 1, pagination.Page)
 		assert.Equal(t, 25, pagination.Limit)
-		assert.Equal(t, int64(50), pagination.TotalItems)
+		assert.Equal(t, int64(50), *pagination.TotalItems)
 		assert.Equal(t, 2, pagination.TotalPages)
 	})
 
@@ -40,13 +43,13 @@ func TestPagination_Structure(t *testing.T) {
 		pagination := shared.Pagination{
 			Page:       5,
 			Limit:      10,
-			TotalItems: 42,
+			TotalItems: totalItems(42),
 			TotalPages: 5,
 		}
 
 		assert.Equal(t, 5, pagination.Page)
 		assert.Equal(t, 10, pagination.Limit)
-		assert.Equal(t, int64(42), pagination.TotalItems)
+		assert.Equal(t, int64(42), *pagination.TotalItems)
 		assert.Equal(t, 5, pagination.TotalPages)
 	})
 
@@ -54,13 +57,13 @@ func TestPagination_Structure(t *testing.T) {
 		pagination := shared.Pagination{
 			Page:       1,
 			Limit:      10,
-			TotalItems: 0,
+			TotalItems: totalItems(0),
 			TotalPages: 0,
 		}
 
 		assert.Equal(t, 1, pagination.Page)
 		assert.Equal(t, 10, pagination.Limit)
-		assert.Equal(t, int64(0), pagination.TotalItems)
+		assert.Equal(t, int64(0), *pagination.TotalItems)
 		assert.Equal(t, 0, pagination.TotalPages)
 	})
 
@@ -68,13 +71,36 @@ func TestPagination_Structure(t *testing.T) {
 		pagination := shared.Pagination{
 			Page:       100,
 			Limit:      50,
-			TotalItems: 10000,
+			TotalItems: totalItems(10000),
 			TotalPages: 200,
 		}
 
 		assert.Equal(t, 100, pagination.Page)
 		assert.Equal(t, 50, pagination.Limit)
-		assert.Equal(t, int64(10000), pagination.TotalItems)
+		assert.Equal(t, int64(10000), *pagination.TotalItems)
 		assert.Equal(t, 200, pagination.TotalPages)
 	})
+
+	t.Run("page pagination keeps zero totals", func(t *testing.T) {
+		pagination := shared.Pagination{
+			Page:       1,
+			Limit:      10,
+			TotalItems: totalItems(0),
+		}
+
+		data, err := json.Marshal(pagination)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"page":1,"limit":10,"total_items":0,"total_pages":0}`, string(data))
+	})
+
+	t.Run("cursor pagination without total", func(t *testing.T) {
+		pagination := shared.Pagination{
+			Limit:      10,
+			NextCursor: "next",
+		}
+
+		data, err := json.Marshal(pagination)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"limit":10,"next_cursor":"next"}`, string(data))
+	})
 }
@@ -0,0 +1,12 @@
+package shared
+
+import "time"
+
+type UploadSessionResponse struct {
+	ID        string    `json:"id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Filename  string    `json:"filename" example:"passport.pdf"`
+	MimeType  string    `json:"mime_type" example:"application/pdf"`
+	Length    int64     `json:"length" example:"73400320"`
+	Offset    int64     `json:"offset" example:"0"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"errors"
 	"net/http"
 
 	domainerrors "github.com/kristianrpo/document-management-microservice/internal/domain/errors"
@@ -29,6 +30,18 @@ func (m *ErrorMapper) MapDomainErrorToHTTPStatus(err *domainerrors.DomainError)
 		return http.StatusInternalServerError
 	case domainerrors.ErrCodeNotFound:
 		return http.StatusNotFound
+	case domainerrors.ErrCodeConflict:
+		return http.StatusConflict
+	case domainerrors.ErrCodeUploadOffsetMismatch:
+		return http.StatusConflict
+	case domainerrors.ErrCodeInvalidSignature:
+		return http.StatusForbidden
+	case domainerrors.ErrCodeQuotaExceeded:
+		// Too many bytes is about the size of the upload; too many documents is about the state of the owner
+		if errors.Is(err.Err, domainerrors.ErrDocumentQuotaExceeded) {
+			return http.StatusConflict
+		}
+		return http.StatusRequestEntityTooLarge
 	default:
 		return http.StatusInternalServerError
 	}
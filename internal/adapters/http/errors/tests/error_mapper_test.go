@@ -65,6 +65,37 @@ func TestErrorMapper_MapDomainErrorToHTTPStatus(t *testing.T) {
 			},
 			expectedStatus: http.StatusNotFound,
 		},
+		{
+			name: "conflict error maps to conflict",
+			domainError: &domainerrors.DomainError{
+				Code:    domainerrors.ErrCodeConflict,
+				Message: "document was modified",
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "upload offset mismatch maps to conflict",
+			domainError: &domainerrors.DomainError{
+				Code:    domainerrors.ErrCodeUploadOffsetMismatch,
+				Message: "upload offset mismatch",
+			},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "invalid signature maps to forbidden",
+			domainError:    domainerrors.NewInvalidSignatureError("invalid or expired signature"),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "storage quota exceeded maps to request entity too large",
+			domainError:    domainerrors.NewQuotaExceededError("storage quota exceeded", domainerrors.ErrStorageQuotaExceeded),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "document quota exceeded maps to conflict",
+			domainError:    domainerrors.NewQuotaExceededError("document quota exceeded", domainerrors.ErrDocumentQuotaExceeded),
+			expectedStatus: http.StatusConflict,
+		},
 		{
 			name: "unknown error code maps to internal server error",
 			domainError: &domainerrors.DomainError{
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/request"
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/endpoints"
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/middleware"
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/presenter"
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/metrics"
+)
+
+// DirectUploadHandler handles HTTP requests for uploads sent straight to storage
+type DirectUploadHandler struct {
+	service      usecases.DirectUploadService
+	errorHandler *errors.ErrorHandler
+	metrics      *metrics.PrometheusMetrics
+}
+
+// NewDirectUploadHandler creates a new handler for direct-to-storage upload operations
+func NewDirectUploadHandler(service usecases.DirectUploadService, errorHandler *errors.ErrorHandler, metricsCollector *metrics.PrometheusMetrics) *DirectUploadHandler {
+	return &DirectUploadHandler{
+		service:      service,
+		errorHandler: errorHandler,
+		metrics:      metricsCollector,
+	}
+}
+
+// Initiate godoc
+// @Summary Initiate a direct-to-storage upload
+// @Description Returns a pre-signed request the client uses to send the file straight to object storage, without
+// @Description passing through this service. The request only accepts the declared size and content type.
+// @Description
+// @Description ## Protocol
+// @Description 1. `POST /api/docs/uploads/direct` with the filename, size and optionally the hex SHA256 of the file
+// @Description 2. Send the file with the returned `method` and `url`:
+// @Description    - `PUT`: the file is the request body; include every entry of `headers`
+// @Description    - `POST`: a multipart form with every entry of `form_fields` followed by a `file` field
+// @Description 3. `POST /api/docs/uploads/direct/{id}/complete` verifies the stored file and creates the document
+// @Description
+// @Description The pre-signed request expires after 15 minutes.
+// @Tags uploads
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body request.InitiateDirectUploadRequest true "File to upload"
+// @Success 201 {object} endpoints.DirectUploadResponse "Direct upload initiated"
+// @Failure 400 {object} endpoints.DirectUploadErrorResponse "Validation error"
+// @Failure 401 {object} endpoints.DirectUploadErrorResponse "Unauthorized - invalid or missing token"
+// @Failure 500 {object} endpoints.DirectUploadErrorResponse "Internal server error"
+// @Router /api/docs/uploads/direct [post]
+func (handler *DirectUploadHandler) Initiate(ctx *gin.Context) {
+	idCitizen, err := middleware.GetUserIDCitizen(ctx)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("user not authenticated"))
+		return
+	}
+
+	var body request.InitiateDirectUploadRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("filename and a positive size are required, and method must be PUT or POST"))
+		return
+	}
+
+	session, presigned, err := handler.service.Initiate(ctx.Request.Context(), idCitizen, body.Filename, body.Size, body.ChecksumSHA256, body.Method)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, endpoints.DirectUploadResponse{
+		Success: true,
+		Data:    *presenter.ToDirectUploadResponse(session, presigned),
+	})
+}
+
+// Complete godoc
+// @Summary Complete a direct-to-storage upload
+// @Description Verifies the size and checksum of the stored file and creates the document. The result is identical to a
+// @Description regular upload, including deduplication. A file that does not match the declaration is discarded and
+// @Description can be uploaded again with the same pre-signed request while it is valid.
+// @Description
+// @Description ## Error Codes
+// @Description - `UPLOAD_OFFSET_MISMATCH`: The file has not been uploaded yet, or another request is completing it
+// @Description - `VALIDATION_ERROR`: The stored file does not match the declared size or checksum
+// @Description - `QUOTA_EXCEEDED`: The document would take the owner past their quota
+// @Tags uploads
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Upload ID"
+// @Success 201 {object} endpoints.UploadResponse "Document uploaded successfully"
+// @Header 201 {string} ETag "Version of the document"
+// @Success 200 {object} endpoints.UploadResponse "The owner already had the same file; the existing document is returned"
+// @Header 200 {string} ETag "Version of the document"
+// @Failure 400 {object} endpoints.DirectUploadErrorResponse "Stored file does not match the declaration"
+// @Failure 404 {object} endpoints.DirectUploadErrorResponse "Upload not found or expired"
+// @Failure 409 {object} endpoints.DirectUploadErrorResponse "File not uploaded yet, or document quota exceeded"
+// @Failure 413 {object} endpoints.DirectUploadErrorResponse "Storage quota exceeded"
+// @Failure 500 {object} endpoints.DirectUploadErrorResponse "Internal server error"
+// @Router /api/docs/uploads/direct/{id}/complete [post]
+func (handler *DirectUploadHandler) Complete(ctx *gin.Context) {
+	idCitizen, err := middleware.GetUserIDCitizen(ctx)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("user not authenticated"))
+		return
+	}
+
+	document, created, err := handler.service.Complete(ctx.Request.Context(), ctx.Param("id"), idCitizen)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, err)
+		return
+	}
+
+	handler.metrics.UploadRequestsTotal.Inc()
+
+	writeUploadResponse(ctx, document, created)
+}
@@ -32,12 +32,15 @@ func NewDocumentDeleteHandler(service usecases.DocumentDeleteService, serviceGet
 
 // Delete godoc
 // @Summary Delete a document by ID
-// @Description Deletes a document and its associated file from S3 storage.
+// @Description Moves a document to the trash, from which it can be restored until it is purged.
 // @Description
 // @Description ## Features
-// @Description - Deletes document metadata from DynamoDB
-// @Description - Removes the physical file from S3 storage
-// @Description - Returns 404 if document doesn't exist
+// @Description - The document leaves the document list and can no longer be retrieved, but is listed in the trash
+// @Description - The document no longer counts in the quota of the user
+// @Description - The document and its file are deleted for good once the trash retention is over (30 days by default)
+// @Description - Restore the document with `POST /api/docs/documents/{id}/restore` until then
+// @Description - Returns 404 if document doesn't exist or is in the trash already
+// @Description - With `If-Match` set to the ETag of the document, deletes it only if it has not changed since
 // @Description
 // @Description ## Use Cases
 // @Description - Remove unwanted documents
@@ -45,16 +48,21 @@ func NewDocumentDeleteHandler(service usecases.DocumentDeleteService, serviceGet
 // @Description - Comply with data deletion requests
 // @Description
 // @Description ## Error Codes
+// @Description - `VALIDATION_ERROR`: `If-Match` is not an ETag of the document
 // @Description - `NOT_FOUND`: Document with the specified ID does not exist
-// @Description - `PERSISTENCE_ERROR`: Failed to delete document from database
+// @Description - `CONFLICT`: The document changed since the `If-Match` version, or while it was being deleted
+// @Description - `PERSISTENCE_ERROR`: Failed to move the document to the trash in the database
 // @Tags documents
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Document ID" example(123e4567-e89b-12d3-a456-426614174000)
-// @Success 200 {object} endpoints.DeleteResponse "Document deleted successfully"
+// @Param If-Match header string false "ETag of the document version to delete" example("3")
+// @Success 200 {object} endpoints.DeleteResponse "Document moved to the trash"
+// @Failure 400 {object} endpoints.DeleteErrorResponse "Invalid If-Match header"
 // @Failure 404 {object} endpoints.DeleteErrorResponse "Document not found"
-// @Failure 500 {object} endpoints.DeleteErrorResponse "Internal server error - database or storage error"
+// @Failure 409 {object} endpoints.DeleteErrorResponse "Document changed since the If-Match version"
+// @Failure 500 {object} endpoints.DeleteErrorResponse "Internal server error - database error"
 // @Router /api/docs/documents/{id} [delete]
 func (handler *DocumentDeleteHandler) Delete(ctx *gin.Context) {
 	id := ctx.Param("id")
@@ -80,7 +88,13 @@ func (handler *DocumentDeleteHandler) Delete(ctx *gin.Context) {
 		return
 	}
 
-	err = handler.service.Delete(ctx.Request.Context(), id)
+	expectedVersion, err := ifMatchVersion(ctx)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, err)
+		return
+	}
+
+	err = handler.service.Delete(ctx.Request.Context(), id, expectedVersion)
 	if err != nil {
 		handler.errorHandler.HandleError(ctx, err)
 		return
@@ -90,7 +104,7 @@ func (handler *DocumentDeleteHandler) Delete(ctx *gin.Context) {
 
 	response := endpoints.DeleteResponse{
 		Success: true,
-		Message: "document deleted successfully",
+		Message: "document moved to the trash",
 	}
 
 	ctx.JSON(http.StatusOK, response)
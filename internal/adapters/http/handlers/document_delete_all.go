@@ -30,11 +30,12 @@ func NewDocumentDeleteAllHandler(service usecases.DocumentDeleteAllService, erro
 
 // DeleteAll godoc
 // @Summary Delete all documents for the authenticated user
-// @Description Deletes all documents belonging to the authenticated user (citizen ID from JWT) and their associated files from S3 storage.
+// @Description Moves all documents belonging to the authenticated user (citizen ID from JWT) to the trash, from which they can be restored until they are purged.
 // @Description
 // @Description ## Features
-// @Description - Deletes all document metadata from DynamoDB for the authenticated user
-// @Description - Removes all physical files from S3 storage
+// @Description - Moves every document of the authenticated user outside the trash to the trash
+// @Description - The documents no longer count in the quota of the user
+// @Description - The documents and their files are deleted for good once the trash retention is over (30 days by default)
 // @Description - Returns the count of deleted documents
 // @Description - Useful for account closure or data migration scenarios
 // @Description
@@ -46,14 +47,14 @@ func NewDocumentDeleteAllHandler(service usecases.DocumentDeleteAllService, erro
 // @Description
 // @Description ## Error Codes
 // @Description - `VALIDATION_ERROR`: Invalid citizen ID
-// @Description - `PERSISTENCE_ERROR`: Failed to delete documents from database
+// @Description - `PERSISTENCE_ERROR`: Failed to move the documents to the trash in the database
 // @Tags documents
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} endpoints.DeleteAllResponse "All documents deleted successfully"
+// @Success 200 {object} endpoints.DeleteAllResponse "All documents moved to the trash"
 // @Failure 400 {object} endpoints.DeleteAllErrorResponse "Validation error - invalid citizen ID"
-// @Failure 500 {object} endpoints.DeleteAllErrorResponse "Internal server error - database or storage error"
+// @Failure 500 {object} endpoints.DeleteAllErrorResponse "Internal server error - database error"
 // @Router /api/docs/documents/user/delete-all [delete]
 func (handler *DocumentDeleteAllHandler) DeleteAll(ctx *gin.Context) {
 	idCitizen, err := middleware.GetUserIDCitizen(ctx)
@@ -72,7 +73,7 @@ func (handler *DocumentDeleteAllHandler) DeleteAll(ctx *gin.Context) {
 
 	response := endpoints.DeleteAllResponse{
 		Success: true,
-		Message: "all documents deleted successfully",
+		Message: "all documents moved to the trash",
 		Data: endpoints.DeleteAllData{
 			DeletedCount: deletedCount,
 		},
@@ -37,6 +37,7 @@ func NewDocumentGetHandler(service usecases.DocumentGetService, errorHandler *er
 // @Description - Returns complete document metadata including URL for viewing/downloading
 // @Description - URL is pre-signed and ready to use in frontend viewers
 // @Description - Includes file information (size, type, hash, etc.)
+// @Description - The `ETag` header holds the version of the document, to send as `If-Match` when changing it
 // @Description
 // @Description ## Use Cases
 // @Description - Display document details in UI
@@ -53,6 +54,7 @@ func NewDocumentGetHandler(service usecases.DocumentGetService, errorHandler *er
 // @Security BearerAuth
 // @Param id path string true "Document ID" example(123e4567-e89b-12d3-a456-426614174000)
 // @Success 200 {object} endpoints.GetResponse "Document retrieved successfully"
+// @Header 200 {string} ETag "Version of the document"
 // @Failure 404 {object} endpoints.GetErrorResponse "Document not found"
 // @Failure 500 {object} endpoints.GetErrorResponse "Internal server error - database error"
 // @Router /api/docs/documents/{id} [get]
@@ -78,6 +80,8 @@ func (handler *DocumentGetHandler) GetByID(ctx *gin.Context) {
 
 	handler.metrics.GetRequestsTotal.Inc()
 
+	setDocumentETag(ctx, document)
+
 	response := endpoints.GetResponse{
 		Success: true,
 		Data:    *presenter.ToDocumentResponse(document),
@@ -1,16 +1,22 @@
 package handlers
 
 import (
-	"fmt"
-	"github.com/gin-gonic/gin"
+	stderrors "errors"
 	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
 
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/request"
 	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/endpoints"
 	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/shared"
 	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/errors"
 	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/middleware"
 	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/presenter"
 	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/metrics"
 )
 
@@ -35,28 +41,41 @@ func NewDocumentListHandler(service usecases.DocumentListService, errorHandler *
 // @Description Retrieves a paginated list of documents for a specific owner (identified by citizen ID).
 // @Description
 // @Description ## Features
-// @Description - Returns documents sorted by creation date (most recent first)
-// @Description - Supports pagination with configurable page size
-// @Description - Includes pagination metadata (total items, total pages, current page)
+// @Description - Returns documents sorted by creation date (most recent first) unless `sort` is given
+// @Description - Sorts by `created_at`, `filename` (ignoring case) or `size` with `sort`, in the `order` given by `asc` or `desc`
+// @Description - Filters by `authentication_status`, MIME type family (`mime_type`, such as `image`), creation date range (`created_from`, `created_to`) and filename substring (`filename`, ignoring case)
+// @Description - Supports page number and cursor pagination with configurable page size
 // @Description - Maximum limit per page: 100 documents
 // @Description - Default page size: 10 documents
 // @Description
 // @Description ## Pagination
-// @Description - Use `page` parameter to navigate through results (starts at 1)
+// @Description - By default documents are listed by page number: `page` (starting at 1, default 1) selects the page, and `total_items` and `total_pages` are always returned; documents come most recent first and deep pages get slower
+// @Description - Passing `cursor`, `include_total`, `sort`, `order` or any filter lists by cursor instead, and `page` must then be left out
+// @Description - By cursor, pass the `next_cursor` of a response as `cursor` to get the following page; the last page has no `next_cursor`
+// @Description - Cursors are opaque and signed; they are only valid for the citizen they were issued to, with the `sort`, `order`, filters and trash view of the request that returned them
+// @Description - By cursor, use `include_total=true` to also get `total_items`, the number of documents matching the filters; counting reads all of them, so request it only when needed
 // @Description - Use `limit` parameter to control page size (1-100)
-// @Description - Response includes total count and total pages for UI rendering
 // @Description
 // @Description ## Error Codes
-// @Description - `VALIDATION_ERROR`: Invalid id_citizen or pagination parameters
+// @Description - `VALIDATION_ERROR`: Invalid id_citizen, cursor, sort, filter or pagination parameters
 // @Description - `PERSISTENCE_ERROR`: Failed to retrieve documents from database
 // @Tags documents
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param page query int false "Page number (starts at 1)" minimum(1) default(1) example(1)
+// @Param cursor query string false "Cursor of the page to retrieve, from the next_cursor of the previous page"
 // @Param limit query int false "Number of items per page (max 100)" minimum(1) maximum(100) default(10) example(10)
+// @Param include_total query bool false "Include the total number of documents" default(false)
+// @Param sort query string false "Attribute the documents are sorted by" Enums(created_at, filename, size) default(created_at)
+// @Param order query string false "Sort direction; desc by default for created_at, asc otherwise" Enums(asc, desc)
+// @Param authentication_status query string false "Only documents in this authentication state" Enums(unauthenticated, authenticating, authenticated)
+// @Param mime_type query string false "Only documents of this MIME type family" Enums(application, audio, font, image, message, model, multipart, text, video)
+// @Param created_from query string false "Only documents created at or after this date (YYYY-MM-DD) or time (RFC 3339)" example(2025-01-01)
+// @Param created_to query string false "Only documents created at or before this date, the whole day included, or time" example(2025-12-31)
+// @Param filename query string false "Only documents whose filename contains this text, ignoring case" maxlength(255)
+// @Param page query int false "Page number (starts at 1); not allowed with cursor pagination" minimum(1) default(1) example(1)
 // @Success 200 {object} endpoints.ListResponse "List of documents retrieved successfully"
-// @Failure 400 {object} endpoints.ListErrorResponse "Validation error - invalid id_citizen or pagination parameters"
+// @Failure 400 {object} endpoints.ListErrorResponse "Validation error - invalid id_citizen, cursor, sort, filter or pagination parameters"
 // @Failure 500 {object} endpoints.ListErrorResponse "Internal server error - database error"
 // @Router /api/docs/documents [get]
 func (handler *DocumentListHandler) List(ctx *gin.Context) {
@@ -66,21 +85,104 @@ func (handler *DocumentListHandler) List(ctx *gin.Context) {
 		return
 	}
 
-	page := 1
-	limit := 10
-	if p := ctx.Query("page"); p != "" {
-		_, _ = fmt.Sscanf(p, "%d", &page)
+	var req request.ListDocumentsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError(invalidQueryMessage(err, req)))
+		return
 	}
-	if l := ctx.Query("limit"); l != "" {
-		_, _ = fmt.Sscanf(l, "%d", &limit)
+	req.IDCitizen = idCitizen
+
+	query, err := listQuery(req)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, err)
+		return
 	}
 
-	documents, pagination, totalPages, totalCount, err := handler.service.List(
-		ctx.Request.Context(),
-		idCitizen,
-		page,
-		limit,
-	)
+	var pagination shared.Pagination
+	var documents []*models.Document
+	if usesCursor(query) {
+		if req.Page != 0 {
+			handler.errorHandler.HandleError(ctx, errors.NewValidationError("sorting, filtering and cursors require cursor pagination, without page"))
+			return
+		}
+		documents, pagination, err = handler.listByCursor(ctx, req.IDCitizen, query)
+	} else {
+		documents, pagination, err = handler.listByPage(ctx, req.IDCitizen, max(req.Page, 1), req.Limit)
+	}
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, err)
+		return
+	}
+
+	handler.metrics.ListRequestsTotal.Inc()
+
+	response := endpoints.ListResponse{
+		Success: true,
+		Data: endpoints.ListData{
+			Documents:  presenter.ToDocumentResponseList(documents),
+			Pagination: pagination,
+		},
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// ListTrash godoc
+// @Summary List the trash
+// @Description Retrieves a paginated list of the deleted documents of the authenticated user still in the trash.
+// @Description
+// @Description ## Features
+// @Description - Deleted documents stay in the trash until their `purge_at` time, then they and their files are deleted for good
+// @Description - Each document holds the time it was deleted (`deleted_at`) and the time it will be purged (`purge_at`)
+// @Description - Restore a document with `POST /api/docs/documents/{id}/restore`
+// @Description - Sorts, filters and paginates by cursor like the document list; `page` is not supported
+// @Description
+// @Description ## Error Codes
+// @Description - `VALIDATION_ERROR`: Invalid cursor, sort, filter or pagination parameters
+// @Description - `PERSISTENCE_ERROR`: Failed to retrieve documents from database
+// @Tags documents
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param cursor query string false "Cursor of the page to retrieve, from the next_cursor of the previous page"
+// @Param limit query int false "Number of items per page (max 100)" minimum(1) maximum(100) default(10) example(10)
+// @Param include_total query bool false "Include the total number of documents in the trash" default(false)
+// @Param sort query string false "Attribute the documents are sorted by" Enums(created_at, filename, size) default(created_at)
+// @Param order query string false "Sort direction; desc by default for created_at, asc otherwise" Enums(asc, desc)
+// @Param authentication_status query string false "Only documents in this authentication state" Enums(unauthenticated, authenticating, authenticated)
+// @Param mime_type query string false "Only documents of this MIME type family" Enums(application, audio, font, image, message, model, multipart, text, video)
+// @Param created_from query string false "Only documents created at or after this date (YYYY-MM-DD) or time (RFC 3339)" example(2025-01-01)
+// @Param created_to query string false "Only documents created at or before this date, the whole day included, or time" example(2025-12-31)
+// @Param filename query string false "Only documents whose filename contains this text, ignoring case" maxlength(255)
+// @Success 200 {object} endpoints.ListResponse "Documents in the trash retrieved successfully"
+// @Failure 400 {object} endpoints.ListErrorResponse "Validation error - invalid cursor, sort, filter or pagination parameters"
+// @Failure 500 {object} endpoints.ListErrorResponse "Internal server error - database error"
+// @Router /api/docs/documents/trash [get]
+func (handler *DocumentListHandler) ListTrash(ctx *gin.Context) {
+	idCitizen, err := middleware.GetUserIDCitizen(ctx)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("user not authenticated"))
+		return
+	}
+
+	var req request.ListDocumentsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError(invalidQueryMessage(err, req)))
+		return
+	}
+	if req.Page != 0 {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("the trash is only listed with cursor pagination"))
+		return
+	}
+
+	query, err := listQuery(req)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, err)
+		return
+	}
+	query.Filter.Trashed = true
+
+	documents, pagination, err := handler.listByCursor(ctx, idCitizen, query)
 	if err != nil {
 		handler.errorHandler.HandleError(ctx, err)
 		return
@@ -91,15 +193,105 @@ func (handler *DocumentListHandler) List(ctx *gin.Context) {
 	response := endpoints.ListResponse{
 		Success: true,
 		Data: endpoints.ListData{
-			Documents: presenter.ToDocumentResponseList(documents),
-			Pagination: shared.Pagination{
-				Page:       pagination.Page,
-				Limit:      pagination.Limit,
-				TotalItems: totalCount,
-				TotalPages: totalPages,
-			},
+			Documents:  presenter.ToDocumentResponseList(documents),
+			Pagination: pagination,
 		},
 	}
 
 	ctx.JSON(http.StatusOK, response)
 }
+
+// listByPage lists a page of documents by page number, with the totals
+func (handler *DocumentListHandler) listByPage(ctx *gin.Context, idCitizen int64, page, limit int) ([]*models.Document, shared.Pagination, error) {
+	documents, pagination, totalPages, totalCount, err := handler.service.List(
+		ctx.Request.Context(),
+		idCitizen,
+		page,
+		limit,
+	)
+	if err != nil {
+		return nil, shared.Pagination{}, err
+	}
+
+	return documents, shared.Pagination{
+		Page:       pagination.Page,
+		Limit:      pagination.Limit,
+		TotalItems: &totalCount,
+		TotalPages: totalPages,
+	}, nil
+}
+
+// listByCursor lists the page of documents selected by query
+func (handler *DocumentListHandler) listByCursor(ctx *gin.Context, idCitizen int64, query usecases.DocumentListQuery) ([]*models.Document, shared.Pagination, error) {
+	page, err := handler.service.ListPage(ctx.Request.Context(), idCitizen, query)
+	if err != nil {
+		return nil, shared.Pagination{}, err
+	}
+
+	return page.Documents, shared.Pagination{
+		Limit:      page.Limit,
+		TotalItems: page.TotalItems,
+		NextCursor: page.NextCursor,
+	}, nil
+}
+
+// usesCursor reports whether a list query asks for cursor pagination: only a cursor, the total
+// count on request, a sort or a filter opt out of the default listing by page number
+func usesCursor(query usecases.DocumentListQuery) bool {
+	return query.Cursor != "" || query.IncludeTotal || query.Sort != (models.DocumentSort{}) || !query.Filter.IsZero()
+}
+
+// listQuery builds the cursor list query of a request
+func listQuery(req request.ListDocumentsRequest) (usecases.DocumentListQuery, error) {
+	createdFrom, err := parseListDate(req.CreatedFrom, false)
+	if err != nil {
+		return usecases.DocumentListQuery{}, errors.NewValidationError("invalid created_from parameter")
+	}
+	createdTo, err := parseListDate(req.CreatedTo, true)
+	if err != nil {
+		return usecases.DocumentListQuery{}, errors.NewValidationError("invalid created_to parameter")
+	}
+
+	return usecases.DocumentListQuery{
+		Sort: models.DocumentSort{
+			Field: models.DocumentSortField(req.Sort),
+			Order: models.SortOrder(req.Order),
+		},
+		Filter: models.DocumentFilter{
+			AuthenticationStatus: models.AuthenticationStatus(req.AuthenticationStatus),
+			MimeFamily:           req.MimeType,
+			CreatedFrom:          createdFrom,
+			CreatedTo:            createdTo,
+			FilenameContains:     req.Filename,
+		},
+		Limit:        req.Limit,
+		Cursor:       req.Cursor,
+		IncludeTotal: req.IncludeTotal,
+	}, nil
+}
+
+// parseListDate parses a created date bound given as a date or an RFC 3339 timestamp. A date
+// closing the range stands for the whole day
+func parseListDate(value string, endOfRange bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		if endOfRange {
+			return date.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+		}
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// invalidQueryMessage names the query parameter of a request that failed validation
+func invalidQueryMessage(err error, req any) string {
+	var validationErrors validator.ValidationErrors
+	if stderrors.As(err, &validationErrors) && len(validationErrors) > 0 {
+		if field, ok := reflect.TypeOf(req).FieldByName(validationErrors[0].StructField()); ok {
+			return "invalid " + field.Tag.Get("form") + " parameter"
+		}
+	}
+	return "invalid query parameters"
+}
@@ -38,15 +38,17 @@ func NewDocumentRequestAuthenticationHandler(
 
 // RequestAuthentication godoc
 // @Summary Request document authentication
-// @Description Requests authentication of a document by publishing an event for external authentication service. The document owner's citizen ID and filename are automatically included in the event.
+// @Description Requests authentication of a document by publishing an event for external authentication service. The document owner's citizen ID and filename are automatically included in the event. With `If-Match` set to the ETag of the document, the request is only made if the document has not changed since.
 // @Tags documents
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "Document ID"
+// @Param If-Match header string false "ETag of the document version to authenticate" example("3")
 // @Success 202 {object} endpoints.RequestAuthenticationResponse "Authentication request accepted"
 // @Failure 400 {object} endpoints.RequestAuthenticationErrorResponse "Invalid request"
 // @Failure 404 {object} endpoints.RequestAuthenticationErrorResponse "Document not found"
+// @Failure 409 {object} endpoints.RequestAuthenticationErrorResponse "Document changed since the If-Match version"
 // @Failure 500 {object} endpoints.RequestAuthenticationErrorResponse "Internal server error"
 // @Router /api/docs/documents/{id}/request-authentication [post]
 func (h *DocumentRequestAuthenticationHandler) RequestAuthentication(c *gin.Context) {
@@ -85,7 +87,13 @@ func (h *DocumentRequestAuthenticationHandler) RequestAuthentication(c *gin.Cont
 		return
 	}
 
-	err = h.authService.RequestAuthentication(c.Request.Context(), documentID)
+	expectedVersion, err := ifMatchVersion(c)
+	if err != nil {
+		h.errorHandler.HandleError(c, err)
+		return
+	}
+
+	err = h.authService.RequestAuthentication(c.Request.Context(), documentID, expectedVersion)
 	if err != nil {
 		h.errorHandler.HandleError(c, err)
 		return
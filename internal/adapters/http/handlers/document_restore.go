@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/endpoints"
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/middleware"
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/presenter"
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+)
+
+// DocumentRestoreHandler handles HTTP requests for taking documents out of the trash
+type DocumentRestoreHandler struct {
+	service      usecases.DocumentRestoreService
+	errorHandler *errors.ErrorHandler
+}
+
+// NewDocumentRestoreHandler creates a new handler for document restoration operations
+func NewDocumentRestoreHandler(service usecases.DocumentRestoreService, errorHandler *errors.ErrorHandler) *DocumentRestoreHandler {
+	return &DocumentRestoreHandler{
+		service:      service,
+		errorHandler: errorHandler,
+	}
+}
+
+// Restore godoc
+// @Summary Restore a document from the trash
+// @Description Takes a deleted document of the authenticated user out of the trash before it is purged.
+// @Description
+// @Description ## Features
+// @Description - Deleted documents stay in the trash until their `purge_at` time, then they and their files are deleted for good
+// @Description - The restored document is listed and can be retrieved again
+// @Description - The restored document counts in the quota of the user again
+// @Description - The `ETag` header holds the new version of the document
+// @Description - With `If-Match` set to the ETag of the trashed document, restores it only if it has not changed since
+// @Description
+// @Description ## Error Codes
+// @Description - `VALIDATION_ERROR`: `If-Match` is not an ETag of the document, or the document belongs to another user
+// @Description - `NOT_FOUND`: No document with the specified ID is in the trash
+// @Description - `CONFLICT`: The document changed since the `If-Match` version, or while it was being restored
+// @Description - `QUOTA_EXCEEDED`: The document would take the user past their quota
+// @Description - `PERSISTENCE_ERROR`: Failed to restore the document in the database
+// @Tags documents
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Document ID" example(123e4567-e89b-12d3-a456-426614174000)
+// @Param If-Match header string false "ETag of the trashed document version to restore" example("3")
+// @Success 200 {object} endpoints.RestoreResponse "Document restored successfully"
+// @Header 200 {string} ETag "Version of the document"
+// @Failure 400 {object} endpoints.RestoreErrorResponse "Invalid If-Match header or document of another user"
+// @Failure 404 {object} endpoints.RestoreErrorResponse "Document not found in the trash"
+// @Failure 409 {object} endpoints.RestoreErrorResponse "Document changed since the If-Match version, or document quota exceeded"
+// @Failure 413 {object} endpoints.RestoreErrorResponse "Storage quota exceeded"
+// @Failure 500 {object} endpoints.RestoreErrorResponse "Internal server error - database error"
+// @Router /api/docs/documents/{id}/restore [post]
+func (handler *DocumentRestoreHandler) Restore(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	if id == "" {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("document id is required"))
+		return
+	}
+
+	idCitizen, err := middleware.GetUserIDCitizen(ctx)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("user not authenticated"))
+		return
+	}
+
+	expectedVersion, err := ifMatchVersion(ctx)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, err)
+		return
+	}
+
+	document, err := handler.service.Restore(ctx.Request.Context(), idCitizen, id, expectedVersion)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, err)
+		return
+	}
+
+	setDocumentETag(ctx, document)
+
+	response := endpoints.RestoreResponse{
+		Success: true,
+		Message: "document restored successfully",
+		Data:    *presenter.ToDocumentResponse(document),
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
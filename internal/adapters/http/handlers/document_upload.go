@@ -10,6 +10,7 @@ import (
 	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/middleware"
 	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/presenter"
 	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/metrics"
 )
 
@@ -37,8 +38,13 @@ func NewDocumentUploadHandler(service usecases.DocumentService, errorHandler *er
 // @Security BearerAuth
 // @Param file formData file true "File to upload"
 // @Success 201 {object} endpoints.UploadResponse "Document uploaded successfully"
+// @Header 201 {string} ETag "Version of the document"
+// @Success 200 {object} endpoints.UploadResponse "The owner already had the same file; the existing document is returned"
+// @Header 200 {string} ETag "Version of the document"
 // @Failure 400 {object} endpoints.UploadErrorResponse "Validation error"
 // @Failure 401 {object} endpoints.UploadErrorResponse "Unauthorized - invalid or missing token"
+// @Failure 409 {object} endpoints.UploadErrorResponse "Document quota exceeded"
+// @Failure 413 {object} endpoints.UploadErrorResponse "Storage quota exceeded"
 // @Failure 500 {object} endpoints.UploadErrorResponse "Internal server error"
 // @Router /api/docs/documents [post]
 func (handler *DocumentUploadHandler) Upload(ctx *gin.Context) {
@@ -56,7 +62,7 @@ func (handler *DocumentUploadHandler) Upload(ctx *gin.Context) {
 		return
 	}
 
-	document, err := handler.service.Upload(ctx.Request.Context(), file, idCitizen)
+	document, created, err := handler.service.Upload(ctx.Request.Context(), file, idCitizen)
 	if err != nil {
 		handler.errorHandler.HandleError(ctx, err)
 		return
@@ -64,8 +70,21 @@ func (handler *DocumentUploadHandler) Upload(ctx *gin.Context) {
 
 	handler.metrics.UploadRequestsTotal.Inc()
 
-	ctx.JSON(http.StatusCreated, endpoints.UploadResponse{
-		Success: true,
-		Data:    *presenter.ToDocumentResponse(document),
+	writeUploadResponse(ctx, document, created)
+}
+
+// writeUploadResponse writes the document returned by an upload: 201 when it was created, 200 when
+// the owner already had the same content
+func writeUploadResponse(ctx *gin.Context, document *models.Document, created bool) {
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	}
+
+	setDocumentETag(ctx, document)
+	ctx.JSON(status, endpoints.UploadResponse{
+		Success:      true,
+		Data:         *presenter.ToDocumentResponse(document),
+		Deduplicated: !created,
 	})
 }
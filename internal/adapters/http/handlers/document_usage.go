@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/endpoints"
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/middleware"
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+)
+
+// DocumentUsageHandler handles HTTP requests for the storage consumed by the authenticated user
+type DocumentUsageHandler struct {
+	service      usecases.DocumentUsageService
+	errorHandler *errors.ErrorHandler
+}
+
+// NewDocumentUsageHandler creates a new handler for usage operations
+func NewDocumentUsageHandler(service usecases.DocumentUsageService, errorHandler *errors.ErrorHandler) *DocumentUsageHandler {
+	return &DocumentUsageHandler{
+		service:      service,
+		errorHandler: errorHandler,
+	}
+}
+
+// GetUsage godoc
+// @Summary Get the storage usage of the authenticated user
+// @Description Returns the number and total size of the documents of the authenticated user (citizen ID from JWT)
+// @Description along with their quota. A limit of 0 is unlimited. Documents in the trash do not count.
+// @Description
+// @Description Uploads and restores taking the user past the quota fail with `QUOTA_EXCEEDED`: 409 when they have too many
+// @Description documents, 413 when the document does not fit in the bytes left.
+// @Tags documents
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} endpoints.UsageResponse "Usage retrieved successfully"
+// @Failure 400 {object} endpoints.UsageErrorResponse "Validation error - invalid citizen ID"
+// @Failure 500 {object} endpoints.UsageErrorResponse "Internal server error - database error"
+// @Router /api/docs/documents/usage [get]
+func (handler *DocumentUsageHandler) GetUsage(ctx *gin.Context) {
+	idCitizen, err := middleware.GetUserIDCitizen(ctx)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("user not authenticated"))
+		return
+	}
+
+	usage, quota, err := handler.service.GetUsage(ctx.Request.Context(), idCitizen)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, endpoints.UsageResponse{
+		Success: true,
+		Data: endpoints.UsageData{
+			DocumentCount: usage.DocumentCount,
+			TotalBytes:    usage.TotalBytes,
+			MaxDocuments:  quota.MaxDocuments,
+			MaxBytes:      quota.MaxBytes,
+		},
+	})
+}
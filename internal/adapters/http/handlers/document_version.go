@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+const (
+	// Conditional request headers carrying the version of a document
+	etagHeader    = "ETag"
+	ifMatchHeader = "If-Match"
+)
+
+// setDocumentETag writes the version of a document as the ETag of the response. Documents written
+// before versioning have no ETag until their next change
+func setDocumentETag(ctx *gin.Context, document *models.Document) {
+	if document.Version > 0 {
+		ctx.Header(etagHeader, `"`+strconv.FormatInt(document.Version, 10)+`"`)
+	}
+}
+
+// ifMatchVersion returns the document version required by the If-Match header of a request, or 0
+// when any version is accepted because the header is absent or "*"
+func ifMatchVersion(ctx *gin.Context) (int64, error) {
+	header := strings.TrimSpace(ctx.GetHeader(ifMatchHeader))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	if len(header) > 2 && strings.HasPrefix(header, `"`) && strings.HasSuffix(header, `"`) {
+		if version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64); err == nil && version > 0 {
+			return version, nil
+		}
+	}
+	return 0, errors.NewValidationError("If-Match must be the ETag of the document")
+}
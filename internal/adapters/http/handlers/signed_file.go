@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	domainerrors "github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+)
+
+// signedFileField is the form field carrying the file in signed POST uploads
+const signedFileField = "file"
+
+// SignedFileHandler serves the signed URLs issued by storages without an endpoint of their own,
+// such as the local filesystem storage. The signature is the only authorization
+type SignedFileHandler struct {
+	store        interfaces.SignedObjectServer
+	errorHandler *errors.ErrorHandler
+}
+
+// NewSignedFileHandler creates a new handler for signed file URLs
+func NewSignedFileHandler(store interfaces.SignedObjectServer, errorHandler *errors.ErrorHandler) *SignedFileHandler {
+	return &SignedFileHandler{
+		store:        store,
+		errorHandler: errorHandler,
+	}
+}
+
+// Download godoc
+// @Summary Download a file through a signed URL
+// @Description Serves an object of the local filesystem storage. The URL is obtained from the API (for example the
+// @Description transfer or authentication endpoints) and expires after the time it was signed for.
+// @Tags files
+// @Produce octet-stream
+// @Param key path string true "Object key"
+// @Param expires query integer true "Expiration as a Unix timestamp"
+// @Param signature query string true "URL signature"
+// @Success 200 {file} binary "File content"
+// @Failure 403 {object} endpoints.SignedFileErrorResponse "Invalid or expired signature"
+// @Failure 404 {object} endpoints.SignedFileErrorResponse "File not found"
+// @Router /api/docs/files/{key} [get]
+func (handler *SignedFileHandler) Download(ctx *gin.Context) {
+	objectKey := ctx.Param("key")
+
+	body, info, err := handler.store.OpenSigned(ctx.Request.Context(), objectKey, ctx.Request.URL.Query())
+	if err != nil {
+		handler.handleError(ctx, err)
+		return
+	}
+	defer func() { _ = body.Close() }()
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	ctx.Header("Content-Type", contentType)
+
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		http.ServeContent(ctx.Writer, ctx.Request, path.Base(objectKey), time.Time{}, seeker)
+		return
+	}
+	ctx.DataFromReader(http.StatusOK, info.Size, contentType, body, nil)
+}
+
+// Upload godoc
+// @Summary Upload a file through a signed URL
+// @Description Stores an object in the local filesystem storage through a URL returned by the direct upload endpoint.
+// @Description `PUT` requests carry the file as the body; `POST` requests are multipart forms with the signed fields
+// @Description followed by a `file` field. Files that do not match the signed size, content type or checksum are rejected.
+// @Tags files
+// @Accept octet-stream
+// @Param key path string true "Object key"
+// @Success 200 "File stored (PUT)"
+// @Success 204 "File stored (POST)"
+// @Failure 400 {object} endpoints.SignedFileErrorResponse "File does not match the signed policy"
+// @Failure 403 {object} endpoints.SignedFileErrorResponse "Invalid or expired signature"
+// @Router /api/docs/files/{key} [put]
+// @Router /api/docs/files/{key} [post]
+func (handler *SignedFileHandler) Upload(ctx *gin.Context) {
+	objectKey := ctx.Param("key")
+
+	if ctx.Request.Method == http.MethodPost {
+		handler.uploadForm(ctx, objectKey)
+		return
+	}
+
+	err := handler.store.PutSigned(ctx.Request.Context(), http.MethodPut, objectKey, ctx.Request.URL.Query(), ctx.ContentType(), ctx.Request.Body)
+	if err != nil {
+		handler.handleError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+// uploadForm handles a signed POST upload. The signed fields must precede the file, which is
+// streamed to storage without buffering
+func (handler *SignedFileHandler) uploadForm(ctx *gin.Context, objectKey string) {
+	reader, err := ctx.Request.MultipartReader()
+	if err != nil {
+		handler.handleError(ctx, interfaces.ErrUploadPolicyViolation)
+		return
+	}
+
+	params := url.Values{}
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			// The form ended, or is malformed, before the file
+			handler.handleError(ctx, interfaces.ErrUploadPolicyViolation)
+			return
+		}
+
+		if part.FormName() == signedFileField {
+			err = handler.store.PutSigned(ctx.Request.Context(), http.MethodPost, objectKey, params, params.Get("Content-Type"), part)
+			if err != nil {
+				handler.handleError(ctx, err)
+				return
+			}
+			ctx.Status(http.StatusNoContent)
+			return
+		}
+
+		if err := readFormField(part, params); err != nil {
+			handler.handleError(ctx, interfaces.ErrUploadPolicyViolation)
+			return
+		}
+	}
+}
+
+// readFormField adds a small form field to params
+func readFormField(part *multipart.Part, params url.Values) error {
+	defer func() { _ = part.Close() }()
+
+	value, err := io.ReadAll(io.LimitReader(part, 4096))
+	if err != nil {
+		return err
+	}
+	params.Set(part.FormName(), string(value))
+	return nil
+}
+
+// handleError maps storage errors to domain errors and writes them through the error handler
+func (handler *SignedFileHandler) handleError(ctx *gin.Context, err error) {
+	switch {
+	case stderrors.Is(err, interfaces.ErrInvalidSignature):
+		err = domainerrors.NewInvalidSignatureError(err.Error())
+	case stderrors.Is(err, interfaces.ErrObjectNotFound):
+		err = domainerrors.NewNotFoundError("file not found")
+	case stderrors.Is(err, interfaces.ErrUploadPolicyViolation):
+		err = domainerrors.NewValidationError(err.Error())
+	}
+	handler.errorHandler.HandleError(ctx, err)
+}
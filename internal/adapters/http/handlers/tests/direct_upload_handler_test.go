@@ -0,0 +1,107 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	handlers "github.com/kristianrpo/document-management-microservice/internal/adapters/http/handlers"
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDirectUploadService records the last initiation and completes once the file is marked as uploaded
+type fakeDirectUploadService struct {
+	method   string
+	checksum string
+	uploaded bool
+}
+
+func (f *fakeDirectUploadService) Initiate(ctx context.Context, ownerID int64, filename string, size int64, checksumSHA256, method string) (*models.UploadSession, *interfaces.PresignedUpload, error) {
+	f.method = method
+	f.checksum = checksumSHA256
+	session := &models.UploadSession{ID: "upload-1", OwnerID: ownerID, Filename: filename, Length: size}
+	presigned := &interfaces.PresignedUpload{
+		Method:    "PUT",
+		URL:       "https://bucket.example.com/staging/upload-1",
+		Headers:   map[string]string{"Content-Type": "application/pdf"},
+		ExpiresAt: time.Now().Add(15 * time.Minute),
+	}
+	return session, presigned, nil
+}
+
+func (f *fakeDirectUploadService) Complete(ctx context.Context, id string, ownerID int64) (*models.Document, bool, error) {
+	if id != "upload-1" {
+		return nil, false, errors.NewNotFoundError("upload session not found")
+	}
+	if !f.uploaded {
+		return nil, false, errors.NewUploadOffsetMismatchError("the file has not been uploaded yet")
+	}
+	return &models.Document{ID: "doc-1", Filename: "a.pdf", OwnerID: ownerID}, true, nil
+}
+
+func newDirectUploadRouter(t *testing.T, service *fakeDirectUploadService) http.Handler {
+	r, errHandler, metricsCollector := newTestRouter(t, true, 1)
+	h := handlers.NewDirectUploadHandler(service, errHandler, metricsCollector)
+	sessions := handlers.NewUploadSessionHandler(newFakeUploadSessionService(10), errHandler, metricsCollector)
+
+	// Register the resumable upload routes as well to make sure both sets can coexist
+	r.POST("/api/docs/uploads", sessions.Create)
+	r.POST("/api/docs/uploads/:id/complete", sessions.Complete)
+	r.POST("/api/docs/uploads/direct", h.Initiate)
+	r.POST("/api/docs/uploads/direct/:id/complete", h.Complete)
+	return r
+}
+
+func TestDirectUploadHandler_Initiate(t *testing.T) {
+	service := &fakeDirectUploadService{}
+	r := newDirectUploadRouter(t, service)
+
+	t.Run("success", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/docs/uploads/direct", strings.NewReader(`{"filename":"a.pdf","size":10,"checksum_sha256":"abc","method":"PUT"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"id":"upload-1"`)
+		assert.Contains(t, w.Body.String(), "https://bucket.example.com/staging/upload-1")
+		assert.Equal(t, "PUT", service.method)
+		assert.Equal(t, "abc", service.checksum)
+	})
+
+	t.Run("invalid method", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/docs/uploads/direct", strings.NewReader(`{"filename":"a.pdf","size":10,"method":"PATCH"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "VALIDATION_ERROR")
+	})
+}
+
+func TestDirectUploadHandler_Complete(t *testing.T) {
+	service := &fakeDirectUploadService{}
+	r := newDirectUploadRouter(t, service)
+
+	// Completing before the client has sent the file is a conflict it can retry
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/docs/uploads/direct/upload-1/complete", nil))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	service.uploaded = true
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/docs/uploads/direct/upload-1/complete", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "doc-1")
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/docs/uploads/direct/other/complete", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -32,7 +32,7 @@ func TestDocumentDeleteAllHandler_Success(t *testing.T) {
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), "all documents deleted successfully")
+	assert.Contains(t, w.Body.String(), "all documents moved to the trash")
 	assert.Contains(t, w.Body.String(), `"deleted_count":5`)
 	service.AssertExpectations(t)
 }
@@ -18,8 +18,8 @@ import (
 
 type mockDeleteService struct{ mock.Mock }
 
-func (m *mockDeleteService) Delete(ctx context.Context, id string) error {
-	args := m.Called(ctx, id)
+func (m *mockDeleteService) Delete(ctx context.Context, id string, expectedVersion int64) error {
+	args := m.Called(ctx, id, expectedVersion)
 	return args.Error(0)
 }
 
@@ -40,7 +40,7 @@ func TestDocumentDeleteHandler_Success(t *testing.T) {
 
 	// Expect GetByID called to verify owner
 	getService.On("GetByID", mock.Anything, "doc123").Return(&models.Document{ID: "doc123", OwnerID: 123456}, nil)
-	service.On("Delete", mock.Anything, "doc123").Return(nil)
+	service.On("Delete", mock.Anything, "doc123", int64(0)).Return(nil)
 
 	w := runWithAuthenticatedRouter(t, http.MethodDelete, "/api/docs/documents/doc123", func(r *gin.Engine) {
 		errMapper := apierrors.NewErrorMapper()
@@ -51,7 +51,7 @@ func TestDocumentDeleteHandler_Success(t *testing.T) {
 	})
 
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Contains(t, w.Body.String(), "document deleted successfully")
+	assert.Contains(t, w.Body.String(), "document moved to the trash")
 	service.AssertExpectations(t)
 }
 
@@ -64,7 +64,7 @@ func TestDocumentDeleteHandler_NotFound(t *testing.T) {
 
 	// GetByID returns a document; Delete returns not found for this id
 	getService.On("GetByID", mock.Anything, "nope").Return(&models.Document{ID: "nope", OwnerID: 123456}, nil)
-	service.On("Delete", mock.Anything, "nope").Return(errors.NewNotFoundError("document not found"))
+	service.On("Delete", mock.Anything, "nope", int64(0)).Return(errors.NewNotFoundError("document not found"))
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/docs/documents/nope", nil)
 	w := httptest.NewRecorder()
@@ -83,7 +83,7 @@ func TestDocumentDeleteHandler_PersistenceError(t *testing.T) {
 	r.DELETE("/api/docs/documents/:id", h.Delete)
 
 	getService.On("GetByID", mock.Anything, "doc123").Return(&models.Document{ID: "doc123", OwnerID: 123456}, nil)
-	service.On("Delete", mock.Anything, "doc123").Return(errors.NewPersistenceError(assert.AnError))
+	service.On("Delete", mock.Anything, "doc123", int64(0)).Return(errors.NewPersistenceError(assert.AnError))
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/docs/documents/doc123", nil)
 	w := httptest.NewRecorder()
@@ -93,3 +93,32 @@ func TestDocumentDeleteHandler_PersistenceError(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "PERSISTENCE_ERROR")
 	service.AssertExpectations(t)
 }
+
+func TestDocumentDeleteHandler_IfMatch(t *testing.T) {
+	r, errHandler, metricsCollector := newTestRouter(t, true, 123456)
+	service := new(mockDeleteService)
+	getService := new(mockDeleteGetService)
+	h := handlers.NewDocumentDeleteHandler(service, getService, errHandler, metricsCollector)
+	r.DELETE("/api/docs/documents/:id", h.Delete)
+
+	getService.On("GetByID", mock.Anything, "doc123").Return(&models.Document{ID: "doc123", OwnerID: 123456, Version: 4}, nil)
+	service.On("Delete", mock.Anything, "doc123", int64(3)).Return(errors.NewConflictError("document was modified"))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/docs/documents/doc123", nil)
+	req.Header.Set("If-Match", `"3"`)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "CONFLICT")
+
+	for _, ifMatch := range []string{`W/"4"`, "4", `"0"`, `"abc"`} {
+		req = httptest.NewRequest(http.MethodDelete, "/api/docs/documents/doc123", nil)
+		req.Header.Set("If-Match", ifMatch)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, ifMatch)
+	}
+	service.AssertExpectations(t)
+}
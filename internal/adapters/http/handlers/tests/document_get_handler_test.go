@@ -39,7 +39,7 @@ func TestDocumentGetHandler_Success(t *testing.T) {
 	h := handlers.NewDocumentGetHandler(service, errHandler, metricsCollector)
 	r.GET("/api/docs/documents/:id", h.GetByID)
 
-	doc := &models.Document{ID: "123", Filename: "a.pdf", MimeType: "application/pdf"}
+	doc := &models.Document{ID: "123", Filename: "a.pdf", MimeType: "application/pdf", Version: 3}
 	service.On("GetByID", mock.Anything, "123").Return(doc, nil)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/docs/documents/123", nil)
@@ -47,6 +47,7 @@ func TestDocumentGetHandler_Success(t *testing.T) {
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
 	presented := presenter.ToDocumentResponse(doc)
 	assert.Contains(t, w.Body.String(), presented.Filename)
 	service.AssertExpectations(t)
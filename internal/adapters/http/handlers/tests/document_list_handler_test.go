@@ -5,8 +5,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	handlers "github.com/kristianrpo/document-management-microservice/internal/adapters/http/handlers"
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
 	"github.com/kristianrpo/document-management-microservice/internal/application/util"
 	domainerrors "github.com/kristianrpo/document-management-microservice/internal/domain/errors"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
@@ -20,12 +22,36 @@ func (okListService) List(ctx context.Context, ownerID int64, page, limit int) (
 	return []*models.Document{{ID: "1", Filename: "a.pdf"}}, util.PaginationParams{Page: 1, Limit: 10}, 1, 1, nil
 }
 
+func (okListService) ListPage(ctx context.Context, ownerID int64, query usecases.DocumentListQuery) (*usecases.DocumentPage, error) {
+	page := &usecases.DocumentPage{Documents: []*models.Document{{ID: "2", Filename: "b.pdf"}}, Limit: query.Limit, NextCursor: "next-" + query.Sort.String() + "-" + query.Cursor}
+	if query.IncludeTotal {
+		total := int64(7)
+		page.TotalItems = &total
+	}
+	return page, nil
+}
+
+// filterListService records the filter of the last cursor listing
+type filterListService struct {
+	okListService
+	filter *models.DocumentFilter
+}
+
+func (s filterListService) ListPage(ctx context.Context, ownerID int64, query usecases.DocumentListQuery) (*usecases.DocumentPage, error) {
+	*s.filter = query.Filter
+	return s.okListService.ListPage(ctx, ownerID, query)
+}
+
 type errListService struct{}
 
 func (errListService) List(ctx context.Context, ownerID int64, page, limit int) ([]*models.Document, util.PaginationParams, int, int64, error) {
 	return nil, util.PaginationParams{}, 0, 0, domainerrors.NewPersistenceError(assert.AnError)
 }
 
+func (errListService) ListPage(ctx context.Context, ownerID int64, query usecases.DocumentListQuery) (*usecases.DocumentPage, error) {
+	return nil, domainerrors.NewValidationError("invalid cursor")
+}
+
 func TestDocumentListHandler_Success(t *testing.T) {
 	r, errHandler, metricsCollector := newTestRouter(t, true, 1)
 	h := handlers.NewDocumentListHandler(okListService{}, errHandler, metricsCollector)
@@ -39,6 +65,20 @@ func TestDocumentListHandler_Success(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "a.pdf")
 }
 
+func TestDocumentListHandler_PagesByNumberByDefault(t *testing.T) {
+	r, errHandler, metricsCollector := newTestRouter(t, true, 1)
+	h := handlers.NewDocumentListHandler(okListService{}, errHandler, metricsCollector)
+	r.GET("/api/docs/documents", h.List)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/docs/documents?limit=10", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "a.pdf")
+	assert.Contains(t, w.Body.String(), `"pagination":{"page":1,"limit":10,"total_items":1,"total_pages":1}`)
+}
+
 func TestDocumentListHandler_ValidationError(t *testing.T) {
 	r, errHandler, metricsCollector := newTestRouter(t, false, 0)
 	h := handlers.NewDocumentListHandler(okListService{}, errHandler, metricsCollector)
@@ -65,3 +105,94 @@ func TestDocumentListHandler_ServiceError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	assert.Contains(t, w.Body.String(), "PERSISTENCE_ERROR")
 }
+
+func TestDocumentListHandler_Cursor(t *testing.T) {
+	r, errHandler, metricsCollector := newTestRouter(t, true, 1)
+	h := handlers.NewDocumentListHandler(okListService{}, errHandler, metricsCollector)
+	r.GET("/api/docs/documents", h.List)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/docs/documents?cursor=abc&limit=5", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "b.pdf")
+	assert.Contains(t, w.Body.String(), `"next_cursor":"next-:-abc"`)
+	assert.Contains(t, w.Body.String(), `"limit":5`)
+	assert.NotContains(t, w.Body.String(), "total_items", "the total is only counted on request")
+
+	req = httptest.NewRequest(http.MethodGet, "/api/docs/documents?include_total=true", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total_items":7`)
+}
+
+func TestDocumentListHandler_InvalidCursor(t *testing.T) {
+	r, errHandler, metricsCollector := newTestRouter(t, true, 1)
+	h := handlers.NewDocumentListHandler(errListService{}, errHandler, metricsCollector)
+	r.GET("/api/docs/documents", h.List)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/docs/documents?cursor=forged", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "VALIDATION_ERROR")
+}
+
+func TestDocumentListHandler_Sort(t *testing.T) {
+	r, errHandler, metricsCollector := newTestRouter(t, true, 1)
+	h := handlers.NewDocumentListHandler(okListService{}, errHandler, metricsCollector)
+	r.GET("/api/docs/documents", h.List)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/docs/documents?sort=size&order=asc&cursor=abc", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"next_cursor":"next-size:asc-abc"`)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/docs/documents?page=2&sort=size", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code, "page listing is not sorted")
+	assert.Contains(t, w.Body.String(), "VALIDATION_ERROR")
+}
+
+func TestDocumentListHandler_Filter(t *testing.T) {
+	r, errHandler, metricsCollector := newTestRouter(t, true, 1)
+	var filter models.DocumentFilter
+	h := handlers.NewDocumentListHandler(filterListService{filter: &filter}, errHandler, metricsCollector)
+	r.GET("/api/docs/documents", h.List)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/docs/documents?authentication_status=authenticated&mime_type=image&created_from=2025-01-01&created_to=2025-01-31&filename=Pass", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, models.DocumentFilter{
+		AuthenticationStatus: models.AuthenticationStatusAuthenticated,
+		MimeFamily:           "image",
+		CreatedFrom:          time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedTo:            time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond),
+		FilenameContains:     "Pass",
+	}, filter, "a date closing the range includes the whole day")
+
+	for _, query := range []string{
+		"mime_type=image/png",
+		"authentication_status=pending",
+		"created_from=yesterday",
+		"page=1&filename=pass",
+		"page=2&cursor=abc",
+	} {
+		req = httptest.NewRequest(http.MethodGet, "/api/docs/documents?"+query, nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Contains(t, w.Body.String(), "VALIDATION_ERROR", query)
+	}
+}
@@ -29,8 +29,8 @@ func (m *mockAuthGetService) GetByID(ctx context.Context, id string) (*models.Do
 
 type mockRequestAuthService struct{ mock.Mock }
 
-func (m *mockRequestAuthService) RequestAuthentication(ctx context.Context, documentID string) error {
-	args := m.Called(ctx, documentID)
+func (m *mockRequestAuthService) RequestAuthentication(ctx context.Context, documentID string, expectedVersion int64) error {
+	args := m.Called(ctx, documentID, expectedVersion)
 	return args.Error(0)
 }
 
@@ -40,7 +40,7 @@ func TestDocumentRequestAuthenticationHandler_Success(t *testing.T) {
 	getService := new(mockAuthGetService)
 
 	getService.On("GetByID", mock.Anything, "doc123").Return(&models.Document{ID: "doc123", OwnerID: 123456}, nil)
-	service.On("RequestAuthentication", mock.Anything, "doc123").Return(nil)
+	service.On("RequestAuthentication", mock.Anything, "doc123", int64(0)).Return(nil)
 
 	w := runWithAuthenticatedRouter(t, http.MethodPost, "/api/docs/documents/doc123/request-authentication", func(r *gin.Engine) {
 		errMapper := apierrors.NewErrorMapper()
@@ -108,7 +108,7 @@ func TestDocumentRequestAuthenticationHandler_PersistenceError(t *testing.T) {
 	r.POST("/api/docs/documents/:id/request-authentication", h.RequestAuthentication)
 
 	getService.On("GetByID", mock.Anything, "doc123").Return(&models.Document{ID: "doc123", OwnerID: 123456}, nil)
-	service.On("RequestAuthentication", mock.Anything, "doc123").Return(errors.NewPersistenceError(assert.AnError))
+	service.On("RequestAuthentication", mock.Anything, "doc123", int64(0)).Return(errors.NewPersistenceError(assert.AnError))
 
 	req := httptest.NewRequest(http.MethodPost, "/api/docs/documents/doc123/request-authentication", nil)
 	w := httptest.NewRecorder()
@@ -0,0 +1,73 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	handlers "github.com/kristianrpo/document-management-microservice/internal/adapters/http/handlers"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockRestoreService struct{ mock.Mock }
+
+func (m *mockRestoreService) Restore(ctx context.Context, ownerID int64, id string, expectedVersion int64) (*models.Document, error) {
+	args := m.Called(ctx, ownerID, id, expectedVersion)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Document), args.Error(1)
+}
+
+func TestDocumentRestoreHandler_Success(t *testing.T) {
+	r, errHandler, _ := newTestRouter(t, true, 123456)
+	service := new(mockRestoreService)
+	h := handlers.NewDocumentRestoreHandler(service, errHandler)
+	r.POST("/api/docs/documents/:id/restore", h.Restore)
+
+	service.On("Restore", mock.Anything, int64(123456), "doc123", int64(3)).Return(&models.Document{ID: "doc123", OwnerID: 123456, Version: 4}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/docs/documents/doc123/restore", nil)
+	req.Header.Set("If-Match", `"3"`)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), "document restored successfully")
+	service.AssertExpectations(t)
+}
+
+func TestDocumentRestoreHandler_NotInTrash(t *testing.T) {
+	r, errHandler, _ := newTestRouter(t, true, 123456)
+	service := new(mockRestoreService)
+	h := handlers.NewDocumentRestoreHandler(service, errHandler)
+	r.POST("/api/docs/documents/:id/restore", h.Restore)
+
+	service.On("Restore", mock.Anything, int64(123456), "doc123", int64(0)).Return(nil, errors.NewNotFoundError("document not found in the trash"))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/docs/documents/doc123/restore", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "NOT_FOUND")
+}
+
+func TestDocumentRestoreHandler_Unauthenticated(t *testing.T) {
+	r, errHandler, _ := newTestRouter(t, false, 0)
+	service := new(mockRestoreService)
+	h := handlers.NewDocumentRestoreHandler(service, errHandler)
+	r.POST("/api/docs/documents/:id/restore", h.Restore)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/docs/documents/doc123/restore", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	service.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
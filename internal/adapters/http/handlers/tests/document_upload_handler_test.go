@@ -18,14 +18,21 @@ import (
 // Fake upload service implementing DocumentService
 type okUploadService struct{}
 
-func (okUploadService) Upload(ctx context.Context, fileHeader *multipart.FileHeader, ownerID int64) (*models.Document, error) {
-	return &models.Document{ID: "1", Filename: fileHeader.Filename, OwnerID: ownerID, MimeType: "application/pdf"}, nil
+func (okUploadService) Upload(ctx context.Context, fileHeader *multipart.FileHeader, ownerID int64) (*models.Document, bool, error) {
+	return &models.Document{ID: "1", Filename: fileHeader.Filename, OwnerID: ownerID, MimeType: "application/pdf"}, true, nil
+}
+
+// Fake upload service finding the same content already uploaded
+type dedupUploadService struct{}
+
+func (dedupUploadService) Upload(ctx context.Context, fileHeader *multipart.FileHeader, ownerID int64) (*models.Document, bool, error) {
+	return &models.Document{ID: "existing", Filename: "original.pdf", OwnerID: ownerID, MimeType: "application/pdf"}, false, nil
 }
 
 type errUploadService struct{}
 
-func (errUploadService) Upload(ctx context.Context, fileHeader *multipart.FileHeader, ownerID int64) (*models.Document, error) {
-	return nil, errors.NewPersistenceError(assert.AnError)
+func (errUploadService) Upload(ctx context.Context, fileHeader *multipart.FileHeader, ownerID int64) (*models.Document, bool, error) {
+	return nil, false, errors.NewPersistenceError(assert.AnError)
 }
 
 func TestDocumentUploadHandler_TableDriven(t *testing.T) {
@@ -47,7 +54,18 @@ func TestDocumentUploadHandler_TableDriven(t *testing.T) {
 				return createMultipartBody(t, "a.pdf", "content", "1")
 			},
 			expectedStatus:  http.StatusCreated,
-			expectedContent: "a.pdf",
+			expectedContent: `"deduplicated":false`,
+		},
+		{
+			name:     "deduplicated",
+			withAuth: true,
+			ownerID:  1,
+			service:  dedupUploadService{},
+			buildBody: func() (*bytes.Buffer, string) {
+				return createMultipartBody(t, "a.pdf", "content", "1")
+			},
+			expectedStatus:  http.StatusOK,
+			expectedContent: `"deduplicated":true`,
 		},
 		{
 			name:     "validation error",
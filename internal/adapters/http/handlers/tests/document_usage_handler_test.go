@@ -0,0 +1,75 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	handlers "github.com/kristianrpo/document-management-microservice/internal/adapters/http/handlers"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockUsageService struct{ mock.Mock }
+
+func (m *mockUsageService) GetUsage(ctx context.Context, ownerID int64) (*models.OwnerUsage, models.Quota, error) {
+	args := m.Called(ctx, ownerID)
+	if args.Get(0) == nil {
+		return nil, models.Quota{}, args.Error(2)
+	}
+	return args.Get(0).(*models.OwnerUsage), args.Get(1).(models.Quota), args.Error(2)
+}
+
+func TestDocumentUsageHandler_Success(t *testing.T) {
+	r, errHandler, _ := newTestRouter(t, true, 123456)
+	service := new(mockUsageService)
+	h := handlers.NewDocumentUsageHandler(service, errHandler)
+	r.GET("/api/docs/documents/usage", h.GetUsage)
+
+	service.On("GetUsage", mock.Anything, int64(123456)).Return(
+		&models.OwnerUsage{OwnerID: 123456, DocumentCount: 3, TotalBytes: 2048},
+		models.Quota{MaxDocuments: 1000, MaxBytes: 1 << 30},
+		nil,
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/docs/documents/usage", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"success":true,"data":{"document_count":3,"total_bytes":2048,"max_documents":1000,"max_bytes":1073741824}}`, w.Body.String())
+	service.AssertExpectations(t)
+}
+
+func TestDocumentUsageHandler_ValidationError_Unauthenticated(t *testing.T) {
+	r, errHandler, _ := newTestRouter(t, false, 0)
+	service := new(mockUsageService)
+	h := handlers.NewDocumentUsageHandler(service, errHandler)
+	r.GET("/api/docs/documents/usage", h.GetUsage)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/docs/documents/usage", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "VALIDATION_ERROR")
+	service.AssertNotCalled(t, "GetUsage", mock.Anything, mock.Anything)
+}
+
+func TestDocumentUsageHandler_PersistenceError(t *testing.T) {
+	r, errHandler, _ := newTestRouter(t, true, 123456)
+	service := new(mockUsageService)
+	h := handlers.NewDocumentUsageHandler(service, errHandler)
+	r.GET("/api/docs/documents/usage", h.GetUsage)
+
+	service.On("GetUsage", mock.Anything, int64(123456)).Return(nil, nil, errors.NewPersistenceError(assert.AnError))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/docs/documents/usage", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
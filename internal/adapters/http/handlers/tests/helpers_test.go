@@ -97,12 +97,13 @@ func createTestMetrics(t *testing.T) *metrics.PrometheusMetrics {
 			},
 			[]string{"result"},
 		),
-		StorageUploadDuration: prometheus.NewHistogram(
+		StorageUploadDuration: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Namespace: namespace,
 				Name:      "storage_upload_duration_seconds",
 				Help:      "Storage upload duration",
 			},
+			[]string{"stage"},
 		),
 		StorageDownloadDuration: prometheus.NewHistogram(
 			prometheus.HistogramOpts{
@@ -0,0 +1,185 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	handlers "github.com/kristianrpo/document-management-microservice/internal/adapters/http/handlers"
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/storage"
+)
+
+const signedFileBaseURL = "http://localhost/api/docs/files"
+
+func newSignedFileRouter(t *testing.T) (http.Handler, *storage.FileSystemStorage) {
+	store, err := storage.NewFileSystemStorage(t.TempDir(), "documents", signedFileBaseURL, []byte("test-signing-key"))
+	require.NoError(t, err)
+
+	r, errHandler, _ := newTestRouter(t, false, 0)
+	h := handlers.NewSignedFileHandler(store, errHandler)
+	r.GET("/api/docs/files/*key", h.Download)
+	r.PUT("/api/docs/files/*key", h.Upload)
+	r.POST("/api/docs/files/*key", h.Upload)
+	return r, store
+}
+
+func requestPath(t *testing.T, signedURL string) string {
+	parsed, err := url.Parse(signedURL)
+	require.NoError(t, err)
+	return parsed.RequestURI()
+}
+
+func TestSignedFileHandler_Download(t *testing.T) {
+	r, store := newSignedFileRouter(t)
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, strings.NewReader("%PDF-1.4 content"), "ab/abcdef.pdf", "application/pdf"))
+
+	signedURL, err := store.GeneratePresignedURL(ctx, "ab/abcdef.pdf", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signedURL, signedFileBaseURL+"/ab/abcdef.pdf?"))
+
+	t.Run("valid signature", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, requestPath(t, signedURL), nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "%PDF-1.4 content", w.Body.String())
+		assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("signature for another key", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		tampered := strings.Replace(requestPath(t, signedURL), "abcdef.pdf", "other.pdf", 1)
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tampered, nil))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "INVALID_SIGNATURE")
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		expiredURL, err := store.GeneratePresignedURL(ctx, "ab/abcdef.pdf", -time.Minute)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, requestPath(t, expiredURL), nil))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("path traversal stays inside the storage", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/docs/files/../../etc/passwd", nil))
+
+		assert.NotEqual(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestSignedFileHandler_PutUpload(t *testing.T) {
+	r, store := newSignedFileRouter(t)
+	ctx := context.Background()
+	content := []byte("hello world")
+	sum := sha256.Sum256(content)
+	policy := interfaces.UploadPolicy{
+		ContentType:    "application/pdf",
+		Size:           int64(len(content)),
+		ChecksumSHA256: base64.StdEncoding.EncodeToString(sum[:]),
+		Expiration:     time.Minute,
+	}
+
+	presigned, err := store.GeneratePresignedPut(ctx, "staging/upload-1", policy)
+	require.NoError(t, err)
+
+	put := func(body []byte, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, requestPath(t, presigned.URL), bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("wrong size is rejected", func(t *testing.T) {
+		w := put([]byte("hello world!"), "application/pdf")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		_, err := store.Stat(ctx, "staging/upload-1")
+		assert.ErrorIs(t, err, interfaces.ErrObjectNotFound)
+	})
+
+	t.Run("wrong checksum is rejected", func(t *testing.T) {
+		w := put([]byte("hello World"), "application/pdf")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("wrong content type is rejected", func(t *testing.T) {
+		w := put(content, "text/plain")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("matching upload is stored", func(t *testing.T) {
+		w := put(content, "application/pdf")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		info, err := store.Stat(ctx, "staging/upload-1")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(content)), info.Size)
+	})
+
+	t.Run("download signature cannot upload", func(t *testing.T) {
+		downloadURL, err := store.GeneratePresignedURL(ctx, "staging/upload-1", time.Minute)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPut, requestPath(t, downloadURL), bytes.NewReader(content))
+		req.Header.Set("Content-Type", "application/pdf")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestSignedFileHandler_PostUpload(t *testing.T) {
+	r, store := newSignedFileRouter(t)
+	ctx := context.Background()
+	content := []byte("form content")
+
+	presigned, err := store.GeneratePresignedPost(ctx, "staging/upload-2", interfaces.UploadPolicy{
+		ContentType: "application/pdf",
+		Size:        int64(len(content)),
+		Expiration:  time.Minute,
+	})
+	require.NoError(t, err)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range presigned.FormFields {
+		require.NoError(t, writer.WriteField(name, value))
+	}
+	part, err := writer.CreateFormFile("file", "a.pdf")
+	require.NoError(t, err)
+	_, _ = part.Write(content)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, requestPath(t, presigned.URL), &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	stored, err := store.Open(ctx, "staging/upload-2")
+	require.NoError(t, err)
+	defer func() { _ = stored.Close() }()
+	data, _ := io.ReadAll(stored)
+	assert.Equal(t, content, data)
+}
@@ -0,0 +1,175 @@
+package handlers_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	handlers "github.com/kristianrpo/document-management-microservice/internal/adapters/http/handlers"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeUploadSessionService keeps a single in-memory session
+type fakeUploadSessionService struct {
+	session  *models.UploadSession
+	received []byte
+}
+
+func newFakeUploadSessionService(length int64) *fakeUploadSessionService {
+	return &fakeUploadSessionService{session: &models.UploadSession{ID: "upload-1", OwnerID: 1, Filename: "a.pdf", Length: length}}
+}
+
+func (f *fakeUploadSessionService) Create(ctx context.Context, ownerID int64, filename string, length int64) (*models.UploadSession, error) {
+	f.session = &models.UploadSession{ID: "upload-1", OwnerID: ownerID, Filename: filename, Length: length}
+	return f.session, nil
+}
+
+func (f *fakeUploadSessionService) Get(ctx context.Context, id string, ownerID int64) (*models.UploadSession, error) {
+	if id != f.session.ID || ownerID != f.session.OwnerID {
+		return nil, errors.NewNotFoundError("upload session not found")
+	}
+	return f.session, nil
+}
+
+func (f *fakeUploadSessionService) AppendChunk(ctx context.Context, id string, ownerID int64, offset int64, chunk io.Reader) (*models.UploadSession, error) {
+	session, err := f.Get(ctx, id, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if offset != session.Offset {
+		return nil, errors.NewUploadOffsetMismatchError("upload offset mismatch")
+	}
+	data, _ := io.ReadAll(chunk)
+	f.received = append(f.received, data...)
+	session.Offset += int64(len(data))
+	return session, nil
+}
+
+func (f *fakeUploadSessionService) Complete(ctx context.Context, id string, ownerID int64) (*models.Document, bool, error) {
+	session, err := f.Get(ctx, id, ownerID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !session.IsComplete() {
+		return nil, false, errors.NewUploadOffsetMismatchError("upload is incomplete")
+	}
+	return &models.Document{ID: "doc-1", Filename: session.Filename, OwnerID: ownerID, SizeBytes: session.Length}, true, nil
+}
+
+func newUploadSessionRouter(t *testing.T, service *fakeUploadSessionService) http.Handler {
+	r, errHandler, metricsCollector := newTestRouter(t, true, 1)
+	h := handlers.NewUploadSessionHandler(service, errHandler, metricsCollector)
+	r.POST("/api/docs/uploads", h.Create)
+	r.HEAD("/api/docs/uploads/:id", h.Head)
+	r.PATCH("/api/docs/uploads/:id", h.Patch)
+	r.POST("/api/docs/uploads/:id/complete", h.Complete)
+	return r
+}
+
+func newChunkRequest(offset, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPatch, "/api/docs/uploads/upload-1", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", offset)
+	return req
+}
+
+func TestUploadSessionHandler_Create(t *testing.T) {
+	r := newUploadSessionRouter(t, newFakeUploadSessionService(0))
+
+	t.Run("success", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/docs/uploads", strings.NewReader(`{"filename":"a.pdf","size":10}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "/api/docs/uploads/upload-1", w.Header().Get("Location"))
+		assert.Equal(t, "0", w.Header().Get("Upload-Offset"))
+		assert.Equal(t, "10", w.Header().Get("Upload-Length"))
+	})
+
+	t.Run("missing size", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/docs/uploads", strings.NewReader(`{"filename":"a.pdf"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "VALIDATION_ERROR")
+	})
+}
+
+func TestUploadSessionHandler_ResumeAfterInterruption(t *testing.T) {
+	service := newFakeUploadSessionService(10)
+	r := newUploadSessionRouter(t, service)
+
+	// First chunk
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newChunkRequest("0", "hello"))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Upload-Offset"))
+
+	// A retried chunk with a stale offset is rejected
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, newChunkRequest("0", "hello"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "UPLOAD_OFFSET_MISMATCH")
+
+	// The client queries the offset and resumes from there
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/api/docs/uploads/upload-1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Upload-Offset"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, newChunkRequest("5", "world"))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "helloworld", string(service.received))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/docs/uploads/upload-1/complete", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "doc-1")
+}
+
+func TestUploadSessionHandler_Patch_Validation(t *testing.T) {
+	r := newUploadSessionRouter(t, newFakeUploadSessionService(10))
+
+	t.Run("missing offset", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, newChunkRequest("", "hello"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("wrong content type", func(t *testing.T) {
+		req := newChunkRequest("0", "hello")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestUploadSessionHandler_Complete_Incomplete(t *testing.T) {
+	r := newUploadSessionRouter(t, newFakeUploadSessionService(10))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/docs/uploads/upload-1/complete", nil))
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestUploadSessionHandler_UnknownSession(t *testing.T) {
+	r := newUploadSessionRouter(t, newFakeUploadSessionService(10))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/api/docs/uploads/other", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/request"
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/endpoints"
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/middleware"
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/presenter"
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/metrics"
+)
+
+const (
+	// Resumable upload protocol headers (tus-style)
+	uploadOffsetHeader = "Upload-Offset"
+	uploadLengthHeader = "Upload-Length"
+
+	// chunkContentType is the media type expected for PATCH chunk bodies
+	chunkContentType = "application/offset+octet-stream"
+)
+
+// UploadSessionHandler handles HTTP requests for resumable chunked uploads
+type UploadSessionHandler struct {
+	service      usecases.UploadSessionService
+	errorHandler *errors.ErrorHandler
+	metrics      *metrics.PrometheusMetrics
+}
+
+// NewUploadSessionHandler creates a new handler for resumable upload operations
+func NewUploadSessionHandler(service usecases.UploadSessionService, errorHandler *errors.ErrorHandler, metricsCollector *metrics.PrometheusMetrics) *UploadSessionHandler {
+	return &UploadSessionHandler{
+		service:      service,
+		errorHandler: errorHandler,
+		metrics:      metricsCollector,
+	}
+}
+
+// Create godoc
+// @Summary Create a resumable upload
+// @Description Starts a resumable upload session for a file of the given size. Chunks are then sent with
+// @Description PATCH requests and the upload is finalized with the complete endpoint.
+// @Description
+// @Description ## Protocol
+// @Description 1. `POST /api/docs/uploads` with the filename and total size
+// @Description 2. `PATCH /api/docs/uploads/{id}` with `Upload-Offset` and a chunk body, as many times as needed
+// @Description 3. After a dropped connection, `HEAD /api/docs/uploads/{id}` returns the offset to resume from
+// @Description 4. `POST /api/docs/uploads/{id}/complete` creates the document
+// @Description
+// @Description Sessions expire if they are not completed in time (24 hours by default).
+// @Tags uploads
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body request.CreateUploadSessionRequest true "File to upload"
+// @Success 201 {object} endpoints.UploadSessionResponse "Upload session created"
+// @Header 201 {string} Location "URL of the upload session"
+// @Header 201 {integer} Upload-Offset "Bytes received so far"
+// @Header 201 {integer} Upload-Length "Declared total size"
+// @Failure 400 {object} endpoints.UploadSessionErrorResponse "Validation error"
+// @Failure 401 {object} endpoints.UploadSessionErrorResponse "Unauthorized - invalid or missing token"
+// @Failure 500 {object} endpoints.UploadSessionErrorResponse "Internal server error"
+// @Router /api/docs/uploads [post]
+func (handler *UploadSessionHandler) Create(ctx *gin.Context) {
+	idCitizen, err := middleware.GetUserIDCitizen(ctx)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("user not authenticated"))
+		return
+	}
+
+	var body request.CreateUploadSessionRequest
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("filename and a positive size are required"))
+		return
+	}
+
+	session, err := handler.service.Create(ctx.Request.Context(), idCitizen, body.Filename, body.Size)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, err)
+		return
+	}
+
+	setUploadHeaders(ctx, session)
+	ctx.Header("Location", strings.TrimSuffix(ctx.Request.URL.Path, "/")+"/"+session.ID)
+	ctx.JSON(http.StatusCreated, endpoints.UploadSessionResponse{
+		Success: true,
+		Data:    *presenter.ToUploadSessionResponse(session),
+	})
+}
+
+// Head godoc
+// @Summary Get the offset of a resumable upload
+// @Description Returns the number of bytes already received in the `Upload-Offset` header. Clients resume by sending
+// @Description the next chunk from that offset.
+// @Tags uploads
+// @Security BearerAuth
+// @Param id path string true "Upload session ID"
+// @Success 200 "Upload session state"
+// @Header 200 {integer} Upload-Offset "Bytes received so far"
+// @Header 200 {integer} Upload-Length "Declared total size"
+// @Failure 404 "Upload session not found or expired"
+// @Router /api/docs/uploads/{id} [head]
+func (handler *UploadSessionHandler) Head(ctx *gin.Context) {
+	idCitizen, err := middleware.GetUserIDCitizen(ctx)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("user not authenticated"))
+		return
+	}
+
+	session, err := handler.service.Get(ctx.Request.Context(), ctx.Param("id"), idCitizen)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, err)
+		return
+	}
+
+	setUploadHeaders(ctx, session)
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Status(http.StatusOK)
+}
+
+// Patch godoc
+// @Summary Append a chunk to a resumable upload
+// @Description Appends the request body at `Upload-Offset`, which must equal the current offset of the session.
+// @Description If the connection drops, the bytes received until then are kept; query the offset and resume.
+// @Description
+// @Description ## Error Codes
+// @Description - `UPLOAD_OFFSET_MISMATCH`: The offset does not match, or another request is writing the session
+// @Description - `VALIDATION_ERROR`: Missing headers or the chunk exceeds the declared size
+// @Tags uploads
+// @Accept application/offset+octet-stream
+// @Security BearerAuth
+// @Param id path string true "Upload session ID"
+// @Param Upload-Offset header integer true "Offset of the first byte of the chunk"
+// @Param chunk body string true "Chunk bytes"
+// @Success 204 "Chunk stored"
+// @Header 204 {integer} Upload-Offset "Bytes received so far"
+// @Failure 400 {object} endpoints.UploadSessionErrorResponse "Validation error"
+// @Failure 404 {object} endpoints.UploadSessionErrorResponse "Upload session not found or expired"
+// @Failure 409 {object} endpoints.UploadSessionErrorResponse "Offset mismatch"
+// @Failure 500 {object} endpoints.UploadSessionErrorResponse "Internal server error"
+// @Router /api/docs/uploads/{id} [patch]
+func (handler *UploadSessionHandler) Patch(ctx *gin.Context) {
+	idCitizen, err := middleware.GetUserIDCitizen(ctx)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("user not authenticated"))
+		return
+	}
+
+	if ctx.ContentType() != chunkContentType {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("content type must be "+chunkContentType))
+		return
+	}
+
+	offset, err := strconv.ParseInt(ctx.GetHeader(uploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("a valid Upload-Offset header is required"))
+		return
+	}
+
+	session, err := handler.service.AppendChunk(ctx.Request.Context(), ctx.Param("id"), idCitizen, offset, ctx.Request.Body)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, err)
+		return
+	}
+
+	setUploadHeaders(ctx, session)
+	ctx.Status(http.StatusNoContent)
+}
+
+// Complete godoc
+// @Summary Complete a resumable upload
+// @Description Creates the document once every byte has been received. The result is identical to a regular upload,
+// @Description including deduplication: if the owner already has the same file, the existing document is returned.
+// @Tags uploads
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Upload session ID"
+// @Success 201 {object} endpoints.UploadResponse "Document uploaded successfully"
+// @Header 201 {string} ETag "Version of the document"
+// @Success 200 {object} endpoints.UploadResponse "The owner already had the same file; the existing document is returned"
+// @Header 200 {string} ETag "Version of the document"
+// @Failure 404 {object} endpoints.UploadSessionErrorResponse "Upload session not found or expired"
+// @Failure 409 {object} endpoints.UploadSessionErrorResponse "Upload is incomplete, or document quota exceeded"
+// @Failure 413 {object} endpoints.UploadSessionErrorResponse "Storage quota exceeded"
+// @Failure 500 {object} endpoints.UploadSessionErrorResponse "Internal server error"
+// @Router /api/docs/uploads/{id}/complete [post]
+func (handler *UploadSessionHandler) Complete(ctx *gin.Context) {
+	idCitizen, err := middleware.GetUserIDCitizen(ctx)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, errors.NewValidationError("user not authenticated"))
+		return
+	}
+
+	document, created, err := handler.service.Complete(ctx.Request.Context(), ctx.Param("id"), idCitizen)
+	if err != nil {
+		handler.errorHandler.HandleError(ctx, err)
+		return
+	}
+
+	handler.metrics.UploadRequestsTotal.Inc()
+
+	writeUploadResponse(ctx, document, created)
+}
+
+// setUploadHeaders writes the protocol headers describing the state of a session
+func setUploadHeaders(ctx *gin.Context, session *models.UploadSession) {
+	ctx.Header(uploadOffsetHeader, strconv.FormatInt(session.Offset, 10))
+	ctx.Header(uploadLengthHeader, strconv.FormatInt(session.Length, 10))
+}
@@ -0,0 +1,23 @@
+package presenter
+
+import (
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/shared"
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// ToDirectUploadResponse converts an initiated direct upload to an HTTP response DTO
+func ToDirectUploadResponse(session *models.UploadSession, presigned *interfaces.PresignedUpload) *shared.DirectUploadResponse {
+	if session == nil || presigned == nil {
+		return nil
+	}
+
+	return &shared.DirectUploadResponse{
+		ID:         session.ID,
+		Method:     presigned.Method,
+		URL:        presigned.URL,
+		Headers:    presigned.Headers,
+		FormFields: presigned.FormFields,
+		ExpiresAt:  presigned.ExpiresAt.UTC(),
+	}
+}
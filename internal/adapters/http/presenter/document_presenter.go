@@ -20,6 +20,8 @@ func ToDocumentResponse(document *models.Document) *shared.DocumentResponse {
 		URL:                  document.URL,
 		OwnerID:              document.OwnerID,
 		AuthenticationStatus: string(document.AuthenticationStatus),
+		DeletedAt:            document.DeletedAt,
+		PurgeAt:              document.PurgeAt,
 	}
 }
 
@@ -56,5 +58,7 @@ func toDocumentListItem(document *models.Document) *shared.DocumentResponse {
 		URL:                  "",
 		OwnerID:              document.OwnerID,
 		AuthenticationStatus: string(document.AuthenticationStatus),
+		DeletedAt:            document.DeletedAt,
+		PurgeAt:              document.PurgeAt,
 	}
 }
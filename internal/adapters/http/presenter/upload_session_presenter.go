@@ -0,0 +1,24 @@
+package presenter
+
+import (
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/dto/response/shared"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// ToUploadSessionResponse converts a domain upload session to an HTTP response DTO
+func ToUploadSessionResponse(session *models.UploadSession) *shared.UploadSessionResponse {
+	if session == nil {
+		return nil
+	}
+
+	return &shared.UploadSessionResponse{
+		ID:        session.ID,
+		Filename:  session.Filename,
+		MimeType:  session.ContentType,
+		Length:    session.Length,
+		Offset:    session.Offset,
+		ExpiresAt: time.Unix(session.ExpiresAt, 0).UTC(),
+	}
+}
@@ -2,7 +2,7 @@ package http
 
 import (
 	"os"
-	
+
 	"github.com/gin-gonic/gin"
 	docs "github.com/kristianrpo/document-management-microservice/docs"
 	"github.com/kristianrpo/document-management-microservice/internal/adapters/http/handlers"
@@ -22,8 +22,16 @@ type RouterConfig struct {
 	DeleteAllHandler   *handlers.DocumentDeleteAllHandler
 	TransferHandler    *handlers.DocumentTransferHandler
 	RequestAuthHandler *handlers.DocumentRequestAuthenticationHandler
-	HealthHandler      *handlers.HealthHandler
-	MetricsCollector   *metrics.PrometheusMetrics
+	UsageHandler       *handlers.DocumentUsageHandler
+	RestoreHandler     *handlers.DocumentRestoreHandler
+	// Resumable upload handler (optional). Only registered when the storage supports multipart uploads
+	UploadSessionHandler *handlers.UploadSessionHandler
+	// Direct-to-storage upload handler (optional). Only registered when the storage supports staging
+	DirectUploadHandler *handlers.DirectUploadHandler
+	// Signed file handler (optional). Only registered when the storage serves its own signed URLs
+	SignedFileHandler *handlers.SignedFileHandler
+	HealthHandler     *handlers.HealthHandler
+	MetricsCollector  *metrics.PrometheusMetrics
 	// JWT middleware instance (optional). If provided, it will be applied to
 	// routes that require authentication (e.g. document upload).
 	JWTMiddleware *middleware.JWTAuthMiddleware
@@ -53,20 +61,44 @@ func NewRouter(cfg *RouterConfig) *gin.Engine {
 	{
 		// Health check endpoint
 		apiGroup.GET("/healthz", cfg.HealthHandler.Ping)
-		
+
 		// Swagger documentation
 		apiGroup.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-		
+
 		// User-protected endpoints (require authenticated user with role USER)
 		apiGroup.POST("/documents", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.UploadHandler.Upload)
 		apiGroup.GET("/documents", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.ListHandler.List)
+		apiGroup.GET("/documents/usage", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.UsageHandler.GetUsage)
+		apiGroup.GET("/documents/trash", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.ListHandler.ListTrash)
 		apiGroup.GET("/documents/:id", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.GetHandler.GetByID)
 		apiGroup.DELETE("/documents/:id", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.DeleteHandler.Delete)
 		apiGroup.DELETE("/documents/user/delete-all", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.DeleteAllHandler.DeleteAll)
+		apiGroup.POST("/documents/:id/restore", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.RestoreHandler.Restore)
 		apiGroup.POST("/documents/:id/request-authentication", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.RequestAuthHandler.RequestAuthentication)
 		apiGroup.GET("/documents/transfer/:id_citizen", cfg.JWTMiddleware.AuthenticateClient(), cfg.JWTMiddleware.RequireClientCredentials(), cfg.TransferHandler.PrepareTransfer)
+
+		// Resumable (tus-style) uploads
+		if cfg.UploadSessionHandler != nil {
+			apiGroup.POST("/uploads", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.UploadSessionHandler.Create)
+			apiGroup.HEAD("/uploads/:id", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.UploadSessionHandler.Head)
+			apiGroup.PATCH("/uploads/:id", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.UploadSessionHandler.Patch)
+			apiGroup.POST("/uploads/:id/complete", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.UploadSessionHandler.Complete)
+		}
+
+		// Pre-signed direct-to-storage uploads
+		if cfg.DirectUploadHandler != nil {
+			apiGroup.POST("/uploads/direct", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.DirectUploadHandler.Initiate)
+			apiGroup.POST("/uploads/direct/:id/complete", cfg.JWTMiddleware.Authenticate(), cfg.JWTMiddleware.RequireRole("USER"), cfg.DirectUploadHandler.Complete)
+		}
+
+		// Signed URLs of the local filesystem storage; the signature authorizes the request
+		if cfg.SignedFileHandler != nil {
+			apiGroup.GET("/files/*key", cfg.SignedFileHandler.Download)
+			apiGroup.PUT("/files/*key", cfg.SignedFileHandler.Upload)
+			apiGroup.POST("/files/*key", cfg.SignedFileHandler.Upload)
+		}
 	}
-	
+
 	// Keep root health check for Kubernetes probes compatibility
 	router.GET("/healthz", cfg.HealthHandler.Ping)
 
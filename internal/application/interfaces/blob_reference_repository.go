@@ -0,0 +1,28 @@
+package interfaces
+
+import "context"
+
+// BlobReferenceRepository tracks how many documents point to each content-addressed storage object.
+// Object keys are derived only from the file hash, so several owners may share one physical object.
+// Counters are incremented and decremented by DocumentRepository in the same atomic write that
+// creates or deletes a document; this repository only releases and repairs them.
+type BlobReferenceRepository interface {
+	// ReleaseIfUnreferenced takes a lease on the counter of an object once no document references
+	// it. Returns true when the caller may physically delete the object from storage; until the
+	// release is finished or its lease expires, no document can reference the object again. An
+	// object without a counter is never released, since the documents referencing it are unknown
+	ReleaseIfUnreferenced(ctx context.Context, objectKey string) (bool, error)
+
+	// ListUnreferenced returns up to limit objects that still have a counter although no document
+	// references them and no release holds them, such as the objects whose deletion from storage
+	// failed after their last document was deleted, so their release can be retried
+	ListUnreferenced(ctx context.Context, limit int) ([]string, error)
+
+	// FinishRelease removes the counter of an object released by ReleaseIfUnreferenced once the
+	// object was deleted from storage. A counter referenced again after its lease expired is kept
+	FinishRelease(ctx context.Context, objectKey string) error
+
+	// RebuildReferenceCounts recomputes every counter from the documents currently stored
+	// and returns the number of distinct objects that are still referenced
+	RebuildReferenceCounts(ctx context.Context) (int, error)
+}
@@ -10,6 +10,14 @@ import (
 // DocumentUploader defines an interface for uploading from an io.ReadSeeker.
 // This is implemented by the document upload usecase so other packages (e.g., event handlers)
 // can reuse the same upload logic without depending on the concrete implementation.
+// created is false when the owner already had a document with the same content, which is returned
 type DocumentUploader interface {
-	UploadFromReader(ctx context.Context, r io.ReadSeeker, filename string, size int64, ownerID int64) (*models.Document, error)
+	UploadFromReader(ctx context.Context, r io.ReadSeeker, filename string, size int64, ownerID int64) (document *models.Document, created bool, err error)
+}
+
+// StagedDocumentUploader turns an object already written to a staging key into a document,
+// applying the same deduplication and validation as a regular upload. It is implemented by
+// the document upload usecase and used to finalize resumable uploads
+type StagedDocumentUploader interface {
+	CompleteStagedUpload(ctx context.Context, stagingKey, hash, filename string, size int64, ownerID int64) (document *models.Document, created bool, err error)
 }
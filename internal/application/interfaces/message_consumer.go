@@ -1,15 +1,81 @@
 package interfaces
 
-import "context"
+import (
+	"context"
+	"errors"
 
-// MessageHandler defines the function signature for message handlers
-type MessageHandler func(ctx context.Context, message []byte) error
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+)
+
+// MessageHandler defines the function signature for message handlers. Messages published without
+// CloudEvents attributes arrive as an envelope holding only their ID and payload
+type MessageHandler func(ctx context.Context, event events.Envelope) error
 
 // MessageConsumer defines the interface for consuming messages from message queues (RabbitMQ, Kafka, SQS, etc.)
 type MessageConsumer interface {
 	// SubscribeToQueue starts consuming messages from a specific queue with the provided handler
 	SubscribeToQueue(ctx context.Context, queueName string, handler MessageHandler) error
 
+	// Shutdown stops consuming and waits for the messages being handled until ctx expires
+	Shutdown(ctx context.Context) error
+
 	// Close closes the connection to the message broker
 	Close() error
 }
+
+// PermanentMessageError marks a handler error that no retry can fix, such as a malformed message.
+// Consumers send such messages straight to the dead-letter queue
+type PermanentMessageError struct {
+	Err error
+}
+
+// Error returns the message of the wrapped error
+func (e *PermanentMessageError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *PermanentMessageError) Unwrap() error {
+	return e.Err
+}
+
+// NewPermanentMessageError wraps err so consumers do not retry the message
+func NewPermanentMessageError(err error) error {
+	return &PermanentMessageError{Err: err}
+}
+
+// IsPermanentMessageError reports whether a handler error must skip retries
+func IsPermanentMessageError(err error) bool {
+	var permanent *PermanentMessageError
+	return errors.As(err, &permanent)
+}
+
+// DeferredMessageError marks a handler error on a message that cannot be handled yet through no
+// fault of its own, such as one being handled by another consumer. Consumers deliver such messages
+// again after a delay without counting the attempt, so they are never dead-lettered for it
+type DeferredMessageError struct {
+	Err error
+}
+
+// Error returns the message of the wrapped error
+func (e *DeferredMessageError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *DeferredMessageError) Unwrap() error {
+	return e.Err
+}
+
+// NewDeferredMessageError wraps err so consumers retry the message later without counting the
+// attempt
+func NewDeferredMessageError(err error) error {
+	return &DeferredMessageError{Err: err}
+}
+
+// IsDeferredMessageError reports whether a handler error must be retried without counting the
+// attempt
+func IsDeferredMessageError(err error) bool {
+	var deferred *DeferredMessageError
+	return errors.As(err, &deferred)
+}
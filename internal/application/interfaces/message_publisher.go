@@ -1,12 +1,53 @@
 package interfaces
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+)
 
 // MessagePublisher defines the interface for publishing messages to message queues
 type MessagePublisher interface {
-	// Publish sends a message to the specified queue/exchange
-	Publish(ctx context.Context, queue string, message []byte) error
+	// Publish sends an event to the destination the broker topology routes its type to, carrying
+	// its CloudEvents attributes as message headers. An event the broker did not accept is reported
+	// as a *PublishError
+	Publish(ctx context.Context, event events.Envelope) error
 
 	// Close closes the connection to the message broker
 	Close() error
 }
+
+var (
+	// ErrPublishNacked is returned when the broker refuses to take responsibility for a message
+	ErrPublishNacked = errors.New("message was rejected by the broker")
+
+	// ErrPublishUnroutable is returned when the broker has no queue to route a message to
+	ErrPublishUnroutable = errors.New("message could not be routed to any queue")
+
+	// ErrPublishNoRoute is returned when the topology routes the event type nowhere
+	ErrPublishNoRoute = errors.New("no route is configured for the event type")
+
+	// ErrPublishUnconfirmed is returned when the broker does not confirm a message in time. The
+	// message may still be delivered
+	ErrPublishUnconfirmed = errors.New("message was not confirmed by the broker in time")
+)
+
+// PublishError reports a message the broker did not accept. Err is one of the ErrPublish errors or
+// the transport error that prevented the publish. Destination names where the event was sent, as
+// "exchange/routing-key", or the event type when it has no route
+type PublishError struct {
+	Destination string
+	Err         error
+}
+
+// Error describes the failed publish
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("failed to publish message to %s: %v", e.Destination, e.Err)
+}
+
+// Unwrap returns the cause of the failure
+func (e *PublishError) Unwrap() error {
+	return e.Err
+}
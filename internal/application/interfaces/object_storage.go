@@ -2,8 +2,23 @@ package interfaces
 
 import (
 	"context"
+	"errors"
 	"io"
+	"net/url"
 	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+var (
+	// ErrObjectNotFound is returned by storages when the requested object does not exist
+	ErrObjectNotFound = errors.New("object not found")
+
+	// ErrInvalidSignature is returned when a signed URL is malformed, tampered with or expired
+	ErrInvalidSignature = errors.New("invalid or expired signature")
+
+	// ErrUploadPolicyViolation is returned when a signed upload does not match its policy
+	ErrUploadPolicyViolation = errors.New("upload does not satisfy the signed policy")
 )
 
 // ObjectStorage defines the interface for object storage operations (S3, MinIO, etc.)
@@ -22,4 +37,90 @@ type ObjectStorage interface {
 
 	// GeneratePresignedURL generates a temporary pre-signed URL for secure access to an object
 	GeneratePresignedURL(ctx context.Context, objectKey string, expiration time.Duration) (string, error)
+
+	// GeneratePresignedPut generates a pre-signed PUT request that lets a client store an object directly
+	GeneratePresignedPut(ctx context.Context, objectKey string, policy UploadPolicy) (*PresignedUpload, error)
+
+	// GeneratePresignedPost generates a pre-signed POST form policy that lets a client store an object directly
+	GeneratePresignedPost(ctx context.Context, objectKey string, policy UploadPolicy) (*PresignedUpload, error)
+
+	// Stat returns the metadata of a stored object, or ErrObjectNotFound
+	Stat(ctx context.Context, objectKey string) (*ObjectInfo, error)
+
+	// Open returns a reader over the content of an object
+	Open(ctx context.Context, objectKey string) (io.ReadCloser, error)
+}
+
+// UploadPolicy restricts what a client may store through a pre-signed upload
+type UploadPolicy struct {
+	ContentType    string        // Content type the client must send
+	Size           int64         // Exact size in bytes the object must have
+	ChecksumSHA256 string        // Optional base64 SHA256 the storage verifies on write
+	Expiration     time.Duration // Validity of the pre-signed request
+}
+
+// PresignedUpload describes a pre-signed request a client uses to upload an object
+type PresignedUpload struct {
+	Method     string            // HTTP method (PUT or POST)
+	URL        string            // Target URL
+	Headers    map[string]string // Headers the client must send with a PUT request
+	FormFields map[string]string // Form fields the client must send before the file in a POST request
+	ExpiresAt  time.Time         // Time after which the request is rejected
+}
+
+// ObjectInfo holds the metadata of a stored object
+type ObjectInfo struct {
+	Size           int64  // Size in bytes
+	ContentType    string // Stored content type
+	ChecksumSHA256 string // Base64 full-object SHA256 verified by the storage, if available
+}
+
+// StagingObjectStorage is implemented by storages able to stream an upload of unknown digest to a
+// temporary staging key and move it to its content-addressed key once the digest is known.
+// Uploads go through this path when available so the file is read only once and never buffered whole
+type StagingObjectStorage interface {
+	ObjectStorage
+
+	// NewStagingKey returns a fresh staging key for an object written by other means, such as a
+	// pre-signed upload
+	NewStagingKey() string
+
+	// PutStaged streams body to a new staging key and returns that key with the number of bytes written
+	PutStaged(ctx context.Context, body io.Reader, contentType string) (stagingKey string, size int64, err error)
+
+	// PromoteStaged moves a staged object to its final key. If the final key already exists
+	// the staged copy is simply discarded, as both objects hold the same content
+	PromoteStaged(ctx context.Context, stagingKey, objectKey, contentType string) error
+
+	// DiscardStaged removes a staged object that will not be promoted
+	DiscardStaged(ctx context.Context, stagingKey string) error
+}
+
+// MultipartObjectStorage is implemented by storages able to assemble a staged object from parts
+// written by separate requests, as required by resumable uploads
+type MultipartObjectStorage interface {
+	StagingObjectStorage
+
+	// CreateStagedMultipart starts a multipart upload to a new staging key
+	CreateStagedMultipart(ctx context.Context, contentType string) (stagingKey, uploadID string, err error)
+
+	// UploadStagedPart writes one part of a multipart upload and returns its ETag
+	UploadStagedPart(ctx context.Context, stagingKey, uploadID string, partNumber int32, body io.Reader, size int64) (string, error)
+
+	// CompleteStagedMultipart assembles the uploaded parts into the staged object
+	CompleteStagedMultipart(ctx context.Context, stagingKey, uploadID string, parts []models.UploadPart) error
+
+	// AbortStagedMultipart releases the parts of a multipart upload that will not be completed
+	AbortStagedMultipart(ctx context.Context, stagingKey, uploadID string) error
+}
+
+// SignedObjectServer is implemented by storages that serve their own signed URLs instead of
+// delegating them to an external service, such as the local filesystem storage
+type SignedObjectServer interface {
+	// OpenSigned verifies a signed download request and opens the object
+	OpenSigned(ctx context.Context, objectKey string, params url.Values) (io.ReadCloser, *ObjectInfo, error)
+
+	// PutSigned verifies a signed upload request and stores body. Content that does not match the
+	// signed policy is rejected with ErrUploadPolicyViolation and never becomes visible
+	PutSigned(ctx context.Context, method, objectKey string, params url.Values, contentType string, body io.Reader) error
 }
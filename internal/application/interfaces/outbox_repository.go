@@ -0,0 +1,33 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// OutboxRepository defines the persistence operations of the transactional outbox. Messages tied to
+// a state change are written by the repository making that change, in the same transaction
+type OutboxRepository interface {
+	// Enqueue stores messages that do not accompany any state change
+	Enqueue(ctx context.Context, messages ...*models.OutboxMessage) error
+
+	// ListPending returns up to limit messages not published yet, oldest first. Messages backing
+	// off after a failed attempt are skipped until their next attempt is due
+	ListPending(ctx context.Context, limit int) ([]*models.OutboxMessage, error)
+
+	// MarkSent records that a message was published; it is no longer pending
+	MarkSent(ctx context.Context, id string) error
+
+	// MarkFailed records a failed publish attempt; the message stays pending but is not listed
+	// again before retryAt
+	MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error
+
+	// MarkParked records the last failed attempt of a message the relay gives up on; it is no
+	// longer pending and stays stored for inspection
+	MarkParked(ctx context.Context, id string, cause error) error
+
+	// EnsureTableExists ensures the outbox table exists (implementation-specific)
+	EnsureTableExists(ctx context.Context) error
+}
@@ -2,14 +2,31 @@ package interfaces
 
 import (
 	"context"
+
 	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 )
 
+// ClaimStatus is the outcome of claiming a message
+type ClaimStatus int
+
+const (
+	// ClaimAcquired means the caller owns the message and must complete or release the claim
+	ClaimAcquired ClaimStatus = iota
+	// ClaimInProgress means another consumer holds a live lease on the message
+	ClaimInProgress
+	// ClaimCompleted means the message was already processed
+	ClaimCompleted
+)
+
 // ProcessedMessageRepository defines the interface for managing processed messages (idempotency)
 type ProcessedMessageRepository interface {
-	// CheckIfProcessed checks if a message with the given messageID has been processed
-	CheckIfProcessed(ctx context.Context, messageID string) (bool, error)
+	// Claim atomically stores an in-progress claim on a message. The claim is acquired when the
+	// message is unknown or the lease of a previous claim has run out
+	Claim(ctx context.Context, claim *models.ProcessedMessage) (ClaimStatus, error)
+
+	// Complete marks a claimed message as processed, provided the claim is still held
+	Complete(ctx context.Context, claim *models.ProcessedMessage) error
 
-	// MarkAsProcessed marks a message as processed
-	MarkAsProcessed(ctx context.Context, message *models.ProcessedMessage) error
+	// Release removes an in-progress claim so the message can be processed again
+	Release(ctx context.Context, claim *models.ProcessedMessage) error
 }
@@ -2,32 +2,103 @@ package interfaces
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 )
 
-// DocumentRepository defines the interface for document persistence operations
+var (
+	// ErrVersionConflict is returned by document writes when the document is not at the expected
+	// version, or changed between the read and the write
+	ErrVersionConflict = errors.New("document was modified concurrently")
+
+	// ErrDocumentNotFound is returned by document writes when the document does not exist
+	ErrDocumentNotFound = errors.New("document not found")
+
+	// ErrDuplicateDocument is returned by Create when the owner already has a document with the
+	// same content hash
+	ErrDuplicateDocument = errors.New("owner already has a document with the same content")
+
+	// ErrObjectReleasing is returned by Create when the storage object of the document is being
+	// deleted, its last reference having been released
+	ErrObjectReleasing = errors.New("storage object is being deleted")
+
+	// ErrQuotaExceeded is returned by Create and Restore when the document would take its owner
+	// past their quota
+	ErrQuotaExceeded = errors.New("owner quota exceeded")
+)
+
+// OutboxFunc builds the outbox messages recording a write to documents. Writes call it with the
+// documents they change, as written, and store the messages in the same transaction: the events
+// exist if and only if the change does. A nil OutboxFunc stores no message
+type OutboxFunc func(documents []*models.Document) ([]*models.OutboxMessage, error)
+
+// DocumentRepository defines the interface for document persistence operations. Every write
+// increments the Version of the document it changes and is conditioned on the version it read, so
+// concurrent writes never overwrite each other silently. Writes taking an expectedVersion fail
+// with ErrVersionConflict unless the document is at that version; 0 accepts any version. Creates,
+// deletes, trashes and restores maintain the usage of the owner of the document in the same
+// transaction. Documents are moved to the trash of their owner before being deleted for good:
+// trashed documents are left out of lists unless asked for and no longer count in the usage of
+// their owner, but are still found by ID and hash, so they keep their content hash taken
 type DocumentRepository interface {
-	// Create stores a new document in the repository
-	Create(ctx context.Context, doc *models.Document) error
+	// Create stores a new document in the repository. An owner has at most one document per
+	// content hash: Create fails with ErrDuplicateDocument when the owner already has one, however
+	// close the two uploads were. It fails with ErrQuotaExceeded when quota does not allow the
+	// owner to store the document, checked against their usage in the same transaction
+	Create(ctx context.Context, doc *models.Document, quota models.Quota, outbox OutboxFunc) error
 
-	// FindByHashAndOwnerID retrieves a document by its hash and owner ID (for deduplication)
+	// FindByHashAndOwnerID retrieves a document by its hash and owner ID (for deduplication). A
+	// document rejected by Create as a duplicate is always found
 	FindByHashAndOwnerID(ctx context.Context, hashSHA256 string, ownerID int64) (*models.Document, error)
 
 	// GetByID retrieves a document by its unique identifier
 	GetByID(ctx context.Context, id string) (*models.Document, error)
 
-	// List retrieves a paginated list of documents for a specific owner
+	// List retrieves a paginated list of the documents of an owner outside the trash, most recent
+	// first like the default order of ListPage
 	List(ctx context.Context, ownerID int64, limit, offset int) ([]*models.Document, int64, error)
 
-	// DeleteByID removes a document by its ID and returns the deleted document
-	DeleteByID(ctx context.Context, id string) (*models.Document, error)
+	// ListPage retrieves up to limit documents of an owner matching filter in the order of sort,
+	// starting after position, the one returned with the previous page of the same sort; an empty
+	// position starts from the first document. The returned position is empty once there are no
+	// more documents, although a page following a non-empty one may turn out empty. Positions are
+	// specific to the implementation and must be handed back unchanged
+	ListPage(ctx context.Context, ownerID int64, sort models.DocumentSort, filter models.DocumentFilter, limit int, position string) ([]*models.Document, string, error)
+
+	// CountByOwner returns the number of documents of an owner matching filter
+	CountByOwner(ctx context.Context, ownerID int64, filter models.DocumentFilter) (int64, error)
+
+	// GetUsage returns the number and total size of the documents of an owner outside the trash
+	GetUsage(ctx context.Context, ownerID int64) (*models.OwnerUsage, error)
+
+	// DeleteByID removes a document by its ID, in the trash or not, and returns the deleted
+	// document, or nil if it does not exist
+	DeleteByID(ctx context.Context, id string, expectedVersion int64, outbox OutboxFunc) (*models.Document, error)
+
+	// Trash moves a document to the trash until purgeAt and returns it as trashed, or nil if it
+	// does not exist or is in the trash already. Trashed documents no longer count in the usage
+	// of their owner
+	Trash(ctx context.Context, id string, expectedVersion int64, purgeAt time.Time, outbox OutboxFunc) (*models.Document, error)
+
+	// TrashAllByOwnerID moves every document of an owner outside the trash to the trash until
+	// purgeAt and returns how many were moved
+	TrashAllByOwnerID(ctx context.Context, ownerID int64, purgeAt time.Time, outbox OutboxFunc) (int, error)
+
+	// Restore takes a document out of the trash and returns it as restored, or nil if it does not
+	// exist or is not in the trash. The document counts in the usage of its owner again, so Restore
+	// fails with ErrQuotaExceeded when quota does not allow it, as Create does
+	Restore(ctx context.Context, id string, expectedVersion int64, quota models.Quota, outbox OutboxFunc) (*models.Document, error)
 
-	// DeleteAllByOwnerID removes all documents owned by a specific user
-	DeleteAllByOwnerID(ctx context.Context, ownerID int64) (int, error)
+	// ListExpired retrieves up to limit trashed documents, of any owner, due to be purged before
+	// the given time, those due first coming first, following position like ListPage. The position
+	// of the next documents is empty once all of them were listed
+	ListExpired(ctx context.Context, before time.Time, limit int, position string) ([]*models.Document, string, error)
 
-	// UpdateAuthenticationStatus updates the authentication status of a document
-	UpdateAuthenticationStatus(ctx context.Context, documentID string, status models.AuthenticationStatus) error
+	// UpdateAuthenticationStatus updates the authentication status of a document. It returns
+	// ErrDocumentNotFound when the document does not exist
+	UpdateAuthenticationStatus(ctx context.Context, documentID string, expectedVersion int64, status models.AuthenticationStatus, outbox OutboxFunc) error
 
 	// EnsureTableExists ensures the documents table exists (implementation-specific)
 	// Called automatically on initialization
@@ -0,0 +1,30 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// UploadSessionRepository defines the persistence operations of resumable upload sessions
+type UploadSessionRepository interface {
+	// Create stores a new upload session and assigns its ID
+	Create(ctx context.Context, session *models.UploadSession) error
+
+	// GetByID retrieves an upload session by its ID. Returns nil if it does not exist
+	GetByID(ctx context.Context, id string) (*models.UploadSession, error)
+
+	// AcquireLease reserves the session for a single writer until leaseUntil, provided that it is
+	// still at offset and no other lease is active. Returns false when the condition does not hold
+	AcquireLease(ctx context.Context, id string, offset int64, leaseUntil time.Time) (bool, error)
+
+	// Save stores the progress of a session and releases the lease held in session.LeaseUntil
+	Save(ctx context.Context, session *models.UploadSession) error
+
+	// Delete removes an upload session
+	Delete(ctx context.Context, id string) error
+
+	// EnsureTableExists ensures the upload sessions table exists (implementation-specific)
+	EnsureTableExists(ctx context.Context) error
+}
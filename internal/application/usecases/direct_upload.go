@@ -0,0 +1,214 @@
+package usecases
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/application/util"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+const (
+	// MaxDirectUploadSize is the largest object a single pre-signed request can store
+	MaxDirectUploadSize = 5 << 30
+
+	// directUploadURLExpiration bounds how long a pre-signed upload request stays valid
+	directUploadURLExpiration = 15 * time.Minute
+
+	// DirectUploadMethodPut selects a pre-signed PUT request
+	DirectUploadMethodPut = "PUT"
+
+	// DirectUploadMethodPost selects a pre-signed POST form policy
+	DirectUploadMethodPost = "POST"
+)
+
+// DirectUploadService defines the interface for uploads sent by clients straight to storage
+type DirectUploadService interface {
+	Initiate(ctx context.Context, ownerID int64, filename string, size int64, checksumSHA256, method string) (*models.UploadSession, *interfaces.PresignedUpload, error)
+	Complete(ctx context.Context, id string, ownerID int64) (document *models.Document, created bool, err error)
+}
+
+type directUploadService struct {
+	sessions     interfaces.UploadSessionRepository
+	storage      interfaces.StagingObjectStorage
+	uploader     interfaces.StagedDocumentUploader
+	hasher       util.FileHasher
+	mimeDetector util.MimeTypeDetector
+	ttl          time.Duration
+}
+
+// NewDirectUploadService creates a new direct-to-storage upload service
+// Initiated uploads that are not completed within ttl expire
+func NewDirectUploadService(
+	sessions interfaces.UploadSessionRepository,
+	storage interfaces.StagingObjectStorage,
+	uploader interfaces.StagedDocumentUploader,
+	hasher util.FileHasher,
+	mimeDetector util.MimeTypeDetector,
+	ttl time.Duration,
+) DirectUploadService {
+	return &directUploadService{
+		sessions:     sessions,
+		storage:      storage,
+		uploader:     uploader,
+		hasher:       hasher,
+		mimeDetector: mimeDetector,
+		ttl:          ttl,
+	}
+}
+
+// Initiate reserves a staging key and returns a pre-signed request limited to the declared size
+// and content type. checksumSHA256 is optional; when present it is enforced on completion
+func (service *directUploadService) Initiate(ctx context.Context, ownerID int64, filename string, size int64, checksumSHA256, method string) (*models.UploadSession, *interfaces.PresignedUpload, error) {
+	if strings.TrimSpace(filename) == "" {
+		return nil, nil, errors.NewValidationError("filename is required")
+	}
+	if size <= 0 {
+		return nil, nil, errors.NewValidationError("upload size must be greater than zero")
+	}
+	if size > MaxDirectUploadSize {
+		return nil, nil, errors.NewValidationError(fmt.Sprintf("upload size cannot exceed %d bytes", int64(MaxDirectUploadSize)))
+	}
+
+	checksumSHA256 = strings.ToLower(strings.TrimSpace(checksumSHA256))
+	var checksumBase64 string
+	if checksumSHA256 != "" {
+		digest, err := hex.DecodeString(checksumSHA256)
+		if err != nil || len(digest) != 32 {
+			return nil, nil, errors.NewValidationError("checksum must be a hex-encoded SHA256 digest")
+		}
+		checksumBase64 = base64.StdEncoding.EncodeToString(digest)
+	}
+
+	contentType := service.mimeDetector.DetectFromFilename(filename)
+	stagingKey := service.storage.NewStagingKey()
+	policy := interfaces.UploadPolicy{
+		ContentType:    contentType,
+		Size:           size,
+		ChecksumSHA256: checksumBase64,
+		Expiration:     directUploadURLExpiration,
+	}
+
+	var (
+		presigned *interfaces.PresignedUpload
+		err       error
+	)
+	switch strings.ToUpper(method) {
+	case "", DirectUploadMethodPut:
+		presigned, err = service.storage.GeneratePresignedPut(ctx, stagingKey, policy)
+	case DirectUploadMethodPost:
+		presigned, err = service.storage.GeneratePresignedPost(ctx, stagingKey, policy)
+	default:
+		return nil, nil, errors.NewValidationError("method must be PUT or POST")
+	}
+	if err != nil {
+		return nil, nil, errors.NewStorageUploadError(err)
+	}
+
+	session := models.NewUploadSession(ownerID, filename, contentType, size, service.ttl)
+	session.Kind = models.UploadKindDirect
+	session.StagingKey = stagingKey
+	session.ExpectedSHA256 = checksumSHA256
+
+	if err := service.sessions.Create(ctx, session); err != nil {
+		return nil, nil, errors.NewPersistenceError(err)
+	}
+
+	return session, presigned, nil
+}
+
+// Complete verifies the object written by the client and creates the document through the regular
+// deduplication and validation path; created is false when the owner already had the content.
+// A rejected object is discarded so the client can upload it again, and the session is kept when
+// the completion can be retried
+func (service *directUploadService) Complete(ctx context.Context, id string, ownerID int64) (*models.Document, bool, error) {
+	session, err := loadUploadSession(ctx, service.sessions, id, ownerID, true)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := acquireUploadLease(ctx, service.sessions, session); err != nil {
+		return nil, false, err
+	}
+
+	hash, size, err := service.verify(ctx, session)
+	if err != nil {
+		// Release the lease so the client can retry once the object is (re)uploaded
+		_ = service.sessions.Save(ctx, session)
+		return nil, false, err
+	}
+
+	document, created, err := service.uploader.CompleteStagedUpload(ctx, session.StagingKey, hash, session.Filename, size, ownerID)
+	if isRetryableUpload(err) {
+		// The staged object is kept, so release the lease to let the client retry the completion
+		_ = service.sessions.Save(ctx, session)
+		return nil, false, err
+	}
+
+	// The staged object is consumed, so the session cannot be completed again
+	if derr := service.sessions.Delete(ctx, session.ID); derr != nil {
+		log.Printf("failed to delete upload session %s: %v", session.ID, derr)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return document, created, nil
+}
+
+// verify checks the staged object against the session and returns its SHA256 and size. The checksum
+// verified by the storage on write is used when available; otherwise the object is read back and hashed
+func (service *directUploadService) verify(ctx context.Context, session *models.UploadSession) (string, int64, error) {
+	info, err := service.storage.Stat(ctx, session.StagingKey)
+	if stderrors.Is(err, interfaces.ErrObjectNotFound) {
+		return "", 0, errors.NewUploadOffsetMismatchError("the file has not been uploaded yet")
+	}
+	if err != nil {
+		return "", 0, errors.NewStorageUploadError(err)
+	}
+
+	if info.Size != session.Length {
+		_ = service.storage.DiscardStaged(ctx, session.StagingKey)
+		return "", 0, errors.NewValidationError(fmt.Sprintf("uploaded file has %d bytes, expected %d", info.Size, session.Length))
+	}
+
+	hash, err := service.objectHash(ctx, session.StagingKey, info)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if session.ExpectedSHA256 != "" && hash != session.ExpectedSHA256 {
+		_ = service.storage.DiscardStaged(ctx, session.StagingKey)
+		return "", 0, errors.NewValidationError("uploaded file does not match the declared checksum")
+	}
+
+	return hash, info.Size, nil
+}
+
+// objectHash returns the hex SHA256 of a staged object
+func (service *directUploadService) objectHash(ctx context.Context, objectKey string, info *interfaces.ObjectInfo) (string, error) {
+	if info.ChecksumSHA256 != "" {
+		if digest, err := base64.StdEncoding.DecodeString(info.ChecksumSHA256); err == nil && len(digest) == 32 {
+			return hex.EncodeToString(digest), nil
+		}
+	}
+
+	body, err := service.storage.Open(ctx, objectKey)
+	if err != nil {
+		return "", errors.NewStorageUploadError(err)
+	}
+	defer func() { _ = body.Close() }()
+
+	hash, err := service.hasher.CalculateHash(body)
+	if err != nil {
+		return "", errors.NewHashCalculateError(err)
+	}
+	return hash, nil
+}
@@ -2,7 +2,8 @@ package usecases
 
 import (
 	"context"
-	"fmt"
+	stderrors "errors"
+	"time"
 
 	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/errors"
@@ -10,25 +11,37 @@ import (
 
 // DocumentDeleteService defines the interface for deleting individual documents
 type DocumentDeleteService interface {
-	Delete(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string, expectedVersion int64) error
 }
 
 type documentDeleteService struct {
-	repository    interfaces.DocumentRepository
-	objectStorage interfaces.ObjectStorage
+	repository interfaces.DocumentRepository
+	events     DocumentEvents
+	retention  time.Duration
 }
 
 // NewDocumentDeleteService creates a new document deletion service
-func NewDocumentDeleteService(repository interfaces.DocumentRepository, objectStorage interfaces.ObjectStorage) DocumentDeleteService {
+// Deleted documents stay in the trash for retention before being purged
+func NewDocumentDeleteService(
+	repository interfaces.DocumentRepository,
+	events DocumentEvents,
+	retention time.Duration,
+) DocumentDeleteService {
 	return &documentDeleteService{
-		repository:    repository,
-		objectStorage: objectStorage,
+		repository: repository,
+		events:     events,
+		retention:  retention,
 	}
 }
 
-// Delete removes a document and its associated file from storage
-func (s *documentDeleteService) Delete(ctx context.Context, id string) error {
-	document, err := s.repository.DeleteByID(ctx, id)
+// Delete moves a document to the trash, publishing a document.deleted event. The document can be
+// restored until the purge deletes it, along with its file once no other document references it.
+// Unless expectedVersion is 0, the document is only deleted at that version
+func (s *documentDeleteService) Delete(ctx context.Context, id string, expectedVersion int64) error {
+	document, err := s.repository.Trash(ctx, id, expectedVersion, time.Now().Add(s.retention), s.events.Deleted)
+	if stderrors.Is(err, interfaces.ErrVersionConflict) {
+		return errors.NewConflictError("document was modified, read it again before deleting it")
+	}
 	if err != nil {
 		return errors.NewPersistenceError(err)
 	}
@@ -37,9 +50,5 @@ func (s *documentDeleteService) Delete(ctx context.Context, id string) error {
 		return errors.NewNotFoundError("document not found")
 	}
 
-	if err := s.objectStorage.Delete(ctx, document.ObjectKey); err != nil {
-		return fmt.Errorf("failed to delete object from storage (metadata was already deleted): %w", err)
-	}
-
 	return nil
 }
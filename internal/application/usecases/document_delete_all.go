@@ -2,7 +2,7 @@ package usecases
 
 import (
 	"context"
-	"log"
+	"time"
 
 	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/errors"
@@ -14,39 +14,31 @@ type DocumentDeleteAllService interface {
 }
 
 type documentDeleteAllService struct {
-	repository    interfaces.DocumentRepository
-	objectStorage interfaces.ObjectStorage
+	repository interfaces.DocumentRepository
+	events     DocumentEvents
+	retention  time.Duration
 }
 
 // NewDocumentDeleteAllService creates a new bulk document deletion service
-func NewDocumentDeleteAllService(repository interfaces.DocumentRepository, objectStorage interfaces.ObjectStorage) DocumentDeleteAllService {
+// Deleted documents stay in the trash for retention before being purged
+func NewDocumentDeleteAllService(
+	repository interfaces.DocumentRepository,
+	events DocumentEvents,
+	retention time.Duration,
+) DocumentDeleteAllService {
 	return &documentDeleteAllService{
-		repository:    repository,
-		objectStorage: objectStorage,
+		repository: repository,
+		events:     events,
+		retention:  retention,
 	}
 }
 
-// DeleteAll removes all documents owned by a specific user and their associated files from storage
+// DeleteAll moves all documents owned by a specific user to the trash, publishing
+// document.bulk_deleted events. The purge deletes them and their files once retention is over
 func (s *documentDeleteAllService) DeleteAll(ctx context.Context, ownerID int64) (int, error) {
-	documents, _, err := s.repository.List(ctx, ownerID, 1000, 0)
+	deletedCount, err := s.repository.TrashAllByOwnerID(ctx, ownerID, time.Now().Add(s.retention), s.events.BulkDeleted)
 	if err != nil {
 		return 0, errors.NewPersistenceError(err)
 	}
-
-	if len(documents) == 0 {
-		return 0, nil
-	}
-
-	deletedCount, err := s.repository.DeleteAllByOwnerID(ctx, ownerID)
-	if err != nil {
-		return 0, errors.NewPersistenceError(err)
-	}
-
-	for _, doc := range documents {
-		if err := s.objectStorage.Delete(ctx, doc.ObjectKey); err != nil {
-			log.Printf("failed to delete object %s from S3: %v (metadata was already deleted)", doc.ObjectKey, err)
-		}
-	}
-
 	return deletedCount, nil
 }
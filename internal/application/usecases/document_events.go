@@ -0,0 +1,122 @@
+package usecases
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// DocumentEvents builds the outbox messages of the document lifecycle events. Its methods are
+// interfaces.OutboxFunc values handed to the repository writes, so each event is stored in the
+// same transaction as the change it reports. The broker topology decides where each event type
+// is published
+type DocumentEvents struct{}
+
+// NewDocumentEvents creates the builder of lifecycle events
+func NewDocumentEvents() DocumentEvents {
+	return DocumentEvents{}
+}
+
+// Uploaded builds a document.uploaded event per created document
+func (e DocumentEvents) Uploaded(documents []*models.Document) ([]*models.OutboxMessage, error) {
+	messages := make([]*models.OutboxMessage, 0, len(documents))
+	for _, doc := range documents {
+		message, err := e.message(events.TypeDocumentUploaded, events.DocumentUploadedVersion, events.DocumentUploadedEvent{
+			DocumentID: doc.ID,
+			IDCitizen:  doc.OwnerID,
+			Filename:   doc.Filename,
+			MimeType:   doc.MimeType,
+			SizeBytes:  doc.SizeBytes,
+			HashSHA256: doc.HashSHA256,
+			UploadedAt: doc.CreatedAt.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// Deleted builds a document.deleted event per document deleted or moved to the trash
+func (e DocumentEvents) Deleted(documents []*models.Document) ([]*models.OutboxMessage, error) {
+	messages := make([]*models.OutboxMessage, 0, len(documents))
+	for _, doc := range documents {
+		message, err := e.message(events.TypeDocumentDeleted, events.DocumentDeletedVersion, events.DocumentDeletedEvent{
+			DocumentID: doc.ID,
+			IDCitizen:  doc.OwnerID,
+			Filename:   doc.Filename,
+		})
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// Restored builds a document.restored event per document taken out of the trash
+func (e DocumentEvents) Restored(documents []*models.Document) ([]*models.OutboxMessage, error) {
+	messages := make([]*models.OutboxMessage, 0, len(documents))
+	for _, doc := range documents {
+		message, err := e.message(events.TypeDocumentRestored, events.DocumentRestoredVersion, events.DocumentRestoredEvent{
+			DocumentID: doc.ID,
+			IDCitizen:  doc.OwnerID,
+			Filename:   doc.Filename,
+		})
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// BulkDeleted builds a single document.bulk_deleted event listing the deleted documents of an owner
+func (e DocumentEvents) BulkDeleted(documents []*models.Document) ([]*models.OutboxMessage, error) {
+	if len(documents) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(documents))
+	for _, doc := range documents {
+		ids = append(ids, doc.ID)
+	}
+	message, err := e.message(events.TypeDocumentsBulkDeleted, events.DocumentsBulkDeletedVersion, events.DocumentsBulkDeletedEvent{
+		IDCitizen:   documents[0].OwnerID,
+		DocumentIDs: ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []*models.OutboxMessage{message}, nil
+}
+
+// AuthenticationStatusChanged builds a document.authentication_status_changed event per updated document
+func (e DocumentEvents) AuthenticationStatusChanged(documents []*models.Document) ([]*models.OutboxMessage, error) {
+	messages := make([]*models.OutboxMessage, 0, len(documents))
+	for _, doc := range documents {
+		message, err := e.message(events.TypeDocumentAuthenticationStatusChanged, events.DocumentAuthenticationStatusChangedVersion, events.DocumentAuthenticationStatusChangedEvent{
+			DocumentID: doc.ID,
+			IDCitizen:  doc.OwnerID,
+			Status:     string(doc.AuthenticationStatus),
+		})
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// message wraps a payload in an envelope and an outbox message
+func (e DocumentEvents) message(eventType string, version int, payload any) (*models.OutboxMessage, error) {
+	envelope, err := events.NewEnvelope(eventType, version, uuid.New().String(), payload)
+	if err != nil {
+		return nil, err
+	}
+	return models.NewOutboxMessage(envelope)
+}
@@ -28,14 +28,15 @@ func NewDocumentGetService(repository interfaces.DocumentRepository, storage int
 	}
 }
 
-// GetByID retrieves a document by its unique identifier and populates a pre-signed URL
+// GetByID retrieves a document by its unique identifier and populates a pre-signed URL. Documents
+// in the trash are not found
 func (s *documentGetService) GetByID(ctx context.Context, id string) (*models.Document, error) {
 	document, err := s.repository.GetByID(ctx, id)
 	if err != nil {
 		return nil, errors.NewPersistenceError(err)
 	}
 
-	if document == nil {
+	if document == nil || document.IsTrashed() {
 		return nil, errors.NewNotFoundError("document not found")
 	}
 
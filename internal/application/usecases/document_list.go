@@ -2,7 +2,11 @@ package usecases
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"math"
+	"strings"
 
 	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
 	"github.com/kristianrpo/document-management-microservice/internal/application/util"
@@ -10,23 +14,57 @@ import (
 	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 )
 
+// DocumentListQuery selects a page of documents listed with a cursor
+type DocumentListQuery struct {
+	// Sort orders the documents; unset parts take their defaults
+	Sort models.DocumentSort
+
+	// Filter restricts the documents listed and counted
+	Filter models.DocumentFilter
+
+	Limit int
+
+	// Cursor is the NextCursor of the previous page, or empty for the first page
+	Cursor string
+
+	// IncludeTotal requests the number of documents matching the filter
+	IncludeTotal bool
+}
+
+// DocumentPage is a page of documents listed with a cursor
+type DocumentPage struct {
+	Documents []*models.Document
+	Limit     int
+
+	// NextCursor continues the listing after this page; it is empty on the last page
+	NextCursor string
+
+	// TotalItems is the number of the owner's documents matching the filter, only computed when
+	// requested
+	TotalItems *int64
+}
+
 // DocumentListService defines the interface for listing documents with pagination
 type DocumentListService interface {
 	List(ctx context.Context, ownerID int64, page, limit int) ([]*models.Document, util.PaginationParams, int, int64, error)
+	ListPage(ctx context.Context, ownerID int64, query DocumentListQuery) (*DocumentPage, error)
 }
 
 type documentListService struct {
 	repository interfaces.DocumentRepository
+	cursors    *util.CursorCodec
 }
 
-// NewDocumentListService creates a new document list service
-func NewDocumentListService(repository interfaces.DocumentRepository) DocumentListService {
+// NewDocumentListService creates a new document list service signing its cursors with cursors
+func NewDocumentListService(repository interfaces.DocumentRepository, cursors *util.CursorCodec) DocumentListService {
 	return &documentListService{
 		repository: repository,
+		cursors:    cursors,
 	}
 }
 
 // List retrieves a paginated list of documents for a specific owner
+// Each page walks the owner's documents from the first one, so ListPage is preferred for deep pages
 func (s *documentListService) List(ctx context.Context, ownerID int64, page, limit int) ([]*models.Document, util.PaginationParams, int, int64, error) {
 	pagination := util.NormalizePagination(page, limit)
 
@@ -42,3 +80,83 @@ func (s *documentListService) List(ctx context.Context, ownerID int64, page, lim
 
 	return documents, pagination, totalPages, totalCount, nil
 }
+
+// ListPage retrieves the page of an owner's documents matching the query filter and following
+// its cursor, or the first page when the cursor is empty; by default the most recent documents
+// come first. Every page costs the same whatever its depth; counting the matching documents reads
+// all of them, so the total is only computed when requested
+func (s *documentListService) ListPage(ctx context.Context, ownerID int64, query DocumentListQuery) (*DocumentPage, error) {
+	pagination := util.NormalizePagination(1, query.Limit)
+
+	sort := query.Sort.WithDefaults()
+	if !sort.IsValid() {
+		return nil, errors.NewValidationError("invalid sort: " + sort.String())
+	}
+	if err := query.Filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	position, err := s.decodeCursor(ownerID, sort, query.Filter, query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, next, err := s.repository.ListPage(ctx, ownerID, sort, query.Filter, pagination.Limit, position)
+	if err != nil {
+		return nil, errors.NewPersistenceError(err)
+	}
+
+	page := &DocumentPage{
+		Documents:  documents,
+		Limit:      pagination.Limit,
+		NextCursor: s.encodeCursor(ownerID, sort, query.Filter, next),
+	}
+
+	if query.IncludeTotal {
+		total, err := s.repository.CountByOwner(ctx, ownerID, query.Filter)
+		if err != nil {
+			return nil, errors.NewPersistenceError(err)
+		}
+		page.TotalItems = &total
+	}
+
+	return page, nil
+}
+
+// encodeCursor wraps a repository position with a digest of the sort and filter it belongs to,
+// since a position only makes sense in the listing it was taken from
+func (s *documentListService) encodeCursor(ownerID int64, sort models.DocumentSort, filter models.DocumentFilter, position string) string {
+	if position == "" {
+		return ""
+	}
+	return s.cursors.Encode(ownerID, listingDigest(sort, filter)+" "+position)
+}
+
+// decodeCursor returns the repository position of a cursor issued for the same sort and filter
+func (s *documentListService) decodeCursor(ownerID int64, sort models.DocumentSort, filter models.DocumentFilter, cursor string) (string, error) {
+	wrapped, err := s.cursors.Decode(ownerID, cursor)
+	if err != nil {
+		return "", errors.NewValidationError("invalid cursor")
+	}
+	if wrapped == "" {
+		return "", nil
+	}
+
+	digest, position, ok := strings.Cut(wrapped, " ")
+	if !ok || digest != listingDigest(sort, filter) {
+		return "", errors.NewValidationError("cursor was issued for another sort order or filter")
+	}
+	return position, nil
+}
+
+// listingDigest identifies a listing by its sort and filter, trash view included
+func listingDigest(sort models.DocumentSort, filter models.DocumentFilter) string {
+	filter.CreatedFrom = filter.CreatedFrom.UTC()
+	filter.CreatedTo = filter.CreatedTo.UTC()
+	encoded, _ := json.Marshal(struct {
+		Sort   string
+		Filter models.DocumentFilter
+	}{sort.String(), filter})
+	sum := sha256.Sum256(encoded)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
@@ -0,0 +1,146 @@
+package usecases
+
+import (
+	"context"
+	stderrors "errors"
+	"log"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+)
+
+// purgeBatchSize is the number of expired documents purged per pass
+const purgeBatchSize = 25
+
+// DocumentPurgeService defines the interface of the background deletion of expired trashed documents
+type DocumentPurgeService interface {
+	Run(ctx context.Context)
+	PurgeExpired(ctx context.Context) (int, error)
+}
+
+type documentPurgeService struct {
+	repository    interfaces.DocumentRepository
+	objectStorage interfaces.ObjectStorage
+	blobRefs      interfaces.BlobReferenceRepository
+	interval      time.Duration
+
+	// position is where the next pass lists expired documents from; passes do not run concurrently
+	position string
+}
+
+// NewDocumentPurgeService creates a purge deleting trashed documents past their purge time every interval
+func NewDocumentPurgeService(
+	repository interfaces.DocumentRepository,
+	objectStorage interfaces.ObjectStorage,
+	blobRefs interfaces.BlobReferenceRepository,
+	interval time.Duration,
+) DocumentPurgeService {
+	return &documentPurgeService{
+		repository:    repository,
+		objectStorage: objectStorage,
+		blobRefs:      blobRefs,
+		interval:      interval,
+	}
+}
+
+// Run purges expired documents until ctx is canceled. A pass that fills a whole batch is followed
+// right away by the next one, so a backlog drains without waiting for the interval
+func (s *documentPurgeService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeExpired(ctx)
+		if err != nil {
+			log.Printf("document purge error: %v", err)
+		}
+		if err == nil && purged == purgeBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Document purge stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeExpired deletes one batch of trashed documents past their purge time, and the files no
+// other document references anymore, and returns how many documents were deleted. Each document is
+// deleted at the version it was listed at, so one restored meanwhile is kept. Deleting a document
+// publishes no event; its document.deleted event was published when it was trashed.
+// Each pass goes on from the documents the previous one listed, so documents that fail to be
+// purged are only tried again once every other expired document was, and do not hold the rest
+// back. The files whose deletion failed keep a counter without references, and each pass retries
+// a batch of them
+func (s *documentPurgeService) PurgeExpired(ctx context.Context) (int, error) {
+	documents, next, err := s.repository.ListExpired(ctx, time.Now(), purgeBatchSize, s.position)
+	if err != nil {
+		return 0, err
+	}
+	s.position = next
+
+	purged := 0
+	for _, expired := range documents {
+		if ctx.Err() != nil {
+			return purged, ctx.Err()
+		}
+
+		document, err := s.repository.DeleteByID(ctx, expired.ID, expired.Version, nil)
+		if stderrors.Is(err, interfaces.ErrVersionConflict) {
+			// Restored or changed since it was listed
+			continue
+		}
+		if err != nil {
+			log.Printf("failed to purge document %s: %v", expired.ID, err)
+			continue
+		}
+		if document == nil {
+			// Purged concurrently
+			continue
+		}
+		purged++
+
+		s.releaseObject(ctx, document.ObjectKey)
+	}
+
+	// Objects left unreferenced by earlier passes, or by the deletes of other documents, whose
+	// release failed
+	unreferenced, err := s.blobRefs.ListUnreferenced(ctx, purgeBatchSize)
+	if err != nil {
+		return purged, err
+	}
+	for _, objectKey := range unreferenced {
+		if ctx.Err() != nil {
+			return purged, ctx.Err()
+		}
+		s.releaseObject(ctx, objectKey)
+	}
+	return purged, nil
+}
+
+// releaseObject deletes an object from storage once no document references it. An object whose
+// release or deletion fails keeps its counter, and is released again by a later pass
+func (s *documentPurgeService) releaseObject(ctx context.Context, objectKey string) {
+	released, err := s.blobRefs.ReleaseIfUnreferenced(ctx, objectKey)
+	if err != nil {
+		log.Printf("failed to release reference to object %s: %v (retried on a later pass)", objectKey, err)
+		return
+	}
+	// Another document still points to the same content-addressed object, its references are
+	// unknown, or another release holds it
+	if !released {
+		return
+	}
+
+	// No document can reference the object until the release is finished or expires
+	if err := s.objectStorage.Delete(ctx, objectKey); err != nil {
+		log.Printf("failed to delete object %s from storage: %v (retried once its release expires)", objectKey, err)
+		return
+	}
+	if err := s.blobRefs.FinishRelease(ctx, objectKey); err != nil {
+		log.Printf("failed to finish release of object %s: %v (its lease expires)", objectKey, err)
+	}
+}
@@ -2,7 +2,7 @@ package usecases
 
 import (
 	"context"
-	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"time"
 
@@ -16,14 +16,12 @@ import (
 
 // DocumentRequestAuthenticationService defines the interface for document authentication request operations
 type DocumentRequestAuthenticationService interface {
-	RequestAuthentication(ctx context.Context, documentID string) error
+	RequestAuthentication(ctx context.Context, documentID string, expectedVersion int64) error
 }
 
 type documentRequestAuthenticationService struct {
 	repo          interfaces.DocumentRepository
 	objectStorage interfaces.ObjectStorage
-	publisher     interfaces.MessagePublisher
-	queue         string
 	expiration    time.Duration
 }
 
@@ -31,8 +29,6 @@ type documentRequestAuthenticationService struct {
 func NewDocumentRequestAuthenticationService(
 	repo interfaces.DocumentRepository,
 	objectStorage interfaces.ObjectStorage,
-	publisher interfaces.MessagePublisher,
-	queue string,
 	expiration time.Duration,
 ) DocumentRequestAuthenticationService {
 	if expiration == 0 {
@@ -41,28 +37,32 @@ func NewDocumentRequestAuthenticationService(
 	return &documentRequestAuthenticationService{
 		repo:          repo,
 		objectStorage: objectStorage,
-		publisher:     publisher,
-		queue:         queue,
 		expiration:    expiration,
 	}
 }
 
-// RequestAuthentication requests authentication for a document by publishing an event
+// RequestAuthentication requests authentication for a document outside the trash. The status
+// change and the request event are stored in one transaction; the outbox relay publishes the event
+// afterwards. Unless expectedVersion is 0, the request is only made for the document at that
+// version, and it is rejected if the document changes before the status does
 func (s *documentRequestAuthenticationService) RequestAuthentication(
 	ctx context.Context,
 	documentID string,
+	expectedVersion int64,
 ) error {
 	doc, err := s.repo.GetByID(ctx, documentID)
 	if err != nil {
 		return err
 	}
 
-	if doc == nil {
+	if doc == nil || doc.IsTrashed() {
 		return errors.NewNotFoundError(fmt.Sprintf("document with ID %s not found", documentID))
 	}
-
-	if err := s.repo.UpdateAuthenticationStatus(ctx, documentID, models.AuthenticationStatusAuthenticating); err != nil {
-		return fmt.Errorf("failed to update authentication status: %w", err)
+	if expectedVersion != 0 && doc.Version != expectedVersion {
+		return errors.NewConflictError("document was modified, read it again before requesting its authentication")
+	}
+	if stderrors.Is(err, interfaces.ErrDocumentNotFound) {
+		return errors.NewNotFoundError("document not found")
 	}
 
 	presignedURL, err := s.objectStorage.GeneratePresignedURL(ctx, doc.ObjectKey, s.expiration)
@@ -81,13 +81,28 @@ func (s *documentRequestAuthenticationService) RequestAuthentication(
 		DocumentID:    doc.ID,
 	}
 
-	eventJSON, err := json.Marshal(event)
+	envelope, err := events.NewEnvelope(events.TypeDocumentAuthenticationRequested, events.DocumentAuthenticationRequestedVersion, messageID, event)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return err
 	}
 
-	if err := s.publisher.Publish(ctx, s.queue, eventJSON); err != nil {
-		return fmt.Errorf("failed to publish authentication request event: %w", err)
+	message, err := models.NewOutboxMessage(envelope)
+	if err != nil {
+		return err
+	}
+	outbox := func([]*models.Document) ([]*models.OutboxMessage, error) {
+		return []*models.OutboxMessage{message}, nil
+	}
+	// The event was built from this version of the document
+	err = s.repo.UpdateAuthenticationStatus(ctx, documentID, doc.Version, models.AuthenticationStatusAuthenticating, outbox)
+	if stderrors.Is(err, interfaces.ErrVersionConflict) {
+		return errors.NewConflictError("document was modified, read it again before requesting its authentication")
+	}
+	if stderrors.Is(err, interfaces.ErrDocumentNotFound) {
+		return errors.NewNotFoundError("document not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update authentication status: %w", err)
 	}
 
 	return nil
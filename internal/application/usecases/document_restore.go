@@ -0,0 +1,65 @@
+package usecases
+
+import (
+	"context"
+	stderrors "errors"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// DocumentRestoreService defines the interface for taking documents out of the trash
+type DocumentRestoreService interface {
+	Restore(ctx context.Context, ownerID int64, id string, expectedVersion int64) (*models.Document, error)
+}
+
+type documentRestoreService struct {
+	repository interfaces.DocumentRepository
+	events     DocumentEvents
+	quotas     models.QuotaPolicy
+}
+
+// NewDocumentRestoreService creates a new document restoration service enforcing quotas
+func NewDocumentRestoreService(repository interfaces.DocumentRepository, events DocumentEvents, quotas models.QuotaPolicy) DocumentRestoreService {
+	return &documentRestoreService{
+		repository: repository,
+		events:     events,
+		quotas:     quotas,
+	}
+}
+
+// Restore takes a document of an owner out of the trash, publishing a document.restored event, and
+// returns it. Documents outside the trash, purged ones included, are not found. The document
+// counts in the quota of the owner again, so restoring it past the quota is rejected with a quota
+// exceeded error. Unless expectedVersion is 0, the document is only restored at that version
+func (s *documentRestoreService) Restore(ctx context.Context, ownerID int64, id string, expectedVersion int64) (*models.Document, error) {
+	trashed, err := s.repository.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewPersistenceError(err)
+	}
+	if trashed == nil || !trashed.IsTrashed() {
+		return nil, errors.NewNotFoundError("document not found in the trash")
+	}
+	if trashed.OwnerID != ownerID {
+		return nil, errors.NewValidationError("forbidden: user is not the owner of the document")
+	}
+
+	quota := s.quotas.For(ownerID)
+	document, err := s.repository.Restore(ctx, id, expectedVersion, quota, s.events.Restored)
+	if stderrors.Is(err, interfaces.ErrQuotaExceeded) {
+		return nil, quotaExceeded(ctx, s.repository, trashed, quota)
+	}
+	if stderrors.Is(err, interfaces.ErrVersionConflict) {
+		return nil, errors.NewConflictError("document was modified, read it again before restoring it")
+	}
+	if err != nil {
+		return nil, errors.NewPersistenceError(err)
+	}
+
+	if document == nil {
+		return nil, errors.NewNotFoundError("document not found in the trash")
+	}
+
+	return document, nil
+}
@@ -48,7 +48,7 @@ func NewDocumentTransferService(
 	}
 }
 
-// PrepareTransfer generates pre-signed URLs for all documents owned by a user
+// PrepareTransfer generates pre-signed URLs for all documents owned by a user outside the trash
 func (s *documentTransferService) PrepareTransfer(ctx context.Context, ownerID int64) ([]DocumentTransferResult, error) {
 	// List all documents for the user
 	documents, _, err := s.repo.List(ctx, ownerID, maxTransferDocuments, 0)
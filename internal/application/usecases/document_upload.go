@@ -1,9 +1,11 @@
 package usecases
 
 import (
-	"bytes"
 	"context"
+	stderrors "errors"
+	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 
 	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
@@ -14,7 +16,7 @@ import (
 
 // DocumentService defines the interface for document upload operations
 type DocumentService interface {
-	Upload(ctx context.Context, fileHeader *multipart.FileHeader, ownerID int64) (*models.Document, error)
+	Upload(ctx context.Context, fileHeader *multipart.FileHeader, ownerID int64) (document *models.Document, created bool, err error)
 }
 
 type documentService struct {
@@ -22,6 +24,8 @@ type documentService struct {
 	storage      interfaces.ObjectStorage
 	hasher       util.FileHasher
 	mimeDetector util.MimeTypeDetector
+	events       DocumentEvents
+	quotas       models.QuotaPolicy
 }
 
 // NewDocumentService creates a new document upload service
@@ -30,63 +34,76 @@ func NewDocumentService(
 	storage interfaces.ObjectStorage,
 	hasher util.FileHasher,
 	mimeDetector util.MimeTypeDetector,
+	events DocumentEvents,
+	quotas models.QuotaPolicy,
 ) DocumentService {
 	return &documentService{
 		repository:   repository,
 		storage:      storage,
 		hasher:       hasher,
 		mimeDetector: mimeDetector,
+		events:       events,
+		quotas:       quotas,
 	}
 }
 
-// Upload uploads a document to storage and saves its metadata to the repository
-// If a document with the same hash already exists for the owner, returns the existing document
-func (service *documentService) Upload(ctx context.Context, fileHeader *multipart.FileHeader, ownerID int64) (*models.Document, error) {
+// Upload uploads a document to storage and saves its metadata to the repository, publishing a
+// document.uploaded event. If a document with the same hash already exists for the owner, returns
+// the existing document, restored from the trash if it was there, and created is false. A new
+// document taking the owner past their quota is rejected with a quota exceeded error
+func (service *documentService) Upload(ctx context.Context, fileHeader *multipart.FileHeader, ownerID int64) (*models.Document, bool, error) {
 	file, err := fileHeader.Open()
 	if err != nil {
-		return nil, errors.NewFileReadError(err)
+		return nil, false, errors.NewFileReadError(err)
 	}
 	defer func() { _ = file.Close() }()
 
-	// Delegate to UploadFromReader which contains the shared logic
-	if seeker, ok := file.(io.ReadSeeker); ok {
-		return service.UploadFromReader(ctx, seeker, fileHeader.Filename, fileHeader.Size, ownerID)
+	// Stream straight to storage when supported: the file is read once and never buffered whole
+	if staging, ok := service.storage.(interfaces.StagingObjectStorage); ok {
+		return service.uploadStreaming(ctx, staging, file, fileHeader.Filename, ownerID)
 	}
 
-	// If not a ReadSeeker, copy into a buffer
-	// Use io.ReadAll as fallback (file should be small enough for upload use-cases)
-	data, err := io.ReadAll(file)
-	if err != nil {
-		return nil, errors.NewFileReadError(err)
-	}
-	return service.UploadFromReader(ctx, bytes.NewReader(data), fileHeader.Filename, fileHeader.Size, ownerID)
+	// A multipart file is seekable, so it is read twice instead of buffered
+	return service.UploadFromReader(ctx, file, fileHeader.Filename, fileHeader.Size, ownerID)
 }
 
 // UploadFromReader uploads a document reading from an io.ReadSeeker. It implements DocumentUploader.
-func (service *documentService) UploadFromReader(ctx context.Context, r io.ReadSeeker, filename string, size int64, ownerID int64) (*models.Document, error) {
-	// Compute hash
+func (service *documentService) UploadFromReader(ctx context.Context, r io.ReadSeeker, filename string, size int64, ownerID int64) (*models.Document, bool, error) {
 	if _, err := r.Seek(0, io.SeekStart); err != nil {
-		return nil, errors.NewFileReadError(err)
+		return nil, false, errors.NewFileReadError(err)
 	}
+
+	if staging, ok := service.storage.(interfaces.StagingObjectStorage); ok {
+		return service.uploadStreaming(ctx, staging, r, filename, ownerID)
+	}
+
+	// Compute hash
 	hash, err := service.hasher.CalculateHash(r)
 	if err != nil {
-		return nil, errors.NewHashCalculateError(err)
+		return nil, false, errors.NewHashCalculateError(err)
 	}
 
-	existingDoc, _ := service.repository.FindByHashAndOwnerID(ctx, hash, ownerID)
+	existingDoc, err := service.repository.FindByHashAndOwnerID(ctx, hash, ownerID)
+	if err != nil {
+		return nil, false, errors.NewPersistenceError(err)
+	}
 	if existingDoc != nil {
-		return existingDoc, nil
+		return service.reuse(ctx, existingDoc)
+	}
+
+	if err := service.checkQuota(ctx, ownerID, size); err != nil {
+		return nil, false, err
 	}
 
 	objectKey := util.ObjectKeyFromHash(hash, filename)
 	contentType := service.mimeDetector.DetectFromFilename(filename)
 
 	if _, err := r.Seek(0, io.SeekStart); err != nil {
-		return nil, errors.NewFileReadError(err)
+		return nil, false, errors.NewFileReadError(err)
 	}
 
 	if err := service.storage.Put(ctx, r, objectKey, contentType); err != nil {
-		return nil, errors.NewStorageUploadError(err)
+		return nil, false, errors.NewStorageUploadError(err)
 	}
 
 	publicURL := service.storage.PublicURL(objectKey)
@@ -103,12 +120,226 @@ func (service *documentService) UploadFromReader(ctx context.Context, r io.ReadS
 	}
 
 	if err := document.Validate(); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	if err := service.repository.Create(ctx, document); err != nil {
-		return nil, errors.NewPersistenceError(err)
+	return service.create(ctx, document)
+}
+
+// CompleteStagedUpload turns an object already written to a staging key into a document.
+// It implements StagedDocumentUploader
+func (service *documentService) CompleteStagedUpload(ctx context.Context, stagingKey, hash, filename string, size int64, ownerID int64) (*models.Document, bool, error) {
+	staging, ok := service.storage.(interfaces.StagingObjectStorage)
+	if !ok {
+		return nil, false, errors.NewStorageUploadError(fmt.Errorf("storage does not support staged uploads"))
 	}
 
-	return document, nil
+	contentType := service.mimeDetector.DetectFromFilename(filename)
+	return service.completeStagedUpload(ctx, staging, stagingKey, hash, filename, contentType, size, ownerID)
+}
+
+// hashResult carries the outcome of the hash computed alongside a streaming upload
+type hashResult struct {
+	hash string
+	err  error
+}
+
+// uploadStreaming uploads a document in a single pass: the content is teed into the hasher while
+// it is streamed to a staging key, then the staged object is deduplicated or promoted
+func (service *documentService) uploadStreaming(ctx context.Context, staging interfaces.StagingObjectStorage, r io.Reader, filename string, ownerID int64) (*models.Document, bool, error) {
+	contentType := service.mimeDetector.DetectFromFilename(filename)
+
+	pipeReader, pipeWriter := io.Pipe()
+	hashDone := make(chan hashResult, 1)
+	go func() {
+		hash, err := service.hasher.CalculateHash(pipeReader)
+		// Keep draining so the upload never blocks on a hasher that stopped reading early
+		_, _ = io.Copy(io.Discard, pipeReader)
+		hashDone <- hashResult{hash: hash, err: err}
+	}()
+
+	stagingKey, size, err := staging.PutStaged(ctx, io.TeeReader(r, pipeWriter), contentType)
+	_ = pipeWriter.CloseWithError(err)
+	result := <-hashDone
+	if err != nil {
+		return nil, false, errors.NewStorageUploadError(err)
+	}
+	if result.err != nil {
+		_ = staging.DiscardStaged(ctx, stagingKey)
+		return nil, false, errors.NewHashCalculateError(result.err)
+	}
+
+	document, created, err := service.completeStagedUpload(ctx, staging, stagingKey, result.hash, filename, contentType, size, ownerID)
+	if isRetryableUpload(err) {
+		// Nothing retries a streamed upload from its staged object
+		_ = staging.DiscardStaged(ctx, stagingKey)
+	}
+	return document, created, err
+}
+
+// completeStagedUpload turns a staged object whose digest is known into a document. The staged
+// object is only promoted to the key of its content once the repository stored the document, so a
+// rejected document leaves no object behind, and it is discarded when the owner already has the
+// same content or when the document is rejected. It is kept when the upload may succeed on a
+// retry (see isRetryableUpload), and a document whose object fails to be promoted is deleted again
+func (service *documentService) completeStagedUpload(
+	ctx context.Context,
+	staging interfaces.StagingObjectStorage,
+	stagingKey, hash, filename, contentType string,
+	size int64,
+	ownerID int64,
+) (*models.Document, bool, error) {
+	discard := func(err error) {
+		if !isRetryableUpload(err) {
+			_ = staging.DiscardStaged(ctx, stagingKey)
+		}
+	}
+
+	existingDoc, err := service.repository.FindByHashAndOwnerID(ctx, hash, ownerID)
+	if err != nil {
+		return nil, false, errors.NewPersistenceError(err)
+	}
+	if existingDoc != nil {
+		reused, created, err := service.reuse(ctx, existingDoc)
+		discard(err)
+		return reused, created, err
+	}
+
+	objectKey := util.ObjectKeyFromHash(hash, filename)
+	document := &models.Document{
+		Filename:             filename,
+		MimeType:             contentType,
+		SizeBytes:            size,
+		HashSHA256:           hash,
+		Bucket:               staging.Bucket(),
+		ObjectKey:            objectKey,
+		URL:                  staging.PublicURL(objectKey),
+		OwnerID:              ownerID,
+		AuthenticationStatus: models.AuthenticationStatusUnauthenticated,
+	}
+
+	if err := document.Validate(); err != nil {
+		discard(err)
+		return nil, false, err
+	}
+
+	if err := service.checkQuota(ctx, ownerID, size); err != nil {
+		discard(err)
+		return nil, false, err
+	}
+
+	stored, created, err := service.create(ctx, document)
+	if err != nil || !created {
+		discard(err)
+		return stored, created, err
+	}
+
+	if err := staging.PromoteStaged(ctx, stagingKey, objectKey, contentType); err != nil {
+		// The document must not point to content that was never stored; the reference it took is
+		// released with it, and the purge deletes the object if nothing else references it
+		if _, deleteErr := service.repository.DeleteByID(ctx, document.ID, document.Version, service.events.Deleted); deleteErr != nil {
+			log.Printf("failed to delete document %s whose object could not be stored: %v", document.ID, deleteErr)
+		}
+		return nil, false, errors.NewStorageUploadError(err)
+	}
+
+	return stored, true, nil
+}
+
+// isRetryableUpload reports whether an upload failed for a reason a retry may not run into again,
+// such as an unavailable repository or storage or a concurrent change, rather than because the
+// document was rejected
+func isRetryableUpload(err error) bool {
+	var domainErr *errors.DomainError
+	if !stderrors.As(err, &domainErr) {
+		return false
+	}
+	switch domainErr.Code {
+	case errors.ErrCodePersistence, errors.ErrCodeStorageUpload, errors.ErrCodeConflict:
+		return true
+	}
+	return false
+}
+
+// checkQuota rejects a document of size bytes that would take its owner past their quota, before
+// its content is stored. The repository checks the quota again when creating the document
+func (service *documentService) checkQuota(ctx context.Context, ownerID, size int64) error {
+	quota := service.quotas.For(ownerID)
+	if quota == (models.Quota{}) {
+		return nil
+	}
+
+	usage, err := service.repository.GetUsage(ctx, ownerID)
+	if err != nil {
+		return errors.NewPersistenceError(err)
+	}
+	return quota.Check(*usage, size)
+}
+
+// create stores a new document. The owner may have stored the same content concurrently since the
+// deduplication lookup, in which case the repository rejects the document and the one stored first
+// is returned instead, so that every concurrent upload gets the same document. Concurrent uploads
+// may also have used up the quota of the owner since it was checked, and the object of the content
+// may be being deleted after its last document was purged
+func (service *documentService) create(ctx context.Context, document *models.Document) (*models.Document, bool, error) {
+	quota := service.quotas.For(document.OwnerID)
+	err := service.repository.Create(ctx, document, quota, service.events.Uploaded)
+	if err == nil {
+		return document, true, nil
+	}
+	if stderrors.Is(err, interfaces.ErrQuotaExceeded) {
+		return nil, false, quotaExceeded(ctx, service.repository, document, quota)
+	}
+	if stderrors.Is(err, interfaces.ErrObjectReleasing) {
+		// The stored object is deleted along with the last document referencing it
+		return nil, false, errors.NewConflictError("the same content is being deleted from storage, retry the upload")
+	}
+	if !stderrors.Is(err, interfaces.ErrDuplicateDocument) {
+		return nil, false, errors.NewPersistenceError(err)
+	}
+
+	existingDoc, err := service.repository.FindByHashAndOwnerID(ctx, document.HashSHA256, document.OwnerID)
+	if err != nil {
+		return nil, false, errors.NewPersistenceError(err)
+	}
+	if existingDoc == nil {
+		// Deleted right after it was stored
+		return nil, false, errors.NewConflictError("a document with the same content was deleted concurrently, retry the upload")
+	}
+	return service.reuse(ctx, existingDoc)
+}
+
+// reuse returns the document the owner already has with the content of an upload, taking it out
+// of the trash first if needed, so that uploading deleted content again restores its document.
+// The restored document counts in the quota of the owner again
+func (service *documentService) reuse(ctx context.Context, existingDoc *models.Document) (*models.Document, bool, error) {
+	if !existingDoc.IsTrashed() {
+		return existingDoc, false, nil
+	}
+
+	quota := service.quotas.For(existingDoc.OwnerID)
+	restored, err := service.repository.Restore(ctx, existingDoc.ID, existingDoc.Version, quota, service.events.Restored)
+	if stderrors.Is(err, interfaces.ErrQuotaExceeded) {
+		return nil, false, quotaExceeded(ctx, service.repository, existingDoc, quota)
+	}
+	if err != nil && !stderrors.Is(err, interfaces.ErrVersionConflict) {
+		return nil, false, errors.NewPersistenceError(err)
+	}
+	if restored == nil {
+		// Restored, changed or purged concurrently
+		return nil, false, errors.NewConflictError("a document with the same content was changed concurrently, retry the upload")
+	}
+	return restored, false, nil
+}
+
+// quotaExceeded builds the error of a document rejected by the repository for its quota, telling
+// which limit it exceeds from the usage of its owner
+func quotaExceeded(ctx context.Context, repository interfaces.DocumentRepository, document *models.Document, quota models.Quota) error {
+	if usage, err := repository.GetUsage(ctx, document.OwnerID); err == nil {
+		if err := quota.Check(*usage, document.SizeBytes); err != nil {
+			return err
+		}
+	}
+	// Usage released concurrently, or unreadable
+	return errors.NewQuotaExceededError("storage quota exceeded", errors.ErrStorageQuotaExceeded)
 }
@@ -0,0 +1,36 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// DocumentUsageService defines the interface for reporting the storage consumed by an owner
+type DocumentUsageService interface {
+	GetUsage(ctx context.Context, ownerID int64) (*models.OwnerUsage, models.Quota, error)
+}
+
+type documentUsageService struct {
+	repository interfaces.DocumentRepository
+	quotas     models.QuotaPolicy
+}
+
+// NewDocumentUsageService creates a new usage reporting service
+func NewDocumentUsageService(repository interfaces.DocumentRepository, quotas models.QuotaPolicy) DocumentUsageService {
+	return &documentUsageService{
+		repository: repository,
+		quotas:     quotas,
+	}
+}
+
+// GetUsage returns the number and total size of the documents of an owner along with their quota
+func (s *documentUsageService) GetUsage(ctx context.Context, ownerID int64) (*models.OwnerUsage, models.Quota, error) {
+	usage, err := s.repository.GetUsage(ctx, ownerID)
+	if err != nil {
+		return nil, models.Quota{}, errors.NewPersistenceError(err)
+	}
+	return usage, s.quotas.For(ownerID), nil
+}
@@ -0,0 +1,285 @@
+package usecases
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	domainerrors "github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type directUploadMocks struct {
+	sessions     *MockUploadSessionRepository
+	storage      *MockStagingObjectStorage
+	uploader     *MockStagedDocumentUploader
+	hasher       *MockFileHasher
+	mimeDetector *MockMimeDetector
+}
+
+func newDirectUploadService() (usecases.DirectUploadService, *directUploadMocks) {
+	mocks := &directUploadMocks{
+		sessions:     new(MockUploadSessionRepository),
+		storage:      new(MockStagingObjectStorage),
+		uploader:     new(MockStagedDocumentUploader),
+		hasher:       new(MockFileHasher),
+		mimeDetector: new(MockMimeDetector),
+	}
+	service := usecases.NewDirectUploadService(mocks.sessions, mocks.storage, mocks.uploader, mocks.hasher, mocks.mimeDetector, time.Hour)
+	return service, mocks
+}
+
+func newTestDirectUpload(length int64, expectedSHA256 string) *models.UploadSession {
+	session := models.NewUploadSession(1, "test.pdf", "application/pdf", length, time.Hour)
+	session.ID = "upload-1"
+	session.Kind = models.UploadKindDirect
+	session.StagingKey = "staging/upload-1"
+	session.ExpectedSHA256 = expectedSHA256
+	return session
+}
+
+func assertDomainErrorCode(t *testing.T, err error, code string) {
+	var domainErr *domainerrors.DomainError
+	if assert.True(t, errors.As(err, &domainErr)) {
+		assert.Equal(t, code, domainErr.Code)
+	}
+}
+
+func TestDirectUploadService_Initiate_Put(t *testing.T) {
+	// Arrange
+	service, mocks := newDirectUploadService()
+	ctx := context.Background()
+	sum := sha256.Sum256([]byte("content"))
+	checksum := hex.EncodeToString(sum[:])
+	presigned := &interfaces.PresignedUpload{Method: "PUT", URL: "https://bucket/staging/abc", ExpiresAt: time.Now().Add(15 * time.Minute)}
+
+	mocks.mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	mocks.storage.On("NewStagingKey").Return("staging/abc")
+	mocks.storage.On("GeneratePresignedPut", ctx, "staging/abc", mock.MatchedBy(func(policy interfaces.UploadPolicy) bool {
+		return policy.Size == 7 &&
+			policy.ContentType == "application/pdf" &&
+			policy.ChecksumSHA256 == base64.StdEncoding.EncodeToString(sum[:]) &&
+			policy.Expiration == 15*time.Minute
+	})).Return(presigned, nil)
+	mocks.sessions.On("Create", ctx, mock.MatchedBy(func(session *models.UploadSession) bool {
+		return session.IsDirect() && session.StagingKey == "staging/abc" && session.ExpectedSHA256 == checksum
+	})).Return(nil)
+
+	// Act
+	session, result, err := service.Initiate(ctx, 1, "test.pdf", 7, strings.ToUpper(checksum), "")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, presigned, result)
+	assert.Equal(t, int64(7), session.Length)
+	mocks.storage.AssertExpectations(t)
+	mocks.sessions.AssertExpectations(t)
+}
+
+func TestDirectUploadService_Initiate_Post(t *testing.T) {
+	service, mocks := newDirectUploadService()
+	ctx := context.Background()
+	presigned := &interfaces.PresignedUpload{Method: "POST", URL: "https://bucket", FormFields: map[string]string{"key": "staging/abc"}}
+
+	mocks.mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	mocks.storage.On("NewStagingKey").Return("staging/abc")
+	mocks.storage.On("GeneratePresignedPost", ctx, "staging/abc", mock.AnythingOfType("interfaces.UploadPolicy")).Return(presigned, nil)
+	mocks.sessions.On("Create", ctx, mock.AnythingOfType("*models.UploadSession")).Return(nil)
+
+	_, result, err := service.Initiate(ctx, 1, "test.pdf", 7, "", "post")
+
+	assert.NoError(t, err)
+	assert.Equal(t, presigned, result)
+	mocks.storage.AssertNotCalled(t, "GeneratePresignedPut", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDirectUploadService_Initiate_Validation(t *testing.T) {
+	service, _ := newDirectUploadService()
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		size     int64
+		checksum string
+	}{
+		{name: "zero size", size: 0},
+		{name: "too large", size: usecases.MaxDirectUploadSize + 1},
+		{name: "invalid checksum", size: 10, checksum: "not-a-digest"},
+		{name: "short checksum", size: 10, checksum: "abcd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := service.Initiate(ctx, 1, "test.pdf", tt.size, tt.checksum, "PUT")
+			assertDomainErrorCode(t, err, domainerrors.ErrCodeValidation)
+		})
+	}
+}
+
+func TestDirectUploadService_Complete_UsesStorageChecksum(t *testing.T) {
+	// Arrange
+	service, mocks := newDirectUploadService()
+	ctx := context.Background()
+	sum := sha256.Sum256([]byte("content"))
+	checksum := hex.EncodeToString(sum[:])
+	existing := newTestDirectUpload(7, checksum)
+	document := &models.Document{ID: "doc-1", Filename: "test.pdf", OwnerID: 1}
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(existing, nil)
+	mocks.sessions.On("AcquireLease", ctx, "upload-1", int64(0), mock.Anything).Return(true, nil)
+	mocks.storage.On("Stat", ctx, "staging/upload-1").Return(&interfaces.ObjectInfo{Size: 7, ChecksumSHA256: base64.StdEncoding.EncodeToString(sum[:])}, nil)
+	mocks.uploader.On("CompleteStagedUpload", ctx, "staging/upload-1", checksum, "test.pdf", int64(7), int64(1)).Return(document, true, nil)
+	mocks.sessions.On("Delete", ctx, "upload-1").Return(nil)
+
+	// Act
+	result, created, err := service.Complete(ctx, "upload-1", 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, document, result)
+	assert.True(t, created)
+	mocks.storage.AssertNotCalled(t, "Open", mock.Anything, mock.Anything)
+	mocks.uploader.AssertExpectations(t)
+	mocks.sessions.AssertExpectations(t)
+}
+
+func TestDirectUploadService_Complete_RehashesWithoutStorageChecksum(t *testing.T) {
+	service, mocks := newDirectUploadService()
+	ctx := context.Background()
+	existing := newTestDirectUpload(7, "")
+	document := &models.Document{ID: "doc-1"}
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(existing, nil)
+	mocks.sessions.On("AcquireLease", ctx, "upload-1", int64(0), mock.Anything).Return(true, nil)
+	mocks.storage.On("Stat", ctx, "staging/upload-1").Return(&interfaces.ObjectInfo{Size: 7}, nil)
+	mocks.storage.On("Open", ctx, "staging/upload-1").Return(io.NopCloser(strings.NewReader("content")), nil)
+	mocks.hasher.On("CalculateHash", mock.Anything).Return("computed-hash", nil)
+	mocks.uploader.On("CompleteStagedUpload", ctx, "staging/upload-1", "computed-hash", "test.pdf", int64(7), int64(1)).Return(document, true, nil)
+	mocks.sessions.On("Delete", ctx, "upload-1").Return(nil)
+
+	result, created, err := service.Complete(ctx, "upload-1", 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, document, result)
+	assert.True(t, created)
+	mocks.hasher.AssertExpectations(t)
+}
+
+func TestDirectUploadService_Complete_KeepsSessionOnRetryableError(t *testing.T) {
+	service, mocks := newDirectUploadService()
+	ctx := context.Background()
+	existing := newTestDirectUpload(7, "")
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(existing, nil)
+	mocks.sessions.On("AcquireLease", ctx, "upload-1", int64(0), mock.Anything).Return(true, nil)
+	mocks.storage.On("Stat", ctx, "staging/upload-1").Return(&interfaces.ObjectInfo{Size: 7}, nil)
+	mocks.storage.On("Open", ctx, "staging/upload-1").Return(io.NopCloser(strings.NewReader("content")), nil)
+	mocks.hasher.On("CalculateHash", mock.Anything).Return("computed-hash", nil)
+	mocks.uploader.On("CompleteStagedUpload", ctx, "staging/upload-1", "computed-hash", "test.pdf", int64(7), int64(1)).
+		Return(nil, false, domainerrors.NewPersistenceError(errors.New("database unavailable")))
+	mocks.sessions.On("Save", ctx, existing).Return(nil)
+
+	result, _, err := service.Complete(ctx, "upload-1", 1)
+
+	assert.Nil(t, result)
+	assertDomainErrorCode(t, err, domainerrors.ErrCodePersistence)
+	mocks.sessions.AssertExpectations(t)
+	mocks.sessions.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestDirectUploadService_Complete_DeletesSessionOnRejectedDocument(t *testing.T) {
+	service, mocks := newDirectUploadService()
+	ctx := context.Background()
+	existing := newTestDirectUpload(7, "")
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(existing, nil)
+	mocks.sessions.On("AcquireLease", ctx, "upload-1", int64(0), mock.Anything).Return(true, nil)
+	mocks.storage.On("Stat", ctx, "staging/upload-1").Return(&interfaces.ObjectInfo{Size: 7}, nil)
+	mocks.storage.On("Open", ctx, "staging/upload-1").Return(io.NopCloser(strings.NewReader("content")), nil)
+	mocks.hasher.On("CalculateHash", mock.Anything).Return("computed-hash", nil)
+	mocks.uploader.On("CompleteStagedUpload", ctx, "staging/upload-1", "computed-hash", "test.pdf", int64(7), int64(1)).
+		Return(nil, false, domainerrors.NewQuotaExceededError("storage quota exceeded", domainerrors.ErrStorageQuotaExceeded))
+	mocks.sessions.On("Delete", ctx, "upload-1").Return(nil)
+
+	_, _, err := service.Complete(ctx, "upload-1", 1)
+
+	assertDomainErrorCode(t, err, domainerrors.ErrCodeQuotaExceeded)
+	mocks.sessions.AssertExpectations(t)
+	mocks.sessions.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func TestDirectUploadService_Complete_ChecksumMismatchDiscardsObject(t *testing.T) {
+	service, mocks := newDirectUploadService()
+	ctx := context.Background()
+	sum := sha256.Sum256([]byte("content"))
+	existing := newTestDirectUpload(7, strings.Repeat("0", 64))
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(existing, nil)
+	mocks.sessions.On("AcquireLease", ctx, "upload-1", int64(0), mock.Anything).Return(true, nil)
+	mocks.storage.On("Stat", ctx, "staging/upload-1").Return(&interfaces.ObjectInfo{Size: 7, ChecksumSHA256: base64.StdEncoding.EncodeToString(sum[:])}, nil)
+	mocks.storage.On("DiscardStaged", ctx, "staging/upload-1").Return(nil)
+	mocks.sessions.On("Save", ctx, existing).Return(nil)
+
+	result, _, err := service.Complete(ctx, "upload-1", 1)
+
+	assert.Nil(t, result)
+	assertDomainErrorCode(t, err, domainerrors.ErrCodeValidation)
+	mocks.storage.AssertExpectations(t)
+	mocks.sessions.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	mocks.uploader.AssertNotCalled(t, "CompleteStagedUpload", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDirectUploadService_Complete_SizeMismatchDiscardsObject(t *testing.T) {
+	service, mocks := newDirectUploadService()
+	ctx := context.Background()
+	existing := newTestDirectUpload(7, "")
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(existing, nil)
+	mocks.sessions.On("AcquireLease", ctx, "upload-1", int64(0), mock.Anything).Return(true, nil)
+	mocks.storage.On("Stat", ctx, "staging/upload-1").Return(&interfaces.ObjectInfo{Size: 8}, nil)
+	mocks.storage.On("DiscardStaged", ctx, "staging/upload-1").Return(nil)
+	mocks.sessions.On("Save", ctx, existing).Return(nil)
+
+	_, _, err := service.Complete(ctx, "upload-1", 1)
+
+	assertDomainErrorCode(t, err, domainerrors.ErrCodeValidation)
+	mocks.storage.AssertExpectations(t)
+}
+
+func TestDirectUploadService_Complete_NotUploadedYet(t *testing.T) {
+	service, mocks := newDirectUploadService()
+	ctx := context.Background()
+	existing := newTestDirectUpload(7, "")
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(existing, nil)
+	mocks.sessions.On("AcquireLease", ctx, "upload-1", int64(0), mock.Anything).Return(true, nil)
+	mocks.storage.On("Stat", ctx, "staging/upload-1").Return(nil, interfaces.ErrObjectNotFound)
+	mocks.sessions.On("Save", ctx, existing).Return(nil)
+
+	_, _, err := service.Complete(ctx, "upload-1", 1)
+
+	assertDomainErrorCode(t, err, domainerrors.ErrCodeUploadOffsetMismatch)
+	mocks.storage.AssertNotCalled(t, "DiscardStaged", mock.Anything, mock.Anything)
+	mocks.sessions.AssertExpectations(t)
+}
+
+func TestDirectUploadService_Complete_ChunkedSessionIsNotFound(t *testing.T) {
+	service, mocks := newDirectUploadService()
+	ctx := context.Background()
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(newTestUploadSession(0, 7), nil)
+
+	_, _, err := service.Complete(ctx, "upload-1", 1)
+
+	assertDomainErrorCode(t, err, domainerrors.ErrCodeNotFound)
+}
@@ -6,17 +6,19 @@ import (
 	"testing"
 	"time"
 
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
 	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestDocumentDeleteAllService_Execute_Success(t *testing.T) {
 	// Arrange
 	repo := new(MockDocumentRepository)
-	storage := new(MockObjectStorage)
 
-	service := usecases.NewDocumentDeleteAllService(repo, storage)
+	service := usecases.NewDocumentDeleteAllService(repo, usecases.NewDocumentEvents(), 7*24*time.Hour)
 
 	ctx := context.Background()
 	ownerID := int64(1)
@@ -24,14 +26,18 @@ func TestDocumentDeleteAllService_Execute_Success(t *testing.T) {
 		{ID: "1", OwnerID: ownerID, ObjectKey: "k1", Filename: "a.pdf", SizeBytes: 10, MimeType: "application/pdf", CreatedAt: time.Now()},
 		{ID: "2", OwnerID: ownerID, ObjectKey: "k2", Filename: "b.pdf", SizeBytes: 20, MimeType: "application/pdf", CreatedAt: time.Now()},
 	}
-	repo.On("List", ctx, ownerID, 1000, 0).Return(docs, int64(len(docs)), nil)
-	repo.On("DeleteAllByOwnerID", ctx, ownerID).Return(len(docs), nil)
-	// storage deletions are best-effort; even if they fail, service doesn't error, just logs
-
-	// Expect Delete for each doc
-
-	storage.On("Delete", ctx, "k1").Return(nil)
-	storage.On("Delete", ctx, "k2").Return(nil)
+	var eventTypes []string
+	var purgeAt time.Time
+	repo.On("TrashAllByOwnerID", ctx, ownerID, mock.AnythingOfType("time.Time"), mock.AnythingOfType("interfaces.OutboxFunc")).Return(len(docs), nil).Run(func(args mock.Arguments) {
+		purgeAt = args.Get(2).(time.Time)
+		messages, err := args.Get(3).(interfaces.OutboxFunc)(docs)
+		assert.NoError(t, err)
+		for _, message := range messages {
+			envelope, err := message.Event()
+			assert.NoError(t, err)
+			eventTypes = append(eventTypes, envelope.Type)
+		}
+	})
 
 	// Act
 	count, err := service.DeleteAll(ctx, ownerID)
@@ -39,6 +45,8 @@ func TestDocumentDeleteAllService_Execute_Success(t *testing.T) {
 	// Assert
 	assert.NoError(t, err)
 	assert.Equal(t, len(docs), count)
+	assert.Equal(t, []string{events.TypeDocumentsBulkDeleted}, eventTypes)
+	assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), purgeAt, time.Minute, "documents are purged after the retention")
 
 	repo.AssertExpectations(t)
 }
@@ -46,13 +54,12 @@ func TestDocumentDeleteAllService_Execute_Success(t *testing.T) {
 func TestDocumentDeleteAllService_Execute_NoDocuments(t *testing.T) {
 	// Arrange
 	repo := new(MockDocumentRepository)
-	storage := new(MockObjectStorage)
 
-	service := usecases.NewDocumentDeleteAllService(repo, storage)
+	service := usecases.NewDocumentDeleteAllService(repo, usecases.NewDocumentEvents(), time.Hour)
 
 	ctx := context.Background()
 	ownerID := int64(1)
-	repo.On("List", ctx, ownerID, 1000, 0).Return([]*models.Document{}, int64(0), nil)
+	repo.On("TrashAllByOwnerID", ctx, ownerID, mock.Anything, mock.Anything).Return(0, nil)
 
 	// Act
 	count, err := service.DeleteAll(ctx, ownerID)
@@ -67,15 +74,14 @@ func TestDocumentDeleteAllService_Execute_NoDocuments(t *testing.T) {
 func TestDocumentDeleteAllService_Execute_RepositoryError(t *testing.T) {
 	// Arrange
 	repo := new(MockDocumentRepository)
-	storage := new(MockObjectStorage)
 
-	service := usecases.NewDocumentDeleteAllService(repo, storage)
+	service := usecases.NewDocumentDeleteAllService(repo, usecases.NewDocumentEvents(), time.Hour)
 
 	ctx := context.Background()
 	ownerID := int64(1)
 
 	expectedError := errors.New("database error")
-	repo.On("List", ctx, ownerID, 1000, 0).Return(nil, int64(0), expectedError)
+	repo.On("TrashAllByOwnerID", ctx, ownerID, mock.Anything, mock.Anything).Return(0, expectedError)
 
 	// Act
 	count, err := service.DeleteAll(ctx, ownerID)
@@ -87,21 +93,3 @@ func TestDocumentDeleteAllService_Execute_RepositoryError(t *testing.T) {
 
 	repo.AssertExpectations(t)
 }
-
-func TestDocumentDeleteAllService_Execute_InvalidOwnerID(t *testing.T) {
-	// Arrange
-	repo := new(MockDocumentRepository)
-	storage := new(MockObjectStorage)
-
-	service := usecases.NewDocumentDeleteAllService(repo, storage)
-
-	ctx := context.Background()
-	ownerID := int64(0)
-	// current implementation does not validate ownerID and will list -> assume empty
-	repo.On("List", ctx, ownerID, 1000, 0).Return([]*models.Document{}, int64(0), nil)
-	// Act
-	count, err := service.DeleteAll(ctx, ownerID)
-	// Assert
-	assert.NoError(t, err)
-	assert.Equal(t, 0, count)
-}
@@ -6,17 +6,20 @@ import (
 	"testing"
 	"time"
 
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
 	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	domainerrors "github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestDocumentDeleteService_Execute_Success(t *testing.T) {
 	// Arrange
 	repo := new(MockDocumentRepository)
-	storage := new(MockObjectStorage)
 
-	service := usecases.NewDocumentDeleteService(repo, storage)
+	service := usecases.NewDocumentDeleteService(repo, usecases.NewDocumentEvents(), 30*24*time.Hour)
 
 	ctx := context.Background()
 	documentID := "doc-123"
@@ -32,33 +35,43 @@ func TestDocumentDeleteService_Execute_Success(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 
-	repo.On("DeleteByID", ctx, documentID).Return(doc, nil)
-	storage.On("Delete", ctx, "documents/test.pdf").Return(nil)
+	var eventTypes []string
+	var purgeAt time.Time
+	repo.On("Trash", ctx, documentID, int64(0), mock.AnythingOfType("time.Time"), mock.AnythingOfType("interfaces.OutboxFunc")).Return(doc, nil).Run(func(args mock.Arguments) {
+		purgeAt = args.Get(3).(time.Time)
+		messages, err := args.Get(4).(interfaces.OutboxFunc)([]*models.Document{doc})
+		assert.NoError(t, err)
+		for _, message := range messages {
+			envelope, err := message.Event()
+			assert.NoError(t, err)
+			eventTypes = append(eventTypes, envelope.Type)
+		}
+	})
 
 	// Act
-	err := service.Delete(ctx, documentID)
+	err := service.Delete(ctx, documentID, 0)
 
 	// Assert
 	assert.NoError(t, err)
+	assert.Equal(t, []string{events.TypeDocumentDeleted}, eventTypes)
+	assert.WithinDuration(t, time.Now().Add(30*24*time.Hour), purgeAt, time.Minute, "the document is purged after the retention")
 
 	repo.AssertExpectations(t)
-	storage.AssertExpectations(t)
 }
 
 func TestDocumentDeleteService_Execute_DocumentNotFound(t *testing.T) {
 	// Arrange
 	repo := new(MockDocumentRepository)
-	storage := new(MockObjectStorage)
 
-	service := usecases.NewDocumentDeleteService(repo, storage)
+	service := usecases.NewDocumentDeleteService(repo, usecases.NewDocumentEvents(), time.Hour)
 
 	ctx := context.Background()
 	documentID := "non-existent"
 
-	repo.On("DeleteByID", ctx, documentID).Return(nil, nil)
+	repo.On("Trash", ctx, documentID, int64(0), mock.Anything, mock.Anything).Return(nil, nil)
 
 	// Act
-	err := service.Delete(ctx, documentID)
+	err := service.Delete(ctx, documentID, 0)
 
 	// Assert
 	assert.Error(t, err)
@@ -67,61 +80,43 @@ func TestDocumentDeleteService_Execute_DocumentNotFound(t *testing.T) {
 	repo.AssertExpectations(t)
 }
 
-func TestDocumentDeleteService_Execute_StorageDeleteError(t *testing.T) {
+func TestDocumentDeleteService_Execute_RepositoryError(t *testing.T) {
 	// Arrange
 	repo := new(MockDocumentRepository)
-	storage := new(MockObjectStorage)
 
-	service := usecases.NewDocumentDeleteService(repo, storage)
+	service := usecases.NewDocumentDeleteService(repo, usecases.NewDocumentEvents(), time.Hour)
 
 	ctx := context.Background()
 	documentID := "doc-123"
 
-	doc := &models.Document{
-		ID:        documentID,
-		Filename:  "test.pdf",
-		OwnerID:   1,
-		SizeBytes: 1024,
-		MimeType:  "application/pdf",
-		ObjectKey: "documents/test.pdf",
-		URL:       "https://s3.amazonaws.com/bucket/documents/test.pdf",
-		CreatedAt: time.Now(),
-	}
-
-	expectedError := errors.New("storage delete failed")
-	repo.On("DeleteByID", ctx, documentID).Return(doc, nil)
-	storage.On("Delete", ctx, "documents/test.pdf").Return(expectedError)
+	expectedError := errors.New("database error")
+	repo.On("Trash", ctx, documentID, int64(0), mock.Anything, mock.Anything).Return(nil, expectedError)
 
 	// Act
-	err := service.Delete(ctx, documentID)
+	err := service.Delete(ctx, documentID, 0)
 
 	// Assert
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "failed to delete object from storage")
+	assert.Contains(t, err.Error(), "failed to persist document")
 
 	repo.AssertExpectations(t)
-	storage.AssertExpectations(t)
 }
 
-func TestDocumentDeleteService_Execute_RepositoryError(t *testing.T) {
+func TestDocumentDeleteService_Execute_VersionConflict(t *testing.T) {
 	// Arrange
 	repo := new(MockDocumentRepository)
-	storage := new(MockObjectStorage)
 
-	service := usecases.NewDocumentDeleteService(repo, storage)
+	service := usecases.NewDocumentDeleteService(repo, usecases.NewDocumentEvents(), time.Hour)
 
 	ctx := context.Background()
 	documentID := "doc-123"
 
-	expectedError := errors.New("database error")
-	repo.On("DeleteByID", ctx, documentID).Return(nil, expectedError)
+	repo.On("Trash", ctx, documentID, int64(3), mock.Anything, mock.Anything).Return(nil, interfaces.ErrVersionConflict)
 
 	// Act
-	err := service.Delete(ctx, documentID)
+	err := service.Delete(ctx, documentID, 3)
 
 	// Assert
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "failed to persist document")
-
+	assertDomainErrorCode(t, err, domainerrors.ErrCodeConflict)
 	repo.AssertExpectations(t)
 }
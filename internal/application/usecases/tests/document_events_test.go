@@ -0,0 +1,107 @@
+package usecases
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+func testDocuments() []*models.Document {
+	return []*models.Document{
+		{
+			ID:                   "doc-1",
+			OwnerID:              42,
+			Filename:             "a.pdf",
+			MimeType:             "application/pdf",
+			SizeBytes:            10,
+			HashSHA256:           "hash-1",
+			AuthenticationStatus: models.AuthenticationStatusAuthenticated,
+			CreatedAt:            time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{ID: "doc-2", OwnerID: 42, Filename: "b.pdf"},
+	}
+}
+
+// decodeMessage checks that an outbox message is pending and decodes its payload as eventType
+func decodeMessage[T any](t *testing.T, message *models.OutboxMessage, eventType string) *T {
+	t.Helper()
+	assert.Equal(t, models.OutboxPending, message.Pending)
+
+	envelope, err := message.Event()
+	require.NoError(t, err)
+	assert.Equal(t, message.ID, envelope.ID)
+	payload, err := events.DecodeAs[T](events.NewDefaultRegistry(), eventType, envelope)
+	require.NoError(t, err)
+	return payload
+}
+
+func TestDocumentEvents_Uploaded(t *testing.T) {
+	messages, err := usecases.NewDocumentEvents().Uploaded(testDocuments()[:1])
+
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	event := decodeMessage[events.DocumentUploadedEvent](t, messages[0], events.TypeDocumentUploaded)
+	assert.Equal(t, events.DocumentUploadedEvent{
+		DocumentID: "doc-1",
+		IDCitizen:  42,
+		Filename:   "a.pdf",
+		MimeType:   "application/pdf",
+		SizeBytes:  10,
+		HashSHA256: "hash-1",
+		UploadedAt: "2025-01-02T03:04:05Z",
+	}, *event)
+}
+
+func TestDocumentEvents_Deleted(t *testing.T) {
+	messages, err := usecases.NewDocumentEvents().Deleted(testDocuments())
+
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.NotEqual(t, messages[0].ID, messages[1].ID)
+	event := decodeMessage[events.DocumentDeletedEvent](t, messages[1], events.TypeDocumentDeleted)
+	assert.Equal(t, events.DocumentDeletedEvent{DocumentID: "doc-2", IDCitizen: 42, Filename: "b.pdf"}, *event)
+}
+
+func TestDocumentEvents_Restored(t *testing.T) {
+	messages, err := usecases.NewDocumentEvents().Restored(testDocuments()[:1])
+
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	event := decodeMessage[events.DocumentRestoredEvent](t, messages[0], events.TypeDocumentRestored)
+	assert.Equal(t, events.DocumentRestoredEvent{DocumentID: "doc-1", IDCitizen: 42, Filename: "a.pdf"}, *event)
+}
+
+func TestDocumentEvents_BulkDeleted(t *testing.T) {
+	builder := usecases.NewDocumentEvents()
+
+	messages, err := builder.BulkDeleted(testDocuments())
+
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	event := decodeMessage[events.DocumentsBulkDeletedEvent](t, messages[0], events.TypeDocumentsBulkDeleted)
+	assert.Equal(t, int64(42), event.IDCitizen)
+	assert.Equal(t, []string{"doc-1", "doc-2"}, event.DocumentIDs)
+
+	t.Run("no documents", func(t *testing.T) {
+		messages, err := builder.BulkDeleted(nil)
+		assert.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+}
+
+func TestDocumentEvents_AuthenticationStatusChanged(t *testing.T) {
+	messages, err := usecases.NewDocumentEvents().AuthenticationStatusChanged(testDocuments()[:1])
+
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	event := decodeMessage[events.DocumentAuthenticationStatusChangedEvent](t, messages[0], events.TypeDocumentAuthenticationStatusChanged)
+	assert.Equal(t, "doc-1", event.DocumentID)
+	assert.Equal(t, int64(42), event.IDCitizen)
+	assert.Equal(t, string(models.AuthenticationStatusAuthenticated), event.Status)
+}
@@ -67,6 +67,26 @@ func TestDocumentGetService_GetByID_DocumentNotFound(t *testing.T) {
 	repo.AssertExpectations(t)
 }
 
+func TestDocumentGetService_GetByID_DocumentInTrash(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	mockStorage := new(MockObjectStorage)
+	service := usecases.NewDocumentGetService(repo, mockStorage)
+
+	ctx := context.Background()
+	deletedAt := time.Now()
+	repo.On("GetByID", ctx, "doc-123").Return(&models.Document{ID: "doc-123", DeletedAt: &deletedAt}, nil)
+
+	// Act
+	result, err := service.GetByID(ctx, "doc-123")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "not found")
+	mockStorage.AssertNotCalled(t, "GeneratePresignedURL", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestDocumentGetService_GetByID_RepositoryError(t *testing.T) {
 	// Arrange
 	repo := new(MockDocumentRepository)
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	"github.com/kristianrpo/document-management-microservice/internal/application/util"
+	domainerrors "github.com/kristianrpo/document-management-microservice/internal/domain/errors"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -14,7 +16,7 @@ import (
 func TestDocumentListService_List_Success(t *testing.T) {
 	// Arrange
 	repo := new(MockDocumentRepository)
-	service := usecases.NewDocumentListService(repo)
+	service := usecases.NewDocumentListService(repo, util.NewCursorCodec([]byte("test-key")))
 
 	ctx := context.Background()
 	ownerID := int64(1)
@@ -63,7 +65,7 @@ func TestDocumentListService_List_Success(t *testing.T) {
 func TestDocumentListService_List_EmptyList(t *testing.T) {
 	// Arrange
 	repo := new(MockDocumentRepository)
-	service := usecases.NewDocumentListService(repo)
+	service := usecases.NewDocumentListService(repo, util.NewCursorCodec([]byte("test-key")))
 
 	ctx := context.Background()
 	ownerID := int64(1)
@@ -89,7 +91,7 @@ func TestDocumentListService_List_EmptyList(t *testing.T) {
 func TestDocumentListService_List_SecondPage(t *testing.T) {
 	// Arrange
 	repo := new(MockDocumentRepository)
-	service := usecases.NewDocumentListService(repo)
+	service := usecases.NewDocumentListService(repo, util.NewCursorCodec([]byte("test-key")))
 
 	ctx := context.Background()
 	ownerID := int64(1)
@@ -122,7 +124,7 @@ func TestDocumentListService_List_SecondPage(t *testing.T) {
 func TestDocumentListService_List_RepositoryError(t *testing.T) {
 	// Arrange
 	repo := new(MockDocumentRepository)
-	service := usecases.NewDocumentListService(repo)
+	service := usecases.NewDocumentListService(repo, util.NewCursorCodec([]byte("test-key")))
 
 	ctx := context.Background()
 	ownerID := int64(1)
@@ -145,3 +147,157 @@ func TestDocumentListService_List_RepositoryError(t *testing.T) {
 
 	repo.AssertExpectations(t)
 }
+
+var newestFirst = models.DocumentSort{Field: models.DocumentSortCreatedAt, Order: models.SortOrderDesc}
+
+func TestDocumentListService_ListPage_FollowsCursors(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	cursors := util.NewCursorCodec([]byte("test-key"))
+	service := usecases.NewDocumentListService(repo, cursors)
+
+	ctx := context.Background()
+	ownerID := int64(1)
+
+	firstPage := []*models.Document{{ID: "doc-1", OwnerID: ownerID}, {ID: "doc-2", OwnerID: ownerID}}
+	lastPage := []*models.Document{{ID: "doc-3", OwnerID: ownerID}}
+	repo.On("ListPage", ctx, ownerID, newestFirst, models.DocumentFilter{}, 2, "").Return(firstPage, "after-doc-2", nil)
+	repo.On("ListPage", ctx, ownerID, newestFirst, models.DocumentFilter{}, 2, "after-doc-2").Return(lastPage, "", nil)
+
+	// Act
+	first, err := service.ListPage(ctx, ownerID, usecases.DocumentListQuery{Limit: 2})
+	assert.NoError(t, err)
+	last, err := service.ListPage(ctx, ownerID, usecases.DocumentListQuery{Limit: 2, Cursor: first.NextCursor})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, firstPage, first.Documents)
+	assert.NotEmpty(t, first.NextCursor)
+	assert.NotContains(t, first.NextCursor, "after-doc-2", "cursors are opaque")
+	assert.Nil(t, first.TotalItems)
+	assert.Equal(t, lastPage, last.Documents)
+	assert.Empty(t, last.NextCursor)
+
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "CountByOwner", ctx, ownerID, models.DocumentFilter{})
+}
+
+func TestDocumentListService_ListPage_IncludeTotal(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	service := usecases.NewDocumentListService(repo, util.NewCursorCodec([]byte("test-key")))
+
+	ctx := context.Background()
+	ownerID := int64(1)
+
+	repo.On("ListPage", ctx, ownerID, newestFirst, models.DocumentFilter{}, 10, "").Return([]*models.Document{}, "", nil)
+	repo.On("CountByOwner", ctx, ownerID, models.DocumentFilter{}).Return(int64(0), nil)
+
+	// Act
+	page, err := service.ListPage(ctx, ownerID, usecases.DocumentListQuery{IncludeTotal: true})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 10, page.Limit)
+	if assert.NotNil(t, page.TotalItems) {
+		assert.Equal(t, int64(0), *page.TotalItems)
+	}
+
+	repo.AssertExpectations(t)
+}
+
+func TestDocumentListService_ListPage_RejectsForeignCursors(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	cursors := util.NewCursorCodec([]byte("test-key"))
+	service := usecases.NewDocumentListService(repo, cursors)
+
+	ctx := context.Background()
+	othersCursor := cursors.Encode(2, "after-doc-2")
+
+	// Act
+	page, err := service.ListPage(ctx, 1, usecases.DocumentListQuery{Limit: 10, Cursor: othersCursor})
+
+	// Assert
+	assert.Nil(t, page)
+	assertDomainErrorCode(t, err, domainerrors.ErrCodeValidation)
+	repo.AssertNotCalled(t, "ListPage", ctx, int64(1), newestFirst, models.DocumentFilter{}, 10, "after-doc-2")
+}
+
+func TestDocumentListService_ListPage_Sort(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	service := usecases.NewDocumentListService(repo, util.NewCursorCodec([]byte("test-key")))
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	byFilename := models.DocumentSort{Field: models.DocumentSortFilename, Order: models.SortOrderAsc}
+
+	repo.On("ListPage", ctx, ownerID, byFilename, models.DocumentFilter{}, 10, "").Return([]*models.Document{{ID: "doc-1"}}, "after-doc-1", nil)
+
+	// Act
+	page, err := service.ListPage(ctx, ownerID, usecases.DocumentListQuery{Sort: models.DocumentSort{Field: models.DocumentSortFilename}, Limit: 10})
+	assert.NoError(t, err)
+	_, otherSortErr := service.ListPage(ctx, ownerID, usecases.DocumentListQuery{Limit: 10, Cursor: page.NextCursor})
+	_, invalidErr := service.ListPage(ctx, ownerID, usecases.DocumentListQuery{Sort: models.DocumentSort{Field: "owner"}, Limit: 10})
+
+	// Assert
+	assertDomainErrorCode(t, otherSortErr, domainerrors.ErrCodeValidation)
+	assertDomainErrorCode(t, invalidErr, domainerrors.ErrCodeValidation)
+	repo.AssertExpectations(t)
+}
+
+func TestDocumentListService_ListPage_RejectsCursorsOfOtherFilters(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	service := usecases.NewDocumentListService(repo, util.NewCursorCodec([]byte("test-key")))
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	images := models.DocumentFilter{MimeFamily: "image"}
+
+	repo.On("ListPage", ctx, ownerID, newestFirst, images, 10, "").Return([]*models.Document{{ID: "doc-1"}}, "after-doc-1", nil)
+	repo.On("ListPage", ctx, ownerID, newestFirst, images, 10, "after-doc-1").Return([]*models.Document{}, "", nil)
+
+	// Act
+	page, err := service.ListPage(ctx, ownerID, usecases.DocumentListQuery{Filter: images, Limit: 10})
+	assert.NoError(t, err)
+	_, sameErr := service.ListPage(ctx, ownerID, usecases.DocumentListQuery{Filter: images, Limit: 10, Cursor: page.NextCursor})
+	_, otherFilterErr := service.ListPage(ctx, ownerID, usecases.DocumentListQuery{Filter: models.DocumentFilter{MimeFamily: "text"}, Limit: 10, Cursor: page.NextCursor})
+	_, trashErr := service.ListPage(ctx, ownerID, usecases.DocumentListQuery{Filter: models.DocumentFilter{MimeFamily: "image", Trashed: true}, Limit: 10, Cursor: page.NextCursor})
+
+	// Assert
+	assert.NoError(t, sameErr)
+	assertDomainErrorCode(t, otherFilterErr, domainerrors.ErrCodeValidation)
+	assertDomainErrorCode(t, trashErr, domainerrors.ErrCodeValidation)
+	repo.AssertExpectations(t)
+	repo.AssertNumberOfCalls(t, "ListPage", 2)
+}
+
+func TestDocumentListService_ListPage_Filter(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	service := usecases.NewDocumentListService(repo, util.NewCursorCodec([]byte("test-key")))
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	images := models.DocumentFilter{MimeFamily: "image", AuthenticationStatus: models.AuthenticationStatusAuthenticated}
+
+	repo.On("ListPage", ctx, ownerID, newestFirst, images, 10, "").Return([]*models.Document{{ID: "doc-1"}}, "", nil)
+	repo.On("CountByOwner", ctx, ownerID, images).Return(int64(1), nil)
+
+	// Act
+	page, err := service.ListPage(ctx, ownerID, usecases.DocumentListQuery{Filter: images, Limit: 10, IncludeTotal: true})
+	_, invalidErr := service.ListPage(ctx, ownerID, usecases.DocumentListQuery{
+		Filter: models.DocumentFilter{CreatedFrom: time.Now(), CreatedTo: time.Now().Add(-time.Hour)},
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, page.Documents, 1)
+	if assert.NotNil(t, page.TotalItems) {
+		assert.Equal(t, int64(1), *page.TotalItems)
+	}
+	assertDomainErrorCode(t, invalidErr, domainerrors.ErrCodeValidation)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,190 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func expiredDocument(id, objectKey string, version int64) *models.Document {
+	deletedAt := time.Now().Add(-31 * 24 * time.Hour)
+	purgeAt := time.Now().Add(-time.Hour)
+	return &models.Document{ID: id, OwnerID: 1, ObjectKey: objectKey, Version: version, DeletedAt: &deletedAt, PurgeAt: &purgeAt}
+}
+
+func TestDocumentPurgeService_PurgeExpired_DeletesDocumentsAndObjects(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockObjectStorage)
+	blobRefs := new(MockBlobReferenceRepository)
+
+	service := usecases.NewDocumentPurgeService(repo, storage, blobRefs, time.Hour)
+
+	ctx := context.Background()
+	own := expiredDocument("doc-1", "ab/own.pdf", 2)
+	shared := expiredDocument("doc-2", "cd/shared.pdf", 4)
+
+	repo.On("ListExpired", ctx, mock.AnythingOfType("time.Time"), 25, "").Return([]*models.Document{own, shared}, "", nil)
+	repo.On("DeleteByID", ctx, "doc-1", int64(2), mock.Anything).Return(own, nil)
+	repo.On("DeleteByID", ctx, "doc-2", int64(4), mock.Anything).Return(shared, nil)
+	blobRefs.On("ReleaseIfUnreferenced", ctx, "ab/own.pdf").Return(true, nil)
+	// another citizen uploaded the same bytes, so the object is still referenced
+	blobRefs.On("ReleaseIfUnreferenced", ctx, "cd/shared.pdf").Return(false, nil)
+	storage.On("Delete", ctx, "ab/own.pdf").Return(nil)
+	blobRefs.On("FinishRelease", ctx, "ab/own.pdf").Return(nil)
+	blobRefs.On("ListUnreferenced", ctx, 25).Return([]string{}, nil)
+
+	// Act
+	purged, err := service.PurgeExpired(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+
+	repo.AssertExpectations(t)
+	blobRefs.AssertExpectations(t)
+	storage.AssertExpectations(t)
+	storage.AssertNotCalled(t, "Delete", ctx, "cd/shared.pdf")
+}
+
+func TestDocumentPurgeService_PurgeExpired_KeepsRestoredDocuments(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockObjectStorage)
+	blobRefs := new(MockBlobReferenceRepository)
+
+	service := usecases.NewDocumentPurgeService(repo, storage, blobRefs, time.Hour)
+
+	ctx := context.Background()
+	restored := expiredDocument("doc-1", "ab/own.pdf", 2)
+
+	repo.On("ListExpired", ctx, mock.Anything, 25, "").Return([]*models.Document{restored}, "", nil)
+	repo.On("DeleteByID", ctx, "doc-1", int64(2), mock.Anything).Return(nil, interfaces.ErrVersionConflict)
+	blobRefs.On("ListUnreferenced", ctx, 25).Return([]string{}, nil)
+
+	// Act
+	purged, err := service.PurgeExpired(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 0, purged)
+
+	blobRefs.AssertNotCalled(t, "ReleaseIfUnreferenced", mock.Anything, mock.Anything)
+	storage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestDocumentPurgeService_PurgeExpired_StorageErrorsAreSkipped(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockObjectStorage)
+	blobRefs := new(MockBlobReferenceRepository)
+
+	service := usecases.NewDocumentPurgeService(repo, storage, blobRefs, time.Hour)
+
+	ctx := context.Background()
+	first := expiredDocument("doc-1", "ab/first.pdf", 2)
+	second := expiredDocument("doc-2", "cd/second.pdf", 2)
+
+	repo.On("ListExpired", ctx, mock.Anything, 25, "").Return([]*models.Document{first, second}, "", nil)
+	repo.On("DeleteByID", ctx, "doc-1", int64(2), mock.Anything).Return(first, nil)
+	repo.On("DeleteByID", ctx, "doc-2", int64(2), mock.Anything).Return(second, nil)
+	blobRefs.On("ReleaseIfUnreferenced", ctx, "ab/first.pdf").Return(false, errors.New("dynamodb unavailable"))
+	blobRefs.On("ReleaseIfUnreferenced", ctx, "cd/second.pdf").Return(true, nil)
+	storage.On("Delete", ctx, "cd/second.pdf").Return(errors.New("storage delete failed"))
+	blobRefs.On("ListUnreferenced", ctx, 25).Return([]string{}, nil)
+
+	// Act
+	purged, err := service.PurgeExpired(ctx)
+
+	// Assert
+	assert.NoError(t, err, "the metadata is already deleted, the objects are released again on a later pass")
+	assert.Equal(t, 2, purged)
+
+	storage.AssertNotCalled(t, "Delete", ctx, "ab/first.pdf")
+	blobRefs.AssertNotCalled(t, "FinishRelease", mock.Anything, mock.Anything)
+}
+
+func TestDocumentPurgeService_PurgeExpired_RetriesUnreferencedObjects(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockObjectStorage)
+	blobRefs := new(MockBlobReferenceRepository)
+
+	service := usecases.NewDocumentPurgeService(repo, storage, blobRefs, time.Hour)
+
+	ctx := context.Background()
+	repo.On("ListExpired", ctx, mock.Anything, 25, "").Return([]*models.Document{}, "", nil)
+	// left behind by a pass whose storage delete failed
+	blobRefs.On("ListUnreferenced", ctx, 25).Return([]string{"ab/orphan.pdf"}, nil)
+	blobRefs.On("ReleaseIfUnreferenced", ctx, "ab/orphan.pdf").Return(true, nil)
+	storage.On("Delete", ctx, "ab/orphan.pdf").Return(nil)
+	blobRefs.On("FinishRelease", ctx, "ab/orphan.pdf").Return(nil)
+
+	// Act
+	purged, err := service.PurgeExpired(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 0, purged)
+	blobRefs.AssertExpectations(t)
+	storage.AssertExpectations(t)
+}
+
+func TestDocumentPurgeService_PurgeExpired_MovesPastFailingDocuments(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockObjectStorage)
+	blobRefs := new(MockBlobReferenceRepository)
+
+	service := usecases.NewDocumentPurgeService(repo, storage, blobRefs, time.Hour)
+
+	ctx := context.Background()
+	failing := expiredDocument("doc-1", "ab/failing.pdf", 2)
+	next := expiredDocument("doc-2", "cd/next.pdf", 2)
+
+	repo.On("ListExpired", ctx, mock.Anything, 25, "").Return([]*models.Document{failing}, "after-doc-1", nil).Once()
+	repo.On("ListExpired", ctx, mock.Anything, 25, "after-doc-1").Return([]*models.Document{next}, "", nil).Once()
+	repo.On("ListExpired", ctx, mock.Anything, 25, "").Return([]*models.Document{failing}, "after-doc-1", nil).Once()
+	repo.On("DeleteByID", ctx, "doc-1", int64(2), mock.Anything).Return(nil, errors.New("database error"))
+	repo.On("DeleteByID", ctx, "doc-2", int64(2), mock.Anything).Return(next, nil)
+	blobRefs.On("ReleaseIfUnreferenced", ctx, "cd/next.pdf").Return(false, nil)
+	blobRefs.On("ListUnreferenced", ctx, 25).Return([]string{}, nil)
+
+	// Act
+	first, err := service.PurgeExpired(ctx)
+	assert.NoError(t, err)
+	second, err := service.PurgeExpired(ctx)
+	assert.NoError(t, err)
+	_, err = service.PurgeExpired(ctx)
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, 0, first)
+	assert.Equal(t, 1, second, "the document behind the failing one is purged on the next pass")
+	repo.AssertExpectations(t)
+	repo.AssertNumberOfCalls(t, "DeleteByID", 3)
+}
+
+func TestDocumentPurgeService_PurgeExpired_ListError(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+
+	service := usecases.NewDocumentPurgeService(repo, new(MockObjectStorage), new(MockBlobReferenceRepository), time.Hour)
+
+	ctx := context.Background()
+	repo.On("ListExpired", ctx, mock.Anything, 25, "").Return(nil, "", errors.New("database error"))
+
+	// Act
+	purged, err := service.PurgeExpired(ctx)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Equal(t, 0, purged)
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
 	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
 	domainErrors "github.com/kristianrpo/document-management-microservice/internal/domain/errors"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
@@ -18,15 +19,12 @@ import (
 func TestNewDocumentRequestAuthenticationService(t *testing.T) {
 	mockRepo := new(MockDocumentRepository)
 	mockStorage := new(MockObjectStorage)
-	mockPublisher := new(MockMessagePublisher)
 
 	t.Run("creates service with custom expiration", func(t *testing.T) {
 		expiration := 12 * time.Hour
 		service := usecases.NewDocumentRequestAuthenticationService(
 			mockRepo,
 			mockStorage,
-			mockPublisher,
-			"test-queue",
 			expiration,
 		)
 		assert.NotNil(t, service)
@@ -36,8 +34,6 @@ func TestNewDocumentRequestAuthenticationService(t *testing.T) {
 		service := usecases.NewDocumentRequestAuthenticationService(
 			mockRepo,
 			mockStorage,
-			mockPublisher,
-			"test-queue",
 			0,
 		)
 		assert.NotNil(t, service)
@@ -47,13 +43,10 @@ func TestNewDocumentRequestAuthenticationService(t *testing.T) {
 func TestRequestAuthentication_Success(t *testing.T) {
 	mockRepo := new(MockDocumentRepository)
 	mockStorage := new(MockObjectStorage)
-	mockPublisher := new(MockMessagePublisher)
 
 	service := usecases.NewDocumentRequestAuthenticationService(
 		mockRepo,
 		mockStorage,
-		mockPublisher,
-		"auth-queue",
 		24*time.Hour,
 	)
 
@@ -68,13 +61,26 @@ func TestRequestAuthentication_Success(t *testing.T) {
 	presignedURL := "https://s3.amazonaws.com/presigned-url"
 
 	mockRepo.On("GetByID", ctx, documentID).Return(document, nil)
-	mockRepo.On("UpdateAuthenticationStatus", ctx, documentID, models.AuthenticationStatusAuthenticating).Return(nil)
 	mockStorage.On("GeneratePresignedURL", ctx, document.ObjectKey, 24*time.Hour).Return(presignedURL, nil)
-	mockPublisher.On("Publish", ctx, "auth-queue", mock.AnythingOfType("[]uint8")).Return(nil).Run(func(args mock.Arguments) {
-		eventJSON := args.Get(2).([]byte)
+	mockRepo.On("UpdateAuthenticationStatus", ctx, documentID, int64(0), models.AuthenticationStatusAuthenticating, mock.AnythingOfType("interfaces.OutboxFunc")).Return(nil).Run(func(args mock.Arguments) {
+		messages, err := args.Get(4).(interfaces.OutboxFunc)([]*models.Document{document})
+		assert.NoError(t, err)
+		if !assert.Len(t, messages, 1) {
+			return
+		}
+		assert.Equal(t, models.OutboxPending, messages[0].Pending)
+
+		envelope, err := messages[0].Event()
+		assert.NoError(t, err)
+		assert.Equal(t, events.TypeDocumentAuthenticationRequested, envelope.Type)
+		assert.Equal(t, events.SpecVersion, envelope.SpecVersion)
+		assert.Equal(t, events.SchemaURI(events.TypeDocumentAuthenticationRequested, events.DocumentAuthenticationRequestedVersion), envelope.DataSchema)
+
 		var event events.DocumentAuthenticationRequestedEvent
-		err := json.Unmarshal(eventJSON, &event)
+		err = json.Unmarshal(envelope.Data, &event)
 		assert.NoError(t, err)
+		assert.Equal(t, envelope.ID, event.MessageID, "the envelope reuses the payload message ID")
+		assert.Equal(t, messages[0].ID, envelope.ID, "the outbox message reuses the event ID")
 		assert.NotEmpty(t, event.MessageID, "MessageID should be set")
 		assert.Contains(t, event.MessageID, documentID)
 		assert.Equal(t, document.OwnerID, event.IDCitizen)
@@ -83,24 +89,20 @@ func TestRequestAuthentication_Success(t *testing.T) {
 		assert.Equal(t, documentID, event.DocumentID)
 	})
 
-	err := service.RequestAuthentication(ctx, documentID)
+	err := service.RequestAuthentication(ctx, documentID, 0)
 
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
 	mockStorage.AssertExpectations(t)
-	mockPublisher.AssertExpectations(t)
 }
 
 func TestRequestAuthentication_DocumentNotFound(t *testing.T) {
 	mockRepo := new(MockDocumentRepository)
 	mockStorage := new(MockObjectStorage)
-	mockPublisher := new(MockMessagePublisher)
 
 	service := usecases.NewDocumentRequestAuthenticationService(
 		mockRepo,
 		mockStorage,
-		mockPublisher,
-		"auth-queue",
 		24*time.Hour,
 	)
 
@@ -109,7 +111,7 @@ func TestRequestAuthentication_DocumentNotFound(t *testing.T) {
 
 	mockRepo.On("GetByID", ctx, documentID).Return(nil, nil)
 
-	err := service.RequestAuthentication(ctx, documentID)
+	err := service.RequestAuthentication(ctx, documentID, 0)
 
 	// Expect a DomainError with NOT_FOUND code
 	assert.Error(t, err)
@@ -123,13 +125,10 @@ func TestRequestAuthentication_DocumentNotFound(t *testing.T) {
 func TestRequestAuthentication_RepositoryGetError(t *testing.T) {
 	mockRepo := new(MockDocumentRepository)
 	mockStorage := new(MockObjectStorage)
-	mockPublisher := new(MockMessagePublisher)
 
 	service := usecases.NewDocumentRequestAuthenticationService(
 		mockRepo,
 		mockStorage,
-		mockPublisher,
-		"auth-queue",
 		24*time.Hour,
 	)
 
@@ -139,7 +138,7 @@ func TestRequestAuthentication_RepositoryGetError(t *testing.T) {
 
 	mockRepo.On("GetByID", ctx, documentID).Return(nil, expectedError)
 
-	err := service.RequestAuthentication(ctx, documentID)
+	err := service.RequestAuthentication(ctx, documentID, 0)
 
 	assert.Error(t, err)
 	assert.Equal(t, expectedError, err)
@@ -149,13 +148,10 @@ func TestRequestAuthentication_RepositoryGetError(t *testing.T) {
 func TestRequestAuthentication_UpdateStatusError(t *testing.T) {
 	mockRepo := new(MockDocumentRepository)
 	mockStorage := new(MockObjectStorage)
-	mockPublisher := new(MockMessagePublisher)
 
 	service := usecases.NewDocumentRequestAuthenticationService(
 		mockRepo,
 		mockStorage,
-		mockPublisher,
-		"auth-queue",
 		24*time.Hour,
 	)
 
@@ -167,28 +163,28 @@ func TestRequestAuthentication_UpdateStatusError(t *testing.T) {
 		Filename:  "test.pdf",
 		ObjectKey: "documents/test.pdf",
 	}
-	expectedError := errors.New("update failed")
+	expectedError := errors.New("transaction canceled")
 
 	mockRepo.On("GetByID", ctx, documentID).Return(document, nil)
-	mockRepo.On("UpdateAuthenticationStatus", ctx, documentID, models.AuthenticationStatusAuthenticating).Return(expectedError)
+	mockStorage.On("GeneratePresignedURL", ctx, document.ObjectKey, 24*time.Hour).Return("https://s3.amazonaws.com/presigned-url", nil)
+	mockRepo.On("UpdateAuthenticationStatus", ctx, documentID, int64(0), models.AuthenticationStatusAuthenticating, mock.Anything).Return(expectedError)
 
-	err := service.RequestAuthentication(ctx, documentID)
+	err := service.RequestAuthentication(ctx, documentID, 0)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to update authentication status")
+	assert.ErrorIs(t, err, expectedError)
 	mockRepo.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
 }
 
 func TestRequestAuthentication_PresignedURLError(t *testing.T) {
 	mockRepo := new(MockDocumentRepository)
 	mockStorage := new(MockObjectStorage)
-	mockPublisher := new(MockMessagePublisher)
 
 	service := usecases.NewDocumentRequestAuthenticationService(
 		mockRepo,
 		mockStorage,
-		mockPublisher,
-		"auth-queue",
 		24*time.Hour,
 	)
 
@@ -203,27 +199,24 @@ func TestRequestAuthentication_PresignedURLError(t *testing.T) {
 	expectedError := errors.New("S3 error")
 
 	mockRepo.On("GetByID", ctx, documentID).Return(document, nil)
-	mockRepo.On("UpdateAuthenticationStatus", ctx, documentID, models.AuthenticationStatusAuthenticating).Return(nil)
 	mockStorage.On("GeneratePresignedURL", ctx, document.ObjectKey, 24*time.Hour).Return("", expectedError)
 
-	err := service.RequestAuthentication(ctx, documentID)
+	err := service.RequestAuthentication(ctx, documentID, 0)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to generate pre-signed URL")
 	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "UpdateAuthenticationStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 	mockStorage.AssertExpectations(t)
 }
 
-func TestRequestAuthentication_PublishError(t *testing.T) {
+func TestRequestAuthentication_VersionConflict(t *testing.T) {
 	mockRepo := new(MockDocumentRepository)
 	mockStorage := new(MockObjectStorage)
-	mockPublisher := new(MockMessagePublisher)
 
 	service := usecases.NewDocumentRequestAuthenticationService(
 		mockRepo,
 		mockStorage,
-		mockPublisher,
-		"auth-queue",
 		24*time.Hour,
 	)
 
@@ -234,20 +227,48 @@ func TestRequestAuthentication_PublishError(t *testing.T) {
 		OwnerID:   12345,
 		Filename:  "test.pdf",
 		ObjectKey: "documents/test.pdf",
+		Version:   4,
 	}
-	presignedURL := "https://s3.amazonaws.com/presigned-url"
-	expectedError := errors.New("RabbitMQ connection error")
 
 	mockRepo.On("GetByID", ctx, documentID).Return(document, nil)
-	mockRepo.On("UpdateAuthenticationStatus", ctx, documentID, models.AuthenticationStatusAuthenticating).Return(nil)
-	mockStorage.On("GeneratePresignedURL", ctx, document.ObjectKey, 24*time.Hour).Return(presignedURL, nil)
-	mockPublisher.On("Publish", ctx, "auth-queue", mock.AnythingOfType("[]uint8")).Return(expectedError)
+	mockStorage.On("GeneratePresignedURL", ctx, document.ObjectKey, 24*time.Hour).Return("https://s3.amazonaws.com/presigned-url", nil)
+	mockRepo.On("UpdateAuthenticationStatus", ctx, documentID, int64(4), models.AuthenticationStatusAuthenticating, mock.Anything).Return(interfaces.ErrVersionConflict)
 
-	err := service.RequestAuthentication(ctx, documentID)
+	staleErr := service.RequestAuthentication(ctx, documentID, 3)
+	concurrentErr := service.RequestAuthentication(ctx, documentID, 4)
 
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "failed to publish authentication request event")
+	assertDomainErrorCode(t, staleErr, domainErrors.ErrCodeConflict)
+	assertDomainErrorCode(t, concurrentErr, domainErrors.ErrCodeConflict)
+	mockRepo.AssertNumberOfCalls(t, "UpdateAuthenticationStatus", 1)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRequestAuthentication_DocumentDeletedConcurrently(t *testing.T) {
+	mockRepo := new(MockDocumentRepository)
+	mockStorage := new(MockObjectStorage)
+
+	service := usecases.NewDocumentRequestAuthenticationService(
+		mockRepo,
+		mockStorage,
+		24*time.Hour,
+	)
+
+	ctx := context.Background()
+	documentID := "doc-123"
+	document := &models.Document{
+		ID:        documentID,
+		OwnerID:   12345,
+		Filename:  "test.pdf",
+		ObjectKey: "documents/test.pdf",
+		Version:   4,
+	}
+
+	mockRepo.On("GetByID", ctx, documentID).Return(document, nil)
+	mockStorage.On("GeneratePresignedURL", ctx, document.ObjectKey, 24*time.Hour).Return("https://s3.amazonaws.com/presigned-url", nil)
+	mockRepo.On("UpdateAuthenticationStatus", ctx, documentID, int64(4), models.AuthenticationStatusAuthenticating, mock.Anything).Return(interfaces.ErrDocumentNotFound)
+
+	err := service.RequestAuthentication(ctx, documentID, 0)
+
+	assertDomainErrorCode(t, err, domainErrors.ErrCodeNotFound)
 	mockRepo.AssertExpectations(t)
-	mockStorage.AssertExpectations(t)
-	mockPublisher.AssertExpectations(t)
 }
@@ -0,0 +1,128 @@
+package usecases
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	domainerrors "github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func trashedDocument(id string, ownerID int64) *models.Document {
+	deletedAt := time.Now().Add(-time.Hour)
+	purgeAt := time.Now().Add(24 * time.Hour)
+	return &models.Document{ID: id, OwnerID: ownerID, Filename: "test.pdf", Version: 2, DeletedAt: &deletedAt, PurgeAt: &purgeAt}
+}
+
+func TestDocumentRestoreService_Restore_Success(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+
+	service := usecases.NewDocumentRestoreService(repo, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	restored := &models.Document{ID: "doc-123", OwnerID: 1, Filename: "test.pdf", Version: 3}
+
+	var eventTypes []string
+	repo.On("GetByID", ctx, "doc-123").Return(trashedDocument("doc-123", 1), nil)
+	repo.On("Restore", ctx, "doc-123", int64(2), models.Quota{}, mock.AnythingOfType("interfaces.OutboxFunc")).Return(restored, nil).Run(func(args mock.Arguments) {
+		messages, err := args.Get(4).(interfaces.OutboxFunc)([]*models.Document{restored})
+		assert.NoError(t, err)
+		for _, message := range messages {
+			envelope, err := message.Event()
+			assert.NoError(t, err)
+			eventTypes = append(eventTypes, envelope.Type)
+		}
+	})
+
+	// Act
+	document, err := service.Restore(ctx, 1, "doc-123", 2)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, restored, document)
+	assert.Equal(t, []string{events.TypeDocumentRestored}, eventTypes)
+
+	repo.AssertExpectations(t)
+}
+
+func TestDocumentRestoreService_Restore_NotInTrash(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+
+	service := usecases.NewDocumentRestoreService(repo, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	repo.On("GetByID", ctx, "doc-123").Return(&models.Document{ID: "doc-123", OwnerID: 1}, nil)
+	repo.On("GetByID", ctx, "purged").Return(nil, nil)
+
+	// Act
+	_, liveErr := service.Restore(ctx, 1, "doc-123", 0)
+	_, purgedErr := service.Restore(ctx, 1, "purged", 0)
+
+	// Assert
+	assertDomainErrorCode(t, liveErr, domainerrors.ErrCodeNotFound)
+	assertDomainErrorCode(t, purgedErr, domainerrors.ErrCodeNotFound)
+	repo.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDocumentRestoreService_Restore_AnotherOwner(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+
+	service := usecases.NewDocumentRestoreService(repo, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	repo.On("GetByID", ctx, "doc-123").Return(trashedDocument("doc-123", 2), nil)
+
+	// Act
+	_, err := service.Restore(ctx, 1, "doc-123", 0)
+
+	// Assert
+	assertDomainErrorCode(t, err, domainerrors.ErrCodeValidation)
+	repo.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDocumentRestoreService_Restore_VersionConflict(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+
+	service := usecases.NewDocumentRestoreService(repo, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	repo.On("GetByID", ctx, "doc-123").Return(trashedDocument("doc-123", 1), nil)
+	repo.On("Restore", ctx, "doc-123", int64(1), models.Quota{}, mock.Anything).Return(nil, interfaces.ErrVersionConflict)
+
+	// Act
+	_, err := service.Restore(ctx, 1, "doc-123", 1)
+
+	// Assert
+	assertDomainErrorCode(t, err, domainerrors.ErrCodeConflict)
+}
+
+func TestDocumentRestoreService_Restore_QuotaExceeded(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+
+	quota := models.Quota{MaxDocuments: 2}
+	service := usecases.NewDocumentRestoreService(repo, usecases.NewDocumentEvents(), models.QuotaPolicy{Default: quota})
+
+	ctx := context.Background()
+	repo.On("GetByID", ctx, "doc-123").Return(trashedDocument("doc-123", 1), nil)
+	repo.On("Restore", ctx, "doc-123", int64(2), quota, mock.Anything).Return(nil, interfaces.ErrQuotaExceeded)
+	repo.On("GetUsage", ctx, int64(1)).Return(&models.OwnerUsage{OwnerID: 1, DocumentCount: 2}, nil)
+
+	// Act
+	_, err := service.Restore(ctx, 1, "doc-123", 2)
+
+	// Assert
+	assertDomainErrorCode(t, err, domainerrors.ErrCodeQuotaExceeded)
+	assert.ErrorIs(t, err, domainerrors.ErrDocumentQuotaExceeded)
+	repo.AssertExpectations(t)
+}
@@ -3,11 +3,19 @@ package usecases
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"io"
 	"mime/multipart"
 	"testing"
+	"time"
 
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
 	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	"github.com/kristianrpo/document-management-microservice/internal/application/util"
+	domainerrors "github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -20,7 +28,7 @@ func TestDocumentUploadService_Execute_Success(t *testing.T) {
 	hasher := new(MockFileHasher)
 	mimeDetector := new(MockMimeDetector)
 
-	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector)
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
 
 	ctx := context.Background()
 	ownerID := int64(1)
@@ -37,18 +45,29 @@ func TestDocumentUploadService_Execute_Success(t *testing.T) {
 	storage.On("Bucket").Return(bucketName)
 	storage.On("Put", ctx, mock.Anything, mock.AnythingOfType("string"), mimeType).Return(nil)
 	storage.On("PublicURL", mock.AnythingOfType("string")).Return(publicURL)
-	repo.On("Create", ctx, mock.AnythingOfType("*models.Document")).Return(nil)
+	var eventTypes []string
+	repo.On("Create", ctx, mock.AnythingOfType("*models.Document"), models.Quota{}, mock.AnythingOfType("interfaces.OutboxFunc")).Return(nil).Run(func(args mock.Arguments) {
+		messages, err := args.Get(3).(interfaces.OutboxFunc)([]*models.Document{args.Get(1).(*models.Document)})
+		assert.NoError(t, err)
+		for _, message := range messages {
+			envelope, err := message.Event()
+			assert.NoError(t, err)
+			eventTypes = append(eventTypes, envelope.Type)
+		}
+	})
 
 	// Act
-	result, err := service.Upload(ctx, file, ownerID)
+	result, created, err := service.Upload(ctx, file, ownerID)
 
 	// Assert
 	assert.NoError(t, err)
+	assert.True(t, created)
 	assert.NotNil(t, result)
 	assert.Equal(t, ownerID, result.OwnerID)
 	assert.Equal(t, "test.pdf", result.Filename)
 	assert.Equal(t, mimeType, result.MimeType)
 	assert.Equal(t, publicURL, result.URL)
+	assert.Equal(t, []string{events.TypeDocumentUploaded}, eventTypes)
 
 	repo.AssertExpectations(t)
 	storage.AssertExpectations(t)
@@ -63,7 +82,7 @@ func TestDocumentUploadService_Execute_HashError(t *testing.T) {
 	hasher := new(MockFileHasher)
 	mimeDetector := new(MockMimeDetector)
 
-	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector)
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
 
 	ctx := context.Background()
 	ownerID := int64(1)
@@ -72,7 +91,7 @@ func TestDocumentUploadService_Execute_HashError(t *testing.T) {
 	hasher.On("CalculateHash", mock.Anything).Return("", expectedError)
 
 	// Act
-	result, err := service.Upload(ctx, file, ownerID)
+	result, _, err := service.Upload(ctx, file, ownerID)
 
 	// Assert
 	assert.Error(t, err)
@@ -89,7 +108,7 @@ func TestDocumentUploadService_Execute_DefaultMimeTypeWhenUnknown(t *testing.T)
 	hasher := new(MockFileHasher)
 	mimeDetector := new(MockMimeDetector)
 
-	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector)
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
 
 	ctx := context.Background()
 	ownerID := int64(1)
@@ -101,13 +120,14 @@ func TestDocumentUploadService_Execute_DefaultMimeTypeWhenUnknown(t *testing.T)
 	storage.On("Bucket").Return("bucket")
 	storage.On("Put", ctx, mock.Anything, mock.AnythingOfType("string"), "application/octet-stream").Return(nil)
 	storage.On("PublicURL", mock.AnythingOfType("string")).Return("https://example.com/object")
-	repo.On("Create", ctx, mock.AnythingOfType("*models.Document")).Return(nil)
+	repo.On("Create", ctx, mock.AnythingOfType("*models.Document"), models.Quota{}, mock.Anything).Return(nil)
 
 	// Act
-	result, err := service.Upload(ctx, file, ownerID)
+	result, created, err := service.Upload(ctx, file, ownerID)
 
 	// Assert
 	assert.NoError(t, err)
+	assert.True(t, created)
 	assert.NotNil(t, result)
 	assert.Equal(t, "application/octet-stream", result.MimeType)
 
@@ -122,7 +142,7 @@ func TestDocumentUploadService_Execute_DuplicateDocument(t *testing.T) {
 	hasher := new(MockFileHasher)
 	mimeDetector := new(MockMimeDetector)
 
-	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector)
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
 
 	ctx := context.Background()
 	ownerID := int64(1)
@@ -135,10 +155,11 @@ func TestDocumentUploadService_Execute_DuplicateDocument(t *testing.T) {
 	repo.On("FindByHashAndOwnerID", ctx, hash, ownerID).Return(existingDoc, nil)
 
 	// Act
-	result, err := service.Upload(ctx, file, ownerID)
+	result, created, err := service.Upload(ctx, file, ownerID)
 
 	// Assert
 	assert.NoError(t, err)
+	assert.False(t, created)
 	assert.Equal(t, existingDoc, result)
 
 	repo.AssertExpectations(t)
@@ -146,6 +167,168 @@ func TestDocumentUploadService_Execute_DuplicateDocument(t *testing.T) {
 	mimeDetector.AssertExpectations(t)
 }
 
+func TestDocumentUploadService_Execute_DuplicateInTrashIsRestored(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockObjectStorage)
+	hasher := new(MockFileHasher)
+	mimeDetector := new(MockMimeDetector)
+
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	file := newMultipartFileHeader("test.pdf", []byte("test content"))
+	hash := "abcd1234"
+
+	deletedAt := time.Now().Add(-time.Hour)
+	trashedDoc := &models.Document{ID: "existing-id", Filename: "existing.pdf", OwnerID: ownerID, Version: 2, DeletedAt: &deletedAt}
+	restoredDoc := &models.Document{ID: "existing-id", Filename: "existing.pdf", OwnerID: ownerID, Version: 3}
+	hasher.On("CalculateHash", mock.Anything).Return(hash, nil)
+	repo.On("FindByHashAndOwnerID", ctx, hash, ownerID).Return(trashedDoc, nil)
+	repo.On("Restore", ctx, "existing-id", int64(2), models.Quota{}, mock.Anything).Return(restoredDoc, nil)
+
+	// Act
+	result, created, err := service.Upload(ctx, file, ownerID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, restoredDoc, result)
+
+	repo.AssertExpectations(t)
+	storage.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDocumentUploadService_Execute_ConcurrentDuplicateReturnsExisting(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockObjectStorage)
+	hasher := new(MockFileHasher)
+	mimeDetector := new(MockMimeDetector)
+
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	file := newMultipartFileHeader("test.pdf", []byte("test content"))
+	hash := "a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9"
+	existingDoc := &models.Document{ID: "existing-id", Filename: "existing.pdf", OwnerID: ownerID, HashSHA256: hash}
+
+	hasher.On("CalculateHash", mock.Anything).Return(hash, nil)
+	mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	// The concurrent upload is stored between the lookup and the write
+	repo.On("FindByHashAndOwnerID", ctx, hash, ownerID).Return(nil, nil).Once()
+	storage.On("Bucket").Return("test-bucket")
+	storage.On("Put", ctx, mock.Anything, mock.AnythingOfType("string"), "application/pdf").Return(nil)
+	storage.On("PublicURL", mock.AnythingOfType("string")).Return("https://s3.amazonaws.com/test/doc.pdf")
+	repo.On("Create", ctx, mock.AnythingOfType("*models.Document"), models.Quota{}, mock.Anything).Return(interfaces.ErrDuplicateDocument)
+	repo.On("FindByHashAndOwnerID", ctx, hash, ownerID).Return(existingDoc, nil).Once()
+
+	// Act
+	result, created, err := service.Upload(ctx, file, ownerID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, existingDoc, result)
+
+	repo.AssertExpectations(t)
+	storage.AssertExpectations(t)
+}
+
+func TestDocumentUploadService_Execute_ConcurrentDuplicateDeleted(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockObjectStorage)
+	hasher := new(MockFileHasher)
+	mimeDetector := new(MockMimeDetector)
+
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	file := newMultipartFileHeader("test.pdf", []byte("test content"))
+	hash := "a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9"
+
+	hasher.On("CalculateHash", mock.Anything).Return(hash, nil)
+	mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	repo.On("FindByHashAndOwnerID", ctx, hash, ownerID).Return(nil, nil)
+	storage.On("Bucket").Return("test-bucket")
+	storage.On("Put", ctx, mock.Anything, mock.AnythingOfType("string"), "application/pdf").Return(nil)
+	storage.On("PublicURL", mock.AnythingOfType("string")).Return("https://s3.amazonaws.com/test/doc.pdf")
+	repo.On("Create", ctx, mock.AnythingOfType("*models.Document"), models.Quota{}, mock.Anything).Return(interfaces.ErrDuplicateDocument)
+
+	// Act
+	result, _, err := service.Upload(ctx, file, ownerID)
+
+	// Assert
+	assert.Nil(t, result)
+	assertDomainErrorCode(t, err, domainerrors.ErrCodeConflict)
+
+	repo.AssertExpectations(t)
+}
+
+func TestDocumentUploadService_Execute_ObjectBeingDeleted(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockObjectStorage)
+	hasher := new(MockFileHasher)
+	mimeDetector := new(MockMimeDetector)
+
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	file := newMultipartFileHeader("test.pdf", []byte("test content"))
+	hash := "a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9"
+
+	hasher.On("CalculateHash", mock.Anything).Return(hash, nil)
+	mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	repo.On("FindByHashAndOwnerID", ctx, hash, ownerID).Return(nil, nil).Once()
+	storage.On("Bucket").Return("test-bucket")
+	storage.On("Put", ctx, mock.Anything, mock.AnythingOfType("string"), "application/pdf").Return(nil)
+	storage.On("PublicURL", mock.AnythingOfType("string")).Return("https://s3.amazonaws.com/test/doc.pdf")
+	repo.On("Create", ctx, mock.AnythingOfType("*models.Document"), models.Quota{}, mock.Anything).Return(interfaces.ErrObjectReleasing)
+
+	// Act
+	result, _, err := service.Upload(ctx, file, ownerID)
+
+	// Assert
+	assert.Nil(t, result)
+	assertDomainErrorCode(t, err, domainerrors.ErrCodeConflict)
+
+	repo.AssertExpectations(t)
+}
+
+func TestDocumentUploadService_Execute_FindError(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockObjectStorage)
+	hasher := new(MockFileHasher)
+	mimeDetector := new(MockMimeDetector)
+
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	file := newMultipartFileHeader("test.pdf", []byte("test content"))
+	hash := "abcd1234"
+
+	hasher.On("CalculateHash", mock.Anything).Return(hash, nil)
+	repo.On("FindByHashAndOwnerID", ctx, hash, ownerID).Return(nil, errors.New("throttled"))
+
+	// Act
+	result, _, err := service.Upload(ctx, file, ownerID)
+
+	// Assert
+	assert.Nil(t, result)
+	assertDomainErrorCode(t, err, domainerrors.ErrCodePersistence)
+
+	repo.AssertExpectations(t)
+	storage.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestDocumentUploadService_Execute_StorageError(t *testing.T) {
 	// Arrange
 	repo := new(MockDocumentRepository)
@@ -153,7 +336,7 @@ func TestDocumentUploadService_Execute_StorageError(t *testing.T) {
 	hasher := new(MockFileHasher)
 	mimeDetector := new(MockMimeDetector)
 
-	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector)
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
 
 	ctx := context.Background()
 	ownerID := int64(1)
@@ -168,7 +351,7 @@ func TestDocumentUploadService_Execute_StorageError(t *testing.T) {
 	storage.On("Put", ctx, mock.Anything, mock.AnythingOfType("string"), "application/pdf").Return(expectedError)
 
 	// Act
-	result, err := service.Upload(ctx, file, ownerID)
+	result, _, err := service.Upload(ctx, file, ownerID)
 
 	// Assert
 	assert.Error(t, err)
@@ -188,7 +371,7 @@ func TestDocumentUploadService_Execute_RepositoryCreateError(t *testing.T) {
 	hasher := new(MockFileHasher)
 	mimeDetector := new(MockMimeDetector)
 
-	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector)
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
 
 	ctx := context.Background()
 	ownerID := int64(1)
@@ -203,10 +386,10 @@ func TestDocumentUploadService_Execute_RepositoryCreateError(t *testing.T) {
 	storage.On("PublicURL", mock.AnythingOfType("string")).Return("https://s3.amazonaws.com/test/doc.pdf")
 
 	expectedError := errors.New("database error")
-	repo.On("Create", ctx, mock.AnythingOfType("*models.Document")).Return(expectedError)
+	repo.On("Create", ctx, mock.AnythingOfType("*models.Document"), models.Quota{}, mock.Anything).Return(expectedError)
 
 	// Act
-	result, err := service.Upload(ctx, file, ownerID)
+	result, _, err := service.Upload(ctx, file, ownerID)
 
 	// Assert
 	assert.Error(t, err)
@@ -219,6 +402,262 @@ func TestDocumentUploadService_Execute_RepositoryCreateError(t *testing.T) {
 	mimeDetector.AssertExpectations(t)
 }
 
+func TestDocumentUploadService_Streaming_Success(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockStagingObjectStorage)
+	mimeDetector := new(MockMimeDetector)
+
+	// The real hasher proves the digest is computed from the same bytes that are streamed
+	service := usecases.NewDocumentService(repo, storage, util.NewSHA256Hasher(), mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	fileContent := []byte("streamed content")
+	file := newMultipartFileHeader("test.pdf", fileContent)
+	sum := sha256.Sum256(fileContent)
+	hash := hex.EncodeToString(sum[:])
+	objectKey := util.ObjectKeyFromHash(hash, "test.pdf")
+
+	var streamed []byte
+	mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	storage.On("PutStaged", ctx, mock.Anything, "application/pdf").
+		Run(func(args mock.Arguments) { streamed, _ = io.ReadAll(args.Get(1).(io.Reader)) }).
+		Return("staging/abc", int64(len(fileContent)), nil)
+	repo.On("FindByHashAndOwnerID", ctx, hash, ownerID).Return(nil, nil)
+	storage.On("Bucket").Return("test-bucket")
+	storage.On("PublicURL", objectKey).Return("https://s3.amazonaws.com/test-bucket/" + objectKey)
+	storage.On("PromoteStaged", ctx, "staging/abc", objectKey, "application/pdf").Return(nil)
+	repo.On("Create", ctx, mock.AnythingOfType("*models.Document"), models.Quota{}, mock.Anything).Return(nil)
+
+	// Act
+	result, created, err := service.Upload(ctx, file, ownerID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.NotNil(t, result)
+	assert.Equal(t, fileContent, streamed)
+	assert.Equal(t, hash, result.HashSHA256)
+	assert.Equal(t, objectKey, result.ObjectKey)
+	assert.Equal(t, int64(len(fileContent)), result.SizeBytes)
+
+	repo.AssertExpectations(t)
+	storage.AssertExpectations(t)
+	storage.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDocumentUploadService_Streaming_DuplicateDiscardsStagedObject(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockStagingObjectStorage)
+	hasher := new(MockFileHasher)
+	mimeDetector := new(MockMimeDetector)
+
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	file := newMultipartFileHeader("test.pdf", []byte("test content"))
+	hash := "abcd1234"
+	existingDoc := &models.Document{ID: "existing-id", Filename: "existing.pdf", OwnerID: ownerID}
+
+	hasher.On("CalculateHash", mock.Anything).Return(hash, nil)
+	mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	storage.On("PutStaged", ctx, mock.Anything, "application/pdf").
+		Run(func(args mock.Arguments) { _, _ = io.Copy(io.Discard, args.Get(1).(io.Reader)) }).
+		Return("staging/abc", int64(12), nil)
+	repo.On("FindByHashAndOwnerID", ctx, hash, ownerID).Return(existingDoc, nil)
+	storage.On("DiscardStaged", ctx, "staging/abc").Return(nil)
+
+	// Act
+	result, created, err := service.Upload(ctx, file, ownerID)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, existingDoc, result)
+
+	repo.AssertExpectations(t)
+	storage.AssertExpectations(t)
+	storage.AssertNotCalled(t, "PromoteStaged", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDocumentUploadService_Streaming_StagingError(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockStagingObjectStorage)
+	hasher := new(MockFileHasher)
+	mimeDetector := new(MockMimeDetector)
+
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	file := newMultipartFileHeader("test.pdf", []byte("test content"))
+
+	hasher.On("CalculateHash", mock.Anything).Return("", errors.New("closed pipe"))
+	mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	storage.On("PutStaged", ctx, mock.Anything, "application/pdf").Return("", int64(0), errors.New("multipart failed"))
+
+	// Act
+	result, _, err := service.Upload(ctx, file, 1)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "failed to upload to storage")
+
+	storage.AssertExpectations(t)
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDocumentUploadService_Streaming_PromoteError(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockStagingObjectStorage)
+	hasher := new(MockFileHasher)
+	mimeDetector := new(MockMimeDetector)
+
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	file := newMultipartFileHeader("test.pdf", []byte("test content"))
+	hash := "a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9"
+
+	hasher.On("CalculateHash", mock.Anything).Return(hash, nil)
+	mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	storage.On("PutStaged", ctx, mock.Anything, "application/pdf").Return("staging/abc", int64(12), nil)
+	repo.On("FindByHashAndOwnerID", ctx, hash, ownerID).Return(nil, nil)
+	storage.On("Bucket").Return("test-bucket")
+	storage.On("PublicURL", mock.AnythingOfType("string")).Return("https://s3.amazonaws.com/test/doc.pdf")
+	repo.On("Create", ctx, mock.AnythingOfType("*models.Document"), models.Quota{}, mock.Anything).
+		Run(func(args mock.Arguments) {
+			document := args.Get(1).(*models.Document)
+			document.ID = "doc-1"
+			document.Version = 1
+		}).
+		Return(nil)
+	storage.On("PromoteStaged", ctx, "staging/abc", mock.AnythingOfType("string"), "application/pdf").Return(errors.New("copy failed"))
+	storage.On("DiscardStaged", ctx, "staging/abc").Return(nil)
+	repo.On("DeleteByID", ctx, "doc-1", int64(1), mock.Anything).Return(&models.Document{ID: "doc-1"}, nil)
+
+	// Act
+	result, _, err := service.Upload(ctx, file, ownerID)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "failed to upload to storage")
+
+	storage.AssertExpectations(t)
+	repo.AssertExpectations(t)
+}
+
+func TestDocumentUploadService_Streaming_RejectedDocumentIsNeverPromoted(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockStagingObjectStorage)
+	hasher := new(MockFileHasher)
+	mimeDetector := new(MockMimeDetector)
+
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	file := newMultipartFileHeader("test.pdf", []byte("test content"))
+	hash := "a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9"
+
+	hasher.On("CalculateHash", mock.Anything).Return(hash, nil)
+	mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	storage.On("PutStaged", ctx, mock.Anything, "application/pdf").Return("staging/abc", int64(12), nil)
+	repo.On("FindByHashAndOwnerID", ctx, hash, ownerID).Return(nil, nil)
+	storage.On("Bucket").Return("test-bucket")
+	storage.On("PublicURL", mock.AnythingOfType("string")).Return("https://s3.amazonaws.com/test/doc.pdf")
+	repo.On("Create", ctx, mock.AnythingOfType("*models.Document"), models.Quota{}, mock.Anything).Return(errors.New("database unavailable"))
+	storage.On("DiscardStaged", ctx, "staging/abc").Return(nil)
+
+	// Act
+	result, _, err := service.Upload(ctx, file, ownerID)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+
+	storage.AssertExpectations(t)
+	storage.AssertNotCalled(t, "PromoteStaged", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDocumentUploadService_Execute_QuotaExceededBeforeStoring(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockObjectStorage)
+	hasher := new(MockFileHasher)
+	mimeDetector := new(MockMimeDetector)
+
+	quotas := models.QuotaPolicy{Default: models.Quota{MaxDocuments: 10, MaxBytes: 100}}
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), quotas)
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	file := newMultipartFileHeader("test.pdf", []byte("test content"))
+	hash := "a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9"
+
+	hasher.On("CalculateHash", mock.Anything).Return(hash, nil)
+	repo.On("FindByHashAndOwnerID", ctx, hash, ownerID).Return(nil, nil)
+	repo.On("GetUsage", ctx, ownerID).Return(&models.OwnerUsage{OwnerID: ownerID, DocumentCount: 3, TotalBytes: 95}, nil)
+
+	// Act
+	result, _, err := service.Upload(ctx, file, ownerID)
+
+	// Assert
+	assert.Nil(t, result)
+	var domainErr *domainerrors.DomainError
+	assert.True(t, errors.As(err, &domainErr))
+	assert.Equal(t, domainerrors.ErrCodeQuotaExceeded, domainErr.Code)
+	assert.ErrorIs(t, err, domainerrors.ErrStorageQuotaExceeded)
+
+	repo.AssertExpectations(t)
+	storage.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDocumentUploadService_Execute_QuotaUsedUpConcurrently(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockObjectStorage)
+	hasher := new(MockFileHasher)
+	mimeDetector := new(MockMimeDetector)
+
+	quota := models.Quota{MaxDocuments: 2}
+	service := usecases.NewDocumentService(repo, storage, hasher, mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{Default: quota})
+
+	ctx := context.Background()
+	ownerID := int64(1)
+	file := newMultipartFileHeader("test.pdf", []byte("test content"))
+	hash := "a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9"
+
+	hasher.On("CalculateHash", mock.Anything).Return(hash, nil)
+	mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	repo.On("FindByHashAndOwnerID", ctx, hash, ownerID).Return(nil, nil)
+	repo.On("GetUsage", ctx, ownerID).Return(&models.OwnerUsage{OwnerID: ownerID, DocumentCount: 1}, nil).Once()
+	storage.On("Bucket").Return("test-bucket")
+	storage.On("Put", ctx, mock.Anything, mock.AnythingOfType("string"), "application/pdf").Return(nil)
+	storage.On("PublicURL", mock.AnythingOfType("string")).Return("https://s3.amazonaws.com/test/doc.pdf")
+	repo.On("Create", ctx, mock.AnythingOfType("*models.Document"), quota, mock.Anything).Return(interfaces.ErrQuotaExceeded)
+	repo.On("GetUsage", ctx, ownerID).Return(&models.OwnerUsage{OwnerID: ownerID, DocumentCount: 2}, nil).Once()
+
+	// Act
+	result, _, err := service.Upload(ctx, file, ownerID)
+
+	// Assert
+	assert.Nil(t, result)
+	assert.ErrorIs(t, err, domainerrors.ErrDocumentQuotaExceeded)
+
+	repo.AssertExpectations(t)
+	storage.AssertExpectations(t)
+}
+
 // helper to build a multipart.FileHeader with content
 func newMultipartFileHeader(filename string, content []byte) *multipart.FileHeader {
 	b := &bytes.Buffer{}
@@ -231,3 +670,27 @@ func newMultipartFileHeader(filename string, content []byte) *multipart.FileHead
 	form, _ := r.ReadForm(int64(len(content) + 1024))
 	return form.File["file"][0]
 }
+
+func TestDocumentUploadService_CompleteStagedUpload_KeepsObjectOnRetryableError(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	storage := new(MockStagingObjectStorage)
+	mimeDetector := new(MockMimeDetector)
+
+	service := usecases.NewDocumentService(repo, storage, new(MockFileHasher), mimeDetector, usecases.NewDocumentEvents(), models.QuotaPolicy{})
+	uploader := service.(interfaces.StagedDocumentUploader)
+
+	ctx := context.Background()
+	hash := "a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9a3f1b0f9"
+
+	mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	repo.On("FindByHashAndOwnerID", ctx, hash, int64(1)).Return(nil, errors.New("database unavailable"))
+
+	// Act
+	result, _, err := uploader.CompleteStagedUpload(ctx, "staging/abc", hash, "test.pdf", 12, 1)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	storage.AssertNotCalled(t, "DiscardStaged", mock.Anything, mock.Anything)
+}
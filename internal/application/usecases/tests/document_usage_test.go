@@ -0,0 +1,53 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	domainerrors "github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentUsageService_GetUsage_Success(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	quotas := models.QuotaPolicy{
+		Default:   models.Quota{MaxDocuments: 10, MaxBytes: 100},
+		Overrides: map[int64]models.Quota{2: {MaxBytes: 1000}},
+	}
+	service := usecases.NewDocumentUsageService(repo, quotas)
+
+	ctx := context.Background()
+	usage := &models.OwnerUsage{OwnerID: 2, DocumentCount: 4, TotalBytes: 512}
+	repo.On("GetUsage", ctx, int64(2)).Return(usage, nil)
+
+	// Act
+	result, quota, err := service.GetUsage(ctx, 2)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, usage, result)
+	assert.Equal(t, models.Quota{MaxBytes: 1000}, quota, "the override of the owner applies")
+	repo.AssertExpectations(t)
+}
+
+func TestDocumentUsageService_GetUsage_RepositoryError(t *testing.T) {
+	// Arrange
+	repo := new(MockDocumentRepository)
+	service := usecases.NewDocumentUsageService(repo, models.QuotaPolicy{})
+
+	ctx := context.Background()
+	repo.On("GetUsage", ctx, int64(1)).Return(nil, errors.New("database error"))
+
+	// Act
+	result, _, err := service.GetUsage(ctx, 1)
+
+	// Assert
+	assert.Nil(t, result)
+	var domainErr *domainerrors.DomainError
+	assert.True(t, errors.As(err, &domainErr))
+	assert.Equal(t, domainerrors.ErrCodePersistence, domainErr.Code)
+}
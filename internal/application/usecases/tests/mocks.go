@@ -5,6 +5,7 @@ import (
 	"io"
 	"time"
 
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
 	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -14,8 +15,8 @@ type MockDocumentRepository struct {
 	mock.Mock
 }
 
-func (m *MockDocumentRepository) Create(ctx context.Context, doc *models.Document) error {
-	args := m.Called(ctx, doc)
+func (m *MockDocumentRepository) Create(ctx context.Context, doc *models.Document, quota models.Quota, outbox interfaces.OutboxFunc) error {
+	args := m.Called(ctx, doc, quota, outbox)
 	return args.Error(0)
 }
 
@@ -43,21 +44,66 @@ func (m *MockDocumentRepository) List(ctx context.Context, ownerID int64, limit,
 	return args.Get(0).([]*models.Document), args.Get(1).(int64), args.Error(2)
 }
 
-func (m *MockDocumentRepository) DeleteByID(ctx context.Context, id string) (*models.Document, error) {
-	args := m.Called(ctx, id)
+func (m *MockDocumentRepository) ListPage(ctx context.Context, ownerID int64, sort models.DocumentSort, filter models.DocumentFilter, limit int, position string) ([]*models.Document, string, error) {
+	args := m.Called(ctx, ownerID, sort, filter, limit, position)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).([]*models.Document), args.String(1), args.Error(2)
+}
+
+func (m *MockDocumentRepository) CountByOwner(ctx context.Context, ownerID int64, filter models.DocumentFilter) (int64, error) {
+	args := m.Called(ctx, ownerID, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockDocumentRepository) GetUsage(ctx context.Context, ownerID int64) (*models.OwnerUsage, error) {
+	args := m.Called(ctx, ownerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.OwnerUsage), args.Error(1)
+}
+
+func (m *MockDocumentRepository) DeleteByID(ctx context.Context, id string, expectedVersion int64, outbox interfaces.OutboxFunc) (*models.Document, error) {
+	args := m.Called(ctx, id, expectedVersion, outbox)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.Document), args.Error(1)
 }
 
-func (m *MockDocumentRepository) DeleteAllByOwnerID(ctx context.Context, ownerID int64) (int, error) {
-	args := m.Called(ctx, ownerID)
+func (m *MockDocumentRepository) Trash(ctx context.Context, id string, expectedVersion int64, purgeAt time.Time, outbox interfaces.OutboxFunc) (*models.Document, error) {
+	args := m.Called(ctx, id, expectedVersion, purgeAt, outbox)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Document), args.Error(1)
+}
+
+func (m *MockDocumentRepository) TrashAllByOwnerID(ctx context.Context, ownerID int64, purgeAt time.Time, outbox interfaces.OutboxFunc) (int, error) {
+	args := m.Called(ctx, ownerID, purgeAt, outbox)
 	return args.Int(0), args.Error(1)
 }
 
-func (m *MockDocumentRepository) UpdateAuthenticationStatus(ctx context.Context, documentID string, status models.AuthenticationStatus) error {
-	args := m.Called(ctx, documentID, status)
+func (m *MockDocumentRepository) Restore(ctx context.Context, id string, expectedVersion int64, quota models.Quota, outbox interfaces.OutboxFunc) (*models.Document, error) {
+	args := m.Called(ctx, id, expectedVersion, quota, outbox)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Document), args.Error(1)
+}
+
+func (m *MockDocumentRepository) ListExpired(ctx context.Context, before time.Time, limit int, position string) ([]*models.Document, string, error) {
+	args := m.Called(ctx, before, limit, position)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).([]*models.Document), args.String(1), args.Error(2)
+}
+
+func (m *MockDocumentRepository) UpdateAuthenticationStatus(ctx context.Context, documentID string, expectedVersion int64, status models.AuthenticationStatus, outbox interfaces.OutboxFunc) error {
+	args := m.Called(ctx, documentID, expectedVersion, status, outbox)
 	return args.Error(0)
 }
 
@@ -96,6 +142,139 @@ func (m *MockObjectStorage) GeneratePresignedURL(ctx context.Context, objectKey
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockObjectStorage) GeneratePresignedPut(ctx context.Context, objectKey string, policy interfaces.UploadPolicy) (*interfaces.PresignedUpload, error) {
+	args := m.Called(ctx, objectKey, policy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*interfaces.PresignedUpload), args.Error(1)
+}
+
+func (m *MockObjectStorage) GeneratePresignedPost(ctx context.Context, objectKey string, policy interfaces.UploadPolicy) (*interfaces.PresignedUpload, error) {
+	args := m.Called(ctx, objectKey, policy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*interfaces.PresignedUpload), args.Error(1)
+}
+
+func (m *MockObjectStorage) Stat(ctx context.Context, objectKey string) (*interfaces.ObjectInfo, error) {
+	args := m.Called(ctx, objectKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*interfaces.ObjectInfo), args.Error(1)
+}
+
+func (m *MockObjectStorage) Open(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	args := m.Called(ctx, objectKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+// MockStagingObjectStorage is a mock implementation of StagingObjectStorage
+type MockStagingObjectStorage struct {
+	MockObjectStorage
+}
+
+func (m *MockStagingObjectStorage) NewStagingKey() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *MockStagingObjectStorage) PutStaged(ctx context.Context, body io.Reader, contentType string) (string, int64, error) {
+	args := m.Called(ctx, body, contentType)
+	return args.String(0), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockStagingObjectStorage) PromoteStaged(ctx context.Context, stagingKey, objectKey, contentType string) error {
+	args := m.Called(ctx, stagingKey, objectKey, contentType)
+	return args.Error(0)
+}
+
+func (m *MockStagingObjectStorage) DiscardStaged(ctx context.Context, stagingKey string) error {
+	args := m.Called(ctx, stagingKey)
+	return args.Error(0)
+}
+
+// MockMultipartObjectStorage is a mock implementation of MultipartObjectStorage
+type MockMultipartObjectStorage struct {
+	MockStagingObjectStorage
+}
+
+func (m *MockMultipartObjectStorage) CreateStagedMultipart(ctx context.Context, contentType string) (string, string, error) {
+	args := m.Called(ctx, contentType)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *MockMultipartObjectStorage) UploadStagedPart(ctx context.Context, stagingKey, uploadID string, partNumber int32, body io.Reader, size int64) (string, error) {
+	args := m.Called(ctx, stagingKey, uploadID, partNumber, body, size)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockMultipartObjectStorage) CompleteStagedMultipart(ctx context.Context, stagingKey, uploadID string, parts []models.UploadPart) error {
+	args := m.Called(ctx, stagingKey, uploadID, parts)
+	return args.Error(0)
+}
+
+func (m *MockMultipartObjectStorage) AbortStagedMultipart(ctx context.Context, stagingKey, uploadID string) error {
+	args := m.Called(ctx, stagingKey, uploadID)
+	return args.Error(0)
+}
+
+// MockUploadSessionRepository is a mock implementation of UploadSessionRepository
+type MockUploadSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockUploadSessionRepository) Create(ctx context.Context, session *models.UploadSession) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (m *MockUploadSessionRepository) GetByID(ctx context.Context, id string) (*models.UploadSession, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.UploadSession), args.Error(1)
+}
+
+func (m *MockUploadSessionRepository) AcquireLease(ctx context.Context, id string, offset int64, leaseUntil time.Time) (bool, error) {
+	args := m.Called(ctx, id, offset, leaseUntil)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUploadSessionRepository) Save(ctx context.Context, session *models.UploadSession) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (m *MockUploadSessionRepository) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUploadSessionRepository) EnsureTableExists(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+// MockStagedDocumentUploader is a mock implementation of StagedDocumentUploader
+type MockStagedDocumentUploader struct {
+	mock.Mock
+}
+
+func (m *MockStagedDocumentUploader) CompleteStagedUpload(ctx context.Context, stagingKey, hash, filename string, size int64, ownerID int64) (*models.Document, bool, error) {
+	args := m.Called(ctx, stagingKey, hash, filename, size, ownerID)
+	if args.Get(0) == nil {
+		return nil, false, args.Error(2)
+	}
+	return args.Get(0).(*models.Document), args.Bool(1), args.Error(2)
+}
+
 // MockFileHasher is a mock implementation of FileHasher
 type MockFileHasher struct {
 	mock.Mock
@@ -130,3 +309,31 @@ func (m *MockMessagePublisher) Close() error {
 	args := m.Called()
 	return args.Error(0)
 }
+
+// MockBlobReferenceRepository is a mock implementation of BlobReferenceRepository
+type MockBlobReferenceRepository struct {
+	mock.Mock
+}
+
+func (m *MockBlobReferenceRepository) ReleaseIfUnreferenced(ctx context.Context, objectKey string) (bool, error) {
+	args := m.Called(ctx, objectKey)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBlobReferenceRepository) ListUnreferenced(ctx context.Context, limit int) ([]string, error) {
+	args := m.Called(ctx, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockBlobReferenceRepository) FinishRelease(ctx context.Context, objectKey string) error {
+	args := m.Called(ctx, objectKey)
+	return args.Error(0)
+}
+
+func (m *MockBlobReferenceRepository) RebuildReferenceCounts(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
@@ -0,0 +1,290 @@
+package usecases
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	"github.com/kristianrpo/document-management-microservice/internal/application/util"
+	domainerrors "github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type uploadSessionMocks struct {
+	sessions     *MockUploadSessionRepository
+	storage      *MockMultipartObjectStorage
+	uploader     *MockStagedDocumentUploader
+	mimeDetector *MockMimeDetector
+}
+
+func newUploadSessionService() (usecases.UploadSessionService, *uploadSessionMocks) {
+	mocks := &uploadSessionMocks{
+		sessions:     new(MockUploadSessionRepository),
+		storage:      new(MockMultipartObjectStorage),
+		uploader:     new(MockStagedDocumentUploader),
+		mimeDetector: new(MockMimeDetector),
+	}
+	service := usecases.NewUploadSessionService(mocks.sessions, mocks.storage, mocks.uploader, mocks.mimeDetector, time.Hour)
+	return service, mocks
+}
+
+func newTestUploadSession(offset, length int64) *models.UploadSession {
+	session := models.NewUploadSession(1, "test.pdf", "application/pdf", length, time.Hour)
+	session.ID = "upload-1"
+	session.Offset = offset
+	session.StagingKey = "staging/upload-1"
+	session.StorageUploadID = "s3-upload"
+	return session
+}
+
+func hashState(t *testing.T, data []byte) []byte {
+	hasher, _ := util.NewResumableSHA256(nil)
+	_, _ = hasher.Write(data)
+	state, err := util.MarshalHashState(hasher)
+	assert.NoError(t, err)
+	return state
+}
+
+func TestUploadSessionService_Create_Success(t *testing.T) {
+	// Arrange
+	service, mocks := newUploadSessionService()
+	ctx := context.Background()
+
+	mocks.mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	mocks.storage.On("CreateStagedMultipart", ctx, "application/pdf").Return("staging/abc", "s3-upload", nil)
+	mocks.sessions.On("Create", ctx, mock.AnythingOfType("*models.UploadSession")).Return(nil)
+
+	// Act
+	session, err := service.Create(ctx, 1, "test.pdf", 1024)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), session.Length)
+	assert.Equal(t, int64(0), session.Offset)
+	assert.Equal(t, "staging/abc", session.StagingKey)
+	assert.Equal(t, "s3-upload", session.StorageUploadID)
+	assert.Greater(t, session.ExpiresAt, time.Now().Unix())
+
+	mocks.storage.AssertExpectations(t)
+	mocks.sessions.AssertExpectations(t)
+}
+
+func TestUploadSessionService_Create_InvalidLength(t *testing.T) {
+	service, _ := newUploadSessionService()
+
+	for _, length := range []int64{0, -1, usecases.MaxUploadSessionLength + 1} {
+		session, err := service.Create(context.Background(), 1, "test.pdf", length)
+
+		assert.Error(t, err)
+		assert.Nil(t, session)
+	}
+}
+
+func TestUploadSessionService_Create_PersistenceErrorAbortsMultipart(t *testing.T) {
+	// Arrange
+	service, mocks := newUploadSessionService()
+	ctx := context.Background()
+
+	mocks.mimeDetector.On("DetectFromFilename", "test.pdf").Return("application/pdf")
+	mocks.storage.On("CreateStagedMultipart", ctx, "application/pdf").Return("staging/abc", "s3-upload", nil)
+	mocks.sessions.On("Create", ctx, mock.Anything).Return(errors.New("dynamo down"))
+	mocks.storage.On("AbortStagedMultipart", ctx, "staging/abc", "s3-upload").Return(nil)
+
+	// Act
+	session, err := service.Create(ctx, 1, "test.pdf", 1024)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, session)
+	mocks.storage.AssertExpectations(t)
+}
+
+func TestUploadSessionService_Get_OtherOwnerIsNotFound(t *testing.T) {
+	service, mocks := newUploadSessionService()
+	ctx := context.Background()
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(newTestUploadSession(0, 100), nil)
+
+	session, err := service.Get(ctx, "upload-1", 2)
+
+	assert.Nil(t, session)
+	var domainErr *domainerrors.DomainError
+	assert.True(t, errors.As(err, &domainErr))
+	assert.Equal(t, domainerrors.ErrCodeNotFound, domainErr.Code)
+}
+
+func TestUploadSessionService_AppendChunk_OffsetMismatch(t *testing.T) {
+	service, mocks := newUploadSessionService()
+	ctx := context.Background()
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(newTestUploadSession(10, 100), nil)
+
+	session, err := service.AppendChunk(ctx, "upload-1", 1, 0, bytes.NewReader([]byte("data")))
+
+	assert.Nil(t, session)
+	var domainErr *domainerrors.DomainError
+	assert.True(t, errors.As(err, &domainErr))
+	assert.Equal(t, domainerrors.ErrCodeUploadOffsetMismatch, domainErr.Code)
+	mocks.sessions.AssertNotCalled(t, "AcquireLease", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadSessionService_AppendChunk_ConcurrentWriter(t *testing.T) {
+	service, mocks := newUploadSessionService()
+	ctx := context.Background()
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(newTestUploadSession(0, 100), nil)
+	mocks.sessions.On("AcquireLease", ctx, "upload-1", int64(0), mock.Anything).Return(false, nil)
+
+	session, err := service.AppendChunk(ctx, "upload-1", 1, 0, bytes.NewReader([]byte("data")))
+
+	assert.Nil(t, session)
+	var domainErr *domainerrors.DomainError
+	assert.True(t, errors.As(err, &domainErr))
+	assert.Equal(t, domainerrors.ErrCodeUploadOffsetMismatch, domainErr.Code)
+	mocks.sessions.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+}
+
+func TestUploadSessionService_AppendChunk_SmallChunkIsKeptPending(t *testing.T) {
+	// Arrange
+	service, mocks := newUploadSessionService()
+	ctx := context.Background()
+	chunk := []byte("first ten!")
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(newTestUploadSession(0, 100), nil)
+	mocks.sessions.On("AcquireLease", ctx, "upload-1", int64(0), mock.Anything).Return(true, nil)
+	mocks.storage.On("Put", ctx, mock.Anything, "staging/upload-1.pending", "application/pdf").Return(nil)
+	mocks.sessions.On("Save", ctx, mock.AnythingOfType("*models.UploadSession")).Return(nil)
+
+	// Act
+	session, err := service.AppendChunk(ctx, "upload-1", 1, 0, bytes.NewReader(chunk))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), session.Offset)
+	assert.Equal(t, int64(10), session.PendingSize)
+	assert.Empty(t, session.Parts)
+	assert.Equal(t, hashState(t, chunk), session.HashState)
+	mocks.storage.AssertNotCalled(t, "UploadStagedPart", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mocks.sessions.AssertExpectations(t)
+}
+
+func TestUploadSessionService_AppendChunk_PendingBytesJoinTheLastPart(t *testing.T) {
+	// Arrange
+	service, mocks := newUploadSessionService()
+	ctx := context.Background()
+	pending := []byte("first ten!")
+	rest := bytes.Repeat([]byte("x"), 90)
+
+	existing := newTestUploadSession(10, 100)
+	existing.PendingSize = 10
+	existing.HashState = hashState(t, pending)
+
+	var uploaded []byte
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(existing, nil)
+	mocks.sessions.On("AcquireLease", ctx, "upload-1", int64(10), mock.Anything).Return(true, nil)
+	mocks.storage.On("Open", ctx, "staging/upload-1.pending").Return(io.NopCloser(bytes.NewReader(pending)), nil)
+	mocks.storage.On("UploadStagedPart", ctx, "staging/upload-1", "s3-upload", int32(1), mock.Anything, int64(100)).
+		Run(func(args mock.Arguments) { uploaded, _ = io.ReadAll(args.Get(4).(io.Reader)) }).
+		Return("etag-1", nil)
+	mocks.storage.On("Delete", ctx, "staging/upload-1.pending").Return(nil)
+	mocks.sessions.On("Save", ctx, mock.AnythingOfType("*models.UploadSession")).Return(nil)
+
+	// Act
+	session, err := service.AppendChunk(ctx, "upload-1", 1, 10, bytes.NewReader(rest))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, session.IsComplete())
+	assert.Equal(t, int64(0), session.PendingSize)
+	assert.Equal(t, []models.UploadPart{{Number: 1, ETag: "etag-1", Size: 100}}, session.Parts)
+	assert.Equal(t, append(append([]byte{}, pending...), rest...), uploaded)
+	assert.Equal(t, hashState(t, uploaded), session.HashState)
+	mocks.storage.AssertExpectations(t)
+}
+
+func TestUploadSessionService_AppendChunk_ExceedsLength(t *testing.T) {
+	service, mocks := newUploadSessionService()
+	ctx := context.Background()
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(newTestUploadSession(0, 5), nil)
+	mocks.sessions.On("AcquireLease", ctx, "upload-1", int64(0), mock.Anything).Return(true, nil)
+	mocks.sessions.On("Save", ctx, mock.Anything).Return(nil)
+
+	session, err := service.AppendChunk(ctx, "upload-1", 1, 0, bytes.NewReader([]byte("too many bytes")))
+
+	assert.Error(t, err)
+	assert.Nil(t, session)
+	// The lease is released even though nothing was written
+	mocks.sessions.AssertCalled(t, "Save", ctx, mock.Anything)
+}
+
+func TestUploadSessionService_Complete_Success(t *testing.T) {
+	// Arrange
+	service, mocks := newUploadSessionService()
+	ctx := context.Background()
+	content := []byte("complete content")
+	sum := sha256.Sum256(content)
+
+	existing := newTestUploadSession(int64(len(content)), int64(len(content)))
+	existing.HashState = hashState(t, content)
+	existing.Parts = []models.UploadPart{{Number: 1, ETag: "etag-1", Size: int64(len(content))}}
+	document := &models.Document{ID: "doc-1", Filename: "test.pdf", OwnerID: 1}
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(existing, nil)
+	mocks.sessions.On("AcquireLease", ctx, "upload-1", int64(len(content)), mock.Anything).Return(true, nil)
+	mocks.storage.On("CompleteStagedMultipart", ctx, "staging/upload-1", "s3-upload", existing.Parts).Return(nil)
+	mocks.uploader.On("CompleteStagedUpload", ctx, "staging/upload-1", hex.EncodeToString(sum[:]), "test.pdf", int64(len(content)), int64(1)).Return(document, true, nil)
+	mocks.sessions.On("Delete", ctx, "upload-1").Return(nil)
+
+	// Act
+	result, created, err := service.Complete(ctx, "upload-1", 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, document, result)
+	assert.True(t, created)
+	mocks.storage.AssertExpectations(t)
+	mocks.uploader.AssertExpectations(t)
+	mocks.sessions.AssertExpectations(t)
+}
+
+func TestUploadSessionService_Complete_Incomplete(t *testing.T) {
+	service, mocks := newUploadSessionService()
+	ctx := context.Background()
+
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(newTestUploadSession(10, 100), nil)
+
+	result, _, err := service.Complete(ctx, "upload-1", 1)
+
+	assert.Nil(t, result)
+	var domainErr *domainerrors.DomainError
+	assert.True(t, errors.As(err, &domainErr))
+	assert.Equal(t, domainerrors.ErrCodeUploadOffsetMismatch, domainErr.Code)
+	mocks.storage.AssertNotCalled(t, "CompleteStagedMultipart", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadSessionService_Complete_StorageErrorKeepsSession(t *testing.T) {
+	service, mocks := newUploadSessionService()
+	ctx := context.Background()
+
+	existing := newTestUploadSession(100, 100)
+	mocks.sessions.On("GetByID", ctx, "upload-1").Return(existing, nil)
+	mocks.sessions.On("AcquireLease", ctx, "upload-1", int64(100), mock.Anything).Return(true, nil)
+	mocks.storage.On("CompleteStagedMultipart", ctx, "staging/upload-1", "s3-upload", mock.Anything).Return(errors.New("s3 down"))
+	mocks.sessions.On("Save", ctx, existing).Return(nil)
+
+	result, _, err := service.Complete(ctx, "upload-1", 1)
+
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	mocks.sessions.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	mocks.uploader.AssertNotCalled(t, "CompleteStagedUpload", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,303 @@
+package usecases
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/application/util"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+const (
+	// uploadPartSize is the size of the buffer used to assemble storage parts from chunks
+	uploadPartSize = 8 << 20
+
+	// minUploadPartSize is the smallest part storage accepts except for the last one. Trailing
+	// bytes below this size are kept in a pending object until the next chunk completes them
+	minUploadPartSize = 5 << 20
+
+	// MaxUploadSessionLength is the largest file accepted by a resumable upload. It keeps the
+	// number of parts, and therefore the size of the session record, bounded
+	MaxUploadSessionLength = 10 << 30
+
+	// uploadLeaseDuration bounds how long a single chunk may take before another request can resume
+	uploadLeaseDuration = 10 * time.Minute
+)
+
+// UploadSessionService defines the interface for resumable (tus-style) upload operations
+type UploadSessionService interface {
+	Create(ctx context.Context, ownerID int64, filename string, length int64) (*models.UploadSession, error)
+	Get(ctx context.Context, id string, ownerID int64) (*models.UploadSession, error)
+	AppendChunk(ctx context.Context, id string, ownerID int64, offset int64, chunk io.Reader) (*models.UploadSession, error)
+	Complete(ctx context.Context, id string, ownerID int64) (document *models.Document, created bool, err error)
+}
+
+type uploadSessionService struct {
+	sessions     interfaces.UploadSessionRepository
+	storage      interfaces.MultipartObjectStorage
+	uploader     interfaces.StagedDocumentUploader
+	mimeDetector util.MimeTypeDetector
+	ttl          time.Duration
+}
+
+// NewUploadSessionService creates a new resumable upload service
+// Sessions that are not completed within ttl expire together with their staged parts
+func NewUploadSessionService(
+	sessions interfaces.UploadSessionRepository,
+	storage interfaces.MultipartObjectStorage,
+	uploader interfaces.StagedDocumentUploader,
+	mimeDetector util.MimeTypeDetector,
+	ttl time.Duration,
+) UploadSessionService {
+	return &uploadSessionService{
+		sessions:     sessions,
+		storage:      storage,
+		uploader:     uploader,
+		mimeDetector: mimeDetector,
+		ttl:          ttl,
+	}
+}
+
+// Create starts a resumable upload of length bytes and its backing multipart upload
+func (service *uploadSessionService) Create(ctx context.Context, ownerID int64, filename string, length int64) (*models.UploadSession, error) {
+	if strings.TrimSpace(filename) == "" {
+		return nil, errors.NewValidationError("filename is required")
+	}
+	if length <= 0 {
+		return nil, errors.NewValidationError("upload length must be greater than zero")
+	}
+	if length > MaxUploadSessionLength {
+		return nil, errors.NewValidationError(fmt.Sprintf("upload length cannot exceed %d bytes", int64(MaxUploadSessionLength)))
+	}
+
+	contentType := service.mimeDetector.DetectFromFilename(filename)
+	stagingKey, uploadID, err := service.storage.CreateStagedMultipart(ctx, contentType)
+	if err != nil {
+		return nil, errors.NewStorageUploadError(err)
+	}
+
+	session := models.NewUploadSession(ownerID, filename, contentType, length, service.ttl)
+	session.Kind = models.UploadKindChunked
+	session.StagingKey = stagingKey
+	session.StorageUploadID = uploadID
+
+	if err := service.sessions.Create(ctx, session); err != nil {
+		_ = service.storage.AbortStagedMultipart(ctx, stagingKey, uploadID)
+		return nil, errors.NewPersistenceError(err)
+	}
+
+	return session, nil
+}
+
+// Get returns the current state of an upload session owned by ownerID
+func (service *uploadSessionService) Get(ctx context.Context, id string, ownerID int64) (*models.UploadSession, error) {
+	return service.load(ctx, id, ownerID)
+}
+
+// AppendChunk writes a chunk starting at offset, which must match the bytes already received.
+// Progress is saved even if the chunk is interrupted, so the client can resume from the new offset
+func (service *uploadSessionService) AppendChunk(ctx context.Context, id string, ownerID int64, offset int64, chunk io.Reader) (*models.UploadSession, error) {
+	session, err := service.load(ctx, id, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if offset != session.Offset {
+		return nil, errors.NewUploadOffsetMismatchError(fmt.Sprintf("upload offset is %d", session.Offset))
+	}
+
+	if err := service.acquireLease(ctx, session); err != nil {
+		return nil, err
+	}
+
+	writeErr := service.writeChunk(ctx, session, chunk)
+	session.UpdatedAt = time.Now()
+	if err := service.sessions.Save(ctx, session); err != nil {
+		return nil, errors.NewPersistenceError(err)
+	}
+	if writeErr != nil {
+		return nil, writeErr
+	}
+
+	return session, nil
+}
+
+// Complete assembles the staged parts and creates the document through the regular
+// deduplication and validation path; created is false when the owner already had the content.
+// The session is removed afterwards
+func (service *uploadSessionService) Complete(ctx context.Context, id string, ownerID int64) (*models.Document, bool, error) {
+	session, err := service.load(ctx, id, ownerID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !session.IsComplete() {
+		return nil, false, errors.NewUploadOffsetMismatchError(fmt.Sprintf("upload is incomplete: %d of %d bytes received", session.Offset, session.Length))
+	}
+
+	hasher, err := util.NewResumableSHA256(session.HashState)
+	if err != nil {
+		return nil, false, errors.NewHashCalculateError(err)
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+
+	if err := service.acquireLease(ctx, session); err != nil {
+		return nil, false, err
+	}
+
+	if err := service.storage.CompleteStagedMultipart(ctx, session.StagingKey, session.StorageUploadID, session.Parts); err != nil {
+		// Release the lease so the client can retry the completion
+		_ = service.sessions.Save(ctx, session)
+		return nil, false, errors.NewStorageUploadError(err)
+	}
+
+	document, created, err := service.uploader.CompleteStagedUpload(ctx, session.StagingKey, hash, session.Filename, session.Length, ownerID)
+	if isRetryableUpload(err) {
+		// The assembled object cannot be completed again from the session
+		_ = service.storage.DiscardStaged(ctx, session.StagingKey)
+	}
+
+	// The staged object is consumed either way, so the session cannot be completed again
+	if derr := service.sessions.Delete(ctx, session.ID); derr != nil {
+		log.Printf("failed to delete upload session %s: %v", session.ID, derr)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return document, created, nil
+}
+
+// load retrieves a live chunked session owned by ownerID
+func (service *uploadSessionService) load(ctx context.Context, id string, ownerID int64) (*models.UploadSession, error) {
+	return loadUploadSession(ctx, service.sessions, id, ownerID, false)
+}
+
+// loadUploadSession retrieves a live session of the requested kind owned by ownerID.
+// Sessions of other owners or kinds are reported as missing
+func loadUploadSession(ctx context.Context, sessions interfaces.UploadSessionRepository, id string, ownerID int64, direct bool) (*models.UploadSession, error) {
+	session, err := sessions.GetByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewPersistenceError(err)
+	}
+	if session == nil || session.OwnerID != ownerID || session.IsDirect() != direct || session.IsExpired(time.Now()) {
+		return nil, errors.NewNotFoundError("upload session not found")
+	}
+	return session, nil
+}
+
+// acquireLease makes the caller the only writer of the session until it is saved
+func (service *uploadSessionService) acquireLease(ctx context.Context, session *models.UploadSession) error {
+	return acquireUploadLease(ctx, service.sessions, session)
+}
+
+// acquireUploadLease reserves a session for the caller and records the lease in session.LeaseUntil
+func acquireUploadLease(ctx context.Context, sessions interfaces.UploadSessionRepository, session *models.UploadSession) error {
+	leaseUntil := time.Now().Add(uploadLeaseDuration)
+	acquired, err := sessions.AcquireLease(ctx, session.ID, session.Offset, leaseUntil)
+	if err != nil {
+		return errors.NewPersistenceError(err)
+	}
+	if !acquired {
+		return errors.NewUploadOffsetMismatchError("upload is being written by another request")
+	}
+	session.LeaseUntil = leaseUntil.UnixMilli()
+	return nil
+}
+
+// writeChunk reads the chunk into part-sized buffers and writes each one as a storage part, or as
+// the pending object when it is too small to be a part. The session offset and hash state only
+// advance once the bytes are safely stored
+func (service *uploadSessionService) writeChunk(ctx context.Context, session *models.UploadSession, chunk io.Reader) error {
+	hasher, err := util.NewResumableSHA256(session.HashState)
+	if err != nil {
+		return errors.NewHashCalculateError(err)
+	}
+
+	buffer := make([]byte, uploadPartSize)
+	carried := 0
+	if session.PendingSize > 0 {
+		if err := service.readPending(ctx, session, buffer[:session.PendingSize]); err != nil {
+			return errors.NewStorageUploadError(err)
+		}
+		carried = int(session.PendingSize)
+	}
+
+	for {
+		n, readErr := io.ReadFull(chunk, buffer[carried:])
+		if session.Offset+int64(n) > session.Length {
+			return errors.NewValidationError("chunk exceeds the declared upload length")
+		}
+
+		if n > 0 {
+			total := carried + n
+			final := session.Offset+int64(n) == session.Length
+			if total == len(buffer) || total >= minUploadPartSize || final {
+				if err := service.writePart(ctx, session, buffer[:total]); err != nil {
+					return errors.NewStorageUploadError(err)
+				}
+			} else {
+				if err := service.storage.Put(ctx, bytes.NewReader(buffer[:total]), pendingKey(session), session.ContentType); err != nil {
+					return errors.NewStorageUploadError(err)
+				}
+				session.PendingSize = int64(total)
+			}
+
+			_, _ = hasher.Write(buffer[carried:total])
+			state, err := util.MarshalHashState(hasher)
+			if err != nil {
+				return errors.NewHashCalculateError(err)
+			}
+			session.HashState = state
+			session.Offset += int64(n)
+			carried = 0
+		}
+
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if readErr != nil {
+			return errors.NewFileReadError(readErr)
+		}
+	}
+}
+
+// writePart uploads data as the next part of the session, consuming the pending object if any
+func (service *uploadSessionService) writePart(ctx context.Context, session *models.UploadSession, data []byte) error {
+	partNumber := int32(len(session.Parts) + 1)
+	etag, err := service.storage.UploadStagedPart(ctx, session.StagingKey, session.StorageUploadID, partNumber, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+
+	session.Parts = append(session.Parts, models.UploadPart{Number: partNumber, ETag: etag, Size: int64(len(data))})
+	if session.PendingSize > 0 {
+		// A leftover pending object is expired together with the staging area
+		_ = service.storage.Delete(ctx, pendingKey(session))
+		session.PendingSize = 0
+	}
+	return nil
+}
+
+// readPending loads the pending object of a session into buffer
+func (service *uploadSessionService) readPending(ctx context.Context, session *models.UploadSession, buffer []byte) error {
+	body, err := service.storage.Open(ctx, pendingKey(session))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	_, err = io.ReadFull(body, buffer)
+	return err
+}
+
+// pendingKey returns the key of the object holding the trailing bytes of a session
+func pendingKey(session *models.UploadSession) string {
+	return session.StagingKey + ".pending"
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCursor is returned when a cursor was not issued by the codec for the same owner
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorCodec turns the positions returned by paginated repository queries into opaque cursors
+// handed to clients, and back. Cursors are signed with HMAC-SHA256 over the owner they list, so a
+// client can neither forge a position nor reuse the cursor of another owner
+type CursorCodec struct {
+	key []byte
+}
+
+// NewCursorCodec creates a cursor codec signing with key
+func NewCursorCodec(key []byte) *CursorCodec {
+	return &CursorCodec{key: key}
+}
+
+// Encode returns the cursor of a position of ownerID's documents. An empty position, the end of
+// the list, has an empty cursor
+func (c *CursorCodec) Encode(ownerID int64, position string) string {
+	if position == "" {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(position)) + "." + c.sign(ownerID, position)
+}
+
+// Decode returns the position wrapped by a cursor after checking its signature. An empty cursor
+// is the first page, whose position is empty
+func (c *CursorCodec) Decode(ownerID int64, cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+
+	encoded, signature, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return "", ErrInvalidCursor
+	}
+	position, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(position) == 0 {
+		return "", ErrInvalidCursor
+	}
+	if !hmac.Equal([]byte(signature), []byte(c.sign(ownerID, string(position)))) {
+		return "", ErrInvalidCursor
+	}
+	return string(position), nil
+}
+
+// sign computes the signature of a position of ownerID's documents
+func (c *CursorCodec) sign(ownerID int64, position string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(strconv.FormatInt(ownerID, 10)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(position))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
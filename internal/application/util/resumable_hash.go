@@ -0,0 +1,35 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"fmt"
+	"hash"
+)
+
+// NewResumableSHA256 restores a SHA256 digest from a state produced by MarshalHashState.
+// An empty state starts a new digest
+func NewResumableSHA256(state []byte) (hash.Hash, error) {
+	hasher := sha256.New()
+	if len(state) == 0 {
+		return hasher, nil
+	}
+
+	unmarshaler, ok := hasher.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return nil, fmt.Errorf("sha256 digest cannot be restored")
+	}
+	if err := unmarshaler.UnmarshalBinary(state); err != nil {
+		return nil, fmt.Errorf("invalid sha256 state: %w", err)
+	}
+	return hasher, nil
+}
+
+// MarshalHashState serializes the internal state of a digest so it can be resumed later
+func MarshalHashState(hasher hash.Hash) ([]byte, error) {
+	marshaler, ok := hasher.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("digest state cannot be serialized")
+	}
+	return marshaler.MarshalBinary()
+}
@@ -0,0 +1,50 @@
+package util_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorCodec_RoundTrip(t *testing.T) {
+	codec := util.NewCursorCodec([]byte("key"))
+
+	cursor := codec.Encode(7, `{"DocumentID":{"S":"doc-1"}}`)
+	position, err := codec.Decode(7, cursor)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"DocumentID":{"S":"doc-1"}}`, position)
+	assert.Empty(t, codec.Encode(7, ""), "the end of the list has no cursor")
+
+	position, err = codec.Decode(7, "")
+	assert.NoError(t, err)
+	assert.Empty(t, position, "an empty cursor is the first page")
+}
+
+func TestCursorCodec_RejectsInvalidCursors(t *testing.T) {
+	codec := util.NewCursorCodec([]byte("key"))
+	cursor := codec.Encode(7, "position")
+	_, signature, _ := strings.Cut(cursor, ".")
+
+	tests := []struct {
+		name    string
+		ownerID int64
+		cursor  string
+	}{
+		{name: "another owner", ownerID: 8, cursor: cursor},
+		{name: "another key", ownerID: 7, cursor: util.NewCursorCodec([]byte("other")).Encode(7, "position")},
+		{name: "tampered position", ownerID: 7, cursor: base64.RawURLEncoding.EncodeToString([]byte("other")) + "." + signature},
+		{name: "no signature", ownerID: 7, cursor: "cG9zaXRpb24"},
+		{name: "not base64", ownerID: 7, cursor: "!!!.signature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := codec.Decode(tt.ownerID, tt.cursor)
+			assert.ErrorIs(t, err, util.ErrInvalidCursor)
+		})
+	}
+}
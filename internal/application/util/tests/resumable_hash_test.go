@@ -0,0 +1,34 @@
+package util_test
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResumableSHA256(t *testing.T) {
+	t.Run("resumed digest matches a single pass", func(t *testing.T) {
+		first, err := util.NewResumableSHA256(nil)
+		assert.NoError(t, err)
+		_, _ = first.Write([]byte("first chunk, "))
+
+		state, err := util.MarshalHashState(first)
+		assert.NoError(t, err)
+
+		resumed, err := util.NewResumableSHA256(state)
+		assert.NoError(t, err)
+		_, _ = resumed.Write([]byte("second chunk"))
+
+		expected := sha256.Sum256([]byte("first chunk, second chunk"))
+		assert.Equal(t, expected[:], resumed.Sum(nil))
+	})
+
+	t.Run("invalid state", func(t *testing.T) {
+		hasher, err := util.NewResumableSHA256([]byte("not a state"))
+
+		assert.Error(t, err)
+		assert.Nil(t, hasher)
+	})
+}
@@ -1,5 +1,7 @@
 package errors
 
+import stderrors "errors"
+
 // DomainError represents a domain-level error with a code and optional wrapped error
 type DomainError struct {
 	Code    string // Error code for categorization
@@ -21,6 +23,17 @@ const (
 	ErrCodeStorageUpload = "STORAGE_UPLOAD_ERROR"
 	ErrCodePersistence   = "PERSISTENCE_ERROR"
 	ErrCodeNotFound      = "NOT_FOUND"
+	ErrCodeConflict      = "CONFLICT"
+
+	ErrCodeUploadOffsetMismatch = "UPLOAD_OFFSET_MISMATCH"
+	ErrCodeQuotaExceeded        = "QUOTA_EXCEEDED"
+	ErrCodeInvalidSignature     = "INVALID_SIGNATURE"
+)
+
+// The limits of a quota, wrapped by the quota errors to tell which one an upload would exceed
+var (
+	ErrStorageQuotaExceeded  = stderrors.New("storage quota exceeded")
+	ErrDocumentQuotaExceeded = stderrors.New("document quota exceeded")
 )
 
 // NewValidationError creates a validation error (e.g., invalid input data)
@@ -52,3 +65,27 @@ func NewPersistenceError(err error) *DomainError {
 func NewNotFoundError(message string) *DomainError {
 	return &DomainError{Code: ErrCodeNotFound, Message: message}
 }
+
+// NewConflictError creates an error when a document changed since the version a write was based on.
+// Clients are expected to read the document again and retry with its new version
+func NewConflictError(message string) *DomainError {
+	return &DomainError{Code: ErrCodeConflict, Message: message}
+}
+
+// NewUploadOffsetMismatchError creates an error when a chunk does not continue a resumable upload
+// at its current offset. Clients are expected to query the offset again and resume from there
+func NewUploadOffsetMismatchError(message string) *DomainError {
+	return &DomainError{Code: ErrCodeUploadOffsetMismatch, Message: message}
+}
+
+// NewInvalidSignatureError creates an error when a signed URL is malformed, tampered with or
+// expired. The signature is the only authorization of such URLs
+func NewInvalidSignatureError(message string) *DomainError {
+	return &DomainError{Code: ErrCodeInvalidSignature, Message: message}
+}
+
+// NewQuotaExceededError creates an error when an upload would take its owner past one of the
+// limits of their quota, ErrStorageQuotaExceeded or ErrDocumentQuotaExceeded
+func NewQuotaExceededError(message string, limit error) *DomainError {
+	return &DomainError{Code: ErrCodeQuotaExceeded, Message: message, Err: limit}
+}
@@ -96,3 +96,30 @@ func TestNewNotFoundError(t *testing.T) {
 	assert.Equal(t, "document not found", err.Message)
 	assert.Nil(t, err.Err)
 }
+
+func TestNewConflictError(t *testing.T) {
+	err := domainerrors.NewConflictError("document was modified")
+
+	assert.NotNil(t, err)
+	assert.Equal(t, domainerrors.ErrCodeConflict, err.Code)
+	assert.Equal(t, "document was modified", err.Message)
+	assert.Nil(t, err.Err)
+}
+
+func TestNewInvalidSignatureError(t *testing.T) {
+	err := domainerrors.NewInvalidSignatureError("invalid or expired signature")
+
+	assert.NotNil(t, err)
+	assert.Equal(t, domainerrors.ErrCodeInvalidSignature, err.Code)
+	assert.Equal(t, "invalid or expired signature", err.Message)
+	assert.Nil(t, err.Err)
+}
+
+func TestNewUploadOffsetMismatchError(t *testing.T) {
+	err := domainerrors.NewUploadOffsetMismatchError("expected offset 10")
+
+	assert.NotNil(t, err)
+	assert.Equal(t, domainerrors.ErrCodeUploadOffsetMismatch, err.Code)
+	assert.Equal(t, "expected offset 10", err.Message)
+	assert.Nil(t, err.Err)
+}
@@ -1,5 +1,12 @@
 package events
 
+const (
+	// TypeDocumentAuthenticationCompleted is the CloudEvents type of the event reporting the result of a document authentication
+	TypeDocumentAuthenticationCompleted = "document.authentication.completed"
+	// DocumentAuthenticationCompletedVersion is the current payload version of the event reporting the result of a document authentication
+	DocumentAuthenticationCompletedVersion = 1
+)
+
 // DocumentAuthenticationCompletedEvent represents the event received when a document has been authenticated
 // This event is published by the operator-connectivity microservice after calling the authentication service
 type DocumentAuthenticationCompletedEvent struct {
@@ -1,5 +1,12 @@
 package events
 
+const (
+	// TypeDocumentAuthenticationRequested is the CloudEvents type of the event requesting the authentication of a document
+	TypeDocumentAuthenticationRequested = "document.authentication.requested"
+	// DocumentAuthenticationRequestedVersion is the current payload version of the event requesting the authentication of a document
+	DocumentAuthenticationRequestedVersion = 1
+)
+
 // DocumentAuthenticationRequestedEvent represents the event published when a document authentication is requested
 type DocumentAuthenticationRequestedEvent struct {
 	MessageID     string `json:"messageId"`     // Unique message ID for deduplication
@@ -0,0 +1,16 @@
+package events
+
+const (
+	// TypeDocumentAuthenticationStatusChanged is the CloudEvents type of the event reporting a new authentication status
+	TypeDocumentAuthenticationStatusChanged = "document.authentication_status_changed"
+	// DocumentAuthenticationStatusChangedVersion is the current payload version of the event reporting a new authentication status
+	DocumentAuthenticationStatusChangedVersion = 1
+)
+
+// DocumentAuthenticationStatusChangedEvent is published when the result of an authentication
+// updates the status of a document
+type DocumentAuthenticationStatusChangedEvent struct {
+	DocumentID string `json:"documentId"` // ID of the document
+	IDCitizen  int64  `json:"idCitizen"`  // Owner's ID (citizen identifier)
+	Status     string `json:"status"`     // New authentication status
+}
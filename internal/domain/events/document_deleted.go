@@ -0,0 +1,15 @@
+package events
+
+const (
+	// TypeDocumentDeleted is the CloudEvents type of the event reporting the deletion of a document
+	TypeDocumentDeleted = "document.deleted"
+	// DocumentDeletedVersion is the current payload version of the event reporting the deletion of a document
+	DocumentDeletedVersion = 1
+)
+
+// DocumentDeletedEvent is published when a single document is deleted
+type DocumentDeletedEvent struct {
+	DocumentID string `json:"documentId"` // ID of the deleted document
+	IDCitizen  int64  `json:"idCitizen"`  // Owner's ID (citizen identifier)
+	Filename   string `json:"filename"`   // Original filename
+}
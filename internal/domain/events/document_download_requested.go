@@ -1,5 +1,12 @@
 package events
 
+const (
+	// TypeDocumentDownloadRequested is the CloudEvents type of the event requesting the download of documents
+	TypeDocumentDownloadRequested = "documents.download.requested"
+	// DocumentDownloadRequestedVersion is the current payload version of the event requesting the download of documents
+	DocumentDownloadRequestedVersion = 1
+)
+
 // DocumentDownloadRequestedEvent represents an event sent to request the service
 // to download a set of pre-signed URLs and store them as documents for a user.
 type DocumentDownloadRequestedEvent struct {
@@ -0,0 +1,15 @@
+package events
+
+const (
+	// TypeDocumentRestored is the CloudEvents type of the event reporting the restoration of a document from the trash
+	TypeDocumentRestored = "document.restored"
+	// DocumentRestoredVersion is the current payload version of the event reporting the restoration of a document from the trash
+	DocumentRestoredVersion = 1
+)
+
+// DocumentRestoredEvent is published when a document is taken out of the trash
+type DocumentRestoredEvent struct {
+	DocumentID string `json:"documentId"` // ID of the restored document
+	IDCitizen  int64  `json:"idCitizen"`  // Owner's ID (citizen identifier)
+	Filename   string `json:"filename"`   // Original filename
+}
@@ -0,0 +1,20 @@
+package events
+
+const (
+	// TypeDocumentUploaded is the CloudEvents type of the event reporting a new document
+	TypeDocumentUploaded = "document.uploaded"
+	// DocumentUploadedVersion is the current payload version of the event reporting a new document
+	DocumentUploadedVersion = 1
+)
+
+// DocumentUploadedEvent is published when a document is stored for a citizen. Uploading content the
+// citizen already has returns the existing document and publishes nothing
+type DocumentUploadedEvent struct {
+	DocumentID string `json:"documentId"` // ID of the new document
+	IDCitizen  int64  `json:"idCitizen"`  // Owner's ID (citizen identifier)
+	Filename   string `json:"filename"`   // Original filename
+	MimeType   string `json:"mimeType"`   // MIME type of the content
+	SizeBytes  int64  `json:"sizeBytes"`  // Content size in bytes
+	HashSHA256 string `json:"hashSha256"` // SHA256 digest of the content
+	UploadedAt string `json:"uploadedAt"` // Timestamp when the document was created (ISO 8601)
+}
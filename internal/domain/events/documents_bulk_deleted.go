@@ -0,0 +1,15 @@
+package events
+
+const (
+	// TypeDocumentsBulkDeleted is the CloudEvents type of the event reporting the deletion of all the documents of a citizen
+	TypeDocumentsBulkDeleted = "document.bulk_deleted"
+	// DocumentsBulkDeletedVersion is the current payload version of the event reporting the deletion of all the documents of a citizen
+	DocumentsBulkDeletedVersion = 1
+)
+
+// DocumentsBulkDeletedEvent is published when the documents of a citizen are deleted at once.
+// Large deletions run in several transactions, each publishing the documents it deleted
+type DocumentsBulkDeletedEvent struct {
+	IDCitizen   int64    `json:"idCitizen"`   // Owner's ID (citizen identifier)
+	DocumentIDs []string `json:"documentIds"` // IDs of the deleted documents
+}
@@ -1,5 +1,12 @@
 package events
 
+const (
+	// TypeDocumentsReady is the CloudEvents type of the event reporting the end of a document download
+	TypeDocumentsReady = "documents.ready"
+	// DocumentsReadyVersion is the current payload version of the event reporting the end of a document download
+	DocumentsReadyVersion = 1
+)
+
 // DocumentsReadyEvent is published when batch download/upload operation completes
 type DocumentsReadyEvent struct {
 	IDCitizen int64  `json:"idCitizen"`
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SpecVersion is the CloudEvents specification version of the envelopes
+	SpecVersion = "1.0"
+
+	// Source identifies this service as the producer of the events it publishes
+	Source = "/document-management-microservice"
+
+	// ContentTypeJSON is the content type of every event payload
+	ContentTypeJSON = "application/json"
+
+	// schemaPrefix starts the data schema URI of every event; the URI ends with the payload version
+	schemaPrefix = "urn:document-management:events:"
+)
+
+// Envelope is a CloudEvents 1.0 event. Its JSON form is the structured CloudEvents format, while
+// brokers carry the attributes as message headers next to the raw payload (binary mode)
+type Envelope struct {
+	ID              string          `json:"id"`                        // Unique event ID, used for deduplication
+	Source          string          `json:"source"`                    // Producer of the event
+	Type            string          `json:"type"`                      // Event type, one of the Type constants
+	SpecVersion     string          `json:"specversion"`               // CloudEvents version, empty for legacy messages without envelope
+	Time            time.Time       `json:"time"`                      // When the event occurred
+	DataSchema      string          `json:"dataschema,omitempty"`      // Schema URI of the payload, ending with its version
+	DataContentType string          `json:"datacontenttype,omitempty"` // Content type of the payload
+	Data            json.RawMessage `json:"data,omitempty"`            // Event payload
+
+	// PartitionKey is the CloudEvents partitioning extension: events sharing it are delivered in
+	// order by brokers that partition their streams. It is the citizen the event is about
+	PartitionKey string `json:"partitionkey,omitempty"`
+}
+
+// NewEnvelope wraps a payload in an envelope published by this service. Payloads naming a citizen
+// are partitioned by it, so the events of a citizen keep their order
+func NewEnvelope(eventType string, version int, id string, payload any) (Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to marshal %s event: %w", eventType, err)
+	}
+	return Envelope{
+		ID:              id,
+		Source:          Source,
+		Type:            eventType,
+		SpecVersion:     SpecVersion,
+		Time:            time.Now().UTC(),
+		DataSchema:      SchemaURI(eventType, version),
+		DataContentType: ContentTypeJSON,
+		Data:            data,
+		PartitionKey:    citizenPartitionKey(data),
+	}, nil
+}
+
+// citizenPartitionKey returns the idCitizen field of a payload, or "" when it has none
+func citizenPartitionKey(data json.RawMessage) string {
+	var payload struct {
+		IDCitizen *int64 `json:"idCitizen"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil || payload.IDCitizen == nil {
+		return ""
+	}
+	return strconv.FormatInt(*payload.IDCitizen, 10)
+}
+
+// SchemaURI returns the data schema URI of a version of an event type
+func SchemaURI(eventType string, version int) string {
+	return fmt.Sprintf("%s%s:v%d", schemaPrefix, eventType, version)
+}
+
+// Version returns the payload version declared by the data schema. Messages without schema, such
+// as those sent before envelopes were introduced, carry version 1; 0 means the schema is not ours
+func (e Envelope) Version() int {
+	if e.DataSchema == "" {
+		return 1
+	}
+	index := strings.LastIndex(e.DataSchema, ":v")
+	if !strings.HasPrefix(e.DataSchema, schemaPrefix) || index < 0 {
+		return 0
+	}
+	version, err := strconv.Atoi(e.DataSchema[index+2:])
+	if err != nil || version < 1 {
+		return 0
+	}
+	return version
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnknownEventType is returned when decoding an event type that is not registered
+	ErrUnknownEventType = errors.New("unknown event type")
+
+	// ErrUnexpectedEventType is returned when an envelope carries another type than the one expected
+	ErrUnexpectedEventType = errors.New("unexpected event type")
+
+	// ErrUnsupportedEventVersion is returned when an envelope carries a specification or payload
+	// version that cannot be decoded or upcast
+	ErrUnsupportedEventVersion = errors.New("unsupported event version")
+)
+
+// Upcaster converts the payload of one version of an event type into the next version
+type Upcaster func(data json.RawMessage) (json.RawMessage, error)
+
+// Registry maps event types and their payload versions to the Go structs they decode into.
+// Older payload versions are upcast step by step to the latest one before decoding
+type Registry struct {
+	schemas map[string]*eventSchema
+}
+
+// eventSchema holds the latest payload struct of an event type and the upcasters leading to it
+type eventSchema struct {
+	latest     int
+	newPayload func() any
+	upcasters  map[int]Upcaster
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{schemas: make(map[string]*eventSchema)}
+}
+
+// NewDefaultRegistry creates a registry with the current version of every event of the service
+func NewDefaultRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register(TypeDocumentAuthenticationRequested, DocumentAuthenticationRequestedVersion, func() any { return &DocumentAuthenticationRequestedEvent{} })
+	registry.Register(TypeDocumentAuthenticationCompleted, DocumentAuthenticationCompletedVersion, func() any { return &DocumentAuthenticationCompletedEvent{} })
+	registry.Register(TypeDocumentDownloadRequested, DocumentDownloadRequestedVersion, func() any { return &DocumentDownloadRequestedEvent{} })
+	registry.Register(TypeDocumentsReady, DocumentsReadyVersion, func() any { return &DocumentsReadyEvent{} })
+	registry.Register(TypeUserTransferred, UserTransferredVersion, func() any { return &UserTransferredEvent{} })
+	registry.Register(TypeDocumentUploaded, DocumentUploadedVersion, func() any { return &DocumentUploadedEvent{} })
+	registry.Register(TypeDocumentDeleted, DocumentDeletedVersion, func() any { return &DocumentDeletedEvent{} })
+	registry.Register(TypeDocumentRestored, DocumentRestoredVersion, func() any { return &DocumentRestoredEvent{} })
+	registry.Register(TypeDocumentsBulkDeleted, DocumentsBulkDeletedVersion, func() any { return &DocumentsBulkDeletedEvent{} })
+	registry.Register(TypeDocumentAuthenticationStatusChanged, DocumentAuthenticationStatusChangedVersion, func() any { return &DocumentAuthenticationStatusChangedEvent{} })
+	return registry
+}
+
+// Register declares the struct of a payload version. The highest registered version of a type is
+// the one events decode into
+func (r *Registry) Register(eventType string, version int, newPayload func() any) {
+	schema := r.schema(eventType)
+	if version >= schema.latest {
+		schema.latest = version
+		schema.newPayload = newPayload
+	}
+}
+
+// RegisterUpcaster declares how to convert the payload of fromVersion into fromVersion+1
+func (r *Registry) RegisterUpcaster(eventType string, fromVersion int, upcaster Upcaster) {
+	r.schema(eventType).upcasters[fromVersion] = upcaster
+}
+
+// Decode checks that an envelope carries the expected event type and decodes its payload into the
+// struct of the latest version, upcasting older payloads first. Legacy messages without envelope
+// are decoded as version 1 of the expected type. It returns a pointer to the payload struct
+func (r *Registry) Decode(eventType string, envelope Envelope) (any, error) {
+	schema, ok := r.schemas[eventType]
+	if !ok || schema.newPayload == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownEventType, eventType)
+	}
+	if envelope.SpecVersion != "" && envelope.SpecVersion != SpecVersion {
+		return nil, fmt.Errorf("%w: CloudEvents specversion %s", ErrUnsupportedEventVersion, envelope.SpecVersion)
+	}
+	if envelope.Type != "" && envelope.Type != eventType {
+		return nil, fmt.Errorf("%w: got %s, expected %s", ErrUnexpectedEventType, envelope.Type, eventType)
+	}
+
+	version := envelope.Version()
+	if version < 1 || version > schema.latest {
+		return nil, fmt.Errorf("%w: %s with schema %q", ErrUnsupportedEventVersion, eventType, envelope.DataSchema)
+	}
+
+	data := envelope.Data
+	for ; version < schema.latest; version++ {
+		upcaster, ok := schema.upcasters[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s version %d cannot be upcast", ErrUnsupportedEventVersion, eventType, version)
+		}
+		upcast, err := upcaster(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upcast %s from version %d: %w", eventType, version, err)
+		}
+		data = upcast
+	}
+
+	payload := schema.newPayload()
+	if err := json.Unmarshal(data, payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s event: %w", eventType, err)
+	}
+	return payload, nil
+}
+
+// DecodeAs decodes an envelope with Decode and returns its payload as a *T
+func DecodeAs[T any](r *Registry, eventType string, envelope Envelope) (*T, error) {
+	payload, err := r.Decode(eventType, envelope)
+	if err != nil {
+		return nil, err
+	}
+	typed, ok := payload.(*T)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s decodes into %T", ErrUnexpectedEventType, eventType, payload)
+	}
+	return typed, nil
+}
+
+// schema returns the schema of an event type, creating it if needed
+func (r *Registry) schema(eventType string) *eventSchema {
+	schema, ok := r.schemas[eventType]
+	if !ok {
+		schema = &eventSchema{upcasters: make(map[int]Upcaster)}
+		r.schemas[eventType] = schema
+	}
+	return schema
+}
@@ -0,0 +1,118 @@
+package events_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+)
+
+func TestNewEnvelope(t *testing.T) {
+	envelope, err := events.NewEnvelope(events.TypeUserTransferred, events.UserTransferredVersion, "evt-1", events.UserTransferredEvent{IDCitizen: 42})
+	require.NoError(t, err)
+
+	assert.Equal(t, "evt-1", envelope.ID)
+	assert.Equal(t, events.Source, envelope.Source)
+	assert.Equal(t, events.TypeUserTransferred, envelope.Type)
+	assert.Equal(t, events.SpecVersion, envelope.SpecVersion)
+	assert.Equal(t, events.ContentTypeJSON, envelope.DataContentType)
+	assert.False(t, envelope.Time.IsZero())
+	assert.Equal(t, events.UserTransferredVersion, envelope.Version())
+	assert.JSONEq(t, `{"idCitizen":42}`, string(envelope.Data))
+	assert.Equal(t, "42", envelope.PartitionKey, "events are partitioned by citizen")
+
+	unkeyed, err := events.NewEnvelope("test.text", 1, "evt-2", "text")
+	require.NoError(t, err)
+	assert.Empty(t, unkeyed.PartitionKey)
+}
+
+func TestEnvelope_Version(t *testing.T) {
+	assert.Equal(t, 1, events.Envelope{}.Version(), "messages without schema are version 1")
+	assert.Equal(t, 3, events.Envelope{DataSchema: events.SchemaURI("user.transferred", 3)}.Version())
+	assert.Equal(t, 0, events.Envelope{DataSchema: "https://example.com/schema.json"}.Version(), "foreign schemas have no version")
+	assert.Equal(t, 0, events.Envelope{DataSchema: events.SchemaURI("user.transferred", 1) + "x"}.Version())
+}
+
+func TestRegistry_DecodeLatestVersion(t *testing.T) {
+	registry := events.NewDefaultRegistry()
+	envelope, err := events.NewEnvelope(events.TypeDocumentsReady, events.DocumentsReadyVersion, "evt-1", events.DocumentsReadyEvent{IDCitizen: 7, Status: "success"})
+	require.NoError(t, err)
+
+	ready, err := events.DecodeAs[events.DocumentsReadyEvent](registry, events.TypeDocumentsReady, envelope)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), ready.IDCitizen)
+	assert.Equal(t, "success", ready.Status)
+}
+
+func TestRegistry_DecodeLegacyMessage(t *testing.T) {
+	registry := events.NewDefaultRegistry()
+
+	transferred, err := events.DecodeAs[events.UserTransferredEvent](registry, events.TypeUserTransferred, events.Envelope{Data: json.RawMessage(`{"idCitizen":9}`)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), transferred.IDCitizen)
+}
+
+func TestRegistry_RejectsMismatchesAndUnknownVersions(t *testing.T) {
+	registry := events.NewDefaultRegistry()
+	envelope, err := events.NewEnvelope(events.TypeUserTransferred, events.UserTransferredVersion, "evt-1", events.UserTransferredEvent{IDCitizen: 1})
+	require.NoError(t, err)
+
+	_, err = registry.Decode(events.TypeDocumentsReady, envelope)
+	assert.ErrorIs(t, err, events.ErrUnexpectedEventType)
+
+	_, err = registry.Decode("unknown.type", envelope)
+	assert.ErrorIs(t, err, events.ErrUnknownEventType)
+
+	newer := envelope
+	newer.DataSchema = events.SchemaURI(events.TypeUserTransferred, events.UserTransferredVersion+1)
+	_, err = registry.Decode(events.TypeUserTransferred, newer)
+	assert.ErrorIs(t, err, events.ErrUnsupportedEventVersion)
+
+	otherSpec := envelope
+	otherSpec.SpecVersion = "0.3"
+	_, err = registry.Decode(events.TypeUserTransferred, otherSpec)
+	assert.ErrorIs(t, err, events.ErrUnsupportedEventVersion)
+}
+
+// transferV3 is a later version of a test event, renaming the citizen field twice
+type transferV3 struct {
+	Citizen int64 `json:"citizen"`
+}
+
+func TestRegistry_UpcastsOlderVersions(t *testing.T) {
+	registry := events.NewRegistry()
+	registry.Register("test.transfer", 3, func() any { return &transferV3{} })
+	registry.RegisterUpcaster("test.transfer", 1, func(data json.RawMessage) (json.RawMessage, error) {
+		var v1 struct {
+			IDCitizen int64 `json:"idCitizen"`
+		}
+		if err := json.Unmarshal(data, &v1); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]int64{"citizenId": v1.IDCitizen})
+	})
+	registry.RegisterUpcaster("test.transfer", 2, func(data json.RawMessage) (json.RawMessage, error) {
+		var v2 struct {
+			CitizenID int64 `json:"citizenId"`
+		}
+		if err := json.Unmarshal(data, &v2); err != nil {
+			return nil, err
+		}
+		return json.Marshal(transferV3{Citizen: v2.CitizenID})
+	})
+
+	v1, err := events.NewEnvelope("test.transfer", 1, "evt-1", map[string]int64{"idCitizen": 5})
+	require.NoError(t, err)
+	decoded, err := events.DecodeAs[transferV3](registry, "test.transfer", v1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), decoded.Citizen)
+
+	registry.Register("test.gap", 2, func() any { return &transferV3{} })
+	gap, err := events.NewEnvelope("test.gap", 1, "evt-2", map[string]int64{"idCitizen": 5})
+	require.NoError(t, err)
+	_, err = registry.Decode("test.gap", gap)
+	assert.ErrorIs(t, err, events.ErrUnsupportedEventVersion, "versions without an upcaster are rejected")
+}
@@ -1,5 +1,12 @@
 package events
 
+const (
+	// TypeUserTransferred is the CloudEvents type of the event reporting a user transfer
+	TypeUserTransferred = "user.transferred"
+	// UserTransferredVersion is the current payload version of the event reporting a user transfer
+	UserTransferredVersion = 1
+)
+
 // UserTransferredEvent represents the event when a user is transferred to another operator
 // All documents owned by this citizen should be deleted as part of the transfer process
 type UserTransferredEvent struct {
@@ -21,6 +21,14 @@ type Document struct {
 	AuthenticationStatus AuthenticationStatus `dynamodbav:"AuthenticationStatus" json:"authentication_status"` // Current authentication state
 	CreatedAt            time.Time            `dynamodbav:"CreatedAt" json:"created_at"`                       // Document creation timestamp
 	UpdatedAt            time.Time            `dynamodbav:"UpdatedAt" json:"updated_at"`                       // Last update timestamp
+	Version              int64                `dynamodbav:"Version" json:"version"`                            // Incremented by every write, starting at 1
+	DeletedAt            *time.Time           `dynamodbav:"DeletedAt,omitempty" json:"deleted_at,omitempty"`   // When the document was moved to the trash, nil outside the trash
+	PurgeAt              *time.Time           `dynamodbav:"PurgeAt,omitempty" json:"purge_at,omitempty"`       // When the trashed document is deleted for good
+}
+
+// IsTrashed reports whether the document is in the trash of its owner
+func (d *Document) IsTrashed() bool {
+	return d.DeletedAt != nil
 }
 
 // Validate checks if the document has all required fields with valid values
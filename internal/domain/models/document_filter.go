@@ -0,0 +1,75 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+)
+
+// DocumentFilter restricts a list of documents to those matching every criterion set. The zero
+// value matches every document outside the trash
+type DocumentFilter struct {
+	// Trashed matches the documents in the trash instead of those outside of it
+	Trashed bool
+
+	// AuthenticationStatus matches documents in this authentication state
+	AuthenticationStatus AuthenticationStatus
+
+	// MimeFamily matches documents whose MIME type has this top-level type, such as image
+	MimeFamily string
+
+	// CreatedFrom and CreatedTo bound the creation date of the documents, both inclusive
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+
+	// FilenameContains matches documents whose filename contains it, ignoring case
+	FilenameContains string
+}
+
+// IsZero reports whether the filter matches every document outside the trash
+func (f DocumentFilter) IsZero() bool {
+	return f == DocumentFilter{}
+}
+
+// Validate checks that the criteria are consistent
+func (f DocumentFilter) Validate() error {
+	if f.AuthenticationStatus != "" && !f.AuthenticationStatus.IsValid() {
+		return errors.NewValidationError("invalid authentication status")
+	}
+	if strings.Contains(f.MimeFamily, "/") {
+		return errors.NewValidationError("MIME type family must not contain a subtype")
+	}
+	if !f.CreatedFrom.IsZero() && !f.CreatedTo.IsZero() && f.CreatedFrom.After(f.CreatedTo) {
+		return errors.NewValidationError("created date range starts after it ends")
+	}
+	return nil
+}
+
+// Matches reports whether a document matches every criterion of the filter
+func (f DocumentFilter) Matches(document *Document) bool {
+	if document.IsTrashed() != f.Trashed {
+		return false
+	}
+	if f.AuthenticationStatus != "" && document.AuthenticationStatus != f.AuthenticationStatus {
+		return false
+	}
+	if f.MimeFamily != "" && !strings.HasPrefix(strings.ToLower(document.MimeType), f.MimeFamilyPrefix()) {
+		return false
+	}
+	if !f.CreatedFrom.IsZero() && document.CreatedAt.Before(f.CreatedFrom) {
+		return false
+	}
+	if !f.CreatedTo.IsZero() && document.CreatedAt.After(f.CreatedTo) {
+		return false
+	}
+	if f.FilenameContains != "" && !strings.Contains(FilenameSortKey(document.Filename), FilenameSortKey(f.FilenameContains)) {
+		return false
+	}
+	return true
+}
+
+// MimeFamilyPrefix returns the prefix of the MIME types of the family, such as image/
+func (f DocumentFilter) MimeFamilyPrefix() string {
+	return strings.ToLower(f.MimeFamily) + "/"
+}
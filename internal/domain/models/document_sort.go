@@ -0,0 +1,70 @@
+package models
+
+import "strings"
+
+// DocumentSortField is the document attribute a list is ordered by
+type DocumentSortField string
+
+const (
+	// DocumentSortCreatedAt orders documents by creation date
+	DocumentSortCreatedAt DocumentSortField = "created_at"
+
+	// DocumentSortFilename orders documents by filename, ignoring case
+	DocumentSortFilename DocumentSortField = "filename"
+
+	// DocumentSortSize orders documents by size
+	DocumentSortSize DocumentSortField = "size"
+)
+
+// SortOrder is the direction of a list order
+type SortOrder string
+
+const (
+	// SortOrderAsc lists the smallest values first
+	SortOrderAsc SortOrder = "asc"
+
+	// SortOrderDesc lists the largest values first
+	SortOrderDesc SortOrder = "desc"
+)
+
+// DocumentSort is the order of a list of documents. Documents with equal values keep an order of
+// their own, stable across pages
+type DocumentSort struct {
+	Field DocumentSortField
+	Order SortOrder
+}
+
+// WithDefaults fills in the unset parts of the sort: documents are listed by creation date by
+// default, the most recent first, and by ascending filename or size otherwise
+func (s DocumentSort) WithDefaults() DocumentSort {
+	if s.Field == "" {
+		s.Field = DocumentSortCreatedAt
+	}
+	if s.Order == "" {
+		s.Order = SortOrderAsc
+		if s.Field == DocumentSortCreatedAt {
+			s.Order = SortOrderDesc
+		}
+	}
+	return s
+}
+
+// IsValid checks if the sort field and order are known values
+func (s DocumentSort) IsValid() bool {
+	switch s.Field {
+	case DocumentSortCreatedAt, DocumentSortFilename, DocumentSortSize:
+	default:
+		return false
+	}
+	return s.Order == SortOrderAsc || s.Order == SortOrderDesc
+}
+
+// String returns the sort as field:order
+func (s DocumentSort) String() string {
+	return string(s.Field) + ":" + string(s.Order)
+}
+
+// FilenameSortKey returns the value filenames are ordered by, so that the order ignores case
+func FilenameSortKey(filename string) string {
+	return strings.ToLower(filename)
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+)
+
+// OutboxPending is the value of OutboxMessage.Pending while a message waits to be published
+const OutboxPending = "1"
+
+// OutboxMessage is an event stored alongside the state change that produced it, waiting to be
+// published to the message broker by the outbox relay. The broker topology routes it by event type
+type OutboxMessage struct {
+	ID            string `dynamodbav:"MessageID" json:"id"`                                      // Unique outbox message identifier
+	Payload       string `dynamodbav:"Payload" json:"payload"`                                   // Event in structured CloudEvents JSON
+	CreatedAt     int64  `dynamodbav:"CreatedAt" json:"created_at"`                              // Unix nanoseconds, orders pending messages
+	Pending       string `dynamodbav:"Pending,omitempty" json:"-"`                               // Set until the message is sent or parked (sparse index key)
+	Attempts      int    `dynamodbav:"Attempts" json:"attempts"`                                 // Failed publish attempts
+	LastError     string `dynamodbav:"LastError,omitempty" json:"last_error,omitempty"`          // Error of the last failed attempt
+	NextAttemptAt int64  `dynamodbav:"NextAttemptAt,omitempty" json:"next_attempt_at,omitempty"` // Unix nanoseconds before which a failed message is not retried
+	ParkedAt      int64  `dynamodbav:"ParkedAt,omitempty" json:"parked_at,omitempty"`            // Unix timestamp the relay gave up on the message
+	SentAt        int64  `dynamodbav:"SentAt,omitempty" json:"sent_at,omitempty"`                // Unix timestamp of the publish
+	ExpiresAt     int64  `dynamodbav:"ExpiresAt,omitempty" json:"-"`                             // DynamoDB TTL attribute, set once sent
+}
+
+// NewOutboxMessage creates a pending outbox message publishing an event. The event ID identifies
+// the message across retries; a new one is generated when empty
+func NewOutboxMessage(event events.Envelope) (*OutboxMessage, error) {
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal outbox event: %w", err)
+	}
+	return &OutboxMessage{
+		ID:        event.ID,
+		Payload:   string(payload),
+		CreatedAt: time.Now().UnixNano(),
+		Pending:   OutboxPending,
+	}, nil
+}
+
+// Due reports whether a pending message may be published at now, i.e. it is not backing off
+// after a failed attempt
+func (m *OutboxMessage) Due(now time.Time) bool {
+	return m.Pending == OutboxPending && m.NextAttemptAt <= now.UnixNano()
+}
+
+// Event decodes the event stored in the message
+func (m *OutboxMessage) Event() (events.Envelope, error) {
+	var event events.Envelope
+	if err := json.Unmarshal([]byte(m.Payload), &event); err != nil {
+		return events.Envelope{}, fmt.Errorf("failed to unmarshal outbox event %s: %w", m.ID, err)
+	}
+	return event, nil
+}
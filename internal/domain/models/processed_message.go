@@ -1,26 +1,48 @@
 package models
 
-import "time"
+import (
+	"time"
 
-// ProcessedMessage represents a message that has been processed (for idempotency)
+	"github.com/google/uuid"
+)
+
+// ProcessedMessageRetention is how long processed messages are remembered for deduplication
+const ProcessedMessageRetention = 7 * 24 * time.Hour
+
+// ProcessedMessageStatus is the processing state of a claimed message
+type ProcessedMessageStatus string
+
+const (
+	// ProcessedMessageInProgress marks a message a consumer is processing under a lease
+	ProcessedMessageInProgress ProcessedMessageStatus = "IN_PROGRESS"
+	// ProcessedMessageCompleted marks a message that was processed successfully. Records written
+	// before claims were introduced have no status and count as completed
+	ProcessedMessageCompleted ProcessedMessageStatus = "COMPLETED"
+)
+
+// ProcessedMessage represents the claim of a consumer on a message (for idempotency)
 type ProcessedMessage struct {
-	MessageID   string    `json:"messageId"`   // Primary key: unique message identifier
-	ProcessedAt time.Time `json:"processedAt"` // Timestamp when the message was processed
-	DocumentID  string    `json:"documentId"`  // Related document ID (for reference)
-	ProcessedBy string    `json:"processedBy"` // Service/handler that processed the message
-	TTL         int64     `json:"ttl"`         // DynamoDB TTL attribute (Unix timestamp)
+	MessageID   string                 `json:"messageId"`   // Primary key: unique message identifier
+	ProcessedAt time.Time              `json:"processedAt"` // Timestamp when the message was claimed, then processed
+	ProcessedBy string                 `json:"processedBy"` // Service/handler that processed the message
+	Status      ProcessedMessageStatus `json:"status"`      // Processing state of the message
+	ClaimToken  string                 `json:"claimToken"`  // Identifies the consumer holding the claim
+	LeaseUntil  int64                  `json:"leaseUntil"`  // Unix milliseconds until an in-progress claim can be taken over
+	TTL         int64                  `json:"ttl"`         // DynamoDB TTL attribute (Unix timestamp)
 }
 
-// NewProcessedMessage creates a new ProcessedMessage with default TTL of 7 days
-func NewProcessedMessage(messageID, documentID, processedBy string) *ProcessedMessage {
+// NewProcessedMessageClaim creates an in-progress claim on a message, leased for the given duration.
+// The TTL also removes claims abandoned by a crashed consumer
+func NewProcessedMessageClaim(messageID, processedBy string, lease time.Duration) *ProcessedMessage {
 	now := time.Now()
-	ttl := now.Add(7 * 24 * time.Hour).Unix() // 7 days TTL
 
 	return &ProcessedMessage{
 		MessageID:   messageID,
 		ProcessedAt: now,
-		DocumentID:  documentID,
 		ProcessedBy: processedBy,
-		TTL:         ttl,
+		Status:      ProcessedMessageInProgress,
+		ClaimToken:  uuid.New().String(),
+		LeaseUntil:  now.Add(lease).UnixMilli(),
+		TTL:         now.Add(ProcessedMessageRetention).Unix(),
 	}
 }
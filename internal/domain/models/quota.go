@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+)
+
+// OwnerUsage is the storage consumed by the documents of an owner
+type OwnerUsage struct {
+	OwnerID       int64
+	DocumentCount int64
+	TotalBytes    int64
+}
+
+// Quota bounds the storage of an owner. A zero limit is unlimited
+type Quota struct {
+	MaxDocuments int64
+	MaxBytes     int64
+}
+
+// Allows reports whether an owner at usage may store one more document of size bytes
+func (q Quota) Allows(usage OwnerUsage, size int64) bool {
+	return q.Check(usage, size) == nil
+}
+
+// Check returns a quota exceeded error when an owner at usage may not store one more document of
+// size bytes, telling which limit it would exceed
+func (q Quota) Check(usage OwnerUsage, size int64) error {
+	if q.MaxDocuments > 0 && usage.DocumentCount >= q.MaxDocuments {
+		return errors.NewQuotaExceededError(
+			fmt.Sprintf("document quota exceeded: %d of %d documents stored", usage.DocumentCount, q.MaxDocuments),
+			errors.ErrDocumentQuotaExceeded,
+		)
+	}
+	if q.MaxBytes > 0 && usage.TotalBytes+size > q.MaxBytes {
+		return errors.NewQuotaExceededError(
+			fmt.Sprintf("storage quota exceeded: %d of %d bytes stored, the document takes %d", usage.TotalBytes, q.MaxBytes, size),
+			errors.ErrStorageQuotaExceeded,
+		)
+	}
+	return nil
+}
+
+// QuotaPolicy assigns a quota to every owner: the default one unless the owner has an override
+type QuotaPolicy struct {
+	Default   Quota
+	Overrides map[int64]Quota
+}
+
+// For returns the quota of an owner
+func (p QuotaPolicy) For(ownerID int64) Quota {
+	if quota, ok := p.Overrides[ownerID]; ok {
+		return quota
+	}
+	return p.Default
+}
@@ -0,0 +1,60 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentFilter_Matches(t *testing.T) {
+	createdAt := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	document := &models.Document{
+		Filename:             "Passport-Scan.PDF",
+		MimeType:             "Application/PDF",
+		AuthenticationStatus: models.AuthenticationStatusAuthenticated,
+		CreatedAt:            createdAt,
+	}
+
+	tests := []struct {
+		name     string
+		filter   models.DocumentFilter
+		expected bool
+	}{
+		{"zero filter matches everything outside the trash", models.DocumentFilter{}, true},
+		{"trash filter", models.DocumentFilter{Trashed: true}, false},
+		{"status", models.DocumentFilter{AuthenticationStatus: models.AuthenticationStatusAuthenticated}, true},
+		{"other status", models.DocumentFilter{AuthenticationStatus: models.AuthenticationStatusUnauthenticated}, false},
+		{"MIME family ignores case", models.DocumentFilter{MimeFamily: "application"}, true},
+		{"other MIME family", models.DocumentFilter{MimeFamily: "image"}, false},
+		{"MIME family is not a type prefix", models.DocumentFilter{MimeFamily: "app"}, false},
+		{"range bounds are inclusive", models.DocumentFilter{CreatedFrom: createdAt, CreatedTo: createdAt}, true},
+		{"created before the range", models.DocumentFilter{CreatedFrom: createdAt.Add(time.Second)}, false},
+		{"created after the range", models.DocumentFilter{CreatedTo: createdAt.Add(-time.Second)}, false},
+		{"filename ignores case", models.DocumentFilter{FilenameContains: "port-scan.pdf"}, true},
+		{"other filename", models.DocumentFilter{FilenameContains: "diploma"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.filter.Matches(document))
+		})
+	}
+
+	deletedAt := createdAt.Add(time.Hour)
+	trashed := *document
+	trashed.DeletedAt = &deletedAt
+	assert.False(t, models.DocumentFilter{}.Matches(&trashed))
+	assert.True(t, models.DocumentFilter{Trashed: true, MimeFamily: "application"}.Matches(&trashed))
+}
+
+func TestDocumentFilter_Validate(t *testing.T) {
+	now := time.Now()
+
+	assert.NoError(t, models.DocumentFilter{}.Validate())
+	assert.NoError(t, models.DocumentFilter{MimeFamily: "image", CreatedFrom: now, CreatedTo: now}.Validate())
+	assert.Error(t, models.DocumentFilter{AuthenticationStatus: "pending"}.Validate())
+	assert.Error(t, models.DocumentFilter{MimeFamily: "image/png"}.Validate())
+	assert.Error(t, models.DocumentFilter{CreatedFrom: now, CreatedTo: now.Add(-time.Minute)}.Validate())
+}
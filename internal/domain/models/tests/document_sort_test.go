@@ -0,0 +1,45 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentSort_WithDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		sort     models.DocumentSort
+		expected models.DocumentSort
+	}{
+		{
+			name:     "zero sort lists the most recent first",
+			sort:     models.DocumentSort{},
+			expected: models.DocumentSort{Field: models.DocumentSortCreatedAt, Order: models.SortOrderDesc},
+		},
+		{
+			name:     "filename defaults to ascending",
+			sort:     models.DocumentSort{Field: models.DocumentSortFilename},
+			expected: models.DocumentSort{Field: models.DocumentSortFilename, Order: models.SortOrderAsc},
+		},
+		{
+			name:     "order alone applies to creation date",
+			sort:     models.DocumentSort{Order: models.SortOrderAsc},
+			expected: models.DocumentSort{Field: models.DocumentSortCreatedAt, Order: models.SortOrderAsc},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.sort.WithDefaults())
+		})
+	}
+}
+
+func TestDocumentSort_IsValid(t *testing.T) {
+	assert.True(t, models.DocumentSort{Field: models.DocumentSortSize, Order: models.SortOrderDesc}.IsValid())
+	assert.False(t, models.DocumentSort{Field: "owner", Order: models.SortOrderAsc}.IsValid())
+	assert.False(t, models.DocumentSort{Field: models.DocumentSortSize, Order: "up"}.IsValid())
+	assert.False(t, models.DocumentSort{}.IsValid(), "defaults are applied first")
+}
@@ -0,0 +1,79 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+
+	domainerrors "github.com/kristianrpo/document-management-microservice/internal/domain/errors"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuota_Check(t *testing.T) {
+	tests := []struct {
+		name     string
+		quota    models.Quota
+		usage    models.OwnerUsage
+		size     int64
+		expected error
+	}{
+		{
+			name:  "zero quota is unlimited",
+			quota: models.Quota{},
+			usage: models.OwnerUsage{DocumentCount: 1 << 20, TotalBytes: 1 << 40},
+			size:  1 << 30,
+		},
+		{
+			name:  "document filling the quota exactly is allowed",
+			quota: models.Quota{MaxDocuments: 2, MaxBytes: 100},
+			usage: models.OwnerUsage{DocumentCount: 1, TotalBytes: 60},
+			size:  40,
+		},
+		{
+			name:     "one byte over the quota exceeds the storage",
+			quota:    models.Quota{MaxDocuments: 2, MaxBytes: 100},
+			usage:    models.OwnerUsage{DocumentCount: 1, TotalBytes: 60},
+			size:     41,
+			expected: domainerrors.ErrStorageQuotaExceeded,
+		},
+		{
+			name:     "owner at the document limit exceeds the documents",
+			quota:    models.Quota{MaxDocuments: 2, MaxBytes: 100},
+			usage:    models.OwnerUsage{DocumentCount: 2, TotalBytes: 100},
+			size:     1,
+			expected: domainerrors.ErrDocumentQuotaExceeded,
+		},
+		{
+			name:  "limits apply independently",
+			quota: models.Quota{MaxBytes: 100},
+			usage: models.OwnerUsage{DocumentCount: 500, TotalBytes: 10},
+			size:  10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.quota.Check(tt.usage, tt.size)
+			assert.Equal(t, tt.expected == nil, tt.quota.Allows(tt.usage, tt.size))
+			if tt.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var domainErr *domainerrors.DomainError
+			assert.True(t, errors.As(err, &domainErr))
+			assert.Equal(t, domainerrors.ErrCodeQuotaExceeded, domainErr.Code)
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
+
+func TestQuotaPolicy_For(t *testing.T) {
+	policy := models.QuotaPolicy{
+		Default:   models.Quota{MaxDocuments: 10, MaxBytes: 100},
+		Overrides: map[int64]models.Quota{42: {}},
+	}
+
+	assert.Equal(t, models.Quota{MaxDocuments: 10, MaxBytes: 100}, policy.For(1))
+	assert.Equal(t, models.Quota{}, policy.For(42), "an override may lift the quota")
+}
@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// UploadKind identifies how the content of an upload session reaches storage
+type UploadKind string
+
+const (
+	// UploadKindChunked sessions receive their content in chunks through the service
+	UploadKindChunked UploadKind = "chunked"
+	// UploadKindDirect sessions receive their content straight from the client through a pre-signed request
+	UploadKindDirect UploadKind = "direct"
+)
+
+// UploadSession tracks an upload that spans several requests. Chunked sessions append their
+// content to a staged multipart object and persist the SHA256 state between chunks so the digest
+// is known as soon as the last byte arrives. Direct sessions only reserve the staging key the
+// client writes to through a pre-signed request
+type UploadSession struct {
+	ID              string       `dynamodbav:"UploadID" json:"id"`              // Unique upload session identifier (UUID)
+	OwnerID         int64        `dynamodbav:"OwnerID" json:"owner_id"`         // Citizen ID who owns the upload
+	Kind            UploadKind   `dynamodbav:"Kind" json:"kind"`                // How the content reaches storage
+	Filename        string       `dynamodbav:"Filename" json:"filename"`        // Original filename
+	ContentType     string       `dynamodbav:"ContentType" json:"content_type"` // MIME type detected from the filename
+	Length          int64        `dynamodbav:"Length" json:"length"`            // Declared total size in bytes
+	Offset          int64        `dynamodbav:"Offset" json:"offset"`            // Bytes received so far
+	StagingKey      string       `dynamodbav:"StagingKey" json:"-"`             // Staging object the parts are written to
+	StorageUploadID string       `dynamodbav:"StorageUploadID" json:"-"`        // Storage multipart upload identifier
+	Parts           []UploadPart `dynamodbav:"Parts" json:"-"`                  // Parts already written to storage
+	PendingSize     int64        `dynamodbav:"PendingSize" json:"-"`            // Trailing bytes kept aside until they fill a part
+	HashState       []byte       `dynamodbav:"HashState" json:"-"`              // Serialized SHA256 state of the received bytes
+	ExpectedSHA256  string       `dynamodbav:"ExpectedSHA256" json:"-"`         // SHA256 announced by the client, if any
+	LeaseUntil      int64        `dynamodbav:"LeaseUntil" json:"-"`             // Unix milliseconds until which a chunk is being written
+	CreatedAt       time.Time    `dynamodbav:"CreatedAt" json:"created_at"`     // Session creation timestamp
+	UpdatedAt       time.Time    `dynamodbav:"UpdatedAt" json:"updated_at"`     // Last chunk timestamp
+	ExpiresAt       int64        `dynamodbav:"ExpiresAt" json:"expires_at"`     // DynamoDB TTL attribute (Unix timestamp)
+}
+
+// UploadPart is a part of an upload session already written to storage
+type UploadPart struct {
+	Number int32  `dynamodbav:"Number"`
+	ETag   string `dynamodbav:"ETag"`
+	Size   int64  `dynamodbav:"Size"`
+}
+
+// NewUploadSession creates a new UploadSession that expires after ttl
+func NewUploadSession(ownerID int64, filename, contentType string, length int64, ttl time.Duration) *UploadSession {
+	now := time.Now()
+	return &UploadSession{
+		OwnerID:     ownerID,
+		Filename:    filename,
+		ContentType: contentType,
+		Length:      length,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ExpiresAt:   now.Add(ttl).Unix(),
+	}
+}
+
+// IsDirect reports whether the content is uploaded straight to storage by the client.
+// Sessions created before kinds existed are chunked
+func (s *UploadSession) IsDirect() bool {
+	return s.Kind == UploadKindDirect
+}
+
+// IsComplete reports whether every declared byte has been received
+func (s *UploadSession) IsComplete() bool {
+	return s.Offset == s.Length
+}
+
+// IsExpired reports whether the session outlived its TTL. DynamoDB removes expired
+// items lazily, so they may still be returned for a while
+func (s *UploadSession) IsExpired(now time.Time) bool {
+	return s.ExpiresAt > 0 && now.Unix() >= s.ExpiresAt
+}
@@ -2,7 +2,10 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -10,10 +13,34 @@ import (
 type Config struct {
 	Port string
 
+	// Standalone runs the whole service in one process with in-memory repositories and the
+	// filesystem storage, without any external dependency
+	Standalone bool
+
+	// DatabaseBackend selects where documents, upload sessions, outbox messages and message claims
+	// are stored: dynamodb or postgres, or memory in standalone mode
+	DatabaseBackend string
+	PostgresURL     string
+
 	DynamoDBTable                  string
 	DynamoDBProcessedMessagesTable string
+	DynamoDBBlobRefsTable          string
+	DynamoDBUploadSessionsTable    string
+	DynamoDBOutboxTable            string
+	DynamoDBHashGuardsTable        string
+	DynamoDBUsageTable             string
 	DynamoDBEndpoint               string
 
+	// DynamoDBSkipMigrations leaves the migrations of the documents table to the migrate-dynamodb
+	// command instead of applying them at startup; the service still refuses to start while any
+	// is pending
+	DynamoDBSkipMigrations bool
+
+	StorageBackend      string
+	FSStorageRoot       string
+	FSStorageBaseURL    string
+	FSStorageSigningKey string
+
 	AWSAccessKey string
 	AWSSecretKey string
 	AWSRegion    string
@@ -22,11 +49,53 @@ type Config struct {
 	S3UsePath    bool
 	S3PublicBase string
 
+	// MessageBroker selects the broker events are published to and consumed from, outside
+	// standalone mode: rabbitmq or kafka
+	MessageBroker string
+
 	RabbitMQ RabbitMQConfig
+	Kafka    KafkaConfig
+
+	// MemoryBrokerFile persists the queues of the in-process broker used in standalone mode;
+	// when empty, queued messages are lost on restart
+	MemoryBrokerFile string
+
+	// Quotas bound the number and total size of the documents of every owner
+	Quotas QuotaConfig
+
+	UploadSessionTTL time.Duration
+
+	// OutboxRelayInterval is how often pending outbox messages are published
+	OutboxRelayInterval time.Duration
+
+	// OutboxMaxAttempts is the number of failed publishes after which an outbox message is parked
+	OutboxMaxAttempts int
+
+	// OutboxRetryMaxDelay caps the backoff of outbox messages whose publish failed
+	OutboxRetryMaxDelay time.Duration
+
+	// TrashRetention is how long documents deleted by their owner stay in the trash before being purged
+	TrashRetention time.Duration
+
+	// TransferGracePeriod is how long the documents of a transferred citizen stay in the trash
+	// before being purged
+	TransferGracePeriod time.Duration
+
+	// PurgeInterval is how often trashed documents past their retention are purged
+	PurgeInterval time.Duration
+
+	// IdempotencyLease is how long a consumer holds a message before another one may take it over
+	IdempotencyLease time.Duration
 
 	ReadHeaderTimeout time.Duration
 
+	// ShutdownTimeout bounds how long in-flight requests and messages may take to finish on shutdown
+	ShutdownTimeout time.Duration
+
 	JWTSecret string
+
+	// CursorSigningKey signs the cursors of paginated lists; required outside STANDALONE mode
+	CursorSigningKey string
 }
 
 func getenv(k, def string) string {
@@ -36,6 +105,46 @@ func getenv(k, def string) string {
 	return def
 }
 func getbool(k string) bool { return os.Getenv(k) == "true" }
+func getint(k string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(k)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+func getduration(k string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(k)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+func getlist(k string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(k), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// getmap reads a list of key=value pairs separated by commas
+func getmap(k string) map[string]string {
+	values := make(map[string]string)
+	for _, pair := range getlist(k) {
+		if key, value, ok := strings.Cut(pair, "="); ok {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+// getlimit reads a non-negative integer, where 0 disables the limit it sets
+func getlimit(k string, def int64) int64 {
+	if v, err := strconv.ParseInt(os.Getenv(k), 10, 64); err == nil && v >= 0 {
+		return v
+	}
+	return def
+}
 
 // Load reads configuration from environment variables with sensible defaults
 func Load() *Config {
@@ -49,12 +158,60 @@ func Load() *Config {
 	rabbitMQConfig.ConsumerQueue = getenv("RABBITMQ_CONSUMER_QUEUE", "user.transferred")
 	rabbitMQConfig.AuthenticationRequestQueue = getenv("RABBITMQ_AUTH_REQUEST_QUEUE", "document.authentication.requested")
 	rabbitMQConfig.AuthenticationResultQueue = getenv("RABBITMQ_AUTH_RESULT_QUEUE", "document.authentication.completed")
+	rabbitMQConfig.DownloadRequestQueue = getenv("RABBITMQ_DOWNLOAD_REQUEST_QUEUE", "documents.download.requested")
+	rabbitMQConfig.DocumentsReadyQueue = getenv("RABBITMQ_DOCUMENTS_READY_QUEUE", "documents.ready")
+	rabbitMQConfig.EventsExchange = getenv("RABBITMQ_EVENTS_EXCHANGE", "document.events")
+	rabbitMQConfig.TopologyFile = getenv("RABBITMQ_TOPOLOGY_FILE", "")
+	rabbitMQConfig.PublishConfirmTimeout = getduration("RABBITMQ_PUBLISH_CONFIRM_TIMEOUT", rabbitMQConfig.PublishConfirmTimeout)
+	rabbitMQConfig.WorkersPerQueue = getint("RABBITMQ_WORKERS_PER_QUEUE", rabbitMQConfig.WorkersPerQueue)
+	rabbitMQConfig.MaxDeliveryAttempts = getint("RABBITMQ_MAX_DELIVERY_ATTEMPTS", rabbitMQConfig.MaxDeliveryAttempts)
+	rabbitMQConfig.RetryBaseDelay = getduration("RABBITMQ_RETRY_BASE_DELAY", rabbitMQConfig.RetryBaseDelay)
+	rabbitMQConfig.RetryMaxDelay = getduration("RABBITMQ_RETRY_MAX_DELAY", rabbitMQConfig.RetryMaxDelay)
+
+	kafkaConfig := DefaultKafkaConfig()
+	kafkaConfig.Brokers = getlist("KAFKA_BROKERS")
+	kafkaConfig.ClientID = getenv("KAFKA_CLIENT_ID", kafkaConfig.ClientID)
+	kafkaConfig.GroupID = getenv("KAFKA_GROUP_ID", kafkaConfig.GroupID)
+	kafkaConfig.Topics = getmap("KAFKA_TOPICS")
+	kafkaConfig.AutoCreateTopics = getbool("KAFKA_AUTO_CREATE_TOPICS")
+	kafkaConfig.ProduceTimeout = getduration("KAFKA_PRODUCE_TIMEOUT", kafkaConfig.ProduceTimeout)
+	kafkaConfig.MaxDeliveryAttempts = getint("KAFKA_MAX_DELIVERY_ATTEMPTS", kafkaConfig.MaxDeliveryAttempts)
+	kafkaConfig.RetryBaseDelay = getduration("KAFKA_RETRY_BASE_DELAY", kafkaConfig.RetryBaseDelay)
+	kafkaConfig.RetryMaxDelay = getduration("KAFKA_RETRY_MAX_DELAY", kafkaConfig.RetryMaxDelay)
+	kafkaConfig.RebalanceTimeout = getduration("KAFKA_REBALANCE_TIMEOUT", kafkaConfig.RebalanceTimeout)
+	kafkaConfig.MaxRetryWait = getduration("KAFKA_MAX_RETRY_WAIT", kafkaConfig.MaxRetryWait)
+
+	quotaConfig := DefaultQuotaConfig()
+	quotaConfig.MaxDocuments = getlimit("QUOTA_MAX_DOCUMENTS", quotaConfig.MaxDocuments)
+	quotaConfig.MaxBytes = getlimit("QUOTA_MAX_BYTES", quotaConfig.MaxBytes)
+	quotaConfig.Overrides = getmap("QUOTA_OVERRIDES")
+
+	standalone := getbool("STANDALONE")
+	storageBackend := getenv("STORAGE_BACKEND", StorageBackendS3)
+	databaseBackend := getenv("DATABASE_BACKEND", DatabaseBackendDynamoDB)
+	if standalone {
+		storageBackend = StorageBackendFS
+		databaseBackend = DatabaseBackendMemory
+	}
 
 	return &Config{
 		Port:                           port,
+		Standalone:                     standalone,
+		DatabaseBackend:                databaseBackend,
+		PostgresURL:                    getenv("POSTGRES_URL", ""),
 		DynamoDBTable:                  getenv("DYNAMODB_TABLE", "documents"),
 		DynamoDBProcessedMessagesTable: getenv("DYNAMODB_PROCESSED_MESSAGES_TABLE", ""),
+		DynamoDBBlobRefsTable:          getenv("DYNAMODB_BLOB_REFS_TABLE", "document_blob_refs"),
+		DynamoDBUploadSessionsTable:    getenv("DYNAMODB_UPLOAD_SESSIONS_TABLE", "document_upload_sessions"),
+		DynamoDBOutboxTable:            getenv("DYNAMODB_OUTBOX_TABLE", "document_outbox"),
+		DynamoDBHashGuardsTable:        getenv("DYNAMODB_HASH_GUARDS_TABLE", "document_hash_guards"),
+		DynamoDBUsageTable:             getenv("DYNAMODB_USAGE_TABLE", "document_owner_usage"),
 		DynamoDBEndpoint:               getenv("DYNAMODB_ENDPOINT", ""),
+		DynamoDBSkipMigrations:         getbool("DYNAMODB_SKIP_MIGRATIONS"),
+		StorageBackend:                 storageBackend,
+		FSStorageRoot:                  getenv("FS_STORAGE_ROOT", "./data/objects"),
+		FSStorageBaseURL:               getenv("FS_STORAGE_BASE_URL", "http://localhost"+port+"/api/docs/files"),
+		FSStorageSigningKey:            getenv("FS_STORAGE_SIGNING_KEY", ""),
 		AWSAccessKey:                   getenv("AWS_ACCESS_KEY_ID", "local"),
 		AWSSecretKey:                   getenv("AWS_SECRET_ACCESS_KEY", "local"),
 		AWSRegion:                      getenv("AWS_REGION", "us-east-1"),
@@ -62,9 +219,23 @@ func Load() *Config {
 		S3Endpoint:                     getenv("S3_ENDPOINT", ""),
 		S3UsePath:                      getbool("S3_USE_PATH_STYLE"),
 		S3PublicBase:                   getenv("S3_PUBLIC_BASE_URL", ""),
+		MessageBroker:                  getenv("MESSAGE_BROKER", MessageBrokerRabbitMQ),
 		RabbitMQ:                       rabbitMQConfig,
+		Kafka:                          kafkaConfig,
+		MemoryBrokerFile:               getenv("MEMORY_BROKER_FILE", ""),
+		Quotas:                         quotaConfig,
+		UploadSessionTTL:               getduration("UPLOAD_SESSION_TTL", 24*time.Hour),
+		OutboxRelayInterval:            getduration("OUTBOX_RELAY_INTERVAL", time.Second),
+		OutboxMaxAttempts:              getint("OUTBOX_MAX_ATTEMPTS", 10),
+		OutboxRetryMaxDelay:            getduration("OUTBOX_RETRY_MAX_DELAY", 5*time.Minute),
+		TrashRetention:                 getduration("TRASH_RETENTION", 30*24*time.Hour),
+		TransferGracePeriod:            getduration("TRANSFER_GRACE_PERIOD", 7*24*time.Hour),
+		PurgeInterval:                  getduration("PURGE_INTERVAL", time.Hour),
+		IdempotencyLease:               getduration("IDEMPOTENCY_LEASE", 5*time.Minute),
 		ReadHeaderTimeout:              5 * time.Second,
+		ShutdownTimeout:                getduration("SHUTDOWN_TIMEOUT", 10*time.Second),
 		JWTSecret:                      jwtSecret,
+		CursorSigningKey:               getenv("CURSOR_SIGNING_KEY", ""),
 	}
 }
 
@@ -73,6 +244,40 @@ func (c *Config) Validate() error {
 	if c.S3Bucket == "" {
 		return errors.New("S3_BUCKET required")
 	}
+	switch c.MessageBroker {
+	case MessageBrokerRabbitMQ:
+	case MessageBrokerKafka:
+		if len(c.Kafka.Brokers) == 0 && !c.Standalone {
+			return errors.New("KAFKA_BROKERS required when MESSAGE_BROKER=kafka")
+		}
+		if c.Kafka.MaxRetryWait >= c.Kafka.RebalanceTimeout {
+			return errors.New("KAFKA_MAX_RETRY_WAIT must be below KAFKA_REBALANCE_TIMEOUT")
+		}
+	default:
+		return fmt.Errorf("unsupported MESSAGE_BROKER %q", c.MessageBroker)
+	}
+	switch c.DatabaseBackend {
+	case DatabaseBackendDynamoDB, DatabaseBackendMemory:
+	case DatabaseBackendPostgres:
+		if c.PostgresURL == "" {
+			return errors.New("POSTGRES_URL required when DATABASE_BACKEND=postgres")
+		}
+	default:
+		return fmt.Errorf("unsupported DATABASE_BACKEND %q", c.DatabaseBackend)
+	}
+	if _, err := c.Quotas.Policy(); err != nil {
+		return err
+	}
+	switch c.StorageBackend {
+	case StorageBackendS3:
+	case StorageBackendFS:
+		if c.FSStorageRoot == "" {
+			return errors.New("FS_STORAGE_ROOT required when STORAGE_BACKEND=fs")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported STORAGE_BACKEND %q", c.StorageBackend)
+	}
 	if c.S3Endpoint != "" && !c.S3UsePath {
 		return errors.New("S3_USE_PATH_STYLE=true required when using S3_ENDPOINT (MinIO)")
 	}
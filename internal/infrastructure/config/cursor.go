@@ -0,0 +1,28 @@
+package config
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/util"
+)
+
+// NewCursorCodec creates the codec signing list cursors with cfg.CursorSigningKey. Every replica
+// must share the key, so it is required outside STANDALONE mode, where a random key is used
+// instead and cursors do not survive restarts
+func NewCursorCodec(cfg Config) (*util.CursorCodec, error) {
+	signingKey := []byte(cfg.CursorSigningKey)
+	if len(signingKey) == 0 {
+		if !cfg.Standalone {
+			return nil, errors.New("CURSOR_SIGNING_KEY required outside STANDALONE mode")
+		}
+		log.Println("warning: CURSOR_SIGNING_KEY not configured, list cursors are invalidated on restart")
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, fmt.Errorf("failed to generate cursor signing key: %w", err)
+		}
+	}
+	return util.NewCursorCodec(signingKey), nil
+}
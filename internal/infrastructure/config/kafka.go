@@ -0,0 +1,67 @@
+package config
+
+import "time"
+
+// Message brokers selectable with MESSAGE_BROKER
+const (
+	MessageBrokerRabbitMQ = "rabbitmq"
+	MessageBrokerKafka    = "kafka"
+)
+
+// KafkaConfig holds Kafka configuration
+type KafkaConfig struct {
+	Brokers  []string
+	ClientID string
+
+	// GroupID prefixes the consumer group of every subscription; each topic is consumed by the
+	// group "<GroupID>.<topic>", so the instances of the service share its partitions
+	GroupID string
+
+	// Topics maps event types to the topic they are published to and consumed from; event types
+	// missing from it use their own name as topic
+	Topics map[string]string
+
+	// AutoCreateTopics lets producing to a missing topic create it, for local development
+	AutoCreateTopics bool
+
+	// ProduceTimeout bounds how long a publish waits for the brokers to acknowledge the record
+	ProduceTimeout time.Duration
+
+	// Retry settings: a failed record is retried in place after RetryBaseDelay, doubling up to
+	// RetryMaxDelay, and goes to the topic's dead-letter topic after MaxDeliveryAttempts deliveries
+	MaxDeliveryAttempts int
+	RetryBaseDelay      time.Duration
+	RetryMaxDelay       time.Duration
+
+	// RebalanceTimeout is how long the group waits for a member to release its partitions
+	RebalanceTimeout time.Duration
+
+	// MaxRetryWait bounds the backoff waited in place between two polls, while no rebalance can
+	// take place; a record whose next retry would go past it is dead-lettered sooner. It must stay
+	// below RebalanceTimeout, or the member is dropped from the group
+	MaxRetryWait time.Duration
+}
+
+// DefaultKafkaConfig returns sensible defaults for Kafka
+func DefaultKafkaConfig() KafkaConfig {
+	return KafkaConfig{
+		ClientID:       "document-management-microservice",
+		GroupID:        "document-management-microservice",
+		Topics:         map[string]string{},
+		ProduceTimeout: 10 * time.Second,
+
+		MaxDeliveryAttempts: 5,
+		RetryBaseDelay:      time.Second,
+		RetryMaxDelay:       10 * time.Second,
+		RebalanceTimeout:    time.Minute,
+		MaxRetryWait:        30 * time.Second,
+	}
+}
+
+// Topic returns the topic of an event type
+func (cfg KafkaConfig) Topic(eventType string) string {
+	if topic, ok := cfg.Topics[eventType]; ok && topic != "" {
+		return topic
+	}
+	return eventType
+}
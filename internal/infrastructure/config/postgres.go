@@ -0,0 +1,31 @@
+package config
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Database backends selectable with DATABASE_BACKEND
+const (
+	DatabaseBackendDynamoDB = "dynamodb"
+	DatabaseBackendPostgres = "postgres"
+
+	// DatabaseBackendMemory keeps everything in memory; it is selected by standalone mode
+	DatabaseBackendMemory = "memory"
+)
+
+// NewPostgresPool creates a connection pool to the PostgreSQL database at url and checks that
+// the database is reachable
+func NewPostgresPool(ctx context.Context, url string) (*pgxpool.Pool, error) {
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure PostgreSQL pool: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+	return pool, nil
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// QuotaConfig holds the storage quotas of the owners
+type QuotaConfig struct {
+	// MaxDocuments and MaxBytes are the default quota of every owner; 0 is unlimited
+	MaxDocuments int64
+	MaxBytes     int64
+
+	// Overrides maps owner IDs to their own quota, as "<max documents>:<max bytes>"
+	Overrides map[string]string
+}
+
+// DefaultQuotaConfig returns sensible defaults for quotas
+func DefaultQuotaConfig() QuotaConfig {
+	return QuotaConfig{
+		MaxDocuments: 1000,
+		MaxBytes:     1 << 30,
+		Overrides:    map[string]string{},
+	}
+}
+
+// Policy parses the quotas into the policy enforced on uploads
+func (cfg QuotaConfig) Policy() (models.QuotaPolicy, error) {
+	policy := models.QuotaPolicy{
+		Default:   models.Quota{MaxDocuments: cfg.MaxDocuments, MaxBytes: cfg.MaxBytes},
+		Overrides: make(map[int64]models.Quota, len(cfg.Overrides)),
+	}
+	if cfg.MaxDocuments < 0 || cfg.MaxBytes < 0 {
+		return models.QuotaPolicy{}, fmt.Errorf("invalid default quota: limits must not be negative")
+	}
+
+	for owner, limits := range cfg.Overrides {
+		ownerID, err := strconv.ParseInt(owner, 10, 64)
+		if err != nil || ownerID <= 0 {
+			return models.QuotaPolicy{}, fmt.Errorf("invalid quota override: owner %q is not an owner ID", owner)
+		}
+		documents, bytes, ok := strings.Cut(limits, ":")
+		if !ok {
+			return models.QuotaPolicy{}, fmt.Errorf("invalid quota override of owner %d: expected <max documents>:<max bytes>, got %q", ownerID, limits)
+		}
+		var quota models.Quota
+		if quota.MaxDocuments, err = strconv.ParseInt(documents, 10, 64); err != nil || quota.MaxDocuments < 0 {
+			return models.QuotaPolicy{}, fmt.Errorf("invalid quota override of owner %d: bad max documents %q", ownerID, documents)
+		}
+		if quota.MaxBytes, err = strconv.ParseInt(bytes, 10, 64); err != nil || quota.MaxBytes < 0 {
+			return models.QuotaPolicy{}, fmt.Errorf("invalid quota override of owner %d: bad max bytes %q", ownerID, bytes)
+		}
+		policy.Overrides[ownerID] = quota
+	}
+	return policy, nil
+}
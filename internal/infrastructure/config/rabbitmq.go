@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 // RabbitMQConfig holds RabbitMQ configuration
 type RabbitMQConfig struct {
 	URL string
@@ -13,10 +15,33 @@ type RabbitMQConfig struct {
 	// Consumer queue for authentication results
 	AuthenticationResultQueue string
 
+	// Consumer queue for document download requests, and the queue their results are published to
+	DownloadRequestQueue string
+	DocumentsReadyQueue  string
+
+	// EventsExchange is the topic exchange the events of the service are published to, routed by
+	// their event type
+	EventsExchange string
+
+	// TopologyFile is a JSON Topology replacing the default one built from the names above
+	TopologyFile string
+
 	// Queue settings
 	Durable       bool
 	PrefetchCount int
 	AutoAck       bool
+
+	// PublishConfirmTimeout bounds how long a publish waits for the broker confirmation
+	PublishConfirmTimeout time.Duration
+
+	// WorkersPerQueue is the number of messages of a queue handled concurrently
+	WorkersPerQueue int
+
+	// Retry settings: a failed message is retried after RetryBaseDelay, doubling up to RetryMaxDelay,
+	// and goes to the queue's dead-letter queue after MaxDeliveryAttempts deliveries
+	MaxDeliveryAttempts int
+	RetryBaseDelay      time.Duration
+	RetryMaxDelay       time.Duration
 }
 
 // DefaultRabbitMQConfig returns sensible defaults for RabbitMQ
@@ -25,5 +50,12 @@ func DefaultRabbitMQConfig() RabbitMQConfig {
 		Durable:       true,  // Queues persist across restarts
 		PrefetchCount: 1,     // Process 1 message at a time per consumer
 		AutoAck:       false, // Manual acknowledgment for reliability
+
+		PublishConfirmTimeout: 5 * time.Second,
+		WorkersPerQueue:       1,
+
+		MaxDeliveryAttempts: 5,
+		RetryBaseDelay:      time.Second,
+		RetryMaxDelay:       5 * time.Minute,
 	}
 }
@@ -10,12 +10,13 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/metrics"
 	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/storage"
 )
 
 // NewS3Client creates a new S3 client with the provided configuration
 // Supports both AWS S3 and S3-compatible storage (MinIO)
-func NewS3Client(ctx context.Context, cfg Config) (*storage.S3Client, error) {
+func NewS3Client(ctx context.Context, cfg Config, metricsCollector *metrics.PrometheusMetrics) (*storage.S3Client, error) {
 	var configLoaders []func(*awscfg.LoadOptions) error
 
 	if cfg.AWSAccessKey != "" && cfg.AWSSecretKey != "" {
@@ -53,5 +54,5 @@ func NewS3Client(ctx context.Context, cfg Config) (*storage.S3Client, error) {
 
 	s3APIClient := s3.NewFromConfig(awsConfig, clientOptions...)
 
-	return storage.NewS3Client(cfg.S3Bucket, cfg.S3PublicBase, s3APIClient), nil
+	return storage.NewS3Client(cfg.S3Bucket, cfg.S3PublicBase, s3APIClient, metricsCollector), nil
 }
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/metrics"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/storage"
+)
+
+// Storage backends selectable with STORAGE_BACKEND
+const (
+	StorageBackendS3 = "s3"
+	StorageBackendFS = "fs"
+)
+
+// NewObjectStorage creates the object storage selected by cfg.StorageBackend
+func NewObjectStorage(ctx context.Context, cfg Config, metricsCollector *metrics.PrometheusMetrics) (interfaces.ObjectStorage, error) {
+	if cfg.StorageBackend == StorageBackendFS {
+		return NewFileSystemStorage(cfg)
+	}
+
+	s3Client, err := NewS3Client(ctx, cfg, metricsCollector)
+	if err != nil {
+		return nil, err
+	}
+	return s3Client, nil
+}
+
+// NewFileSystemStorage creates a local filesystem storage under cfg.FSStorageRoot
+// Without FS_STORAGE_SIGNING_KEY a random key is used, so signed URLs do not survive restarts
+func NewFileSystemStorage(cfg Config) (interfaces.ObjectStorage, error) {
+	signingKey := []byte(cfg.FSStorageSigningKey)
+	if len(signingKey) == 0 {
+		log.Println("warning: FS_STORAGE_SIGNING_KEY not configured, signed URLs are invalidated on restart")
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+	}
+
+	fsStorage, err := storage.NewFileSystemStorage(cfg.FSStorageRoot, cfg.S3Bucket, cfg.FSStorageBaseURL, signingKey)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Filesystem storage initialized (root: %s)", cfg.FSStorageRoot)
+	return fsStorage, nil
+}
@@ -0,0 +1,27 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+)
+
+func TestNewCursorCodec_RequiresASigningKeyOutsideStandalone(t *testing.T) {
+	_, err := config.NewCursorCodec(config.Config{})
+	assert.ErrorContains(t, err, "CURSOR_SIGNING_KEY")
+
+	standalone, err := config.NewCursorCodec(config.Config{Standalone: true})
+	require.NoError(t, err)
+	assert.NotNil(t, standalone, "standalone mode signs with a random key")
+
+	first, err := config.NewCursorCodec(config.Config{CursorSigningKey: "shared"})
+	require.NoError(t, err)
+	second, err := config.NewCursorCodec(config.Config{CursorSigningKey: "shared"})
+	require.NoError(t, err)
+	position, err := second.Decode(7, first.Encode(7, "position"))
+	require.NoError(t, err)
+	assert.Equal(t, "position", position, "replicas sharing the key accept each other's cursors")
+}
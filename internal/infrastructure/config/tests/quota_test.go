@@ -0,0 +1,36 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+)
+
+func TestQuotaConfig_Policy(t *testing.T) {
+	cfg := config.DefaultQuotaConfig()
+	cfg.Overrides = map[string]string{"42": "0:5368709120", "7": "10:0"}
+
+	policy, err := cfg.Policy()
+	require.NoError(t, err)
+	assert.Equal(t, models.Quota{MaxDocuments: 1000, MaxBytes: 1 << 30}, policy.For(1))
+	assert.Equal(t, models.Quota{MaxBytes: 5 << 30}, policy.For(42))
+	assert.Equal(t, models.Quota{MaxDocuments: 10}, policy.For(7))
+}
+
+func TestQuotaConfig_PolicyRejectsInvalidOverrides(t *testing.T) {
+	for _, overrides := range []map[string]string{
+		{"citizen": "10:100"},
+		{"42": "10"},
+		{"42": "ten:100"},
+		{"42": "10:-1"},
+	} {
+		cfg := config.DefaultQuotaConfig()
+		cfg.Overrides = overrides
+		_, err := cfg.Policy()
+		assert.Error(t, err, "overrides %v", overrides)
+	}
+}
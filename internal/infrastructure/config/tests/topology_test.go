@@ -0,0 +1,155 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+)
+
+func testRabbitMQConfig() config.RabbitMQConfig {
+	return config.RabbitMQConfig{
+		EventsExchange:             "document.events",
+		ConsumerQueue:              "user.transferred",
+		AuthenticationRequestQueue: "document.authentication.requested",
+		AuthenticationResultQueue:  "document.authentication.completed",
+		DownloadRequestQueue:       "documents.download.requested",
+		DocumentsReadyQueue:        "documents.ready",
+	}
+}
+
+func TestLoadTopology_DefaultsToTheConfiguredQueues(t *testing.T) {
+	topology, err := testRabbitMQConfig().LoadTopology()
+	require.NoError(t, err)
+
+	route, ok := topology.Route(events.TypeDocumentAuthenticationRequested)
+	require.True(t, ok)
+	assert.Equal(t, config.RouteTopology{Exchange: "document.events", RoutingKey: events.TypeDocumentAuthenticationRequested, Mandatory: true}, route)
+
+	route, ok = topology.Route(events.TypeDocumentUploaded)
+	require.True(t, ok)
+	assert.False(t, route.Mandatory, "lifecycle events may have no subscribers")
+
+	_, ok = topology.Route(events.TypeUserTransferred)
+	assert.False(t, ok, "consumed event types are not published")
+
+	assert.Equal(t, "user.transferred", topology.ConsumerQueue(events.TypeUserTransferred))
+	assert.Equal(t, "document.authentication.completed", topology.ConsumerQueue(events.TypeDocumentAuthenticationCompleted))
+	assert.Equal(t, "documents.download.requested", topology.ConsumerQueue(events.TypeDocumentDownloadRequested))
+}
+
+func TestLoadTopology_ReadsTheTopologyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "topology.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"exchanges": [{"name": "events", "kind": "topic"}, {"name": "events.dlx", "kind": "fanout"}],
+		"queues": [
+			{"name": "ready", "type": "quorum", "messageTtl": "30s", "deadLetterExchange": "events.dlx", "deadLetterRoutingKey": "expired"},
+			{"name": "events.dead"}
+		],
+		"bindings": [
+			{"exchange": "events", "queue": "ready", "routingKey": "documents.ready"},
+			{"exchange": "events.dlx", "queue": "events.dead", "routingKey": ""}
+		],
+		"routes": {"documents.ready": {"exchange": "events", "routingKey": "ready.v1", "mandatory": true}},
+		"consumers": {"user.transferred": "ready"}
+	}`), 0o600))
+
+	cfg := testRabbitMQConfig()
+	cfg.TopologyFile = path
+	topology, err := cfg.LoadTopology()
+	require.NoError(t, err)
+
+	queue, ok := topology.Queue("ready")
+	require.True(t, ok)
+	assert.Equal(t, config.QueueTypeQuorum, queue.Type)
+	assert.Equal(t, 30*time.Second, time.Duration(queue.MessageTTL))
+	assert.Equal(t, "events.dlx", queue.DeadLetterExchange)
+	assert.Equal(t, "expired", queue.DeadLetterRoutingKey)
+
+	route, ok := topology.Route(events.TypeDocumentsReady)
+	require.True(t, ok)
+	assert.Equal(t, "ready.v1", route.RoutingKey)
+
+	_, ok = topology.Route(events.TypeDocumentUploaded)
+	assert.False(t, ok, "the file replaces the default topology")
+	assert.Equal(t, "ready", topology.ConsumerQueue(events.TypeUserTransferred))
+}
+
+func TestLoadTopology_RejectsInvalidFiles(t *testing.T) {
+	write := func(t *testing.T, content string) config.RabbitMQConfig {
+		path := filepath.Join(t.TempDir(), "topology.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		cfg := testRabbitMQConfig()
+		cfg.TopologyFile = path
+		return cfg
+	}
+
+	_, err := write(t, `{"queues": [{"name": "q", "messageTtl": 30}]}`).LoadTopology()
+	assert.ErrorContains(t, err, "duration must be a string")
+
+	cfg := testRabbitMQConfig()
+	cfg.TopologyFile = filepath.Join(t.TempDir(), "missing.json")
+	_, err = cfg.LoadTopology()
+	assert.ErrorContains(t, err, "failed to read RabbitMQ topology")
+}
+
+func TestTopologyValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		topology config.Topology
+		err      string
+	}{
+		{
+			name:     "unknown exchange kind",
+			topology: config.Topology{Exchanges: []config.ExchangeTopology{{Name: "events", Kind: "random"}}},
+			err:      `exchange events has unknown kind "random"`,
+		},
+		{
+			name:     "queue declared twice",
+			topology: config.Topology{Queues: []config.QueueTopology{{Name: "q"}, {Name: "q"}}},
+			err:      "queue q is declared twice",
+		},
+		{
+			name:     "unknown queue type",
+			topology: config.Topology{Queues: []config.QueueTopology{{Name: "q", Type: "stream"}}},
+			err:      `queue q has unknown type "stream"`,
+		},
+		{
+			name: "binding to an undeclared exchange",
+			topology: config.Topology{
+				Queues:   []config.QueueTopology{{Name: "q"}},
+				Bindings: []config.BindingTopology{{Exchange: "events", Queue: "q", RoutingKey: "#"}},
+			},
+			err: `binding of queue q to undeclared exchange "events"`,
+		},
+		{
+			name:     "route to an undeclared queue",
+			topology: config.Topology{Routes: map[string]config.RouteTopology{"test.event": {}}},
+			err:      `event type test.event is routed to undeclared queue "test.event"`,
+		},
+		{
+			name:     "consumer of an undeclared queue",
+			topology: config.Topology{Consumers: map[string]string{"test.event": "q"}},
+			err:      `event type test.event is consumed from undeclared queue "q"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, tt.topology.Validate(), tt.err)
+		})
+	}
+
+	predeclared := config.Topology{
+		Queues:   []config.QueueTopology{{Name: "q"}},
+		Bindings: []config.BindingTopology{{Exchange: "amq.topic", Queue: "q", RoutingKey: "#"}},
+		Routes:   map[string]config.RouteTopology{"test.event": {Exchange: "amq.topic"}},
+	}
+	assert.NoError(t, predeclared.Validate(), "broker predeclared exchanges need no declaration")
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+)
+
+// Queue types selectable in a QueueTopology
+const (
+	QueueTypeClassic = "classic"
+	QueueTypeQuorum  = "quorum"
+)
+
+// Topology declares the RabbitMQ exchanges, queues and bindings of the service, where each event
+// type it publishes is routed and which queue delivers each event type it consumes. It is applied
+// on every connection, so publishers and consumers only deal with event types
+type Topology struct {
+	Exchanges []ExchangeTopology `json:"exchanges"`
+	Queues    []QueueTopology    `json:"queues"`
+	Bindings  []BindingTopology  `json:"bindings"`
+
+	// Routes maps the event types published by the service to their destination
+	Routes map[string]RouteTopology `json:"routes"`
+
+	// Consumers maps the event types consumed by the service to the queue delivering them
+	Consumers map[string]string `json:"consumers"`
+}
+
+// ExchangeTopology declares an exchange
+type ExchangeTopology struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"` // direct, fanout, topic (default) or headers
+}
+
+// QueueTopology declares a queue. Changing the arguments of an existing queue is refused by the
+// broker, so the queue has to be deleted or migrated first
+type QueueTopology struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"` // classic (default) or quorum
+
+	// MessageTTL expires messages left in the queue for longer, dead-lettering them if configured
+	MessageTTL Duration `json:"messageTtl,omitempty"`
+
+	// DeadLetterExchange receives the messages the queue expires or rejects, with
+	// DeadLetterRoutingKey instead of their own routing key when set
+	DeadLetterExchange   string `json:"deadLetterExchange,omitempty"`
+	DeadLetterRoutingKey string `json:"deadLetterRoutingKey,omitempty"`
+}
+
+// BindingTopology routes the messages of an exchange matching RoutingKey to a queue
+type BindingTopology struct {
+	Exchange   string `json:"exchange"`
+	Queue      string `json:"queue"`
+	RoutingKey string `json:"routingKey"`
+}
+
+// RouteTopology is the destination of a published event type. An empty Exchange is the default
+// exchange, whose routing keys are queue names. Mandatory events fail to publish when no queue
+// takes them; the others are dropped silently, as events nobody subscribed to yet
+type RouteTopology struct {
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routingKey,omitempty"` // defaults to the event type
+	Mandatory  bool   `json:"mandatory,omitempty"`
+}
+
+// Duration is a time.Duration read from JSON as a Go duration string such as "30s"
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes a duration string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// DefaultTopology returns the topology matching the queue names of cfg: the service's own events
+// go to the events exchange with their type as routing key, and the queues of the authentication
+// requests and download results are bound to it
+func DefaultTopology(cfg RabbitMQConfig) Topology {
+	queue := func(name string) QueueTopology {
+		return QueueTopology{Name: name, Type: QueueTypeClassic}
+	}
+	route := func(mandatory bool) RouteTopology {
+		return RouteTopology{Exchange: cfg.EventsExchange, Mandatory: mandatory}
+	}
+
+	return Topology{
+		Exchanges: []ExchangeTopology{{Name: cfg.EventsExchange, Kind: "topic"}},
+		Queues: []QueueTopology{
+			queue(cfg.AuthenticationRequestQueue),
+			queue(cfg.DocumentsReadyQueue),
+			queue(cfg.ConsumerQueue),
+			queue(cfg.AuthenticationResultQueue),
+			queue(cfg.DownloadRequestQueue),
+		},
+		Bindings: []BindingTopology{
+			{Exchange: cfg.EventsExchange, Queue: cfg.AuthenticationRequestQueue, RoutingKey: events.TypeDocumentAuthenticationRequested},
+			{Exchange: cfg.EventsExchange, Queue: cfg.DocumentsReadyQueue, RoutingKey: events.TypeDocumentsReady},
+		},
+		Routes: map[string]RouteTopology{
+			// Someone waits for these two: an event no queue takes is an error
+			events.TypeDocumentAuthenticationRequested: route(true),
+			events.TypeDocumentsReady:                  route(true),
+
+			events.TypeDocumentUploaded:                    route(false),
+			events.TypeDocumentDeleted:                     route(false),
+			events.TypeDocumentRestored:                    route(false),
+			events.TypeDocumentsBulkDeleted:                route(false),
+			events.TypeDocumentAuthenticationStatusChanged: route(false),
+		},
+		Consumers: map[string]string{
+			events.TypeUserTransferred:                 cfg.ConsumerQueue,
+			events.TypeDocumentAuthenticationCompleted: cfg.AuthenticationResultQueue,
+			events.TypeDocumentDownloadRequested:       cfg.DownloadRequestQueue,
+		},
+	}
+}
+
+// LoadTopology returns the topology of the file at cfg.TopologyFile, or the default topology when
+// no file is configured, after validating it
+func (cfg RabbitMQConfig) LoadTopology() (Topology, error) {
+	topology := DefaultTopology(cfg)
+	if cfg.TopologyFile != "" {
+		data, err := os.ReadFile(cfg.TopologyFile)
+		if err != nil {
+			return Topology{}, fmt.Errorf("failed to read RabbitMQ topology: %w", err)
+		}
+		topology = Topology{}
+		if err := json.Unmarshal(data, &topology); err != nil {
+			return Topology{}, fmt.Errorf("failed to decode RabbitMQ topology %s: %w", cfg.TopologyFile, err)
+		}
+	}
+
+	if err := topology.Validate(); err != nil {
+		return Topology{}, err
+	}
+	return topology, nil
+}
+
+// Validate checks that the topology is consistent: kinds and queue types are known, and every
+// binding, route and consumer refers to a declared exchange or queue
+func (t Topology) Validate() error {
+	exchanges := map[string]bool{"": true}
+	for _, exchange := range t.Exchanges {
+		if exchange.Name == "" {
+			return errors.New("topology: exchange without name")
+		}
+		switch exchange.Kind {
+		case "", "direct", "fanout", "topic", "headers":
+		default:
+			return fmt.Errorf("topology: exchange %s has unknown kind %q", exchange.Name, exchange.Kind)
+		}
+		exchanges[exchange.Name] = true
+	}
+
+	queues := make(map[string]bool)
+	for _, queue := range t.Queues {
+		if queue.Name == "" {
+			return errors.New("topology: queue without name")
+		}
+		if queues[queue.Name] {
+			return fmt.Errorf("topology: queue %s is declared twice", queue.Name)
+		}
+		switch queue.Type {
+		case "", QueueTypeClassic, QueueTypeQuorum:
+		default:
+			return fmt.Errorf("topology: queue %s has unknown type %q", queue.Name, queue.Type)
+		}
+		if queue.MessageTTL < 0 {
+			return fmt.Errorf("topology: queue %s has a negative message TTL", queue.Name)
+		}
+		queues[queue.Name] = true
+	}
+
+	for _, binding := range t.Bindings {
+		if binding.Exchange == "" || !declared(exchanges, binding.Exchange) {
+			return fmt.Errorf("topology: binding of queue %s to undeclared exchange %q", binding.Queue, binding.Exchange)
+		}
+		if !queues[binding.Queue] {
+			return fmt.Errorf("topology: binding to undeclared queue %s", binding.Queue)
+		}
+	}
+
+	for eventType := range t.Routes {
+		route, _ := t.Route(eventType)
+		if !declared(exchanges, route.Exchange) {
+			return fmt.Errorf("topology: event type %s is routed to undeclared exchange %s", eventType, route.Exchange)
+		}
+		if route.Exchange == "" && !queues[route.RoutingKey] {
+			return fmt.Errorf("topology: event type %s is routed to undeclared queue %q", eventType, route.RoutingKey)
+		}
+	}
+
+	for eventType, queue := range t.Consumers {
+		if !queues[queue] {
+			return fmt.Errorf("topology: event type %s is consumed from undeclared queue %q", eventType, queue)
+		}
+	}
+	return nil
+}
+
+// declared reports whether an exchange is declared by the topology or predeclared by the broker
+func declared(exchanges map[string]bool, name string) bool {
+	return exchanges[name] || strings.HasPrefix(name, "amq.")
+}
+
+// Route returns the destination of a published event type, with its routing key resolved
+func (t Topology) Route(eventType string) (RouteTopology, bool) {
+	route, ok := t.Routes[eventType]
+	if !ok {
+		return RouteTopology{}, false
+	}
+	if route.RoutingKey == "" {
+		route.RoutingKey = eventType
+	}
+	return route, true
+}
+
+// Queue returns the declaration of a queue
+func (t Topology) Queue(name string) (QueueTopology, bool) {
+	for _, queue := range t.Queues {
+		if queue.Name == name {
+			return queue, true
+		}
+	}
+	return QueueTopology{}, false
+}
+
+// ConsumerQueue returns the queue delivering a consumed event type, or "" if the service does not
+// consume it
+func (t Topology) ConsumerQueue(eventType string) string {
+	return t.Consumers[eventType]
+}
@@ -0,0 +1,152 @@
+package messaging
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+)
+
+const (
+	// cloudEventsHeaderPrefix prefixes the CloudEvents attributes carried as AMQP headers, following
+	// the binary content mode of the CloudEvents AMQP binding. datacontenttype maps to the content type
+	cloudEventsHeaderPrefix = "cloudEvents:"
+
+	// kafkaCloudEventsHeaderPrefix prefixes the CloudEvents attributes carried as Kafka record headers,
+	// following the binary content mode of the CloudEvents Kafka binding
+	kafkaCloudEventsHeaderPrefix = "ce_"
+
+	// kafkaContentTypeHeader carries datacontenttype in the Kafka binding
+	kafkaContentTypeHeader = "content-type"
+)
+
+// EventPublishing builds the AMQP message carrying an event in binary mode: the attributes go in
+// the headers and the payload is the body
+func EventPublishing(event events.Envelope) amqp091.Publishing {
+	headers := amqp091.Table{
+		cloudEventsHeaderPrefix + "id":          event.ID,
+		cloudEventsHeaderPrefix + "source":      event.Source,
+		cloudEventsHeaderPrefix + "type":        event.Type,
+		cloudEventsHeaderPrefix + "specversion": event.SpecVersion,
+		cloudEventsHeaderPrefix + "time":        event.Time.UTC().Format(time.RFC3339Nano),
+		// Kept for consumers that deduplicate on the header used before envelopes were introduced
+		"x-message-id": event.ID,
+	}
+	if event.DataSchema != "" {
+		headers[cloudEventsHeaderPrefix+"dataschema"] = event.DataSchema
+	}
+	if event.PartitionKey != "" {
+		headers[cloudEventsHeaderPrefix+"partitionkey"] = event.PartitionKey
+	}
+
+	return amqp091.Publishing{
+		DeliveryMode: amqp091.Persistent,
+		ContentType:  event.DataContentType,
+		MessageId:    event.ID,
+		Type:         event.Type,
+		Timestamp:    event.Time,
+		Headers:      headers,
+		Body:         event.Data,
+	}
+}
+
+// DeliveryEvent reads the event carried by a delivery in binary mode. Messages without CloudEvents
+// headers become an envelope holding only their message ID and payload
+func DeliveryEvent(delivery amqp091.Delivery) events.Envelope {
+	header := func(name string) string {
+		value, _ := delivery.Headers[cloudEventsHeaderPrefix+name].(string)
+		return value
+	}
+
+	event := events.Envelope{
+		ID:              header("id"),
+		Source:          header("source"),
+		Type:            header("type"),
+		SpecVersion:     header("specversion"),
+		DataSchema:      header("dataschema"),
+		DataContentType: delivery.ContentType,
+		Data:            delivery.Body,
+		PartitionKey:    header("partitionkey"),
+	}
+	if t, err := time.Parse(time.RFC3339Nano, header("time")); err == nil {
+		event.Time = t
+	}
+
+	if event.ID == "" {
+		event.ID = delivery.MessageId
+	}
+	if event.ID == "" {
+		event.ID, _ = delivery.Headers["x-message-id"].(string)
+	}
+	return event
+}
+
+// EventRecord builds the Kafka record carrying an event to a topic in binary mode: the attributes go
+// in the headers, the payload is the value and the partition key is the record key, so the events
+// of a citizen land on the same partition
+func EventRecord(topic string, event events.Envelope) *kgo.Record {
+	attributes := []struct{ name, value string }{
+		{"id", event.ID},
+		{"source", event.Source},
+		{"type", event.Type},
+		{"specversion", event.SpecVersion},
+		{"time", event.Time.UTC().Format(time.RFC3339Nano)},
+		{"dataschema", event.DataSchema},
+		{"partitionkey", event.PartitionKey},
+	}
+
+	record := &kgo.Record{Topic: topic, Value: event.Data}
+	for _, attribute := range attributes {
+		if attribute.value != "" {
+			record.Headers = append(record.Headers, kgo.RecordHeader{Key: kafkaCloudEventsHeaderPrefix + attribute.name, Value: []byte(attribute.value)})
+		}
+	}
+	if event.DataContentType != "" {
+		record.Headers = append(record.Headers, kgo.RecordHeader{Key: kafkaContentTypeHeader, Value: []byte(event.DataContentType)})
+	}
+	if event.PartitionKey != "" {
+		record.Key = []byte(event.PartitionKey)
+	}
+	return record
+}
+
+// RecordEvent reads the event carried by a Kafka record in binary mode. Records without CloudEvents
+// headers become an envelope holding only their payload, identified by their topic, partition and offset
+func RecordEvent(record *kgo.Record) events.Envelope {
+	header := func(name string) string {
+		for _, h := range record.Headers {
+			if h.Key == name {
+				return string(h.Value)
+			}
+		}
+		return ""
+	}
+	attribute := func(name string) string {
+		return header(kafkaCloudEventsHeaderPrefix + name)
+	}
+
+	event := events.Envelope{
+		ID:              attribute("id"),
+		Source:          attribute("source"),
+		Type:            attribute("type"),
+		SpecVersion:     attribute("specversion"),
+		DataSchema:      attribute("dataschema"),
+		DataContentType: header(kafkaContentTypeHeader),
+		Data:            record.Value,
+		PartitionKey:    attribute("partitionkey"),
+	}
+	if t, err := time.Parse(time.RFC3339Nano, attribute("time")); err == nil {
+		event.Time = t
+	}
+
+	if event.ID == "" {
+		event.ID = fmt.Sprintf("%s-%d-%d", record.Topic, record.Partition, record.Offset)
+	}
+	if event.PartitionKey == "" {
+		event.PartitionKey = string(record.Key)
+	}
+	return event
+}
@@ -0,0 +1,384 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+)
+
+// kafkaPollRecords caps the records handled between two polls, which bounds how long a rebalance
+// waits for the subscription
+const kafkaPollRecords = 100
+
+// KafkaConsumer implements the MessageConsumer interface for Kafka. Every subscription joins the
+// consumer group of its topic and handles its records one at a time, committing each offset once
+// the handler succeeds. A failed record is retried in place after the policy's backoff delay, so
+// the records behind it on the partition, those of the same citizen, keep their order; once the
+// policy gives up it goes to the topic's dead-letter topic. A retry that would outlast MaxRetryWait
+// is not waited for in place: the partition is rewound to the record and paused for the delay, so
+// the group can rebalance meanwhile
+type KafkaConsumer struct {
+	config        config.KafkaConfig
+	policy        RetryPolicy
+	mu            sync.Mutex
+	subscriptions []*kafkaSubscription
+	stopping      bool
+}
+
+// kafkaSubscription consumes one topic with a client of its own
+type kafkaSubscription struct {
+	consumer *KafkaConsumer
+	topic    string
+	handler  interfaces.MessageHandler
+	client   *kgo.Client
+
+	// handlerCtx is passed to handlers; it is canceled when the shutdown deadline expires
+	handlerCtx    context.Context
+	cancelHandler context.CancelFunc
+
+	// pollCtx is canceled to interrupt a poll waiting for records
+	pollCtx    context.Context
+	cancelPoll context.CancelFunc
+
+	// retries holds the attempts of the records whose partition was rewound to them, by
+	// partition; it is only used by the polling goroutine
+	retries map[int32]kafkaRetry
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// kafkaRetry is the attempt a record of a rewound partition failed at
+type kafkaRetry struct {
+	offset  int64
+	attempt int
+}
+
+// kafkaOutcome is how the handling of a record ended
+type kafkaOutcome int
+
+const (
+	// kafkaRecordDone means the record was handled or dead-lettered, and committed
+	kafkaRecordDone kafkaOutcome = iota
+	// kafkaRecordRewound means the partition was rewound to the record to retry it after a pause
+	kafkaRecordRewound
+	// kafkaRecordStopped means the subscription stopped before the record was done with
+	kafkaRecordStopped
+)
+
+// NewKafkaConsumer creates a new Kafka message consumer retrying failed records according to policy.
+// A MaxRetryWait not below the rebalance timeout is cut to half of it, so that waiting in place
+// never drops the consumer from its group
+func NewKafkaConsumer(cfg config.KafkaConfig, policy RetryPolicy) (*KafkaConsumer, error) {
+	if cfg.RebalanceTimeout <= 0 {
+		cfg.RebalanceTimeout = config.DefaultKafkaConfig().RebalanceTimeout
+	}
+	if cfg.MaxRetryWait >= cfg.RebalanceTimeout {
+		log.Printf("KAFKA_MAX_RETRY_WAIT %v is not below KAFKA_REBALANCE_TIMEOUT %v, using %v", cfg.MaxRetryWait, cfg.RebalanceTimeout, cfg.RebalanceTimeout/2)
+		cfg.MaxRetryWait = cfg.RebalanceTimeout / 2
+	}
+	return &KafkaConsumer{config: cfg, policy: policy}, nil
+}
+
+// SubscribeToQueue starts consuming the topic named queueName with the provided handler. The
+// subscription stops when ctx is canceled or the consumer shuts down
+func (k *KafkaConsumer) SubscribeToQueue(ctx context.Context, queueName string, handler interfaces.MessageHandler) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.stopping {
+		return ErrConsumerStopped
+	}
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(k.config.Brokers...),
+		kgo.ClientID(k.config.ClientID),
+		kgo.ConsumerGroup(KafkaGroupName(k.config.GroupID, queueName)),
+		kgo.ConsumeTopics(queueName),
+		kgo.DisableAutoCommit(),
+		// Partitions are not reassigned while a polled batch is being handled, which the retries
+		// waited in place keep below the rebalance timeout
+		kgo.BlockRebalanceOnPoll(),
+		kgo.RebalanceTimeout(k.config.RebalanceTimeout),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+	}
+	if k.config.AutoCreateTopics {
+		opts = append(opts, kgo.AllowAutoTopicCreation())
+	}
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create Kafka consumer for topic %s: %w", queueName, err)
+	}
+
+	handlerCtx, cancelHandler := context.WithCancel(context.WithoutCancel(ctx))
+	pollCtx, cancelPoll := context.WithCancel(context.Background())
+	sub := &kafkaSubscription{
+		consumer:      k,
+		topic:         queueName,
+		handler:       handler,
+		client:        client,
+		handlerCtx:    handlerCtx,
+		cancelHandler: cancelHandler,
+		pollCtx:       pollCtx,
+		cancelPoll:    cancelPoll,
+		retries:       make(map[int32]kafkaRetry),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	k.subscriptions = append(k.subscriptions, sub)
+	go sub.run()
+
+	// Canceling the subscription context stops consuming; the record being handled still finishes
+	context.AfterFunc(ctx, sub.stopConsuming)
+
+	log.Printf("Kafka consumer subscribed to topic: %s (group %s)", queueName, KafkaGroupName(k.config.GroupID, queueName))
+	return nil
+}
+
+// Shutdown stops consuming from every topic and waits for the records being handled until ctx
+// expires. Handlers still running at the deadline see their context canceled. The clients leave
+// their groups last; records not committed are delivered again to the remaining members, and the
+// commits of handlers finishing after the deadline fail the same way
+func (k *KafkaConsumer) Shutdown(ctx context.Context) error {
+	k.mu.Lock()
+	k.stopping = true
+	subscriptions := append([]*kafkaSubscription(nil), k.subscriptions...)
+	k.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		sub.stopConsuming()
+	}
+
+	var err error
+	for _, sub := range subscriptions {
+		select {
+		case <-sub.done:
+		case <-ctx.Done():
+			err = fmt.Errorf("timed out draining consumer for topic %s: %w", sub.topic, ctx.Err())
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err != nil {
+		for _, sub := range subscriptions {
+			sub.cancelHandler()
+		}
+	}
+
+	for _, sub := range subscriptions {
+		sub.client.CloseAllowingRebalance()
+	}
+
+	if err == nil {
+		log.Println("Kafka consumers drained")
+	}
+	return err
+}
+
+// Close stops every subscription without draining and closes the clients
+func (k *KafkaConsumer) Close() error {
+	k.mu.Lock()
+	k.stopping = true
+	subscriptions := append([]*kafkaSubscription(nil), k.subscriptions...)
+	k.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		sub.stopConsuming()
+		sub.cancelHandler()
+		sub.client.CloseAllowingRebalance()
+	}
+	return nil
+}
+
+// run polls and handles records until the subscription stops
+func (s *kafkaSubscription) run() {
+	defer close(s.done)
+
+	for {
+		fetches := s.client.PollRecords(s.pollCtx, kafkaPollRecords)
+		if s.stopped() || fetches.IsClientClosed() {
+			s.client.AllowRebalance()
+			log.Printf("Stopped consuming from topic: %s", s.topic)
+			return
+		}
+		fetches.EachError(func(topic string, partition int32, err error) {
+			log.Printf("Error fetching from topic %s partition %d: %v", topic, partition, err)
+		})
+
+		// Records left when the subscription stops are not committed and are delivered again.
+		// No rebalance takes place until the next poll, so records are only retried in place until
+		// MaxRetryWait after this one; the records behind a rewound one are delivered again too
+		retryUntil := time.Now().Add(s.consumer.config.MaxRetryWait)
+		rewound := make(map[int32]bool)
+	records:
+		for _, record := range fetches.Records() {
+			if s.stopped() {
+				break
+			}
+			if rewound[record.Partition] {
+				continue
+			}
+			switch s.process(record, retryUntil) {
+			case kafkaRecordRewound:
+				rewound[record.Partition] = true
+			case kafkaRecordStopped:
+				break records
+			}
+		}
+		s.client.AllowRebalance()
+	}
+}
+
+// process handles a record until it succeeds or is dead-lettered, then commits its offset. A record
+// whose next retry would start after retryUntil has its partition rewound to it instead
+func (s *kafkaSubscription) process(record *kgo.Record, retryUntil time.Time) kafkaOutcome {
+	event := RecordEvent(record)
+	policy := s.consumer.policy
+
+	if event.ID != "" {
+		log.Printf("Processing message from topic %s with messageId: %s", s.topic, event.ID)
+	}
+
+	// A record delivered again after a rewind goes on from the attempt it failed at
+	attempt := 1
+	if retry, ok := s.retries[record.Partition]; ok && retry.offset == record.Offset {
+		attempt = retry.attempt + 1
+	}
+	delete(s.retries, record.Partition)
+
+	for ; ; attempt++ {
+		err := s.handler(s.handlerCtx, event)
+		if err == nil {
+			break
+		}
+		log.Printf("Error processing message from topic %s (messageId: %s): %v", s.topic, event.ID, err)
+
+		// Deferred records are tried again after the delay of their last failure, without counting
+		// the attempt
+		deferred := interfaces.IsDeferredMessageError(err)
+		if deferred {
+			attempt--
+		}
+		if !deferred && policy.ShouldDeadLetter(err, attempt) {
+			if !s.deadLetter(record, attempt, err) {
+				return kafkaRecordStopped
+			}
+			log.Printf("Message %s from topic %s dead-lettered to %s after %d attempt(s)", event.ID, s.topic, DeadLetterQueueName(s.topic), attempt)
+			break
+		}
+
+		delay := policy.Delay(max(attempt, 1))
+		if time.Now().Add(delay).After(retryUntil) {
+			s.rewind(record, attempt, delay)
+			log.Printf("Message %s from topic %s redelivered in %v, partition %d paused meanwhile", event.ID, s.topic, delay, record.Partition)
+			return kafkaRecordRewound
+		}
+
+		if deferred {
+			log.Printf("Message %s from topic %s deferred for %v", event.ID, s.topic, delay)
+		} else {
+			log.Printf("Message %s from topic %s scheduled for retry %d in %v", event.ID, s.topic, attempt, delay)
+		}
+		if !s.wait(delay) {
+			return kafkaRecordStopped
+		}
+	}
+
+	if err := s.client.CommitRecords(context.Background(), record); err != nil {
+		// The record is delivered again and absorbed by the handler idempotency
+		log.Printf("Failed to commit message %s from topic %s: %v", event.ID, s.topic, err)
+		return kafkaRecordDone
+	}
+	log.Printf("Successfully processed and committed message with messageId: %s from topic %s", event.ID, s.topic)
+	return kafkaRecordDone
+}
+
+// rewind sets the partition of a failed record back to it and pauses fetching from the partition
+// for delay, remembering the attempt it failed at. Rebalances are blocked meanwhile, so the
+// partition is still assigned; once reassigned, the record is delivered again from its committed
+// offset anyway
+func (s *kafkaSubscription) rewind(record *kgo.Record, attempt int, delay time.Duration) {
+	s.retries[record.Partition] = kafkaRetry{offset: record.Offset, attempt: attempt}
+
+	partitions := map[string][]int32{record.Topic: {record.Partition}}
+	s.client.PauseFetchPartitions(partitions)
+	s.client.SetOffsets(map[string]map[int32]kgo.EpochOffset{
+		record.Topic: {record.Partition: {Epoch: record.LeaderEpoch, Offset: record.Offset}},
+	})
+	time.AfterFunc(delay, func() { s.client.ResumeFetchPartitions(partitions) })
+}
+
+// deadLetter produces a failed record to the dead-letter topic with the retry headers, retrying
+// until it is accepted. It returns false when the subscription stopped first
+func (s *kafkaSubscription) deadLetter(record *kgo.Record, attempt int, handlerErr error) bool {
+	headers := append([]kgo.RecordHeader(nil), record.Headers...)
+	headers = append(headers,
+		kgo.RecordHeader{Key: RetryCountHeader, Value: []byte(strconv.Itoa(attempt))},
+		kgo.RecordHeader{Key: LastErrorHeader, Value: []byte(handlerErr.Error())},
+		kgo.RecordHeader{Key: OriginalQueueHeader, Value: []byte(s.topic)},
+	)
+	dead := &kgo.Record{
+		Topic:   DeadLetterQueueName(s.topic),
+		Key:     record.Key,
+		Value:   record.Value,
+		Headers: headers,
+	}
+
+	for retry := 1; ; retry++ {
+		err := s.client.ProduceSync(s.handlerCtx, dead).FirstErr()
+		if err == nil {
+			return true
+		}
+		log.Printf("Failed to dead-letter message from topic %s: %v", s.topic, err)
+		if !s.wait(s.consumer.policy.Delay(retry)) {
+			return false
+		}
+	}
+}
+
+// wait sleeps for delay and reports whether the subscription is still running
+func (s *kafkaSubscription) wait(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-s.stop:
+		return false
+	}
+}
+
+// stopConsuming interrupts polling; the record being handled still finishes
+func (s *kafkaSubscription) stopConsuming() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		s.cancelPoll()
+	})
+}
+
+// stopped reports whether the subscription was asked to stop
+func (s *kafkaSubscription) stopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// KafkaGroupName returns the consumer group consuming a topic for the service
+func KafkaGroupName(groupID, topic string) string {
+	return groupID + "." + topic
+}
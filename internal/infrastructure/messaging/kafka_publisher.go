@@ -0,0 +1,63 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+)
+
+// KafkaPublisher implements the MessagePublisher interface for Kafka. Each event type is produced to
+// its topic, keyed by the event's partition key; the producer is idempotent and waits for every
+// in-sync replica, so Publish only succeeds once the record is durably written
+type KafkaPublisher struct {
+	config config.KafkaConfig
+	client *kgo.Client
+}
+
+// NewKafkaPublisher creates a new Kafka message publisher. The brokers are contacted on the first
+// publish, so the service starts even if Kafka is unavailable
+func NewKafkaPublisher(cfg config.KafkaConfig) (*KafkaPublisher, error) {
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.ClientID(cfg.ClientID),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+	}
+	if cfg.AutoCreateTopics {
+		opts = append(opts, kgo.AllowAutoTopicCreation())
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka producer: %w", err)
+	}
+	return &KafkaPublisher{config: cfg, client: client}, nil
+}
+
+// Publish produces an event to the topic of its type and waits for the acknowledgment
+func (p *KafkaPublisher) Publish(ctx context.Context, event events.Envelope) error {
+	topic := p.config.Topic(event.Type)
+
+	ctx, cancel := context.WithTimeout(ctx, p.config.ProduceTimeout)
+	defer cancel()
+
+	result, err := p.client.ProduceSync(ctx, EventRecord(topic, event)).First()
+	if err != nil {
+		log.Printf("Message to topic %s was not accepted (messageId: %s): %v", topic, event.ID, err)
+		return &interfaces.PublishError{Destination: topic, Err: err}
+	}
+
+	log.Printf("Published message to topic %s partition %d (messageId: %s)", topic, result.Partition, event.ID)
+	return nil
+}
+
+// Close closes the producer. Publish is synchronous, so no record is left to flush
+func (p *KafkaPublisher) Close() error {
+	p.client.Close()
+	return nil
+}
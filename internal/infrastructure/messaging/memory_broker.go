@@ -0,0 +1,523 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+)
+
+// ErrBrokerClosed is returned when publishing to or subscribing on a closed MemoryBroker
+var ErrBrokerClosed = errors.New("message broker is closed")
+
+// MemoryBroker implements both MessagePublisher and MessageConsumer in process. Each queue buffers
+// its messages until a subscriber takes them; as with RabbitMQConsumer, a message is removed once
+// the handler succeeds, goes back to the end of its queue after a backoff delay when the handler
+// fails, and moves to the queue's dead-letter queue once the retry policy gives up on it.
+// Exchanges route by topic patterns, or to every bound queue for fanout exchanges.
+// When a persistence file is configured, unacknowledged messages survive restarts
+type MemoryBroker struct {
+	mu          sync.Mutex
+	queues      map[string]*memoryQueue
+	bindings    map[string][]memoryBinding
+	fanouts     map[string]bool
+	topology    config.Topology
+	persistPath string
+	policy      RetryPolicy
+	sequence    uint64
+	closed      bool
+	stopping    bool
+	stop        chan struct{}
+	consumers   sync.WaitGroup
+	// cancels cancel the handler contexts when a shutdown deadline expires
+	cancels []context.CancelFunc
+}
+
+// memoryQueue holds the ready and in-flight messages of a queue; messages waiting for a retry
+// stay in flight until their delay expires
+type memoryQueue struct {
+	ready    []memoryMessage
+	inFlight map[uint64]memoryMessage
+	// signal is closed and replaced whenever a message becomes ready, waking every idle consumer
+	signal chan struct{}
+}
+
+// memoryBinding routes the messages of an exchange whose routing key matches pattern to a queue
+type memoryBinding struct {
+	pattern string
+	queue   string
+}
+
+// memoryMessage is a message stored by the broker
+type memoryMessage struct {
+	Sequence  uint64          `json:"sequence"`
+	Event     events.Envelope `json:"event"`
+	Attempts  int             `json:"attempts,omitempty"`
+	LastError string          `json:"lastError,omitempty"`
+}
+
+// memoryBrokerSnapshot is the persisted state of a broker; in-flight messages are saved as ready
+type memoryBrokerSnapshot struct {
+	Sequence uint64                     `json:"sequence"`
+	Queues   map[string][]memoryMessage `json:"queues"`
+}
+
+// NewMemoryBroker creates an in-process broker routing events according to topology and retrying
+// failed messages according to policy. If persistPath is not empty, the queues are restored from
+// that file and written back to it after every change
+func NewMemoryBroker(persistPath string, policy RetryPolicy, topology config.Topology) (*MemoryBroker, error) {
+	broker := &MemoryBroker{
+		queues:      make(map[string]*memoryQueue),
+		bindings:    make(map[string][]memoryBinding),
+		fanouts:     make(map[string]bool),
+		topology:    topology,
+		persistPath: persistPath,
+		policy:      policy,
+		stop:        make(chan struct{}),
+	}
+
+	for _, exchange := range topology.Exchanges {
+		broker.fanouts[exchange.Name] = exchange.Kind == "fanout"
+	}
+	for _, queue := range topology.Queues {
+		broker.queue(queue.Name)
+	}
+	for _, binding := range topology.Bindings {
+		broker.bindings[binding.Exchange] = append(broker.bindings[binding.Exchange], memoryBinding{pattern: binding.RoutingKey, queue: binding.Queue})
+	}
+
+	if persistPath != "" {
+		if err := broker.restore(); err != nil {
+			return nil, err
+		}
+	}
+	return broker, nil
+}
+
+// Publish appends an event to the queues its type's route leads to: the queue named by the
+// routing key on the default exchange, or the queues bound to the exchange with a matching
+// pattern, as a RabbitMQ topic exchange does. An event no queue takes fails to publish when its
+// route is mandatory and is dropped otherwise
+func (b *MemoryBroker) Publish(ctx context.Context, event events.Envelope) error {
+	route, ok := b.topology.Route(event.Type)
+	if !ok {
+		return &interfaces.PublishError{Destination: event.Type, Err: interfaces.ErrPublishNoRoute}
+	}
+	destination := route.Exchange + "/" + route.RoutingKey
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	queues := b.route(route.Exchange, route.RoutingKey)
+	if len(queues) == 0 && route.Mandatory {
+		return &interfaces.PublishError{Destination: destination, Err: interfaces.ErrPublishUnroutable}
+	}
+	if err := b.enqueue(destination, queues, event); err != nil {
+		return err
+	}
+
+	log.Printf("Published message to in-memory %s to %d queue(s) (messageId: %s)", destination, len(queues), event.ID)
+	return nil
+}
+
+// PublishToQueue appends an event directly to a queue, creating the queue if needed, as another
+// service publishing to it would
+func (b *MemoryBroker) PublishToQueue(ctx context.Context, queue string, event events.Envelope) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.enqueue(queue, []string{queue}, event); err != nil {
+		return err
+	}
+
+	log.Printf("Published message to in-memory queue: %s (messageId: %s)", queue, event.ID)
+	return nil
+}
+
+// route returns the queues receiving a message published to an exchange with a routing key. The
+// caller must hold the lock
+func (b *MemoryBroker) route(exchange, routingKey string) []string {
+	if exchange == "" {
+		if _, ok := b.queues[routingKey]; ok {
+			return []string{routingKey}
+		}
+		return nil
+	}
+
+	var queues []string
+	seen := make(map[string]bool)
+	for _, binding := range b.bindings[exchange] {
+		if seen[binding.queue] {
+			continue
+		}
+		if b.fanouts[exchange] || topicMatches(binding.pattern, routingKey) {
+			seen[binding.queue] = true
+			queues = append(queues, binding.queue)
+		}
+	}
+	return queues
+}
+
+// enqueue appends a copy of an event to queues and persists them, reporting failures for
+// destination. The caller must hold the lock
+func (b *MemoryBroker) enqueue(destination string, queues []string, event events.Envelope) error {
+	if b.closed {
+		return &interfaces.PublishError{Destination: destination, Err: ErrBrokerClosed}
+	}
+
+	msg := memoryMessage{
+		Sequence: b.nextSequence(),
+		Event:    event,
+	}
+	msg.Event.Data = append(json.RawMessage(nil), event.Data...)
+	for _, name := range queues {
+		q := b.queue(name)
+		q.ready = append(q.ready, msg)
+	}
+
+	if err := b.persist(); err != nil {
+		// Keep the broker consistent with the file: the message was not accepted
+		for _, name := range queues {
+			q := b.queues[name]
+			q.ready = q.ready[:len(q.ready)-1]
+		}
+		return &interfaces.PublishError{Destination: destination, Err: fmt.Errorf("failed to persist message: %w", err)}
+	}
+
+	for _, name := range queues {
+		b.queues[name].wake()
+	}
+	return nil
+}
+
+// SubscribeToQueue starts a consumer delivering the messages of a queue to handler one at a time.
+// Several subscriptions to the same queue compete for its messages
+func (b *MemoryBroker) SubscribeToQueue(ctx context.Context, queueName string, handler interfaces.MessageHandler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed || b.stopping {
+		return ErrBrokerClosed
+	}
+
+	q := b.queue(queueName)
+	ctx, cancel := context.WithCancel(ctx)
+	b.cancels = append(b.cancels, cancel)
+	b.consumers.Add(1)
+	go b.consume(ctx, queueName, q, handler)
+
+	log.Printf("In-memory consumer subscribed to queue: %s", queueName)
+	return nil
+}
+
+// Shutdown stops the consumers and waits for the messages being handled until ctx expires, then
+// cancels the handlers' context. Publishing keeps working until Close, so handlers can still emit
+// their events while draining
+func (b *MemoryBroker) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.stopping {
+		b.stopping = true
+		close(b.stop)
+	}
+	b.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		b.consumers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		for _, cancel := range b.cancels {
+			cancel()
+		}
+		b.mu.Unlock()
+		return fmt.Errorf("timed out draining in-memory consumers: %w", ctx.Err())
+	}
+}
+
+// Close shuts the consumers down, waiting for the messages being handled, and persists the final
+// state. Messages that were not acknowledged stay queued
+func (b *MemoryBroker) Close() error {
+	_ = b.Shutdown(context.Background())
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for _, cancel := range b.cancels {
+		cancel()
+	}
+	return b.persist()
+}
+
+// Pending returns the number of messages of a queue that are not acknowledged yet
+func (b *MemoryBroker) Pending(queueName string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queueName]
+	if !ok {
+		return 0
+	}
+	return len(q.ready) + len(q.inFlight)
+}
+
+// consume delivers messages until the context is canceled or the broker shuts down
+func (b *MemoryBroker) consume(ctx context.Context, queueName string, q *memoryQueue, handler interfaces.MessageHandler) {
+	defer b.consumers.Done()
+
+	for {
+		msg, ok := b.take(ctx, q)
+		if !ok {
+			return
+		}
+
+		if msg.Event.ID != "" {
+			log.Printf("Processing message from in-memory queue %s with messageId: %s", queueName, msg.Event.ID)
+		}
+
+		if err := handler(ctx, msg.Event); err != nil {
+			log.Printf("Error processing message from in-memory queue %s (messageId: %s): %v", queueName, msg.Event.ID, err)
+			b.fail(queueName, q, msg, err)
+			continue
+		}
+
+		b.ack(q, msg)
+	}
+}
+
+// take waits for the next ready message of a queue and marks it as in flight
+func (b *MemoryBroker) take(ctx context.Context, q *memoryQueue) (memoryMessage, bool) {
+	for {
+		b.mu.Lock()
+		if b.stopping {
+			b.mu.Unlock()
+			return memoryMessage{}, false
+		}
+		if len(q.ready) > 0 {
+			msg := q.ready[0]
+			q.ready = q.ready[1:]
+			q.inFlight[msg.Sequence] = msg
+			b.mu.Unlock()
+			return msg, true
+		}
+		signal := q.signal
+		b.mu.Unlock()
+
+		select {
+		case <-signal:
+		case <-ctx.Done():
+			return memoryMessage{}, false
+		case <-b.stop:
+			return memoryMessage{}, false
+		}
+	}
+}
+
+// ack removes a handled message for good
+func (b *MemoryBroker) ack(q *memoryQueue, msg memoryMessage) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(q.inFlight, msg.Sequence)
+	if err := b.persist(); err != nil {
+		log.Printf("Failed to persist in-memory broker after ACK: %v", err)
+	}
+}
+
+// fail schedules a failed message for a retry after the policy's backoff delay, or moves it to the
+// dead-letter queue when the policy gives up on it. Deferred messages wait the delay of their last
+// failure without counting the attempt
+func (b *MemoryBroker) fail(queueName string, q *memoryQueue, msg memoryMessage, handlerErr error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	msg.LastError = handlerErr.Error()
+	deferred := interfaces.IsDeferredMessageError(handlerErr)
+	if !deferred {
+		msg.Attempts++
+	}
+
+	if !deferred && b.policy.ShouldDeadLetter(handlerErr, msg.Attempts) {
+		delete(q.inFlight, msg.Sequence)
+		dlq := b.queue(DeadLetterQueueName(queueName))
+		dlq.ready = append(dlq.ready, msg)
+		dlq.wake()
+		log.Printf("Message %s from in-memory queue %s dead-lettered after %d attempt(s)", msg.Event.ID, queueName, msg.Attempts)
+	} else {
+		q.inFlight[msg.Sequence] = msg
+		delay := b.policy.Delay(max(msg.Attempts, 1))
+		time.AfterFunc(delay, func() { b.retry(q, msg.Sequence) })
+		log.Printf("Message %s from in-memory queue %s scheduled for retry %d in %v", msg.Event.ID, queueName, msg.Attempts, delay)
+	}
+
+	if err := b.persist(); err != nil {
+		log.Printf("Failed to persist in-memory broker after a failed delivery: %v", err)
+	}
+}
+
+// retry puts a message whose retry delay expired back at the end of its queue. After Close the
+// message stays in flight, which the persisted state already records as pending
+func (b *MemoryBroker) retry(q *memoryQueue, sequence uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	msg, ok := q.inFlight[sequence]
+	if b.closed || !ok {
+		return
+	}
+	delete(q.inFlight, sequence)
+	q.ready = append(q.ready, msg)
+	q.wake()
+
+	if err := b.persist(); err != nil {
+		log.Printf("Failed to persist in-memory broker after a retry: %v", err)
+	}
+}
+
+// queue returns a queue by name, creating it if needed. The caller must hold the lock
+func (b *MemoryBroker) queue(name string) *memoryQueue {
+	q, ok := b.queues[name]
+	if !ok {
+		q = &memoryQueue{
+			inFlight: make(map[uint64]memoryMessage),
+			signal:   make(chan struct{}),
+		}
+		b.queues[name] = q
+	}
+	return q
+}
+
+// wake notifies the consumers waiting on the queue. The caller must hold the broker lock
+func (q *memoryQueue) wake() {
+	close(q.signal)
+	q.signal = make(chan struct{})
+}
+
+// topicMatches reports whether a routing key matches a topic binding pattern. Both are lists of
+// words separated by dots; "*" matches exactly one word and "#" zero or more words
+func topicMatches(pattern, routingKey string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(routingKey, "."))
+}
+
+// matchWords matches the words of a routing key against the words of a pattern
+func matchWords(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+	if pattern[0] == "#" {
+		for i := 0; i <= len(words); i++ {
+			if matchWords(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(words) == 0 || (pattern[0] != "*" && pattern[0] != words[0]) {
+		return false
+	}
+	return matchWords(pattern[1:], words[1:])
+}
+
+// nextSequence numbers a new message across all queues. The caller must hold the lock
+func (b *MemoryBroker) nextSequence() uint64 {
+	b.sequence++
+	return b.sequence
+}
+
+// persist writes the queues to the persistence file, if any. The file is replaced atomically so a
+// crash never leaves it half written. The caller must hold the lock
+func (b *MemoryBroker) persist() error {
+	if b.persistPath == "" {
+		return nil
+	}
+
+	snapshot := memoryBrokerSnapshot{
+		Sequence: b.sequence,
+		Queues:   make(map[string][]memoryMessage, len(b.queues)),
+	}
+	for name, q := range b.queues {
+		messages := make([]memoryMessage, 0, len(q.inFlight)+len(q.ready))
+		// In-flight messages were delivered before the ready ones; a restart redelivers them first
+		for _, msg := range q.inFlight {
+			messages = append(messages, msg)
+		}
+		sort.Slice(messages, func(i, j int) bool { return messages[i].Sequence < messages[j].Sequence })
+		messages = append(messages, q.ready...)
+		if len(messages) > 0 {
+			snapshot.Queues[name] = messages
+		}
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(b.persistPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".broker-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), b.persistPath)
+}
+
+// restore loads the queues saved in the persistence file. A missing file means an empty broker
+func (b *MemoryBroker) restore() error {
+	data, err := os.ReadFile(b.persistPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read message broker file: %w", err)
+	}
+
+	var snapshot memoryBrokerSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to decode message broker file: %w", err)
+	}
+
+	b.sequence = snapshot.Sequence
+	for name, messages := range snapshot.Queues {
+		q := b.queue(name)
+		q.ready = append(q.ready, messages...)
+		for _, msg := range messages {
+			if msg.Sequence > b.sequence {
+				b.sequence = msg.Sequence
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/metrics"
+)
+
+// outboxRelayBatchSize is the number of pending messages published per pass
+const outboxRelayBatchSize = 25
+
+// OutboxRelay publishes the messages stored in the outbox. Delivery is at least once: a message
+// published but not marked as sent, or relayed by two instances at a time, is published again, which
+// the consumers absorb through their message ID idempotency. Failed messages back off following the
+// retry policy and are parked once they run out of attempts or can never be published. Before a
+// message is parked, the handler registered for its event type in parked compensates the state
+// change the message accompanied, which would otherwise wait for the event forever
+type OutboxRelay struct {
+	outbox    interfaces.OutboxRepository
+	publisher interfaces.MessagePublisher
+	interval  time.Duration
+	retry     RetryPolicy
+	parked    map[string]interfaces.MessageHandler
+	metrics   *metrics.PrometheusMetrics
+}
+
+// NewOutboxRelay creates a relay publishing pending outbox messages every interval. parked holds
+// the compensations of the event types whose messages must not be given up on silently, and may be
+// nil as well as metricsCollector
+func NewOutboxRelay(outbox interfaces.OutboxRepository, publisher interfaces.MessagePublisher, interval time.Duration, retry RetryPolicy, parked map[string]interfaces.MessageHandler, metricsCollector *metrics.PrometheusMetrics) *OutboxRelay {
+	return &OutboxRelay{
+		outbox:    outbox,
+		publisher: publisher,
+		interval:  interval,
+		retry:     retry,
+		parked:    parked,
+		metrics:   metricsCollector,
+	}
+}
+
+// NewOutboxRetryPolicy builds the retry policy of the outbox relay: failed messages wait from one
+// relay interval, doubling on every attempt, and are parked after maxAttempts
+func NewOutboxRetryPolicy(maxAttempts int, interval, maxDelay time.Duration) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: maxAttempts,
+		BaseDelay:   interval,
+		MaxDelay:    maxDelay,
+	}
+}
+
+// Run relays pending messages until ctx is canceled. A pass that fills a whole batch is followed
+// right away by the next one, so a backlog drains without waiting for the interval
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		published, err := r.RelayPending(ctx)
+		if err != nil {
+			log.Printf("outbox relay error: %v", err)
+		}
+		if err == nil && published == outboxRelayBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Outbox relay stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayPending publishes one batch of pending messages and returns how many were published.
+// Messages that fail stay pending with their attempt recorded and are retried once their backoff
+// elapses, unless they are parked
+func (r *OutboxRelay) RelayPending(ctx context.Context) (int, error) {
+	messages, err := r.outbox.ListPending(ctx, outboxRelayBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, message := range messages {
+		if ctx.Err() != nil {
+			return published, ctx.Err()
+		}
+
+		event, err := message.Event()
+		if err != nil {
+			err = interfaces.NewPermanentMessageError(err)
+		} else if err = r.publisher.Publish(ctx, event); errors.Is(err, interfaces.ErrPublishNoRoute) {
+			err = interfaces.NewPermanentMessageError(err)
+		}
+		if err != nil {
+			r.recordFailure(ctx, message, event, err)
+			continue
+		}
+
+		if err := r.outbox.MarkSent(ctx, message.ID); err != nil {
+			// The message will be published again on the next pass
+			log.Printf("failed to mark outbox message %s as sent: %v", message.ID, err)
+			continue
+		}
+		published++
+	}
+	return published, nil
+}
+
+// recordFailure parks a message that failed for good or ran out of attempts, and otherwise
+// schedules its next attempt. A message whose compensation fails is not parked but scheduled
+// again, so both its publish and its compensation are retried
+func (r *OutboxRelay) recordFailure(ctx context.Context, message *models.OutboxMessage, event events.Envelope, err error) {
+	attempt := message.Attempts + 1
+	if r.retry.ShouldDeadLetter(err, attempt) {
+		if compensate, ok := r.parked[event.Type]; ok {
+			if compensateErr := compensate(ctx, event); compensateErr != nil {
+				log.Printf("failed to compensate outbox message %s before parking it: %v", message.ID, compensateErr)
+				err = fmt.Errorf("%w (compensation failed: %v)", err, compensateErr)
+				if markErr := r.outbox.MarkFailed(ctx, message.ID, err, time.Now().Add(r.retry.Delay(attempt))); markErr != nil {
+					log.Printf("failed to record outbox failure for message %s: %v", message.ID, markErr)
+				}
+				return
+			}
+		}
+
+		log.Printf("parking outbox message %s after attempt %d: %v", message.ID, attempt, err)
+		if markErr := r.outbox.MarkParked(ctx, message.ID, err); markErr != nil {
+			log.Printf("failed to park outbox message %s: %v", message.ID, markErr)
+			return
+		}
+		if r.metrics != nil {
+			r.metrics.RecordOutboxParked(event.Type)
+		}
+		return
+	}
+
+	log.Printf("failed to relay outbox message %s (attempt %d): %v", message.ID, attempt, err)
+	if markErr := r.outbox.MarkFailed(ctx, message.ID, err, time.Now().Add(r.retry.Delay(attempt))); markErr != nil {
+		log.Printf("failed to record outbox failure for message %s: %v", message.ID, markErr)
+	}
+}
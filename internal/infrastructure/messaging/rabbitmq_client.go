@@ -12,10 +12,12 @@ import (
 )
 
 // RabbitMQClient manages a shared RabbitMQ connection with auto-reconnection
-// Following RabbitMQ best practices: one connection, multiple channels
+// Following RabbitMQ best practices: one connection, multiple channels.
+// The topology is declared on every new connection, so it is restored after a broker restart
 type RabbitMQClient struct {
 	conn         *amqp091.Connection
 	config       config.RabbitMQConfig
+	topology     config.Topology
 	mu           sync.RWMutex
 	reconnecting bool
 	stopMonitor  chan struct{}
@@ -23,7 +25,7 @@ type RabbitMQClient struct {
 
 // NewRabbitMQClient creates a new RabbitMQ client with a shared connection
 // Implements retry logic to handle RabbitMQ startup delays
-func NewRabbitMQClient(cfg config.RabbitMQConfig) (*RabbitMQClient, error) {
+func NewRabbitMQClient(cfg config.RabbitMQConfig, topology config.Topology) (*RabbitMQClient, error) {
 	var conn *amqp091.Connection
 	var err error
 
@@ -50,10 +52,13 @@ func NewRabbitMQClient(cfg config.RabbitMQConfig) (*RabbitMQClient, error) {
 	c := &RabbitMQClient{
 		conn:        conn, // may be nil if initial attempts failed
 		config:      cfg,
+		topology:    topology,
 		stopMonitor: make(chan struct{}),
 	}
 
-	if err != nil {
+	if err == nil {
+		c.applyTopology(conn)
+	} else {
 		// Do not fail startup: keep retrying in background until RabbitMQ is available
 		log.Printf("RabbitMQ not available at startup: %v. Will keep retrying in background.", err)
 		go c.reconnect()
@@ -136,6 +141,7 @@ func (c *RabbitMQClient) reconnect() {
 			c.conn = conn
 			c.mu.Unlock()
 			log.Printf("Successfully reconnected to RabbitMQ (attempt %d)", attempt)
+			c.applyTopology(conn)
 			return
 		}
 		log.Printf("Reconnection attempt %d failed: %v. Retrying in %v...", attempt, err, retryDelay)
@@ -182,19 +188,123 @@ func (c *RabbitMQClient) CreateChannel() (*amqp091.Channel, error) {
 	}
 }
 
-// DeclareQueue declares a queue (idempotent operation)
+// applyTopology declares the exchanges, queues and bindings of the topology on a new connection.
+// Failures are logged rather than returned: publishes to a missing destination fail on their own,
+// and the next reconnection tries again
+func (c *RabbitMQClient) applyTopology(conn *amqp091.Connection) {
+	ch, err := conn.Channel()
+	if err != nil {
+		log.Printf("Failed to open channel to declare RabbitMQ topology: %v", err)
+		return
+	}
+	defer func() { _ = ch.Close() }()
+
+	if err := c.declareTopology(ch); err != nil {
+		log.Printf("Failed to declare RabbitMQ topology: %v", err)
+		return
+	}
+	log.Printf("Declared RabbitMQ topology: %d exchange(s), %d queue(s), %d binding(s)",
+		len(c.topology.Exchanges), len(c.topology.Queues), len(c.topology.Bindings))
+}
+
+// declareTopology declares the topology on a channel (idempotent operation)
+func (c *RabbitMQClient) declareTopology(channel *amqp091.Channel) error {
+	for _, exchange := range c.topology.Exchanges {
+		kind := exchange.Kind
+		if kind == "" {
+			kind = amqp091.ExchangeTopic
+		}
+		if err := channel.ExchangeDeclare(exchange.Name, kind, c.config.Durable, false, false, false, nil); err != nil {
+			return fmt.Errorf("failed to declare exchange %s: %w", exchange.Name, err)
+		}
+	}
+
+	for _, queue := range c.topology.Queues {
+		if err := c.DeclareQueue(channel, queue.Name); err != nil {
+			return err
+		}
+	}
+
+	for _, binding := range c.topology.Bindings {
+		if err := channel.QueueBind(binding.Queue, binding.RoutingKey, binding.Exchange, false, nil); err != nil {
+			return fmt.Errorf("failed to bind queue %s to exchange %s with %s: %w", binding.Queue, binding.Exchange, binding.RoutingKey, err)
+		}
+	}
+	return nil
+}
+
+// DeclareQueue declares a queue together with its dead-letter exchange and queue (idempotent operation).
+// The queue takes its type, TTL and dead-letter arguments from the topology; a queue the topology
+// does not declare is a durable classic queue without arguments, as declared by earlier versions.
+// Consumers publish dead letters to the .dlx exchange explicitly, the queue's own dead-letter
+// exchange only receives the messages the broker expires
 func (c *RabbitMQClient) DeclareQueue(channel *amqp091.Channel, queueName string) error {
+	queue, _ := c.topology.Queue(queueName)
+	durable := c.config.Durable || queue.Type == config.QueueTypeQuorum // quorum queues are always durable
 	_, err := channel.QueueDeclare(
 		queueName,        // name
-		c.config.Durable, // durable
+		durable,          // durable
 		false,            // delete when unused
 		false,            // exclusive
 		false,            // no-wait
-		nil,              // arguments
+		queueArgs(queue), // arguments
 	)
 	if err != nil {
 		return fmt.Errorf("failed to declare queue %s: %w", queueName, err)
 	}
+
+	dlx := DeadLetterExchangeName(queueName)
+	if err := channel.ExchangeDeclare(dlx, amqp091.ExchangeDirect, c.config.Durable, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange %s: %w", dlx, err)
+	}
+
+	dlq := DeadLetterQueueName(queueName)
+	if _, err := channel.QueueDeclare(dlq, c.config.Durable, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue %s: %w", dlq, err)
+	}
+	if err := channel.QueueBind(dlq, queueName, dlx, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue %s: %w", dlq, err)
+	}
+	return nil
+}
+
+// queueArgs returns the declaration arguments of a topology queue, or nil when it has none
+func queueArgs(queue config.QueueTopology) amqp091.Table {
+	args := amqp091.Table{}
+	if queue.Type == config.QueueTypeQuorum {
+		args[amqp091.QueueTypeArg] = amqp091.QueueTypeQuorum
+	}
+	if queue.MessageTTL > 0 {
+		args["x-message-ttl"] = time.Duration(queue.MessageTTL).Milliseconds()
+	}
+	if queue.DeadLetterExchange != "" {
+		args["x-dead-letter-exchange"] = queue.DeadLetterExchange
+		if queue.DeadLetterRoutingKey != "" {
+			args["x-dead-letter-routing-key"] = queue.DeadLetterRoutingKey
+		}
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return args
+}
+
+// DeclareRetryQueues declares one delay queue per retry of a consumed queue. Each holds messages
+// for its backoff delay and then dead-letters them back to the original queue through the default
+// exchange; fixed per-queue TTLs avoid messages with short delays waiting behind longer ones
+func (c *RabbitMQClient) DeclareRetryQueues(channel *amqp091.Channel, queueName string) error {
+	policy := NewRetryPolicy(c.config)
+	for retry := 1; retry < policy.MaxAttempts; retry++ {
+		name := RetryQueueName(queueName, retry)
+		args := amqp091.Table{
+			"x-message-ttl":             policy.Delay(retry).Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queueName,
+		}
+		if _, err := channel.QueueDeclare(name, c.config.Durable, false, false, false, args); err != nil {
+			return fmt.Errorf("failed to declare retry queue %s: %w", name, err)
+		}
+	}
 	return nil
 }
 
@@ -203,6 +313,11 @@ func (c *RabbitMQClient) GetConfig() config.RabbitMQConfig {
 	return c.config
 }
 
+// Topology returns the topology declared by the client
+func (c *RabbitMQClient) Topology() config.Topology {
+	return c.topology
+}
+
 // Close closes the RabbitMQ connection and stops monitoring
 func (c *RabbitMQClient) Close() error {
 	c.mu.Lock()
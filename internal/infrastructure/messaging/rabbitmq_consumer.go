@@ -2,197 +2,321 @@ package messaging
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/rabbitmq/amqp091-go"
 
 	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
 )
 
-// RabbitMQConsumer implements the MessageConsumer interface for RabbitMQ
+// ErrConsumerStopped is returned when subscribing on a consumer that is shutting down
+var ErrConsumerStopped = errors.New("consumer is shutting down")
+
+// RabbitMQConsumer implements the MessageConsumer interface for RabbitMQ. Every subscription has its
+// own channel and a pool of workers handling its deliveries concurrently. Failed messages are moved
+// to their retry or dead-letter queue through a publisher in confirm mode
 type RabbitMQConsumer struct {
 	client        *RabbitMQClient
-	channel       *amqp091.Channel
-	subscriptions map[string]interfaces.MessageHandler // queueName -> handler
+	failures      *RabbitMQPublisher
+	mu            sync.Mutex
+	subscriptions []*rabbitSubscription
+	stopping      bool
 }
 
-// NewRabbitMQConsumer creates a new RabbitMQ message consumer
-func NewRabbitMQConsumer(client *RabbitMQClient) (*RabbitMQConsumer, error) {
-	// Attempt to create a channel, but do not fail if RabbitMQ is down.
-	channel, _ := client.CreateChannel()
+// rabbitSubscription consumes one queue on a dedicated channel
+type rabbitSubscription struct {
+	consumer    *RabbitMQConsumer
+	queueName   string
+	handler     interfaces.MessageHandler
+	consumerTag string
 
-	r := &RabbitMQConsumer{
-		client:        client,
-		channel:       channel, // may be nil
-		subscriptions: make(map[string]interfaces.MessageHandler),
-	}
+	// handlerCtx is passed to handlers; it is canceled when the shutdown deadline expires
+	handlerCtx    context.Context
+	cancelHandler context.CancelFunc
 
-	// Start monitor for channel close and initial creation/re-subscription
-	go r.monitorChannel()
+	mu       sync.Mutex
+	channel  *amqp091.Channel
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
 
-	return r, nil
+// NewRabbitMQConsumer creates a new RabbitMQ message consumer
+func NewRabbitMQConsumer(client *RabbitMQClient) (*RabbitMQConsumer, error) {
+	failures, err := NewRabbitMQPublisher(client)
+	if err != nil {
+		return nil, err
+	}
+	return &RabbitMQConsumer{client: client, failures: failures}, nil
 }
 
-// SubscribeToQueue starts consuming messages from the specified queue with the provided handler
+// SubscribeToQueue starts consuming messages from the specified queue with the provided handler.
+// The subscription stops when ctx is canceled or the consumer shuts down, and resubscribes by itself
+// when its channel closes
 func (r *RabbitMQConsumer) SubscribeToQueue(ctx context.Context, queueName string, handler interfaces.MessageHandler) error {
-	// Keep subscription for re-subscribe after reconnect
-	if r.subscriptions == nil {
-		r.subscriptions = make(map[string]interfaces.MessageHandler)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopping {
+		return ErrConsumerStopped
 	}
-	r.subscriptions[queueName] = handler
-	if err := r.setupConsumer(queueName); err != nil {
-		return err
+
+	handlerCtx, cancelHandler := context.WithCancel(context.WithoutCancel(ctx))
+	sub := &rabbitSubscription{
+		consumer:      r,
+		queueName:     queueName,
+		handler:       handler,
+		consumerTag:   fmt.Sprintf("%s-%s", queueName, uuid.NewString()),
+		handlerCtx:    handlerCtx,
+		cancelHandler: cancelHandler,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
 	}
 
-	msgs, err := r.startConsuming(queueName)
+	deliveries, err := sub.open()
 	if err != nil {
+		cancelHandler()
 		return err
 	}
 
-	log.Printf("RabbitMQ consumer subscribed to queue: %s", queueName)
+	r.subscriptions = append(r.subscriptions, sub)
+	go sub.run(deliveries)
 
-	go r.consumeMessages(ctx, queueName, msgs, handler)
+	// Canceling the subscription context stops consuming; messages already received still finish
+	context.AfterFunc(ctx, sub.stopConsuming)
+
+	log.Printf("RabbitMQ consumer subscribed to queue: %s (%d workers)", queueName, r.workers())
 	return nil
 }
 
-// setupConsumer declares the queue and sets QoS parameters
-func (r *RabbitMQConsumer) setupConsumer(queueName string) error {
-	cfg := r.client.GetConfig()
+// Shutdown stops consuming from every queue and waits for the in-flight handlers until ctx expires.
+// Handlers still running at the deadline see their context canceled. The channels are closed
+// last, so the broker requeues whatever was not acknowledged
+func (r *RabbitMQConsumer) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.stopping = true
+	subscriptions := append([]*rabbitSubscription(nil), r.subscriptions...)
+	r.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		sub.stopConsuming()
+	}
 
-	if r.channel == nil || r.channel.IsClosed() {
-		// Attempt to get a channel lazily
-		ch, err := r.client.CreateChannel()
+	var err error
+	for _, sub := range subscriptions {
+		select {
+		case <-sub.done:
+		case <-ctx.Done():
+			err = fmt.Errorf("timed out draining consumer for queue %s: %w", sub.queueName, ctx.Err())
+		}
 		if err != nil {
-			return fmt.Errorf("consumer channel unavailable: %w", err)
+			break
+		}
+	}
+	if err != nil {
+		for _, sub := range subscriptions {
+			sub.cancelHandler()
 		}
-		r.channel = ch
 	}
 
-	if err := r.client.DeclareQueue(r.channel, queueName); err != nil {
-		return err
+	for _, sub := range subscriptions {
+		sub.closeChannel()
+	}
+	if closeErr := r.failures.Close(); closeErr != nil {
+		log.Printf("Error closing failed message publisher: %v", closeErr)
+	}
+
+	if err == nil {
+		log.Println("RabbitMQ consumers drained")
+	}
+	return err
+}
+
+// workers returns the number of concurrent handlers per subscription
+func (r *RabbitMQConsumer) workers() int {
+	if workers := r.client.GetConfig().WorkersPerQueue; workers > 0 {
+		return workers
 	}
+	return 1
+}
+
+// open creates the subscription channel, declares the queue topology and starts consuming
+func (s *rabbitSubscription) open() (<-chan amqp091.Delivery, error) {
+	cfg := s.consumer.client.GetConfig()
 
-	err := r.channel.Qos(cfg.PrefetchCount, 0, false)
+	channel, err := s.consumer.client.CreateChannel()
 	if err != nil {
-		return fmt.Errorf("failed to set QoS: %w", err)
+		return nil, fmt.Errorf("consumer channel unavailable: %w", err)
 	}
 
-	return nil
+	deliveries, err := s.setup(channel, cfg.PrefetchCount)
+	if err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channel = channel
+	if s.stopped() {
+		// Shutdown started while the channel was being opened
+		s.cancelConsumer()
+	}
+	return deliveries, nil
 }
 
-// startConsuming begins consuming messages from the queue
-func (r *RabbitMQConsumer) startConsuming(queueName string) (<-chan amqp091.Delivery, error) {
-	cfg := r.client.GetConfig()
+// setup declares the queue and its retry queues, sets QoS and registers the consumer
+func (s *rabbitSubscription) setup(channel *amqp091.Channel, prefetch int) (<-chan amqp091.Delivery, error) {
+	client := s.consumer.client
+	cfg := client.GetConfig()
 
-	msgs, err := r.channel.Consume(
-		queueName,   // queue
-		"",          // consumer tag
-		cfg.AutoAck, // auto-ack
-		false,       // exclusive
-		false,       // no-local
-		false,       // no-wait
-		nil,         // args
+	if err := client.DeclareQueue(channel, s.queueName); err != nil {
+		return nil, err
+	}
+	if err := client.DeclareRetryQueues(channel, s.queueName); err != nil {
+		return nil, err
+	}
+
+	// Every worker needs a delivery of its own to work concurrently
+	if workers := s.consumer.workers(); prefetch < workers {
+		prefetch = workers
+	}
+	if err := channel.Qos(prefetch, 0, false); err != nil {
+		return nil, fmt.Errorf("failed to set QoS: %w", err)
+	}
+
+	deliveries, err := channel.Consume(
+		s.queueName,   // queue
+		s.consumerTag, // consumer tag
+		cfg.AutoAck,   // auto-ack
+		false,         // exclusive
+		false,         // no-local
+		false,         // no-wait
+		nil,           // args
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to register consumer for queue %s: %w", queueName, err)
+		return nil, fmt.Errorf("failed to register consumer for queue %s: %w", s.queueName, err)
 	}
-
-	return msgs, nil
+	return deliveries, nil
 }
 
-// consumeMessages processes messages in a goroutine
-func (r *RabbitMQConsumer) consumeMessages(ctx context.Context, queueName string, msgs <-chan amqp091.Delivery, handler interfaces.MessageHandler) {
+// run processes deliveries until the subscription stops, reopening the channel whenever it closes
+func (s *rabbitSubscription) run(deliveries <-chan amqp091.Delivery) {
+	defer close(s.done)
+
 	for {
-		select {
-		case <-ctx.Done():
-			log.Printf("Context canceled, stopping consumer for queue: %s", queueName)
+		s.consume(deliveries)
+
+		if s.stopped() {
+			log.Printf("Stopped consuming from queue: %s", s.queueName)
+			return
+		}
+
+		log.Printf("Message channel closed for queue: %s. Resubscribing...", s.queueName)
+		deliveries = s.reopen()
+		if deliveries == nil {
 			return
-		case msg, ok := <-msgs:
-			if !ok {
-				log.Printf("Message channel closed for queue: %s (will attempt to resubscribe)", queueName)
-				return
-			}
-			r.processMessage(ctx, queueName, msg, handler)
 		}
+		log.Printf("Resubscribed to queue: %s", s.queueName)
 	}
 }
 
-// monitorChannel watches the consumer channel and re-creates it on close, then re-subscribes
-func (r *RabbitMQConsumer) monitorChannel() {
+// consume hands deliveries to the worker pool and returns once the delivery channel is closed and
+// every worker has finished its message
+func (s *rabbitSubscription) consume(deliveries <-chan amqp091.Delivery) {
+	var workers sync.WaitGroup
+	for i := 0; i < s.consumer.workers(); i++ {
+		workers.Go(func() {
+			for msg := range deliveries {
+				s.consumer.processMessage(s.handlerCtx, s.queueName, msg, s.handler)
+			}
+		})
+	}
+	workers.Wait()
+}
+
+// reopen retries opening the subscription until it succeeds or the subscription stops
+func (s *rabbitSubscription) reopen() <-chan amqp091.Delivery {
 	for {
-		ch := r.channel
-		if ch == nil {
-			time.Sleep(1 * time.Second)
-			continue
+		select {
+		case <-s.stop:
+			return nil
+		case <-time.After(2 * time.Second):
 		}
 
-		closeCh := ch.NotifyClose(make(chan *amqp091.Error))
-		if err := <-closeCh; err != nil {
-			log.Printf("Consumer channel closed: %v. Reconnecting...", err)
-		} else {
-			log.Printf("Consumer channel closed cleanly")
-			return
+		s.closeChannel()
+		deliveries, err := s.open()
+		if err != nil {
+			log.Printf("Failed to resubscribe to queue %s: %v", s.queueName, err)
+			continue
 		}
+		return deliveries
+	}
+}
 
-		// Try to recreate channel until success
-		for {
-			time.Sleep(2 * time.Second)
-			newCh, err := r.client.CreateChannel()
-			if err != nil {
-				log.Printf("Failed to recreate consumer channel: %v", err)
-				continue
-			}
-			r.channel = newCh
-			log.Printf("Consumer channel recreated successfully")
-			break
-		}
+// stopConsuming cancels the consumer so the broker sends no more messages. Deliveries already
+// received are still handed to the workers before the delivery channel closes
+func (s *rabbitSubscription) stopConsuming() {
+	s.stopOnce.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		close(s.stop)
+		s.cancelConsumer()
+	})
+}
 
-		// Resubscribe to all queues
-		for q, h := range r.subscriptions {
-			if err := r.setupConsumer(q); err != nil {
-				log.Printf("Failed to setup consumer for queue %s after reconnect: %v", q, err)
-				continue
-			}
-			msgs, err := r.startConsuming(q)
-			if err != nil {
-				log.Printf("Failed to restart consuming for queue %s: %v", q, err)
-				continue
-			}
-			go r.consumeMessages(context.Background(), q, msgs, h)
-			log.Printf("Resubscribed to queue: %s", q)
+// cancelConsumer cancels the consumer on the current channel. The caller must hold the lock
+func (s *rabbitSubscription) cancelConsumer() {
+	if s.channel == nil || s.channel.IsClosed() {
+		return
+	}
+	if err := s.channel.Cancel(s.consumerTag, false); err != nil {
+		log.Printf("Failed to cancel consumer for queue %s: %v", s.queueName, err)
+		_ = s.channel.Close()
+	}
+}
+
+// stopped reports whether the subscription was asked to stop
+func (s *rabbitSubscription) stopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeChannel closes the subscription channel if it is open
+func (s *rabbitSubscription) closeChannel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.channel != nil && !s.channel.IsClosed() {
+		if err := s.channel.Close(); err != nil {
+			log.Printf("error closing channel for queue %s: %v", s.queueName, err)
 		}
 	}
 }
 
 // processMessage handles a single message with error handling and acknowledgment
 func (r *RabbitMQConsumer) processMessage(ctx context.Context, queueName string, msg amqp091.Delivery, handler interfaces.MessageHandler) {
-	// Extract MessageID from headers for logging and potential deduplication
-	messageID := ""
-	if msg.MessageId != "" {
-		messageID = msg.MessageId
-	} else if msg.Headers != nil {
-		if id, ok := msg.Headers["x-message-id"].(string); ok {
-			messageID = id
-		}
-	}
+	event := DeliveryEvent(msg)
+	messageID := event.ID
 
 	if messageID != "" {
 		log.Printf("Processing message from queue %s with messageId: %s", queueName, messageID)
 	}
 
 	// Process the message with the handler
-	err := handler(ctx, msg.Body)
+	err := handler(ctx, event)
 	if err != nil {
-		// Handler returned an error - NACK the message to requeue it
 		log.Printf("Error processing message from queue %s (messageId: %s): %v", queueName, messageID, err)
-		log.Printf("Message will be NACK'd and requeued for retry")
-		if nackErr := msg.Nack(false, true); nackErr != nil {
-			log.Printf("Failed to NACK message: %v", nackErr)
-		} else {
-			log.Printf("   ✓ Message NACK'd - will be retried")
-		}
+		r.handleFailure(ctx, queueName, msg, messageID, err)
 		return
 	}
 
@@ -209,12 +333,97 @@ func (r *RabbitMQConsumer) processMessage(ctx context.Context, queueName string,
 	log.Printf("Successfully processed and acknowledged message with messageId: %s from queue %s", messageID, queueName)
 }
 
-// Close closes the consumer channel (connection is managed by RabbitMQClient)
-func (r *RabbitMQConsumer) Close() error {
-	if r.channel != nil {
-		if err := r.channel.Close(); err != nil {
-			log.Printf("error closing channel: %v", err)
+// handleFailure moves a failed message to the retry queue matching its attempt, or to the
+// dead-letter exchange once it is permanent or out of attempts, and then ACKs the original.
+// Deferred messages wait in the retry queue of their last failure without counting the attempt.
+// The original is ACK'd only once the broker confirms the move; otherwise it is NACK'd and
+// requeued so it is never lost
+func (r *RabbitMQConsumer) handleFailure(ctx context.Context, queueName string, msg amqp091.Delivery, messageID string, handlerErr error) {
+	cfg := r.client.GetConfig()
+	if cfg.AutoAck {
+		log.Printf("AutoAck is enabled, failed message %s from queue %s cannot be retried", messageID, queueName)
+		return
+	}
+
+	policy := NewRetryPolicy(cfg)
+	attempt := RetryCount(msg.Headers) + 1
+
+	headers := amqp091.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[RetryCountHeader] = int32(attempt)
+
+	exchange, routingKey := "", RetryQueueName(queueName, attempt)
+	deferred := interfaces.IsDeferredMessageError(handlerErr)
+	deadLetter := !deferred && policy.ShouldDeadLetter(handlerErr, attempt)
+	if deferred {
+		if policy.MaxAttempts <= 1 {
+			// No retry queue to wait in
+			log.Printf("Message %s from queue %s deferred, requeueing it", messageID, queueName)
+			if nackErr := msg.Nack(false, true); nackErr != nil {
+				log.Printf("Failed to NACK message: %v", nackErr)
+			}
+			return
 		}
+		attempt--
+		headers[RetryCountHeader] = int32(attempt)
+		routingKey = RetryQueueName(queueName, max(attempt, 1))
 	}
-	return nil
+	if deadLetter {
+		exchange, routingKey = DeadLetterExchangeName(queueName), queueName
+		headers[LastErrorHeader] = handlerErr.Error()
+		headers[OriginalQueueHeader] = queueName
+	}
+
+	deliveryMode := amqp091.Transient
+	if cfg.Durable {
+		deliveryMode = amqp091.Persistent
+	}
+
+	err := r.failures.publish(ctx, exchange, routingKey, true, amqp091.Publishing{
+		ContentType:  msg.ContentType,
+		DeliveryMode: deliveryMode,
+		MessageId:    msg.MessageId,
+		Type:         msg.Type,
+		Timestamp:    msg.Timestamp,
+		Headers:      headers,
+		Body:         msg.Body,
+	})
+	if err != nil {
+		log.Printf("Failed to route failed message %s from queue %s: %v. Requeueing it", messageID, queueName, err)
+		if nackErr := msg.Nack(false, true); nackErr != nil {
+			log.Printf("Failed to NACK message: %v", nackErr)
+		}
+		return
+	}
+
+	if ackErr := msg.Ack(false); ackErr != nil {
+		log.Printf("Failed to ACK message: %v", ackErr)
+	}
+
+	if deadLetter {
+		log.Printf("Message %s from queue %s dead-lettered to %s after %d attempt(s)", messageID, queueName, DeadLetterQueueName(queueName), attempt)
+		return
+	}
+	if deferred {
+		log.Printf("Message %s from queue %s deferred for %v", messageID, queueName, policy.Delay(max(attempt, 1)))
+		return
+	}
+	log.Printf("Message %s from queue %s scheduled for retry %d in %v", messageID, queueName, attempt, policy.Delay(attempt))
+}
+
+// Close closes the consumer channels without draining (connection is managed by RabbitMQClient)
+func (r *RabbitMQConsumer) Close() error {
+	r.mu.Lock()
+	r.stopping = true
+	subscriptions := append([]*rabbitSubscription(nil), r.subscriptions...)
+	r.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		sub.stopConsuming()
+		sub.cancelHandler()
+		sub.closeChannel()
+	}
+	return r.failures.Close()
 }
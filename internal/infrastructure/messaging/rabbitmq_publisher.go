@@ -2,18 +2,25 @@ package messaging
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/rabbitmq/amqp091-go"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
 )
 
-// RabbitMQPublisher implements the MessagePublisher interface for RabbitMQ
+// RabbitMQPublisher implements the MessagePublisher interface for RabbitMQ. Events are sent where
+// the client topology routes their type. Its channel runs in confirm mode and mandatory routes are
+// published as mandatory, so Publish only succeeds once the broker has routed and accepted them
 type RabbitMQPublisher struct {
 	client  *RabbitMQClient
+	mu      sync.Mutex
 	channel *amqp091.Channel
+	returns chan amqp091.Return
 }
 
 // NewRabbitMQPublisher creates a new RabbitMQ message publisher
@@ -27,8 +34,33 @@ func NewRabbitMQPublisher(client *RabbitMQClient) (*RabbitMQPublisher, error) {
 	}, nil
 }
 
-// Publish sends a message to the specified RabbitMQ queue
-func (p *RabbitMQPublisher) Publish(ctx context.Context, queue string, message []byte) error {
+// Publish sends an event to the exchange and routing key of its type's route and waits for the
+// broker confirmation, retrying while the channel is being re-established. The destinations are
+// declared by the client topology
+func (p *RabbitMQPublisher) Publish(ctx context.Context, event events.Envelope) error {
+	route, ok := p.client.Topology().Route(event.Type)
+	if !ok {
+		return &interfaces.PublishError{Destination: event.Type, Err: interfaces.ErrPublishNoRoute}
+	}
+	destination := route.Exchange + "/" + route.RoutingKey
+
+	// Publish the event in binary mode; a mandatory message is returned by the broker if no queue takes it
+	if err := p.publish(ctx, route.Exchange, route.RoutingKey, route.Mandatory, EventPublishing(event)); err != nil {
+		log.Printf("Message to %s was not accepted (messageId: %s): %v", destination, event.ID, err)
+		return &interfaces.PublishError{Destination: destination, Err: err}
+	}
+
+	log.Printf("Published message to %s (messageId: %s)", destination, event.ID)
+	return nil
+}
+
+// publish sends a message and waits for the broker confirmation, retrying while the channel is
+// being re-established. Messages are published one at a time, so a returned message always belongs
+// to the current publish
+func (p *RabbitMQPublisher) publish(ctx context.Context, exchange, routingKey string, mandatory bool, msg amqp091.Publishing) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	// Retry a few times in case the connection/channel is being re-established
 	const maxRetries = 3
 	var lastErr error
@@ -37,77 +69,85 @@ func (p *RabbitMQPublisher) Publish(ctx context.Context, queue string, message [
 			time.Sleep(1 * time.Second)
 		}
 
-		ch := p.channel
-		if ch == nil || ch.IsClosed() {
-			// Try to create a fresh channel
-			newCh, err := p.client.CreateChannel()
-			if err != nil {
-				lastErr = fmt.Errorf("publisher channel unavailable: %w", err)
-				continue
-			}
-			p.channel = newCh
-			ch = newCh
-		}
-
-		// Declare the queue (idempotent)
-		if err := p.client.DeclareQueue(ch, queue); err != nil {
+		ch, err := p.confirmChannel()
+		if err != nil {
 			lastErr = err
-			// Force channel refresh on next attempt
-			_ = ch.Close()
-			p.channel = nil
 			continue
 		}
 
-		// Extract message ID from the message if present
-		var messageID string
-		var messageData map[string]interface{}
-		if err := json.Unmarshal(message, &messageData); err == nil {
-			if id, ok := messageData["messageId"].(string); ok {
-				messageID = id
-			}
-		}
-
-		// Prepare headers for message deduplication
-		headers := amqp091.Table{}
-		if messageID != "" {
-			// Set message_id header for RabbitMQ deduplication
-			headers["x-message-id"] = messageID
-		}
-		// Set timestamp for message ordering
-		headers["x-timestamp"] = time.Now().Unix()
-
-		// Publish the message
-		err := ch.PublishWithContext(
-			ctx,
-			"",
-			queue,
-			false,
-			false,
-			amqp091.Publishing{
-				DeliveryMode: amqp091.Persistent,
-				ContentType:  "application/json",
-				Body:         message,
-				Headers:      headers,
-				MessageId:    messageID, // Standard AMQP message ID for deduplication
-			},
-		)
+		confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, mandatory, false, msg)
 		if err != nil {
 			lastErr = fmt.Errorf("failed to publish message: %w", err)
 			// Force channel refresh on next attempt
-			_ = ch.Close()
-			p.channel = nil
+			p.resetChannel()
 			continue
 		}
 
-		log.Printf("Published message to queue: %s (messageId: %s)", queue, messageID)
-		return nil
+		return p.awaitConfirmation(ctx, confirmation)
 	}
 	return fmt.Errorf("publish failed after retries: %w", lastErr)
 }
 
+// awaitConfirmation waits for the broker to confirm a publish. The broker sends basic.return
+// before the ack of an unroutable message, so the return is already queued once the ack arrives
+func (p *RabbitMQPublisher) awaitConfirmation(ctx context.Context, confirmation *amqp091.DeferredConfirmation) error {
+	waitCtx, cancel := context.WithTimeout(ctx, p.client.GetConfig().PublishConfirmTimeout)
+	defer cancel()
+
+	acked, err := confirmation.WaitContext(waitCtx)
+	if err != nil {
+		// The channel may deliver the confirmation later; start over with a fresh one
+		p.resetChannel()
+		return fmt.Errorf("%w: %v", interfaces.ErrPublishUnconfirmed, err)
+	}
+
+	select {
+	case ret := <-p.returns:
+		return fmt.Errorf("%w: %d %s", interfaces.ErrPublishUnroutable, ret.ReplyCode, ret.ReplyText)
+	default:
+	}
+
+	if !acked {
+		return interfaces.ErrPublishNacked
+	}
+	return nil
+}
+
+// confirmChannel returns the publisher channel, creating it in confirm mode if needed
+func (p *RabbitMQPublisher) confirmChannel() (*amqp091.Channel, error) {
+	if p.channel != nil && !p.channel.IsClosed() {
+		return p.channel, nil
+	}
+
+	ch, err := p.client.CreateChannel()
+	if err != nil {
+		return nil, fmt.Errorf("publisher channel unavailable: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		_ = ch.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	p.channel = ch
+	p.returns = ch.NotifyReturn(make(chan amqp091.Return, 1))
+	return ch, nil
+}
+
+// resetChannel closes the publisher channel so the next publish opens a fresh one
+func (p *RabbitMQPublisher) resetChannel() {
+	if p.channel != nil {
+		_ = p.channel.Close()
+	}
+	p.channel = nil
+	p.returns = nil
+}
+
 // Close closes the publisher channel (connection is managed by RabbitMQClient)
 func (p *RabbitMQPublisher) Close() error {
-	if p.channel != nil {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.channel != nil && !p.channel.IsClosed() {
 		if err := p.channel.Close(); err != nil {
 			log.Printf("Error closing RabbitMQ publisher channel: %v", err)
 			return err
@@ -0,0 +1,112 @@
+package messaging
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+)
+
+const (
+	// RetryCountHeader counts how many times a message has already failed
+	RetryCountHeader = "x-retry-count"
+	// LastErrorHeader carries the handler error of a dead-lettered message
+	LastErrorHeader = "x-last-error"
+	// OriginalQueueHeader carries the queue a dead-lettered message was consumed from
+	OriginalQueueHeader = "x-original-queue"
+)
+
+// RetryPolicy decides when a failed message is retried and when it is dead-lettered
+type RetryPolicy struct {
+	// MaxAttempts is the number of deliveries before a message is dead-lettered
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry; every further retry doubles it
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries
+	MaxDelay time.Duration
+}
+
+// NewRetryPolicy builds the retry policy configured for RabbitMQ
+func NewRetryPolicy(cfg config.RabbitMQConfig) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: cfg.MaxDeliveryAttempts,
+		BaseDelay:   cfg.RetryBaseDelay,
+		MaxDelay:    cfg.RetryMaxDelay,
+	}
+}
+
+// NewKafkaRetryPolicy builds the retry policy configured for Kafka
+func NewKafkaRetryPolicy(cfg config.KafkaConfig) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: cfg.MaxDeliveryAttempts,
+		BaseDelay:   cfg.RetryBaseDelay,
+		MaxDelay:    cfg.RetryMaxDelay,
+	}
+}
+
+// Delay returns how long to wait before the given retry (1 for the first one)
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	if retry < 1 {
+		retry = 1
+	}
+	delay := p.BaseDelay
+	for i := 1; i < retry && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// ShouldDeadLetter reports whether a message whose delivery number attempt failed with err must
+// skip further retries: permanent errors never retry, and no message exceeds MaxAttempts
+func (p RetryPolicy) ShouldDeadLetter(err error, attempt int) bool {
+	return interfaces.IsPermanentMessageError(err) || attempt >= p.MaxAttempts
+}
+
+// RetryQueueName returns the delay queue holding messages waiting for the given retry of a queue
+func RetryQueueName(queue string, retry int) string {
+	return fmt.Sprintf("%s.retry.%d", queue, retry)
+}
+
+// DeadLetterExchangeName returns the exchange dead letters of a queue are published to
+func DeadLetterExchangeName(queue string) string {
+	return queue + ".dlx"
+}
+
+// DeadLetterQueueName returns the queue holding the dead letters of a queue
+func DeadLetterQueueName(queue string) string {
+	return queue + ".dlq"
+}
+
+// RetryCount reads the retry counter of a message; messages without one have not failed yet
+func RetryCount(headers amqp091.Table) int {
+	switch v := headers[RetryCountHeader].(type) {
+	case int:
+		return v
+	case int8:
+		return int(v)
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case uint8:
+		return int(v)
+	case uint16:
+		return int(v)
+	case uint32:
+		return int(v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return 0
+}
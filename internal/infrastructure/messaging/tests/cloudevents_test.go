@@ -0,0 +1,50 @@
+package messaging_test
+
+import (
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/messaging"
+)
+
+func TestEventPublishing_BinaryModeRoundTrip(t *testing.T) {
+	event, err := events.NewEnvelope(events.TypeDocumentsReady, events.DocumentsReadyVersion, "evt-1", events.DocumentsReadyEvent{IDCitizen: 3, Status: "success"})
+	require.NoError(t, err)
+
+	publishing := messaging.EventPublishing(event)
+	assert.Equal(t, events.ContentTypeJSON, publishing.ContentType)
+	assert.Equal(t, "evt-1", publishing.MessageId)
+	assert.Equal(t, events.TypeDocumentsReady, publishing.Headers["cloudEvents:type"])
+	assert.Equal(t, events.SpecVersion, publishing.Headers["cloudEvents:specversion"])
+	assert.JSONEq(t, `{"idCitizen":3,"status":"success"}`, string(publishing.Body), "the body is the bare payload")
+
+	received := messaging.DeliveryEvent(amqp091.Delivery{
+		Headers:     publishing.Headers,
+		ContentType: publishing.ContentType,
+		MessageId:   publishing.MessageId,
+		Body:        publishing.Body,
+	})
+	assert.Equal(t, event.ID, received.ID)
+	assert.Equal(t, event.Source, received.Source)
+	assert.Equal(t, event.Type, received.Type)
+	assert.Equal(t, event.DataSchema, received.DataSchema)
+	assert.Equal(t, "3", received.PartitionKey)
+	assert.True(t, event.Time.Equal(received.Time))
+	assert.Equal(t, event.Data, received.Data)
+}
+
+func TestDeliveryEvent_LegacyMessage(t *testing.T) {
+	received := messaging.DeliveryEvent(amqp091.Delivery{
+		Headers: amqp091.Table{"x-message-id": "legacy-1"},
+		Body:    []byte(`{"idCitizen":1}`),
+	})
+
+	assert.Equal(t, "legacy-1", received.ID)
+	assert.Empty(t, received.SpecVersion)
+	assert.Equal(t, 1, received.Version())
+	assert.JSONEq(t, `{"idCitizen":1}`, string(received.Data))
+}
@@ -0,0 +1,248 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/messaging"
+)
+
+// newKafkaCluster starts an in-process Kafka cluster with three partitions per topic
+func newKafkaCluster(t *testing.T, topics ...string) config.KafkaConfig {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(3, topics...), kfake.AllowAutoTopicCreation(), kfake.DefaultNumPartitions(3))
+	require.NoError(t, err)
+	t.Cleanup(cluster.Close)
+
+	cfg := config.DefaultKafkaConfig()
+	cfg.Brokers = cluster.ListenAddrs()
+	cfg.GroupID = "test"
+	cfg.AutoCreateTopics = true
+	return cfg
+}
+
+// citizenEvent builds a documents.ready event of a citizen identified by text
+func citizenEvent(t *testing.T, citizen int64, text string) events.Envelope {
+	event, err := events.NewEnvelope(events.TypeDocumentsReady, events.DocumentsReadyVersion, text, events.DocumentsReadyEvent{IDCitizen: citizen, Status: "success"})
+	require.NoError(t, err)
+	return event
+}
+
+// consumeRecords reads n records of a topic from the beginning without a group
+func consumeRecords(t *testing.T, cfg config.KafkaConfig, topic string, n int) []*kgo.Record {
+	client, err := kgo.NewClient(kgo.SeedBrokers(cfg.Brokers...), kgo.ConsumeTopics(topic))
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var records []*kgo.Record
+	for len(records) < n {
+		fetches := client.PollFetches(ctx)
+		require.NoError(t, ctx.Err(), "timed out waiting for records of %s", topic)
+		records = append(records, fetches.Records()...)
+	}
+	return records
+}
+
+func newKafkaConsumer(t *testing.T, cfg config.KafkaConfig) *messaging.KafkaConsumer {
+	consumer, err := messaging.NewKafkaConsumer(cfg, testRetryPolicy)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = consumer.Close() })
+	return consumer
+}
+
+func TestKafkaPublisher_KeysRecordsByCitizen(t *testing.T) {
+	ctx := context.Background()
+	cfg := newKafkaCluster(t, "documents.ready")
+	publisher, err := messaging.NewKafkaPublisher(cfg)
+	require.NoError(t, err)
+	defer func() { _ = publisher.Close() }()
+
+	for i := 0; i < 6; i++ {
+		citizen := int64(i % 2)
+		require.NoError(t, publisher.Publish(ctx, citizenEvent(t, citizen, "evt-"+strconv.Itoa(i))))
+	}
+
+	records := consumeRecords(t, cfg, "documents.ready", 6)
+	partitions := make(map[string]int32)
+	order := make(map[string][]string)
+	for _, record := range records {
+		event := messaging.RecordEvent(record)
+		assert.Equal(t, event.PartitionKey, string(record.Key))
+		if partition, ok := partitions[event.PartitionKey]; ok {
+			assert.Equal(t, partition, record.Partition, "the events of a citizen share a partition")
+		}
+		partitions[event.PartitionKey] = record.Partition
+		order[event.PartitionKey] = append(order[event.PartitionKey], event.ID)
+	}
+	assert.Equal(t, []string{"evt-0", "evt-2", "evt-4"}, order["0"])
+	assert.Equal(t, []string{"evt-1", "evt-3", "evt-5"}, order["1"])
+}
+
+func TestKafkaPublisher_TopicsFollowTheConfiguredMapping(t *testing.T) {
+	ctx := context.Background()
+	cfg := newKafkaCluster(t, "citizens.ready")
+	cfg.Topics = map[string]string{events.TypeDocumentsReady: "citizens.ready"}
+	publisher, err := messaging.NewKafkaPublisher(cfg)
+	require.NoError(t, err)
+	defer func() { _ = publisher.Close() }()
+
+	event := citizenEvent(t, 5, "mapped")
+	require.NoError(t, publisher.Publish(ctx, event))
+
+	records := consumeRecords(t, cfg, "citizens.ready", 1)
+	received := messaging.RecordEvent(records[0])
+	assert.Equal(t, event.ID, received.ID)
+	assert.Equal(t, event.Type, received.Type)
+	assert.Equal(t, event.Source, received.Source)
+	assert.Equal(t, event.DataSchema, received.DataSchema)
+	assert.Equal(t, event.DataContentType, received.DataContentType)
+	assert.True(t, event.Time.Equal(received.Time))
+	assert.JSONEq(t, string(event.Data), string(received.Data))
+}
+
+func TestKafkaConsumer_CommitsHandledRecords(t *testing.T) {
+	ctx := context.Background()
+	cfg := newKafkaCluster(t, "documents.ready")
+	publisher, err := messaging.NewKafkaPublisher(cfg)
+	require.NoError(t, err)
+	defer func() { _ = publisher.Close() }()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, publisher.Publish(ctx, citizenEvent(t, 7, "evt-"+strconv.Itoa(i))))
+	}
+
+	consumer := newKafkaConsumer(t, cfg)
+	received := &recorder{}
+	require.NoError(t, consumer.SubscribeToQueue(ctx, "documents.ready", func(_ context.Context, event events.Envelope) error {
+		received.add(event)
+		return nil
+	}))
+	assert.Eventually(t, func() bool { return len(received.all()) == 3 }, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"evt-0", "evt-1", "evt-2"}, received.all(), "the events of a citizen arrive in order")
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, consumer.Shutdown(shutdownCtx))
+	assert.ErrorIs(t, consumer.SubscribeToQueue(ctx, "documents.ready", func(context.Context, events.Envelope) error { return nil }), messaging.ErrConsumerStopped)
+
+	// A new member of the group resumes after the committed offsets
+	require.NoError(t, publisher.Publish(ctx, citizenEvent(t, 7, "evt-3")))
+	restarted := newKafkaConsumer(t, cfg)
+	again := &recorder{}
+	require.NoError(t, restarted.SubscribeToQueue(ctx, "documents.ready", func(_ context.Context, event events.Envelope) error {
+		again.add(event)
+		return nil
+	}))
+	assert.Eventually(t, func() bool { return len(again.all()) == 1 }, 10*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, []string{"evt-3"}, again.all())
+}
+
+func TestKafkaConsumer_RetriesInOrderThenDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	cfg := newKafkaCluster(t, "documents.ready", "documents.ready.dlq")
+	publisher, err := messaging.NewKafkaPublisher(cfg)
+	require.NoError(t, err)
+	defer func() { _ = publisher.Close() }()
+
+	require.NoError(t, publisher.Publish(ctx, citizenEvent(t, 9, "flaky")))
+	require.NoError(t, publisher.Publish(ctx, citizenEvent(t, 9, "poison")))
+	require.NoError(t, publisher.Publish(ctx, citizenEvent(t, 9, "after")))
+
+	var flakyAttempts atomic.Int32
+	received := &recorder{}
+	consumer := newKafkaConsumer(t, cfg)
+	require.NoError(t, consumer.SubscribeToQueue(ctx, "documents.ready", func(_ context.Context, event events.Envelope) error {
+		received.add(event)
+		switch event.ID {
+		case "flaky":
+			if flakyAttempts.Add(1) < 2 {
+				return errors.New("temporary failure")
+			}
+		case "poison":
+			return interfaces.NewPermanentMessageError(errors.New("malformed"))
+		}
+		return nil
+	}))
+
+	assert.Eventually(t, func() bool { return len(received.all()) == 4 }, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"flaky", "flaky", "poison", "after"}, received.all(), "a retried record holds back the records behind it")
+
+	dead := consumeRecords(t, cfg, "documents.ready.dlq", 1)
+	assert.Equal(t, "poison", messaging.RecordEvent(dead[0]).ID)
+	assert.Equal(t, "9", string(dead[0].Key))
+	headers := make(map[string]string)
+	for _, header := range dead[0].Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	assert.Equal(t, "1", headers[messaging.RetryCountHeader])
+	assert.Equal(t, "malformed", headers[messaging.LastErrorHeader])
+	assert.Equal(t, "documents.ready", headers[messaging.OriginalQueueHeader])
+}
+
+func TestKafkaConsumer_RedeliversRetriesOutlastingTheWaitBudget(t *testing.T) {
+	ctx := context.Background()
+	cfg := newKafkaCluster(t, "documents.ready", "documents.ready.dlq")
+	cfg.MaxRetryWait = 120 * time.Millisecond
+	publisher, err := messaging.NewKafkaPublisher(cfg)
+	require.NoError(t, err)
+	defer func() { _ = publisher.Close() }()
+
+	for _, id := range []string{"first", "second", "third"} {
+		require.NoError(t, publisher.Publish(ctx, citizenEvent(t, 9, id)))
+	}
+
+	received := &recorder{}
+	failures := map[string][]error{"second": {
+		interfaces.NewDeferredMessageError(errors.New("in progress")),
+		errors.New("transient"),
+	}}
+	var mu sync.Mutex
+	consumer, err := messaging.NewKafkaConsumer(cfg, messaging.RetryPolicy{MaxAttempts: 2, BaseDelay: 50 * time.Millisecond, MaxDelay: time.Second})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = consumer.Close() })
+	require.NoError(t, consumer.SubscribeToQueue(ctx, "documents.ready", func(_ context.Context, event events.Envelope) error {
+		received.add(event)
+		if event.ID == "first" {
+			// Uses up most of the wait budget of the batch
+			time.Sleep(100 * time.Millisecond)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if pending := failures[event.ID]; len(pending) > 0 {
+			failures[event.ID] = pending[1:]
+			return pending[0]
+		}
+		return nil
+	}))
+
+	// The second record is deferred past the budget of its batch: it is delivered again once the
+	// partition resumes instead of being dead-lettered, and the record behind it keeps its order
+	require.Eventually(t, func() bool { return len(received.all()) == 5 }, 15*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"first", "second", "second", "second", "third"}, received.all())
+}
+
+func TestRecordEvent_LegacyRecord(t *testing.T) {
+	received := messaging.RecordEvent(&kgo.Record{Topic: "user.transferred", Partition: 2, Offset: 41, Key: []byte("1"), Value: []byte(`{"idCitizen":1}`)})
+
+	assert.Equal(t, "user.transferred-2-41", received.ID, "records without id are identified by their position")
+	assert.Empty(t, received.SpecVersion)
+	assert.Equal(t, 1, received.Version())
+	assert.Equal(t, "1", received.PartitionKey)
+	assert.JSONEq(t, `{"idCitizen":1}`, string(received.Data))
+}
@@ -0,0 +1,337 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	adapters "github.com/kristianrpo/document-management-microservice/internal/adapters/events"
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/messaging"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/repository"
+)
+
+// recorder collects the IDs of the messages delivered to a handler
+type recorder struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *recorder) add(message events.Envelope) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, message.ID)
+}
+
+func (r *recorder) all() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.messages...)
+}
+
+// textEvent builds a test.text event identified by text, carrying text as its payload
+func textEvent(text string) events.Envelope {
+	return typedEvent("test.text", text)
+}
+
+// typedEvent builds an event of eventType identified by text, carrying text as its payload
+func typedEvent(eventType, text string) events.Envelope {
+	event, err := events.NewEnvelope(eventType, 1, text, text)
+	if err != nil {
+		panic(err)
+	}
+	return event
+}
+
+var testRetryPolicy = messaging.RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+// testTopology routes test.text events to the queue named "queue" through the default exchange
+var testTopology = config.Topology{
+	Queues: []config.QueueTopology{{Name: "queue"}},
+	Routes: map[string]config.RouteTopology{"test.text": {RoutingKey: "queue", Mandatory: true}},
+}
+
+func newBroker(t *testing.T, persistPath string) *messaging.MemoryBroker {
+	broker, err := messaging.NewMemoryBroker(persistPath, testRetryPolicy, testTopology)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = broker.Close() })
+	return broker
+}
+
+func TestMemoryBroker_DeliversInOrderAndAcks(t *testing.T) {
+	ctx := context.Background()
+	broker := newBroker(t, "")
+
+	require.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("first")))
+	require.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("second")))
+	require.NoError(t, broker.PublishToQueue(ctx, "other", textEvent("elsewhere")))
+
+	received := &recorder{}
+	require.NoError(t, broker.SubscribeToQueue(ctx, "queue", func(_ context.Context, message events.Envelope) error {
+		received.add(message)
+		return nil
+	}))
+	require.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("third")))
+
+	assert.Eventually(t, func() bool { return broker.Pending("queue") == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"first", "second", "third"}, received.all())
+	assert.Equal(t, 1, broker.Pending("other"), "queues are independent")
+}
+
+func TestMemoryBroker_FailedMessagesAreRetriedAfterBackoff(t *testing.T) {
+	ctx := context.Background()
+	broker := newBroker(t, "")
+	require.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("flaky")))
+	require.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("next")))
+
+	received := &recorder{}
+	failed := false
+	require.NoError(t, broker.SubscribeToQueue(ctx, "queue", func(_ context.Context, message events.Envelope) error {
+		received.add(message)
+		if message.ID == "flaky" && !failed {
+			failed = true
+			return errors.New("temporary failure")
+		}
+		return nil
+	}))
+
+	assert.Eventually(t, func() bool { return broker.Pending("queue") == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"flaky", "next", "flaky"}, received.all(), "a failed message does not block the rest of the queue")
+	assert.Equal(t, 0, broker.Pending(messaging.DeadLetterQueueName("queue")))
+}
+
+func TestMemoryBroker_DeadLettersAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	broker := newBroker(t, "")
+	require.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("poison")))
+
+	received := &recorder{}
+	require.NoError(t, broker.SubscribeToQueue(ctx, "queue", func(_ context.Context, message events.Envelope) error {
+		received.add(message)
+		return errors.New("always fails")
+	}))
+
+	assert.Eventually(t, func() bool {
+		return broker.Pending(messaging.DeadLetterQueueName("queue")) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0, broker.Pending("queue"))
+	assert.Len(t, received.all(), testRetryPolicy.MaxAttempts)
+}
+
+func TestMemoryBroker_PermanentErrorsSkipRetries(t *testing.T) {
+	ctx := context.Background()
+	broker := newBroker(t, "")
+	require.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("{invalid}")))
+
+	received := &recorder{}
+	require.NoError(t, broker.SubscribeToQueue(ctx, "queue", func(_ context.Context, message events.Envelope) error {
+		received.add(message)
+		return interfaces.NewPermanentMessageError(errors.New("malformed"))
+	}))
+
+	assert.Eventually(t, func() bool {
+		return broker.Pending(messaging.DeadLetterQueueName("queue")) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Len(t, received.all(), 1)
+}
+
+func TestMemoryBroker_DeferredErrorsDoNotUseUpAttempts(t *testing.T) {
+	ctx := context.Background()
+	broker := newBroker(t, "")
+	require.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("busy")))
+
+	received := &recorder{}
+	deferrals := testRetryPolicy.MaxAttempts + 1
+	require.NoError(t, broker.SubscribeToQueue(ctx, "queue", func(_ context.Context, message events.Envelope) error {
+		received.add(message)
+		if len(received.all()) <= deferrals {
+			return interfaces.NewDeferredMessageError(errors.New("in progress elsewhere"))
+		}
+		return nil
+	}))
+
+	assert.Eventually(t, func() bool { return broker.Pending("queue") == 0 }, time.Second, 10*time.Millisecond)
+	assert.Len(t, received.all(), deferrals+1)
+	assert.Equal(t, 0, broker.Pending(messaging.DeadLetterQueueName("queue")), "deferred messages are never dead-lettered for it")
+}
+
+func TestMemoryBroker_PersistsUnackedMessages(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "broker.json")
+
+	broker, err := messaging.NewMemoryBroker(path, testRetryPolicy, testTopology)
+	require.NoError(t, err)
+	require.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("kept")))
+	require.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("also kept")))
+	require.NoError(t, broker.Close())
+	assert.ErrorIs(t, broker.PublishToQueue(ctx, "queue", textEvent("late")), messaging.ErrBrokerClosed)
+
+	restarted := newBroker(t, path)
+	assert.Equal(t, 2, restarted.Pending("queue"))
+
+	received := &recorder{}
+	require.NoError(t, restarted.SubscribeToQueue(ctx, "queue", func(_ context.Context, message events.Envelope) error {
+		received.add(message)
+		return nil
+	}))
+	assert.Eventually(t, func() bool { return restarted.Pending("queue") == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"kept", "also kept"}, received.all())
+
+	require.NoError(t, restarted.Close())
+	empty := newBroker(t, path)
+	assert.Equal(t, 0, empty.Pending("queue"), "acknowledged messages are not restored")
+}
+
+func TestMemoryBroker_AuthenticationFlowEndToEnd(t *testing.T) {
+	ctx := context.Background()
+	broker := newBroker(t, "")
+	documents := repository.NewMemoryDocumentRepo(repository.NewMemoryDocumentStore())
+	processed := repository.NewMemoryProcessedMessageRepository()
+
+	document := &models.Document{Filename: "file.pdf", HashSHA256: "hash", ObjectKey: "objects/hash", OwnerID: 7}
+	require.NoError(t, documents.Create(ctx, document, models.Quota{}, nil))
+
+	handler := adapters.NewDocumentAuthenticationHandler(documents, events.NewDefaultRegistry(), usecases.NewDocumentEvents())
+	idempotent := adapters.NewIdempotentHandler(processed, time.Minute)
+	require.NoError(t, broker.SubscribeToQueue(ctx, "document.authentication.completed", idempotent.Wrap("authentication-handler", handler.HandleAuthenticationCompleted)))
+
+	event, err := events.NewEnvelope(events.TypeDocumentAuthenticationCompleted, events.DocumentAuthenticationCompletedVersion, "result-1", events.DocumentAuthenticationCompletedEvent{
+		MessageID:     "msg-1",
+		DocumentID:    document.ID,
+		IDCitizen:     7,
+		Authenticated: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, broker.PublishToQueue(ctx, "document.authentication.completed", event))
+
+	assert.Eventually(t, func() bool {
+		return broker.Pending("document.authentication.completed") == 0
+	}, time.Second, 10*time.Millisecond)
+
+	stored, err := documents.GetByID(ctx, document.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AuthenticationStatusAuthenticated, stored.AuthenticationStatus)
+
+	status, err := processed.Claim(ctx, models.NewProcessedMessageClaim("authentication-handler:result-1", "test", time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, interfaces.ClaimCompleted, status)
+}
+
+func TestMemoryBroker_PublishRoutesEventTypesByTopology(t *testing.T) {
+	ctx := context.Background()
+	topology := config.Topology{
+		Exchanges: []config.ExchangeTopology{{Name: "events", Kind: "topic"}, {Name: "broadcast", Kind: "fanout"}, {Name: "unbound", Kind: "topic"}},
+		Queues:    []config.QueueTopology{{Name: "documents"}, {Name: "audit"}, {Name: "direct"}, {Name: "everyone"}},
+		Bindings: []config.BindingTopology{
+			{Exchange: "events", Queue: "documents", RoutingKey: "document.*"},
+			{Exchange: "events", Queue: "audit", RoutingKey: "document.#"},
+			{Exchange: "events", Queue: "audit", RoutingKey: "#"},
+			{Exchange: "broadcast", Queue: "everyone", RoutingKey: "ignored"},
+		},
+		Routes: map[string]config.RouteTopology{
+			"document.uploaded":                 {Exchange: "events"},
+			"document.authentication.requested": {Exchange: "events"},
+			"user.transferred":                  {Exchange: "events"},
+			"test.direct":                       {RoutingKey: "direct", Mandatory: true},
+			"test.broadcast":                    {Exchange: "broadcast"},
+			"test.dropped":                      {Exchange: "unbound"},
+			"test.missing":                      {RoutingKey: "missing", Mandatory: true},
+		},
+	}
+	broker, err := messaging.NewMemoryBroker("", testRetryPolicy, topology)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = broker.Close() })
+
+	require.NoError(t, broker.Publish(ctx, typedEvent("document.uploaded", "uploaded")))
+	require.NoError(t, broker.Publish(ctx, typedEvent("document.authentication.requested", "requested")))
+	require.NoError(t, broker.Publish(ctx, typedEvent("user.transferred", "transferred")))
+	require.NoError(t, broker.Publish(ctx, typedEvent("test.direct", "direct")))
+	require.NoError(t, broker.Publish(ctx, typedEvent("test.broadcast", "broadcast")))
+	require.NoError(t, broker.Publish(ctx, typedEvent("test.dropped", "dropped")), "events nobody subscribed to are dropped")
+
+	assert.Equal(t, 1, broker.Pending("documents"), "* matches exactly one word")
+	assert.Equal(t, 3, broker.Pending("audit"), "a queue receives a message once even when several bindings match")
+	assert.Equal(t, 1, broker.Pending("direct"), "the default exchange routes to the queue named by the routing key")
+	assert.Equal(t, 1, broker.Pending("everyone"), "fanout exchanges ignore routing keys")
+	assert.Equal(t, 0, broker.Pending("document.uploaded"), "exchange messages never fall back to a queue named after the routing key")
+
+	err = broker.Publish(ctx, typedEvent("test.missing", "missing"))
+	assert.ErrorIs(t, err, interfaces.ErrPublishUnroutable, "mandatory events nobody takes fail")
+	err = broker.Publish(ctx, typedEvent("test.unknown", "unknown"))
+	assert.ErrorIs(t, err, interfaces.ErrPublishNoRoute)
+	var publishErr *interfaces.PublishError
+	require.ErrorAs(t, err, &publishErr)
+	assert.Equal(t, "test.unknown", publishErr.Destination)
+
+	received := &recorder{}
+	require.NoError(t, broker.SubscribeToQueue(ctx, "audit", func(_ context.Context, message events.Envelope) error {
+		received.add(message)
+		return nil
+	}))
+	assert.Eventually(t, func() bool { return broker.Pending("audit") == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"uploaded", "requested", "transferred"}, received.all())
+}
+
+func TestMemoryBroker_ShutdownDrainsInFlightMessages(t *testing.T) {
+	ctx := context.Background()
+	broker := newBroker(t, "")
+	require.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("slow")))
+	require.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("not started")))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	require.NoError(t, broker.SubscribeToQueue(ctx, "queue", func(_ context.Context, message events.Envelope) error {
+		if message.ID == "slow" {
+			close(started)
+			<-release
+		}
+		return nil
+	}))
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		shutdown <- broker.Shutdown(shutdownCtx)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	require.NoError(t, <-shutdown)
+
+	assert.Equal(t, 1, broker.Pending("queue"), "messages not started before the shutdown stay queued")
+	assert.ErrorIs(t, broker.SubscribeToQueue(ctx, "queue", func(context.Context, events.Envelope) error { return nil }), messaging.ErrBrokerClosed)
+	assert.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("published while draining")))
+}
+
+func TestMemoryBroker_ShutdownDeadlineCancelsHandlers(t *testing.T) {
+	ctx := context.Background()
+	broker := newBroker(t, "")
+	require.NoError(t, broker.PublishToQueue(ctx, "queue", textEvent("stuck")))
+
+	started := make(chan struct{})
+	require.NoError(t, broker.SubscribeToQueue(ctx, "queue", func(handlerCtx context.Context, _ events.Envelope) error {
+		close(started)
+		<-handlerCtx.Done()
+		return handlerCtx.Err()
+	}))
+	<-started
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, broker.Shutdown(shutdownCtx), context.DeadlineExceeded)
+
+	require.NoError(t, broker.Close())
+	assert.Equal(t, 1, broker.Pending("queue"), "the interrupted message is kept for a retry")
+}
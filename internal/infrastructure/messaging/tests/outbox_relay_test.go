@@ -0,0 +1,244 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	adapters "github.com/kristianrpo/document-management-microservice/internal/adapters/events"
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/application/usecases"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/messaging"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/repository"
+)
+
+// flakyPublisher fails the publishes of the event types listed in failing, and has no route for
+// the ones listed in unrouted
+type flakyPublisher struct {
+	mu        sync.Mutex
+	failing   map[string]bool
+	unrouted  map[string]bool
+	published []string
+}
+
+func (p *flakyPublisher) Publish(_ context.Context, event events.Envelope) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failing[event.Type] {
+		return errors.New("broker unavailable")
+	}
+	if p.unrouted[event.Type] {
+		return &interfaces.PublishError{Destination: event.Type, Err: interfaces.ErrPublishNoRoute}
+	}
+	p.published = append(p.published, event.ID)
+	return nil
+}
+
+func (p *flakyPublisher) setFailing(eventType string, failing bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.failing[eventType] = failing
+}
+
+func (p *flakyPublisher) all() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.published...)
+}
+
+func (p *flakyPublisher) Close() error { return nil }
+
+func TestOutboxRelay_PublishesAndKeepsFailedMessagesPending(t *testing.T) {
+	ctx := context.Background()
+	outbox := repository.NewMemoryOutboxRepo(repository.NewMemoryDocumentStore())
+	publisher := &flakyPublisher{failing: map[string]bool{"test.down": true}}
+	relay := messaging.NewOutboxRelay(outbox, publisher, time.Hour, messaging.RetryPolicy{MaxAttempts: 3}, nil, nil)
+
+	first, err := models.NewOutboxMessage(typedEvent("test.up", "first"))
+	require.NoError(t, err)
+	failed, err := models.NewOutboxMessage(typedEvent("test.down", "failed"))
+	require.NoError(t, err)
+	failed.CreatedAt = first.CreatedAt + 1
+	last, err := models.NewOutboxMessage(typedEvent("test.up", "last"))
+	require.NoError(t, err)
+	last.CreatedAt = first.CreatedAt + 2
+	require.NoError(t, outbox.Enqueue(ctx, first, failed, last))
+
+	published, err := relay.RelayPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []string{"first", "last"}, publisher.all())
+
+	pending, err := outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, failed.ID, pending[0].ID)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Equal(t, "broker unavailable", pending[0].LastError)
+
+	publisher.setFailing("test.down", false)
+	published, err = relay.RelayPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, []string{"first", "last", "failed"}, publisher.all())
+
+	pending, err = outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestOutboxRelay_FailedMessagesBackOffAndDoNotStarveNewerOnes(t *testing.T) {
+	ctx := context.Background()
+	outbox := repository.NewMemoryOutboxRepo(repository.NewMemoryDocumentStore())
+	publisher := &flakyPublisher{failing: map[string]bool{"test.down": true}}
+	relay := messaging.NewOutboxRelay(outbox, publisher, time.Hour, messaging.NewOutboxRetryPolicy(3, time.Hour, time.Hour), nil, nil)
+
+	failed, err := models.NewOutboxMessage(typedEvent("test.down", "failed"))
+	require.NoError(t, err)
+	require.NoError(t, outbox.Enqueue(ctx, failed))
+
+	published, err := relay.RelayPending(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, published)
+
+	newer, err := models.NewOutboxMessage(typedEvent("test.up", "newer"))
+	require.NoError(t, err)
+	require.NoError(t, outbox.Enqueue(ctx, newer))
+
+	pending, err := outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1, "the failed message waits for its backoff")
+	assert.Equal(t, newer.ID, pending[0].ID)
+
+	published, err = relay.RelayPending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, []string{"newer"}, publisher.all())
+}
+
+func TestOutboxRelay_ParksExhaustedAndUnpublishableMessages(t *testing.T) {
+	ctx := context.Background()
+	outbox := repository.NewMemoryOutboxRepo(repository.NewMemoryDocumentStore())
+	publisher := &flakyPublisher{failing: map[string]bool{"test.down": true}, unrouted: map[string]bool{"test.nowhere": true}}
+	relay := messaging.NewOutboxRelay(outbox, publisher, time.Hour, messaging.RetryPolicy{MaxAttempts: 2}, nil, nil)
+
+	failing, err := models.NewOutboxMessage(typedEvent("test.down", "failing"))
+	require.NoError(t, err)
+	noRoute, err := models.NewOutboxMessage(typedEvent("test.nowhere", "no-route"))
+	require.NoError(t, err)
+	corrupt := &models.OutboxMessage{ID: "corrupt", Payload: "{"}
+	require.NoError(t, outbox.Enqueue(ctx, failing, noRoute, corrupt))
+
+	_, err = relay.RelayPending(ctx)
+	require.NoError(t, err)
+	pending, err := outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1, "permanent failures are parked right away")
+	assert.Equal(t, failing.ID, pending[0].ID)
+	assert.Equal(t, 1, pending[0].Attempts)
+
+	_, err = relay.RelayPending(ctx)
+	require.NoError(t, err)
+	pending, err = outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending, "the message is parked once it runs out of attempts")
+}
+
+func TestOutboxRelay_CompensatesParkedAuthenticationRequests(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryDocumentStore()
+	documents := repository.NewMemoryDocumentRepo(store)
+	outbox := repository.NewMemoryOutboxRepo(store)
+	publisher := &flakyPublisher{unrouted: map[string]bool{events.TypeDocumentAuthenticationRequested: true}}
+
+	document := &models.Document{Filename: "file.pdf", HashSHA256: "hash", ObjectKey: "objects/hash", OwnerID: 1}
+	require.NoError(t, documents.Create(ctx, document, models.Quota{}, nil))
+	request, err := events.NewEnvelope(events.TypeDocumentAuthenticationRequested, events.DocumentAuthenticationRequestedVersion, "request",
+		events.DocumentAuthenticationRequestedEvent{MessageID: "request", IDCitizen: 1, DocumentID: document.ID})
+	require.NoError(t, err)
+	message, err := models.NewOutboxMessage(request)
+	require.NoError(t, err)
+	require.NoError(t, documents.UpdateAuthenticationStatus(ctx, document.ID, 0, models.AuthenticationStatusAuthenticating, func([]*models.Document) ([]*models.OutboxMessage, error) {
+		return []*models.OutboxMessage{message}, nil
+	}))
+
+	handler := adapters.NewDocumentAuthenticationHandler(documents, events.NewDefaultRegistry(), usecases.NewDocumentEvents())
+	compensationFails := true
+	parked := map[string]interfaces.MessageHandler{
+		events.TypeDocumentAuthenticationRequested: func(ctx context.Context, event events.Envelope) error {
+			if compensationFails {
+				return errors.New("database unavailable")
+			}
+			return handler.HandleAuthenticationRequestParked(ctx, event)
+		},
+	}
+	relay := messaging.NewOutboxRelay(outbox, publisher, time.Hour, messaging.RetryPolicy{MaxAttempts: 3}, parked, nil)
+
+	_, err = relay.RelayPending(ctx)
+	require.NoError(t, err)
+	pending, err := outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1, "a message whose compensation fails is not parked")
+	assert.Equal(t, message.ID, pending[0].ID)
+	assert.Contains(t, pending[0].LastError, "database unavailable")
+
+	compensationFails = false
+	_, err = relay.RelayPending(ctx)
+	require.NoError(t, err)
+	stored, err := documents.GetByID(ctx, document.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AuthenticationStatusUnauthenticated, stored.AuthenticationStatus, "the document does not stay authenticating")
+
+	// Only the status change of the compensation is left to publish
+	pending, err = outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	changed, err := pending[0].Event()
+	require.NoError(t, err)
+	assert.Equal(t, events.TypeDocumentAuthenticationStatusChanged, changed.Type)
+}
+
+func TestOutboxRelay_RunDeliversThroughTheBrokerUntilCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	store := repository.NewMemoryDocumentStore()
+	documents := repository.NewMemoryDocumentRepo(store)
+	outbox := repository.NewMemoryOutboxRepo(store)
+	broker := newBroker(t, "")
+
+	document := &models.Document{Filename: "file.pdf", HashSHA256: "hash", ObjectKey: "objects/hash", OwnerID: 1}
+	require.NoError(t, documents.Create(ctx, document, models.Quota{}, nil))
+	message, err := models.NewOutboxMessage(textEvent("request"))
+	require.NoError(t, err)
+	require.NoError(t, documents.UpdateAuthenticationStatus(ctx, document.ID, 0, models.AuthenticationStatusAuthenticating, func([]*models.Document) ([]*models.OutboxMessage, error) {
+		return []*models.OutboxMessage{message}, nil
+	}))
+
+	received := &recorder{}
+	require.NoError(t, broker.SubscribeToQueue(ctx, "queue", func(_ context.Context, message events.Envelope) error {
+		received.add(message)
+		return nil
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		messaging.NewOutboxRelay(outbox, broker, 10*time.Millisecond, messaging.NewOutboxRetryPolicy(3, 10*time.Millisecond, time.Second), nil, nil).Run(ctx)
+	}()
+
+	assert.Eventually(t, func() bool { return len(received.all()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"request"}, received.all())
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("relay did not stop after cancellation")
+	}
+}
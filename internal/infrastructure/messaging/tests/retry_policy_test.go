@@ -0,0 +1,57 @@
+package messaging_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/messaging"
+)
+
+func TestRetryPolicy_DelayIsExponentialAndCapped(t *testing.T) {
+	policy := messaging.RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	assert.Equal(t, time.Second, policy.Delay(1))
+	assert.Equal(t, 2*time.Second, policy.Delay(2))
+	assert.Equal(t, 8*time.Second, policy.Delay(4))
+	assert.Equal(t, 10*time.Second, policy.Delay(5))
+	assert.Equal(t, 10*time.Second, policy.Delay(60))
+}
+
+func TestRetryPolicy_ShouldDeadLetter(t *testing.T) {
+	policy := messaging.NewRetryPolicy(config.DefaultRabbitMQConfig())
+	transient := errors.New("timeout")
+	permanent := interfaces.NewPermanentMessageError(errors.New("malformed"))
+
+	assert.False(t, policy.ShouldDeadLetter(transient, 1))
+	assert.True(t, policy.ShouldDeadLetter(transient, policy.MaxAttempts))
+	assert.True(t, policy.ShouldDeadLetter(permanent, 1))
+	assert.True(t, policy.ShouldDeadLetter(fmt.Errorf("wrapped: %w", permanent), 1))
+}
+
+func TestNewKafkaRetryPolicy(t *testing.T) {
+	cfg := config.DefaultKafkaConfig()
+	policy := messaging.NewKafkaRetryPolicy(cfg)
+
+	assert.Equal(t, messaging.RetryPolicy{MaxAttempts: cfg.MaxDeliveryAttempts, BaseDelay: cfg.RetryBaseDelay, MaxDelay: cfg.RetryMaxDelay}, policy)
+	assert.Less(t, cfg.MaxRetryWait, cfg.RebalanceTimeout, "the default retries fit in the rebalance timeout")
+}
+
+func TestRetryCount(t *testing.T) {
+	assert.Equal(t, 0, messaging.RetryCount(nil))
+	assert.Equal(t, 2, messaging.RetryCount(amqp091.Table{messaging.RetryCountHeader: int32(2)}))
+	assert.Equal(t, 3, messaging.RetryCount(amqp091.Table{messaging.RetryCountHeader: int64(3)}))
+	assert.Equal(t, 4, messaging.RetryCount(amqp091.Table{messaging.RetryCountHeader: "4"}))
+}
+
+func TestQueueNames(t *testing.T) {
+	assert.Equal(t, "documents.retry.2", messaging.RetryQueueName("documents", 2))
+	assert.Equal(t, "documents.dlx", messaging.DeadLetterExchangeName("documents"))
+	assert.Equal(t, "documents.dlq", messaging.DeadLetterQueueName("documents"))
+}
@@ -22,7 +22,7 @@ type PrometheusMetrics struct {
 	AuthRequestsTotal       prometheus.Counter
 	AuthCompletedTotal      *prometheus.CounterVec
 
-	StorageUploadDuration   prometheus.Histogram
+	StorageUploadDuration   *prometheus.HistogramVec
 	StorageDownloadDuration prometheus.Histogram
 	StorageErrorsTotal      *prometheus.CounterVec
 
@@ -32,6 +32,7 @@ type PrometheusMetrics struct {
 	MessagesPublishedTotal *prometheus.CounterVec
 	MessagesConsumedTotal  *prometheus.CounterVec
 	MessageErrorsTotal     *prometheus.CounterVec
+	OutboxParkedTotal      *prometheus.CounterVec
 }
 
 // NewPrometheusMetrics creates and registers all Prometheus metrics
@@ -124,13 +125,14 @@ func NewPrometheusMetrics(namespace string) *PrometheusMetrics {
 			[]string{"result"},
 		),
 
-		StorageUploadDuration: promauto.NewHistogram(
+		StorageUploadDuration: promauto.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Namespace: namespace,
 				Name:      "storage_upload_duration_seconds",
-				Help:      "Duration of storage upload operations in seconds",
-				Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 30},
+				Help:      "Duration of storage upload operations in seconds by pipeline stage",
+				Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300},
 			},
+			[]string{"stage"},
 		),
 		StorageDownloadDuration: promauto.NewHistogram(
 			prometheus.HistogramOpts{
@@ -191,6 +193,14 @@ func NewPrometheusMetrics(namespace string) *PrometheusMetrics {
 			},
 			[]string{"queue", "type"},
 		),
+		OutboxParkedTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "outbox_parked_total",
+				Help:      "Total number of outbox messages the relay gave up on by event type",
+			},
+			[]string{"event_type"},
+		),
 	}
 }
 
@@ -212,7 +222,7 @@ func (m *PrometheusMetrics) RecordDatabaseQuery(operation string, duration time.
 func (m *PrometheusMetrics) RecordStorageOperation(operation string, duration time.Duration, err error) {
 	switch operation {
 	case "upload":
-		m.StorageUploadDuration.Observe(duration.Seconds())
+		m.StorageUploadDuration.WithLabelValues("total").Observe(duration.Seconds())
 	case "download":
 		m.StorageDownloadDuration.Observe(duration.Seconds())
 	}
@@ -222,6 +232,15 @@ func (m *PrometheusMetrics) RecordStorageOperation(operation string, duration ti
 	}
 }
 
+// RecordStorageUploadStage records the duration of a single stage of the upload pipeline
+// (e.g. streaming parts to the staging key, promoting or discarding the staged object)
+func (m *PrometheusMetrics) RecordStorageUploadStage(stage string, duration time.Duration, err error) {
+	m.StorageUploadDuration.WithLabelValues(stage).Observe(duration.Seconds())
+	if err != nil {
+		m.StorageErrorsTotal.WithLabelValues("upload_" + stage).Inc()
+	}
+}
+
 // RecordMessagePublished records a message publish metric
 func (m *PrometheusMetrics) RecordMessagePublished(queue string, err error) {
 	if err != nil {
@@ -239,3 +258,8 @@ func (m *PrometheusMetrics) RecordMessageConsumed(queue string, err error) {
 		m.MessagesConsumedTotal.WithLabelValues(queue).Inc()
 	}
 }
+
+// RecordOutboxParked records an outbox message the relay gave up on
+func (m *PrometheusMetrics) RecordOutboxParked(eventType string) {
+	m.OutboxParkedTotal.WithLabelValues(eventType).Inc()
+}
@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+)
+
+const (
+	// blobRefPosition is the index of the counter update in the transaction of Create
+	blobRefPosition = 1
+
+	// blobReleaseLease is how long a released object stays unreferenceable while it is deleted
+	// from storage. A release whose deletion failed expires after it and the object can be used again
+	blobReleaseLease = 10 * time.Minute
+
+	// blobReleasingAttr holds the expiry, in Unix milliseconds, of the release of an object
+	blobReleasingAttr = "ReleasingUntil"
+
+	// blobCountedAttr marks the documents whose reference is counted. Documents stored before the
+	// counters lack it until the 0004_blob_ref_counts migration counts them
+	blobCountedAttr = "BlobCounted"
+
+	// blobNotReleasingCondition holds for a counter with no live release; it expects :now
+	blobNotReleasingCondition = "(attribute_not_exists(" + blobReleasingAttr + ") OR " + blobReleasingAttr + " < :now)"
+)
+
+// dynamoDBBlobReferenceRepository implements BlobReferenceRepository on a dedicated DynamoDB table
+// keyed by ObjectKey with a numeric RefCount attribute
+type dynamoDBBlobReferenceRepository struct {
+	client             *dynamodb.Client
+	documentsTableName string
+	tableName          string
+}
+
+// NewDynamoDBBlobReferenceRepo creates a new DynamoDB blob reference repository
+// documentsTableName is scanned when the counters have to be rebuilt
+func NewDynamoDBBlobReferenceRepo(client *dynamodb.Client, documentsTableName, tableName string) interfaces.BlobReferenceRepository {
+	return &dynamoDBBlobReferenceRepository{
+		client:             client,
+		documentsTableName: documentsTableName,
+		tableName:          tableName,
+	}
+}
+
+// ReleaseIfUnreferenced takes the lease of an object whose counter dropped to zero (or below) and
+// no other release holds. A failed condition means the object is still referenced, is released by
+// another caller, or has no counter and is kept
+func (repo *dynamoDBBlobReferenceRepository) ReleaseIfUnreferenced(ctx context.Context, objectKey string) (bool, error) {
+	now := time.Now()
+	_, err := repo.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(repo.tableName),
+		Key:                 blobRefKey(objectKey),
+		UpdateExpression:    aws.String("SET " + blobReleasingAttr + " = :until"),
+		ConditionExpression: aws.String("attribute_exists(ObjectKey) AND RefCount <= :zero AND " + blobNotReleasingCondition),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":zero":  &types.AttributeValueMemberN{Value: "0"},
+			":now":   millisAttribute(now),
+			":until": millisAttribute(now.Add(blobReleaseLease)),
+		},
+	})
+	if err != nil {
+		if isConditionalCheckFailure(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to release blob reference %s: %w", objectKey, err)
+	}
+	return true, nil
+}
+
+// ListUnreferenced returns up to limit objects whose counter dropped to zero or below and no live
+// release holds. The counters table has no index on the count, so it is scanned until enough
+// objects are found
+func (repo *dynamoDBBlobReferenceRepository) ListUnreferenced(ctx context.Context, limit int) ([]string, error) {
+	var objectKeys []string
+	paginator := dynamodb.NewScanPaginator(repo.client, &dynamodb.ScanInput{
+		TableName:            aws.String(repo.tableName),
+		ProjectionExpression: aws.String("ObjectKey"),
+		FilterExpression:     aws.String("RefCount <= :zero AND " + blobNotReleasingCondition),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":zero": &types.AttributeValueMemberN{Value: "0"},
+			":now":  millisAttribute(time.Now()),
+		},
+	})
+
+	for paginator.HasMorePages() && len(objectKeys) < limit {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan blob references: %w", err)
+		}
+		for _, item := range page.Items {
+			if key, ok := item["ObjectKey"].(*types.AttributeValueMemberS); ok && len(objectKeys) < limit {
+				objectKeys = append(objectKeys, key.Value)
+			}
+		}
+	}
+	return objectKeys, nil
+}
+
+// FinishRelease deletes the counter of a released object, unless a document referenced it again
+func (repo *dynamoDBBlobReferenceRepository) FinishRelease(ctx context.Context, objectKey string) error {
+	_, err := repo.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(repo.tableName),
+		Key:                 blobRefKey(objectKey),
+		ConditionExpression: aws.String("RefCount <= :zero AND attribute_exists(" + blobReleasingAttr + ")"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":zero": &types.AttributeValueMemberN{Value: "0"},
+		},
+	})
+	if err != nil && !isConditionalCheckFailure(err) {
+		return fmt.Errorf("failed to finish release of blob reference %s: %w", objectKey, err)
+	}
+	return nil
+}
+
+// RebuildReferenceCounts scans the documents table, counts the documents pointing to each object
+// and overwrites the counters table accordingly. Counters of objects that are no longer referenced
+// are removed. It is meant to be run from the repair command, ideally while writes are paused
+func (repo *dynamoDBBlobReferenceRepository) RebuildReferenceCounts(ctx context.Context) (int, error) {
+	counts, err := repo.countDocumentReferences(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	stale, err := repo.listStaleCounters(ctx, counts)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for objectKey, refCount := range counts {
+		_, err := repo.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(repo.tableName),
+			Item: map[string]types.AttributeValue{
+				"ObjectKey": &types.AttributeValueMemberS{Value: objectKey},
+				"RefCount":  &types.AttributeValueMemberN{Value: strconv.FormatInt(refCount, 10)},
+				"UpdatedAt": &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)},
+			},
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to write blob reference %s: %w", objectKey, err)
+		}
+	}
+
+	for _, objectKey := range stale {
+		_, err := repo.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(repo.tableName),
+			Key:       blobRefKey(objectKey),
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete stale blob reference %s: %w", objectKey, err)
+		}
+	}
+
+	return len(counts), nil
+}
+
+// countDocumentReferences scans the documents table and counts documents per object key
+func (repo *dynamoDBBlobReferenceRepository) countDocumentReferences(ctx context.Context) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	paginator := dynamodb.NewScanPaginator(repo.client, &dynamodb.ScanInput{
+		TableName:            aws.String(repo.documentsTableName),
+		ProjectionExpression: aws.String("ObjectKey"),
+		FilterExpression:     aws.String("attribute_exists(ObjectKey)"),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan documents: %w", err)
+		}
+		for _, item := range page.Items {
+			if key, ok := item["ObjectKey"].(*types.AttributeValueMemberS); ok && key.Value != "" {
+				counts[key.Value]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+// listStaleCounters returns the object keys that have a counter but no referencing document
+func (repo *dynamoDBBlobReferenceRepository) listStaleCounters(ctx context.Context, counts map[string]int64) ([]string, error) {
+	var stale []string
+	paginator := dynamodb.NewScanPaginator(repo.client, &dynamodb.ScanInput{
+		TableName:            aws.String(repo.tableName),
+		ProjectionExpression: aws.String("ObjectKey"),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan blob references: %w", err)
+		}
+		for _, item := range page.Items {
+			key, ok := item["ObjectKey"].(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			if _, referenced := counts[key.Value]; !referenced {
+				stale = append(stale, key.Value)
+			}
+		}
+	}
+	return stale, nil
+}
+
+// blobRefKey returns the primary key of a blob reference counter
+func blobRefKey(objectKey string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"ObjectKey": &types.AttributeValueMemberS{Value: objectKey},
+	}
+}
+
+// blobCounted reports whether the reference of a document item is counted
+func blobCounted(item map[string]types.AttributeValue) bool {
+	counted, ok := item[blobCountedAttr].(*types.AttributeValueMemberBOOL)
+	return ok && counted.Value
+}
+
+// blobRefUpdate builds a transactional update that adds delta to the counter of an object,
+// creating the counter if it doesn't exist yet. A new reference fails while the object is released,
+// and takes over an expired release
+func blobRefUpdate(tableName, objectKey string, delta int64, now time.Time) types.TransactWriteItem {
+	update := &types.Update{
+		TableName:        aws.String(tableName),
+		Key:              blobRefKey(objectKey),
+		UpdateExpression: aws.String("ADD RefCount :delta SET UpdatedAt = :updated"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":delta":   &types.AttributeValueMemberN{Value: strconv.FormatInt(delta, 10)},
+			":updated": &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)},
+		},
+	}
+	if delta > 0 {
+		update.UpdateExpression = aws.String("ADD RefCount :delta SET UpdatedAt = :updated REMOVE " + blobReleasingAttr)
+		update.ConditionExpression = aws.String(blobNotReleasingCondition)
+		update.ExpressionAttributeValues[":now"] = millisAttribute(now)
+	}
+	return types.TransactWriteItem{Update: update}
+}
+
+// ensureBlobRefsTable creates the blob references table if it doesn't exist
+func ensureBlobRefsTable(ctx context.Context, client *dynamodb.Client, tableName string) error {
+	return ensureHashKeyTable(ctx, client, tableName, "ObjectKey")
+}
+
+// ensureHashKeyTable creates a table keyed by a single string attribute if it doesn't exist
+func ensureHashKeyTable(ctx context.Context, client *dynamodb.Client, tableName, keyAttr string) error {
+	_, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err == nil {
+		return nil
+	}
+
+	_, err = client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(tableName),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String(keyAttr),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String(keyAttr),
+				KeyType:       types.KeyTypeHash,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create table %s: %w", tableName, err)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(client)
+	return waiter.Wait(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	}, time.Second*30)
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// Hash guards make the content of the documents of an owner unique. Create puts the guard of the
+// owner and hash of a document, pointing to it, in the same transaction as the document and only
+// if it does not exist yet, so two concurrent uploads of the same content cannot both succeed.
+// Unlike the HashOwnerIndex GSI, the guards table can be read consistently
+
+const (
+	// hashGuardKeyAttr is the key of the guards table, the owner and hash of a document
+	hashGuardKeyAttr = "OwnerHash"
+
+	// hashGuardPosition is the index of the guard put in the transaction of Create
+	hashGuardPosition = 2
+)
+
+// hashGuard is the item of the guards table
+type hashGuard struct {
+	OwnerHash  string `dynamodbav:"OwnerHash"`
+	DocumentID string `dynamodbav:"DocumentID"`
+}
+
+// hashGuardKey returns the key of the guard of an owner and content hash
+func hashGuardKey(ownerID int64, hash string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		hashGuardKeyAttr: &types.AttributeValueMemberS{Value: fmt.Sprintf("%d#%s", ownerID, hash)},
+	}
+}
+
+// hashGuardPut builds the transactional put of the guard of a new document, failing if the owner
+// already has a document with the same content
+func hashGuardPut(tableName string, document *models.Document) types.TransactWriteItem {
+	item := hashGuardKey(document.OwnerID, document.HashSHA256)
+	item["DocumentID"] = &types.AttributeValueMemberS{Value: document.ID}
+
+	return types.TransactWriteItem{
+		Put: &types.Put{
+			TableName:           aws.String(tableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(" + hashGuardKeyAttr + ")"),
+		},
+	}
+}
+
+// hashGuardDelete builds the transactional delete of the guard of a document. Documents written
+// before the guards have none, which deletes nothing
+func hashGuardDelete(tableName string, document *models.Document) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		Delete: &types.Delete{
+			TableName: aws.String(tableName),
+			Key:       hashGuardKey(document.OwnerID, document.HashSHA256),
+		},
+	}
+}
+
+// getHashGuard reads the guard of an owner and content hash consistently, returning nil if there
+// is none
+func getHashGuard(ctx context.Context, client *dynamodb.Client, tableName string, ownerID int64, hash string) (*hashGuard, error) {
+	result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(tableName),
+		Key:            hashGuardKey(ownerID, hash),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hash guard: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var guard hashGuard
+	if err := attributevalue.UnmarshalMap(result.Item, &guard); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal hash guard: %w", err)
+	}
+	return &guard, nil
+}
+
+// ensureHashGuardsTable creates the hash guards table if it doesn't exist
+func ensureHashGuardsTable(ctx context.Context, client *dynamodb.Client, tableName string) error {
+	return ensureHashKeyTable(ctx, client, tableName, hashGuardKeyAttr)
+}
@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// NewDynamoDBDocumentMigrator creates the runner of the migrations of the documents table
+func NewDynamoDBDocumentMigrator(client *dynamodb.Client, tableName, hashGuardsTableName, blobRefsTableName, usageTableName string) *DynamoDBMigrator {
+	return NewDynamoDBMigrator(client, tableName, documentMigrations(client, tableName, hashGuardsTableName, blobRefsTableName, usageTableName))
+}
+
+// documentMigrations are the changes made to the documents table since it was first released.
+// Tables created by EnsureTableExists already have the indexes, and their migrations only record
+// it. Released migrations must never change; later changes go to new ones
+func documentMigrations(client *dynamodb.Client, tableName, hashGuardsTableName, blobRefsTableName, usageTableName string) []DynamoDBMigration {
+	sortIndexes := make([]DynamoDBIndex, 0, len(documentSortIndexes))
+	for _, index := range documentSortIndexes {
+		sortIndexes = append(sortIndexes, index.migrationIndex())
+	}
+
+	return []DynamoDBMigration{
+		{
+			// Documents are listed from an index per sort field, which leave out the documents
+			// written before them until their sort keys are set
+			Version:    "0001_sort_indexes",
+			AddIndexes: sortIndexes,
+			Backfill:   sortKeysBackfill(client, tableName),
+		},
+		{
+			// Documents written before the hash guards have none, so their content could be
+			// uploaded again by the same owner
+			Version:  "0002_hash_guards",
+			Backfill: hashGuardsBackfill(client, tableName, hashGuardsTableName),
+		},
+		{
+			// Trashed documents are purged from the purge index; no document was trashed before it
+			Version:    "0003_purge_index",
+			AddIndexes: []DynamoDBIndex{purgeIndex},
+		},
+		{
+			// Documents written before the reference counters are not counted, so the object of
+			// one of them could be deleted while it still references it
+			Version:  "0004_blob_ref_counts",
+			Backfill: blobRefCountsBackfill(client, tableName, blobRefsTableName),
+		},
+		{
+			// Documents trashed before trashing released their usage are still counted, so the
+			// trash of an owner takes from its quota until purged
+			Version:  "0005_trash_usage",
+			Backfill: trashUsageBackfill(client, tableName, usageTableName),
+		},
+	}
+}
+
+// sortKeysBackfill sets the sort keys of every document lacking one of them
+func sortKeysBackfill(client *dynamodb.Client, tableName string) *DynamoDBBackfill {
+	return &DynamoDBBackfill{
+		FilterExpression:     "attribute_not_exists(" + createdAtSortKeyAttr + ") OR attribute_not_exists(" + filenameSortKeyAttr + ")",
+		ProjectionExpression: "DocumentID, OwnerID, CreatedAt, Filename",
+		Update: func(ctx context.Context, item map[string]types.AttributeValue) (bool, error) {
+			var document models.Document
+			if err := attributevalue.UnmarshalMap(item, &document); err != nil {
+				return false, fmt.Errorf(errUnmarshalDocument, err)
+			}
+
+			if err := setSortKeys(ctx, client, tableName, &document); err != nil {
+				if isConditionalCheckFailure(err) {
+					// Deleted since the scan
+					return false, nil
+				}
+				return false, fmt.Errorf("failed to backfill sort keys of document %s: %w", document.ID, err)
+			}
+			return true, nil
+		},
+	}
+}
+
+// hashGuardsBackfill puts the missing guard of every document. The guard is put only while the
+// document exists, in one transaction, so a document deleted meanwhile leaves no guard behind. An
+// owner holding the same content twice from before the guards keeps both documents, the first
+// one scanned getting the guard
+func hashGuardsBackfill(client *dynamodb.Client, tableName, hashGuardsTableName string) *DynamoDBBackfill {
+	return &DynamoDBBackfill{
+		FilterExpression:     "attribute_exists(HashSHA256)",
+		ProjectionExpression: "DocumentID, OwnerID, HashSHA256",
+		Update: func(ctx context.Context, item map[string]types.AttributeValue) (bool, error) {
+			var document models.Document
+			if err := attributevalue.UnmarshalMap(item, &document); err != nil {
+				return false, fmt.Errorf(errUnmarshalDocument, err)
+			}
+
+			_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+				TransactItems: []types.TransactWriteItem{
+					{
+						ConditionCheck: &types.ConditionCheck{
+							TableName:           aws.String(tableName),
+							Key:                 documentKey(&document),
+							ConditionExpression: aws.String(documentExistsCondition),
+						},
+					},
+					hashGuardPut(hashGuardsTableName, &document),
+				},
+			})
+			if err != nil {
+				if isConditionalCheckFailure(err) {
+					// Deleted since the scan, or guarded already
+					return false, nil
+				}
+				return false, fmt.Errorf("failed to backfill hash guard of document %s: %w", document.ID, err)
+			}
+			return true, nil
+		},
+	}
+}
+
+// blobRefCountsBackfill counts the reference of every document not counted yet, marking it counted
+// in the same transaction so that it is counted once. Documents written between the counters and
+// the mark were counted already and are counted again; their objects are kept when unreferenced,
+// since an overcounted object is never released
+func blobRefCountsBackfill(client *dynamodb.Client, tableName, blobRefsTableName string) *DynamoDBBackfill {
+	return &DynamoDBBackfill{
+		FilterExpression:     "attribute_exists(ObjectKey) AND attribute_not_exists(" + blobCountedAttr + ")",
+		ProjectionExpression: "DocumentID, OwnerID, ObjectKey",
+		Update: func(ctx context.Context, item map[string]types.AttributeValue) (bool, error) {
+			var document models.Document
+			if err := attributevalue.UnmarshalMap(item, &document); err != nil {
+				return false, fmt.Errorf(errUnmarshalDocument, err)
+			}
+
+			_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+				TransactItems: []types.TransactWriteItem{
+					{
+						Update: &types.Update{
+							TableName:           aws.String(tableName),
+							Key:                 documentKey(&document),
+							UpdateExpression:    aws.String("SET " + blobCountedAttr + " = :counted"),
+							ConditionExpression: aws.String(documentExistsCondition + " AND attribute_not_exists(" + blobCountedAttr + ")"),
+							ExpressionAttributeValues: map[string]types.AttributeValue{
+								":counted": &types.AttributeValueMemberBOOL{Value: true},
+							},
+						},
+					},
+					{
+						Update: &types.Update{
+							TableName:        aws.String(blobRefsTableName),
+							Key:              blobRefKey(document.ObjectKey),
+							UpdateExpression: aws.String("ADD RefCount :one"),
+							ExpressionAttributeValues: map[string]types.AttributeValue{
+								":one": &types.AttributeValueMemberN{Value: "1"},
+							},
+						},
+					},
+				},
+			})
+			if err != nil {
+				if isConditionalCheckFailure(err) {
+					// Deleted since the scan, or counted already
+					return false, nil
+				}
+				return false, fmt.Errorf("failed to backfill blob reference of document %s: %w", document.ID, err)
+			}
+			return true, nil
+		},
+	}
+}
+
+// trashUsageBackfill releases the usage of every trashed document still counted, marking it
+// released in the same transaction so that it is released once. The counters of an owner not
+// initialized yet are left missing, the document only being marked, since they are summed from the
+// documents not marked when initialized
+func trashUsageBackfill(client *dynamodb.Client, tableName, usageTableName string) *DynamoDBBackfill {
+	return &DynamoDBBackfill{
+		FilterExpression:     trashedCondition + " AND " + usageReleasedCondition(false),
+		ProjectionExpression: "DocumentID, OwnerID, SizeBytes",
+		Update: func(ctx context.Context, item map[string]types.AttributeValue) (bool, error) {
+			var document models.Document
+			if err := attributevalue.UnmarshalMap(item, &document); err != nil {
+				return false, fmt.Errorf(errUnmarshalDocument, err)
+			}
+
+			mark := types.TransactWriteItem{
+				Update: &types.Update{
+					TableName:           aws.String(tableName),
+					Key:                 documentKey(&document),
+					UpdateExpression:    aws.String("SET " + usageReleasedAttr + " = :released"),
+					ConditionExpression: aws.String(documentExistsCondition + " AND " + trashedCondition + " AND " + usageReleasedCondition(false)),
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":released": &types.AttributeValueMemberBOOL{Value: true},
+					},
+				},
+			}
+			// The counters are either released or checked missing, and may be initialized between
+			// both attempts
+			usageWrites := []types.TransactWriteItem{
+				usageRelease(usageTableName, document.OwnerID, 1, document.SizeBytes),
+				{
+					ConditionCheck: &types.ConditionCheck{
+						TableName:           aws.String(usageTableName),
+						Key:                 usageKey(document.OwnerID),
+						ConditionExpression: aws.String("attribute_not_exists(" + usageKeyAttr + ")"),
+					},
+				},
+			}
+			for attempt := 0; ; attempt++ {
+				_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+					TransactItems: []types.TransactWriteItem{mark, usageWrites[attempt%len(usageWrites)]},
+				})
+				if err == nil {
+					return true, nil
+				}
+				if transactionConditionFailed(err, 0) {
+					// Deleted or restored since the scan, or released already
+					return false, nil
+				}
+				if !transactionConditionFailed(err, 1) || attempt == len(usageWrites) {
+					return false, fmt.Errorf("failed to backfill trash usage of document %s: %w", document.ID, err)
+				}
+			}
+		},
+	}
+}
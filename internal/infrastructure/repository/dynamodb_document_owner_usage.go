@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// The usage table holds the number and total size of the documents of every owner outside the
+// trash. Create and Restore add a document to the counters of its owner in the same transaction as
+// the document, only if the quota of the owner allows it, and Trash subtracts it the same way,
+// marking the document with UsageReleased; deleting a document not marked subtracts it instead.
+// The counters of an owner are initialized from its unmarked documents the first time a write needs
+// them, so owners with documents from before the table need no backfill; until then every write of
+// their documents fails on the counters and none can change them
+
+const (
+	// usageKeyAttr is the key of the usage table, the owner ID
+	usageKeyAttr = "OwnerID"
+
+	// usagePosition is the index of the usage update in the transaction of Create
+	usagePosition = 3
+
+	// usageExistsCondition is the condition of every update of the counters
+	usageExistsCondition = "attribute_exists(" + usageKeyAttr + ")"
+
+	// usageReleasedAttr marks the trashed documents no longer counted in the usage of their owner.
+	// Documents trashed before it lack it until the 0005_trash_usage migration releases them
+	usageReleasedAttr = "UsageReleased"
+)
+
+// ownerUsage is the item of the usage table
+type ownerUsage struct {
+	OwnerID       string `dynamodbav:"OwnerID"`
+	DocumentCount int64  `dynamodbav:"DocumentCount"`
+	TotalBytes    int64  `dynamodbav:"TotalBytes"`
+}
+
+// usageKey returns the key of the counters of an owner
+func usageKey(ownerID int64) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		usageKeyAttr: &types.AttributeValueMemberS{Value: fmt.Sprintf("%d", ownerID)},
+	}
+}
+
+// usageReserve builds the transactional update adding a new document to the counters of its
+// owner, failing if the counters are missing or quota does not allow the document. The caller
+// checks first that quota allows the document to an owner with no usage
+func usageReserve(tableName string, document *models.Document, quota models.Quota) types.TransactWriteItem {
+	condition := usageExistsCondition
+	values := map[string]types.AttributeValue{
+		":documents": &types.AttributeValueMemberN{Value: "1"},
+		":bytes":     &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", document.SizeBytes)},
+	}
+	if quota.MaxDocuments > 0 {
+		condition += " AND DocumentCount < :maxDocuments"
+		values[":maxDocuments"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", quota.MaxDocuments)}
+	}
+	if quota.MaxBytes > 0 {
+		condition += " AND TotalBytes <= :maxBytes"
+		values[":maxBytes"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", quota.MaxBytes-document.SizeBytes)}
+	}
+
+	return types.TransactWriteItem{
+		Update: &types.Update{
+			TableName:                 aws.String(tableName),
+			Key:                       usageKey(document.OwnerID),
+			UpdateExpression:          aws.String("ADD DocumentCount :documents, TotalBytes :bytes"),
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeValues: values,
+		},
+	}
+}
+
+// usageRelease builds the transactional update subtracting deleted documents from the counters of
+// their owner, failing if the counters are missing
+func usageRelease(tableName string, ownerID, documents, bytes int64) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		Update: &types.Update{
+			TableName:           aws.String(tableName),
+			Key:                 usageKey(ownerID),
+			UpdateExpression:    aws.String("ADD DocumentCount :documents, TotalBytes :bytes"),
+			ConditionExpression: aws.String(usageExistsCondition),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":documents": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", -documents)},
+				":bytes":     &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", -bytes)},
+			},
+		},
+	}
+}
+
+// usageReleased reports whether the item of a document is no longer counted in the usage of its
+// owner
+func usageReleased(item map[string]types.AttributeValue) bool {
+	released, ok := item[usageReleasedAttr].(*types.AttributeValueMemberBOOL)
+	return ok && released.Value
+}
+
+// usageReleasedCondition is the condition on a document item that it was read with the given
+// released state, so that a write does not count it twice
+func usageReleasedCondition(released bool) string {
+	if released {
+		return "attribute_exists(" + usageReleasedAttr + ")"
+	}
+	return "attribute_not_exists(" + usageReleasedAttr + ")"
+}
+
+// GetUsage returns the usage counters of an owner, initializing them if missing
+func (repo *dynamoDBDocumentRepository) GetUsage(ctx context.Context, ownerID int64) (*models.OwnerUsage, error) {
+	usage, err := repo.loadUsage(ctx, ownerID)
+	if err != nil || usage != nil {
+		return usage, err
+	}
+	return repo.initializeUsage(ctx, ownerID)
+}
+
+// loadUsage reads the counters of an owner consistently, returning nil if they are missing
+func (repo *dynamoDBDocumentRepository) loadUsage(ctx context.Context, ownerID int64) (*models.OwnerUsage, error) {
+	result, err := repo.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(repo.usageTableName),
+		Key:            usageKey(ownerID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage of owner %d: %w", ownerID, err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var item ownerUsage
+	if err := attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal usage: %w", err)
+	}
+	return &models.OwnerUsage{OwnerID: ownerID, DocumentCount: item.DocumentCount, TotalBytes: item.TotalBytes}, nil
+}
+
+// initializeUsage puts the counters of an owner summed from its documents still counted, those
+// outside the trash or trashed before UsageReleased, unless they were put meanwhile, and returns
+// them
+func (repo *dynamoDBDocumentRepository) initializeUsage(ctx context.Context, ownerID int64) (*models.OwnerUsage, error) {
+	usage := &models.OwnerUsage{OwnerID: ownerID}
+
+	input := repo.buildQueryInput(ownerID)
+	input.FilterExpression = aws.String(usageReleasedCondition(false))
+	input.ProjectionExpression = aws.String("SizeBytes")
+	paginator := dynamodb.NewQueryPaginator(repo.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sum usage of owner %d: %w", ownerID, err)
+		}
+		for _, item := range page.Items {
+			var document models.Document
+			if err := attributevalue.UnmarshalMap(item, &document); err != nil {
+				return nil, fmt.Errorf(errUnmarshalDocument, err)
+			}
+			usage.DocumentCount++
+			usage.TotalBytes += document.SizeBytes
+		}
+	}
+
+	item, err := attributevalue.MarshalMap(ownerUsage{
+		OwnerID:       fmt.Sprintf("%d", ownerID),
+		DocumentCount: usage.DocumentCount,
+		TotalBytes:    usage.TotalBytes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal usage: %w", err)
+	}
+
+	_, err = repo.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(repo.usageTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(" + usageKeyAttr + ")"),
+	})
+	if err != nil {
+		if isConditionalCheckFailure(err) {
+			// Initialized concurrently, and possibly changed since
+			return repo.loadUsage(ctx, ownerID)
+		}
+		return nil, fmt.Errorf("failed to initialize usage of owner %d: %w", ownerID, err)
+	}
+	return usage, nil
+}
+
+// ensureUsageTable creates the usage table if it doesn't exist
+func ensureUsageTable(ctx context.Context, client *dynamodb.Client, tableName string) error {
+	return ensureHashKeyTable(ctx, client, tableName, usageKeyAttr)
+}
@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -21,32 +24,65 @@ const (
 	ownerIDIndexName   = "OwnerIDIndex"
 
 	// Batch operation limits
-	maxBatchDeleteSize = 25 // DynamoDB BatchWriteItem limit
-	bulkQueryLimit     = 1000
+	maxBatchDeleteSize = 25 // Documents per trash transaction, along with their outbox messages
+
+	// filteredQueryBatch is the least number of items a filtered list query reads at once
+	filteredQueryBatch = 100
 
 	// DynamoDB expression attribute names
 	ownerIDAttr = ":ownerid"
 
+	// Condition expressions
+	documentExistsCondition    = "attribute_exists(DocumentID)"
+	documentNotExistsCondition = "attribute_not_exists(DocumentID)"
+
 	// Error messages
 	errUnmarshalDocument = "failed to unmarshal document: %w"
 )
 
 // dynamoDBDocumentRepository implements the DocumentRepository interface using AWS DynamoDB
+// Every write that adds or removes a document also updates the reference counter of its
+// storage object in the blob references table, the guard of its content in the hash guards
+// table and the usage of its owner in the usage table within the same transaction. The events of
+// every write are put in the outbox table in the same transaction as well
 type dynamoDBDocumentRepository struct {
-	client    *dynamodb.Client
-	tableName string
+	client              *dynamodb.Client
+	tableName           string
+	blobRefsTableName   string
+	outboxTableName     string
+	hashGuardsTableName string
+	usageTableName      string
 }
 
 // NewDynamoDBDocumentRepo creates a new DynamoDB document repository
-func NewDynamoDBDocumentRepo(client *dynamodb.Client, tableName string) interfaces.DocumentRepository {
+func NewDynamoDBDocumentRepo(client *dynamodb.Client, tableName, blobRefsTableName, outboxTableName, hashGuardsTableName, usageTableName string) interfaces.DocumentRepository {
 	return &dynamoDBDocumentRepository{
-		client:    client,
-		tableName: tableName,
+		client:              client,
+		tableName:           tableName,
+		blobRefsTableName:   blobRefsTableName,
+		outboxTableName:     outboxTableName,
+		hashGuardsTableName: hashGuardsTableName,
+		usageTableName:      usageTableName,
 	}
 }
 
-// EnsureTableExists creates the documents table if it doesn't exist
+// EnsureTableExists creates the documents, blob references, hash guards and usage tables if they
+// don't exist
 func (repo *dynamoDBDocumentRepository) EnsureTableExists(ctx context.Context) error {
+	if err := repo.ensureDocumentsTable(ctx); err != nil {
+		return err
+	}
+	if err := ensureBlobRefsTable(ctx, repo.client, repo.blobRefsTableName); err != nil {
+		return err
+	}
+	if err := ensureHashGuardsTable(ctx, repo.client, repo.hashGuardsTableName); err != nil {
+		return err
+	}
+	return ensureUsageTable(ctx, repo.client, repo.usageTableName)
+}
+
+// ensureDocumentsTable creates the documents table if it doesn't exist
+func (repo *dynamoDBDocumentRepository) ensureDocumentsTable(ctx context.Context) error {
 	// Check if table already exists
 	_, err := repo.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
 		TableName: aws.String(repo.tableName),
@@ -58,23 +94,61 @@ func (repo *dynamoDBDocumentRepository) EnsureTableExists(ctx context.Context) e
 	}
 
 	// Table doesn't exist, create it
-	_, err = repo.client.CreateTable(ctx, &dynamodb.CreateTableInput{
-		TableName:   aws.String(repo.tableName),
-		BillingMode: types.BillingModePayPerRequest,
-		AttributeDefinitions: []types.AttributeDefinition{
-			{
-				AttributeName: aws.String("DocumentID"),
-				AttributeType: types.ScalarAttributeTypeS,
+	attributes := []types.AttributeDefinition{
+		{
+			AttributeName: aws.String("DocumentID"),
+			AttributeType: types.ScalarAttributeTypeS,
+		},
+		{
+			AttributeName: aws.String("OwnerID"),
+			AttributeType: types.ScalarAttributeTypeN,
+		},
+		{
+			AttributeName: aws.String("HashSHA256"),
+			AttributeType: types.ScalarAttributeTypeS,
+		},
+	}
+	indexes := []types.GlobalSecondaryIndex{
+		{
+			IndexName: aws.String(ownerIDIndexName),
+			KeySchema: []types.KeySchemaElement{
+				{
+					AttributeName: aws.String("OwnerID"),
+					KeyType:       types.KeyTypeHash,
+				},
 			},
-			{
-				AttributeName: aws.String("OwnerID"),
-				AttributeType: types.ScalarAttributeTypeN,
+			Projection: &types.Projection{
+				ProjectionType: types.ProjectionTypeAll,
 			},
-			{
-				AttributeName: aws.String("HashSHA256"),
-				AttributeType: types.ScalarAttributeTypeS,
+		},
+		{
+			IndexName: aws.String(hashOwnerIndexName),
+			KeySchema: []types.KeySchemaElement{
+				{
+					AttributeName: aws.String("HashSHA256"),
+					KeyType:       types.KeyTypeHash,
+				},
+				{
+					AttributeName: aws.String("OwnerID"),
+					KeyType:       types.KeyTypeRange,
+				},
+			},
+			Projection: &types.Projection{
+				ProjectionType: types.ProjectionTypeAll,
 			},
 		},
+	}
+	for _, index := range documentSortIndexes {
+		attributes = append(attributes, index.attributeDefinition())
+		indexes = append(indexes, index.definition())
+	}
+	attributes = append(attributes, purgeIndex.Attributes...)
+	indexes = append(indexes, purgeIndex.Definition)
+
+	_, err = repo.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:            aws.String(repo.tableName),
+		BillingMode:          types.BillingModePayPerRequest,
+		AttributeDefinitions: attributes,
 		KeySchema: []types.KeySchemaElement{
 			{
 				AttributeName: aws.String("DocumentID"),
@@ -85,36 +159,7 @@ func (repo *dynamoDBDocumentRepository) EnsureTableExists(ctx context.Context) e
 				KeyType:       types.KeyTypeRange,
 			},
 		},
-		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
-			{
-				IndexName: aws.String(ownerIDIndexName),
-				KeySchema: []types.KeySchemaElement{
-					{
-						AttributeName: aws.String("OwnerID"),
-						KeyType:       types.KeyTypeHash,
-					},
-				},
-				Projection: &types.Projection{
-					ProjectionType: types.ProjectionTypeAll,
-				},
-			},
-			{
-				IndexName: aws.String(hashOwnerIndexName),
-				KeySchema: []types.KeySchemaElement{
-					{
-						AttributeName: aws.String("HashSHA256"),
-						KeyType:       types.KeyTypeHash,
-					},
-					{
-						AttributeName: aws.String("OwnerID"),
-						KeyType:       types.KeyTypeRange,
-					},
-				},
-				Projection: &types.Projection{
-					ProjectionType: types.ProjectionTypeAll,
-				},
-			},
-		},
+		GlobalSecondaryIndexes: indexes,
 	})
 
 	if err != nil {
@@ -128,8 +173,10 @@ func (repo *dynamoDBDocumentRepository) EnsureTableExists(ctx context.Context) e
 	}, time.Second*30)
 }
 
-// Create stores a new document in DynamoDB, generating an ID and timestamps if not present
-func (repo *dynamoDBDocumentRepository) Create(ctx context.Context, document *models.Document) error {
+// Create stores a new document in DynamoDB at version 1, generating an ID and timestamps if not
+// present, along with the guard of its owner and content and the usage of its owner. It fails
+// with ErrQuotaExceeded if quota does not allow the owner to store it
+func (repo *dynamoDBDocumentRepository) Create(ctx context.Context, document *models.Document, quota models.Quota, outbox interfaces.OutboxFunc) error {
 	if document.ID == "" {
 		document.ID = uuid.New().String()
 	}
@@ -138,26 +185,83 @@ func (repo *dynamoDBDocumentRepository) Create(ctx context.Context, document *mo
 		document.CreatedAt = now
 	}
 	document.UpdatedAt = now
+	document.Version = 1
 
 	item, err := attributevalue.MarshalMap(document)
 	if err != nil {
 		return fmt.Errorf("failed to marshal document: %w", err)
 	}
+	for name, value := range documentSortKeys(document) {
+		item[name] = value
+	}
+	item[blobCountedAttr] = &types.AttributeValueMemberBOOL{Value: true}
 
-	_, err = repo.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(repo.tableName),
-		Item:      item,
-	})
+	if !quota.Allows(models.OwnerUsage{OwnerID: document.OwnerID}, document.SizeBytes) {
+		return interfaces.ErrQuotaExceeded
+	}
+
+	messages, err := outboxPuts(repo.outboxTableName, outbox, document)
 	if err != nil {
-		return fmt.Errorf("failed to create document in DynamoDB: %w", err)
+		return err
 	}
 
-	return nil
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:           aws.String(repo.tableName),
+					Item:                item,
+					ConditionExpression: aws.String(documentNotExistsCondition),
+				},
+			},
+			blobRefUpdate(repo.blobRefsTableName, document.ObjectKey, 1, now),
+			hashGuardPut(repo.hashGuardsTableName, document),
+			usageReserve(repo.usageTableName, document, quota),
+		}, messages...),
+	}
+
+	// Written again once if the usage of the owner was missing and got initialized
+	for attempt := 0; ; attempt++ {
+		_, err = repo.client.TransactWriteItems(ctx, input)
+		if err == nil {
+			return nil
+		}
+		if transactionConditionFailed(err, hashGuardPosition) {
+			return interfaces.ErrDuplicateDocument
+		}
+		if transactionConditionFailed(err, blobRefPosition) {
+			return interfaces.ErrObjectReleasing
+		}
+		if !transactionConditionFailed(err, usagePosition) {
+			return fmt.Errorf("failed to create document in DynamoDB: %w", err)
+		}
+
+		usage, err := repo.loadUsage(ctx, document.OwnerID)
+		if err != nil {
+			return err
+		}
+		if usage != nil || attempt > 0 {
+			return interfaces.ErrQuotaExceeded
+		}
+		if _, err := repo.initializeUsage(ctx, document.OwnerID); err != nil {
+			return err
+		}
+	}
 }
 
-// FindByHashAndOwnerID retrieves a document by its hash and owner ID using the HashOwnerIndex GSI
+// FindByHashAndOwnerID retrieves a document by its hash and owner ID from the guard of the pair,
+// which is read consistently. Documents written before the guards have none and are looked up in
+// the HashOwnerIndex GSI instead, whose reads are eventually consistent
 // This is used for file deduplication
 func (repo *dynamoDBDocumentRepository) FindByHashAndOwnerID(ctx context.Context, hashSHA256 string, ownerID int64) (*models.Document, error) {
+	guard, err := getHashGuard(ctx, repo.client, repo.hashGuardsTableName, ownerID, hashSHA256)
+	if err != nil {
+		return nil, err
+	}
+	if guard != nil {
+		return repo.GetByID(ctx, guard.DocumentID)
+	}
+
 	result, err := repo.client.Query(ctx, &dynamodb.QueryInput{
 		TableName:              aws.String(repo.tableName),
 		IndexName:              aws.String(hashOwnerIndexName),
@@ -185,13 +289,28 @@ func (repo *dynamoDBDocumentRepository) FindByHashAndOwnerID(ctx context.Context
 
 // GetByID retrieves a document by its unique identifier
 func (repo *dynamoDBDocumentRepository) GetByID(ctx context.Context, id string) (*models.Document, error) {
+	item, err := repo.getItemByID(ctx, id)
+	if err != nil || item == nil {
+		return nil, err
+	}
+
+	var document models.Document
+	if err := attributevalue.UnmarshalMap(item, &document); err != nil {
+		return nil, fmt.Errorf(errUnmarshalDocument, err)
+	}
+	return &document, nil
+}
+
+// getItemByID reads the item of a document consistently, returning nil if it doesn't exist
+func (repo *dynamoDBDocumentRepository) getItemByID(ctx context.Context, id string) (map[string]types.AttributeValue, error) {
 	result, err := repo.client.Query(ctx, &dynamodb.QueryInput{
 		TableName:              aws.String(repo.tableName),
 		KeyConditionExpression: aws.String("DocumentID = :id"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":id": &types.AttributeValueMemberS{Value: id},
 		},
-		Limit: aws.Int32(1),
+		ConsistentRead: aws.Bool(true),
+		Limit:          aws.Int32(1),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get document by ID: %w", err)
@@ -200,16 +319,12 @@ func (repo *dynamoDBDocumentRepository) GetByID(ctx context.Context, id string)
 	if len(result.Items) == 0 {
 		return nil, nil
 	}
-
-	var document models.Document
-	if err := attributevalue.UnmarshalMap(result.Items[0], &document); err != nil {
-		return nil, fmt.Errorf(errUnmarshalDocument, err)
-	}
-	return &document, nil
+	return result.Items[0], nil
 }
 
-// List retrieves a paginated list of documents for a specific owner using the OwnerIDIndex GSI
-// Returns documents sorted by creation date (most recent first)
+// List retrieves a paginated list of the documents of an owner outside the trash, most recent
+// first, from the OwnerCreatedAtIndex GSI ListPage sorts by default. The total is counted from the
+// OwnerIDIndex GSI, which also holds the documents the sort keys migration has not backfilled yet
 func (repo *dynamoDBDocumentRepository) List(ctx context.Context, ownerID int64, limit, offset int) ([]*models.Document, int64, error) {
 	totalCount, err := repo.countDocumentsByOwner(ctx, ownerID)
 	if err != nil {
@@ -228,28 +343,100 @@ func (repo *dynamoDBDocumentRepository) List(ctx context.Context, ownerID int64,
 	return documents, totalCount, nil
 }
 
-// countDocumentsByOwner returns the total count of documents for a specific owner
+// countDocumentsByOwner returns the total count of documents outside the trash for a specific owner
 func (repo *dynamoDBDocumentRepository) countDocumentsByOwner(ctx context.Context, ownerID int64) (int64, error) {
-	countInput := &dynamodb.QueryInput{
-		TableName:              aws.String(repo.tableName),
-		IndexName:              aws.String(ownerIDIndexName),
-		KeyConditionExpression: aws.String("OwnerID = " + ownerIDAttr),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			ownerIDAttr: &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", ownerID)},
-		},
-		Select: types.SelectCount,
+	return repo.countQuery(ctx, repo.buildNotTrashedQueryInput(ownerID))
+}
+
+// countQuery returns the number of items matched by a query
+// A count query reads up to 1 MB per request, so it follows LastEvaluatedKey until the end
+func (repo *dynamoDBDocumentRepository) countQuery(ctx context.Context, countInput *dynamodb.QueryInput) (int64, error) {
+	countInput.Select = types.SelectCount
+
+	var total int64
+	for {
+		countResult, err := repo.client.Query(ctx, countInput)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count documents: %w", err)
+		}
+		total += int64(countResult.Count)
+		if countResult.LastEvaluatedKey == nil {
+			return total, nil
+		}
+		countInput.ExclusiveStartKey = countResult.LastEvaluatedKey
 	}
+}
 
-	countResult, err := repo.client.Query(ctx, countInput)
-	if err != nil {
-		return 0, fmt.Errorf("failed to count documents: %w", err)
+// ListPage retrieves up to limit documents of an owner matching filter using the sort index of the
+// sort field, starting at the key the previous page ended on, so each page reads only its own
+// items. Items discarded by the filter expression, trashed documents included, are still read, so
+// a page may take several queries. Queries of filtered pages read more items than the page needs
+// and the page then ends on the key of its last document. When a page ends exactly on the last
+// document, DynamoDB still returns a key and the following page is empty
+func (repo *dynamoDBDocumentRepository) ListPage(ctx context.Context, ownerID int64, sort models.DocumentSort, filter models.DocumentFilter, limit int, position string) ([]*models.Document, string, error) {
+	index := sortIndexFor(sort.Field)
+	queryInput := repo.buildListQueryInput(ownerID, sort, filter)
+	if position != "" {
+		startKey, err := decodeStartKey(position)
+		if err != nil {
+			return nil, "", err
+		}
+		queryInput.ExclusiveStartKey = startKey
 	}
-	return int64(countResult.Count), nil
+
+	documents := make([]*models.Document, 0, limit)
+	for {
+		// A query stops after 1 MB of items, so a page may take several of them
+		batch := limit - len(documents)
+		if !filter.IsZero() && batch < filteredQueryBatch {
+			batch = filteredQueryBatch
+		}
+		queryInput.Limit = aws.Int32(int32(batch))
+		result, err := repo.executeQuery(ctx, queryInput)
+		if err != nil {
+			return nil, "", err
+		}
+
+		for i, item := range result.Items {
+			var doc models.Document
+			if err := attributevalue.UnmarshalMap(item, &doc); err != nil {
+				return nil, "", fmt.Errorf(errUnmarshalDocument, err)
+			}
+			documents = append(documents, &doc)
+
+			if len(documents) == limit {
+				if i == len(result.Items)-1 && result.LastEvaluatedKey == nil {
+					return documents, "", nil
+				}
+				next, err := encodeStartKey(index.startKey(item))
+				if err != nil {
+					return nil, "", err
+				}
+				return documents, next, nil
+			}
+		}
+
+		if result.LastEvaluatedKey == nil {
+			return documents, "", nil
+		}
+		queryInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// CountByOwner returns the number of documents of an owner matching filter. Without criteria every
+// document of the owner outside the trash is counted from the OwnerIDIndex GSI; otherwise the count runs on the
+// creation date index, whose key condition takes the created date range
+func (repo *dynamoDBDocumentRepository) CountByOwner(ctx context.Context, ownerID int64, filter models.DocumentFilter) (int64, error) {
+	if filter.IsZero() {
+		return repo.countDocumentsByOwner(ctx, ownerID)
+	}
+	return repo.countQuery(ctx, repo.buildListQueryInput(ownerID, models.DocumentSort{}.WithDefaults(), filter))
 }
 
-// fetchPaginatedDocuments retrieves paginated documents for an owner
+// fetchPaginatedDocuments retrieves paginated documents outside the trash for an owner, most
+// recent first
 func (repo *dynamoDBDocumentRepository) fetchPaginatedDocuments(ctx context.Context, ownerID int64, limit, offset int) ([]*models.Document, error) {
-	queryInput := repo.buildQueryInput(ownerID)
+	queryInput := repo.buildListQueryInput(ownerID, models.DocumentSort{}.WithDefaults(), models.DocumentFilter{})
 
 	var documents []*models.Document
 	pagination := &paginationState{
@@ -310,10 +497,74 @@ func (repo *dynamoDBDocumentRepository) buildQueryInput(ownerID int64) *dynamodb
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			ownerIDAttr: &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", ownerID)},
 		},
-		ScanIndexForward: aws.Bool(false),
 	}
 }
 
+// buildNotTrashedQueryInput creates a query input for fetching the documents of an owner outside the trash
+func (repo *dynamoDBDocumentRepository) buildNotTrashedQueryInput(ownerID int64) *dynamodb.QueryInput {
+	queryInput := repo.buildQueryInput(ownerID)
+	queryInput.FilterExpression = aws.String(notTrashedCondition)
+	return queryInput
+}
+
+// buildListQueryInput creates a query input listing the documents of an owner matching filter
+// from the sort index of sort. The created date range narrows the key condition when documents
+// are sorted by creation date; every other criterion, the trash included, goes to the filter
+// expression
+func (repo *dynamoDBDocumentRepository) buildListQueryInput(ownerID int64, sort models.DocumentSort, filter models.DocumentFilter) *dynamodb.QueryInput {
+	index := sortIndexFor(sort.Field)
+	queryInput := repo.buildQueryInput(ownerID)
+	queryInput.IndexName = aws.String(index.name)
+	queryInput.ScanIndexForward = aws.Bool(sort.Order == models.SortOrderAsc)
+
+	keyCondition := "OwnerID = " + ownerIDAttr
+	conditions := []string{notTrashedCondition}
+	if filter.Trashed {
+		conditions[0] = trashedCondition
+	}
+	values := queryInput.ExpressionAttributeValues
+
+	var createdRange string
+	switch {
+	case !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero():
+		createdRange = createdAtSortKeyAttr + " BETWEEN :createdFrom AND :createdTo"
+	case !filter.CreatedFrom.IsZero():
+		createdRange = createdAtSortKeyAttr + " >= :createdFrom"
+	case !filter.CreatedTo.IsZero():
+		createdRange = createdAtSortKeyAttr + " <= :createdTo"
+	}
+	if !filter.CreatedFrom.IsZero() {
+		values[":createdFrom"] = &types.AttributeValueMemberS{Value: filter.CreatedFrom.UTC().Format(sortableTimeLayout)}
+	}
+	if !filter.CreatedTo.IsZero() {
+		values[":createdTo"] = &types.AttributeValueMemberS{Value: filter.CreatedTo.UTC().Format(sortableTimeLayout)}
+	}
+	if createdRange != "" {
+		if index.field == models.DocumentSortCreatedAt {
+			keyCondition += " AND " + createdRange
+		} else {
+			conditions = append(conditions, createdRange)
+		}
+	}
+
+	if filter.AuthenticationStatus != "" {
+		conditions = append(conditions, "AuthenticationStatus = :status")
+		values[":status"] = &types.AttributeValueMemberS{Value: string(filter.AuthenticationStatus)}
+	}
+	if filter.MimeFamily != "" {
+		conditions = append(conditions, "begins_with(MimeType, :mimeFamily)")
+		values[":mimeFamily"] = &types.AttributeValueMemberS{Value: filter.MimeFamilyPrefix()}
+	}
+	if filter.FilenameContains != "" {
+		conditions = append(conditions, "contains("+filenameSortKeyAttr+", :filename)")
+		values[":filename"] = &types.AttributeValueMemberS{Value: models.FilenameSortKey(filter.FilenameContains)}
+	}
+
+	queryInput.KeyConditionExpression = aws.String(keyCondition)
+	queryInput.FilterExpression = aws.String(strings.Join(conditions, " AND "))
+	return queryInput
+}
+
 // executeQuery executes a DynamoDB query
 func (repo *dynamoDBDocumentRepository) executeQuery(ctx context.Context, input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
 	result, err := repo.client.Query(ctx, input)
@@ -344,82 +595,103 @@ func (repo *dynamoDBDocumentRepository) processQueryResults(items []map[string]t
 	return documents, nil
 }
 
-// DeleteByID removes a document by its ID and returns the deleted document
-// Returns nil if the document doesn't exist
-func (repo *dynamoDBDocumentRepository) DeleteByID(ctx context.Context, id string) (*models.Document, error) {
-	document, err := repo.GetByID(ctx, id)
+// DeleteByID removes a document by its ID, in the trash or not, and returns the deleted document
+// The reference counter of the document's object and the usage of its owner are decremented in
+// the same transaction; documents whose reference was never counted leave the counter as is, and
+// documents whose usage was released when trashed leave the usage as is. Returns nil if the
+// document doesn't exist
+func (repo *dynamoDBDocumentRepository) DeleteByID(ctx context.Context, id string, expectedVersion int64, outbox interfaces.OutboxFunc) (*models.Document, error) {
+	item, err := repo.getItemByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	if document == nil {
+	if item == nil {
 		return nil, nil
 	}
+	var document models.Document
+	if err := attributevalue.UnmarshalMap(item, &document); err != nil {
+		return nil, fmt.Errorf(errUnmarshalDocument, err)
+	}
+	if expectedVersion != 0 && document.Version != expectedVersion {
+		return nil, interfaces.ErrVersionConflict
+	}
 
-	_, err = repo.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
-		TableName: aws.String(repo.tableName),
-		Key: map[string]types.AttributeValue{
-			"DocumentID": &types.AttributeValueMemberS{Value: document.ID},
-			"OwnerID":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", document.OwnerID)},
-		},
-	})
+	condition, values := versionCondition(document.Version)
+	messages, err := outboxPuts(repo.outboxTableName, outbox, &document)
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete document: %w", err)
+		return nil, err
 	}
 
-	return document, nil
-}
+	// The counted and released states are part of the condition, so a document counted or
+	// released by a backfill since it was read is not deleted without its reference or usage
+	counted := blobCounted(item)
+	if counted {
+		condition += " AND attribute_exists(" + blobCountedAttr + ")"
+	} else {
+		condition += " AND attribute_not_exists(" + blobCountedAttr + ")"
+	}
+	released := usageReleased(item)
+	condition += " AND " + usageReleasedCondition(released)
 
-// DeleteAllByOwnerID removes all documents owned by a specific user
-// Uses batch operations for efficiency (max 25 items per batch)
-func (repo *dynamoDBDocumentRepository) DeleteAllByOwnerID(ctx context.Context, ownerID int64) (int, error) {
-	documents, _, err := repo.List(ctx, ownerID, bulkQueryLimit, 0)
-	if err != nil {
-		return 0, fmt.Errorf("failed to list documents for deletion: %w", err)
+	// A released document still updates the usage, by nothing, keeping the positions of the items
+	var releasedDocuments, releasedBytes int64
+	if !released {
+		releasedDocuments, releasedBytes = 1, document.SizeBytes
 	}
 
-	if len(documents) == 0 {
-		return 0, nil
+	items := []types.TransactWriteItem{
+		usageRelease(repo.usageTableName, document.OwnerID, releasedDocuments, releasedBytes),
+		{
+			Delete: &types.Delete{
+				TableName:                 aws.String(repo.tableName),
+				Key:                       documentKey(&document),
+				ConditionExpression:       aws.String(condition),
+				ExpressionAttributeValues: values,
+			},
+		},
+		hashGuardDelete(repo.hashGuardsTableName, &document),
+	}
+	if counted {
+		items = append(items, blobRefUpdate(repo.blobRefsTableName, document.ObjectKey, -1, time.Now()))
 	}
 
-	deletedCount := 0
+	input := &dynamodb.TransactWriteItemsInput{TransactItems: append(items, messages...)}
 
-	for i := 0; i < len(documents); i += maxBatchDeleteSize {
-		end := i + maxBatchDeleteSize
-		if end > len(documents) {
-			end = len(documents)
-		}
-		batch := documents[i:end]
-
-		var writeRequests []types.WriteRequest
-		for _, doc := range batch {
-			writeRequests = append(writeRequests, types.WriteRequest{
-				DeleteRequest: &types.DeleteRequest{
-					Key: map[string]types.AttributeValue{
-						"DocumentID": &types.AttributeValueMemberS{Value: doc.ID},
-						"OwnerID":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", doc.OwnerID)},
-					},
-				},
-			})
+	if err = repo.writeReleasingUsage(ctx, document.OwnerID, input); err != nil {
+		// Only the condition of the document item is a conflict; the usage failing again after
+		// being initialized is not
+		if transactionConditionFailed(err, 1) {
+			// Deleted concurrently by another request, or changed since it was read
+			if current, getErr := repo.GetByID(ctx, id); getErr == nil && current == nil {
+				return nil, nil
+			}
+			return nil, interfaces.ErrVersionConflict
 		}
+		return nil, fmt.Errorf("failed to delete document: %w", err)
+	}
 
-		_, err := repo.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
-			RequestItems: map[string][]types.WriteRequest{
-				repo.tableName: writeRequests,
-			},
-		})
-		if err != nil {
-			return deletedCount, fmt.Errorf("failed to batch delete documents: %w", err)
-		}
+	return &document, nil
+}
 
-		deletedCount += len(batch)
+// writeReleasingUsage writes a transaction whose first item decrements the usage of an owner,
+// initializing the usage and writing it again once if it was missing. The usage may have been
+// initialized concurrently instead, which writes it again all the same
+func (repo *dynamoDBDocumentRepository) writeReleasingUsage(ctx context.Context, ownerID int64, input *dynamodb.TransactWriteItemsInput) error {
+	_, err := repo.client.TransactWriteItems(ctx, input)
+	if err == nil || !transactionConditionFailed(err, 0) {
+		return err
 	}
-
-	return deletedCount, nil
+	if _, err := repo.GetUsage(ctx, ownerID); err != nil {
+		return err
+	}
+	_, err = repo.client.TransactWriteItems(ctx, input)
+	return err
 }
 
-// UpdateAuthenticationStatus updates the authentication status of a document and its updated timestamp
-func (repo *dynamoDBDocumentRepository) UpdateAuthenticationStatus(ctx context.Context, documentID string, status models.AuthenticationStatus) error {
+// UpdateAuthenticationStatus updates the authentication status of a document, its updated
+// timestamp and its version, putting the outbox messages in the same transaction
+func (repo *dynamoDBDocumentRepository) UpdateAuthenticationStatus(ctx context.Context, documentID string, expectedVersion int64, status models.AuthenticationStatus, outbox interfaces.OutboxFunc) error {
 	now := time.Now()
 
 	document, err := repo.GetByID(ctx, documentID)
@@ -428,24 +700,144 @@ func (repo *dynamoDBDocumentRepository) UpdateAuthenticationStatus(ctx context.C
 	}
 
 	if document == nil {
-		return fmt.Errorf("document not found")
+		return interfaces.ErrDocumentNotFound
+	}
+	if expectedVersion != 0 && document.Version != expectedVersion {
+		return interfaces.ErrVersionConflict
 	}
 
-	_, err = repo.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: aws.String(repo.tableName),
-		Key: map[string]types.AttributeValue{
-			"DocumentID": &types.AttributeValueMemberS{Value: documentID},
-			"OwnerID":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", document.OwnerID)},
-		},
-		UpdateExpression: aws.String("SET AuthenticationStatus = :status, UpdatedAt = :updated"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":status":  &types.AttributeValueMemberS{Value: string(status)},
-			":updated": &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)},
-		},
+	condition, values := versionCondition(document.Version)
+	document.AuthenticationStatus = status
+	document.UpdatedAt = now
+	document.Version++
+	messages, err := outboxPuts(repo.outboxTableName, outbox, document)
+	if err != nil {
+		return err
+	}
+
+	if values == nil {
+		values = make(map[string]types.AttributeValue, 3)
+	}
+	values[":status"] = &types.AttributeValueMemberS{Value: string(status)}
+	values[":updated"] = &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)}
+	values[":nextVersion"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", document.Version)}
+
+	_, err = repo.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: append([]types.TransactWriteItem{
+			{
+				Update: &types.Update{
+					TableName:                 aws.String(repo.tableName),
+					Key:                       documentKey(document),
+					UpdateExpression:          aws.String("SET AuthenticationStatus = :status, UpdatedAt = :updated, Version = :nextVersion"),
+					ConditionExpression:       aws.String(condition),
+					ExpressionAttributeValues: values,
+				},
+			},
+		}, messages...),
 	})
 	if err != nil {
+		if isConditionalCheckFailure(err) {
+			return interfaces.ErrVersionConflict
+		}
 		return fmt.Errorf("failed to update authentication status: %w", err)
 	}
 
 	return nil
 }
+
+// versionCondition returns the condition expression, with its values, checking that a document
+// item is still at the version it was read at. Items written before versioning have no Version
+// attribute and read as version 0
+func versionCondition(version int64) (string, map[string]types.AttributeValue) {
+	if version == 0 {
+		return documentExistsCondition + " AND attribute_not_exists(Version)", nil
+	}
+	return "Version = :version", map[string]types.AttributeValue{
+		":version": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", version)},
+	}
+}
+
+// documentKey returns the primary key of a document item
+func documentKey(document *models.Document) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"DocumentID": &types.AttributeValueMemberS{Value: document.ID},
+		"OwnerID":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", document.OwnerID)},
+	}
+}
+
+// startKeyValue is the JSON form of a key attribute of a LastEvaluatedKey; key attributes are
+// always strings or numbers
+type startKeyValue struct {
+	S *string `json:"S,omitempty"`
+	N *string `json:"N,omitempty"`
+}
+
+// encodeStartKey serializes a LastEvaluatedKey into a list position
+func encodeStartKey(key map[string]types.AttributeValue) (string, error) {
+	values := make(map[string]startKeyValue, len(key))
+	for name, value := range key {
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			values[name] = startKeyValue{S: aws.String(v.Value)}
+		case *types.AttributeValueMemberN:
+			values[name] = startKeyValue{N: aws.String(v.Value)}
+		default:
+			return "", fmt.Errorf("unsupported key attribute %s of type %T", name, value)
+		}
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode list position: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeStartKey parses a list position back into an ExclusiveStartKey
+func decodeStartKey(position string) (map[string]types.AttributeValue, error) {
+	var values map[string]startKeyValue
+	if err := json.Unmarshal([]byte(position), &values); err != nil {
+		return nil, fmt.Errorf("malformed list position: %w", err)
+	}
+
+	key := make(map[string]types.AttributeValue, len(values))
+	for name, value := range values {
+		switch {
+		case value.S != nil:
+			key[name] = &types.AttributeValueMemberS{Value: *value.S}
+		case value.N != nil:
+			key[name] = &types.AttributeValueMemberN{Value: *value.N}
+		default:
+			return nil, fmt.Errorf("malformed list position: attribute %s has no value", name)
+		}
+	}
+	return key, nil
+}
+
+// transactionConditionFailed reports whether a transaction was canceled because the condition of
+// its item at position failed
+func transactionConditionFailed(err error, position int) bool {
+	var canceledErr *types.TransactionCanceledException
+	if !errors.As(err, &canceledErr) || position >= len(canceledErr.CancellationReasons) {
+		return false
+	}
+	return aws.ToString(canceledErr.CancellationReasons[position].Code) == "ConditionalCheckFailed"
+}
+
+// isConditionalCheckFailure reports whether a write was rejected by its condition expression,
+// either directly or as the cancellation reason of a transaction
+func isConditionalCheckFailure(err error) bool {
+	var conditionErr *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return true
+	}
+	var canceledErr *types.TransactionCanceledException
+	if errors.As(err, &canceledErr) {
+		for _, reason := range canceledErr.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return true
+			}
+		}
+	}
+	return false
+}
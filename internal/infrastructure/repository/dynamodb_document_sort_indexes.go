@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+const (
+	// Attributes holding the sort keys of the sort indexes. CreatedAt is stored with a variable
+	// number of decimals, which does not sort as text, and filenames are sorted ignoring case
+	createdAtSortKeyAttr = "CreatedAtSortKey"
+	filenameSortKeyAttr  = "FilenameSortKey"
+
+	// sortableTimeLayout formats UTC timestamps with a fixed width, so that they sort as text
+	sortableTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+	// indexPollInterval is how often the creation or deletion of an index is checked
+	indexPollInterval = 5 * time.Second
+)
+
+// documentSortIndex is a GSI listing the documents of an owner ordered by one attribute
+type documentSortIndex struct {
+	name          string
+	field         models.DocumentSortField
+	attribute     string
+	attributeType types.ScalarAttributeType
+}
+
+// documentSortIndexes are the indexes ListPage queries, one per sort field
+var documentSortIndexes = []documentSortIndex{
+	{name: "OwnerCreatedAtIndex", field: models.DocumentSortCreatedAt, attribute: createdAtSortKeyAttr, attributeType: types.ScalarAttributeTypeS},
+	{name: "OwnerFilenameIndex", field: models.DocumentSortFilename, attribute: filenameSortKeyAttr, attributeType: types.ScalarAttributeTypeS},
+	{name: "OwnerSizeIndex", field: models.DocumentSortSize, attribute: "SizeBytes", attributeType: types.ScalarAttributeTypeN},
+}
+
+// sortIndexFor returns the index ordering documents by field, by creation date if the field is unknown
+func sortIndexFor(field models.DocumentSortField) documentSortIndex {
+	for _, index := range documentSortIndexes {
+		if index.field == field {
+			return index
+		}
+	}
+	return documentSortIndexes[0]
+}
+
+// definition returns the GSI declaration of the index
+func (index documentSortIndex) definition() types.GlobalSecondaryIndex {
+	return types.GlobalSecondaryIndex{
+		IndexName: aws.String(index.name),
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("OwnerID"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String(index.attribute),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		Projection: &types.Projection{
+			ProjectionType: types.ProjectionTypeAll,
+		},
+	}
+}
+
+// startKey returns the key resuming a query of the index after an item
+func (index documentSortIndex) startKey(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"DocumentID":    item["DocumentID"],
+		"OwnerID":       item["OwnerID"],
+		index.attribute: item[index.attribute],
+	}
+}
+
+// attributeDefinition returns the declaration of the sort key attribute of the index
+func (index documentSortIndex) attributeDefinition() types.AttributeDefinition {
+	return types.AttributeDefinition{
+		AttributeName: aws.String(index.attribute),
+		AttributeType: index.attributeType,
+	}
+}
+
+// migrationIndex returns the index as added by a migration
+func (index documentSortIndex) migrationIndex() DynamoDBIndex {
+	return DynamoDBIndex{
+		Definition: index.definition(),
+		Attributes: []types.AttributeDefinition{index.attributeDefinition()},
+	}
+}
+
+// documentSortKeys returns the sort key attributes stored with a document
+func documentSortKeys(document *models.Document) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		createdAtSortKeyAttr: &types.AttributeValueMemberS{Value: document.CreatedAt.UTC().Format(sortableTimeLayout)},
+		filenameSortKeyAttr:  &types.AttributeValueMemberS{Value: models.FilenameSortKey(document.Filename)},
+	}
+}
+
+// setSortKeys stores the sort keys of an existing document
+func setSortKeys(ctx context.Context, client *dynamodb.Client, tableName string, document *models.Document) error {
+	keys := documentSortKeys(document)
+	_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName),
+		Key:                 documentKey(document),
+		UpdateExpression:    aws.String("SET " + createdAtSortKeyAttr + " = :createdAt, " + filenameSortKeyAttr + " = :filename"),
+		ConditionExpression: aws.String(documentExistsCondition),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":createdAt": keys[createdAtSortKeyAttr],
+			":filename":  keys[filenameSortKeyAttr],
+		},
+	})
+	return err
+}
@@ -0,0 +1,357 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// Trashed documents keep their item, with DeletedAt and PurgeAt set, and every other table but the
+// usage unchanged until they are purged. They also hold the keys of the purge index, a sparse GSI
+// listing every trashed document by the time it is due to be purged, so the purge never scans the
+// table. The index has a single partition; it only holds trashed documents and is read by the
+// purge alone
+
+const (
+	// purgeIndexName is the GSI listing trashed documents by purge time
+	purgeIndexName = "PurgeIndex"
+
+	// Attributes holding the keys of the purge index, set only on trashed documents
+	purgePartitionAttr  = "PurgePartition"
+	purgeAtSortKeyAttr  = "PurgeAtSortKey"
+	purgePartitionValue = "trash"
+
+	// restoreUsagePosition is the index of the usage update in the transaction of Restore
+	restoreUsagePosition = 1
+
+	// maxTrashBatchAttempts is how many times a batch of TrashAllByOwnerID is read and written
+	// before a document changing in between fails it
+	maxTrashBatchAttempts = 3
+
+	// Filter conditions selecting the documents in the trash or outside of it
+	trashedCondition    = "attribute_exists(DeletedAt)"
+	notTrashedCondition = "attribute_not_exists(DeletedAt)"
+)
+
+// purgeIndex is the purge index as added by a migration
+var purgeIndex = DynamoDBIndex{
+	Definition: types.GlobalSecondaryIndex{
+		IndexName: aws.String(purgeIndexName),
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String(purgePartitionAttr),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String(purgeAtSortKeyAttr),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		Projection: &types.Projection{
+			ProjectionType: types.ProjectionTypeAll,
+		},
+	},
+	Attributes: []types.AttributeDefinition{
+		{
+			AttributeName: aws.String(purgePartitionAttr),
+			AttributeType: types.ScalarAttributeTypeS,
+		},
+		{
+			AttributeName: aws.String(purgeAtSortKeyAttr),
+			AttributeType: types.ScalarAttributeTypeS,
+		},
+	},
+}
+
+// Trash moves a document to the trash until purgeAt, updating its timestamp and its version and
+// releasing the usage of its owner, putting the outbox messages in the same transaction. Returns
+// nil if the document doesn't exist or is in the trash already
+func (repo *dynamoDBDocumentRepository) Trash(ctx context.Context, id string, expectedVersion int64, purgeAt time.Time, outbox interfaces.OutboxFunc) (*models.Document, error) {
+	document, err := repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if document == nil || document.IsTrashed() {
+		return nil, nil
+	}
+	if expectedVersion != 0 && document.Version != expectedVersion {
+		return nil, interfaces.ErrVersionConflict
+	}
+
+	items := []types.TransactWriteItem{
+		usageRelease(repo.usageTableName, document.OwnerID, 1, document.SizeBytes),
+		repo.trashUpdate(document, time.Now(), purgeAt),
+	}
+	messages, err := outboxPuts(repo.outboxTableName, outbox, document)
+	if err != nil {
+		return nil, err
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{TransactItems: append(items, messages...)}
+	if err = repo.writeReleasingUsage(ctx, document.OwnerID, input); err != nil {
+		if transactionConditionFailed(err, 1) {
+			// Deleted or trashed concurrently by another request, or changed since it was read
+			if current, getErr := repo.GetByID(ctx, id); getErr == nil && (current == nil || current.IsTrashed()) {
+				return nil, nil
+			}
+			return nil, interfaces.ErrVersionConflict
+		}
+		return nil, fmt.Errorf("failed to move document to the trash: %w", err)
+	}
+
+	return document, nil
+}
+
+// TrashAllByOwnerID moves every document of an owner outside the trash to the trash until purgeAt
+// It walks the owner's documents page by page and updates them in transactions of up to 25
+// documents; the outbox is called once per transaction with the documents it moves. Each batch is
+// read again consistently before its update, since the list indexes are eventually consistent, and
+// retried a few times when a document changes in between. A batch still conflicting fails with
+// ErrVersionConflict, the batches before it staying applied
+func (repo *dynamoDBDocumentRepository) TrashAllByOwnerID(ctx context.Context, ownerID int64, purgeAt time.Time, outbox interfaces.OutboxFunc) (int, error) {
+	sort := models.DocumentSort{}.WithDefaults()
+	now := time.Now()
+	trashedCount := 0
+	position := ""
+
+	for {
+		listed, next, err := repo.ListPage(ctx, ownerID, sort, models.DocumentFilter{}, maxBatchDeleteSize, position)
+		if err != nil {
+			return trashedCount, fmt.Errorf("failed to list documents for deletion: %w", err)
+		}
+
+		trashed, err := repo.trashBatch(ctx, listed, now, purgeAt, outbox)
+		trashedCount += trashed
+		if err != nil {
+			return trashedCount, err
+		}
+
+		if next == "" {
+			return trashedCount, nil
+		}
+		position = next
+	}
+}
+
+// trashBatch moves the listed documents still outside the trash to it in one transaction releasing
+// their usage, reading them consistently first and again after a version conflict. Returns how
+// many it moved
+func (repo *dynamoDBDocumentRepository) trashBatch(ctx context.Context, listed []*models.Document, now, purgeAt time.Time, outbox interfaces.OutboxFunc) (int, error) {
+	for attempt := 1; ; attempt++ {
+		batch := make([]*models.Document, 0, len(listed))
+		for _, doc := range listed {
+			current, err := repo.GetByID(ctx, doc.ID)
+			if err != nil {
+				return 0, err
+			}
+			if current != nil && !current.IsTrashed() {
+				batch = append(batch, current)
+			}
+		}
+		if len(batch) == 0 {
+			return 0, nil
+		}
+
+		var bytes int64
+		items := make([]types.TransactWriteItem, 1, len(batch)+2)
+		for _, doc := range batch {
+			bytes += doc.SizeBytes
+			items = append(items, repo.trashUpdate(doc, now, purgeAt))
+		}
+		items[0] = usageRelease(repo.usageTableName, batch[0].OwnerID, int64(len(batch)), bytes)
+		messages, err := outboxPuts(repo.outboxTableName, outbox, batch...)
+		if err != nil {
+			return 0, err
+		}
+
+		input := &dynamodb.TransactWriteItemsInput{TransactItems: append(items, messages...)}
+		err = repo.writeReleasingUsage(ctx, batch[0].OwnerID, input)
+		if err == nil {
+			return len(batch), nil
+		}
+		if !isConditionalCheckFailure(err) || transactionConditionFailed(err, 0) {
+			return 0, fmt.Errorf("failed to move documents to the trash: %w", err)
+		}
+		if attempt == maxTrashBatchAttempts {
+			return 0, fmt.Errorf("failed to move documents to the trash: %w", interfaces.ErrVersionConflict)
+		}
+	}
+}
+
+// trashUpdate marks document as moved to the trash at now until purgeAt and builds the
+// transactional update doing it and marking its usage released, conditioned on the version it was
+// read at
+func (repo *dynamoDBDocumentRepository) trashUpdate(document *models.Document, now, purgeAt time.Time) types.TransactWriteItem {
+	condition, values := versionCondition(document.Version)
+	document.DeletedAt = &now
+	document.PurgeAt = &purgeAt
+	document.UpdatedAt = now
+	document.Version++
+
+	if values == nil {
+		values = make(map[string]types.AttributeValue, 6)
+	}
+	values[":deleted"] = &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)}
+	values[":purgeAt"] = &types.AttributeValueMemberS{Value: purgeAt.Format(time.RFC3339Nano)}
+	values[":partition"] = &types.AttributeValueMemberS{Value: purgePartitionValue}
+	values[":purgeKey"] = &types.AttributeValueMemberS{Value: purgeAt.UTC().Format(sortableTimeLayout)}
+	values[":updated"] = &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)}
+	values[":nextVersion"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", document.Version)}
+	values[":released"] = &types.AttributeValueMemberBOOL{Value: true}
+
+	return types.TransactWriteItem{
+		Update: &types.Update{
+			TableName: aws.String(repo.tableName),
+			Key:       documentKey(document),
+			UpdateExpression: aws.String("SET DeletedAt = :deleted, PurgeAt = :purgeAt, " + purgePartitionAttr + " = :partition, " +
+				purgeAtSortKeyAttr + " = :purgeKey, UpdatedAt = :updated, Version = :nextVersion, " + usageReleasedAttr + " = :released"),
+			ConditionExpression:       aws.String(condition + " AND " + notTrashedCondition + " AND " + usageReleasedCondition(false)),
+			ExpressionAttributeValues: values,
+		},
+	}
+}
+
+// Restore takes a document out of the trash, updating its timestamp and its version and putting
+// the outbox messages in the same transaction. A document whose usage was released is added back
+// to the usage of its owner in the transaction too, only if quota allows it, failing with
+// ErrQuotaExceeded otherwise. Returns nil if the document doesn't exist or is not in the trash
+func (repo *dynamoDBDocumentRepository) Restore(ctx context.Context, id string, expectedVersion int64, quota models.Quota, outbox interfaces.OutboxFunc) (*models.Document, error) {
+	now := time.Now()
+
+	item, err := repo.getItemByID(ctx, id)
+	if err != nil || item == nil {
+		return nil, err
+	}
+	var document models.Document
+	if err := attributevalue.UnmarshalMap(item, &document); err != nil {
+		return nil, fmt.Errorf(errUnmarshalDocument, err)
+	}
+
+	if !document.IsTrashed() {
+		return nil, nil
+	}
+	if expectedVersion != 0 && document.Version != expectedVersion {
+		return nil, interfaces.ErrVersionConflict
+	}
+	released := usageReleased(item)
+	if released && !quota.Allows(models.OwnerUsage{OwnerID: document.OwnerID}, document.SizeBytes) {
+		return nil, interfaces.ErrQuotaExceeded
+	}
+
+	condition, values := versionCondition(document.Version)
+	document.DeletedAt = nil
+	document.PurgeAt = nil
+	document.UpdatedAt = now
+	document.Version++
+	messages, err := outboxPuts(repo.outboxTableName, outbox, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	if values == nil {
+		values = make(map[string]types.AttributeValue, 2)
+	}
+	values[":updated"] = &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)}
+	values[":nextVersion"] = &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", document.Version)}
+
+	items := []types.TransactWriteItem{
+		{
+			Update: &types.Update{
+				TableName: aws.String(repo.tableName),
+				Key:       documentKey(&document),
+				UpdateExpression: aws.String("SET UpdatedAt = :updated, Version = :nextVersion REMOVE DeletedAt, PurgeAt, " +
+					purgePartitionAttr + ", " + purgeAtSortKeyAttr + ", " + usageReleasedAttr),
+				ConditionExpression:       aws.String(condition + " AND " + trashedCondition + " AND " + usageReleasedCondition(released)),
+				ExpressionAttributeValues: values,
+			},
+		},
+	}
+	if released {
+		items = append(items, usageReserve(repo.usageTableName, &document, quota))
+	}
+	input := &dynamodb.TransactWriteItemsInput{TransactItems: append(items, messages...)}
+
+	// Written again once if the usage of the owner was missing and got initialized
+	for attempt := 0; ; attempt++ {
+		_, err = repo.client.TransactWriteItems(ctx, input)
+		if err == nil {
+			return &document, nil
+		}
+		if transactionConditionFailed(err, 0) {
+			// Purged or restored concurrently, or changed since it was read
+			if current, getErr := repo.GetByID(ctx, id); getErr == nil && (current == nil || !current.IsTrashed()) {
+				return nil, nil
+			}
+			return nil, interfaces.ErrVersionConflict
+		}
+		if !released || !transactionConditionFailed(err, restoreUsagePosition) {
+			return nil, fmt.Errorf("failed to restore document: %w", err)
+		}
+
+		usage, err := repo.loadUsage(ctx, document.OwnerID)
+		if err != nil {
+			return nil, err
+		}
+		if usage != nil || attempt > 0 {
+			return nil, interfaces.ErrQuotaExceeded
+		}
+		if _, err := repo.initializeUsage(ctx, document.OwnerID); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ListExpired retrieves up to limit trashed documents due to be purged before the given time from
+// the purge index, those due first coming first, starting at the key position holds. The index is
+// read eventually consistently, so a document restored just before may still be listed
+func (repo *dynamoDBDocumentRepository) ListExpired(ctx context.Context, before time.Time, limit int, position string) ([]*models.Document, string, error) {
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(repo.tableName),
+		IndexName:              aws.String(purgeIndexName),
+		KeyConditionExpression: aws.String(purgePartitionAttr + " = :partition AND " + purgeAtSortKeyAttr + " < :before"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":partition": &types.AttributeValueMemberS{Value: purgePartitionValue},
+			":before":    &types.AttributeValueMemberS{Value: before.UTC().Format(sortableTimeLayout)},
+		},
+		Limit: aws.Int32(int32(limit)),
+	}
+	if position != "" {
+		startKey, err := decodeStartKey(position)
+		if err != nil {
+			return nil, "", err
+		}
+		queryInput.ExclusiveStartKey = startKey
+	}
+
+	result, err := repo.client.Query(ctx, queryInput)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list expired documents: %w", err)
+	}
+
+	documents := make([]*models.Document, 0, len(result.Items))
+	for _, item := range result.Items {
+		var document models.Document
+		if err := attributevalue.UnmarshalMap(item, &document); err != nil {
+			return nil, "", fmt.Errorf(errUnmarshalDocument, err)
+		}
+		documents = append(documents, &document)
+	}
+
+	if result.LastEvaluatedKey == nil {
+		return documents, "", nil
+	}
+	next, err := encodeStartKey(result.LastEvaluatedKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return documents, next, nil
+}
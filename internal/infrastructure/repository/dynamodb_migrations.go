@@ -0,0 +1,549 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+// The migrations applied to a DynamoDB table are recorded in a metadata item of the table itself,
+// keyed by an ID no document has and owner 0, which no document belongs to. The item also holds
+// the lease of the runner applying them and the progress of the backfill in progress, so a runner
+// taking over after a failure resumes the scan where it stopped
+
+const (
+	// migrationsItemID is the DocumentID of the metadata item
+	migrationsItemID = "#migrations"
+
+	// migrationLease is how long a runner holds the migrations without renewing its lease
+	migrationLease = 5 * time.Minute
+
+	// backfillSegments is the number of segments backfills scan in parallel. Saved progress is
+	// specific to a number of segments, so it must not change while a backfill is in progress
+	backfillSegments = 4
+
+	// backfillPageSize is the number of items a backfill scans between saves of its progress
+	backfillPageSize = 100
+
+	// backfillSegmentDone marks a segment scanned to the end
+	backfillSegmentDone = "done"
+)
+
+// ErrMigrationsLocked is returned by Migrate when another runner holds the migrations of the table
+var ErrMigrationsLocked = errors.New("migrations are being applied by another runner")
+
+// DynamoDBIndex is a GSI added by a migration, with the attributes its keys use
+type DynamoDBIndex struct {
+	Definition types.GlobalSecondaryIndex
+	Attributes []types.AttributeDefinition
+}
+
+// DynamoDBBackfill sets attributes of the existing items of a table. The items are scanned in
+// parallel segments and the scan resumes from the last saved page when interrupted, so Update is
+// called at least once for each item and must be idempotent
+type DynamoDBBackfill struct {
+	// FilterExpression selects the items to update; the metadata item is always left out
+	FilterExpression          string
+	ProjectionExpression      string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]types.AttributeValue
+
+	// Update writes the attributes of one item, reporting whether it changed anything
+	Update func(ctx context.Context, item map[string]types.AttributeValue) (bool, error)
+}
+
+// DynamoDBMigration is one version of the schema of a table. Its steps run in order: the indexes
+// are removed, then added one at a time, then the items are backfilled. Every step must be safe to
+// run again, since a migration interrupted midway is applied again from its start
+type DynamoDBMigration struct {
+	// Version identifies the migration; migrations are applied in the order of their versions
+	Version string
+
+	AddIndexes    []DynamoDBIndex
+	RemoveIndexes []string
+	Backfill      *DynamoDBBackfill
+}
+
+// DynamoDBMigrator applies versioned migrations to a DynamoDB table
+type DynamoDBMigrator struct {
+	client     *dynamodb.Client
+	tableName  string
+	migrations []DynamoDBMigration
+
+	// owner identifies the runner in the lease of the metadata item
+	owner string
+}
+
+// NewDynamoDBMigrator creates a runner applying migrations to a table
+func NewDynamoDBMigrator(client *dynamodb.Client, tableName string, migrations []DynamoDBMigration) *DynamoDBMigrator {
+	sorted := append([]DynamoDBMigration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	return &DynamoDBMigrator{
+		client:     client,
+		tableName:  tableName,
+		migrations: sorted,
+		owner:      uuid.New().String(),
+	}
+}
+
+// migrationsState is the content of the metadata item
+type migrationsState struct {
+	applied         map[string]bool
+	backfillVersion string
+	backfillKeys    map[int]map[string]types.AttributeValue
+	backfillDone    map[int]bool
+}
+
+// Migrate applies the migrations the table lacks and returns their versions. It fails with
+// ErrMigrationsLocked while another runner holds a live lease on the migrations of the table
+func (m *DynamoDBMigrator) Migrate(ctx context.Context) ([]string, error) {
+	state, err := m.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer m.release()
+
+	var applied []string
+	for _, migration := range m.migrations {
+		if state.applied[migration.Version] {
+			continue
+		}
+
+		log.Printf("applying migration %s to table %s", migration.Version, m.tableName)
+		if err := m.apply(ctx, migration, state); err != nil {
+			return applied, fmt.Errorf("failed to apply migration %s: %w", migration.Version, err)
+		}
+		if err := m.record(ctx, migration.Version); err != nil {
+			return applied, err
+		}
+		applied = append(applied, migration.Version)
+	}
+	return applied, nil
+}
+
+// Applied returns the versions of the migrations recorded as applied to the table
+func (m *DynamoDBMigrator) Applied(ctx context.Context) ([]string, error) {
+	result, err := m.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(m.tableName),
+		Key:            migrationsKey(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migrations of table %s: %w", m.tableName, err)
+	}
+
+	var versions []string
+	for version := range parseMigrationsState(result.Item).applied {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// Pending returns the versions of the migrations not yet recorded as applied to the table, in
+// the order they are applied
+func (m *DynamoDBMigrator) Pending(ctx context.Context) ([]string, error) {
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]bool, len(applied))
+	for _, version := range applied {
+		done[version] = true
+	}
+	var pending []string
+	for _, migration := range m.migrations {
+		if !done[migration.Version] {
+			pending = append(pending, migration.Version)
+		}
+	}
+	return pending, nil
+}
+
+// apply runs the steps of a migration
+func (m *DynamoDBMigrator) apply(ctx context.Context, migration DynamoDBMigration, state *migrationsState) error {
+	for _, name := range migration.RemoveIndexes {
+		if err := m.removeIndex(ctx, name); err != nil {
+			return err
+		}
+	}
+	for _, index := range migration.AddIndexes {
+		if err := m.addIndex(ctx, index); err != nil {
+			return err
+		}
+	}
+	if migration.Backfill != nil {
+		return m.backfill(ctx, migration.Version, migration.Backfill, state)
+	}
+	return nil
+}
+
+// acquire takes the lease on the migrations of the table, creating the metadata item if needed,
+// and returns the state it holds
+func (m *DynamoDBMigrator) acquire(ctx context.Context) (*migrationsState, error) {
+	now := time.Now()
+	result, err := m.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(m.tableName),
+		Key:                 migrationsKey(),
+		UpdateExpression:    aws.String("SET LockOwner = :owner, LockUntil = :until, Applied = if_not_exists(Applied, :empty)"),
+		ConditionExpression: aws.String("attribute_not_exists(LockUntil) OR LockUntil < :now OR LockOwner = :owner"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner": &types.AttributeValueMemberS{Value: m.owner},
+			":until": millisAttribute(now.Add(migrationLease)),
+			":now":   millisAttribute(now),
+			":empty": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	if err != nil {
+		if isConditionalCheckFailure(err) {
+			return nil, ErrMigrationsLocked
+		}
+		return nil, fmt.Errorf("failed to lock migrations of table %s: %w", m.tableName, err)
+	}
+	return parseMigrationsState(result.Attributes), nil
+}
+
+// renew extends the lease of the runner, failing if it was lost
+func (m *DynamoDBMigrator) renew(ctx context.Context) error {
+	return m.updateLocked(ctx, "SET LockUntil = :until", nil, nil)
+}
+
+// release gives up the lease. It is best effort: a lease that is not released expires
+func (m *DynamoDBMigrator) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := m.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(m.tableName),
+		Key:                 migrationsKey(),
+		UpdateExpression:    aws.String("REMOVE LockOwner, LockUntil"),
+		ConditionExpression: aws.String("LockOwner = :owner"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner": &types.AttributeValueMemberS{Value: m.owner},
+		},
+	})
+	if err != nil {
+		log.Printf("warning: failed to release migrations of table %s: %v", m.tableName, err)
+	}
+}
+
+// record marks a migration as applied and clears the progress of its backfill
+func (m *DynamoDBMigrator) record(ctx context.Context, version string) error {
+	err := m.updateLocked(ctx,
+		"SET Applied.#version = :appliedAt, LockUntil = :until REMOVE BackfillVersion, BackfillSegments",
+		map[string]string{"#version": version},
+		map[string]types.AttributeValue{":appliedAt": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", version, err)
+	}
+	return nil
+}
+
+// updateLocked updates the metadata item and renews the lease, provided the runner still holds it
+func (m *DynamoDBMigrator) updateLocked(ctx context.Context, expression string, names map[string]string, values map[string]types.AttributeValue) error {
+	attributeValues := map[string]types.AttributeValue{
+		":owner": &types.AttributeValueMemberS{Value: m.owner},
+		":until": millisAttribute(time.Now().Add(migrationLease)),
+	}
+	for name, value := range values {
+		attributeValues[name] = value
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(m.tableName),
+		Key:                       migrationsKey(),
+		UpdateExpression:          aws.String(expression),
+		ConditionExpression:       aws.String("LockOwner = :owner"),
+		ExpressionAttributeValues: attributeValues,
+	}
+	if len(names) > 0 {
+		input.ExpressionAttributeNames = names
+	}
+
+	if _, err := m.client.UpdateItem(ctx, input); err != nil {
+		if isConditionalCheckFailure(err) {
+			return fmt.Errorf("lease on the migrations of table %s was lost", m.tableName)
+		}
+		return err
+	}
+	return nil
+}
+
+// addIndex creates an index if the table lacks it and waits until it is active. A table accepts a
+// single index creation at a time, so the indexes are created one after the other
+func (m *DynamoDBMigrator) addIndex(ctx context.Context, index DynamoDBIndex) error {
+	name := aws.ToString(index.Definition.IndexName)
+	status, err := indexStatus(ctx, m.client, m.tableName, name)
+	if err != nil {
+		return err
+	}
+
+	if status == "" {
+		log.Printf("creating index %s on table %s", name, m.tableName)
+		_, err := m.client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+			TableName:            aws.String(m.tableName),
+			AttributeDefinitions: index.Attributes,
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+				{
+					Create: &types.CreateGlobalSecondaryIndexAction{
+						IndexName:  index.Definition.IndexName,
+						KeySchema:  index.Definition.KeySchema,
+						Projection: index.Definition.Projection,
+					},
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create index %s: %w", name, err)
+		}
+	}
+
+	return m.waitForIndex(ctx, name, func(status types.IndexStatus) bool {
+		return status == types.IndexStatusActive
+	})
+}
+
+// removeIndex deletes an index if the table has it and waits until it is gone
+func (m *DynamoDBMigrator) removeIndex(ctx context.Context, name string) error {
+	status, err := indexStatus(ctx, m.client, m.tableName, name)
+	if err != nil {
+		return err
+	}
+
+	if status == types.IndexStatusActive {
+		log.Printf("deleting index %s from table %s", name, m.tableName)
+		_, err := m.client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+			TableName: aws.String(m.tableName),
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+				{
+					Delete: &types.DeleteGlobalSecondaryIndexAction{IndexName: aws.String(name)},
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete index %s: %w", name, err)
+		}
+	}
+
+	return m.waitForIndex(ctx, name, func(status types.IndexStatus) bool {
+		return status == ""
+	})
+}
+
+// waitForIndex polls the status of an index until ready accepts it, renewing the lease meanwhile
+func (m *DynamoDBMigrator) waitForIndex(ctx context.Context, name string, ready func(types.IndexStatus) bool) error {
+	for {
+		status, err := indexStatus(ctx, m.client, m.tableName, name)
+		if err != nil {
+			return err
+		}
+		if ready(status) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("index %s is still %s: %w", name, status, ctx.Err())
+		case <-time.After(indexPollInterval):
+		}
+		if err := m.renew(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// indexStatus returns the status of a GSI of the table, or "" if the table has no such index
+func indexStatus(ctx context.Context, client *dynamodb.Client, tableName, indexName string) (types.IndexStatus, error) {
+	table, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe table %s: %w", tableName, err)
+	}
+
+	for _, index := range table.Table.GlobalSecondaryIndexes {
+		if aws.ToString(index.IndexName) == indexName {
+			return index.IndexStatus, nil
+		}
+	}
+	return "", nil
+}
+
+// backfill scans the segments of the table in parallel, resuming each one from the progress
+// saved by a previous runner of the same migration
+func (m *DynamoDBMigrator) backfill(ctx context.Context, version string, backfill *DynamoDBBackfill, state *migrationsState) error {
+	if state.backfillVersion != version {
+		err := m.updateLocked(ctx, "SET BackfillVersion = :version, BackfillSegments = :empty", nil, map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberS{Value: version},
+			":empty":   &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to start backfill: %w", err)
+		}
+		state.backfillKeys = map[int]map[string]types.AttributeValue{}
+		state.backfillDone = map[int]bool{}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		updated  int
+	)
+	for segment := 0; segment < backfillSegments; segment++ {
+		if state.backfillDone[segment] {
+			continue
+		}
+
+		wg.Add(1)
+		go func(segment int, startKey map[string]types.AttributeValue) {
+			defer wg.Done()
+			count, err := m.backfillSegment(ctx, backfill, segment, startKey)
+
+			mu.Lock()
+			defer mu.Unlock()
+			updated += count
+			if err != nil && firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+		}(segment, state.backfillKeys[segment])
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	log.Printf("backfill of migration %s updated %d items", version, updated)
+	return nil
+}
+
+// backfillSegment scans one segment of the table from startKey, saving its progress after each page
+func (m *DynamoDBMigrator) backfillSegment(ctx context.Context, backfill *DynamoDBBackfill, segment int, startKey map[string]types.AttributeValue) (int, error) {
+	filter := "DocumentID <> :migrationsItem"
+	if backfill.FilterExpression != "" {
+		filter = "(" + backfill.FilterExpression + ") AND " + filter
+	}
+	values := map[string]types.AttributeValue{
+		":migrationsItem": &types.AttributeValueMemberS{Value: migrationsItemID},
+	}
+	for name, value := range backfill.ExpressionAttributeValues {
+		values[name] = value
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:                 aws.String(m.tableName),
+		Segment:                   aws.Int32(int32(segment)),
+		TotalSegments:             aws.Int32(backfillSegments),
+		Limit:                     aws.Int32(backfillPageSize),
+		FilterExpression:          aws.String(filter),
+		ExpressionAttributeValues: values,
+		ExclusiveStartKey:         startKey,
+	}
+	if backfill.ProjectionExpression != "" {
+		scanInput.ProjectionExpression = aws.String(backfill.ProjectionExpression)
+	}
+	if len(backfill.ExpressionAttributeNames) > 0 {
+		scanInput.ExpressionAttributeNames = backfill.ExpressionAttributeNames
+	}
+
+	updated := 0
+	for {
+		result, err := m.client.Scan(ctx, scanInput)
+		if err != nil {
+			return updated, fmt.Errorf("failed to scan segment %d: %w", segment, err)
+		}
+
+		for _, item := range result.Items {
+			changed, err := backfill.Update(ctx, item)
+			if err != nil {
+				return updated, err
+			}
+			if changed {
+				updated++
+			}
+		}
+
+		var progress types.AttributeValue = &types.AttributeValueMemberS{Value: backfillSegmentDone}
+		if result.LastEvaluatedKey != nil {
+			progress = &types.AttributeValueMemberM{Value: result.LastEvaluatedKey}
+		}
+		err = m.updateLocked(ctx, "SET BackfillSegments.#segment = :progress, LockUntil = :until",
+			map[string]string{"#segment": strconv.Itoa(segment)},
+			map[string]types.AttributeValue{":progress": progress},
+		)
+		if err != nil {
+			return updated, fmt.Errorf("failed to save progress of segment %d: %w", segment, err)
+		}
+
+		if result.LastEvaluatedKey == nil {
+			return updated, nil
+		}
+		scanInput.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// migrationsKey returns the key of the metadata item
+func migrationsKey() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"DocumentID": &types.AttributeValueMemberS{Value: migrationsItemID},
+		"OwnerID":    &types.AttributeValueMemberN{Value: "0"},
+	}
+}
+
+// millisAttribute returns a time as a number of Unix milliseconds
+func millisAttribute(t time.Time) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.UnixMilli(), 10)}
+}
+
+// parseMigrationsState reads the metadata item, which may not exist yet
+func parseMigrationsState(item map[string]types.AttributeValue) *migrationsState {
+	state := &migrationsState{
+		applied:      map[string]bool{},
+		backfillKeys: map[int]map[string]types.AttributeValue{},
+		backfillDone: map[int]bool{},
+	}
+
+	if applied, ok := item["Applied"].(*types.AttributeValueMemberM); ok {
+		for version := range applied.Value {
+			state.applied[version] = true
+		}
+	}
+	if version, ok := item["BackfillVersion"].(*types.AttributeValueMemberS); ok {
+		state.backfillVersion = version.Value
+	}
+	if segments, ok := item["BackfillSegments"].(*types.AttributeValueMemberM); ok {
+		for name, progress := range segments.Value {
+			segment, err := strconv.Atoi(name)
+			if err != nil {
+				continue
+			}
+			switch progress := progress.(type) {
+			case *types.AttributeValueMemberM:
+				state.backfillKeys[segment] = progress.Value
+			case *types.AttributeValueMemberS:
+				state.backfillDone[segment] = progress.Value == backfillSegmentDone
+			}
+		}
+	}
+	return state
+}
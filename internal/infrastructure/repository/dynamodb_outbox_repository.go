@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+const (
+	// outboxPendingIndexName is a sparse GSI holding only the messages not published yet
+	outboxPendingIndexName = "PendingIndex"
+
+	// outboxSentRetention is how long sent messages are kept before the table TTL removes them
+	outboxSentRetention = 7 * 24 * time.Hour
+)
+
+// dynamoDBOutboxRepository implements OutboxRepository using DynamoDB. Pending messages carry the
+// Pending attribute, which keys the sparse PendingIndex; sending a message removes it and sets the
+// TTL, parking it removes it and keeps the message
+type dynamoDBOutboxRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+// NewDynamoDBOutboxRepo creates a new DynamoDB outbox repository
+func NewDynamoDBOutboxRepo(client *dynamodb.Client, tableName string) interfaces.OutboxRepository {
+	return &dynamoDBOutboxRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+// Enqueue stores messages in a single transaction
+func (repo *dynamoDBOutboxRepository) Enqueue(ctx context.Context, messages ...*models.OutboxMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	items := make([]types.TransactWriteItem, 0, len(messages))
+	for _, message := range messages {
+		item, err := outboxPut(repo.tableName, message)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+	}
+
+	_, err := repo.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue outbox messages: %w", err)
+	}
+	return nil
+}
+
+// ListPending queries the pending index, oldest first. Messages backing off are filtered out after
+// the read, so the query goes on past them until limit due messages are found; their number stays
+// small since messages are parked once they run out of attempts
+func (repo *dynamoDBOutboxRepository) ListPending(ctx context.Context, limit int) ([]*models.OutboxMessage, error) {
+	paginator := dynamodb.NewQueryPaginator(repo.client, &dynamodb.QueryInput{
+		TableName:              aws.String(repo.tableName),
+		IndexName:              aws.String(outboxPendingIndexName),
+		KeyConditionExpression: aws.String("Pending = :pending"),
+		FilterExpression:       aws.String("attribute_not_exists(NextAttemptAt) OR NextAttemptAt <= :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pending": &types.AttributeValueMemberS{Value: models.OutboxPending},
+			":now":     &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().UnixNano(), 10)},
+		},
+		ScanIndexForward: aws.Bool(true),
+		Limit:            aws.Int32(int32(limit)),
+	})
+
+	messages := make([]*models.OutboxMessage, 0, limit)
+	for paginator.HasMorePages() && len(messages) < limit {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query pending outbox messages: %w", err)
+		}
+		for _, item := range page.Items {
+			if len(messages) == limit {
+				break
+			}
+			var message models.OutboxMessage
+			if err := attributevalue.UnmarshalMap(item, &message); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal outbox message: %w", err)
+			}
+			messages = append(messages, &message)
+		}
+	}
+	return messages, nil
+}
+
+// MarkSent removes a message from the pending index and schedules its expiration
+func (repo *dynamoDBOutboxRepository) MarkSent(ctx context.Context, id string) error {
+	now := time.Now()
+	_, err := repo.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(repo.tableName),
+		Key:                 outboxKey(id),
+		UpdateExpression:    aws.String("SET SentAt = :sent, ExpiresAt = :expires REMOVE Pending"),
+		ConditionExpression: aws.String("attribute_exists(MessageID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":sent":    &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			":expires": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(outboxSentRetention).Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox message as sent: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed counts a failed attempt, records its error and schedules the next one
+func (repo *dynamoDBOutboxRepository) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	_, err := repo.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(repo.tableName),
+		Key:                 outboxKey(id),
+		UpdateExpression:    aws.String("ADD Attempts :one SET LastError = :error, NextAttemptAt = :retry"),
+		ConditionExpression: aws.String("attribute_exists(MessageID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":   &types.AttributeValueMemberN{Value: "1"},
+			":error": &types.AttributeValueMemberS{Value: cause.Error()},
+			":retry": &types.AttributeValueMemberN{Value: strconv.FormatInt(retryAt.UnixNano(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox message as failed: %w", err)
+	}
+	return nil
+}
+
+// MarkParked counts the last failed attempt and removes the message from the pending index. Parked
+// messages get no TTL so they can be inspected and re-enqueued by hand
+func (repo *dynamoDBOutboxRepository) MarkParked(ctx context.Context, id string, cause error) error {
+	_, err := repo.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(repo.tableName),
+		Key:                 outboxKey(id),
+		UpdateExpression:    aws.String("ADD Attempts :one SET LastError = :error, ParkedAt = :parked REMOVE Pending, NextAttemptAt"),
+		ConditionExpression: aws.String("attribute_exists(MessageID)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one":    &types.AttributeValueMemberN{Value: "1"},
+			":error":  &types.AttributeValueMemberS{Value: cause.Error()},
+			":parked": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to park outbox message: %w", err)
+	}
+	return nil
+}
+
+// EnsureTableExists creates the outbox table with its pending index and TTL if it doesn't exist
+func (repo *dynamoDBOutboxRepository) EnsureTableExists(ctx context.Context) error {
+	_, err := repo.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(repo.tableName),
+	})
+	if err == nil {
+		return nil
+	}
+
+	_, err = repo.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(repo.tableName),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("MessageID"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("Pending"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("CreatedAt"),
+				AttributeType: types.ScalarAttributeTypeN,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("MessageID"),
+				KeyType:       types.KeyTypeHash,
+			},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				IndexName: aws.String(outboxPendingIndexName),
+				KeySchema: []types.KeySchemaElement{
+					{
+						AttributeName: aws.String("Pending"),
+						KeyType:       types.KeyTypeHash,
+					},
+					{
+						AttributeName: aws.String("CreatedAt"),
+						KeyType:       types.KeyTypeRange,
+					},
+				},
+				Projection: &types.Projection{
+					ProjectionType: types.ProjectionTypeAll,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create outbox table: %w", err)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(repo.client)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(repo.tableName),
+	}, time.Second*30); err != nil {
+		return err
+	}
+
+	_, err = repo.client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(repo.tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String("ExpiresAt"),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable outbox TTL: %w", err)
+	}
+	return nil
+}
+
+// outboxPut returns the transaction item inserting an outbox message, so other repositories can
+// store messages together with their own writes
+func outboxPut(tableName string, message *models.OutboxMessage) (types.TransactWriteItem, error) {
+	item, err := attributevalue.MarshalMap(message)
+	if err != nil {
+		return types.TransactWriteItem{}, fmt.Errorf("failed to marshal outbox message: %w", err)
+	}
+	return types.TransactWriteItem{
+		Put: &types.Put{
+			TableName:           aws.String(tableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(MessageID)"),
+		},
+	}, nil
+}
+
+// outboxPuts builds the outbox messages of a write to documents and returns the transaction items
+// inserting them
+func outboxPuts(tableName string, outbox interfaces.OutboxFunc, documents ...*models.Document) ([]types.TransactWriteItem, error) {
+	if outbox == nil {
+		return nil, nil
+	}
+	messages, err := outbox(documents)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]types.TransactWriteItem, 0, len(messages))
+	for _, message := range messages {
+		item, err := outboxPut(tableName, message)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// outboxKey returns the primary key of an outbox message
+func outboxKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"MessageID": &types.AttributeValueMemberS{Value: id},
+	}
+}
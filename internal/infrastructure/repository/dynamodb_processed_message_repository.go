@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -13,7 +16,8 @@ import (
 	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
 )
 
-// DynamoDBProcessedMessageRepository implements ProcessedMessageRepository using DynamoDB
+// DynamoDBProcessedMessageRepository implements ProcessedMessageRepository using DynamoDB. Claims
+// are conditional writes, so only one consumer at a time can hold a message
 type DynamoDBProcessedMessageRepository struct {
 	client    *dynamodb.Client
 	tableName string
@@ -27,52 +31,126 @@ func NewDynamoDBProcessedMessageRepository(client *dynamodb.Client, tableName st
 	}
 }
 
-// CheckIfProcessed checks if a message has been processed by querying DynamoDB
-func (r *DynamoDBProcessedMessageRepository) CheckIfProcessed(ctx context.Context, messageID string) (bool, error) {
-	if messageID == "" {
-		return false, nil
+// Claim puts the claim unless the message is already stored with a live lease or as completed. The
+// TTL condition covers records that expired but were not deleted by DynamoDB yet
+func (r *DynamoDBProcessedMessageRepository) Claim(ctx context.Context, claim *models.ProcessedMessage) (interfaces.ClaimStatus, error) {
+	if claim == nil {
+		return interfaces.ClaimAcquired, fmt.Errorf("claim cannot be nil")
 	}
 
-	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String(r.tableName),
-		Key: map[string]types.AttributeValue{
-			"MessageID": &types.AttributeValueMemberS{Value: messageID},
+	item, err := attributevalue.MarshalMap(claim)
+	if err != nil {
+		return interfaces.ClaimAcquired, fmt.Errorf("failed to marshal message claim: %w", err)
+	}
+
+	now := time.Now()
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(MessageID) OR (#status = :inProgress AND #lease < :nowMillis) OR #ttl < :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status",
+			"#lease":  "LeaseUntil",
+			"#ttl":    "TTL",
 		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":inProgress": &types.AttributeValueMemberS{Value: string(models.ProcessedMessageInProgress)},
+			":nowMillis":  &types.AttributeValueMemberN{Value: strconv.FormatInt(now.UnixMilli(), 10)},
+			":now":        &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
 	})
-
-	if err != nil {
-		return false, fmt.Errorf("failed to check if message is processed: %w", err)
+	if err == nil {
+		return interfaces.ClaimAcquired, nil
 	}
 
-	// If no item found, message hasn't been processed
-	if result.Item == nil {
-		return false, nil
+	var conditionErr *types.ConditionalCheckFailedException
+	if !errors.As(err, &conditionErr) {
+		return interfaces.ClaimAcquired, fmt.Errorf("failed to claim message: %w", err)
 	}
 
-	return true, nil
+	var existing models.ProcessedMessage
+	if err := attributevalue.UnmarshalMap(conditionErr.Item, &existing); err != nil {
+		return interfaces.ClaimAcquired, fmt.Errorf("failed to unmarshal message claim: %w", err)
+	}
+	if existing.Status == models.ProcessedMessageInProgress {
+		return interfaces.ClaimInProgress, nil
+	}
+	return interfaces.ClaimCompleted, nil
 }
 
-// MarkAsProcessed marks a message as processed in DynamoDB
-func (r *DynamoDBProcessedMessageRepository) MarkAsProcessed(ctx context.Context, message *models.ProcessedMessage) error {
-	if message == nil {
-		return fmt.Errorf("message cannot be nil")
+// Complete turns the claim into a completed record kept for the retention period
+func (r *DynamoDBProcessedMessageRepository) Complete(ctx context.Context, claim *models.ProcessedMessage) error {
+	if claim == nil {
+		return fmt.Errorf("claim cannot be nil")
 	}
 
-	// Marshal the message to DynamoDB attributes
-	item, err := attributevalue.MarshalMap(message)
+	now := time.Now()
+	processedAt, err := attributevalue.Marshal(now)
 	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
+		return fmt.Errorf("failed to marshal processing time: %w", err)
 	}
 
-	// Put the item (will overwrite if exists, which is fine for idempotency)
-	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.tableName),
-		Item:      item,
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 processedMessageKey(claim.MessageID),
+		UpdateExpression:    aws.String("SET #status = :completed, ProcessedAt = :processedAt, #ttl = :ttl REMOVE #lease"),
+		ConditionExpression: aws.String("ClaimToken = :token"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status",
+			"#lease":  "LeaseUntil",
+			"#ttl":    "TTL",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":completed":   &types.AttributeValueMemberS{Value: string(models.ProcessedMessageCompleted)},
+			":processedAt": processedAt,
+			":ttl":         &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(models.ProcessedMessageRetention).Unix(), 10)},
+			":token":       &types.AttributeValueMemberS{Value: claim.ClaimToken},
+		},
 	})
-
 	if err != nil {
+		if isConditionalCheckFailure(err) {
+			return fmt.Errorf("failed to mark message as processed: claim on %s was lost", claim.MessageID)
+		}
 		return fmt.Errorf("failed to mark message as processed: %w", err)
 	}
 
+	claim.Status = models.ProcessedMessageCompleted
+	claim.ProcessedAt = now
 	return nil
 }
+
+// Release deletes the claim if it is still held and in progress
+func (r *DynamoDBProcessedMessageRepository) Release(ctx context.Context, claim *models.ProcessedMessage) error {
+	if claim == nil {
+		return fmt.Errorf("claim cannot be nil")
+	}
+
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 processedMessageKey(claim.MessageID),
+		ConditionExpression: aws.String("ClaimToken = :token AND #status = :inProgress"),
+		ExpressionAttributeNames: map[string]string{
+			"#status": "Status",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":token":      &types.AttributeValueMemberS{Value: claim.ClaimToken},
+			":inProgress": &types.AttributeValueMemberS{Value: string(models.ProcessedMessageInProgress)},
+		},
+	})
+	if err != nil {
+		if isConditionalCheckFailure(err) {
+			// Another consumer took the message over after the lease ran out
+			return nil
+		}
+		return fmt.Errorf("failed to release message claim: %w", err)
+	}
+	return nil
+}
+
+// processedMessageKey returns the primary key of a processed message
+func processedMessageKey(messageID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"MessageID": &types.AttributeValueMemberS{Value: messageID},
+	}
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// dynamoDBUploadSessionRepository implements UploadSessionRepository using DynamoDB.
+// Items expire through the table TTL on the ExpiresAt attribute
+type dynamoDBUploadSessionRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+// NewDynamoDBUploadSessionRepo creates a new DynamoDB upload session repository
+func NewDynamoDBUploadSessionRepo(client *dynamodb.Client, tableName string) interfaces.UploadSessionRepository {
+	return &dynamoDBUploadSessionRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+// Create stores a new upload session, generating its ID if not present
+func (repo *dynamoDBUploadSessionRepository) Create(ctx context.Context, session *models.UploadSession) error {
+	if session.ID == "" {
+		session.ID = uuid.New().String()
+	}
+
+	item, err := attributevalue.MarshalMap(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload session: %w", err)
+	}
+
+	_, err = repo.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(repo.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(UploadID)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create upload session: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves an upload session by its ID
+func (repo *dynamoDBUploadSessionRepository) GetByID(ctx context.Context, id string) (*models.UploadSession, error) {
+	result, err := repo.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(repo.tableName),
+		Key:            uploadSessionKey(id),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload session: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var session models.UploadSession
+	if err := attributevalue.UnmarshalMap(result.Item, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal upload session: %w", err)
+	}
+	return &session, nil
+}
+
+// AcquireLease sets LeaseUntil only if the session is at the expected offset and any previous
+// lease has run out
+func (repo *dynamoDBUploadSessionRepository) AcquireLease(ctx context.Context, id string, offset int64, leaseUntil time.Time) (bool, error) {
+	_, err := repo.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(repo.tableName),
+		Key:                 uploadSessionKey(id),
+		UpdateExpression:    aws.String("SET #lease = :lease"),
+		ConditionExpression: aws.String("attribute_exists(UploadID) AND #offset = :offset AND #lease < :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#lease":  "LeaseUntil",
+			"#offset": "Offset",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":lease":  &types.AttributeValueMemberN{Value: strconv.FormatInt(leaseUntil.UnixMilli(), 10)},
+			":offset": &types.AttributeValueMemberN{Value: strconv.FormatInt(offset, 10)},
+			":now":    &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().UnixMilli(), 10)},
+		},
+	})
+	if err != nil {
+		if isConditionalCheckFailure(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to acquire upload session lease: %w", err)
+	}
+	return true, nil
+}
+
+// Save overwrites the session with its new progress and clears the lease, provided that the
+// lease recorded in session.LeaseUntil is still the one stored
+func (repo *dynamoDBUploadSessionRepository) Save(ctx context.Context, session *models.UploadSession) error {
+	heldLease := session.LeaseUntil
+	session.LeaseUntil = 0
+
+	item, err := attributevalue.MarshalMap(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload session: %w", err)
+	}
+
+	_, err = repo.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(repo.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("#lease = :lease"),
+		ExpressionAttributeNames: map[string]string{
+			"#lease": "LeaseUntil",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":lease": &types.AttributeValueMemberN{Value: strconv.FormatInt(heldLease, 10)},
+		},
+	})
+	if err != nil {
+		if isConditionalCheckFailure(err) {
+			return fmt.Errorf("upload session lease was lost")
+		}
+		return fmt.Errorf("failed to save upload session: %w", err)
+	}
+	return nil
+}
+
+// Delete removes an upload session
+func (repo *dynamoDBUploadSessionRepository) Delete(ctx context.Context, id string) error {
+	_, err := repo.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(repo.tableName),
+		Key:       uploadSessionKey(id),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete upload session: %w", err)
+	}
+	return nil
+}
+
+// EnsureTableExists creates the upload sessions table with TTL enabled if it doesn't exist
+func (repo *dynamoDBUploadSessionRepository) EnsureTableExists(ctx context.Context) error {
+	_, err := repo.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(repo.tableName),
+	})
+	if err == nil {
+		return nil
+	}
+
+	_, err = repo.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(repo.tableName),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("UploadID"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("UploadID"),
+				KeyType:       types.KeyTypeHash,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create upload sessions table: %w", err)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(repo.client)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(repo.tableName),
+	}, time.Second*30); err != nil {
+		return err
+	}
+
+	_, err = repo.client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(repo.tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String("ExpiresAt"),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable upload sessions TTL: %w", err)
+	}
+	return nil
+}
+
+// uploadSessionKey returns the primary key of an upload session
+func uploadSessionKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"UploadID": &types.AttributeValueMemberS{Value: id},
+	}
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+)
+
+// memoryBlobReferenceRepository implements BlobReferenceRepository on the counters of a MemoryDocumentStore
+type memoryBlobReferenceRepository struct {
+	store *MemoryDocumentStore
+}
+
+// NewMemoryBlobReferenceRepo creates a new in-memory blob reference repository backed by store
+func NewMemoryBlobReferenceRepo(store *MemoryDocumentStore) interfaces.BlobReferenceRepository {
+	return &memoryBlobReferenceRepository{store: store}
+}
+
+// ReleaseIfUnreferenced takes the lease of an object whose counter dropped to zero or below and
+// no other release holds. Objects without a counter are kept
+func (repo *memoryBlobReferenceRepository) ReleaseIfUnreferenced(ctx context.Context, objectKey string) (bool, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	now := time.Now()
+	refCount, counted := repo.store.blobRefs[objectKey]
+	if !counted || refCount > 0 {
+		return false, nil
+	}
+	if until, released := repo.store.releases[objectKey]; released && until.After(now) {
+		return false, nil
+	}
+	repo.store.releases[objectKey] = now.Add(blobReleaseLease)
+	return true, nil
+}
+
+// ListUnreferenced returns up to limit objects whose counter dropped to zero or below and no live
+// release holds
+func (repo *memoryBlobReferenceRepository) ListUnreferenced(ctx context.Context, limit int) ([]string, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	now := time.Now()
+	var objectKeys []string
+	for objectKey, refCount := range repo.store.blobRefs {
+		if len(objectKeys) == limit {
+			break
+		}
+		if until, released := repo.store.releases[objectKey]; refCount > 0 || (released && until.After(now)) {
+			continue
+		}
+		objectKeys = append(objectKeys, objectKey)
+	}
+	return objectKeys, nil
+}
+
+// FinishRelease removes the counter of a released object, unless a document referenced it again
+func (repo *memoryBlobReferenceRepository) FinishRelease(ctx context.Context, objectKey string) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	if _, released := repo.store.releases[objectKey]; !released || repo.store.blobRefs[objectKey] > 0 {
+		return nil
+	}
+	delete(repo.store.releases, objectKey)
+	delete(repo.store.blobRefs, objectKey)
+	return nil
+}
+
+// RebuildReferenceCounts recomputes every counter from the stored documents
+func (repo *memoryBlobReferenceRepository) RebuildReferenceCounts(ctx context.Context) (int, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	counts := make(map[string]int64)
+	for _, document := range repo.store.documents {
+		counts[document.ObjectKey]++
+	}
+	repo.store.blobRefs = counts
+	return len(counts), nil
+}
@@ -0,0 +1,481 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// MemoryDocumentStore holds the documents, blob reference and usage counters and outbox messages
+// shared by the in-memory document, blob reference and outbox repositories, as the DynamoDB tables
+// are for their counterparts. Holding them together lets a write span several of them atomically
+type MemoryDocumentStore struct {
+	mu        sync.RWMutex
+	documents map[string]models.Document
+	blobRefs  map[string]int64
+	releases  map[string]time.Time
+	usage     map[int64]models.OwnerUsage
+	outbox    map[string]models.OutboxMessage
+}
+
+// NewMemoryDocumentStore creates an empty in-memory document store
+func NewMemoryDocumentStore() *MemoryDocumentStore {
+	return &MemoryDocumentStore{
+		documents: make(map[string]models.Document),
+		blobRefs:  make(map[string]int64),
+		releases:  make(map[string]time.Time),
+		usage:     make(map[int64]models.OwnerUsage),
+		outbox:    make(map[string]models.OutboxMessage),
+	}
+}
+
+// memoryDocumentRepository implements the DocumentRepository interface in memory with the same
+// semantics as the DynamoDB repository. Documents are stored and returned as copies, so callers
+// never share state with the store
+type memoryDocumentRepository struct {
+	store *MemoryDocumentStore
+}
+
+// NewMemoryDocumentRepo creates a new in-memory document repository backed by store
+func NewMemoryDocumentRepo(store *MemoryDocumentStore) interfaces.DocumentRepository {
+	return &memoryDocumentRepository{store: store}
+}
+
+// EnsureTableExists is a no-op; the store needs no provisioning
+func (repo *memoryDocumentRepository) EnsureTableExists(ctx context.Context) error {
+	return nil
+}
+
+// Create stores a new document at version 1, generating an ID and timestamps if not present, and
+// increments the reference counter of its object and the usage of its owner. It fails with
+// ErrDuplicateDocument if the owner already has a document with the same content, and with
+// ErrQuotaExceeded if quota does not allow the owner to store it
+func (repo *memoryDocumentRepository) Create(ctx context.Context, document *models.Document, quota models.Quota, outbox interfaces.OutboxFunc) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	if document.ID == "" {
+		document.ID = uuid.New().String()
+	}
+	if _, exists := repo.store.documents[document.ID]; exists {
+		return fmt.Errorf("failed to create document: document %s already exists", document.ID)
+	}
+	for _, existing := range repo.store.documents {
+		if existing.OwnerID == document.OwnerID && existing.HashSHA256 == document.HashSHA256 {
+			return interfaces.ErrDuplicateDocument
+		}
+	}
+	if !quota.Allows(repo.store.usageOf(document.OwnerID), document.SizeBytes) {
+		return interfaces.ErrQuotaExceeded
+	}
+
+	now := time.Now()
+	if until, released := repo.store.releases[document.ObjectKey]; released && until.After(now) {
+		return interfaces.ErrObjectReleasing
+	}
+	if document.CreatedAt.IsZero() {
+		document.CreatedAt = now
+	}
+	document.UpdatedAt = now
+	document.Version = 1
+
+	if err := repo.store.record(outbox, document); err != nil {
+		return err
+	}
+
+	repo.store.documents[document.ID] = *document
+	delete(repo.store.releases, document.ObjectKey)
+	repo.store.blobRefs[document.ObjectKey]++
+	repo.store.addUsage(document, 1)
+	return nil
+}
+
+// FindByHashAndOwnerID retrieves a document by its hash and owner ID
+func (repo *memoryDocumentRepository) FindByHashAndOwnerID(ctx context.Context, hashSHA256 string, ownerID int64) (*models.Document, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	for _, document := range repo.store.documents {
+		if document.HashSHA256 == hashSHA256 && document.OwnerID == ownerID {
+			return &document, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetByID retrieves a document by its unique identifier
+func (repo *memoryDocumentRepository) GetByID(ctx context.Context, id string) (*models.Document, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	document, ok := repo.store.documents[id]
+	if !ok {
+		return nil, nil
+	}
+	return &document, nil
+}
+
+// List retrieves a page of the documents of an owner outside the trash, most recent first, with
+// their total count
+func (repo *memoryDocumentRepository) List(ctx context.Context, ownerID int64, limit, offset int) ([]*models.Document, int64, error) {
+	repo.store.mu.RLock()
+	owned := repo.store.ownedBy(ownerID)
+	repo.store.mu.RUnlock()
+
+	totalCount := int64(len(owned))
+	if offset >= len(owned) {
+		return []*models.Document{}, totalCount, nil
+	}
+
+	end := offset + limit
+	if end > len(owned) {
+		end = len(owned)
+	}
+	return owned[offset:end], totalCount, nil
+}
+
+// ListPage retrieves up to limit documents of an owner matching filter in the order of sort,
+// following position. A position holds the sorted attributes and ID of the last document of the
+// previous page, so pages stay consistent when documents are created or deleted in between
+func (repo *memoryDocumentRepository) ListPage(ctx context.Context, ownerID int64, order models.DocumentSort, filter models.DocumentFilter, limit int, position string) ([]*models.Document, string, error) {
+	repo.store.mu.RLock()
+	owned := repo.store.matching(ownerID, filter)
+	repo.store.mu.RUnlock()
+
+	less := documentLess(order)
+	sort.Slice(owned, func(i, j int) bool { return less(owned[i], owned[j]) })
+
+	start := 0
+	if position != "" {
+		var last memoryPosition
+		if err := json.Unmarshal([]byte(position), &last); err != nil {
+			return nil, "", fmt.Errorf("malformed list position: %w", err)
+		}
+		after := last.document()
+		start = sort.Search(len(owned), func(i int) bool { return less(after, owned[i]) })
+	}
+
+	end := start + limit
+	if end >= len(owned) {
+		return owned[start:], "", nil
+	}
+	next, err := json.Marshal(newMemoryPosition(owned[end-1]))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode list position: %w", err)
+	}
+	return owned[start:end], string(next), nil
+}
+
+// memoryPosition is the position of a document in every order ListPage supports and in the purge
+// order of ListExpired
+type memoryPosition struct {
+	CreatedAt int64  `json:"c"`
+	Filename  string `json:"f"`
+	Size      int64  `json:"s"`
+	PurgeAt   int64  `json:"p,omitempty"`
+	ID        string `json:"id"`
+}
+
+func newMemoryPosition(document *models.Document) memoryPosition {
+	return memoryPosition{
+		CreatedAt: document.CreatedAt.UnixNano(),
+		Filename:  document.Filename,
+		Size:      document.SizeBytes,
+		ID:        document.ID,
+	}
+}
+
+// document returns a document with the sorted attributes of the position
+func (p memoryPosition) document() *models.Document {
+	return &models.Document{
+		ID:        p.ID,
+		Filename:  p.Filename,
+		SizeBytes: p.Size,
+		CreatedAt: time.Unix(0, p.CreatedAt),
+	}
+}
+
+// documentLess returns the comparison ordering documents by order. Ties are broken by ID so that
+// the order is total
+func documentLess(order models.DocumentSort) func(a, b *models.Document) bool {
+	compare := func(a, b *models.Document) int {
+		switch order.Field {
+		case models.DocumentSortFilename:
+			return strings.Compare(models.FilenameSortKey(a.Filename), models.FilenameSortKey(b.Filename))
+		case models.DocumentSortSize:
+			return cmp.Compare(a.SizeBytes, b.SizeBytes)
+		default:
+			return a.CreatedAt.Compare(b.CreatedAt)
+		}
+	}
+
+	return func(a, b *models.Document) bool {
+		c := compare(a, b)
+		if order.Order == models.SortOrderDesc {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	}
+}
+
+// CountByOwner returns the number of documents of an owner matching filter
+func (repo *memoryDocumentRepository) CountByOwner(ctx context.Context, ownerID int64, filter models.DocumentFilter) (int64, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	var count int64
+	for _, document := range repo.store.documents {
+		if document.OwnerID == ownerID && filter.Matches(&document) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetUsage returns the usage counters of an owner
+func (repo *memoryDocumentRepository) GetUsage(ctx context.Context, ownerID int64) (*models.OwnerUsage, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	usage := repo.store.usageOf(ownerID)
+	return &usage, nil
+}
+
+// DeleteByID removes a document and decrements the reference counter of its object and, unless
+// the document is in the trash, the usage of its owner. Returns nil if the document doesn't exist
+func (repo *memoryDocumentRepository) DeleteByID(ctx context.Context, id string, expectedVersion int64, outbox interfaces.OutboxFunc) (*models.Document, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	document, ok := repo.store.documents[id]
+	if !ok {
+		return nil, nil
+	}
+	if expectedVersion != 0 && document.Version != expectedVersion {
+		return nil, interfaces.ErrVersionConflict
+	}
+	if err := repo.store.record(outbox, &document); err != nil {
+		return nil, err
+	}
+
+	delete(repo.store.documents, id)
+	repo.store.blobRefs[document.ObjectKey]--
+	if !document.IsTrashed() {
+		repo.store.addUsage(&document, -1)
+	}
+	return &document, nil
+}
+
+// Trash moves a document to the trash until purgeAt, updating its timestamp and its version and
+// releasing the usage of its owner. Returns nil if the document doesn't exist or is in the trash
+// already
+func (repo *memoryDocumentRepository) Trash(ctx context.Context, id string, expectedVersion int64, purgeAt time.Time, outbox interfaces.OutboxFunc) (*models.Document, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	document, ok := repo.store.documents[id]
+	if !ok || document.IsTrashed() {
+		return nil, nil
+	}
+	if expectedVersion != 0 && document.Version != expectedVersion {
+		return nil, interfaces.ErrVersionConflict
+	}
+
+	trashDocument(&document, time.Now(), purgeAt)
+	if err := repo.store.record(outbox, &document); err != nil {
+		return nil, err
+	}
+	repo.store.documents[id] = document
+	repo.store.addUsage(&document, -1)
+	return &document, nil
+}
+
+// TrashAllByOwnerID moves every document of an owner outside the trash to the trash until purgeAt,
+// releasing their usage
+func (repo *memoryDocumentRepository) TrashAllByOwnerID(ctx context.Context, ownerID int64, purgeAt time.Time, outbox interfaces.OutboxFunc) (int, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	owned := repo.store.ownedBy(ownerID)
+	if len(owned) == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	for _, document := range owned {
+		trashDocument(document, now, purgeAt)
+	}
+	if err := repo.store.record(outbox, owned...); err != nil {
+		return 0, err
+	}
+	for _, document := range owned {
+		repo.store.documents[document.ID] = *document
+		repo.store.addUsage(document, -1)
+	}
+	return len(owned), nil
+}
+
+// Restore takes a document out of the trash, updating its timestamp and its version and adding it
+// back to the usage of its owner. It fails with ErrQuotaExceeded if quota does not allow it. Returns
+// nil if the document doesn't exist or is not in the trash
+func (repo *memoryDocumentRepository) Restore(ctx context.Context, id string, expectedVersion int64, quota models.Quota, outbox interfaces.OutboxFunc) (*models.Document, error) {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	document, ok := repo.store.documents[id]
+	if !ok || !document.IsTrashed() {
+		return nil, nil
+	}
+	if expectedVersion != 0 && document.Version != expectedVersion {
+		return nil, interfaces.ErrVersionConflict
+	}
+	if !quota.Allows(repo.store.usageOf(document.OwnerID), document.SizeBytes) {
+		return nil, interfaces.ErrQuotaExceeded
+	}
+
+	document.DeletedAt = nil
+	document.PurgeAt = nil
+	document.UpdatedAt = time.Now()
+	document.Version++
+	if err := repo.store.record(outbox, &document); err != nil {
+		return nil, err
+	}
+	repo.store.documents[id] = document
+	repo.store.addUsage(&document, 1)
+	return &document, nil
+}
+
+// ListExpired retrieves up to limit trashed documents due to be purged before the given time,
+// those due first coming first, following position
+func (repo *memoryDocumentRepository) ListExpired(ctx context.Context, before time.Time, limit int, position string) ([]*models.Document, string, error) {
+	repo.store.mu.RLock()
+	var expired []*models.Document
+	for _, document := range repo.store.documents {
+		if document.PurgeAt != nil && document.PurgeAt.Before(before) {
+			document := document
+			expired = append(expired, &document)
+		}
+	}
+	repo.store.mu.RUnlock()
+
+	less := func(a, b *models.Document) bool {
+		if !a.PurgeAt.Equal(*b.PurgeAt) {
+			return a.PurgeAt.Before(*b.PurgeAt)
+		}
+		return a.ID < b.ID
+	}
+	sort.Slice(expired, func(i, j int) bool { return less(expired[i], expired[j]) })
+
+	start := 0
+	if position != "" {
+		var last memoryPosition
+		if err := json.Unmarshal([]byte(position), &last); err != nil {
+			return nil, "", fmt.Errorf("malformed list position: %w", err)
+		}
+		purgeAt := time.Unix(0, last.PurgeAt)
+		after := &models.Document{ID: last.ID, PurgeAt: &purgeAt}
+		start = sort.Search(len(expired), func(i int) bool { return less(after, expired[i]) })
+	}
+
+	end := start + limit
+	if end >= len(expired) {
+		return expired[start:], "", nil
+	}
+	next, err := json.Marshal(memoryPosition{PurgeAt: expired[end-1].PurgeAt.UnixNano(), ID: expired[end-1].ID})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode list position: %w", err)
+	}
+	return expired[start:end], string(next), nil
+}
+
+// trashDocument marks a document as moved to the trash at now until purgeAt
+func trashDocument(document *models.Document, now, purgeAt time.Time) {
+	document.DeletedAt = &now
+	document.PurgeAt = &purgeAt
+	document.UpdatedAt = now
+	document.Version++
+}
+
+// UpdateAuthenticationStatus updates the authentication status of a document, its updated
+// timestamp and its version, storing the outbox messages under the same lock
+func (repo *memoryDocumentRepository) UpdateAuthenticationStatus(ctx context.Context, documentID string, expectedVersion int64, status models.AuthenticationStatus, outbox interfaces.OutboxFunc) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	document, ok := repo.store.documents[documentID]
+	if !ok {
+		return interfaces.ErrDocumentNotFound
+	}
+	if expectedVersion != 0 && document.Version != expectedVersion {
+		return interfaces.ErrVersionConflict
+	}
+
+	document.AuthenticationStatus = status
+	document.UpdatedAt = time.Now()
+	document.Version++
+	if err := repo.store.record(outbox, &document); err != nil {
+		return err
+	}
+	repo.store.documents[documentID] = document
+	return nil
+}
+
+// usageOf returns the usage of an owner. The caller must hold the lock
+func (store *MemoryDocumentStore) usageOf(ownerID int64) models.OwnerUsage {
+	usage := store.usage[ownerID]
+	usage.OwnerID = ownerID
+	return usage
+}
+
+// addUsage adds count times a document to the usage of its owner. The caller must hold the lock
+func (store *MemoryDocumentStore) addUsage(document *models.Document, count int64) {
+	usage := store.usageOf(document.OwnerID)
+	usage.DocumentCount += count
+	usage.TotalBytes += count * document.SizeBytes
+	store.usage[document.OwnerID] = usage
+}
+
+// ownedBy returns copies of the documents of an owner outside the trash sorted by creation date,
+// most recent first. Ties are broken by ID so that pages are stable. The caller must hold the lock
+func (store *MemoryDocumentStore) ownedBy(ownerID int64) []*models.Document {
+	var owned []*models.Document
+	for _, document := range store.documents {
+		if document.OwnerID == ownerID && !document.IsTrashed() {
+			document := document
+			owned = append(owned, &document)
+		}
+	}
+
+	sort.Slice(owned, func(i, j int) bool {
+		if !owned[i].CreatedAt.Equal(owned[j].CreatedAt) {
+			return owned[i].CreatedAt.After(owned[j].CreatedAt)
+		}
+		return owned[i].ID < owned[j].ID
+	})
+	return owned
+}
+
+// matching returns copies of the documents of an owner matching filter, in no particular order.
+// The caller must hold the lock
+func (store *MemoryDocumentStore) matching(ownerID int64, filter models.DocumentFilter) []*models.Document {
+	var matched []*models.Document
+	for _, document := range store.documents {
+		if document.OwnerID == ownerID && filter.Matches(&document) {
+			document := document
+			matched = append(matched, &document)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// memoryOutboxRepository implements the OutboxRepository interface in memory. Sent messages are
+// dropped right away since nothing reads them back; parked ones stay without being pending
+type memoryOutboxRepository struct {
+	store *MemoryDocumentStore
+}
+
+// NewMemoryOutboxRepo creates a new in-memory outbox repository backed by store
+func NewMemoryOutboxRepo(store *MemoryDocumentStore) interfaces.OutboxRepository {
+	return &memoryOutboxRepository{store: store}
+}
+
+// EnsureTableExists is a no-op; the store needs no provisioning
+func (repo *memoryOutboxRepository) EnsureTableExists(ctx context.Context) error {
+	return nil
+}
+
+// Enqueue stores messages all at once
+func (repo *memoryOutboxRepository) Enqueue(ctx context.Context, messages ...*models.OutboxMessage) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	return repo.store.enqueue(messages)
+}
+
+// ListPending returns copies of the pending messages that are due, oldest first
+func (repo *memoryOutboxRepository) ListPending(ctx context.Context, limit int) ([]*models.OutboxMessage, error) {
+	repo.store.mu.RLock()
+	defer repo.store.mu.RUnlock()
+
+	now := time.Now()
+	pending := make([]*models.OutboxMessage, 0, len(repo.store.outbox))
+	for _, message := range repo.store.outbox {
+		if message.Due(now) {
+			pending = append(pending, &message)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].CreatedAt != pending[j].CreatedAt {
+			return pending[i].CreatedAt < pending[j].CreatedAt
+		}
+		return pending[i].ID < pending[j].ID
+	})
+
+	if limit > 0 && len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+// MarkSent removes a published message
+func (repo *memoryOutboxRepository) MarkSent(ctx context.Context, id string) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	if _, ok := repo.store.outbox[id]; !ok {
+		return fmt.Errorf("failed to mark outbox message as sent: message %s not found", id)
+	}
+	delete(repo.store.outbox, id)
+	return nil
+}
+
+// MarkFailed counts a failed attempt, records its error and schedules the next one
+func (repo *memoryOutboxRepository) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	message, ok := repo.store.outbox[id]
+	if !ok {
+		return fmt.Errorf("failed to mark outbox message as failed: message %s not found", id)
+	}
+	message.Attempts++
+	message.LastError = cause.Error()
+	message.NextAttemptAt = retryAt.UnixNano()
+	repo.store.outbox[id] = message
+	return nil
+}
+
+// MarkParked counts the last failed attempt and keeps the message out of the pending ones
+func (repo *memoryOutboxRepository) MarkParked(ctx context.Context, id string, cause error) error {
+	repo.store.mu.Lock()
+	defer repo.store.mu.Unlock()
+
+	message, ok := repo.store.outbox[id]
+	if !ok {
+		return fmt.Errorf("failed to park outbox message: message %s not found", id)
+	}
+	message.Attempts++
+	message.LastError = cause.Error()
+	message.Pending = ""
+	message.NextAttemptAt = 0
+	message.ParkedAt = time.Now().Unix()
+	repo.store.outbox[id] = message
+	return nil
+}
+
+// enqueue stores copies of pending outbox messages, rejecting the whole batch if any ID is taken.
+// The caller must hold the lock
+func (store *MemoryDocumentStore) enqueue(messages []*models.OutboxMessage) error {
+	for _, message := range messages {
+		if _, exists := store.outbox[message.ID]; exists {
+			return fmt.Errorf("failed to enqueue outbox message: message %s already exists", message.ID)
+		}
+	}
+	for _, message := range messages {
+		if message.CreatedAt == 0 {
+			message.CreatedAt = time.Now().UnixNano()
+		}
+		message.Pending = models.OutboxPending
+		store.outbox[message.ID] = *message
+	}
+	return nil
+}
+
+// record builds the outbox messages of a write to documents and stores them. The caller must hold
+// the lock and apply the write only once record succeeds
+func (store *MemoryDocumentStore) record(outbox interfaces.OutboxFunc, documents ...*models.Document) error {
+	if outbox == nil {
+		return nil
+	}
+	messages, err := outbox(documents)
+	if err != nil {
+		return err
+	}
+	return store.enqueue(messages)
+}
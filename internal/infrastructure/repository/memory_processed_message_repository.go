@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// MemoryProcessedMessageRepository implements ProcessedMessageRepository in memory. Messages past
+// their TTL are treated as never processed, as if the DynamoDB TTL had already removed them
+type MemoryProcessedMessageRepository struct {
+	mu       sync.Mutex
+	messages map[string]models.ProcessedMessage
+}
+
+// NewMemoryProcessedMessageRepository creates a new in-memory processed message repository
+func NewMemoryProcessedMessageRepository() interfaces.ProcessedMessageRepository {
+	return &MemoryProcessedMessageRepository{messages: make(map[string]models.ProcessedMessage)}
+}
+
+// Claim stores the claim unless the message is held under a live lease or completed. Expired
+// records are purged on the way so that memory stays bounded by the TTL
+func (r *MemoryProcessedMessageRepository) Claim(ctx context.Context, claim *models.ProcessedMessage) (interfaces.ClaimStatus, error) {
+	if claim == nil {
+		return interfaces.ClaimAcquired, fmt.Errorf("claim cannot be nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, stored := range r.messages {
+		if isProcessedMessageExpired(stored, now) {
+			delete(r.messages, id)
+		}
+	}
+
+	if existing, ok := r.messages[claim.MessageID]; ok {
+		if existing.Status != models.ProcessedMessageInProgress {
+			return interfaces.ClaimCompleted, nil
+		}
+		if existing.LeaseUntil >= now.UnixMilli() {
+			return interfaces.ClaimInProgress, nil
+		}
+	}
+
+	r.messages[claim.MessageID] = *claim
+	return interfaces.ClaimAcquired, nil
+}
+
+// Complete turns the claim into a completed record kept for the retention period
+func (r *MemoryProcessedMessageRepository) Complete(ctx context.Context, claim *models.ProcessedMessage) error {
+	if claim == nil {
+		return fmt.Errorf("claim cannot be nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.messages[claim.MessageID]
+	if !ok || stored.ClaimToken != claim.ClaimToken {
+		return fmt.Errorf("failed to mark message as processed: claim on %s was lost", claim.MessageID)
+	}
+
+	now := time.Now()
+	stored.Status = models.ProcessedMessageCompleted
+	stored.ProcessedAt = now
+	stored.LeaseUntil = 0
+	stored.TTL = now.Add(models.ProcessedMessageRetention).Unix()
+	r.messages[claim.MessageID] = stored
+
+	claim.Status = stored.Status
+	claim.ProcessedAt = now
+	return nil
+}
+
+// Release deletes the claim if it is still held and in progress
+func (r *MemoryProcessedMessageRepository) Release(ctx context.Context, claim *models.ProcessedMessage) error {
+	if claim == nil {
+		return fmt.Errorf("claim cannot be nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.messages[claim.MessageID]
+	if ok && stored.ClaimToken == claim.ClaimToken && stored.Status == models.ProcessedMessageInProgress {
+		delete(r.messages, claim.MessageID)
+	}
+	return nil
+}
+
+// isProcessedMessageExpired reports whether a record is past its TTL. A zero TTL never expires
+func isProcessedMessageExpired(message models.ProcessedMessage, now time.Time) bool {
+	return message.TTL > 0 && now.Unix() >= message.TTL
+}
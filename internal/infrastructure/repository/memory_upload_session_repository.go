@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// memoryUploadSessionRepository implements UploadSessionRepository in memory with the same lease
+// conditions as the DynamoDB repository. Sessions past ExpiresAt are dropped as the table TTL would
+type memoryUploadSessionRepository struct {
+	mu       sync.Mutex
+	sessions map[string]models.UploadSession
+}
+
+// NewMemoryUploadSessionRepo creates a new in-memory upload session repository
+func NewMemoryUploadSessionRepo() interfaces.UploadSessionRepository {
+	return &memoryUploadSessionRepository{sessions: make(map[string]models.UploadSession)}
+}
+
+// Create stores a new upload session, generating its ID if not present
+func (repo *memoryUploadSessionRepository) Create(ctx context.Context, session *models.UploadSession) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if session.ID == "" {
+		session.ID = uuid.New().String()
+	}
+	if _, exists := repo.live(session.ID); exists {
+		return fmt.Errorf("failed to create upload session: session %s already exists", session.ID)
+	}
+
+	repo.sessions[session.ID] = cloneUploadSession(*session)
+	return nil
+}
+
+// GetByID retrieves an upload session by its ID
+func (repo *memoryUploadSessionRepository) GetByID(ctx context.Context, id string) (*models.UploadSession, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	session, ok := repo.live(id)
+	if !ok {
+		return nil, nil
+	}
+	clone := cloneUploadSession(session)
+	return &clone, nil
+}
+
+// AcquireLease sets LeaseUntil only if the session is at the expected offset and any previous
+// lease has run out
+func (repo *memoryUploadSessionRepository) AcquireLease(ctx context.Context, id string, offset int64, leaseUntil time.Time) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	session, ok := repo.live(id)
+	if !ok || session.Offset != offset || session.LeaseUntil >= time.Now().UnixMilli() {
+		return false, nil
+	}
+
+	session.LeaseUntil = leaseUntil.UnixMilli()
+	repo.sessions[id] = session
+	return true, nil
+}
+
+// Save overwrites the session with its new progress and clears the lease, provided that the
+// lease recorded in session.LeaseUntil is still the one stored
+func (repo *memoryUploadSessionRepository) Save(ctx context.Context, session *models.UploadSession) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	heldLease := session.LeaseUntil
+	session.LeaseUntil = 0
+
+	stored, ok := repo.sessions[session.ID]
+	if !ok || stored.LeaseUntil != heldLease {
+		return fmt.Errorf("upload session lease was lost")
+	}
+
+	repo.sessions[session.ID] = cloneUploadSession(*session)
+	return nil
+}
+
+// Delete removes an upload session
+func (repo *memoryUploadSessionRepository) Delete(ctx context.Context, id string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	delete(repo.sessions, id)
+	return nil
+}
+
+// EnsureTableExists is a no-op; the repository needs no provisioning
+func (repo *memoryUploadSessionRepository) EnsureTableExists(ctx context.Context) error {
+	return nil
+}
+
+// live returns a session unless it is missing or expired, in which case it is dropped.
+// The caller must hold the lock
+func (repo *memoryUploadSessionRepository) live(id string) (models.UploadSession, bool) {
+	session, ok := repo.sessions[id]
+	if !ok {
+		return models.UploadSession{}, false
+	}
+	if session.IsExpired(time.Now()) {
+		delete(repo.sessions, id)
+		return models.UploadSession{}, false
+	}
+	return session, true
+}
+
+// cloneUploadSession copies a session including its slices
+func cloneUploadSession(session models.UploadSession) models.UploadSession {
+	session.Parts = append([]models.UploadPart(nil), session.Parts...)
+	session.HashState = append([]byte(nil), session.HashState...)
+	return session
+}
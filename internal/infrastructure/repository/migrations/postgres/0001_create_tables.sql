@@ -0,0 +1,76 @@
+-- Documents; an owner has at most one document per content hash
+CREATE TABLE documents (
+    id                    TEXT PRIMARY KEY,
+    filename              TEXT NOT NULL,
+    mime_type             TEXT NOT NULL,
+    size_bytes            BIGINT NOT NULL,
+    hash_sha256           TEXT NOT NULL,
+    bucket                TEXT NOT NULL,
+    object_key            TEXT NOT NULL,
+    url                   TEXT NOT NULL,
+    owner_id              BIGINT NOT NULL,
+    authentication_status TEXT NOT NULL,
+    created_at            TIMESTAMPTZ NOT NULL,
+    updated_at            TIMESTAMPTZ NOT NULL,
+    version               BIGINT NOT NULL,
+    CONSTRAINT documents_hash_owner_key UNIQUE (hash_sha256, owner_id)
+);
+
+-- One index per order of the document list, ties broken by ID
+CREATE INDEX documents_owner_created_at_idx ON documents (owner_id, created_at, id);
+CREATE INDEX documents_owner_filename_idx ON documents (owner_id, lower(filename), id);
+CREATE INDEX documents_owner_size_idx ON documents (owner_id, size_bytes, id);
+
+-- Reference counters for content-addressed objects shared between documents
+CREATE TABLE document_blob_refs (
+    object_key TEXT PRIMARY KEY,
+    ref_count  BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+-- Transactional outbox; messages are deleted once published
+CREATE TABLE document_outbox (
+    message_id TEXT PRIMARY KEY,
+    payload    TEXT NOT NULL,
+    created_at BIGINT NOT NULL,
+    attempts   INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX document_outbox_created_at_idx ON document_outbox (created_at, message_id);
+
+-- Claims of consumers on messages (idempotency); expired records are purged by the service
+CREATE TABLE processed_messages (
+    message_id   TEXT PRIMARY KEY,
+    processed_at TIMESTAMPTZ NOT NULL,
+    processed_by TEXT NOT NULL,
+    status       TEXT NOT NULL,
+    claim_token  TEXT NOT NULL,
+    lease_until  BIGINT NOT NULL,
+    expires_at   BIGINT NOT NULL
+);
+
+CREATE INDEX processed_messages_expires_at_idx ON processed_messages (expires_at);
+
+-- Resumable upload sessions; expired sessions are purged by the service
+CREATE TABLE upload_sessions (
+    upload_id         TEXT PRIMARY KEY,
+    owner_id          BIGINT NOT NULL,
+    kind              TEXT NOT NULL,
+    filename          TEXT NOT NULL,
+    content_type      TEXT NOT NULL,
+    length            BIGINT NOT NULL,
+    upload_offset     BIGINT NOT NULL,
+    staging_key       TEXT NOT NULL,
+    storage_upload_id TEXT NOT NULL,
+    parts             JSONB NOT NULL,
+    pending_size      BIGINT NOT NULL,
+    hash_state        BYTEA,
+    expected_sha256   TEXT NOT NULL,
+    lease_until       BIGINT NOT NULL,
+    created_at        TIMESTAMPTZ NOT NULL,
+    updated_at        TIMESTAMPTZ NOT NULL,
+    expires_at        BIGINT NOT NULL
+);
+
+CREATE INDEX upload_sessions_expires_at_idx ON upload_sessions (expires_at);
@@ -0,0 +1,9 @@
+-- Usage counters of the owners, maintained by the writes to documents and checked against quotas
+CREATE TABLE owner_usage (
+    owner_id       BIGINT PRIMARY KEY,
+    document_count BIGINT NOT NULL,
+    total_bytes    BIGINT NOT NULL
+);
+
+INSERT INTO owner_usage (owner_id, document_count, total_bytes)
+SELECT owner_id, count(*), sum(size_bytes) FROM documents GROUP BY owner_id;
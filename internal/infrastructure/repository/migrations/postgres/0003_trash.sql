@@ -0,0 +1,6 @@
+-- Documents moved to the trash keep their row until purged
+ALTER TABLE documents
+    ADD COLUMN deleted_at TIMESTAMPTZ,
+    ADD COLUMN purge_at   TIMESTAMPTZ;
+
+CREATE INDEX documents_purge_at_idx ON documents (purge_at) WHERE purge_at IS NOT NULL;
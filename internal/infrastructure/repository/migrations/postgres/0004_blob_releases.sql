@@ -0,0 +1,4 @@
+-- Objects being deleted from storage hold a release on their counter until this time, and no
+-- document can reference them meanwhile
+ALTER TABLE document_blob_refs
+    ADD COLUMN releasing_until TIMESTAMPTZ;
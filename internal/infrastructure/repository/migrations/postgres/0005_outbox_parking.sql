@@ -0,0 +1,7 @@
+-- Failed messages back off until next_attempt_at; parked ones are kept out of the pending messages
+ALTER TABLE document_outbox
+    ADD COLUMN next_attempt_at BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN parked_at       TIMESTAMPTZ;
+
+DROP INDEX document_outbox_created_at_idx;
+CREATE INDEX document_outbox_pending_idx ON document_outbox (created_at, message_id) WHERE parked_at IS NULL;
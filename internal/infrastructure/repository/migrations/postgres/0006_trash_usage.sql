@@ -0,0 +1,7 @@
+-- Documents in the trash no longer count in the usage of their owner
+UPDATE owner_usage AS usage
+SET document_count = usage.document_count - trashed.document_count,
+    total_bytes    = usage.total_bytes - trashed.total_bytes
+FROM (SELECT owner_id, count(*) AS document_count, sum(size_bytes) AS total_bytes
+      FROM documents WHERE deleted_at IS NOT NULL GROUP BY owner_id) AS trashed
+WHERE usage.owner_id = trashed.owner_id;
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+)
+
+// postgresBlobReferenceRepository implements BlobReferenceRepository on the document_blob_refs table
+type postgresBlobReferenceRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresBlobReferenceRepo creates a new PostgreSQL blob reference repository
+func NewPostgresBlobReferenceRepo(pool *pgxpool.Pool) interfaces.BlobReferenceRepository {
+	return &postgresBlobReferenceRepository{pool: pool}
+}
+
+// ReleaseIfUnreferenced takes the lease of an object whose counter dropped to zero or below and
+// no other release holds. An object without a counter is kept
+func (repo *postgresBlobReferenceRepository) ReleaseIfUnreferenced(ctx context.Context, objectKey string) (bool, error) {
+	now := time.Now()
+	result, err := repo.pool.Exec(ctx, `UPDATE document_blob_refs SET releasing_until = $2
+		WHERE object_key = $1 AND ref_count <= 0 AND (releasing_until IS NULL OR releasing_until < $3)`,
+		objectKey, now.Add(blobReleaseLease), now)
+	if err != nil {
+		return false, fmt.Errorf("failed to release blob reference %s: %w", objectKey, err)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// ListUnreferenced returns up to limit objects whose counter dropped to zero or below and no live
+// release holds, those left unreferenced first
+func (repo *postgresBlobReferenceRepository) ListUnreferenced(ctx context.Context, limit int) ([]string, error) {
+	rows, err := repo.pool.Query(ctx, `SELECT object_key FROM document_blob_refs
+		WHERE ref_count <= 0 AND (releasing_until IS NULL OR releasing_until < $1) ORDER BY updated_at LIMIT $2`, time.Now(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unreferenced blob references: %w", err)
+	}
+	objectKeys, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unreferenced blob references: %w", err)
+	}
+	return objectKeys, nil
+}
+
+// FinishRelease deletes the counter of a released object, unless a document referenced it again
+func (repo *postgresBlobReferenceRepository) FinishRelease(ctx context.Context, objectKey string) error {
+	_, err := repo.pool.Exec(ctx, "DELETE FROM document_blob_refs WHERE object_key = $1 AND ref_count <= 0 AND releasing_until IS NOT NULL", objectKey)
+	if err != nil {
+		return fmt.Errorf("failed to finish release of blob reference %s: %w", objectKey, err)
+	}
+	return nil
+}
+
+// RebuildReferenceCounts recomputes every counter from the documents table. Document writes are
+// blocked until it completes, so the counters match the documents exactly
+func (repo *postgresBlobReferenceRepository) RebuildReferenceCounts(ctx context.Context) (int, error) {
+	var referenced int
+	err := pgx.BeginFunc(ctx, repo.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "LOCK TABLE documents IN SHARE MODE"); err != nil {
+			return fmt.Errorf("failed to lock documents: %w", err)
+		}
+		if _, err := tx.Exec(ctx, "DELETE FROM document_blob_refs"); err != nil {
+			return fmt.Errorf("failed to clear blob references: %w", err)
+		}
+		result, err := tx.Exec(ctx, `INSERT INTO document_blob_refs (object_key, ref_count, updated_at)
+			SELECT object_key, count(*), now() FROM documents GROUP BY object_key`)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild blob references: %w", err)
+		}
+		referenced = int(result.RowsAffected())
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return referenced, nil
+}
+
+// adjustPostgresBlobRef adds delta to the reference counter of an object within a transaction,
+// creating the counter if needed. A new reference fails with ErrObjectReleasing while the object is
+// released, and takes over an expired release
+func adjustPostgresBlobRef(ctx context.Context, tx pgx.Tx, objectKey string, delta int64, now time.Time) error {
+	result, err := tx.Exec(ctx, `INSERT INTO document_blob_refs (object_key, ref_count, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (object_key) DO UPDATE SET ref_count = document_blob_refs.ref_count + EXCLUDED.ref_count, updated_at = EXCLUDED.updated_at, releasing_until = NULL
+		WHERE EXCLUDED.ref_count < 0 OR document_blob_refs.releasing_until IS NULL OR document_blob_refs.releasing_until < EXCLUDED.updated_at`,
+		objectKey, delta, now)
+	if err != nil {
+		return fmt.Errorf("failed to update blob reference %s: %w", objectKey, err)
+	}
+	if result.RowsAffected() == 0 {
+		return interfaces.ErrObjectReleasing
+	}
+	return nil
+}
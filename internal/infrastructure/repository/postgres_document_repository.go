@@ -0,0 +1,482 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+const (
+	// postgresDocumentColumns are the columns a document is read from, in the order of scanPostgresDocument
+	postgresDocumentColumns = "id, filename, mime_type, size_bytes, hash_sha256, bucket, object_key, url, owner_id, authentication_status, created_at, updated_at, version, deleted_at, purge_at"
+
+	// postgresHashOwnerConstraint makes the content of the documents of an owner unique
+	postgresHashOwnerConstraint = "documents_hash_owner_key"
+
+	// postgresUniqueViolation is the SQLSTATE of a unique constraint violation
+	postgresUniqueViolation = "23505"
+)
+
+// postgresDocumentRepository implements the DocumentRepository interface using PostgreSQL. Every
+// write changes the documents, the reference counters of their objects and the outbox in a single
+// transaction, and rows are locked while their version is checked
+type postgresDocumentRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresDocumentRepo creates a new PostgreSQL document repository
+func NewPostgresDocumentRepo(pool *pgxpool.Pool) interfaces.DocumentRepository {
+	return &postgresDocumentRepository{pool: pool}
+}
+
+// EnsureTableExists applies the migrations of the PostgreSQL repositories
+func (repo *postgresDocumentRepository) EnsureTableExists(ctx context.Context) error {
+	return MigratePostgres(ctx, repo.pool)
+}
+
+// Create stores a new document at version 1, generating an ID and timestamps if not present, and
+// increments the reference counter of its object and the usage of its owner. It fails with
+// ErrDuplicateDocument if the owner already has a document with the same content, and with
+// ErrQuotaExceeded if quota does not allow the owner to store it
+func (repo *postgresDocumentRepository) Create(ctx context.Context, document *models.Document, quota models.Quota, outbox interfaces.OutboxFunc) error {
+	if document.ID == "" {
+		document.ID = uuid.New().String()
+	}
+
+	// PostgreSQL keeps timestamps to the microsecond
+	now := time.Now().Truncate(time.Microsecond)
+	if document.CreatedAt.IsZero() {
+		document.CreatedAt = now
+	}
+	document.CreatedAt = document.CreatedAt.Truncate(time.Microsecond)
+	document.UpdatedAt = now
+	document.Version = 1
+
+	return repo.inTransaction(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "INSERT INTO documents ("+postgresDocumentColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)",
+			document.ID, document.Filename, document.MimeType, document.SizeBytes, document.HashSHA256, document.Bucket,
+			document.ObjectKey, document.URL, document.OwnerID, string(document.AuthenticationStatus),
+			document.CreatedAt, document.UpdatedAt, document.Version, document.DeletedAt, document.PurgeAt,
+		)
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == postgresUniqueViolation && pgErr.ConstraintName == postgresHashOwnerConstraint {
+				return interfaces.ErrDuplicateDocument
+			}
+			return fmt.Errorf("failed to create document in PostgreSQL: %w", err)
+		}
+
+		if err := reservePostgresUsage(ctx, tx, document, quota); err != nil {
+			return err
+		}
+		if err := adjustPostgresBlobRef(ctx, tx, document.ObjectKey, 1, now); err != nil {
+			return err
+		}
+		return recordPostgresOutbox(ctx, tx, outbox, document)
+	})
+}
+
+// FindByHashAndOwnerID retrieves a document by its hash and owner ID
+// This is used for file deduplication
+func (repo *postgresDocumentRepository) FindByHashAndOwnerID(ctx context.Context, hashSHA256 string, ownerID int64) (*models.Document, error) {
+	row := repo.pool.QueryRow(ctx, "SELECT "+postgresDocumentColumns+" FROM documents WHERE hash_sha256 = $1 AND owner_id = $2", hashSHA256, ownerID)
+	document, err := scanPostgresDocument(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query documents by hash: %w", err)
+	}
+	return document, nil
+}
+
+// GetByID retrieves a document by its unique identifier
+func (repo *postgresDocumentRepository) GetByID(ctx context.Context, id string) (*models.Document, error) {
+	document, err := scanPostgresDocument(repo.pool.QueryRow(ctx, "SELECT "+postgresDocumentColumns+" FROM documents WHERE id = $1", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document: %w", err)
+	}
+	return document, nil
+}
+
+// List retrieves a page of the documents of an owner outside the trash, most recent first, with
+// their total count
+func (repo *postgresDocumentRepository) List(ctx context.Context, ownerID int64, limit, offset int) ([]*models.Document, int64, error) {
+	totalCount, err := repo.CountByOwner(ctx, ownerID, models.DocumentFilter{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := repo.pool.Query(ctx, "SELECT "+postgresDocumentColumns+" FROM documents WHERE owner_id = $1 AND deleted_at IS NULL ORDER BY created_at DESC, id LIMIT $2 OFFSET $3", ownerID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list documents: %w", err)
+	}
+	documents, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Document, error) {
+		return scanPostgresDocument(row)
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list documents: %w", err)
+	}
+	return documents, totalCount, nil
+}
+
+// ListPage retrieves up to limit documents of an owner matching filter in the order of sort,
+// following position. A position holds the sort key, as text, and the ID of the last document of
+// the previous page, and the query resumes after them on the index of the order
+func (repo *postgresDocumentRepository) ListPage(ctx context.Context, ownerID int64, order models.DocumentSort, filter models.DocumentFilter, limit int, position string) ([]*models.Document, string, error) {
+	sortKey, sortType := postgresSortKey(order.Field)
+	direction, comparison := "ASC", ">"
+	if order.Order == models.SortOrderDesc {
+		direction, comparison = "DESC", "<"
+	}
+
+	var args postgresArgs
+	where := postgresDocumentConditions(&args, ownerID, filter)
+	if position != "" {
+		var last postgresPosition
+		if err := json.Unmarshal([]byte(position), &last); err != nil {
+			return nil, "", fmt.Errorf("malformed list position: %w", err)
+		}
+		where += fmt.Sprintf(" AND (%s, id) %s (%s::%s, %s)", sortKey, comparison, args.add(last.Key), sortType, args.add(last.ID))
+	}
+
+	// One more document than requested tells whether there is a next page
+	query := fmt.Sprintf("SELECT %s, (%s)::text FROM documents WHERE %s ORDER BY %s %s, id %s LIMIT %s",
+		postgresDocumentColumns, sortKey, where, sortKey, direction, direction, args.add(limit+1))
+	rows, err := repo.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	var keys []string
+	documents, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Document, error) {
+		var key string
+		document, err := scanPostgresDocument(row, &key)
+		keys = append(keys, key)
+		return document, err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	if len(documents) <= limit {
+		return documents, "", nil
+	}
+	next, err := json.Marshal(postgresPosition{Key: keys[limit-1], ID: documents[limit-1].ID})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode list position: %w", err)
+	}
+	return documents[:limit], string(next), nil
+}
+
+// postgresPosition is the position of a document in a list, after which the next page starts
+type postgresPosition struct {
+	Key string `json:"k"`
+	ID  string `json:"id"`
+}
+
+// postgresSortKey returns the expression a list is ordered by, as indexed, and its type
+func postgresSortKey(field models.DocumentSortField) (string, string) {
+	switch field {
+	case models.DocumentSortFilename:
+		return "lower(filename)", "text"
+	case models.DocumentSortSize:
+		return "size_bytes", "bigint"
+	default:
+		return "created_at", "timestamptz"
+	}
+}
+
+// CountByOwner returns the number of documents of an owner matching filter
+func (repo *postgresDocumentRepository) CountByOwner(ctx context.Context, ownerID int64, filter models.DocumentFilter) (int64, error) {
+	var args postgresArgs
+	where := postgresDocumentConditions(&args, ownerID, filter)
+
+	var count int64
+	if err := repo.pool.QueryRow(ctx, "SELECT count(*) FROM documents WHERE "+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteByID removes a document and decrements the reference counter of its object and, unless
+// the document is in the trash, the usage of its owner. Returns nil if the document doesn't exist
+func (repo *postgresDocumentRepository) DeleteByID(ctx context.Context, id string, expectedVersion int64, outbox interfaces.OutboxFunc) (*models.Document, error) {
+	var deleted *models.Document
+	err := repo.inTransaction(ctx, func(tx pgx.Tx) error {
+		document, err := scanPostgresDocument(tx.QueryRow(ctx, "SELECT "+postgresDocumentColumns+" FROM documents WHERE id = $1 FOR UPDATE", id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get document: %w", err)
+		}
+		if expectedVersion != 0 && document.Version != expectedVersion {
+			return interfaces.ErrVersionConflict
+		}
+
+		if _, err := tx.Exec(ctx, "DELETE FROM documents WHERE id = $1", id); err != nil {
+			return fmt.Errorf("failed to delete document: %w", err)
+		}
+		if err := adjustPostgresBlobRef(ctx, tx, document.ObjectKey, -1, time.Now()); err != nil {
+			return err
+		}
+		if !document.IsTrashed() {
+			if err := releasePostgresUsage(ctx, tx, document.OwnerID, 1, document.SizeBytes); err != nil {
+				return err
+			}
+		}
+		if err := recordPostgresOutbox(ctx, tx, outbox, document); err != nil {
+			return err
+		}
+		deleted = document
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// Trash moves a document to the trash until purgeAt, updating its timestamp and its version and
+// releasing the usage of its owner. Returns nil if the document doesn't exist or is in the trash
+// already
+func (repo *postgresDocumentRepository) Trash(ctx context.Context, id string, expectedVersion int64, purgeAt time.Time, outbox interfaces.OutboxFunc) (*models.Document, error) {
+	return repo.moveTrash(ctx, id, expectedVersion, true, purgeAt, models.Quota{}, outbox)
+}
+
+// Restore takes a document out of the trash, updating its timestamp and its version and adding it
+// back to the usage of its owner. It fails with ErrQuotaExceeded if quota does not allow it. Returns
+// nil if the document doesn't exist or is not in the trash
+func (repo *postgresDocumentRepository) Restore(ctx context.Context, id string, expectedVersion int64, quota models.Quota, outbox interfaces.OutboxFunc) (*models.Document, error) {
+	return repo.moveTrash(ctx, id, expectedVersion, false, time.Time{}, quota, outbox)
+}
+
+// moveTrash moves a document into the trash until purgeAt, or out of it within quota, if it is not
+// there yet, updating the usage of its owner
+func (repo *postgresDocumentRepository) moveTrash(ctx context.Context, id string, expectedVersion int64, trash bool, purgeAt time.Time, quota models.Quota, outbox interfaces.OutboxFunc) (*models.Document, error) {
+	var moved *models.Document
+	err := repo.inTransaction(ctx, func(tx pgx.Tx) error {
+		document, err := scanPostgresDocument(tx.QueryRow(ctx, "SELECT "+postgresDocumentColumns+" FROM documents WHERE id = $1 FOR UPDATE", id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get document: %w", err)
+		}
+		if document.IsTrashed() == trash {
+			return nil
+		}
+		if expectedVersion != 0 && document.Version != expectedVersion {
+			return interfaces.ErrVersionConflict
+		}
+
+		now := time.Now().Truncate(time.Microsecond)
+		document.UpdatedAt = now
+		document.DeletedAt, document.PurgeAt = nil, nil
+		if trash {
+			purgeAt = purgeAt.Truncate(time.Microsecond)
+			document.DeletedAt, document.PurgeAt = &now, &purgeAt
+		}
+		document.Version++
+		_, err = tx.Exec(ctx, "UPDATE documents SET deleted_at = $1, purge_at = $2, updated_at = $3, version = $4 WHERE id = $5",
+			document.DeletedAt, document.PurgeAt, document.UpdatedAt, document.Version, id)
+		if err != nil {
+			return fmt.Errorf("failed to move document to or from the trash: %w", err)
+		}
+		if trash {
+			err = releasePostgresUsage(ctx, tx, document.OwnerID, 1, document.SizeBytes)
+		} else {
+			err = reservePostgresUsage(ctx, tx, document, quota)
+		}
+		if err != nil {
+			return err
+		}
+		if err := recordPostgresOutbox(ctx, tx, outbox, document); err != nil {
+			return err
+		}
+		moved = document
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+// TrashAllByOwnerID moves every document of an owner outside the trash to the trash until purgeAt
+// in a single transaction, releasing their usage
+func (repo *postgresDocumentRepository) TrashAllByOwnerID(ctx context.Context, ownerID int64, purgeAt time.Time, outbox interfaces.OutboxFunc) (int, error) {
+	trashed := 0
+	err := repo.inTransaction(ctx, func(tx pgx.Tx) error {
+		now := time.Now().Truncate(time.Microsecond)
+		rows, err := tx.Query(ctx, "UPDATE documents SET deleted_at = $1, purge_at = $2, updated_at = $1, version = version + 1 WHERE owner_id = $3 AND deleted_at IS NULL RETURNING "+postgresDocumentColumns,
+			now, purgeAt.Truncate(time.Microsecond), ownerID)
+		if err != nil {
+			return fmt.Errorf("failed to move documents to the trash: %w", err)
+		}
+		documents, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Document, error) {
+			return scanPostgresDocument(row)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to move documents to the trash: %w", err)
+		}
+		if len(documents) == 0 {
+			return nil
+		}
+		var bytes int64
+		for _, document := range documents {
+			bytes += document.SizeBytes
+		}
+		if err := releasePostgresUsage(ctx, tx, ownerID, int64(len(documents)), bytes); err != nil {
+			return err
+		}
+		if err := recordPostgresOutbox(ctx, tx, outbox, documents...); err != nil {
+			return err
+		}
+		trashed = len(documents)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return trashed, nil
+}
+
+// ListExpired retrieves up to limit trashed documents due to be purged before the given time,
+// those due first coming first. A position holds the purge time, as text, and the ID of the last
+// document listed
+func (repo *postgresDocumentRepository) ListExpired(ctx context.Context, before time.Time, limit int, position string) ([]*models.Document, string, error) {
+	args := postgresArgs{before}
+	where := "purge_at < $1"
+	if position != "" {
+		var last postgresPosition
+		if err := json.Unmarshal([]byte(position), &last); err != nil {
+			return nil, "", fmt.Errorf("malformed list position: %w", err)
+		}
+		where += fmt.Sprintf(" AND (purge_at, id) > (%s::timestamptz, %s)", args.add(last.Key), args.add(last.ID))
+	}
+
+	// One more document than requested tells whether there are more
+	query := fmt.Sprintf("SELECT %s, purge_at::text FROM documents WHERE %s ORDER BY purge_at, id LIMIT %s", postgresDocumentColumns, where, args.add(limit+1))
+	rows, err := repo.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list expired documents: %w", err)
+	}
+
+	var keys []string
+	documents, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Document, error) {
+		var key string
+		document, err := scanPostgresDocument(row, &key)
+		keys = append(keys, key)
+		return document, err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list expired documents: %w", err)
+	}
+
+	if len(documents) <= limit {
+		return documents, "", nil
+	}
+	next, err := json.Marshal(postgresPosition{Key: keys[limit-1], ID: documents[limit-1].ID})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode list position: %w", err)
+	}
+	return documents[:limit], string(next), nil
+}
+
+// UpdateAuthenticationStatus updates the authentication status of a document, its updated
+// timestamp and its version, storing the outbox messages in the same transaction
+func (repo *postgresDocumentRepository) UpdateAuthenticationStatus(ctx context.Context, documentID string, expectedVersion int64, status models.AuthenticationStatus, outbox interfaces.OutboxFunc) error {
+	return repo.inTransaction(ctx, func(tx pgx.Tx) error {
+		document, err := scanPostgresDocument(tx.QueryRow(ctx, "SELECT "+postgresDocumentColumns+" FROM documents WHERE id = $1 FOR UPDATE", documentID))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return interfaces.ErrDocumentNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get document: %w", err)
+		}
+		if expectedVersion != 0 && document.Version != expectedVersion {
+			return interfaces.ErrVersionConflict
+		}
+
+		document.AuthenticationStatus = status
+		document.UpdatedAt = time.Now().Truncate(time.Microsecond)
+		document.Version++
+		_, err = tx.Exec(ctx, "UPDATE documents SET authentication_status = $1, updated_at = $2, version = $3 WHERE id = $4",
+			string(status), document.UpdatedAt, document.Version, documentID)
+		if err != nil {
+			return fmt.Errorf("failed to update authentication status: %w", err)
+		}
+		return recordPostgresOutbox(ctx, tx, outbox, document)
+	})
+}
+
+// inTransaction runs fn in a transaction, committed only if fn succeeds
+func (repo *postgresDocumentRepository) inTransaction(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return pgx.BeginFunc(ctx, repo.pool, fn)
+}
+
+// postgresArgs collects the arguments of a query as they are referenced
+type postgresArgs []any
+
+// add appends an argument and returns its placeholder
+func (args *postgresArgs) add(value any) string {
+	*args = append(*args, value)
+	return "$" + strconv.Itoa(len(*args))
+}
+
+// postgresDocumentConditions returns the condition selecting the documents of an owner matching filter
+func postgresDocumentConditions(args *postgresArgs, ownerID int64, filter models.DocumentFilter) string {
+	conditions := []string{"owner_id = " + args.add(ownerID), "deleted_at IS NULL"}
+	if filter.Trashed {
+		conditions[1] = "deleted_at IS NOT NULL"
+	}
+	if filter.AuthenticationStatus != "" {
+		conditions = append(conditions, "authentication_status = "+args.add(string(filter.AuthenticationStatus)))
+	}
+	if filter.MimeFamily != "" {
+		conditions = append(conditions, "starts_with(lower(mime_type), "+args.add(filter.MimeFamilyPrefix())+")")
+	}
+	if !filter.CreatedFrom.IsZero() {
+		conditions = append(conditions, "created_at >= "+args.add(filter.CreatedFrom))
+	}
+	if !filter.CreatedTo.IsZero() {
+		conditions = append(conditions, "created_at <= "+args.add(filter.CreatedTo))
+	}
+	if filter.FilenameContains != "" {
+		conditions = append(conditions, "strpos(lower(filename), lower("+args.add(filter.FilenameContains)+")) > 0")
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// scanPostgresDocument reads a document selected with postgresDocumentColumns, followed by the
+// columns read into extra
+func scanPostgresDocument(row pgx.Row, extra ...any) (*models.Document, error) {
+	var document models.Document
+	var status string
+	destinations := append([]any{
+		&document.ID, &document.Filename, &document.MimeType, &document.SizeBytes, &document.HashSHA256, &document.Bucket,
+		&document.ObjectKey, &document.URL, &document.OwnerID, &status, &document.CreatedAt, &document.UpdatedAt, &document.Version, &document.DeletedAt, &document.PurgeAt,
+	}, extra...)
+	if err := row.Scan(destinations...); err != nil {
+		return nil, err
+	}
+	document.AuthenticationStatus = models.AuthenticationStatus(status)
+	return &document, nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresMigrationsLock is the advisory lock serializing the replicas migrating the same database
+const postgresMigrationsLock = 7436103
+
+// postgresMigrations holds the schema of the PostgreSQL repositories, one file per version. Files
+// are applied in the order of their names and must never change once released
+//
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// MigratePostgres applies the embedded migrations the database lacks, all in one transaction, and
+// records them in the schema_migrations table. It takes an advisory lock, so replicas starting
+// together apply each migration once
+func MigratePostgres(ctx context.Context, pool *pgxpool.Pool) error {
+	files, err := fs.Glob(postgresMigrations, "migrations/postgres/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	sort.Strings(files)
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", postgresMigrationsLock); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	for _, file := range files {
+		version := strings.TrimSuffix(path.Base(file), ".sql")
+
+		var applied bool
+		err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&applied)
+		if err != nil {
+			return fmt.Errorf("failed to check migration %s: %w", version, err)
+		}
+		if applied {
+			continue
+		}
+
+		statements, err := postgresMigrations.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", version, err)
+		}
+		// Without arguments the file is sent as a simple query, which may hold several statements
+		if _, err := tx.Exec(ctx, string(statements)); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", version, err)
+		}
+		if _, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", version, err)
+		}
+		log.Printf("applied migration %s", version)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit migrations: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// postgresOutboxRepository implements the OutboxRepository interface using PostgreSQL. The table
+// only holds pending and parked messages: sending a message deletes it
+type postgresOutboxRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresOutboxRepo creates a new PostgreSQL outbox repository
+func NewPostgresOutboxRepo(pool *pgxpool.Pool) interfaces.OutboxRepository {
+	return &postgresOutboxRepository{pool: pool}
+}
+
+// EnsureTableExists applies the migrations of the PostgreSQL repositories
+func (repo *postgresOutboxRepository) EnsureTableExists(ctx context.Context) error {
+	return MigratePostgres(ctx, repo.pool)
+}
+
+// Enqueue stores messages in a single transaction
+func (repo *postgresOutboxRepository) Enqueue(ctx context.Context, messages ...*models.OutboxMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	return pgx.BeginFunc(ctx, repo.pool, func(tx pgx.Tx) error {
+		return insertPostgresOutboxMessages(ctx, tx, messages)
+	})
+}
+
+// ListPending returns the oldest pending messages that are due
+func (repo *postgresOutboxRepository) ListPending(ctx context.Context, limit int) ([]*models.OutboxMessage, error) {
+	rows, err := repo.pool.Query(ctx, "SELECT message_id, payload, created_at, attempts, last_error, next_attempt_at FROM document_outbox WHERE parked_at IS NULL AND next_attempt_at <= $1 ORDER BY created_at, message_id LIMIT $2",
+		time.Now().UnixNano(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending outbox messages: %w", err)
+	}
+	messages, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.OutboxMessage, error) {
+		message := &models.OutboxMessage{Pending: models.OutboxPending}
+		err := row.Scan(&message.ID, &message.Payload, &message.CreatedAt, &message.Attempts, &message.LastError, &message.NextAttemptAt)
+		return message, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending outbox messages: %w", err)
+	}
+	return messages, nil
+}
+
+// MarkSent deletes a published message
+func (repo *postgresOutboxRepository) MarkSent(ctx context.Context, id string) error {
+	result, err := repo.pool.Exec(ctx, "DELETE FROM document_outbox WHERE message_id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox message as sent: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("failed to mark outbox message as sent: message %s not found", id)
+	}
+	return nil
+}
+
+// MarkFailed counts a failed attempt, records its error and schedules the next one
+func (repo *postgresOutboxRepository) MarkFailed(ctx context.Context, id string, cause error, retryAt time.Time) error {
+	result, err := repo.pool.Exec(ctx, "UPDATE document_outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE message_id = $3",
+		cause.Error(), retryAt.UnixNano(), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox message as failed: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("failed to mark outbox message as failed: message %s not found", id)
+	}
+	return nil
+}
+
+// MarkParked counts the last failed attempt and keeps the message out of the pending ones
+func (repo *postgresOutboxRepository) MarkParked(ctx context.Context, id string, cause error) error {
+	result, err := repo.pool.Exec(ctx, "UPDATE document_outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = 0, parked_at = now() WHERE message_id = $2", cause.Error(), id)
+	if err != nil {
+		return fmt.Errorf("failed to park outbox message: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("failed to park outbox message: message %s not found", id)
+	}
+	return nil
+}
+
+// insertPostgresOutboxMessages stores pending outbox messages within a transaction
+func insertPostgresOutboxMessages(ctx context.Context, tx pgx.Tx, messages []*models.OutboxMessage) error {
+	for _, message := range messages {
+		if message.CreatedAt == 0 {
+			message.CreatedAt = time.Now().UnixNano()
+		}
+		message.Pending = models.OutboxPending
+		_, err := tx.Exec(ctx, "INSERT INTO document_outbox (message_id, payload, created_at, attempts, last_error) VALUES ($1, $2, $3, $4, $5)",
+			message.ID, message.Payload, message.CreatedAt, message.Attempts, message.LastError)
+		if err != nil {
+			return fmt.Errorf("failed to enqueue outbox message %s: %w", message.ID, err)
+		}
+	}
+	return nil
+}
+
+// recordPostgresOutbox builds the outbox messages of a write to documents and stores them within
+// the transaction of the write
+func recordPostgresOutbox(ctx context.Context, tx pgx.Tx, outbox interfaces.OutboxFunc, documents ...*models.Document) error {
+	if outbox == nil {
+		return nil
+	}
+	messages, err := outbox(documents)
+	if err != nil {
+		return err
+	}
+	return insertPostgresOutboxMessages(ctx, tx, messages)
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// reservePostgresUsage adds a new document to the usage of its owner within a transaction, failing
+// with ErrQuotaExceeded if quota does not allow it. The counter row is locked by the update, so
+// concurrent creates of the same owner are checked one after the other
+func reservePostgresUsage(ctx context.Context, tx pgx.Tx, document *models.Document, quota models.Quota) error {
+	// The first document of an owner is inserted without the condition of the update
+	if !quota.Allows(models.OwnerUsage{OwnerID: document.OwnerID}, document.SizeBytes) {
+		return interfaces.ErrQuotaExceeded
+	}
+
+	tag, err := tx.Exec(ctx, `INSERT INTO owner_usage AS usage (owner_id, document_count, total_bytes) VALUES ($1, 1, $2)
+		ON CONFLICT (owner_id) DO UPDATE SET document_count = usage.document_count + 1, total_bytes = usage.total_bytes + EXCLUDED.total_bytes
+		WHERE ($3 = 0 OR usage.document_count < $3) AND ($4 = 0 OR usage.total_bytes + EXCLUDED.total_bytes <= $4)`,
+		document.OwnerID, document.SizeBytes, quota.MaxDocuments, quota.MaxBytes)
+	if err != nil {
+		return fmt.Errorf("failed to update usage of owner %d: %w", document.OwnerID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return interfaces.ErrQuotaExceeded
+	}
+	return nil
+}
+
+// releasePostgresUsage removes deleted or trashed documents from the usage of their owner within a
+// transaction
+func releasePostgresUsage(ctx context.Context, tx pgx.Tx, ownerID, documents, bytes int64) error {
+	_, err := tx.Exec(ctx, "UPDATE owner_usage SET document_count = document_count - $2, total_bytes = total_bytes - $3 WHERE owner_id = $1",
+		ownerID, documents, bytes)
+	if err != nil {
+		return fmt.Errorf("failed to update usage of owner %d: %w", ownerID, err)
+	}
+	return nil
+}
+
+// GetUsage returns the usage counters of an owner
+func (repo *postgresDocumentRepository) GetUsage(ctx context.Context, ownerID int64) (*models.OwnerUsage, error) {
+	usage := &models.OwnerUsage{OwnerID: ownerID}
+	err := repo.pool.QueryRow(ctx, "SELECT document_count, total_bytes FROM owner_usage WHERE owner_id = $1", ownerID).
+		Scan(&usage.DocumentCount, &usage.TotalBytes)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get usage of owner %d: %w", ownerID, err)
+	}
+	return usage, nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// PostgresProcessedMessageRepository implements ProcessedMessageRepository using PostgreSQL. Claims
+// are conditional upserts, so only one consumer at a time can hold a message
+type PostgresProcessedMessageRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresProcessedMessageRepository creates a new PostgreSQL-based processed message repository
+func NewPostgresProcessedMessageRepository(pool *pgxpool.Pool) interfaces.ProcessedMessageRepository {
+	return &PostgresProcessedMessageRepository{pool: pool}
+}
+
+// Claim stores the claim unless the message is stored with a live lease or as completed. Records
+// past their TTL count as never processed and are purged on the way, as the DynamoDB TTL would
+func (r *PostgresProcessedMessageRepository) Claim(ctx context.Context, claim *models.ProcessedMessage) (interfaces.ClaimStatus, error) {
+	if claim == nil {
+		return interfaces.ClaimAcquired, fmt.Errorf("claim cannot be nil")
+	}
+
+	now := time.Now()
+	if _, err := r.pool.Exec(ctx, "DELETE FROM processed_messages WHERE expires_at > 0 AND expires_at <= $1", now.Unix()); err != nil {
+		return interfaces.ClaimAcquired, fmt.Errorf("failed to purge expired message claims: %w", err)
+	}
+
+	result, err := r.pool.Exec(ctx, `INSERT INTO processed_messages (message_id, processed_at, processed_by, status, claim_token, lease_until, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (message_id) DO UPDATE SET processed_at = EXCLUDED.processed_at, processed_by = EXCLUDED.processed_by,
+			status = EXCLUDED.status, claim_token = EXCLUDED.claim_token, lease_until = EXCLUDED.lease_until, expires_at = EXCLUDED.expires_at
+		WHERE processed_messages.status = $8 AND processed_messages.lease_until < $9`,
+		claim.MessageID, claim.ProcessedAt, claim.ProcessedBy, string(claim.Status), claim.ClaimToken, claim.LeaseUntil, claim.TTL,
+		string(models.ProcessedMessageInProgress), now.UnixMilli(),
+	)
+	if err != nil {
+		return interfaces.ClaimAcquired, fmt.Errorf("failed to claim message: %w", err)
+	}
+	if result.RowsAffected() == 1 {
+		return interfaces.ClaimAcquired, nil
+	}
+
+	var status string
+	if err := r.pool.QueryRow(ctx, "SELECT status FROM processed_messages WHERE message_id = $1", claim.MessageID).Scan(&status); err != nil {
+		return interfaces.ClaimAcquired, fmt.Errorf("failed to read message claim: %w", err)
+	}
+	if models.ProcessedMessageStatus(status) == models.ProcessedMessageInProgress {
+		return interfaces.ClaimInProgress, nil
+	}
+	return interfaces.ClaimCompleted, nil
+}
+
+// Complete turns the claim into a completed record kept for the retention period
+func (r *PostgresProcessedMessageRepository) Complete(ctx context.Context, claim *models.ProcessedMessage) error {
+	if claim == nil {
+		return fmt.Errorf("claim cannot be nil")
+	}
+
+	now := time.Now()
+	result, err := r.pool.Exec(ctx, "UPDATE processed_messages SET status = $1, processed_at = $2, lease_until = 0, expires_at = $3 WHERE message_id = $4 AND claim_token = $5",
+		string(models.ProcessedMessageCompleted), now, now.Add(models.ProcessedMessageRetention).Unix(), claim.MessageID, claim.ClaimToken)
+	if err != nil {
+		return fmt.Errorf("failed to mark message as processed: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("failed to mark message as processed: claim on %s was lost", claim.MessageID)
+	}
+
+	claim.Status = models.ProcessedMessageCompleted
+	claim.ProcessedAt = now
+	return nil
+}
+
+// Release deletes the claim if it is still held and in progress
+func (r *PostgresProcessedMessageRepository) Release(ctx context.Context, claim *models.ProcessedMessage) error {
+	if claim == nil {
+		return fmt.Errorf("claim cannot be nil")
+	}
+
+	_, err := r.pool.Exec(ctx, "DELETE FROM processed_messages WHERE message_id = $1 AND claim_token = $2 AND status = $3",
+		claim.MessageID, claim.ClaimToken, string(models.ProcessedMessageInProgress))
+	if err != nil {
+		return fmt.Errorf("failed to release message claim: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+// postgresUploadSessionColumns are the columns an upload session is stored in, in the order of scanPostgresUploadSession
+const postgresUploadSessionColumns = "upload_id, owner_id, kind, filename, content_type, length, upload_offset, staging_key, storage_upload_id, parts, pending_size, hash_state, expected_sha256, lease_until, created_at, updated_at, expires_at"
+
+// postgresUploadSessionRepository implements UploadSessionRepository using PostgreSQL with the same
+// lease conditions as the DynamoDB repository. Sessions past ExpiresAt are ignored, and purged
+// whenever a session is created
+type postgresUploadSessionRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresUploadSessionRepo creates a new PostgreSQL upload session repository
+func NewPostgresUploadSessionRepo(pool *pgxpool.Pool) interfaces.UploadSessionRepository {
+	return &postgresUploadSessionRepository{pool: pool}
+}
+
+// EnsureTableExists applies the migrations of the PostgreSQL repositories
+func (repo *postgresUploadSessionRepository) EnsureTableExists(ctx context.Context) error {
+	return MigratePostgres(ctx, repo.pool)
+}
+
+// Create stores a new upload session, generating its ID if not present
+func (repo *postgresUploadSessionRepository) Create(ctx context.Context, session *models.UploadSession) error {
+	if session.ID == "" {
+		session.ID = uuid.New().String()
+	}
+
+	if _, err := repo.pool.Exec(ctx, "DELETE FROM upload_sessions WHERE expires_at > 0 AND expires_at <= $1", time.Now().Unix()); err != nil {
+		return fmt.Errorf("failed to purge expired upload sessions: %w", err)
+	}
+
+	parts, err := json.Marshal(uploadSessionParts(session))
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload parts: %w", err)
+	}
+	_, err = repo.pool.Exec(ctx, "INSERT INTO upload_sessions ("+postgresUploadSessionColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)",
+		session.ID, session.OwnerID, string(session.Kind), session.Filename, session.ContentType, session.Length, session.Offset,
+		session.StagingKey, session.StorageUploadID, parts, session.PendingSize, session.HashState, session.ExpectedSHA256,
+		session.LeaseUntil, session.CreatedAt, session.UpdatedAt, session.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create upload session: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a live upload session by its ID
+func (repo *postgresUploadSessionRepository) GetByID(ctx context.Context, id string) (*models.UploadSession, error) {
+	row := repo.pool.QueryRow(ctx, "SELECT "+postgresUploadSessionColumns+" FROM upload_sessions WHERE upload_id = $1", id)
+	session, err := scanPostgresUploadSession(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload session: %w", err)
+	}
+	if session.IsExpired(time.Now()) {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// AcquireLease sets LeaseUntil only if the live session is at the expected offset and any
+// previous lease has run out
+func (repo *postgresUploadSessionRepository) AcquireLease(ctx context.Context, id string, offset int64, leaseUntil time.Time) (bool, error) {
+	now := time.Now()
+	result, err := repo.pool.Exec(ctx, `UPDATE upload_sessions SET lease_until = $1
+		WHERE upload_id = $2 AND upload_offset = $3 AND lease_until < $4 AND (expires_at = 0 OR expires_at > $5)`,
+		leaseUntil.UnixMilli(), id, offset, now.UnixMilli(), now.Unix())
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire upload session lease: %w", err)
+	}
+	return result.RowsAffected() == 1, nil
+}
+
+// Save overwrites the progress of the session and clears the lease, provided that the lease
+// recorded in session.LeaseUntil is still the one stored
+func (repo *postgresUploadSessionRepository) Save(ctx context.Context, session *models.UploadSession) error {
+	heldLease := session.LeaseUntil
+	session.LeaseUntil = 0
+
+	parts, err := json.Marshal(uploadSessionParts(session))
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload parts: %w", err)
+	}
+	result, err := repo.pool.Exec(ctx, `UPDATE upload_sessions SET upload_offset = $1, staging_key = $2, storage_upload_id = $3, parts = $4,
+		pending_size = $5, hash_state = $6, lease_until = 0, updated_at = $7, expires_at = $8
+		WHERE upload_id = $9 AND lease_until = $10`,
+		session.Offset, session.StagingKey, session.StorageUploadID, parts, session.PendingSize, session.HashState,
+		session.UpdatedAt, session.ExpiresAt, session.ID, heldLease,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save upload session: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("upload session lease was lost")
+	}
+	return nil
+}
+
+// Delete removes an upload session
+func (repo *postgresUploadSessionRepository) Delete(ctx context.Context, id string) error {
+	if _, err := repo.pool.Exec(ctx, "DELETE FROM upload_sessions WHERE upload_id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete upload session: %w", err)
+	}
+	return nil
+}
+
+// uploadSessionParts returns the parts of a session, empty rather than nil so they store as a JSON array
+func uploadSessionParts(session *models.UploadSession) []models.UploadPart {
+	if session.Parts == nil {
+		return []models.UploadPart{}
+	}
+	return session.Parts
+}
+
+// scanPostgresUploadSession reads an upload session selected with postgresUploadSessionColumns
+func scanPostgresUploadSession(row pgx.Row) (*models.UploadSession, error) {
+	var session models.UploadSession
+	var kind string
+	var parts []byte
+	err := row.Scan(
+		&session.ID, &session.OwnerID, &kind, &session.Filename, &session.ContentType, &session.Length, &session.Offset,
+		&session.StagingKey, &session.StorageUploadID, &parts, &session.PendingSize, &session.HashState, &session.ExpectedSHA256,
+		&session.LeaseUntil, &session.CreatedAt, &session.UpdatedAt, &session.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	session.Kind = models.UploadKind(kind)
+	if err := json.Unmarshal(parts, &session.Parts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal upload parts: %w", err)
+	}
+	if len(session.Parts) == 0 {
+		session.Parts = nil
+	}
+	return &session, nil
+}
@@ -0,0 +1,267 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/repository"
+)
+
+// newMigrationTables creates empty documents, hash guards, blob references and usage tables,
+// returning their names
+func newMigrationTables(t *testing.T, client *dynamodb.Client) (string, string, string, string) {
+	prefix := "migrations-" + uuid.NewString()[:8]
+	documents := repository.NewDynamoDBDocumentRepo(client, prefix+"-documents", prefix+"-blob-refs", prefix+"-outbox", prefix+"-hash-guards", prefix+"-usage")
+	require.NoError(t, documents.EnsureTableExists(context.Background()))
+	return prefix + "-documents", prefix + "-hash-guards", prefix + "-blob-refs", prefix + "-usage"
+}
+
+// putLegacyDocument stores a document the way versions before the sort indexes and hash guards did
+func putLegacyDocument(t *testing.T, client *dynamodb.Client, tableName string, ownerID int64) string {
+	id := uuid.NewString()
+	_, err := client.PutItem(context.Background(), &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]types.AttributeValue{
+			"DocumentID": &types.AttributeValueMemberS{Value: id},
+			"OwnerID":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", ownerID)},
+			"Filename":   &types.AttributeValueMemberS{Value: "Legacy.pdf"},
+			"HashSHA256": &types.AttributeValueMemberS{Value: "hash-" + id},
+			"ObjectKey":  &types.AttributeValueMemberS{Value: "objects/" + id},
+			"CreatedAt":  &types.AttributeValueMemberS{Value: "2024-01-01T00:00:00Z"},
+			"Version":    &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	require.NoError(t, err)
+	return id
+}
+
+func TestDynamoDBDocumentMigrator_BackfillsLegacyDocuments(t *testing.T) {
+	client := newDynamoDBClient(t)
+	ctx := context.Background()
+	tableName, hashGuardsTableName, blobRefsTableName, usageTableName := newMigrationTables(t, client)
+
+	ownerID := newContractOwner()
+	var ids []string
+	for i := 0; i < 12; i++ {
+		ids = append(ids, putLegacyDocument(t, client, tableName, ownerID))
+	}
+
+	migrator := repository.NewDynamoDBDocumentMigrator(client, tableName, hashGuardsTableName, blobRefsTableName, usageTableName)
+	pending, err := migrator.Pending(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0001_sort_indexes", "0002_hash_guards", "0003_purge_index", "0004_blob_ref_counts", "0005_trash_usage"}, pending)
+
+	applied, err := migrator.Migrate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0001_sort_indexes", "0002_hash_guards", "0003_purge_index", "0004_blob_ref_counts", "0005_trash_usage"}, applied)
+
+	for _, id := range ids {
+		item, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(tableName),
+			Key: map[string]types.AttributeValue{
+				"DocumentID": &types.AttributeValueMemberS{Value: id},
+				"OwnerID":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", ownerID)},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &types.AttributeValueMemberS{Value: "legacy.pdf"}, item.Item["FilenameSortKey"])
+
+		guard, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(hashGuardsTableName),
+			Key: map[string]types.AttributeValue{
+				"OwnerHash": &types.AttributeValueMemberS{Value: fmt.Sprintf("%d#hash-%s", ownerID, id)},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &types.AttributeValueMemberS{Value: id}, guard.Item["DocumentID"])
+
+		counter, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(blobRefsTableName),
+			Key: map[string]types.AttributeValue{
+				"ObjectKey": &types.AttributeValueMemberS{Value: "objects/" + id},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &types.AttributeValueMemberN{Value: "1"}, counter.Item["RefCount"])
+	}
+
+	applied, err = migrator.Migrate(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied, "applied migrations are not applied again")
+	pending, err = migrator.Pending(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	recorded, err := migrator.Applied(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0001_sort_indexes", "0002_hash_guards", "0003_purge_index", "0004_blob_ref_counts", "0005_trash_usage"}, recorded)
+}
+
+func TestDynamoDBMigrator_AddsAndRemovesIndexes(t *testing.T) {
+	client := newDynamoDBClient(t)
+	ctx := context.Background()
+	tableName, _, _, _ := newMigrationTables(t, client)
+
+	index := repository.DynamoDBIndex{
+		Definition: types.GlobalSecondaryIndex{
+			IndexName: aws.String("OwnerStatusIndex"),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("OwnerID"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("AuthenticationStatus"), KeyType: types.KeyTypeRange},
+			},
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+		},
+		Attributes: []types.AttributeDefinition{
+			{AttributeName: aws.String("AuthenticationStatus"), AttributeType: types.ScalarAttributeTypeS},
+		},
+	}
+	indexNames := func() []string {
+		table, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+		require.NoError(t, err)
+		var names []string
+		for _, index := range table.Table.GlobalSecondaryIndexes {
+			names = append(names, aws.ToString(index.IndexName))
+		}
+		return names
+	}
+
+	_, err := repository.NewDynamoDBMigrator(client, tableName, []repository.DynamoDBMigration{
+		{Version: "0001_add", AddIndexes: []repository.DynamoDBIndex{index}},
+	}).Migrate(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, indexNames(), "OwnerStatusIndex")
+
+	applied, err := repository.NewDynamoDBMigrator(client, tableName, []repository.DynamoDBMigration{
+		{Version: "0001_add", AddIndexes: []repository.DynamoDBIndex{index}},
+		{Version: "0002_remove", RemoveIndexes: []string{"OwnerStatusIndex"}},
+	}).Migrate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0002_remove"}, applied)
+	assert.NotContains(t, indexNames(), "OwnerStatusIndex")
+}
+
+func TestDynamoDBMigrator_OneRunnerAtATime(t *testing.T) {
+	client := newDynamoDBClient(t)
+	ctx := context.Background()
+	tableName, _, _, _ := newMigrationTables(t, client)
+	putLegacyDocument(t, client, tableName, newContractOwner())
+
+	var concurrentErr error
+	migrator := repository.NewDynamoDBMigrator(client, tableName, []repository.DynamoDBMigration{
+		{
+			Version: "0001_backfill",
+			Backfill: &repository.DynamoDBBackfill{
+				Update: func(ctx context.Context, item map[string]types.AttributeValue) (bool, error) {
+					_, concurrentErr = repository.NewDynamoDBMigrator(client, tableName, nil).Migrate(ctx)
+					return true, nil
+				},
+			},
+		},
+	})
+
+	applied, err := migrator.Migrate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0001_backfill"}, applied)
+	assert.ErrorIs(t, concurrentErr, repository.ErrMigrationsLocked)
+
+	applied, err = repository.NewDynamoDBMigrator(client, tableName, nil).Migrate(ctx)
+	require.NoError(t, err, "the lease is released once done")
+	assert.Empty(t, applied)
+}
+
+func TestDynamoDBDocumentRepository_InitializesUsageOfLegacyDocuments(t *testing.T) {
+	client := newDynamoDBClient(t)
+	ctx := context.Background()
+	prefix := "usage-" + uuid.NewString()[:8]
+	documents := repository.NewDynamoDBDocumentRepo(client, prefix+"-documents", prefix+"-blob-refs", prefix+"-outbox", prefix+"-hash-guards", prefix+"-usage")
+	require.NoError(t, documents.EnsureTableExists(ctx))
+
+	ownerID := newContractOwner()
+	legacyID := putLegacyDocument(t, client, prefix+"-documents", ownerID)
+	putLegacyDocument(t, client, prefix+"-documents", ownerID)
+
+	// The first write of the owner initializes the usage from the legacy documents
+	document := newContractDocument(ownerID, "file.pdf", time.Now())
+	require.NoError(t, documents.Create(ctx, document, models.Quota{MaxDocuments: 3}, nil))
+	assert.ErrorIs(t, documents.Create(ctx, newContractDocument(ownerID, "file.pdf", time.Now()), models.Quota{MaxDocuments: 3}, nil), interfaces.ErrQuotaExceeded)
+
+	_, err := documents.DeleteByID(ctx, legacyID, 0, nil)
+	require.NoError(t, err)
+	usage, err := documents.GetUsage(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, &models.OwnerUsage{OwnerID: ownerID, DocumentCount: 2, TotalBytes: 11}, usage)
+}
+
+func TestDynamoDBDocumentMigrator_ReleasesUsageOfLegacyTrash(t *testing.T) {
+	client := newDynamoDBClient(t)
+	ctx := context.Background()
+	prefix := "trash-usage-" + uuid.NewString()[:8]
+	documents := repository.NewDynamoDBDocumentRepo(client, prefix+"-documents", prefix+"-blob-refs", prefix+"-outbox", prefix+"-hash-guards", prefix+"-usage")
+	require.NoError(t, documents.EnsureTableExists(ctx))
+
+	// Documents trashed before trashing released their usage are counted like the live ones
+	ownerID := newContractOwner()
+	trashedID := putLegacyDocument(t, client, prefix+"-documents", ownerID)
+	putLegacyDocument(t, client, prefix+"-documents", ownerID)
+	_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(prefix + "-documents"),
+		Key: map[string]types.AttributeValue{
+			"DocumentID": &types.AttributeValueMemberS{Value: trashedID},
+			"OwnerID":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", ownerID)},
+		},
+		UpdateExpression: aws.String("SET DeletedAt = :deleted, SizeBytes = :size"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":deleted": &types.AttributeValueMemberS{Value: "2024-01-02T00:00:00Z"},
+			":size":    &types.AttributeValueMemberN{Value: "5"},
+		},
+	})
+	require.NoError(t, err)
+	usage, err := documents.GetUsage(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, &models.OwnerUsage{OwnerID: ownerID, DocumentCount: 2, TotalBytes: 5}, usage)
+
+	// Owners with no counters yet have their trashed documents only marked
+	untouchedOwnerID := newContractOwner()
+	untouchedTrashedID := putLegacyDocument(t, client, prefix+"-documents", untouchedOwnerID)
+	_, err = client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(prefix + "-documents"),
+		Key: map[string]types.AttributeValue{
+			"DocumentID": &types.AttributeValueMemberS{Value: untouchedTrashedID},
+			"OwnerID":    &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", untouchedOwnerID)},
+		},
+		UpdateExpression: aws.String("SET DeletedAt = :deleted"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":deleted": &types.AttributeValueMemberS{Value: "2024-01-02T00:00:00Z"},
+		},
+	})
+	require.NoError(t, err)
+
+	migrator := repository.NewDynamoDBDocumentMigrator(client, prefix+"-documents", prefix+"-hash-guards", prefix+"-blob-refs", prefix+"-usage")
+	_, err = migrator.Migrate(ctx)
+	require.NoError(t, err)
+
+	usage, err = documents.GetUsage(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, &models.OwnerUsage{OwnerID: ownerID, DocumentCount: 1}, usage)
+	usage, err = documents.GetUsage(ctx, untouchedOwnerID)
+	require.NoError(t, err)
+	assert.Equal(t, &models.OwnerUsage{OwnerID: untouchedOwnerID}, usage)
+
+	// Released documents are not released again when purged
+	_, err = documents.DeleteByID(ctx, trashedID, 0, nil)
+	require.NoError(t, err)
+	usage, err = documents.GetUsage(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, &models.OwnerUsage{OwnerID: ownerID, DocumentCount: 1}, usage)
+}
@@ -0,0 +1,512 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/events"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/repository"
+)
+
+func newMemoryDocument(ownerID int64, hash string, createdAt time.Time) *models.Document {
+	return &models.Document{
+		Filename:   "file.pdf",
+		HashSHA256: hash,
+		ObjectKey:  "objects/" + hash,
+		OwnerID:    ownerID,
+		CreatedAt:  createdAt,
+	}
+}
+
+func TestMemoryDocumentRepository_ListIsMostRecentFirstAndPaginated(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryDocumentRepo(repository.NewMemoryDocumentStore())
+	base := time.Now().Add(-time.Hour)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, repo.Create(ctx, newMemoryDocument(1, fmt.Sprintf("hash-%d", i), base.Add(time.Duration(i)*time.Minute)), models.Quota{}, nil))
+	}
+	require.NoError(t, repo.Create(ctx, newMemoryDocument(2, "other-owner", base), models.Quota{}, nil))
+
+	page, total, err := repo.List(ctx, 1, 2, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	require.Len(t, page, 2)
+	assert.Equal(t, "hash-3", page[0].HashSHA256)
+	assert.Equal(t, "hash-2", page[1].HashSHA256)
+
+	page, total, err = repo.List(ctx, 1, 10, 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.NotNil(t, page)
+	assert.Empty(t, page)
+}
+
+var newestFirst = models.DocumentSort{Field: models.DocumentSortCreatedAt, Order: models.SortOrderDesc}
+
+func TestMemoryDocumentRepository_ListPageFollowsPositions(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryDocumentRepo(repository.NewMemoryDocumentStore())
+	base := time.Now().Add(-time.Hour)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, repo.Create(ctx, newMemoryDocument(1, fmt.Sprintf("hash-%d", i), base.Add(time.Duration(i)*time.Minute)), models.Quota{}, nil))
+	}
+	require.NoError(t, repo.Create(ctx, newMemoryDocument(2, "other-owner", base), models.Quota{}, nil))
+
+	page, position, err := repo.ListPage(ctx, 1, newestFirst, models.DocumentFilter{}, 2, "")
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "hash-4", page[0].HashSHA256)
+	assert.Equal(t, "hash-3", page[1].HashSHA256)
+	require.NotEmpty(t, position)
+
+	// A document created meanwhile does not shift the following pages
+	require.NoError(t, repo.Create(ctx, newMemoryDocument(1, "newest", time.Now()), models.Quota{}, nil))
+
+	page, position, err = repo.ListPage(ctx, 1, newestFirst, models.DocumentFilter{}, 2, position)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "hash-2", page[0].HashSHA256)
+	assert.Equal(t, "hash-1", page[1].HashSHA256)
+
+	page, position, err = repo.ListPage(ctx, 1, newestFirst, models.DocumentFilter{}, 2, position)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "hash-0", page[0].HashSHA256)
+	assert.Empty(t, position, "the last page has no position")
+
+	count, err := repo.CountByOwner(ctx, 1, models.DocumentFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), count)
+}
+
+func TestMemoryDocumentRepository_ListPageSorts(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryDocumentRepo(repository.NewMemoryDocumentStore())
+	base := time.Now().Add(-time.Hour)
+
+	for i, file := range []struct {
+		name string
+		size int64
+	}{{"b.pdf", 30}, {"C.pdf", 10}, {"a.pdf", 20}, {"d.pdf", 20}} {
+		document := newMemoryDocument(1, file.name, base.Add(time.Duration(i)*time.Minute))
+		document.Filename = file.name
+		document.SizeBytes = file.size
+		require.NoError(t, repo.Create(ctx, document, models.Quota{}, nil))
+	}
+
+	names := func(sort models.DocumentSort, limit int) []string {
+		var names []string
+		position := ""
+		for {
+			page, next, err := repo.ListPage(ctx, 1, sort, models.DocumentFilter{}, limit, position)
+			require.NoError(t, err)
+			for _, document := range page {
+				names = append(names, document.Filename)
+			}
+			if next == "" {
+				return names
+			}
+			position = next
+		}
+	}
+
+	assert.Equal(t, []string{"a.pdf", "b.pdf", "C.pdf", "d.pdf"}, names(models.DocumentSort{Field: models.DocumentSortFilename, Order: models.SortOrderAsc}, 3), "filenames sort ignoring case")
+	assert.Equal(t, []string{"d.pdf", "C.pdf", "b.pdf", "a.pdf"}, names(models.DocumentSort{Field: models.DocumentSortFilename, Order: models.SortOrderDesc}, 1))
+	bySize := names(models.DocumentSort{Field: models.DocumentSortSize, Order: models.SortOrderAsc}, 1)
+	assert.Equal(t, "C.pdf", bySize[0])
+	assert.ElementsMatch(t, []string{"a.pdf", "d.pdf"}, bySize[1:3], "equal sizes are both listed once")
+	assert.Equal(t, "b.pdf", bySize[3])
+	assert.Equal(t, []string{"b.pdf", "C.pdf", "a.pdf", "d.pdf"}, names(models.DocumentSort{Field: models.DocumentSortCreatedAt, Order: models.SortOrderAsc}, 2))
+}
+
+func TestMemoryDocumentRepository_ReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryDocumentRepo(repository.NewMemoryDocumentStore())
+	document := newMemoryDocument(1, "hash", time.Time{})
+	require.NoError(t, repo.Create(ctx, document, models.Quota{}, nil))
+	assert.NotEmpty(t, document.ID)
+	assert.False(t, document.CreatedAt.IsZero())
+
+	fetched, err := repo.GetByID(ctx, document.ID)
+	require.NoError(t, err)
+	fetched.Filename = "changed.pdf"
+
+	again, err := repo.GetByID(ctx, document.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "file.pdf", again.Filename)
+
+	assert.Error(t, repo.Create(ctx, document, models.Quota{}, nil), "creating an existing ID must fail")
+}
+
+func TestMemoryDocumentRepository_BlobReferences(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryDocumentStore()
+	repo := repository.NewMemoryDocumentRepo(store)
+	blobRefs := repository.NewMemoryBlobReferenceRepo(store)
+
+	first := newMemoryDocument(1, "shared", time.Time{})
+	second := newMemoryDocument(2, "shared", time.Time{})
+	require.NoError(t, repo.Create(ctx, first, models.Quota{}, nil))
+	require.NoError(t, repo.Create(ctx, second, models.Quota{}, nil))
+
+	_, err := repo.DeleteByID(ctx, first.ID, 0, nil)
+	require.NoError(t, err)
+	released, err := blobRefs.ReleaseIfUnreferenced(ctx, "objects/shared")
+	require.NoError(t, err)
+	assert.False(t, released, "the object is still referenced by the second owner")
+
+	trashed, err := repo.TrashAllByOwnerID(ctx, 2, time.Now(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, trashed)
+	released, err = blobRefs.ReleaseIfUnreferenced(ctx, "objects/shared")
+	require.NoError(t, err)
+	assert.False(t, released, "trashed documents still reference their object")
+
+	_, err = repo.DeleteByID(ctx, second.ID, 0, nil)
+	require.NoError(t, err)
+	released, err = blobRefs.ReleaseIfUnreferenced(ctx, "objects/shared")
+	require.NoError(t, err)
+	assert.True(t, released)
+}
+
+func TestMemoryDocumentRepository_UpdateAuthenticationStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryDocumentRepo(repository.NewMemoryDocumentStore())
+	document := newMemoryDocument(1, "hash", time.Time{})
+	require.NoError(t, repo.Create(ctx, document, models.Quota{}, nil))
+
+	require.NoError(t, repo.UpdateAuthenticationStatus(ctx, document.ID, 0, models.AuthenticationStatusAuthenticated, nil))
+	fetched, err := repo.GetByID(ctx, document.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AuthenticationStatusAuthenticated, fetched.AuthenticationStatus)
+
+	assert.Error(t, repo.UpdateAuthenticationStatus(ctx, "missing", 0, models.AuthenticationStatusAuthenticated, nil))
+}
+
+func TestMemoryDocumentRepository_WritesCheckVersions(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryDocumentRepo(repository.NewMemoryDocumentStore())
+	document := newMemoryDocument(1, "hash", time.Time{})
+	require.NoError(t, repo.Create(ctx, document, models.Quota{}, nil))
+	assert.Equal(t, int64(1), document.Version)
+
+	require.NoError(t, repo.UpdateAuthenticationStatus(ctx, document.ID, 1, models.AuthenticationStatusAuthenticating, nil))
+	err := repo.UpdateAuthenticationStatus(ctx, document.ID, 1, models.AuthenticationStatusAuthenticated, nil)
+	assert.ErrorIs(t, err, interfaces.ErrVersionConflict, "the first update moved the document to version 2")
+
+	deleted, err := repo.DeleteByID(ctx, document.ID, 1, nil)
+	assert.ErrorIs(t, err, interfaces.ErrVersionConflict)
+	assert.Nil(t, deleted)
+
+	deleted, err = repo.DeleteByID(ctx, document.ID, 2, nil)
+	require.NoError(t, err)
+	assert.Equal(t, models.AuthenticationStatusAuthenticating, deleted.AuthenticationStatus)
+	assert.Equal(t, int64(2), deleted.Version)
+}
+
+func TestMemoryDocumentRepository_CreateRejectsDuplicateContent(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryDocumentRepo(repository.NewMemoryDocumentStore())
+	first := newMemoryDocument(1, "hash", time.Time{})
+	require.NoError(t, repo.Create(ctx, first, models.Quota{}, nil))
+
+	err := repo.Create(ctx, newMemoryDocument(1, "hash", time.Time{}), models.Quota{}, nil)
+	assert.ErrorIs(t, err, interfaces.ErrDuplicateDocument)
+	require.NoError(t, repo.Create(ctx, newMemoryDocument(2, "hash", time.Time{}), models.Quota{}, nil), "another owner may have the same content")
+
+	found, err := repo.FindByHashAndOwnerID(ctx, "hash", 1)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, found.ID)
+
+	_, err = repo.DeleteByID(ctx, first.ID, 0, nil)
+	require.NoError(t, err)
+	assert.NoError(t, repo.Create(ctx, newMemoryDocument(1, "hash", time.Time{}), models.Quota{}, nil), "the content can be uploaded again once deleted")
+}
+
+func TestMemoryProcessedMessageRepository_Claim(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryProcessedMessageRepository()
+
+	first := models.NewProcessedMessageClaim("msg-1", "test", time.Minute)
+	status, err := repo.Claim(ctx, first)
+	require.NoError(t, err)
+	assert.Equal(t, interfaces.ClaimAcquired, status)
+
+	second := models.NewProcessedMessageClaim("msg-1", "test", time.Minute)
+	status, err = repo.Claim(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, interfaces.ClaimInProgress, status, "a live lease blocks other consumers")
+
+	assert.Error(t, repo.Complete(ctx, second), "only the claim holder can complete")
+	require.NoError(t, repo.Release(ctx, second))
+
+	require.NoError(t, repo.Complete(ctx, first))
+	assert.Equal(t, models.ProcessedMessageCompleted, first.Status)
+	status, err = repo.Claim(ctx, second)
+	require.NoError(t, err)
+	assert.Equal(t, interfaces.ClaimCompleted, status)
+
+	assert.Error(t, repo.Complete(ctx, nil))
+}
+
+func TestMemoryProcessedMessageRepository_ReleaseAndExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryProcessedMessageRepository()
+
+	claim := models.NewProcessedMessageClaim("msg-1", "test", time.Minute)
+	_, err := repo.Claim(ctx, claim)
+	require.NoError(t, err)
+	require.NoError(t, repo.Release(ctx, claim))
+
+	retry := models.NewProcessedMessageClaim("msg-1", "test", -time.Second)
+	status, err := repo.Claim(ctx, retry)
+	require.NoError(t, err)
+	assert.Equal(t, interfaces.ClaimAcquired, status, "a released message can be claimed again")
+
+	takeover := models.NewProcessedMessageClaim("msg-1", "test", time.Minute)
+	status, err = repo.Claim(ctx, takeover)
+	require.NoError(t, err)
+	assert.Equal(t, interfaces.ClaimAcquired, status, "an expired lease can be taken over")
+
+	require.NoError(t, repo.Release(ctx, retry))
+	status, err = repo.Claim(ctx, models.NewProcessedMessageClaim("msg-1", "test", time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, interfaces.ClaimInProgress, status, "a stale holder cannot release the new claim")
+
+	past := models.NewProcessedMessageClaim("msg-3", "test", time.Minute)
+	past.Status = models.ProcessedMessageCompleted
+	past.TTL = time.Now().Add(-time.Second).Unix()
+	_, err = repo.Claim(ctx, past)
+	require.NoError(t, err)
+	status, err = repo.Claim(ctx, models.NewProcessedMessageClaim("msg-3", "test", time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, interfaces.ClaimAcquired, status, "records past their TTL are forgotten")
+}
+
+func TestMemoryUploadSessionRepository_Lease(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryUploadSessionRepo()
+	session := models.NewUploadSession(1, "file.pdf", "application/pdf", 10, time.Hour)
+	require.NoError(t, repo.Create(ctx, session))
+
+	acquired, err := repo.AcquireLease(ctx, session.ID, 0, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = repo.AcquireLease(ctx, session.ID, 0, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, acquired, "a second writer must wait for the lease")
+
+	stored, err := repo.GetByID(ctx, session.ID)
+	require.NoError(t, err)
+	stored.Offset = 5
+	require.NoError(t, repo.Save(ctx, stored))
+
+	acquired, err = repo.AcquireLease(ctx, session.ID, 0, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, acquired, "a stale offset must not acquire the lease")
+
+	stale := *stored
+	stale.LeaseUntil = 42
+	assert.Error(t, repo.Save(ctx, &stale), "saving without the lease must fail")
+}
+
+func TestMemoryUploadSessionRepository_ExpiredSessionsAreGone(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryUploadSessionRepo()
+	session := models.NewUploadSession(1, "file.pdf", "application/pdf", 10, time.Hour)
+	session.ExpiresAt = time.Now().Add(-time.Second).Unix()
+	require.NoError(t, repo.Create(ctx, session))
+
+	stored, err := repo.GetByID(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func newOutboxMessage(t *testing.T, id string) *models.OutboxMessage {
+	event, err := events.NewEnvelope("test.event", 1, id, map[string]bool{"ok": true})
+	require.NoError(t, err)
+	message, err := models.NewOutboxMessage(event)
+	require.NoError(t, err)
+	return message
+}
+
+// outboxOf returns an OutboxFunc storing the given messages
+func outboxOf(messages ...*models.OutboxMessage) interfaces.OutboxFunc {
+	return func([]*models.Document) ([]*models.OutboxMessage, error) {
+		return messages, nil
+	}
+}
+
+func TestMemoryOutboxRepository_StatusChangeAndMessagesAreStoredTogether(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryDocumentStore()
+	repo := repository.NewMemoryDocumentRepo(store)
+	outbox := repository.NewMemoryOutboxRepo(store)
+	document := newMemoryDocument(1, "hash", time.Time{})
+	require.NoError(t, repo.Create(ctx, document, models.Quota{}, nil))
+
+	message := newOutboxMessage(t, "msg-1")
+	require.NoError(t, repo.UpdateAuthenticationStatus(ctx, document.ID, 0, models.AuthenticationStatusAuthenticating, outboxOf(message)))
+
+	fetched, err := repo.GetByID(ctx, document.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AuthenticationStatusAuthenticating, fetched.AuthenticationStatus)
+
+	pending, err := outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "msg-1", pending[0].ID)
+	event, err := pending[0].Event()
+	require.NoError(t, err)
+	assert.Equal(t, "test.event", event.Type)
+	assert.JSONEq(t, `{"ok":true}`, string(event.Data))
+
+	duplicate := newOutboxMessage(t, "msg-1")
+	assert.Error(t, repo.UpdateAuthenticationStatus(ctx, document.ID, 0, models.AuthenticationStatusAuthenticated, outboxOf(duplicate)))
+	fetched, err = repo.GetByID(ctx, document.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.AuthenticationStatusAuthenticating, fetched.AuthenticationStatus, "a rejected outbox write must not change the status")
+
+	assert.Error(t, repo.UpdateAuthenticationStatus(ctx, "missing", 0, models.AuthenticationStatusAuthenticating, outboxOf(newOutboxMessage(t, ""))))
+	pending, err = outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1, "no message is stored for a missing document")
+}
+
+func TestMemoryOutboxRepository_DocumentWritesStoreTheirMessages(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryDocumentStore()
+	repo := repository.NewMemoryDocumentRepo(store)
+	outbox := repository.NewMemoryOutboxRepo(store)
+
+	var written [][]string
+	record := func(documents []*models.Document) ([]*models.OutboxMessage, error) {
+		ids := make([]string, 0, len(documents))
+		for _, document := range documents {
+			ids = append(ids, document.ID)
+		}
+		written = append(written, ids)
+		return []*models.OutboxMessage{newOutboxMessage(t, "")}, nil
+	}
+
+	first := newMemoryDocument(1, "first", time.Time{})
+	second := newMemoryDocument(1, "second", time.Time{})
+	require.NoError(t, repo.Create(ctx, first, models.Quota{}, record))
+	require.NoError(t, repo.Create(ctx, second, models.Quota{}, record))
+	assert.NotEmpty(t, written[0][0], "the outbox sees the generated ID")
+
+	_, err := repo.DeleteByID(ctx, first.ID, 0, record)
+	require.NoError(t, err)
+	trashed, err := repo.TrashAllByOwnerID(ctx, 1, time.Now(), record)
+	require.NoError(t, err)
+	assert.Equal(t, 1, trashed)
+	assert.Equal(t, [][]string{{first.ID}, {second.ID}, {first.ID}, {second.ID}}, written)
+
+	pending, err := outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, pending, 4)
+
+	failing := func([]*models.Document) ([]*models.OutboxMessage, error) {
+		return nil, fmt.Errorf("cannot build event")
+	}
+	assert.Error(t, repo.Create(ctx, newMemoryDocument(1, "third", time.Time{}), models.Quota{}, failing))
+	_, total, err := repo.List(ctx, 1, 10, 0)
+	require.NoError(t, err)
+	assert.Zero(t, total, "a write whose events cannot be built is not applied")
+}
+
+func TestMemoryOutboxRepository_PendingLifecycle(t *testing.T) {
+	ctx := context.Background()
+	outbox := repository.NewMemoryOutboxRepo(repository.NewMemoryDocumentStore())
+
+	first := newOutboxMessage(t, "")
+	second := newOutboxMessage(t, "")
+	second.CreatedAt = first.CreatedAt + 1
+	require.NoError(t, outbox.Enqueue(ctx, second, first))
+
+	pending, err := outbox.ListPending(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, first.ID, pending[0].ID, "the oldest message comes first")
+
+	require.NoError(t, outbox.MarkFailed(ctx, first.ID, fmt.Errorf("broker down"), time.Now().Add(time.Hour)))
+	pending, err = outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1, "a message backing off is not listed")
+	assert.Equal(t, second.ID, pending[0].ID)
+
+	require.NoError(t, outbox.MarkFailed(ctx, first.ID, fmt.Errorf("broker down"), time.Now()))
+	pending, err = outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.Equal(t, 2, pending[0].Attempts)
+	assert.Equal(t, "broker down", pending[0].LastError)
+
+	require.NoError(t, outbox.MarkParked(ctx, second.ID, fmt.Errorf("no route")))
+	pending, err = outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1, "a parked message is no longer pending")
+	assert.Equal(t, first.ID, pending[0].ID)
+
+	require.NoError(t, outbox.MarkSent(ctx, first.ID))
+	pending, err = outbox.ListPending(ctx, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	assert.Error(t, outbox.MarkSent(ctx, first.ID))
+}
+
+func TestMemoryDocumentRepository_ListPageFilters(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryDocumentRepo(repository.NewMemoryDocumentStore())
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, file := range []struct {
+		name     string
+		mimeType string
+		status   models.AuthenticationStatus
+	}{
+		{"Passport.png", "image/png", models.AuthenticationStatusAuthenticated},
+		{"passport.pdf", "application/pdf", models.AuthenticationStatusAuthenticated},
+		{"diploma.jpg", "IMAGE/JPEG", models.AuthenticationStatusUnauthenticated},
+		{"old-passport.png", "image/png", models.AuthenticationStatusAuthenticated},
+	} {
+		document := newMemoryDocument(1, file.name, base.AddDate(0, 0, -i))
+		document.Filename = file.name
+		document.MimeType = file.mimeType
+		document.AuthenticationStatus = file.status
+		require.NoError(t, repo.Create(ctx, document, models.Quota{}, nil))
+	}
+
+	names := func(filter models.DocumentFilter) []string {
+		page, _, err := repo.ListPage(ctx, 1, newestFirst, filter, 10, "")
+		require.NoError(t, err)
+		count, err := repo.CountByOwner(ctx, 1, filter)
+		require.NoError(t, err)
+
+		var names []string
+		for _, document := range page {
+			names = append(names, document.Filename)
+		}
+		assert.Equal(t, int64(len(names)), count, "the count follows the filter")
+		return names
+	}
+
+	assert.Equal(t, []string{"Passport.png", "diploma.jpg", "old-passport.png"}, names(models.DocumentFilter{MimeFamily: "Image"}))
+	assert.Equal(t, []string{"Passport.png", "passport.pdf"}, names(models.DocumentFilter{FilenameContains: "PASS", CreatedFrom: base.AddDate(0, 0, -1)}))
+	assert.Equal(t, []string{"old-passport.png"}, names(models.DocumentFilter{
+		AuthenticationStatus: models.AuthenticationStatusAuthenticated,
+		MimeFamily:           "image",
+		CreatedTo:            base.AddDate(0, 0, -2),
+	}))
+}
@@ -0,0 +1,579 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/config"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/repository"
+)
+
+// The contract suites run against the memory repositories, and against DynamoDB and PostgreSQL
+// when these variables point to a test instance, such as DynamoDB Local and a disposable database
+const (
+	dynamoDBEndpointEnv = "TEST_DYNAMODB_ENDPOINT"
+	postgresURLEnv      = "TEST_POSTGRES_URL"
+)
+
+// documentBackend is the set of repositories sharing one document store
+type documentBackend struct {
+	documents interfaces.DocumentRepository
+	blobRefs  interfaces.BlobReferenceRepository
+}
+
+// contractOwners hands out owner IDs unused by previous runs against the same database
+var contractOwners = time.Now().UnixNano() / 1000
+
+func newContractOwner() int64 {
+	return atomic.AddInt64(&contractOwners, 1)
+}
+
+func newContractDocument(ownerID int64, filename string, createdAt time.Time) *models.Document {
+	hash := uuid.NewString()
+	return &models.Document{
+		Filename:             filename,
+		MimeType:             "application/pdf",
+		SizeBytes:            1,
+		HashSHA256:           hash,
+		ObjectKey:            "objects/" + hash,
+		OwnerID:              ownerID,
+		AuthenticationStatus: models.AuthenticationStatusUnauthenticated,
+		CreatedAt:            createdAt,
+	}
+}
+
+// documentIDs returns the IDs of documents
+func documentIDs(documents []*models.Document) []string {
+	ids := make([]string, 0, len(documents))
+	for _, document := range documents {
+		ids = append(ids, document.ID)
+	}
+	return ids
+}
+
+func newDynamoDBClient(t *testing.T) *dynamodb.Client {
+	endpoint := os.Getenv(dynamoDBEndpointEnv)
+	if endpoint == "" {
+		t.Skipf("%s is not set", dynamoDBEndpointEnv)
+	}
+	client, err := config.NewDynamoDBClient(context.Background(), "test", "test", "us-east-1", endpoint)
+	require.NoError(t, err)
+	return client
+}
+
+func newPostgresPool(t *testing.T) *pgxpool.Pool {
+	url := os.Getenv(postgresURLEnv)
+	if url == "" {
+		t.Skipf("%s is not set", postgresURLEnv)
+	}
+	pool, err := config.NewPostgresPool(context.Background(), url)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestDocumentRepositoryContract_Memory(t *testing.T) {
+	runDocumentRepositoryContract(t, func(t *testing.T) documentBackend {
+		store := repository.NewMemoryDocumentStore()
+		return documentBackend{
+			documents: repository.NewMemoryDocumentRepo(store),
+			blobRefs:  repository.NewMemoryBlobReferenceRepo(store),
+		}
+	})
+}
+
+func TestDocumentRepositoryContract_DynamoDB(t *testing.T) {
+	client := newDynamoDBClient(t)
+	runDocumentRepositoryContract(t, func(t *testing.T) documentBackend {
+		prefix := "contract-" + uuid.NewString()[:8]
+		documents := repository.NewDynamoDBDocumentRepo(client, prefix+"-documents", prefix+"-blob-refs", prefix+"-outbox", prefix+"-hash-guards", prefix+"-usage")
+		require.NoError(t, documents.EnsureTableExists(context.Background()))
+		return documentBackend{
+			documents: documents,
+			blobRefs:  repository.NewDynamoDBBlobReferenceRepo(client, prefix+"-documents", prefix+"-blob-refs"),
+		}
+	})
+}
+
+func TestDocumentRepositoryContract_Postgres(t *testing.T) {
+	pool := newPostgresPool(t)
+	runDocumentRepositoryContract(t, func(t *testing.T) documentBackend {
+		documents := repository.NewPostgresDocumentRepo(pool)
+		require.NoError(t, documents.EnsureTableExists(context.Background()))
+		return documentBackend{
+			documents: documents,
+			blobRefs:  repository.NewPostgresBlobReferenceRepo(pool),
+		}
+	})
+}
+
+// runDocumentRepositoryContract checks the behaviour every DocumentRepository shares. Each case
+// uses owners of its own, so the backends may keep the data of previous runs
+func runDocumentRepositoryContract(t *testing.T, newBackend func(t *testing.T) documentBackend) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newBackend(t).documents
+		document := newContractDocument(newContractOwner(), "file.pdf", time.Now())
+		require.NoError(t, repo.Create(ctx, document, models.Quota{}, nil))
+		assert.NotEmpty(t, document.ID)
+		assert.Equal(t, int64(1), document.Version)
+
+		fetched, err := repo.GetByID(ctx, document.ID)
+		require.NoError(t, err)
+		require.NotNil(t, fetched)
+		assert.Equal(t, document.Filename, fetched.Filename)
+		assert.Equal(t, document.HashSHA256, fetched.HashSHA256)
+		assert.Equal(t, document.OwnerID, fetched.OwnerID)
+		assert.True(t, document.CreatedAt.Sub(fetched.CreatedAt).Abs() < time.Millisecond)
+
+		missing, err := repo.GetByID(ctx, uuid.NewString())
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("CreateRejectsDuplicateContent", func(t *testing.T) {
+		repo := newBackend(t).documents
+		ownerID := newContractOwner()
+		first := newContractDocument(ownerID, "file.pdf", time.Now())
+		require.NoError(t, repo.Create(ctx, first, models.Quota{}, nil))
+
+		duplicate := newContractDocument(ownerID, "copy.pdf", time.Now())
+		duplicate.HashSHA256 = first.HashSHA256
+		assert.ErrorIs(t, repo.Create(ctx, duplicate, models.Quota{}, nil), interfaces.ErrDuplicateDocument)
+
+		other := newContractDocument(newContractOwner(), "file.pdf", time.Now())
+		other.HashSHA256 = first.HashSHA256
+		require.NoError(t, repo.Create(ctx, other, models.Quota{}, nil), "another owner may have the same content")
+
+		found, err := repo.FindByHashAndOwnerID(ctx, first.HashSHA256, ownerID)
+		require.NoError(t, err)
+		require.NotNil(t, found)
+		assert.Equal(t, first.ID, found.ID)
+
+		_, err = repo.DeleteByID(ctx, first.ID, 0, nil)
+		require.NoError(t, err)
+		duplicate.ID = ""
+		assert.NoError(t, repo.Create(ctx, duplicate, models.Quota{}, nil), "the content can be uploaded again once deleted")
+	})
+
+	t.Run("WritesCheckVersions", func(t *testing.T) {
+		repo := newBackend(t).documents
+		document := newContractDocument(newContractOwner(), "file.pdf", time.Now())
+		require.NoError(t, repo.Create(ctx, document, models.Quota{}, nil))
+
+		require.NoError(t, repo.UpdateAuthenticationStatus(ctx, document.ID, 1, models.AuthenticationStatusAuthenticating, nil))
+		err := repo.UpdateAuthenticationStatus(ctx, document.ID, 1, models.AuthenticationStatusAuthenticated, nil)
+		assert.ErrorIs(t, err, interfaces.ErrVersionConflict)
+		assert.ErrorIs(t, repo.UpdateAuthenticationStatus(ctx, uuid.NewString(), 0, models.AuthenticationStatusAuthenticated, nil), interfaces.ErrDocumentNotFound)
+
+		deleted, err := repo.DeleteByID(ctx, document.ID, 1, nil)
+		assert.ErrorIs(t, err, interfaces.ErrVersionConflict)
+		assert.Nil(t, deleted)
+
+		deleted, err = repo.DeleteByID(ctx, document.ID, 2, nil)
+		require.NoError(t, err)
+		require.NotNil(t, deleted)
+		assert.Equal(t, models.AuthenticationStatusAuthenticating, deleted.AuthenticationStatus)
+
+		deleted, err = repo.DeleteByID(ctx, document.ID, 0, nil)
+		require.NoError(t, err)
+		assert.Nil(t, deleted)
+	})
+
+	t.Run("TrashAndRestore", func(t *testing.T) {
+		repo := newBackend(t).documents
+		ownerID := newContractOwner()
+		document := newContractDocument(ownerID, "file.pdf", time.Now())
+		require.NoError(t, repo.Create(ctx, document, models.Quota{}, nil))
+
+		purgeAt := time.Now().Add(-time.Minute)
+		_, err := repo.Trash(ctx, document.ID, 2, purgeAt, nil)
+		assert.ErrorIs(t, err, interfaces.ErrVersionConflict)
+		trashed, err := repo.Trash(ctx, document.ID, 1, purgeAt, nil)
+		require.NoError(t, err)
+		require.NotNil(t, trashed)
+		assert.True(t, trashed.IsTrashed())
+		assert.Equal(t, int64(2), trashed.Version)
+
+		again, err := repo.Trash(ctx, document.ID, 0, purgeAt, nil)
+		require.NoError(t, err)
+		assert.Nil(t, again, "a document is trashed once")
+
+		_, total, err := repo.List(ctx, ownerID, 10, 0)
+		require.NoError(t, err)
+		assert.Zero(t, total, "trashed documents are not listed")
+		documents, _, err := repo.ListPage(ctx, ownerID, models.DocumentSort{}.WithDefaults(), models.DocumentFilter{Trashed: true}, 10, "")
+		require.NoError(t, err)
+		require.Len(t, documents, 1)
+		assert.Equal(t, document.ID, documents[0].ID)
+
+		expired, _, err := repo.ListExpired(ctx, time.Now(), 1000, "")
+		require.NoError(t, err)
+		assert.Contains(t, documentIDs(expired), document.ID)
+
+		restored, err := repo.Restore(ctx, document.ID, 2, models.Quota{}, nil)
+		require.NoError(t, err)
+		require.NotNil(t, restored)
+		assert.False(t, restored.IsTrashed())
+		again, err = repo.Restore(ctx, document.ID, 0, models.Quota{}, nil)
+		require.NoError(t, err)
+		assert.Nil(t, again, "only trashed documents are restored")
+
+		expired, _, err = repo.ListExpired(ctx, time.Now(), 1000, "")
+		require.NoError(t, err)
+		assert.NotContains(t, documentIDs(expired), document.ID)
+		_, total, err = repo.List(ctx, ownerID, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+	})
+
+	t.Run("ListExpiredFollowsPositions", func(t *testing.T) {
+		repo := newBackend(t).documents
+		ownerID := newContractOwner()
+		base := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+		var due []string
+		for i := 0; i < 5; i++ {
+			document := newContractDocument(ownerID, fmt.Sprintf("file-%d.pdf", i), base)
+			require.NoError(t, repo.Create(ctx, document, models.Quota{}, nil))
+			_, err := repo.Trash(ctx, document.ID, 0, base.Add(time.Duration(i)*time.Second), nil)
+			require.NoError(t, err)
+			due = append(due, document.ID)
+		}
+
+		// Other expired documents of the same database may come in between
+		var listed []string
+		position := ""
+		for pages := 0; pages < 1000; pages++ {
+			page, next, err := repo.ListExpired(ctx, base.Add(5*time.Second), 2, position)
+			require.NoError(t, err)
+			for _, id := range documentIDs(page) {
+				if slices.Contains(due, id) {
+					listed = append(listed, id)
+				}
+			}
+			if next == "" {
+				break
+			}
+			position = next
+		}
+		assert.Equal(t, due, listed, "every page goes on after the previous one, those due first coming first")
+	})
+
+	t.Run("TrashAllCoversEveryPage", func(t *testing.T) {
+		repo := newBackend(t).documents
+		ownerID := newContractOwner()
+		base := time.Now().Add(-time.Hour)
+		for i := 0; i < 30; i++ {
+			require.NoError(t, repo.Create(ctx, newContractDocument(ownerID, "file.pdf", base.Add(time.Duration(i)*time.Second)), models.Quota{}, nil))
+		}
+
+		trashed, err := repo.TrashAllByOwnerID(ctx, ownerID, time.Now().Add(time.Hour), nil)
+		require.NoError(t, err)
+		assert.Equal(t, 30, trashed, "documents beyond one transaction are trashed too")
+		_, total, err := repo.List(ctx, ownerID, 10, 0)
+		require.NoError(t, err)
+		assert.Zero(t, total)
+	})
+
+	t.Run("ListPageFollowsPositions", func(t *testing.T) {
+		repo := newBackend(t).documents
+		ownerID := newContractOwner()
+		base := time.Now().Add(-time.Hour)
+		for i := 0; i < 5; i++ {
+			require.NoError(t, repo.Create(ctx, newContractDocument(ownerID, fmt.Sprintf("file-%d.pdf", i), base.Add(time.Duration(i)*time.Minute)), models.Quota{}, nil))
+		}
+		require.NoError(t, repo.Create(ctx, newContractDocument(newContractOwner(), "other.pdf", base), models.Quota{}, nil))
+
+		var names []string
+		position := ""
+		for pages := 0; pages < 5; pages++ {
+			page, next, err := repo.ListPage(ctx, ownerID, newestFirst, models.DocumentFilter{}, 2, position)
+			require.NoError(t, err)
+			for _, document := range page {
+				names = append(names, document.Filename)
+			}
+			if next == "" {
+				break
+			}
+			position = next
+		}
+		assert.Equal(t, []string{"file-4.pdf", "file-3.pdf", "file-2.pdf", "file-1.pdf", "file-0.pdf"}, names)
+
+		page, _, err := repo.ListPage(ctx, ownerID, models.DocumentSort{Field: models.DocumentSortFilename, Order: models.SortOrderAsc}, models.DocumentFilter{}, 2, "")
+		require.NoError(t, err)
+		require.Len(t, page, 2)
+		assert.Equal(t, "file-0.pdf", page[0].Filename)
+		assert.Equal(t, "file-1.pdf", page[1].Filename)
+
+		listed, total, err := repo.List(ctx, ownerID, 10, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), total)
+		assert.Len(t, listed, 5)
+	})
+
+	t.Run("ListDefaultsToNewestFirst", func(t *testing.T) {
+		repo := newBackend(t).documents
+		ownerID := newContractOwner()
+		base := time.Now().Add(-time.Hour)
+		for _, minute := range []int{2, 0, 4, 1, 3} {
+			require.NoError(t, repo.Create(ctx, newContractDocument(ownerID, fmt.Sprintf("file-%d.pdf", minute), base.Add(time.Duration(minute)*time.Minute)), models.Quota{}, nil))
+		}
+
+		var names []string
+		for offset := 0; offset < 5; offset += 2 {
+			page, total, err := repo.List(ctx, ownerID, 2, offset)
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), total)
+			for _, document := range page {
+				names = append(names, document.Filename)
+			}
+		}
+		assert.Equal(t, []string{"file-4.pdf", "file-3.pdf", "file-2.pdf", "file-1.pdf", "file-0.pdf"}, names, "pages by number come in the default order of ListPage")
+
+		sorted, _, err := repo.ListPage(ctx, ownerID, models.DocumentSort{}.WithDefaults(), models.DocumentFilter{}, 5, "")
+		require.NoError(t, err)
+		listed, _, err := repo.List(ctx, ownerID, 5, 0)
+		require.NoError(t, err)
+		assert.Equal(t, documentIDs(sorted), documentIDs(listed))
+	})
+
+	t.Run("ListPageFilters", func(t *testing.T) {
+		repo := newBackend(t).documents
+		ownerID := newContractOwner()
+		base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, file := range []struct {
+			name     string
+			mimeType string
+			status   models.AuthenticationStatus
+		}{
+			{"Passport.png", "image/png", models.AuthenticationStatusAuthenticated},
+			{"passport.pdf", "application/pdf", models.AuthenticationStatusAuthenticated},
+			{"diploma.jpg", "IMAGE/JPEG", models.AuthenticationStatusUnauthenticated},
+			{"old-passport.png", "image/png", models.AuthenticationStatusAuthenticated},
+		} {
+			document := newContractDocument(ownerID, file.name, base.AddDate(0, 0, -i))
+			document.MimeType = file.mimeType
+			document.AuthenticationStatus = file.status
+			require.NoError(t, repo.Create(ctx, document, models.Quota{}, nil))
+		}
+
+		names := func(filter models.DocumentFilter) []string {
+			page, _, err := repo.ListPage(ctx, ownerID, newestFirst, filter, 10, "")
+			require.NoError(t, err)
+			count, err := repo.CountByOwner(ctx, ownerID, filter)
+			require.NoError(t, err)
+
+			var names []string
+			for _, document := range page {
+				names = append(names, document.Filename)
+			}
+			assert.Equal(t, int64(len(names)), count, "the count follows the filter")
+			return names
+		}
+
+		assert.Equal(t, []string{"Passport.png", "diploma.jpg", "old-passport.png"}, names(models.DocumentFilter{MimeFamily: "Image"}))
+		assert.Equal(t, []string{"Passport.png", "passport.pdf"}, names(models.DocumentFilter{FilenameContains: "PASS", CreatedFrom: base.AddDate(0, 0, -1)}))
+		assert.Equal(t, []string{"old-passport.png"}, names(models.DocumentFilter{
+			AuthenticationStatus: models.AuthenticationStatusAuthenticated,
+			MimeFamily:           "image",
+			CreatedTo:            base.AddDate(0, 0, -2),
+		}))
+	})
+
+	t.Run("BlobReferences", func(t *testing.T) {
+		backend := newBackend(t)
+		repo := backend.documents
+		first := newContractDocument(newContractOwner(), "file.pdf", time.Now())
+		second := newContractDocument(newContractOwner(), "file.pdf", time.Now())
+		second.HashSHA256 = first.HashSHA256
+		second.ObjectKey = first.ObjectKey
+		require.NoError(t, repo.Create(ctx, first, models.Quota{}, nil))
+		require.NoError(t, repo.Create(ctx, second, models.Quota{}, nil))
+
+		_, err := repo.DeleteByID(ctx, first.ID, 0, nil)
+		require.NoError(t, err)
+		released, err := backend.blobRefs.ReleaseIfUnreferenced(ctx, first.ObjectKey)
+		require.NoError(t, err)
+		assert.False(t, released, "the object is still referenced by the second owner")
+
+		trashed, err := repo.Trash(ctx, second.ID, 0, time.Now(), nil)
+		require.NoError(t, err)
+		require.NotNil(t, trashed)
+		released, err = backend.blobRefs.ReleaseIfUnreferenced(ctx, first.ObjectKey)
+		require.NoError(t, err)
+		assert.False(t, released, "trashed documents still reference their object")
+
+		_, err = repo.DeleteByID(ctx, second.ID, 0, nil)
+		require.NoError(t, err)
+		unreferenced, err := backend.blobRefs.ListUnreferenced(ctx, 1000)
+		require.NoError(t, err)
+		assert.Contains(t, unreferenced, first.ObjectKey, "an object left unreferenced is listed until released")
+		released, err = backend.blobRefs.ReleaseIfUnreferenced(ctx, first.ObjectKey)
+		require.NoError(t, err)
+		assert.True(t, released)
+		unreferenced, err = backend.blobRefs.ListUnreferenced(ctx, 1000)
+		require.NoError(t, err)
+		assert.NotContains(t, unreferenced, first.ObjectKey, "a released object is not listed")
+
+		again, err := backend.blobRefs.ReleaseIfUnreferenced(ctx, first.ObjectKey)
+		require.NoError(t, err)
+		assert.False(t, again, "an object is released once")
+		third := newContractDocument(newContractOwner(), "file.pdf", time.Now())
+		third.HashSHA256 = first.HashSHA256
+		third.ObjectKey = first.ObjectKey
+		assert.ErrorIs(t, repo.Create(ctx, third, models.Quota{}, nil), interfaces.ErrObjectReleasing, "a released object cannot be referenced until deleted")
+
+		require.NoError(t, backend.blobRefs.FinishRelease(ctx, first.ObjectKey))
+		third.ID = ""
+		require.NoError(t, repo.Create(ctx, third, models.Quota{}, nil), "the content can be stored again once deleted")
+
+		unknown, err := backend.blobRefs.ReleaseIfUnreferenced(ctx, "objects/"+uuid.NewString())
+		require.NoError(t, err)
+		assert.False(t, unknown, "objects without a counter are kept")
+	})
+
+	t.Run("CreateEnforcesQuota", func(t *testing.T) {
+		repo := newBackend(t).documents
+		ownerID := newContractOwner()
+		usage := func() models.OwnerUsage {
+			usage, err := repo.GetUsage(ctx, ownerID)
+			require.NoError(t, err)
+			return *usage
+		}
+		assert.Equal(t, models.OwnerUsage{OwnerID: ownerID}, usage())
+
+		quota := models.Quota{MaxDocuments: 2, MaxBytes: 10}
+		first := newContractDocument(ownerID, "first.pdf", time.Now())
+		first.SizeBytes = 6
+		require.NoError(t, repo.Create(ctx, first, quota, nil))
+
+		tooLarge := newContractDocument(ownerID, "large.pdf", time.Now())
+		tooLarge.SizeBytes = 5
+		assert.ErrorIs(t, repo.Create(ctx, tooLarge, quota, nil), interfaces.ErrQuotaExceeded)
+
+		second := newContractDocument(ownerID, "second.pdf", time.Now())
+		second.SizeBytes = 4
+		require.NoError(t, repo.Create(ctx, second, quota, nil))
+		assert.Equal(t, models.OwnerUsage{OwnerID: ownerID, DocumentCount: 2, TotalBytes: 10}, usage())
+
+		third := newContractDocument(ownerID, "third.pdf", time.Now())
+		assert.ErrorIs(t, repo.Create(ctx, third, quota, nil), interfaces.ErrQuotaExceeded)
+		assert.Equal(t, models.OwnerUsage{OwnerID: ownerID, DocumentCount: 2, TotalBytes: 10}, usage(), "rejected documents take no quota")
+
+		_, err := repo.DeleteByID(ctx, first.ID, 0, nil)
+		require.NoError(t, err)
+		assert.Equal(t, models.OwnerUsage{OwnerID: ownerID, DocumentCount: 1, TotalBytes: 4}, usage())
+		require.NoError(t, repo.Create(ctx, third, quota, nil), "deletes release quota")
+
+		trashed, err := repo.TrashAllByOwnerID(ctx, ownerID, time.Now(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, 2, trashed)
+		assert.Equal(t, models.OwnerUsage{OwnerID: ownerID}, usage(), "trashed documents release their quota")
+
+		restored, err := repo.Restore(ctx, second.ID, 0, models.Quota{MaxDocuments: 1}, nil)
+		require.NoError(t, err)
+		require.NotNil(t, restored)
+		assert.Equal(t, models.OwnerUsage{OwnerID: ownerID, DocumentCount: 1, TotalBytes: 4}, usage(), "restores reserve quota again")
+		_, err = repo.Restore(ctx, third.ID, 0, models.Quota{MaxDocuments: 1}, nil)
+		assert.ErrorIs(t, err, interfaces.ErrQuotaExceeded)
+		assert.Equal(t, models.OwnerUsage{OwnerID: ownerID, DocumentCount: 1, TotalBytes: 4}, usage(), "rejected restores take no quota")
+
+		for _, document := range []*models.Document{second, third} {
+			_, err = repo.DeleteByID(ctx, document.ID, 0, nil)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, models.OwnerUsage{OwnerID: ownerID}, usage())
+	})
+}
+
+func TestProcessedMessageRepositoryContract_Memory(t *testing.T) {
+	runProcessedMessageRepositoryContract(t, repository.NewMemoryProcessedMessageRepository())
+}
+
+func TestProcessedMessageRepositoryContract_DynamoDB(t *testing.T) {
+	client := newDynamoDBClient(t)
+	ctx := context.Background()
+	tableName := "contract-" + uuid.NewString()[:8] + "-processed-messages"
+
+	// The table is provisioned with the infrastructure, not by the repository
+	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:            aws.String(tableName),
+		BillingMode:          types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{{AttributeName: aws.String("MessageID"), AttributeType: types.ScalarAttributeTypeS}},
+		KeySchema:            []types.KeySchemaElement{{AttributeName: aws.String("MessageID"), KeyType: types.KeyTypeHash}},
+	})
+	require.NoError(t, err)
+
+	runProcessedMessageRepositoryContract(t, repository.NewDynamoDBProcessedMessageRepository(client, tableName))
+}
+
+func TestProcessedMessageRepositoryContract_Postgres(t *testing.T) {
+	pool := newPostgresPool(t)
+	require.NoError(t, repository.MigratePostgres(context.Background(), pool))
+
+	runProcessedMessageRepositoryContract(t, repository.NewPostgresProcessedMessageRepository(pool))
+}
+
+// runProcessedMessageRepositoryContract checks the claim lifecycle every ProcessedMessageRepository
+// shares, on messages of its own
+func runProcessedMessageRepositoryContract(t *testing.T, repo interfaces.ProcessedMessageRepository) {
+	ctx := context.Background()
+
+	t.Run("Claim", func(t *testing.T) {
+		messageID := uuid.NewString()
+		first := models.NewProcessedMessageClaim(messageID, "test", time.Minute)
+		status, err := repo.Claim(ctx, first)
+		require.NoError(t, err)
+		assert.Equal(t, interfaces.ClaimAcquired, status)
+
+		second := models.NewProcessedMessageClaim(messageID, "test", time.Minute)
+		status, err = repo.Claim(ctx, second)
+		require.NoError(t, err)
+		assert.Equal(t, interfaces.ClaimInProgress, status, "a live lease blocks other consumers")
+		assert.Error(t, repo.Complete(ctx, second), "only the claim holder can complete")
+
+		require.NoError(t, repo.Complete(ctx, first))
+		status, err = repo.Claim(ctx, second)
+		require.NoError(t, err)
+		assert.Equal(t, interfaces.ClaimCompleted, status)
+	})
+
+	t.Run("ReleaseAndExpiredLease", func(t *testing.T) {
+		messageID := uuid.NewString()
+		claim := models.NewProcessedMessageClaim(messageID, "test", time.Minute)
+		_, err := repo.Claim(ctx, claim)
+		require.NoError(t, err)
+		require.NoError(t, repo.Release(ctx, claim))
+
+		retry := models.NewProcessedMessageClaim(messageID, "test", -time.Second)
+		status, err := repo.Claim(ctx, retry)
+		require.NoError(t, err)
+		assert.Equal(t, interfaces.ClaimAcquired, status, "a released message can be claimed again")
+
+		takeover := models.NewProcessedMessageClaim(messageID, "test", time.Minute)
+		status, err = repo.Claim(ctx, takeover)
+		require.NoError(t, err)
+		assert.Equal(t, interfaces.ClaimAcquired, status, "an expired lease can be taken over")
+
+		require.NoError(t, repo.Release(ctx, retry))
+		status, err = repo.Claim(ctx, models.NewProcessedMessageClaim(messageID, "test", time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, interfaces.ClaimInProgress, status, "a stale holder cannot release the new claim")
+	})
+}
@@ -0,0 +1,455 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+const (
+	// multipartDir holds the parts of unfinished multipart uploads, one directory per upload
+	multipartDir = ".multipart"
+
+	// Query parameters of signed URLs
+	expiresParam     = "expires"
+	contentTypeParam = "content_type"
+	sizeParam        = "size"
+	checksumParam    = "checksum"
+	signatureParam   = "signature"
+)
+
+// FileSystemStorage implements the ObjectStorage interface on a local directory. It is meant for
+// development and offline environments; objects are only reachable through HMAC-signed URLs served
+// by the application itself
+type FileSystemStorage struct {
+	bucketName string
+	root       string
+	baseURL    string
+	signingKey []byte
+}
+
+// NewFileSystemStorage creates a storage rooted at root/bucketName, creating the directory if needed.
+// Signed URLs point to baseURL, where the application serves them, and are signed with signingKey
+func NewFileSystemStorage(root, bucketName, baseURL string, signingKey []byte) (*FileSystemStorage, error) {
+	if len(signingKey) == 0 {
+		return nil, errors.New("a signing key is required")
+	}
+
+	bucketRoot := filepath.Join(root, bucketName)
+	if err := os.MkdirAll(bucketRoot, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	return &FileSystemStorage{
+		bucketName: bucketName,
+		root:       bucketRoot,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		signingKey: signingKey,
+	}, nil
+}
+
+// Put writes an object atomically, so readers never observe a partially written file
+func (storage *FileSystemStorage) Put(ctx context.Context, body io.Reader, objectKey, contentType string) error {
+	_, err := storage.writeObject(objectKey, func(w io.Writer) (int64, error) {
+		return io.Copy(w, body)
+	})
+	return err
+}
+
+// PublicURL returns an empty string; filesystem objects are only reachable through signed URLs
+func (storage *FileSystemStorage) PublicURL(objectKey string) string {
+	return ""
+}
+
+// Bucket returns the name of the directory holding the objects
+func (storage *FileSystemStorage) Bucket() string {
+	return storage.bucketName
+}
+
+// Delete removes an object. Deleting a missing object is not an error, as with S3
+func (storage *FileSystemStorage) Delete(ctx context.Context, objectKey string) error {
+	filePath, err := storage.objectPath(objectKey)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// GeneratePresignedURL creates a signed download URL valid for expiration
+func (storage *FileSystemStorage) GeneratePresignedURL(ctx context.Context, objectKey string, expiration time.Duration) (string, error) {
+	return storage.signedURL(http.MethodGet, objectKey, url.Values{}, time.Now().Add(expiration)), nil
+}
+
+// GeneratePresignedPut creates a signed PUT URL restricted to the size, content type and checksum of the policy
+func (storage *FileSystemStorage) GeneratePresignedPut(ctx context.Context, objectKey string, policy interfaces.UploadPolicy) (*interfaces.PresignedUpload, error) {
+	expiresAt := time.Now().Add(policy.Expiration)
+	return &interfaces.PresignedUpload{
+		Method:    http.MethodPut,
+		URL:       storage.signedURL(http.MethodPut, objectKey, policyParams(policy), expiresAt),
+		Headers:   map[string]string{"Content-Type": policy.ContentType},
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// GeneratePresignedPost creates a signed POST form restricted to the size, content type and checksum of the policy.
+// The signed parameters are returned as form fields, to be sent before the file, together with the
+// Content-Type field expected by S3-style POST uploads
+func (storage *FileSystemStorage) GeneratePresignedPost(ctx context.Context, objectKey string, policy interfaces.UploadPolicy) (*interfaces.PresignedUpload, error) {
+	expiresAt := time.Now().Add(policy.Expiration)
+	params := policyParams(policy)
+	storage.sign(http.MethodPost, objectKey, params, expiresAt)
+
+	fields := map[string]string{"Content-Type": policy.ContentType}
+	for name := range params {
+		fields[name] = params.Get(name)
+	}
+
+	return &interfaces.PresignedUpload{
+		Method:     http.MethodPost,
+		URL:        storage.objectURL(objectKey),
+		FormFields: fields,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+// Stat returns the size and content type of an object. No checksum is kept, so callers hash the content themselves
+func (storage *FileSystemStorage) Stat(ctx context.Context, objectKey string) (*interfaces.ObjectInfo, error) {
+	filePath, err := storage.objectPath(objectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, interfaces.ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &interfaces.ObjectInfo{
+		Size:        info.Size(),
+		ContentType: mime.TypeByExtension(path.Ext(objectKey)),
+	}, nil
+}
+
+// Open returns the content of an object. The returned reader also implements io.ReadSeeker
+func (storage *FileSystemStorage) Open(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	filePath, err := storage.objectPath(objectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath) // #nosec G304 -- objectPath keeps the path inside the storage root
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, interfaces.ErrObjectNotFound
+	}
+	return file, err
+}
+
+// OpenSigned verifies a signed download URL and opens the object
+func (storage *FileSystemStorage) OpenSigned(ctx context.Context, objectKey string, params url.Values) (io.ReadCloser, *interfaces.ObjectInfo, error) {
+	if err := storage.verify(http.MethodGet, objectKey, params); err != nil {
+		return nil, nil, err
+	}
+
+	info, err := storage.Stat(ctx, objectKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := storage.Open(ctx, objectKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, info, nil
+}
+
+// PutSigned verifies a signed PUT or POST upload and stores body if it matches the signed policy
+func (storage *FileSystemStorage) PutSigned(ctx context.Context, method, objectKey string, params url.Values, contentType string, body io.Reader) error {
+	if method != http.MethodPut && method != http.MethodPost {
+		return interfaces.ErrInvalidSignature
+	}
+	if err := storage.verify(method, objectKey, params); err != nil {
+		return err
+	}
+
+	size, err := strconv.ParseInt(params.Get(sizeParam), 10, 64)
+	if err != nil || contentType != params.Get(contentTypeParam) {
+		return interfaces.ErrUploadPolicyViolation
+	}
+
+	reader := &policyReader{
+		reader:    io.LimitReader(body, size+1),
+		hash:      sha256.New(),
+		remaining: size,
+		checksum:  params.Get(checksumParam),
+	}
+	_, err = storage.writeObject(objectKey, func(w io.Writer) (int64, error) {
+		return io.Copy(w, reader)
+	})
+	return err
+}
+
+// NewStagingKey returns a fresh key under the staging prefix
+func (storage *FileSystemStorage) NewStagingKey() string {
+	return stagingPrefix + uuid.NewString()
+}
+
+// PutStaged writes body to a new staging key
+func (storage *FileSystemStorage) PutStaged(ctx context.Context, body io.Reader, contentType string) (string, int64, error) {
+	stagingKey := storage.NewStagingKey()
+	size, err := storage.writeObject(stagingKey, func(w io.Writer) (int64, error) {
+		return io.Copy(w, body)
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return stagingKey, size, nil
+}
+
+// PromoteStaged renames a staged object to its final key, or removes it if the final key already exists
+func (storage *FileSystemStorage) PromoteStaged(ctx context.Context, stagingKey, objectKey, contentType string) error {
+	stagingPath, err := storage.objectPath(stagingKey)
+	if err != nil {
+		return err
+	}
+	objectPath, err := storage.objectPath(objectKey)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(objectPath); err == nil {
+		return storage.Delete(ctx, stagingKey)
+	}
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0o750); err != nil {
+		return err
+	}
+	return os.Rename(stagingPath, objectPath)
+}
+
+// DiscardStaged removes a staged object
+func (storage *FileSystemStorage) DiscardStaged(ctx context.Context, stagingKey string) error {
+	return storage.Delete(ctx, stagingKey)
+}
+
+// CreateStagedMultipart reserves a staging key and a directory for the parts of the upload
+func (storage *FileSystemStorage) CreateStagedMultipart(ctx context.Context, contentType string) (string, string, error) {
+	uploadID := uuid.NewString()
+	partsPath, err := storage.objectPath(path.Join(multipartDir, uploadID))
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(partsPath, 0o750); err != nil {
+		return "", "", err
+	}
+	return storage.NewStagingKey(), uploadID, nil
+}
+
+// UploadStagedPart writes one part of a multipart upload
+func (storage *FileSystemStorage) UploadStagedPart(ctx context.Context, stagingKey, uploadID string, partNumber int32, body io.Reader, size int64) (string, error) {
+	written, err := storage.writeObject(partKey(uploadID, partNumber), func(w io.Writer) (int64, error) {
+		return io.Copy(w, body)
+	})
+	if err != nil {
+		return "", err
+	}
+	if written != size {
+		return "", fmt.Errorf("part %d has %d bytes, expected %d", partNumber, written, size)
+	}
+	return strconv.Itoa(int(partNumber)), nil
+}
+
+// CompleteStagedMultipart concatenates the parts into the staged object and removes them
+func (storage *FileSystemStorage) CompleteStagedMultipart(ctx context.Context, stagingKey, uploadID string, parts []models.UploadPart) error {
+	_, err := storage.writeObject(stagingKey, func(w io.Writer) (int64, error) {
+		var total int64
+		for _, part := range parts {
+			body, err := storage.Open(ctx, partKey(uploadID, part.Number))
+			if err != nil {
+				return total, fmt.Errorf("part %d: %w", part.Number, err)
+			}
+			n, err := io.Copy(w, body)
+			_ = body.Close()
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+		return total, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return storage.AbortStagedMultipart(ctx, stagingKey, uploadID)
+}
+
+// AbortStagedMultipart removes the parts of a multipart upload
+func (storage *FileSystemStorage) AbortStagedMultipart(ctx context.Context, stagingKey, uploadID string) error {
+	partsPath, err := storage.objectPath(path.Join(multipartDir, uploadID))
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(partsPath)
+}
+
+// writeObject writes an object to a temporary file next to its final path and renames it into place
+// once write succeeds. On failure the temporary file is removed and the previous object, if any, is kept
+func (storage *FileSystemStorage) writeObject(objectKey string, write func(io.Writer) (int64, error)) (int64, error) {
+	filePath, err := storage.objectPath(objectKey)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o750); err != nil {
+		return 0, err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(filePath), ".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	tempPath := file.Name()
+
+	written, err := write(file)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, filePath)
+	}
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return 0, err
+	}
+	return written, nil
+}
+
+// objectPath maps an object key to a path inside the storage root. Keys are cleaned as absolute
+// paths first, so ".." segments cannot escape the root
+func (storage *FileSystemStorage) objectPath(objectKey string) (string, error) {
+	cleaned := path.Clean("/" + objectKey)
+	if cleaned == "/" {
+		return "", fmt.Errorf("invalid object key %q", objectKey)
+	}
+	return filepath.Join(storage.root, filepath.FromSlash(cleaned)), nil
+}
+
+// objectURL returns the unsigned URL of an object under the base URL
+func (storage *FileSystemStorage) objectURL(objectKey string) string {
+	segments := strings.Split(strings.TrimPrefix(path.Clean("/"+objectKey), "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return storage.baseURL + "/" + strings.Join(segments, "/")
+}
+
+// signedURL returns the URL of an object carrying params and a signature valid until expiresAt
+func (storage *FileSystemStorage) signedURL(method, objectKey string, params url.Values, expiresAt time.Time) string {
+	storage.sign(method, objectKey, params, expiresAt)
+	return storage.objectURL(objectKey) + "?" + params.Encode()
+}
+
+// sign adds the expiration and the signature of the request to params
+func (storage *FileSystemStorage) sign(method, objectKey string, params url.Values, expiresAt time.Time) {
+	params.Set(expiresParam, strconv.FormatInt(expiresAt.Unix(), 10))
+	params.Set(signatureParam, storage.signature(method, objectKey, params))
+}
+
+// verify checks that params carry a valid, unexpired signature for the request
+func (storage *FileSystemStorage) verify(method, objectKey string, params url.Values) error {
+	expires, err := strconv.ParseInt(params.Get(expiresParam), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return interfaces.ErrInvalidSignature
+	}
+
+	expected := storage.signature(method, objectKey, params)
+	if !hmac.Equal([]byte(expected), []byte(params.Get(signatureParam))) {
+		return interfaces.ErrInvalidSignature
+	}
+	return nil
+}
+
+// signature computes the HMAC of the method, the cleaned key and the signed parameters
+func (storage *FileSystemStorage) signature(method, objectKey string, params url.Values) string {
+	mac := hmac.New(sha256.New, storage.signingKey)
+	for _, value := range []string{
+		method,
+		path.Clean("/" + objectKey),
+		params.Get(expiresParam),
+		params.Get(contentTypeParam),
+		params.Get(sizeParam),
+		params.Get(checksumParam),
+	} {
+		_, _ = mac.Write([]byte(value))
+		_, _ = mac.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// policyParams returns the signed parameters describing an upload policy
+func policyParams(policy interfaces.UploadPolicy) url.Values {
+	params := url.Values{}
+	params.Set(contentTypeParam, policy.ContentType)
+	params.Set(sizeParam, strconv.FormatInt(policy.Size, 10))
+	if policy.ChecksumSHA256 != "" {
+		params.Set(checksumParam, policy.ChecksumSHA256)
+	}
+	return params
+}
+
+// partKey returns the key of one part of a multipart upload
+func partKey(uploadID string, partNumber int32) string {
+	return path.Join(multipartDir, uploadID, fmt.Sprintf("%05d", partNumber))
+}
+
+// policyReader fails with ErrUploadPolicyViolation when the body it wraps is not exactly the signed
+// size or does not match the signed base64 SHA256
+type policyReader struct {
+	reader    io.Reader
+	hash      hash.Hash
+	remaining int64
+	checksum  string
+}
+
+func (r *policyReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	_, _ = r.hash.Write(p[:n])
+	r.remaining -= int64(n)
+
+	if r.remaining < 0 {
+		return n, interfaces.ErrUploadPolicyViolation
+	}
+	if err == io.EOF {
+		if r.remaining != 0 {
+			return n, interfaces.ErrUploadPolicyViolation
+		}
+		if r.checksum != "" && base64.StdEncoding.EncodeToString(r.hash.Sum(nil)) != r.checksum {
+			return n, interfaces.ErrUploadPolicyViolation
+		}
+	}
+	return n, err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net/http"
 	"path"
 	"strings"
 	"time"
@@ -13,6 +14,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
 	"sync"
+
+	"github.com/kristianrpo/document-management-microservice/internal/application/interfaces"
+	"github.com/kristianrpo/document-management-microservice/internal/infrastructure/metrics"
 )
 
 // S3Client implements the ObjectStorage interface using AWS S3 or compatible storage (MinIO)
@@ -20,20 +24,31 @@ type S3Client struct {
 	bucketName    string
 	publicBaseURL string
 	s3Client      *s3.Client
+	metrics       *metrics.PrometheusMetrics
 	ensureOnce    sync.Once
 }
 
 // NewS3Client creates a new S3 client for object storage operations
-func NewS3Client(bucketName, publicBaseURL string, s3APIClient *s3.Client) *S3Client {
+// metricsCollector may be nil, in which case upload stage timings are not recorded
+func NewS3Client(bucketName, publicBaseURL string, s3APIClient *s3.Client, metricsCollector *metrics.PrometheusMetrics) *S3Client {
 	return &S3Client{
 		bucketName:    bucketName,
 		publicBaseURL: publicBaseURL,
 		s3Client:      s3APIClient,
+		metrics:       metricsCollector,
 	}
 }
 
 // Put uploads an object to S3 with the specified key and content type
 func (client *S3Client) Put(ctx context.Context, body io.Reader, objectKey, contentType string) error {
+	start := time.Now()
+	err := client.put(ctx, body, objectKey, contentType)
+	client.recordUpload(start, err)
+	return err
+}
+
+// put uploads an object in a single request, creating the bucket and retrying once if it is missing
+func (client *S3Client) put(ctx context.Context, body io.Reader, objectKey, contentType string) error {
 	// Ensure bucket exists once per process (best-effort)
 	client.ensureOnce.Do(func() {
 		_ = client.ensureBucket(ctx)
@@ -114,6 +129,120 @@ func (client *S3Client) GeneratePresignedURL(ctx context.Context, objectKey stri
 	return request.URL, nil
 }
 
+// GeneratePresignedPut creates a pre-signed PUT request bound to the content type, size and,
+// when provided, the SHA256 checksum of the policy. S3 rejects uploads that do not match
+func (client *S3Client) GeneratePresignedPut(ctx context.Context, objectKey string, policy interfaces.UploadPolicy) (*interfaces.PresignedUpload, error) {
+	client.ensureOnce.Do(func() {
+		_ = client.ensureBucket(ctx)
+	})
+
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(client.bucketName),
+		Key:           aws.String(objectKey),
+		ContentType:   aws.String(policy.ContentType),
+		ContentLength: aws.Int64(policy.Size),
+	}
+	if policy.ChecksumSHA256 != "" {
+		input.ChecksumSHA256 = aws.String(policy.ChecksumSHA256)
+	}
+
+	presignClient := s3.NewPresignClient(client.s3Client)
+	request, err := presignClient.PresignPutObject(ctx, input, s3.WithPresignExpires(policy.Expiration))
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]string, len(request.SignedHeader))
+	for name, values := range request.SignedHeader {
+		if strings.EqualFold(name, "Host") || len(values) == 0 {
+			continue
+		}
+		headers[name] = values[0]
+	}
+
+	return &interfaces.PresignedUpload{
+		Method:    http.MethodPut,
+		URL:       request.URL,
+		Headers:   headers,
+		ExpiresAt: time.Now().Add(policy.Expiration),
+	}, nil
+}
+
+// GeneratePresignedPost creates a pre-signed POST form policy that only accepts the content type
+// and exact size of the policy
+func (client *S3Client) GeneratePresignedPost(ctx context.Context, objectKey string, policy interfaces.UploadPolicy) (*interfaces.PresignedUpload, error) {
+	client.ensureOnce.Do(func() {
+		_ = client.ensureBucket(ctx)
+	})
+
+	conditions := []interface{}{
+		[]interface{}{"content-length-range", policy.Size, policy.Size},
+		map[string]string{"Content-Type": policy.ContentType},
+	}
+
+	presignClient := s3.NewPresignClient(client.s3Client)
+	request, err := presignClient.PresignPostObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(client.bucketName),
+		Key:    aws.String(objectKey),
+	}, func(options *s3.PresignPostOptions) {
+		options.Expires = policy.Expiration
+		options.Conditions = conditions
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string, len(request.Values)+1)
+	for name, value := range request.Values {
+		fields[name] = value
+	}
+	fields["Content-Type"] = policy.ContentType
+
+	return &interfaces.PresignedUpload{
+		Method:     http.MethodPost,
+		URL:        request.URL,
+		FormFields: fields,
+		ExpiresAt:  time.Now().Add(policy.Expiration),
+	}, nil
+}
+
+// Stat returns the size, content type and full-object SHA256 checksum of an object
+func (client *S3Client) Stat(ctx context.Context, objectKey string) (*interfaces.ObjectInfo, error) {
+	output, err := client.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(client.bucketName),
+		Key:          aws.String(objectKey),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, interfaces.ErrObjectNotFound
+		}
+		return nil, err
+	}
+
+	info := &interfaces.ObjectInfo{
+		Size:        aws.ToInt64(output.ContentLength),
+		ContentType: aws.ToString(output.ContentType),
+	}
+	// Composite checksums of multipart objects are not the SHA256 of the content
+	if output.ChecksumType != types.ChecksumTypeComposite {
+		info.ChecksumSHA256 = aws.ToString(output.ChecksumSHA256)
+	}
+	return info, nil
+}
+
+// Open returns a reader over the content of an object
+func (client *S3Client) Open(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	output, err := client.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(client.bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.Body, nil
+}
+
 // ensureBucket checks if the bucket exists and creates it if missing. Best-effort: returns nil
 // if the bucket already exists or is created successfully. Returns error only on definitive failures.
 func (client *S3Client) ensureBucket(ctx context.Context) error {
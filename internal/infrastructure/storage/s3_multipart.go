@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/google/uuid"
+
+	"github.com/kristianrpo/document-management-microservice/internal/domain/models"
+)
+
+const (
+	// stagingPrefix is the key prefix of objects whose digest is not known yet
+	stagingPrefix = "staging/"
+
+	// multipartPartSize is the size of each uploaded part; one buffer of this size is reused
+	// for the whole upload, which bounds memory regardless of the file size
+	multipartPartSize = 8 << 20
+
+	// maxMultipartParts is the maximum number of parts accepted by S3 in a single upload
+	maxMultipartParts = 10000
+
+	// maxCopyObjectSize is the largest object S3 can copy with a single CopyObject call
+	maxCopyObjectSize = 5 << 30
+
+	// copyPartSize is the size of each range copied when promoting objects larger than maxCopyObjectSize
+	copyPartSize = 512 << 20
+)
+
+// NewStagingKey returns a fresh key under the staging prefix
+func (client *S3Client) NewStagingKey() string {
+	return stagingPrefix + uuid.NewString()
+}
+
+// PutStaged streams body to a new staging key using the multipart API. Bodies smaller than
+// one part are sent with a single PutObject
+func (client *S3Client) PutStaged(ctx context.Context, body io.Reader, contentType string) (string, int64, error) {
+	client.ensureOnce.Do(func() {
+		_ = client.ensureBucket(ctx)
+	})
+
+	start := time.Now()
+	stagingKey := client.NewStagingKey()
+	size, err := client.streamMultipart(ctx, body, stagingKey, contentType)
+	client.recordStage("stream", start, err)
+	client.recordUpload(start, err)
+	if err != nil {
+		return "", 0, err
+	}
+	return stagingKey, size, nil
+}
+
+// PromoteStaged copies a staged object to its final key and removes the staged copy.
+// Objects are content-addressed, so an existing final key already holds the same bytes
+func (client *S3Client) PromoteStaged(ctx context.Context, stagingKey, objectKey, contentType string) error {
+	start := time.Now()
+	err := client.promote(ctx, stagingKey, objectKey, contentType)
+	client.recordStage("promote", start, err)
+	if err != nil {
+		return err
+	}
+
+	// The object is already in place; a leftover staging key is expired by the bucket lifecycle rule
+	_ = client.Delete(ctx, stagingKey)
+	return nil
+}
+
+// DiscardStaged removes a staged object
+func (client *S3Client) DiscardStaged(ctx context.Context, stagingKey string) error {
+	start := time.Now()
+	err := client.Delete(ctx, stagingKey)
+	client.recordStage("discard", start, err)
+	return err
+}
+
+// streamMultipart reads body part by part into a reusable buffer and uploads each part as soon as it is full
+func (client *S3Client) streamMultipart(ctx context.Context, body io.Reader, objectKey, contentType string) (int64, error) {
+	buffer := make([]byte, multipartPartSize)
+
+	n, readErr := io.ReadFull(body, buffer)
+	if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+		_, err := client.s3Client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(client.bucketName),
+			Key:           aws.String(objectKey),
+			Body:          bytes.NewReader(buffer[:n]),
+			ContentLength: aws.Int64(int64(n)),
+			ContentType:   aws.String(contentType),
+			ACL:           types.ObjectCannedACLPrivate,
+		})
+		if err != nil {
+			return 0, err
+		}
+		return int64(n), nil
+	}
+	if readErr != nil {
+		return 0, readErr
+	}
+
+	created, err := client.s3Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(client.bucketName),
+		Key:         aws.String(objectKey),
+		ContentType: aws.String(contentType),
+		ACL:         types.ObjectCannedACLPrivate,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+
+	var (
+		parts []types.CompletedPart
+		total int64
+	)
+	for partNumber := int32(1); ; partNumber++ {
+		if partNumber > maxMultipartParts {
+			return 0, client.abortMultipart(ctx, objectKey, created.UploadId,
+				fmt.Errorf("file exceeds %d parts of %d bytes", maxMultipartParts, multipartPartSize))
+		}
+
+		partStart := time.Now()
+		uploaded, err := client.s3Client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(client.bucketName),
+			Key:           aws.String(objectKey),
+			UploadId:      created.UploadId,
+			PartNumber:    aws.Int32(partNumber),
+			Body:          bytes.NewReader(buffer[:n]),
+			ContentLength: aws.Int64(int64(n)),
+		})
+		client.recordStage("part", partStart, err)
+		if err != nil {
+			return 0, client.abortMultipart(ctx, objectKey, created.UploadId, fmt.Errorf("failed to upload part %d: %w", partNumber, err))
+		}
+		parts = append(parts, types.CompletedPart{ETag: uploaded.ETag, PartNumber: aws.Int32(partNumber)})
+		total += int64(n)
+
+		n, readErr = io.ReadFull(body, buffer)
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil && readErr != io.ErrUnexpectedEOF {
+			return 0, client.abortMultipart(ctx, objectKey, created.UploadId, readErr)
+		}
+	}
+
+	_, err = client.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(client.bucketName),
+		Key:             aws.String(objectKey),
+		UploadId:        created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return 0, client.abortMultipart(ctx, objectKey, created.UploadId, fmt.Errorf("failed to complete multipart upload: %w", err))
+	}
+	return total, nil
+}
+
+// abortMultipart releases the parts of a failed multipart upload and returns the original error.
+// A fresh context is used so that parts are released even if the request was cancelled
+func (client *S3Client) abortMultipart(ctx context.Context, objectKey string, uploadID *string, cause error) error {
+	abortCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	_, _ = client.s3Client.AbortMultipartUpload(abortCtx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(client.bucketName),
+		Key:      aws.String(objectKey),
+		UploadId: uploadID,
+	})
+	return cause
+}
+
+// promote copies stagingKey to objectKey unless objectKey already exists
+func (client *S3Client) promote(ctx context.Context, stagingKey, objectKey, contentType string) error {
+	if _, err := client.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(client.bucketName),
+		Key:    aws.String(objectKey),
+	}); err == nil {
+		return nil
+	} else if !isNotFound(err) {
+		return fmt.Errorf("failed to check object %s: %w", objectKey, err)
+	}
+
+	staged, err := client.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(client.bucketName),
+		Key:    aws.String(stagingKey),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read staged object %s: %w", stagingKey, err)
+	}
+
+	// Staging keys are generated by this client and never need URL escaping
+	copySource := client.bucketName + "/" + stagingKey
+	size := aws.ToInt64(staged.ContentLength)
+	if size <= maxCopyObjectSize {
+		_, err := client.s3Client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:            aws.String(client.bucketName),
+			Key:               aws.String(objectKey),
+			CopySource:        aws.String(copySource),
+			ContentType:       aws.String(contentType),
+			MetadataDirective: types.MetadataDirectiveReplace,
+			ACL:               types.ObjectCannedACLPrivate,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to copy staged object: %w", err)
+		}
+		return nil
+	}
+
+	return client.copyMultipart(ctx, copySource, objectKey, contentType, size)
+}
+
+// copyMultipart copies an object larger than maxCopyObjectSize range by range
+func (client *S3Client) copyMultipart(ctx context.Context, copySource, objectKey, contentType string, size int64) error {
+	created, err := client.s3Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(client.bucketName),
+		Key:         aws.String(objectKey),
+		ContentType: aws.String(contentType),
+		ACL:         types.ObjectCannedACLPrivate,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create multipart copy: %w", err)
+	}
+
+	var parts []types.CompletedPart
+	partNumber := int32(1)
+	for offset := int64(0); offset < size; offset += copyPartSize {
+		last := min(offset+copyPartSize, size) - 1
+		copied, err := client.s3Client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:          aws.String(client.bucketName),
+			Key:             aws.String(objectKey),
+			UploadId:        created.UploadId,
+			PartNumber:      aws.Int32(partNumber),
+			CopySource:      aws.String(copySource),
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", offset, last)),
+		})
+		if err != nil {
+			return client.abortMultipart(ctx, objectKey, created.UploadId, fmt.Errorf("failed to copy part %d: %w", partNumber, err))
+		}
+		parts = append(parts, types.CompletedPart{ETag: copied.CopyPartResult.ETag, PartNumber: aws.Int32(partNumber)})
+		partNumber++
+	}
+
+	_, err = client.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(client.bucketName),
+		Key:             aws.String(objectKey),
+		UploadId:        created.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		return client.abortMultipart(ctx, objectKey, created.UploadId, fmt.Errorf("failed to complete multipart copy: %w", err))
+	}
+	return nil
+}
+
+// recordUpload records the duration of a whole upload, as its total stage, when metrics are
+// configured
+func (client *S3Client) recordUpload(start time.Time, err error) {
+	if client.metrics != nil {
+		client.metrics.RecordStorageOperation("upload", time.Since(start), err)
+	}
+}
+
+// recordStage records the duration of an upload stage when metrics are configured
+func (client *S3Client) recordStage(stage string, start time.Time, err error) {
+	if client.metrics != nil {
+		client.metrics.RecordStorageUploadStage(stage, time.Since(start), err)
+	}
+}
+
+// isNotFound returns true if the error corresponds to a missing object
+func isNotFound(err error) bool {
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code := apiErr.ErrorCode()
+		return code == "NotFound" || code == "NoSuchKey"
+	}
+	return false
+}
+
+// CreateStagedMultipart starts a multipart upload to a new staging key whose parts are written
+// by separate requests
+func (client *S3Client) CreateStagedMultipart(ctx context.Context, contentType string) (string, string, error) {
+	client.ensureOnce.Do(func() {
+		_ = client.ensureBucket(ctx)
+	})
+
+	stagingKey := client.NewStagingKey()
+	created, err := client.s3Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(client.bucketName),
+		Key:         aws.String(stagingKey),
+		ContentType: aws.String(contentType),
+		ACL:         types.ObjectCannedACLPrivate,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+	return stagingKey, aws.ToString(created.UploadId), nil
+}
+
+// UploadStagedPart writes one part of a staged multipart upload
+func (client *S3Client) UploadStagedPart(ctx context.Context, stagingKey, uploadID string, partNumber int32, body io.Reader, size int64) (string, error) {
+	start := time.Now()
+	uploaded, err := client.s3Client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(client.bucketName),
+		Key:           aws.String(stagingKey),
+		UploadId:      aws.String(uploadID),
+		PartNumber:    aws.Int32(partNumber),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+	})
+	client.recordStage("part", start, err)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+	}
+	return aws.ToString(uploaded.ETag), nil
+}
+
+// CompleteStagedMultipart assembles the uploaded parts into the staged object
+func (client *S3Client) CompleteStagedMultipart(ctx context.Context, stagingKey, uploadID string, parts []models.UploadPart) error {
+	completed := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completed = append(completed, types.CompletedPart{ETag: aws.String(part.ETag), PartNumber: aws.Int32(part.Number)})
+	}
+
+	start := time.Now()
+	_, err := client.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(client.bucketName),
+		Key:             aws.String(stagingKey),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	client.recordStage("complete", start, err)
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	return nil
+}
+
+// AbortStagedMultipart releases the parts of a staged multipart upload
+func (client *S3Client) AbortStagedMultipart(ctx context.Context, stagingKey, uploadID string) error {
+	_, err := client.s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(client.bucketName),
+		Key:      aws.String(stagingKey),
+		UploadId: aws.String(uploadID),
+	})
+	return err
+}